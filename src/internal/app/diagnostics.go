@@ -0,0 +1,127 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Diagnostics returns a plain-text dump of the state for bug reports, one
+// "key: value" line in a fixed order. Secrets are redacted: the password,
+// its confirmation and the comments are reduced to their length, and file,
+// keyfile and output template paths to their base names, also where they
+// appear in the status line, so the dump can be pasted into a public issue.
+func (s *State) Diagnostics() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var b strings.Builder
+	line := func(key string, value any) {
+		fmt.Fprintf(&b, "%s: %v\n", key, value)
+	}
+
+	line("version", Version)
+	line("os/arch", runtime.GOOS+"/"+runtime.GOARCH)
+	line("go", runtime.Version())
+
+	line("mode", s.Mode)
+	line("working", s.Working)
+	line("input", baseName(s.InputFile))
+	line("output", baseName(s.OutputFile))
+	line("files", len(s.AllFiles))
+	line("dropped files", len(s.OnlyFiles))
+	line("dropped folders", len(s.OnlyFolders))
+
+	line("password", redacted(s.Password))
+	line("confirm password", redacted(s.CPassword))
+	line("password strength", s.PasswordStrength)
+	line("min password score", s.MinPasswordScore)
+	keyfiles := make([]string, len(s.Keyfiles))
+	for i, path := range s.Keyfiles {
+		keyfiles[i] = baseName(path)
+	}
+	line("keyfiles", strings.Join(keyfiles, ", "))
+	line("keyfile ordered", s.KeyfileOrdered)
+	line("keyfile required", s.Keyfile)
+	line("comments", redacted(s.Comments))
+
+	line("paranoid", s.Paranoid)
+	line("reed-solomon", s.ReedSolomon)
+	line("deniability", s.Deniability)
+	line("compress", s.Compress)
+	line("keep archive", s.KeepArchive)
+	line("keep", s.Keep)
+	line("verify first", s.VerifyFirst)
+	line("auto unzip", s.AutoUnzip)
+	line("same level", s.SameLevel)
+	line("fast decode", s.FastDecode)
+	split := "off"
+	if s.Split {
+		split = s.SplitSize
+		if s.SplitSelected >= 0 && int(s.SplitSelected) < len(s.SplitUnits) {
+			split += " " + s.SplitUnits[s.SplitSelected]
+		}
+	}
+	line("split", split)
+	line("recursive", s.Recursively)
+	line("output template", baseName(s.OutputTemplate))
+	line("concurrency", s.Concurrency)
+	line("delete", s.Delete)
+	line("recombine", s.Recombine)
+
+	line("status", s.redactPaths(s.MainStatus))
+	line("progress", fmt.Sprintf("%.2f", s.Progress))
+	return b.String()
+}
+
+// redacted stands in for a secret, keeping only its length.
+func redacted(secret string) string {
+	return fmt.Sprintf("[redacted, len=%d]", len(secret))
+}
+
+// baseName is filepath.Base that leaves an empty path empty.
+func baseName(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
+// absPathRe matches an absolute path, "/..." or a drive letter followed by
+// a separator, that starts the text or follows a space, quote or bracket,
+// up to the next space, quote or colon; "3/10" and "MiB/s" do not match.
+var absPathRe = regexp.MustCompile(`(?:^|[\s"'(])(?:[A-Za-z]:)?[\\/][^\s"':]+`)
+
+// redactPaths reduces the paths in text to their base names: first the
+// paths known to the state, which may contain spaces or be relative, longest
+// first, then anything else that looks like an absolute path. Caller holds
+// s.mu.
+func (s *State) redactPaths(text string) string {
+	known := []string{s.InputFile, s.OutputFile, s.LastOutput, s.OutputTemplate}
+	known = append(known, s.AllFiles...)
+	known = append(known, s.OnlyFiles...)
+	known = append(known, s.OnlyFolders...)
+	known = append(known, s.Keyfiles...)
+	for _, path := range slices.Clone(known) {
+		if path != "" {
+			known = append(known, filepath.Dir(path))
+		}
+	}
+	slices.SortFunc(known, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	for _, path := range known {
+		if path != "" && path != "." {
+			text = strings.ReplaceAll(text, path, filepath.Base(path))
+		}
+	}
+	return absPathRe.ReplaceAllStringFunc(text, func(match string) string {
+		var lead string
+		if strings.ContainsRune(" \t\n\"'(", rune(match[0])) {
+			lead, match = match[:1], match[1:]
+		}
+		return lead + match[strings.LastIndexAny(match, `/\`)+1:]
+	})
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	state := NewState()
+	secretDir := filepath.Join("home", "alice", "secret-keys")
+	state.Mode = "encrypt"
+	state.InputFile = filepath.Join(secretDir, "taxes.pdf")
+	state.OutputFile = filepath.Join(secretDir, "taxes.pdf.pcv")
+	state.AllFiles = []string{state.InputFile}
+	state.Password = "correct horse battery"
+	state.CPassword = "correct horse battery"
+	state.Keyfiles = []string{filepath.Join(secretDir, "one.key"), filepath.Join(secretDir, "two.key")}
+	state.Comments = "private note"
+	state.Paranoid = true
+	state.ReedSolomon = true
+	state.Split = true
+	state.SplitSize = "100"
+	state.MainStatus = "open " + state.OutputFile + ": permission denied (3/10 done, backup in /mnt/backup/alice/old.pcv)"
+
+	dump := state.Diagnostics()
+
+	for _, leak := range []string{"correct horse battery", "private note", secretDir, "alice"} {
+		if strings.Contains(dump, leak) {
+			t.Errorf("diagnostics contain %q:\n%s", leak, dump)
+		}
+	}
+	for _, want := range []string{
+		"version: " + Version + "\n",
+		"os/arch: " + runtime.GOOS + "/" + runtime.GOARCH + "\n",
+		"mode: encrypt\n",
+		"input: taxes.pdf\n",
+		"password: [redacted, len=21]\n",
+		"keyfiles: one.key, two.key\n",
+		"comments: [redacted, len=12]\n",
+		"paranoid: true\n",
+		"reed-solomon: true\n",
+		"deniability: false\n",
+		"split: 100 MiB\n",
+		"status: open taxes.pdf.pcv: permission denied (3/10 done, backup in old.pcv)\n",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("diagnostics missing %q:\n%s", want, dump)
+		}
+	}
+
+	if again := state.Diagnostics(); again != dump {
+		t.Errorf("diagnostics not stable:\n%s\nvs\n%s", dump, again)
+	}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// IdleTimer clears the entered credentials once the UI has gone unused for a
+// while, so a password typed in and then left behind does not sit in memory
+// and on screen indefinitely. The UI calls Touch on every interaction and
+// Check periodically; a zero timeout disables it.
+type IdleTimer struct {
+	mu      sync.Mutex
+	state   *State
+	timeout time.Duration
+	last    time.Time
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+}
+
+// NewIdleTimer returns a timer for state that clears credentials after
+// timeout without interaction. The idle period starts now.
+func NewIdleTimer(state *State, timeout time.Duration) *IdleTimer {
+	t := &IdleTimer{state: state, timeout: timeout, now: time.Now}
+	t.last = t.now()
+	return t
+}
+
+// Enabled reports whether the timer has a timeout set.
+func (t *IdleTimer) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timeout > 0
+}
+
+// SetTimeout changes the timeout, e.g. when the user changes the setting,
+// and restarts the idle period. Zero disables the timer.
+func (t *IdleTimer) SetTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timeout = timeout
+	t.last = t.now()
+}
+
+// Touch records a UI interaction and restarts the idle period.
+func (t *IdleTimer) Touch() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last = t.now()
+}
+
+// Check clears the credentials if the timeout has passed since the last
+// Touch and no operation is running. It returns true if anything was
+// cleared, in which case the caller should blank the input widgets. While an
+// operation runs the idle period keeps restarting, so the timeout counts
+// from when the operation ended.
+func (t *IdleTimer) Check() bool {
+	if !t.Enabled() {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	if t.state.IsBusy() {
+		t.last = now
+		return false
+	}
+	if now.Sub(t.last) < t.timeout {
+		return false
+	}
+	t.last = now
+	return t.state.ClearCredentials()
+}
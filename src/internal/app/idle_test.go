@@ -0,0 +1,155 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for IdleTimer tests.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestIdleTimer(state *State, timeout time.Duration) (*IdleTimer, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	timer := NewIdleTimer(state, timeout)
+	timer.now = clock.now
+	timer.last = clock.now()
+	return timer, clock
+}
+
+func TestIdleTimerClearsAfterTimeout(t *testing.T) {
+	state := NewState()
+	state.Password = "secret"
+	state.CPassword = "secret"
+	state.PasswordStrength = 3
+	state.Keyfiles = []string{"key1.bin"}
+	state.KeyfileLabel = "Using 1 keyfile"
+	state.InputFile = "file.txt"
+
+	timer, clock := newTestIdleTimer(state, 5*time.Minute)
+
+	clock.advance(4 * time.Minute)
+	if timer.Check() {
+		t.Fatal("Check cleared credentials before the timeout")
+	}
+	if state.Password != "secret" {
+		t.Fatal("Password cleared before the timeout")
+	}
+
+	clock.advance(time.Minute)
+	if !timer.Check() {
+		t.Fatal("Check did not clear credentials after the timeout")
+	}
+	if state.Password != "" || state.CPassword != "" {
+		t.Error("Passwords were not cleared")
+	}
+	if state.PasswordStrength != 0 {
+		t.Errorf("PasswordStrength = %d; want 0", state.PasswordStrength)
+	}
+	if state.Keyfiles != nil {
+		t.Errorf("Keyfiles = %v; want nil", state.Keyfiles)
+	}
+	if state.KeyfileLabel != "None selected" {
+		t.Errorf("KeyfileLabel = %q; want 'None selected'", state.KeyfileLabel)
+	}
+	if state.InputFile != "file.txt" {
+		t.Error("Clearing credentials should leave the selected files alone")
+	}
+
+	// Nothing left to clear
+	clock.advance(10 * time.Minute)
+	if timer.Check() {
+		t.Error("Check reported a clear with no credentials entered")
+	}
+}
+
+func TestIdleTimerTouchResets(t *testing.T) {
+	state := NewState()
+	state.Password = "secret"
+
+	timer, clock := newTestIdleTimer(state, 5*time.Minute)
+
+	clock.advance(4 * time.Minute)
+	timer.Touch()
+	clock.advance(4 * time.Minute)
+	if timer.Check() || state.Password == "" {
+		t.Fatal("Password cleared although the UI was used 4 minutes ago")
+	}
+
+	clock.advance(time.Minute)
+	if !timer.Check() || state.Password != "" {
+		t.Error("Password not cleared 5 minutes after the last interaction")
+	}
+}
+
+func TestIdleTimerWaitsForOperation(t *testing.T) {
+	state := NewState()
+	state.Password = "secret"
+	state.Working = true
+
+	timer, clock := newTestIdleTimer(state, 5*time.Minute)
+
+	clock.advance(time.Hour)
+	if timer.Check() || state.Password == "" {
+		t.Fatal("Password cleared during an operation")
+	}
+
+	// The idle period restarts when the operation ends
+	state.Working = false
+	clock.advance(4 * time.Minute)
+	if timer.Check() {
+		t.Fatal("Password cleared less than the timeout after the operation")
+	}
+	clock.advance(time.Minute)
+	if !timer.Check() {
+		t.Error("Password not cleared the timeout after the operation")
+	}
+}
+
+func TestIdleTimerDisabled(t *testing.T) {
+	state := NewState()
+	state.Password = "secret"
+
+	timer, clock := newTestIdleTimer(state, 0)
+	if timer.Enabled() {
+		t.Error("Zero timeout should disable the timer")
+	}
+	clock.advance(24 * time.Hour)
+	if timer.Check() || state.Password == "" {
+		t.Error("Disabled timer cleared the password")
+	}
+}
+
+func TestIdleTimerSetTimeout(t *testing.T) {
+	state := NewState()
+	state.Password = "secret"
+
+	timer, clock := newTestIdleTimer(state, 0)
+
+	// Turning the timer on starts the idle period then, not at creation
+	clock.advance(time.Hour)
+	timer.SetTimeout(5 * time.Minute)
+	if !timer.Enabled() {
+		t.Fatal("Timer not enabled after setting a timeout")
+	}
+	clock.advance(4 * time.Minute)
+	if timer.Check() || state.Password == "" {
+		t.Fatal("Password cleared before the new timeout")
+	}
+	clock.advance(time.Minute)
+	if !timer.Check() || state.Password != "" {
+		t.Fatal("Password not cleared after the new timeout")
+	}
+
+	state.Password = "secret"
+	timer.SetTimeout(0)
+	clock.advance(time.Hour)
+	if timer.Enabled() || timer.Check() || state.Password == "" {
+		t.Error("Password cleared after the timer was turned off")
+	}
+}
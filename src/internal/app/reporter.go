@@ -2,11 +2,18 @@
 package app
 
 import (
+	"strings"
 	"sync"
+	"time"
 
 	"Picocrypt-NG/internal/volume"
 )
 
+// DefaultMaxUpdatesPerSecond is the default cap on progress and refresh
+// callbacks. The crypto loops report every MiB, which on fast disks would
+// otherwise queue thousands of UI refreshes per second.
+const DefaultMaxUpdatesPerSecond = 20
+
 // Ensure UIReporter implements volume.ProgressReporter
 var _ volume.ProgressReporter = (*UIReporter)(nil)
 
@@ -22,8 +29,24 @@ type UIReporter struct {
 	OnUpdate    func()
 	CheckCancel func() bool
 
+	// OnWarn receives non-fatal advisories; nil drops them. Never throttled.
+	OnWarn func(msg string)
+
+	// MaxUpdatesPerSecond caps how often OnStatus, OnProgress and OnUpdate
+	// fire. Intermediate updates inside the window are dropped; a terminal
+	// progress (fraction >= 1) and the refresh after it are always delivered,
+	// and so are a status that starts a new phase and the refresh after it.
+	// Zero or negative disables throttling.
+	MaxUpdatesPerSecond int
+
 	// Internal state
-	cancelled bool
+	cancelled    bool
+	now          func() time.Time // Clock, replaceable in tests
+	lastStatus   time.Time        // Last time OnStatus fired
+	lastProgress time.Time        // Last time OnProgress fired
+	lastUpdate   time.Time        // Last time OnUpdate fired
+	forceUpdate  bool             // Deliver the next Update regardless of rate
+	phase        string           // Phase of the last status, see statusPhase
 }
 
 // NewUIReporter creates a new UI reporter with the given callbacks.
@@ -40,19 +63,70 @@ func NewUIReporter(
 		OnCanCancel: onCanCancel,
 		OnUpdate:    onUpdate,
 		CheckCancel: checkCancel,
+
+		MaxUpdatesPerSecond: DefaultMaxUpdatesPerSecond,
+		now:                 time.Now,
 	}
 }
 
+// allow reports whether a throttled callback may fire now and, if so,
+// records the emission time in last. Callers must hold r.mu.
+func (r *UIReporter) allow(last *time.Time) bool {
+	if r.MaxUpdatesPerSecond <= 0 || r.now == nil {
+		return true
+	}
+	now := r.now()
+	if !last.IsZero() && now.Sub(*last) < time.Second/time.Duration(r.MaxUpdatesPerSecond) {
+		return false
+	}
+	*last = now
+	return true
+}
+
+// statusPhase returns the part of a status that names the phase: the verb of
+// a "<verb> at <speed> MiB/s (ETA: ...)" rate status, or the whole text, so
+// a new rate is not mistaken for a phase change.
+func statusPhase(text string) string {
+	verb, _, _ := strings.Cut(text, " at ")
+	return verb
+}
+
 // SetStatus implements volume.ProgressReporter.
+// Calls within a phase, such as each new rate, are rate limited by
+// MaxUpdatesPerSecond. A status that starts a new phase is always delivered
+// and lets the next Update through the throttle.
 func (r *UIReporter) SetStatus(text string) {
-	if r.OnStatus != nil {
+	r.mu.Lock()
+	var emit bool
+	if phase := statusPhase(text); phase != r.phase {
+		r.phase = phase
+		r.forceUpdate = true
+		emit = true
+		if r.now != nil {
+			// A new phase still starts a new window
+			r.lastStatus = r.now()
+		}
+	} else {
+		emit = r.allow(&r.lastStatus)
+	}
+	r.mu.Unlock()
+
+	if emit && r.OnStatus != nil {
 		r.OnStatus(text)
 	}
 }
 
 // SetProgress implements volume.ProgressReporter.
+// Calls are rate limited by MaxUpdatesPerSecond; completion always passes.
 func (r *UIReporter) SetProgress(fraction float32, info string) {
-	if r.OnProgress != nil {
+	r.mu.Lock()
+	emit := fraction >= 1 || r.allow(&r.lastProgress)
+	if fraction >= 1 {
+		r.forceUpdate = true
+	}
+	r.mu.Unlock()
+
+	if emit && r.OnProgress != nil {
 		r.OnProgress(fraction, info)
 	}
 }
@@ -65,8 +139,19 @@ func (r *UIReporter) SetCanCancel(can bool) {
 }
 
 // Update implements volume.ProgressReporter.
+// Calls are rate limited by MaxUpdatesPerSecond, except the refresh that
+// follows a terminal progress update or a phase change.
 func (r *UIReporter) Update() {
-	if r.OnUpdate != nil {
+	r.mu.Lock()
+	emit := r.forceUpdate || r.allow(&r.lastUpdate)
+	if r.forceUpdate && r.now != nil {
+		// A forced refresh still starts a new window
+		r.lastUpdate = r.now()
+	}
+	r.forceUpdate = false
+	r.mu.Unlock()
+
+	if emit && r.OnUpdate != nil {
 		r.OnUpdate()
 	}
 }
@@ -85,6 +170,13 @@ func (r *UIReporter) IsCancelled() bool {
 	return false
 }
 
+// Warn implements volume.ProgressReporter.
+func (r *UIReporter) Warn(msg string) {
+	if r.OnWarn != nil {
+		r.OnWarn(msg)
+	}
+}
+
 // Cancel marks the operation as cancelled.
 func (r *UIReporter) Cancel() {
 	r.mu.Lock()
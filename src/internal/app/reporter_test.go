@@ -1,8 +1,10 @@
 package app
 
 import (
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewUIReporter(t *testing.T) {
@@ -57,6 +59,7 @@ func TestUIReporterNilCallbacks(t *testing.T) {
 	reporter.SetProgress(0.5, "info")
 	reporter.SetCanCancel(true)
 	reporter.Update()
+	reporter.Warn("test")
 
 	// IsCancelled with nil CheckCancel
 	if reporter.IsCancelled() {
@@ -164,6 +167,7 @@ func TestUIReporterSetProgressValues(t *testing.T) {
 		},
 		nil, nil, nil,
 	)
+	reporter.MaxUpdatesPerSecond = 0 // Unthrottled: every value is forwarded
 
 	reporter.SetProgress(0.0, "0%")
 	if lastFraction != 0.0 || lastInfo != "0%" {
@@ -202,3 +206,138 @@ func TestUIReporterSetCanCancelValue(t *testing.T) {
 		t.Error("lastCanCancel should be false")
 	}
 }
+
+func TestUIReporterThrottle(t *testing.T) {
+	var statusCalls, progressCalls, updateCalls int
+	var lastFraction float32
+	reporter := NewUIReporter(
+		func(string) { statusCalls++ },
+		func(fraction float32, info string) {
+			progressCalls++
+			lastFraction = fraction
+		},
+		nil,
+		func() { updateCalls++ },
+		nil,
+	)
+	reporter.MaxUpdatesPerSecond = 10 // One emit per 100ms
+
+	// Fake clock advanced by 1ms per simulated MiB
+	clock := time.Unix(0, 0)
+	reporter.now = func() time.Time { return clock }
+
+	// 1000 reports over one simulated second
+	for i := 0; i < 1000; i++ {
+		reporter.SetStatus(fmt.Sprintf("Encrypting at %d.00 MiB/s (ETA: 00:00:01)", i))
+		reporter.SetProgress(float32(i)/1000, "")
+		reporter.Update()
+		clock = clock.Add(time.Millisecond)
+	}
+
+	if statusCalls != 10 {
+		t.Errorf("OnStatus called %d times; want 10 (coalesced to 10/s)", statusCalls)
+	}
+	if progressCalls != 10 {
+		t.Errorf("OnProgress called %d times; want 10 (coalesced to 10/s)", progressCalls)
+	}
+	if updateCalls != 10 {
+		t.Errorf("OnUpdate called %d times; want 10 (coalesced to 10/s)", updateCalls)
+	}
+
+	// The terminal update is delivered even inside the throttle window
+	reporter.SetProgress(1, "100%")
+	reporter.Update()
+	if lastFraction != 1 {
+		t.Errorf("lastFraction = %f; want 1 (terminal update must be delivered)", lastFraction)
+	}
+	if progressCalls != 11 || updateCalls != 11 {
+		t.Errorf("calls after terminal update = (%d, %d); want (11, 11)", progressCalls, updateCalls)
+	}
+}
+
+// TestUIReporterThrottleStatus tests that a status starting a new phase is
+// delivered inside the throttle window, while repeats and new rates for the
+// same phase are throttled.
+func TestUIReporterThrottleStatus(t *testing.T) {
+	var statuses []string
+	reporter := NewUIReporter(func(text string) { statuses = append(statuses, text) }, nil, nil, nil, nil)
+	reporter.MaxUpdatesPerSecond = 10
+
+	clock := time.Unix(0, 0)
+	reporter.now = func() time.Time { return clock }
+
+	steps := []struct {
+		status string
+		want   int
+	}{
+		{"Deriving key...", 1},
+		{"Deriving key...", 1},                           // Same phase, throttled
+		{"Encrypting at 10.00 MiB/s (ETA: 00:00:09)", 2}, // New phase
+		{"Encrypting at 12.00 MiB/s (ETA: 00:00:07)", 2}, // New rate, throttled
+		{"Generating parity...", 3},                      // New phase
+		{"Encrypting at 11.00 MiB/s (ETA: 00:00:01)", 4}, // Back to encrypting
+	}
+	for i, step := range steps {
+		reporter.SetStatus(step.status)
+		if len(statuses) != step.want {
+			t.Errorf("step %d (%q): OnStatus called %d times; want %d", i, step.status, len(statuses), step.want)
+		}
+		clock = clock.Add(time.Millisecond)
+	}
+
+	// A new rate is shown once the window has passed
+	clock = clock.Add(100 * time.Millisecond)
+	reporter.SetStatus("Encrypting at 13.00 MiB/s (ETA: 00:00:01)")
+	if got := statuses[len(statuses)-1]; got != "Encrypting at 13.00 MiB/s (ETA: 00:00:01)" {
+		t.Errorf("last status = %q; want the rate after the window", got)
+	}
+}
+
+// TestUIReporterThrottlePhaseChange tests that the refresh after a new
+// phase is delivered inside the throttle window, while a new rate for the
+// same phase is still throttled.
+func TestUIReporterThrottlePhaseChange(t *testing.T) {
+	var updateCalls int
+	reporter := NewUIReporter(nil, nil, nil, func() { updateCalls++ }, nil)
+	reporter.MaxUpdatesPerSecond = 10
+
+	clock := time.Unix(0, 0)
+	reporter.now = func() time.Time { return clock }
+
+	steps := []struct {
+		status string
+		want   int
+	}{
+		{"Deriving key...", 1},
+		{"Deriving key...", 1},                           // Same phase, throttled
+		{"Encrypting at 10.00 MiB/s (ETA: 00:00:09)", 2}, // New phase
+		{"Encrypting at 12.00 MiB/s (ETA: 00:00:07)", 2}, // New rate, throttled
+		{"Generating parity...", 3},                      // New phase
+		{"Encrypting at 11.00 MiB/s (ETA: 00:00:01)", 4}, // Back to encrypting
+	}
+	for i, step := range steps {
+		reporter.SetStatus(step.status)
+		reporter.Update()
+		if updateCalls != step.want {
+			t.Errorf("step %d (%q): OnUpdate called %d times; want %d", i, step.status, updateCalls, step.want)
+		}
+		clock = clock.Add(time.Millisecond)
+	}
+}
+
+func TestUIReporterWarn(t *testing.T) {
+	state := NewState()
+	reporter := NewUIReporter(nil, nil, nil, nil, nil)
+	reporter.OnWarn = state.AddWarning
+
+	reporter.Warn("skipping pipe (not a regular file)")
+	reporter.Warn("data.zip appears already compressed; compression disabled")
+
+	warnings := state.TakeWarnings()
+	if len(warnings) != 2 || warnings[0] != "skipping pipe (not a regular file)" {
+		t.Errorf("warnings = %q; want both advisories in order", warnings)
+	}
+	if again := state.TakeWarnings(); len(again) != 0 {
+		t.Errorf("TakeWarnings did not clear: %q", again)
+	}
+}
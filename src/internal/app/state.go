@@ -19,6 +19,7 @@ package app
 
 import (
 	"image/color"
+	"strings"
 	"sync"
 	"time"
 
@@ -67,21 +68,27 @@ type State struct {
 	OnlyFolders  []string
 	AllFiles     []string
 	InputLabel   string
+	LastOutput   string // Output of the last completed operation, for "Show in folder"
 
 	// Credentials
 	Password           string
 	CPassword          string // Confirm password
 	PasswordStrength   int
+	PasswordEntropy    float64 // Estimated bits, shown next to the strength indicator
+	PasswordCommon     bool    // Password is on the bundled common-password list
+	MinPasswordScore   int     // Lowest strength Start accepts when encrypting with a password; kept across resets
 	PasswordMode       PasswordInputMode
 	PasswordStateLabel string
 
 	// Password generator
-	PassgenLength  int32
-	PassgenUpper   bool
-	PassgenLower   bool
-	PassgenNums    bool
-	PassgenSymbols bool
-	PassgenCopy    bool
+	PassgenLength    int32
+	PassgenUpper     bool
+	PassgenLower     bool
+	PassgenNums      bool
+	PassgenSymbols   bool
+	PassgenSymbolSet string // Symbols to draw from; see util.ParseSymbolSet
+	PassgenCopy      bool
+	PassgenCount     int32 // Passwords per Generate; more than one are listed instead of filled in
 
 	// Keyfiles
 	Keyfiles       []string
@@ -99,6 +106,7 @@ type State struct {
 	ReedSolomon bool
 	Deniability bool
 	Compress    bool
+	KeepArchive bool // Zip a dropped folder holding one file instead of encrypting the file directly
 
 	// Decryption options
 	Keep        bool // Force decrypt despite errors
@@ -114,15 +122,18 @@ type State struct {
 	SplitSelected int32
 
 	// Processing options
-	Recursively bool
-	Delete      bool
-	Recombine   bool
+	Recursively    bool
+	OutputTemplate string // Per-file output path in recursive encryption, see fileops.ExpandOutputTemplate
+	Concurrency    int    // Files processed at once in recursive mode; 0 or 1 processes them in turn
+	Delete         bool
+	Recombine      bool
 
 	// Status
 	StartLabel      string
 	MainStatus      string
 	MainStatusColor color.RGBA
 	PopupStatus     string
+	Warnings        []string // Advisories from the current operation, see AddWarning
 
 	// Progress
 	Progress     float32
@@ -164,6 +175,8 @@ func NewState() *State {
 		PasswordMode:       PasswordModeHidden,
 		PasswordStateLabel: "Show",
 		PassgenLength:      32,
+		PassgenCount:       1,
+		PassgenSymbolSet:   util.DefaultSymbols,
 		SplitSelected:      1, // Default to MiB
 		SplitUnits:         []string{"KiB", "MiB", "GiB", "TiB", "Total"},
 		FastDecode:         true,
@@ -219,6 +232,7 @@ func (s *State) resetUILocked() {
 	s.InputFile = ""
 	s.InputFileOld = ""
 	s.OutputFile = ""
+	s.LastOutput = ""
 	s.OnlyFiles = nil
 	s.OnlyFolders = nil
 	s.AllFiles = nil
@@ -227,6 +241,8 @@ func (s *State) resetUILocked() {
 	s.Password = ""
 	s.CPassword = ""
 	s.PasswordStrength = 0
+	s.PasswordEntropy = 0
+	s.PasswordCommon = false
 	s.PasswordMode = PasswordModeHidden
 	s.PasswordStateLabel = "Show"
 
@@ -243,6 +259,7 @@ func (s *State) resetUILocked() {
 	s.ReedSolomon = false
 	s.Deniability = false
 	s.Compress = false
+	s.KeepArchive = false
 
 	s.Keep = false
 	s.Kept = false
@@ -261,8 +278,12 @@ func (s *State) resetUILocked() {
 	s.PassgenNums = true
 	s.PassgenSymbols = true
 	s.PassgenCopy = true
+	s.PassgenCount = 1
+	s.PassgenSymbolSet = util.DefaultSymbols
 
 	s.Recursively = false
+	s.OutputTemplate = ""
+	s.Concurrency = 0
 	s.Delete = false
 	s.Recombine = false
 
@@ -270,6 +291,7 @@ func (s *State) resetUILocked() {
 	s.MainStatus = "Ready"
 	s.MainStatusColor = util.WHITE
 	s.PopupStatus = ""
+	s.Warnings = nil
 
 	// Progress values are reset, but not the progress FLAGS
 	s.Progress = 0
@@ -329,6 +351,36 @@ func (s *State) CanStart() bool {
 	return true
 }
 
+// IsBusy reports whether an operation or file scan is running.
+func (s *State) IsBusy() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Working || s.Scanning || s.ShowProgress
+}
+
+// ClearCredentials forgets the entered passwords and selected keyfiles,
+// leaving the files and options alone. Returns false if there was nothing
+// to clear.
+func (s *State) ClearCredentials() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Password == "" && s.CPassword == "" && len(s.Keyfiles) == 0 {
+		return false
+	}
+	s.Password = ""
+	s.CPassword = ""
+	s.PasswordStrength = 0
+	s.PasswordEntropy = 0
+	s.PasswordCommon = false
+	s.Keyfiles = nil
+	if s.Keyfile {
+		s.KeyfileLabel = "Keyfiles required"
+	} else {
+		s.KeyfileLabel = "None selected"
+	}
+	return true
+}
+
 // TogglePasswordVisibility toggles password show/hide.
 func (s *State) TogglePasswordVisibility() {
 	s.mu.Lock()
@@ -376,6 +428,22 @@ func (s *State) SetStatus(text string, c color.RGBA) {
 	s.MainStatusColor = c
 }
 
+// AddWarning records a non-fatal advisory for the warnings panel.
+func (s *State) AddWarning(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Warnings = append(s.Warnings, msg)
+}
+
+// TakeWarnings returns the recorded warnings and clears them.
+func (s *State) TakeWarnings() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	warnings := s.Warnings
+	s.Warnings = nil
+	return warnings
+}
+
 // SetPopupStatus updates the popup status display.
 func (s *State) SetPopupStatus(text string) {
 	s.mu.Lock()
@@ -403,11 +471,12 @@ func (s *State) SetCanCancel(can bool) {
 func (s *State) GenPassword() string {
 	s.mu.RLock()
 	opts := util.PassgenOptions{
-		Length:  int(s.PassgenLength),
-		Upper:   s.PassgenUpper,
-		Lower:   s.PassgenLower,
-		Numbers: s.PassgenNums,
-		Symbols: s.PassgenSymbols,
+		Length:    int(s.PassgenLength),
+		Upper:     s.PassgenUpper,
+		Lower:     s.PassgenLower,
+		Numbers:   s.PassgenNums,
+		Symbols:   s.PassgenSymbols,
+		SymbolSet: s.PassgenSymbolSet,
 	}
 	copyToClipboard := s.PassgenCopy
 	clipboardFunc := s.SetClipboard
@@ -424,3 +493,31 @@ func (s *State) GenPassword() string {
 	}
 	return password
 }
+
+// GenPasswords generates PassgenCount distinct passwords using current passgen
+// settings, copying them one per line if PassgenCopy is set.
+// Returns nil if generation fails.
+func (s *State) GenPasswords() []string {
+	s.mu.RLock()
+	opts := util.PassgenOptions{
+		Length:    int(s.PassgenLength),
+		Upper:     s.PassgenUpper,
+		Lower:     s.PassgenLower,
+		Numbers:   s.PassgenNums,
+		Symbols:   s.PassgenSymbols,
+		SymbolSet: s.PassgenSymbolSet,
+	}
+	count := int(s.PassgenCount)
+	copyToClipboard := s.PassgenCopy
+	clipboardFunc := s.SetClipboard
+	s.mu.RUnlock()
+
+	passwords, err := util.GeneratePasswords(count, opts)
+	if err != nil {
+		return nil
+	}
+	if copyToClipboard && clipboardFunc != nil {
+		clipboardFunc(strings.Join(passwords, "\n"))
+	}
+	return passwords
+}
@@ -393,6 +393,41 @@ func TestGenPasswordNoOptions(t *testing.T) {
 	}
 }
 
+func TestGenPasswordSymbolSet(t *testing.T) {
+	state := NewState()
+	if state.PassgenSymbolSet != util.DefaultSymbols {
+		t.Errorf("PassgenSymbolSet = %q; want the default symbols", state.PassgenSymbolSet)
+	}
+
+	state.PassgenLength = 64
+	state.PassgenUpper = false
+	state.PassgenLower = false
+	state.PassgenNums = false
+	state.PassgenSymbols = true
+	state.PassgenCopy = false
+	state.PassgenSymbolSet = ".:.:"
+
+	password := state.GenPassword()
+	if len(password) != 64 {
+		t.Fatalf("Password length = %d; want 64", len(password))
+	}
+	for _, ch := range password {
+		if ch != '.' && ch != ':' {
+			t.Errorf("Password contains %c outside the symbol set", ch)
+		}
+	}
+
+	state.PassgenSymbolSet = "a b"
+	if password := state.GenPassword(); password != "" {
+		t.Errorf("Password = %q; want empty for an invalid symbol set", password)
+	}
+
+	state.ResetUI()
+	if state.PassgenSymbolSet != util.DefaultSymbols {
+		t.Errorf("PassgenSymbolSet after reset = %q; want the default symbols", state.PassgenSymbolSet)
+	}
+}
+
 func TestStateConcurrency(t *testing.T) {
 	state := NewState()
 
@@ -2,10 +2,24 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/volume"
 )
 
 func TestReporter(t *testing.T) {
@@ -350,7 +364,7 @@ func TestOutputAutoGeneration(t *testing.T) {
 		input := "/path/to/file.pcv"
 		expected := "/path/to/file"
 
-		output := strings.TrimSuffix(input, ".pcv")
+		output := volume.DecryptOutputName(input, nil)
 		if output != expected {
 			t.Errorf("expected %q, got %q", expected, output)
 		}
@@ -456,6 +470,177 @@ func TestReporterOutput(t *testing.T) {
 	})
 }
 
+func TestJSONReporter(t *testing.T) {
+	t.Run("short encrypt", func(t *testing.T) {
+		rsCodecs, err := encoding.NewRSCodecs()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "input.bin")
+		if err := os.WriteFile(inputPath, bytes.Repeat([]byte("json"), 1<<19), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		err = volume.Encrypt(context.Background(), &volume.EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: inputPath + ".pcv",
+			Password:   "json_password",
+			Reporter:   NewJSONReporter(&out, "encrypt"),
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) == 0 || lines[0] == "" {
+			t.Fatal("expected JSON progress lines, got none")
+		}
+
+		var last map[string]any
+		sawSpeed := false
+		for _, line := range lines {
+			var event map[string]any
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("line is not valid JSON: %q (%v)", line, err)
+			}
+			for _, key := range []string{"operation", "status", "fraction", "speedMiBps", "eta"} {
+				if _, ok := event[key]; !ok {
+					t.Errorf("line %q is missing field %q", line, key)
+				}
+			}
+			if event["operation"] != "encrypt" {
+				t.Errorf("operation = %v, want encrypt", event["operation"])
+			}
+			if strings.HasPrefix(event["status"].(string), "Encrypting at") {
+				if event["eta"] == "" {
+					t.Errorf("line %q has a rate in the status but no ETA", line)
+				}
+				sawSpeed = true
+			} else if event["speedMiBps"] != 0.0 || event["eta"] != "" {
+				t.Errorf("line %q has a speed or ETA without a rate in the status", line)
+			}
+			last = event
+		}
+
+		if !sawSpeed {
+			t.Error("expected at least one line with speed and ETA")
+		}
+		if fraction := last["fraction"].(float64); fraction < 0.99 {
+			t.Errorf("final fraction = %v, want ~1.0", fraction)
+		}
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		if _, err := newCommandReporter("xml", "", false, "encrypt"); err == nil {
+			t.Error("expected error for unknown progress format")
+		}
+	})
+}
+
+func TestSocketReporter(t *testing.T) {
+	// Keep the socket path short: Unix socket paths are limited to ~100 bytes
+	dir, err := os.MkdirTemp("", "pcv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	socketPath := filepath.Join(dir, "progress.sock")
+
+	t.Run("short encrypt", func(t *testing.T) {
+		ln, err := net.Listen("unix", socketPath)
+		if err != nil {
+			t.Skipf("Unix sockets unavailable: %v", err)
+		}
+		defer func() { _ = ln.Close() }()
+
+		received := make(chan []byte, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				received <- nil
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			data, _ := io.ReadAll(conn)
+			received <- data
+		}()
+
+		rsCodecs, err := encoding.NewRSCodecs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputPath := filepath.Join(t.TempDir(), "input.bin")
+		if err := os.WriteFile(inputPath, bytes.Repeat([]byte("sock"), 1<<19), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		reporter, err := newCommandReporter(ProgressText, socketPath, false, "encrypt")
+		if err != nil {
+			t.Fatalf("newCommandReporter failed: %v", err)
+		}
+		if reporter.showsText() {
+			t.Error("socket progress should keep informational lines off stderr")
+		}
+		err = volume.Encrypt(context.Background(), &volume.EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: inputPath + ".pcv",
+			Password:   "socket_password",
+			Reporter:   reporter,
+			RSCodecs:   rsCodecs,
+		})
+		reporter.Finish() // Closes the socket, ending the stream
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+
+		data := <-received
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) == 0 || lines[0] == "" {
+			t.Fatal("expected JSON progress frames on the socket, got none")
+		}
+		var last progressEvent
+		for _, line := range lines {
+			if err := json.Unmarshal([]byte(line), &last); err != nil {
+				t.Fatalf("frame is not valid JSON: %q (%v)", line, err)
+			}
+			if last.Operation != "encrypt" {
+				t.Errorf("operation = %q, want encrypt", last.Operation)
+			}
+		}
+		if last.Fraction < 0.99 {
+			t.Errorf("final fraction = %v, want ~1.0", last.Fraction)
+		}
+	})
+
+	t.Run("socket unavailable", func(t *testing.T) {
+		reporter, err := newCommandReporter(ProgressJSON, filepath.Join(dir, "missing.sock"), false, "encrypt")
+		if err != nil {
+			t.Fatalf("an unavailable socket should not fail the command: %v", err)
+		}
+		if reporter.jsonOut != os.Stderr {
+			t.Error("expected JSON progress on stderr as a fallback")
+		}
+	})
+
+	t.Run("frontend goes away", func(t *testing.T) {
+		client, server := net.Pipe()
+		_ = server.Close()
+		w := &socketWriter{conn: client, path: "pipe"}
+		for range 2 {
+			if n, err := w.Write([]byte("{}\n")); n != 3 || err != nil {
+				t.Errorf("Write = %d, %v; failures should be swallowed", n, err)
+			}
+		}
+		if !w.failed {
+			t.Error("expected the writer to give up after a failed write")
+		}
+	})
+}
+
 func TestVersionFlag(t *testing.T) {
 	// Test that version is set correctly
 	Version = "v1.0.0"
@@ -467,3 +652,226 @@ func TestVersionFlag(t *testing.T) {
 		t.Errorf("expected version v1.0.0, got %s", rootCmd.Version)
 	}
 }
+
+func TestReadPasswordInteractive(t *testing.T) {
+	// script replaces the terminal with canned answers; an exhausted script
+	// behaves like Ctrl-D
+	script := func(t *testing.T, terminal bool, answers ...string) *int {
+		prompts := 0
+		origTerminal, origPrompt := stdinIsTerminal, promptPassword
+		stdinIsTerminal = func() bool { return terminal }
+		promptPassword = func(string) (string, error) {
+			if prompts >= len(answers) {
+				return "", ErrPasswordAborted
+			}
+			prompts++
+			return answers[prompts-1], nil
+		}
+		t.Cleanup(func() { stdinIsTerminal, promptPassword = origTerminal, origPrompt })
+		return &prompts
+	}
+
+	t.Run("match", func(t *testing.T) {
+		prompts := script(t, true, "hunter2", "hunter2")
+		pw, err := ReadPasswordInteractive(true, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pw != "hunter2" || *prompts != 2 {
+			t.Errorf("got %q after %d prompts", pw, *prompts)
+		}
+	})
+
+	t.Run("mismatch_then_match", func(t *testing.T) {
+		prompts := script(t, true, "hunter2", "hunter3", "hunter4", "hunter4")
+		pw, err := ReadPasswordInteractive(true, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pw != "hunter4" || *prompts != 4 {
+			t.Errorf("got %q after %d prompts", pw, *prompts)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		script(t, true, "hunter2", "hunter3", "hunter2")
+		if _, err := ReadPasswordInteractive(true, false); !errors.Is(err, ErrPasswordAborted) {
+			t.Errorf("expected ErrPasswordAborted, got %v", err)
+		}
+	})
+
+	t.Run("no_confirm_for_decrypt", func(t *testing.T) {
+		prompts := script(t, true, "hunter2")
+		pw, err := ReadPasswordInteractive(false, false)
+		if err != nil || pw != "hunter2" || *prompts != 1 {
+			t.Errorf("got %q, %v after %d prompts", pw, err, *prompts)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		script(t, true, "")
+		if _, err := ReadPasswordInteractive(true, false); !errors.Is(err, ErrPasswordEmpty) {
+			t.Errorf("expected ErrPasswordEmpty, got %v", err)
+		}
+		script(t, true, "")
+		if pw, err := ReadPasswordInteractive(true, true); err != nil || pw != "" {
+			t.Errorf("expected empty password to be allowed, got %q, %v", pw, err)
+		}
+	})
+
+	t.Run("not_a_terminal", func(t *testing.T) {
+		prompts := script(t, false, "hunter2", "hunter2")
+		if _, err := ReadPasswordInteractive(true, false); !errors.Is(err, ErrNoTerminal) {
+			t.Errorf("expected ErrNoTerminal, got %v", err)
+		}
+		if *prompts != 0 {
+			t.Error("should not prompt without a terminal")
+		}
+	})
+}
+
+func TestDecryptToCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh and cat")
+	}
+
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Larger than a pipe buffer so the command has to keep reading
+	tmpDir := t.TempDir()
+	plaintext := make([]byte, 3<<20+123)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	inputPath := filepath.Join(tmpDir, "backup.tar")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatal(err)
+	}
+	volumePath := inputPath + ".pcv"
+	err = volume.Encrypt(context.Background(), &volume.EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "pipe_password",
+		Reporter:   NewReporter(true),
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	decrypt := func(input, command string) error {
+		return decryptToCommand(context.Background(), &volume.DecryptRequest{
+			InputFile: input,
+			Password:  "pipe_password",
+			Reporter:  NewReporter(true),
+			RSCodecs:  rsCodecs,
+		}, pipeCommand(command))
+	}
+
+	t.Run("receives plaintext", func(t *testing.T) {
+		received := filepath.Join(tmpDir, "received")
+		if err := decrypt(volumePath, "cat > '"+received+"'"); err != nil {
+			t.Fatalf("decryptToCommand failed: %v", err)
+		}
+		got, err := os.ReadFile(received)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("command received %d bytes, want the %d plaintext bytes", len(got), len(plaintext))
+		}
+	})
+
+	t.Run("output reaches stdout", func(t *testing.T) {
+		stdout, err := os.Create(filepath.Join(tmpDir, "stdout"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = stdout.Close() }()
+		saved := os.Stdout
+		os.Stdout = stdout
+		err = decrypt(volumePath, "wc -c")
+		os.Stdout = saved
+		if err != nil {
+			t.Fatalf("decryptToCommand failed: %v", err)
+		}
+		got, err := os.ReadFile(stdout.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(got)) != strconv.Itoa(len(plaintext)) {
+			t.Errorf("command printed %q; want the plaintext size %d", got, len(plaintext))
+		}
+	})
+
+	t.Run("tampered volume", func(t *testing.T) {
+		data, err := os.ReadFile(volumePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)-1] ^= 0x01
+		tampered := filepath.Join(tmpDir, "tampered.pcv")
+		if err := os.WriteFile(tampered, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// cat exits cleanly, but the MAC failure must still be reported
+		err = decrypt(tampered, "cat > /dev/null")
+		if !errors.Is(err, perrors.ErrCorruptData) {
+			t.Errorf("expected ErrCorruptData, got %v", err)
+		}
+	})
+
+	t.Run("exit status", func(t *testing.T) {
+		err := decrypt(volumePath, "exit 3")
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+			t.Errorf("expected exit status 3, got %v", err)
+		}
+	})
+}
+
+func TestCheckRNGMode(t *testing.T) {
+	for _, mode := range []string{"off", "warn", "fail"} {
+		if err := checkRNG(mode); err != nil {
+			t.Errorf("checkRNG(%q) = %v; want nil with a healthy RNG", mode, err)
+		}
+	}
+	if err := checkRNG("strict"); err == nil {
+		t.Error("checkRNG accepted an unknown mode")
+	}
+}
+
+func TestCheckSafetyCode(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := header.NewVolumeHeader(
+		bytes.Repeat([]byte{0x01}, header.SaltSize),
+		bytes.Repeat([]byte{0x02}, header.HKDFSaltSize),
+		bytes.Repeat([]byte{0x03}, header.SerpentIVSize),
+		bytes.Repeat([]byte{0x04}, header.NonceSize),
+	)
+	var buf bytes.Buffer
+	if _, err := header.NewWriter(&buf, rsCodecs).WriteHeader(h); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "volume.pcv")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code := h.SafetyCode()
+	for _, written := range []string{code, strings.ToLower(code), strings.ReplaceAll(code, "-", " ")} {
+		if err := checkSafetyCode(path, written); err != nil {
+			t.Errorf("checkSafetyCode(%q) = %v; want a match", written, err)
+		}
+	}
+	if err := checkSafetyCode(path, "AAAA-AAAA"); err == nil || !strings.Contains(err.Error(), code) {
+		t.Errorf("expected a mismatch naming the actual code, got %v", err)
+	}
+}
@@ -3,11 +3,15 @@ package cli
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"unicode"
 
 	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
 	"Picocrypt-NG/internal/header"
 	"Picocrypt-NG/internal/volume"
 
@@ -48,7 +52,10 @@ Examples:
   Picocrypt-NG decrypt -i damaged.pcv --force
 
   # Read password from stdin (for scripts)
-  echo "mypassword" | Picocrypt-NG decrypt -i secret.pcv -P`,
+  echo "mypassword" | Picocrypt-NG decrypt -i secret.pcv -P
+
+  # Restore a tar backup without writing the plaintext to disk
+  Picocrypt-NG decrypt -i backup.tar.pcv -p "mypassword" --pipe "tar -x -C /restore"`,
 	RunE: runDecrypt,
 }
 
@@ -58,15 +65,27 @@ var (
 	decOutput        string
 	decPassword      string
 	decPasswordStdin bool
+	decKeyring       string
 	decKeyfiles      []string
+	decMaxKeyfiles   int
+	decStrictKeyfile bool
 	decForce         bool
 	decVerifyFirst   bool
 	decAutoUnzip     bool
 	decSameLevel     bool
+	decRestoreModes  bool
 	decRecombine     bool
+	decVerifyChunks  bool
 	decDeniability   bool
 	decQuiet         bool
+	decProgress      string
+	decProgressSock  string
+	decNice          bool
+	decCipherWorkers int
+	decMmap          bool
 	decYes           bool
+	decPipe          string
+	decSafetyCode    string
 )
 
 func init() {
@@ -79,27 +98,45 @@ func init() {
 	// Credentials
 	decryptCmd.Flags().StringVarP(&decPassword, "password", "p", "", "Decryption password")
 	decryptCmd.Flags().BoolVarP(&decPasswordStdin, "password-stdin", "P", false, "Read password from stdin")
+	decryptCmd.Flags().StringVar(&decKeyring, "password-keyring", "", "Read password from the system keyring entry service/account")
+	hideKeyringFlag(decryptCmd)
 	decryptCmd.Flags().StringArrayVarP(&decKeyfiles, "keyfile", "k", nil, "Keyfile path(s) (can be specified multiple times)")
+	decryptCmd.Flags().IntVar(&decMaxKeyfiles, "max-keyfiles", 0, "Refuse more keyfiles than this (0 = no limit)")
+	decryptCmd.Flags().BoolVar(&decStrictKeyfile, "strict-keyfiles", false, "Fail if keyfiles are given for a volume that does not use them")
 
 	// Decryption options
 	decryptCmd.Flags().BoolVar(&decForce, "force", false, "Continue despite MAC verification failure")
 	decryptCmd.Flags().BoolVar(&decVerifyFirst, "verify-first", false, "Verify integrity before decryption (slower but more secure)")
 	decryptCmd.Flags().BoolVar(&decAutoUnzip, "auto-unzip", false, "Automatically extract if output is a zip file")
 	decryptCmd.Flags().BoolVar(&decSameLevel, "same-level", false, "Extract zip to same directory (not subdirectory)")
+	decryptCmd.Flags().BoolVar(&decRestoreModes, "restore-modes", false, "Restore the Unix permissions stored in the zip (less the umask)")
+	decryptCmd.Flags().IntVar(&decCipherWorkers, "cipher-workers", 0, "For paranoid volumes, split each block's cipher work over this many goroutines (0 = serial)")
+	decryptCmd.Flags().BoolVar(&decMmap, "mmap-output", false, "Write the output through a memory mapping (falls back to normal writes where unsupported)")
 
 	// Volume state
 	decryptCmd.Flags().BoolVar(&decRecombine, "recombine", false, "Recombine split chunks first")
+	decryptCmd.Flags().BoolVar(&decVerifyChunks, "verify-chunks", false, "Check split chunk sizes before recombining")
 	decryptCmd.Flags().BoolVar(&decDeniability, "deniability", false, "Remove deniability wrapper first")
+	decryptCmd.Flags().StringVar(&decSafetyCode, "safety-code", "", "Refuse the volume unless its safety code matches this one (as printed by encrypt --safety-code)")
 
 	// Other
 	decryptCmd.Flags().BoolVarP(&decQuiet, "quiet", "q", false, "Suppress progress output")
+	decryptCmd.Flags().StringVar(&decProgress, "progress", ProgressText, "Progress output format: text or json (JSON lines on stderr)")
+	decryptCmd.Flags().StringVar(&decProgressSock, "progress-socket", "", "Write JSON progress lines to this Unix socket or named pipe instead of stderr")
+	decryptCmd.Flags().BoolVar(&decNice, "nice", false, "Run at lower scheduling priority")
 	decryptCmd.Flags().BoolVarP(&decYes, "yes", "y", false, "Overwrite output file without prompting")
+	decryptCmd.Flags().StringVar(&decPipe, "pipe", "", "Feed the plaintext to the stdin of a shell command instead of writing a file")
 
 	// Mark required
 	_ = decryptCmd.MarkFlagRequired("input")
 }
 
 func runDecrypt(cmd *cobra.Command, args []string) error {
+	reporter, err := newCommandReporter(decProgress, decProgressSock, decQuiet, "decrypt")
+	if err != nil {
+		return err
+	}
+
 	// Validate input exists
 	if decInput == "" {
 		return fmt.Errorf("input file is required (-i)")
@@ -125,21 +162,35 @@ func runDecrypt(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if decPipe != "" && (decOutput != "" || decAutoUnzip) {
+		return fmt.Errorf("--pipe cannot be combined with -o or --auto-unzip")
+	}
+
+	// Check the volume is the expected one before asking for credentials
+	if decSafetyCode != "" {
+		if decDeniability {
+			return fmt.Errorf("--safety-code cannot be combined with --deniability")
+		}
+		if err := checkSafetyCode(decInput, decSafetyCode); err != nil {
+			return err
+		}
+	}
+
+	// Initialize RS codecs
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return fmt.Errorf("initializing Reed-Solomon codecs: %w", err)
+	}
+
 	// Determine output file
 	outputFile := decOutput
-	if outputFile == "" {
-		// Auto-generate from input by removing .pcv extension
-		outputFile = strings.TrimSuffix(decInput, ".pcv")
-		if decRecombine {
-			// For split files like file.pcv.0, need to strip more
-			if idx := strings.LastIndex(outputFile, ".pcv."); idx > 0 {
-				outputFile = outputFile[:idx]
-			}
-		}
-		// If we're left with the same name, add .decrypted
-		if outputFile == decInput {
-			outputFile = decInput + ".decrypted"
+	if outputFile == "" && decPipe == "" {
+		// Strip .pcv, or restore the name stored in the header
+		var hdr *header.VolumeHeader
+		if !decDeniability {
+			hdr, _ = readHeaderInfo(decInput, rsCodecs)
 		}
+		outputFile = volume.DecryptOutputName(decInput, hdr)
 	}
 
 	// Check if output exists
@@ -155,7 +206,17 @@ func runDecrypt(cmd *cobra.Command, args []string) error {
 
 	// Get password
 	password := decPassword
-	if decPasswordStdin {
+	var passwordFunc func() (string, error)
+	if decKeyring != "" {
+		if password != "" || decPasswordStdin {
+			return fmt.Errorf("--password-keyring cannot be combined with --password or --password-stdin")
+		}
+		var err error
+		passwordFunc, err = keyringPassword(systemKeyring, decKeyring)
+		if err != nil {
+			return err
+		}
+	} else if decPasswordStdin {
 		var err error
 		password, err = ReadPasswordFromStdin()
 		if err != nil {
@@ -170,27 +231,25 @@ func runDecrypt(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Initialize RS codecs
-	rsCodecs, err := encoding.NewRSCodecs()
-	if err != nil {
-		return fmt.Errorf("initializing Reed-Solomon codecs: %w", err)
-	}
-
 	// Try to read header to check if keyfiles are required
 	// Note: with deniability, we can't read the header until wrapper is removed
 	var volumeUsesKeyfiles bool
-	if password == "" && !decDeniability {
+	if password == "" && passwordFunc == nil && !decDeniability {
 		hdr, err := readHeaderInfo(decInput, rsCodecs)
 		if err == nil {
 			volumeUsesKeyfiles = hdr.Flags.UseKeyfiles
 			if !decQuiet && volumeUsesKeyfiles && len(decKeyfiles) == 0 {
-				fmt.Fprintln(os.Stderr, "Warning: This volume requires keyfiles")
+				if names := hdr.KeyfileNames(); len(names) > 0 {
+					fmt.Fprintf(os.Stderr, "Warning: This volume requires keyfiles: %s\n", strings.Join(names, ", "))
+				} else {
+					fmt.Fprintln(os.Stderr, "Warning: This volume requires keyfiles")
+				}
 			}
 		}
 	}
 
 	// Prompt for password interactively if not provided via -p/-P
-	if password == "" {
+	if password == "" && passwordFunc == nil {
 		hasKeyfiles := len(decKeyfiles) > 0
 
 		// With deniability, we can't know if volume uses keyfiles until wrapper is removed.
@@ -215,30 +274,38 @@ func runDecrypt(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Create reporter
-	reporter := NewReporter(decQuiet)
 	globalReporter = reporter
 
 	// Build request
 	var kept bool
+	var repair volume.RepairStats
 	req := &volume.DecryptRequest{
-		InputFile:    decInput,
-		OutputFile:   outputFile,
-		Password:     password,
-		Keyfiles:     decKeyfiles,
-		ForceDecrypt: decForce,
-		VerifyFirst:  decVerifyFirst,
-		AutoUnzip:    decAutoUnzip,
-		SameLevel:    decSameLevel,
-		Recombine:    decRecombine,
-		Deniability:  decDeniability,
-		Reporter:     reporter,
-		RSCodecs:     rsCodecs,
-		Kept:         &kept,
+		InputFile:      decInput,
+		OutputFile:     outputFile,
+		Password:       password,
+		PasswordFunc:   passwordFunc,
+		Keyfiles:       decKeyfiles,
+		MaxKeyfiles:    decMaxKeyfiles,
+		StrictKeyfiles: decStrictKeyfile,
+		ForceDecrypt:   decForce,
+		VerifyFirst:    decVerifyFirst,
+		AutoUnzip:      decAutoUnzip,
+		SameLevel:      decSameLevel,
+		RestoreModes:   decRestoreModes,
+		Recombine:      decRecombine,
+		VerifyChunks:   decVerifyChunks,
+		Deniability:    decDeniability,
+		LowPriority:    decNice,
+		CipherWorkers:  decCipherWorkers,
+		MmapOutput:     decMmap,
+		Reporter:       reporter,
+		RSCodecs:       rsCodecs,
+		Kept:           &kept,
+		RepairStats:    &repair,
 	}
 
 	// Print info
-	if !decQuiet {
+	if reporter.showsText() {
 		fmt.Fprintf(os.Stderr, "Decrypting %s\n", decInput)
 		if decVerifyFirst {
 			fmt.Fprintln(os.Stderr, "Mode: Verify-first (two-pass, slower but more secure)")
@@ -250,18 +317,38 @@ func runDecrypt(cmd *cobra.Command, args []string) error {
 	}
 
 	// Run decryption
-	err = volume.Decrypt(context.Background(), req)
+	if decPipe != "" {
+		err = decryptToCommand(context.Background(), req, pipeCommand(decPipe))
+		outputFile = decPipe
+	} else {
+		err = volume.Decrypt(context.Background(), req)
+	}
 	reporter.Finish()
 
+	// The volume was decrypted; only extracting the .zip failed
+	if errors.Is(err, perrors.ErrUnzipFailed) {
+		reporter.PrintSuccess("Decryption completed successfully: %s", outputFile)
+		if !decQuiet {
+			fmt.Fprintf(os.Stderr, "Warning: %v; the decrypted .zip was kept\n", err)
+		}
+		return nil
+	}
+
 	if err != nil {
 		reporter.PrintError("%v", err)
 		// Clean up partial output on error
-		_ = os.Remove(outputFile + ".incomplete")
+		if decPipe == "" {
+			_ = os.Remove(outputFile + ".incomplete")
+		}
 		return err
 	}
 
 	if kept {
 		reporter.PrintSuccess("Decryption completed with warnings (MAC verification failed): %s", outputFile)
+		if repair.Uncorrectable > 0 && !decQuiet {
+			fmt.Fprintf(os.Stderr, "Warning: %.1f%% of blocks unrecoverable (%d of %d)\n",
+				repair.Fraction()*100, repair.Uncorrectable, repair.Total)
+		}
 	} else {
 		reporter.PrintSuccess("Decryption completed successfully: %s", outputFile)
 	}
@@ -276,10 +363,40 @@ func readHeaderInfo(inputFile string, rsCodecs *encoding.RSCodecs) (*header.Volu
 	}
 	defer f.Close()
 
-	reader := header.NewReader(f, rsCodecs)
+	var r io.Reader = f
+	if encoding.IsArmored(inputFile) {
+		if r, err = encoding.NewArmorReader(f); err != nil {
+			return nil, err
+		}
+	}
+
+	reader := header.NewReader(r, rsCodecs)
 	result, err := reader.ReadHeader()
 	if err != nil {
 		return nil, err
 	}
 	return result.Header, nil
 }
+
+// normalizeSafetyCode uppercases a safety code and drops the separators a
+// user may have written it down with.
+func normalizeSafetyCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, code)
+}
+
+// checkSafetyCode fails unless the volume at path has the safety code want.
+func checkSafetyCode(path, want string) error {
+	got, err := header.SafetyCode(path)
+	if err != nil {
+		return err
+	}
+	if normalizeSafetyCode(got) != normalizeSafetyCode(want) {
+		return fmt.Errorf("%s has safety code %s, not %s: this is not the expected volume", path, got, want)
+	}
+	return nil
+}
@@ -3,13 +3,18 @@ package cli
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
 	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/keyfile"
 	"Picocrypt-NG/internal/volume"
 
 	"github.com/spf13/cobra"
@@ -59,17 +64,51 @@ var (
 	encOutput        string
 	encPassword      string
 	encPasswordStdin bool
+	encKeyring       string
+	encMinScore      int
 	encKeyfiles      []string
+	encMaxKeyfiles   int
 	encKeyfileOrder  bool
+	encKeyfileHash   string
+	encKeyfileNames  bool
+	encStoreName     bool
 	encComments      string
 	encParanoid      bool
 	encReedSolomon   bool
+	encExplicitPad   bool
 	encDeniability   bool
 	encCompress      bool
+	encSkipEntropy   bool
+	encZipWorkers    int
+	encCipherWorkers int
+	encRawSingle     bool
+	encPreserveDirs  bool
+	encNames         bool
+	encThreads       int
+	encMaxDerivation time.Duration
+	encTargetDerive  time.Duration
+	encBlockHashes   bool
+	encTrailer       bool
+	encIndex         bool
+	encCDC           bool
+	encVerify        bool
+	encDurable       bool
+	encCreateDirs    bool
+	encAbortChange   bool
+	encAtomic        bool
+	encArmor         bool
+	encArmorOnly     bool
 	encSplit         bool
 	encSplitSize     int
 	encSplitUnit     string
+	encSplitLayout   bool
+	encRecordSplit   int
+	encRecordDelim   string
+	encSafetyCode    bool
 	encQuiet         bool
+	encProgress      string
+	encProgressSock  string
+	encNice          bool
 	encYes           bool
 )
 
@@ -83,87 +122,145 @@ func init() {
 	// Credentials
 	encryptCmd.Flags().StringVarP(&encPassword, "password", "p", "", "Encryption password")
 	encryptCmd.Flags().BoolVarP(&encPasswordStdin, "password-stdin", "P", false, "Read password from stdin")
+	encryptCmd.Flags().StringVar(&encKeyring, "password-keyring", "", "Read password from the system keyring entry service/account")
+	hideKeyringFlag(encryptCmd)
+	encryptCmd.Flags().IntVar(&encMinScore, "min-password-score", 0, "Refuse passwords with a lower zxcvbn strength score (0-4; 0 accepts any)")
 	encryptCmd.Flags().StringArrayVarP(&encKeyfiles, "keyfile", "k", nil, "Keyfile path(s) (can be specified multiple times)")
+	encryptCmd.Flags().IntVar(&encMaxKeyfiles, "max-keyfiles", volume.DefaultMaxKeyfiles, "Refuse more keyfiles than this (negative = no limit)")
 	encryptCmd.Flags().BoolVar(&encKeyfileOrder, "keyfile-ordered", false, "Keyfile order matters (sequential hashing)")
+	encryptCmd.Flags().StringVar(&encKeyfileHash, "keyfile-hash", "sha3", "Hash for keyfiles: sha3 or blake2b (recorded in the header)")
+	encryptCmd.Flags().BoolVar(&encKeyfileNames, "store-keyfile-names", false, "Store keyfile names (not contents) in the header as a reminder")
+	encryptCmd.Flags().BoolVar(&encStoreName, "store-name", false, "Store the original file name in the header for decrypt to restore")
 
 	// Security options
 	encryptCmd.Flags().StringVarP(&encComments, "comments", "c", "", "Comments to store in header (NOT encrypted)")
 	encryptCmd.Flags().BoolVar(&encParanoid, "paranoid", false, "Enable paranoid mode (Serpent + XChaCha20, HMAC-SHA3)")
 	encryptCmd.Flags().BoolVar(&encReedSolomon, "reed-solomon", false, "Enable Reed-Solomon error correction (6% overhead)")
+	encryptCmd.Flags().BoolVar(&encExplicitPad, "explicit-padding", false, "With --reed-solomon, store the final pad length in the header (not readable by older versions)")
 	encryptCmd.Flags().BoolVar(&encDeniability, "deniability", false, "Add deniability wrapper")
 	encryptCmd.Flags().BoolVar(&encCompress, "compress", false, "Compress files before encryption")
+	encryptCmd.Flags().BoolVar(&encSkipEntropy, "skip-incompressible", false, "With --compress, store files that already look compressed or encrypted")
+	encryptCmd.Flags().IntVar(&encZipWorkers, "zip-workers", 0, "With --compress, compress small files on this many goroutines (0 = serial)")
+	encryptCmd.Flags().IntVar(&encCipherWorkers, "cipher-workers", 0, "With --paranoid, split each block's cipher work over this many goroutines (0 = serial)")
+	encryptCmd.Flags().BoolVar(&encRawSingle, "raw-single-file", false, "Encrypt a folder holding one file directly instead of zipping it")
+	encryptCmd.Flags().BoolVar(&encPreserveDirs, "preserve-dirs", false, "Store directory entries and their permissions in the archive")
+	encryptCmd.Flags().BoolVar(&encNames, "encrypt-names", false, "Store archive entries under opaque names, sealing the real names under the volume key")
+	encryptCmd.Flags().IntVar(&encThreads, "argon2-threads", 0, "Argon2 threads (0 = mode default, -1 = mode default limited to available CPUs; non-default counts need Picocrypt NG to decrypt)")
+	encryptCmd.Flags().DurationVar(&encMaxDerivation, "max-derivation-time", 0, "With --paranoid, refuse to start if key derivation is estimated to take longer (e.g. 30s)")
+	encryptCmd.Flags().DurationVar(&encTargetDerive, "target-derivation-time", 0, "Raise the Argon2 passes until key derivation takes about this long (e.g. 10s); decryption pays the same")
+	encryptCmd.Flags().BoolVar(&encBlockHashes, "block-hashes", false, "Store per-MiB block hashes so partial copies can be verified")
+	encryptCmd.Flags().BoolVar(&encTrailer, "trailer", false, "End the volume with an authenticated descriptor of its layout and parameters")
+	encryptCmd.Flags().BoolVar(&encIndex, "index", false, "Write an index of the encrypted file names and sizes, sealed with the same credentials, beside the volume")
+	encryptCmd.Flags().BoolVar(&encCDC, "cdc-dedup", false, "Encrypt content-defined chunks deterministically so unchanged regions deduplicate (reveals shared chunks)")
+	encryptCmd.Flags().BoolVar(&encVerify, "verify", false, "Re-read and verify the volume after writing it")
+	encryptCmd.Flags().BoolVar(&encDurable, "require-durable", false, "Fail unless the output and its directory are fsynced to stable storage")
+	encryptCmd.Flags().BoolVar(&encCreateDirs, "create-dirs", false, "Create the output's parent directories if they are missing")
+	encryptCmd.Flags().BoolVar(&encAbortChange, "abort-on-change", false, "Fail if the input grows or shrinks while it is being encrypted")
+	encryptCmd.Flags().BoolVar(&encAtomic, "atomic-output", false, "Write under a hidden name and only rename finished, synced files into place")
+	encryptCmd.Flags().BoolVar(&encArmor, "armor", false, "Also write a base64 armored copy (.asc) for pasting as text")
+	encryptCmd.Flags().BoolVar(&encArmorOnly, "armor-only", false, "Write the volume as base64 armored text instead of binary")
 
 	// Split options
 	encryptCmd.Flags().BoolVar(&encSplit, "split", false, "Split output into chunks")
 	encryptCmd.Flags().IntVar(&encSplitSize, "split-size", 0, "Size of each chunk (requires --split)")
 	encryptCmd.Flags().StringVar(&encSplitUnit, "split-unit", "MiB", "Unit for split size: KiB, MiB, GiB, TiB, or Total")
+	encryptCmd.Flags().BoolVar(&encSplitLayout, "split-layout", false, "Record the chunk size and count in the volume so decrypt rejects stray or missing chunks")
+	encryptCmd.Flags().IntVar(&encRecordSplit, "record-split", 0, "Write one volume per N records (.0.pcv, .1.pcv, ...) instead of one volume")
+	encryptCmd.Flags().StringVar(&encRecordDelim, "record-delimiter", "\\n", "Single character ending each record for --record-split")
+
+	// Identity
+	encryptCmd.Flags().BoolVar(&encSafetyCode, "safety-code", false, "Print the volume's safety code on stdout to note down and check before decrypting")
 
 	// Other
 	encryptCmd.Flags().BoolVarP(&encQuiet, "quiet", "q", false, "Suppress progress output")
+	encryptCmd.Flags().StringVar(&encProgress, "progress", ProgressText, "Progress output format: text or json (JSON lines on stderr)")
+	encryptCmd.Flags().StringVar(&encProgressSock, "progress-socket", "", "Write JSON progress lines to this Unix socket or named pipe instead of stderr")
+	encryptCmd.Flags().BoolVar(&encNice, "nice", false, "Run at lower scheduling priority")
 	encryptCmd.Flags().BoolVarP(&encYes, "yes", "y", false, "Overwrite output file without prompting")
 
 	// Mark required
 	_ = encryptCmd.MarkFlagRequired("input")
 }
 
-func runEncrypt(cmd *cobra.Command, args []string) error {
-	// Validate inputs
-	if len(encInput) == 0 {
-		return fmt.Errorf("at least one input file is required (-i)")
-	}
-
-	// Check input files exist
-	var allFiles []string
-	var onlyFiles []string
-	var onlyFolders []string
-
-	for _, input := range encInput {
+// collectInputs expands the --input globs into the files to encrypt, along
+// with the files and folders named directly. Folders are walked for regular
+// files; anything else found inside one is skipped with a warning through
+// reporter, while a special file named directly is an error.
+func collectInputs(patterns []string, reporter volume.ProgressReporter) (allFiles, onlyFiles, onlyFolders []string, err error) {
+	for _, input := range patterns {
 		// Expand glob patterns
 		matches, err := filepath.Glob(input)
 		if err != nil {
-			return fmt.Errorf("invalid glob pattern %q: %w", input, err)
+			return nil, nil, nil, fmt.Errorf("invalid glob pattern %q: %w", input, err)
 		}
 		if len(matches) == 0 {
-			return fmt.Errorf("input file not found: %s", input)
+			return nil, nil, nil, fmt.Errorf("input file not found: %s", input)
 		}
 
 		for _, match := range matches {
 			info, err := os.Stat(match)
 			if err != nil {
-				return fmt.Errorf("cannot access %s: %w", match, err)
+				return nil, nil, nil, fmt.Errorf("cannot access %s: %w", match, err)
 			}
 
 			if info.IsDir() {
 				onlyFolders = append(onlyFolders, match)
-				// Walk directory to get all files
-				err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
-					if !info.IsDir() {
-						allFiles = append(allFiles, path)
-					}
-					return nil
-				})
+				files, err := fileops.ListFiles(match, reporter.Warn)
 				if err != nil {
-					return fmt.Errorf("walking directory %s: %w", match, err)
+					return nil, nil, nil, fmt.Errorf("walking directory %s: %w", match, err)
 				}
+				allFiles = append(allFiles, files...)
+			} else if !info.Mode().IsRegular() {
+				return nil, nil, nil, fmt.Errorf("%s is not a regular file", match)
 			} else {
 				onlyFiles = append(onlyFiles, match)
 				allFiles = append(allFiles, match)
 			}
 		}
 	}
+	return allFiles, onlyFiles, onlyFolders, nil
+}
+
+func runEncrypt(cmd *cobra.Command, args []string) error {
+	reporter, err := newCommandReporter(encProgress, encProgressSock, encQuiet, "encrypt")
+	if err != nil {
+		return err
+	}
+
+	// Validate inputs
+	if len(encInput) == 0 {
+		return fmt.Errorf("at least one input file is required (-i)")
+	}
+
+	// Check input files exist
+	allFiles, onlyFiles, onlyFolders, err := collectInputs(encInput, reporter)
+	if err != nil {
+		return err
+	}
 
 	if len(allFiles) == 0 {
 		return fmt.Errorf("no files found to encrypt")
 	}
 
+	if encSafetyCode && (encDeniability || encArmorOnly || encRecordSplit != 0) {
+		return fmt.Errorf("--safety-code cannot be combined with --deniability, --armor-only or --record-split")
+	}
+
+	rawReq := volume.EncryptRequest{
+		InputFiles:    allFiles,
+		OnlyFolders:   onlyFolders,
+		OnlyFiles:     onlyFiles,
+		Compress:      encCompress,
+		RawSingleFile: encRawSingle,
+	}
+
 	// Determine output file
 	outputFile := encOutput
 	if outputFile == "" {
 		// Auto-generate output name
-		if len(encInput) == 1 {
+		if rawReq.IsRawSingleFile() {
+			outputFile = rawReq.RawSingleFileOutput()
+		} else if len(encInput) == 1 {
 			outputFile = encInput[0] + ".pcv"
 		} else {
 			outputFile = "encrypted.pcv"
@@ -188,7 +285,17 @@ func runEncrypt(cmd *cobra.Command, args []string) error {
 
 	// Get password
 	password := encPassword
-	if encPasswordStdin {
+	var passwordFunc func() (string, error)
+	if encKeyring != "" {
+		if password != "" || encPasswordStdin {
+			return fmt.Errorf("--password-keyring cannot be combined with --password or --password-stdin")
+		}
+		var err error
+		passwordFunc, err = keyringPassword(systemKeyring, encKeyring)
+		if err != nil {
+			return err
+		}
+	} else if encPasswordStdin {
 		var err error
 		password, err = ReadPasswordFromStdin()
 		if err != nil {
@@ -214,6 +321,10 @@ func runEncrypt(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("keyfile not found: %s", kf)
 		}
 	}
+	keyfileHash, err := keyfile.ParseHashAlgorithm(encKeyfileHash)
+	if err != nil {
+		return err
+	}
 
 	// Validate split options
 	var chunkSize int
@@ -240,39 +351,83 @@ func runEncrypt(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Validate record split options
+	var recordDelim byte
+	if encRecordSplit != 0 {
+		switch {
+		case encRecordDelim == "\\n":
+			recordDelim = '\n'
+		case encRecordDelim == "\\t":
+			recordDelim = '\t'
+		case len(encRecordDelim) == 1:
+			recordDelim = encRecordDelim[0]
+		default:
+			return fmt.Errorf("invalid record delimiter: %q (must be a single character, \\n or \\t)", encRecordDelim)
+		}
+	}
+
 	// Initialize RS codecs
 	rsCodecs, err := encoding.NewRSCodecs()
 	if err != nil {
 		return fmt.Errorf("initializing Reed-Solomon codecs: %w", err)
 	}
 
-	// Create reporter
-	reporter := NewReporter(encQuiet)
 	globalReporter = reporter
 
 	// Build request
 	req := &volume.EncryptRequest{
-		InputFiles:     allFiles,
-		OnlyFiles:      onlyFiles,
-		OnlyFolders:    onlyFolders,
-		OutputFile:     outputFile,
-		Password:       password,
-		Keyfiles:       encKeyfiles,
-		KeyfileOrdered: encKeyfileOrder,
-		Comments:       encComments,
-		Paranoid:       encParanoid,
-		ReedSolomon:    encReedSolomon,
-		Deniability:    encDeniability,
-		Compress:       encCompress,
-		Split:          encSplit,
-		ChunkSize:      chunkSize,
-		ChunkUnit:      chunkUnit,
-		Reporter:       reporter,
-		RSCodecs:       rsCodecs,
+		InputFiles:           allFiles,
+		OnlyFiles:            onlyFiles,
+		OnlyFolders:          onlyFolders,
+		OutputFile:           outputFile,
+		Password:             password,
+		PasswordFunc:         passwordFunc,
+		MinPasswordScore:     encMinScore,
+		Keyfiles:             encKeyfiles,
+		MaxKeyfiles:          encMaxKeyfiles,
+		KeyfileOrdered:       encKeyfileOrder,
+		KeyfileHash:          keyfileHash,
+		StoreKeyfileNames:    encKeyfileNames,
+		StoreOriginalName:    encStoreName,
+		Comments:             encComments,
+		Paranoid:             encParanoid,
+		ReedSolomon:          encReedSolomon,
+		ExplicitPadding:      encExplicitPad,
+		Deniability:          encDeniability,
+		Compress:             encCompress,
+		SkipIncompressible:   encSkipEntropy,
+		ZipWorkers:           encZipWorkers,
+		CipherWorkers:        encCipherWorkers,
+		RawSingleFile:        encRawSingle,
+		PreserveDirs:         encPreserveDirs,
+		EncryptNames:         encNames,
+		Argon2Threads:        encThreads,
+		MaxDerivationTime:    encMaxDerivation,
+		TargetDerivationTime: encTargetDerive,
+		BlockHashes:          encBlockHashes,
+		Trailer:              encTrailer,
+		Index:                encIndex,
+		CDCDedup:             encCDC,
+		LowPriority:          encNice,
+		VerifyAfterEncrypt:   encVerify,
+		RequireDurable:       encDurable,
+		CreateOutputDirs:     encCreateDirs,
+		AbortOnInputChange:   encAbortChange,
+		AtomicOutput:         encAtomic,
+		Armor:                encArmor,
+		ArmorOnly:            encArmorOnly,
+		Split:                encSplit,
+		ChunkSize:            chunkSize,
+		ChunkUnit:            chunkUnit,
+		StoreSplitLayout:     encSplitLayout,
+		RecordSplit:          encRecordSplit,
+		RecordDelimiter:      recordDelim,
+		Reporter:             reporter,
+		RSCodecs:             rsCodecs,
 	}
 
 	// Print info
-	if !encQuiet {
+	if reporter.showsText() {
 		fmt.Fprintf(os.Stderr, "Encrypting %d file(s) to %s\n", len(allFiles), outputFile)
 		if encParanoid {
 			fmt.Fprintln(os.Stderr, "Mode: Paranoid (Serpent-CTR + XChaCha20, HMAC-SHA3)")
@@ -292,12 +447,31 @@ func runEncrypt(cmd *cobra.Command, args []string) error {
 
 	if err != nil {
 		reporter.PrintError("%v", err)
+		// A volume that failed post-write verification is kept for inspection
+		if errors.Is(err, perrors.ErrPostWriteVerifyFailed) {
+			return err
+		}
 		// Clean up partial output on error
 		_ = os.Remove(outputFile)
 		_ = os.Remove(outputFile + ".incomplete")
 		return err
 	}
 
+	if encRecordSplit > 0 {
+		reporter.PrintSuccess("Encryption completed successfully: %s.N.pcv", strings.TrimSuffix(outputFile, ".pcv"))
+		return nil
+	}
 	reporter.PrintSuccess("Encryption completed successfully: %s", outputFile)
+	if encSafetyCode {
+		codePath := outputFile
+		if encSplit {
+			codePath += ".0"
+		}
+		code, err := header.SafetyCode(codePath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Safety code: %s\n", code)
+	}
 	return nil
 }
@@ -0,0 +1,64 @@
+//go:build unix
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// warnRecorder is a volume.ProgressReporter that only records warnings.
+type warnRecorder struct {
+	warnings []string
+}
+
+func (r *warnRecorder) SetStatus(text string)                     {}
+func (r *warnRecorder) SetProgress(fraction float32, info string) {}
+func (r *warnRecorder) SetCanCancel(can bool)                     {}
+func (r *warnRecorder) Update()                                   {}
+func (r *warnRecorder) IsCancelled() bool                         { return false }
+func (r *warnRecorder) Warn(msg string)                           { r.warnings = append(r.warnings, msg) }
+
+// TestCollectInputsWarnsOnFIFO tests that a FIFO inside a folder is skipped
+// with a warning through the reporter, while one named directly is an error.
+func TestCollectInputsWarnsOnFIFO(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "folder")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	regular := filepath.Join(dir, "regular.txt")
+	if err := os.WriteFile(regular, []byte("regular file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fifo := filepath.Join(dir, "pipe")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo not supported: %v", err)
+	}
+
+	reporter := &warnRecorder{}
+	allFiles, onlyFiles, onlyFolders, err := collectInputs([]string{dir}, reporter)
+	if err != nil {
+		t.Fatalf("collectInputs failed: %v", err)
+	}
+	if !reflect.DeepEqual(allFiles, []string{regular}) {
+		t.Errorf("allFiles = %q; want only %s", allFiles, regular)
+	}
+	if len(onlyFiles) != 0 || !reflect.DeepEqual(onlyFolders, []string{dir}) {
+		t.Errorf("onlyFiles = %q, onlyFolders = %q; want none and %s", onlyFiles, onlyFolders, dir)
+	}
+	want := []string{"skipping " + fifo + " (not a regular file)"}
+	if !reflect.DeepEqual(reporter.warnings, want) {
+		t.Errorf("warnings = %q; want %q", reporter.warnings, want)
+	}
+
+	reporter = &warnRecorder{}
+	if _, _, _, err := collectInputs([]string{fifo}, reporter); err == nil {
+		t.Error("collectInputs accepted a FIFO named directly")
+	}
+	if len(reporter.warnings) != 0 {
+		t.Errorf("unexpected warnings for a direct FIFO: %q", reporter.warnings)
+	}
+}
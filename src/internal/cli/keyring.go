@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ErrKeyringUnavailable means the platform secret store could not be
+	// reached, or there is no backend for this platform.
+	ErrKeyringUnavailable = errors.New("no system keyring is available")
+
+	// ErrKeyringNotFound means the secret store holds no entry for the
+	// requested service and account.
+	ErrKeyringNotFound = errors.New("no password stored in the keyring")
+)
+
+// Keyring reads secrets from a platform secret store: the macOS Keychain or
+// the Secret Service on Linux and BSD. Windows has no backend, so
+// --password-keyring is hidden there.
+type Keyring interface {
+	// Get returns the secret stored for service and account. A store that
+	// cannot be reached fails with ErrKeyringUnavailable and a missing
+	// entry with ErrKeyringNotFound.
+	Get(service, account string) (string, error)
+}
+
+// systemKeyring is the platform backend, replaced in tests.
+var systemKeyring Keyring = platformKeyring{}
+
+// hideKeyringFlag hides --password-keyring from cmd's help on platforms
+// without a keyring backend. Given anyway, it fails with
+// ErrKeyringUnavailable.
+func hideKeyringFlag(cmd *cobra.Command) {
+	if !keyringSupported {
+		_ = cmd.Flags().MarkHidden("password-keyring")
+	}
+}
+
+// parseKeyringRef splits a --password-keyring value of the form
+// service/account. The account may itself contain slashes.
+func parseKeyringRef(ref string) (service, account string, err error) {
+	service, account, ok := strings.Cut(ref, "/")
+	if !ok || service == "" || account == "" {
+		return "", "", fmt.Errorf("invalid --password-keyring %q (expected service/account)", ref)
+	}
+	return service, account, nil
+}
+
+// keyringPassword returns a volume request PasswordFunc that reads the
+// password for ref from kr when the operation starts.
+func keyringPassword(kr Keyring, ref string) (func() (string, error), error) {
+	service, account, err := parseKeyringRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	return func() (string, error) {
+		pw, err := kr.Get(service, account)
+		if err != nil {
+			return "", fmt.Errorf("keyring %s/%s: %w", service, account, err)
+		}
+		if pw == "" {
+			return "", fmt.Errorf("keyring %s/%s: %w", service, account, ErrPasswordEmpty)
+		}
+		return pw, nil
+	}, nil
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keyringSupported reports whether platformKeyring has a backend.
+const keyringSupported = true
+
+// platformKeyring reads generic passwords from the login Keychain with the
+// security tool that ships with macOS.
+type platformKeyring struct{}
+
+func (platformKeyring) Get(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "", fmt.Errorf("%w: security tool not found", ErrKeyringUnavailable)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 44: // errSecItemNotFound
+		return "", ErrKeyringNotFound
+	case err != nil:
+		return "", fmt.Errorf("%w: %w", ErrKeyringUnavailable, err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
@@ -0,0 +1,30 @@
+//go:build !darwin && !windows
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// keyringSupported reports whether platformKeyring has a backend.
+const keyringSupported = true
+
+// platformKeyring looks passwords up in the Secret Service (GNOME Keyring,
+// KWallet) with libsecret's secret-tool, by service and account attributes.
+type platformKeyring struct{}
+
+func (platformKeyring) Get(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "", fmt.Errorf("%w: secret-tool not found (install libsecret-tools)", ErrKeyringUnavailable)
+	case errors.As(err, &exitErr) && len(exitErr.Stderr) == 0:
+		return "", ErrKeyringNotFound // secret-tool exits 1 silently on no match
+	case err != nil:
+		return "", fmt.Errorf("%w: %w", ErrKeyringUnavailable, err)
+	}
+	return string(out), nil
+}
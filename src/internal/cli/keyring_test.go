@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/volume"
+)
+
+// fakeKeyring serves secrets from a map keyed by "service/account".
+type fakeKeyring map[string]string
+
+func (f fakeKeyring) Get(service, account string) (string, error) {
+	pw, ok := f[service+"/"+account]
+	if !ok {
+		return "", ErrKeyringNotFound
+	}
+	return pw, nil
+}
+
+func TestParseKeyringRef(t *testing.T) {
+	service, account, err := parseKeyringRef("backups/nas/nightly")
+	if err != nil || service != "backups" || account != "nas/nightly" {
+		t.Errorf("parseKeyringRef = %q, %q, %v; want backups, nas/nightly", service, account, err)
+	}
+	for _, ref := range []string{"", "backups", "backups/", "/nightly"} {
+		if _, _, err := parseKeyringRef(ref); err == nil {
+			t.Errorf("parseKeyringRef(%q) accepted an invalid reference", ref)
+		}
+	}
+}
+
+func TestKeyringPassword(t *testing.T) {
+	kr := fakeKeyring{"picocrypt/backup": "keyring_password", "picocrypt/empty": ""}
+
+	t.Run("missing entry", func(t *testing.T) {
+		fn, err := keyringPassword(kr, "picocrypt/other")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fn(); !errors.Is(err, ErrKeyringNotFound) {
+			t.Errorf("expected ErrKeyringNotFound, got %v", err)
+		}
+	})
+
+	t.Run("empty entry", func(t *testing.T) {
+		fn, err := keyringPassword(kr, "picocrypt/empty")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fn(); !errors.Is(err, ErrPasswordEmpty) {
+			t.Errorf("expected ErrPasswordEmpty, got %v", err)
+		}
+	})
+
+	t.Run("combined with --password", func(t *testing.T) {
+		defer func() { encKeyring, encPassword, encInput = "", "", nil }()
+		tmpFile := filepath.Join(t.TempDir(), "test.txt")
+		if err := os.WriteFile(tmpFile, []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		encInput = []string{tmpFile}
+		encKeyring = "picocrypt/backup"
+		encPassword = "other"
+		if err := encryptCmd.RunE(encryptCmd, nil); err == nil {
+			t.Error("expected an error for --password-keyring with --password")
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		rsCodecs, err := encoding.NewRSCodecs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn, err := keyringPassword(kr, "picocrypt/backup")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmpDir := t.TempDir()
+		plaintext := []byte("secret fetched from the keyring")
+		inputPath := filepath.Join(tmpDir, "notes.txt")
+		if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+			t.Fatal(err)
+		}
+		volumePath := inputPath + ".pcv"
+		req := &volume.EncryptRequest{
+			InputFile:    inputPath,
+			OutputFile:   volumePath,
+			PasswordFunc: fn,
+			Reporter:     NewReporter(true),
+			RSCodecs:     rsCodecs,
+		}
+		if err := volume.Encrypt(context.Background(), req); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if req.Password != "" {
+			t.Error("the fetched password was left in the caller's request")
+		}
+
+		// The stored password, typed directly, must open the volume
+		outPath := filepath.Join(tmpDir, "out.txt")
+		err = volume.Decrypt(context.Background(), &volume.DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: outPath,
+			Password:   "keyring_password",
+			Reporter:   NewReporter(true),
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt with the stored password failed: %v", err)
+		}
+		if got, _ := os.ReadFile(outPath); !bytes.Equal(got, plaintext) {
+			t.Error("decrypted content differs from the original")
+		}
+
+		// And so must the keyring, while a wrong entry fails authentication
+		wrong, _ := keyringPassword(fakeKeyring{"picocrypt/backup": "wrong"}, "picocrypt/backup")
+		err = volume.Decrypt(context.Background(), &volume.DecryptRequest{
+			InputFile:    volumePath,
+			OutputFile:   outPath,
+			PasswordFunc: fn,
+			Reporter:     NewReporter(true),
+			RSCodecs:     rsCodecs,
+		})
+		if err != nil {
+			t.Errorf("Decrypt with the keyring password failed: %v", err)
+		}
+		err = volume.Decrypt(context.Background(), &volume.DecryptRequest{
+			InputFile:    volumePath,
+			OutputFile:   outPath,
+			PasswordFunc: wrong,
+			Reporter:     NewReporter(true),
+			RSCodecs:     rsCodecs,
+		})
+		var authErr *header.AuthError
+		if !errors.As(err, &authErr) {
+			t.Errorf("expected an AuthError for a wrong keyring entry, got %v", err)
+		}
+	})
+}
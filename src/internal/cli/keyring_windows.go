@@ -0,0 +1,14 @@
+package cli
+
+import "fmt"
+
+// keyringSupported reports whether platformKeyring has a backend.
+const keyringSupported = false
+
+// platformKeyring is not implemented on Windows: Credential Manager has no
+// stock command that reveals a stored password.
+type platformKeyring struct{}
+
+func (platformKeyring) Get(service, account string) (string, error) {
+	return "", fmt.Errorf("%w: Credential Manager is not supported yet; use --password-stdin", ErrKeyringUnavailable)
+}
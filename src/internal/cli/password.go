@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
 	"syscall"
 
@@ -12,8 +14,15 @@ import (
 )
 
 var (
-	ErrPasswordMismatch = errors.New("passwords do not match")
-	ErrPasswordEmpty    = errors.New("password cannot be empty")
+	ErrPasswordEmpty   = errors.New("password cannot be empty")
+	ErrPasswordAborted = errors.New("password entry aborted")
+	ErrNoTerminal      = errors.New("stdin is not a terminal; use --password or --password-stdin")
+)
+
+// Prompt hooks, replaced in tests to drive the interactive flow without a TTY.
+var (
+	stdinIsTerminal = isTerminal
+	promptPassword  = readPasswordSecure
 )
 
 // isTerminal returns true if stdin is a terminal (not piped/redirected).
@@ -21,26 +30,39 @@ func isTerminal() bool {
 	return term.IsTerminal(int(syscall.Stdin))
 }
 
-// readPasswordSecure reads a password from stdin without echo.
-// Falls back to buffered read if stdin is not a terminal.
+// readPasswordSecure prompts on stderr and reads a password from the
+// terminal without echo. Ctrl-D aborts with ErrPasswordAborted; Ctrl-C
+// restores the terminal state before exiting so echo is not left off.
 func readPasswordSecure(prompt string) (string, error) {
-	fmt.Fprint(os.Stderr, prompt)
+	fd := int(syscall.Stdin)
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
 
-	if !isTerminal() {
-		// stdin is piped; read normally
-		reader := bufio.NewReader(os.Stdin)
-		pw, err := reader.ReadString('\n')
-		if err != nil {
-			return "", fmt.Errorf("reading password: %w", err)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(sigCh)
+		close(done)
+	}()
+	go func() {
+		select {
+		case <-sigCh:
+			_ = term.Restore(fd, state)
+			fmt.Fprintln(os.Stderr)
+			os.Exit(130)
+		case <-done:
 		}
-		pw = strings.TrimSuffix(pw, "\n")
-		pw = strings.TrimSuffix(pw, "\r")
-		return pw, nil
-	}
+	}()
 
-	// Terminal mode: disable echo
-	pw, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprint(os.Stderr, prompt)
+	pw, err := term.ReadPassword(fd)
 	fmt.Fprintln(os.Stderr) // newline after hidden input
+	if errors.Is(err, io.EOF) {
+		return "", ErrPasswordAborted
+	}
 	if err != nil {
 		return "", fmt.Errorf("reading password: %w", err)
 	}
@@ -48,29 +70,38 @@ func readPasswordSecure(prompt string) (string, error) {
 }
 
 // ReadPasswordInteractive prompts for password interactively.
-// If confirm is true, asks for confirmation (for encryption).
+// If confirm is true, asks for confirmation (for encryption) and prompts
+// again until both entries match or the user aborts.
 // If allowEmpty is true, empty password is allowed (useful when keyfiles provide credentials).
+// Returns ErrNoTerminal if stdin is not a terminal.
 func ReadPasswordInteractive(confirm, allowEmpty bool) (string, error) {
-	password, err := readPasswordSecure("Password: ")
-	if err != nil {
-		return "", err
+	if !stdinIsTerminal() {
+		return "", ErrNoTerminal
 	}
 
-	if password == "" && !allowEmpty {
-		return "", ErrPasswordEmpty
-	}
+	for {
+		password, err := promptPassword("Password: ")
+		if err != nil {
+			return "", err
+		}
 
-	if confirm && password != "" {
-		confirmPw, err := readPasswordSecure("Confirm password: ")
+		if password == "" && !allowEmpty {
+			return "", ErrPasswordEmpty
+		}
+
+		if !confirm || password == "" {
+			return password, nil
+		}
+
+		confirmPw, err := promptPassword("Confirm password: ")
 		if err != nil {
 			return "", err
 		}
-		if password != confirmPw {
-			return "", ErrPasswordMismatch
+		if password == confirmPw {
+			return password, nil
 		}
+		fmt.Fprintln(os.Stderr, "Passwords do not match, try again.")
 	}
-
-	return password, nil
 }
 
 // ReadPasswordFromStdin reads password from stdin (for piped input with -P flag).
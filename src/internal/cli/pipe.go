@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"Picocrypt-NG/internal/volume"
+)
+
+// pipeCommand returns a command that runs line through the system shell.
+func pipeCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+// decryptToCommand decrypts req into the stdin of cmd, which must not have
+// been started. Unless already redirected, the command's output goes to our
+// own stdout and stderr. The volume error wins over the command's: a MAC failure is
+// reported even if cmd already consumed the plaintext and exited cleanly.
+// If cmd exits before reading everything, its *exec.ExitError is returned
+// so the caller can pass the exit status on.
+func decryptToCommand(ctx context.Context, req *volume.DecryptRequest, cmd *exec.Cmd) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("create pipe: %w", err)
+	}
+	cmd.Stdin = r
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Start(); err != nil {
+		_ = r.Close()
+		_ = w.Close()
+		return fmt.Errorf("start %s: %w", cmd.Path, err)
+	}
+	// The child holds its own copy of the read end
+	_ = r.Close()
+
+	req.Output = w
+	decErr := volume.Decrypt(ctx, req)
+	_ = w.Close()
+	waitErr := cmd.Wait()
+
+	if decErr != nil {
+		// A command that quits early breaks the pipe; its status says why
+		var exitErr *exec.ExitError
+		if errors.Is(decErr, syscall.EPIPE) && errors.As(waitErr, &exitErr) {
+			return fmt.Errorf("pipe command: %w", waitErr)
+		}
+		return decErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("pipe command: %w", waitErr)
+	}
+	return nil
+}
@@ -2,13 +2,33 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+// Progress output formats accepted by --progress.
+const (
+	ProgressText = "text"
+	ProgressJSON = "json"
+)
+
+// progressEvent is one line of --progress json output.
+type progressEvent struct {
+	Operation  string  `json:"operation"`
+	Status     string  `json:"status"`
+	Fraction   float32 `json:"fraction"`
+	SpeedMiBps float64 `json:"speedMiBps"`
+	ETA        string  `json:"eta"`
+}
+
 // Reporter implements volume.ProgressReporter for terminal output.
 // It displays progress updates on a single line that gets overwritten.
 type Reporter struct {
@@ -19,6 +39,13 @@ type Reporter struct {
 	quiet     bool
 	cancelled atomic.Bool
 	lastLine  int // Length of last printed line (for clearing)
+
+	speed float64 // Payload rate in MiB/s, 0 when the status carries none
+	eta   string  // Time remaining to go with speed
+
+	jsonOut   io.Writer // If set, Update writes JSON lines here instead of a progress bar
+	operation string    // Operation name reported in JSON lines ("encrypt" or "decrypt")
+	closer    io.Closer // Progress socket, closed by Finish
 }
 
 // NewReporter creates a new CLI progress reporter.
@@ -29,11 +56,104 @@ func NewReporter(quiet bool) *Reporter {
 	}
 }
 
-// SetStatus updates the status message.
+// NewJSONReporter creates a reporter that writes each update to w as a
+// newline-delimited JSON object for consumption by other programs.
+func NewJSONReporter(w io.Writer, operation string) *Reporter {
+	return &Reporter{
+		jsonOut:   w,
+		operation: operation,
+	}
+}
+
+// socketWriteTimeout bounds each write to a progress socket, so a frontend
+// that stops reading cannot stall the operation.
+const socketWriteTimeout = 5 * time.Second
+
+// NewSocketReporter creates a reporter that writes JSON lines to the Unix
+// domain socket or named pipe at path, leaving stderr for errors. If a write
+// fails, for example because the frontend went away, a warning is printed
+// once and progress is no longer reported; the operation carries on.
+func NewSocketReporter(path, operation string) (*Reporter, error) {
+	conn, err := openProgressSocket(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewJSONReporter(&socketWriter{conn: conn, path: path}, operation)
+	r.closer = conn
+	return r, nil
+}
+
+// openProgressSocket opens a named pipe (a FIFO, or \\.\pipe\ on Windows)
+// for writing, and connects to anything else as a Unix domain socket. A FIFO
+// without a reader fails instead of blocking.
+func openProgressSocket(path string) (io.WriteCloser, error) {
+	info, err := os.Stat(path)
+	if strings.HasPrefix(path, `\\.\pipe\`) || (err == nil && info.Mode()&os.ModeNamedPipe != 0) {
+		return os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	}
+	return net.DialTimeout("unix", path, socketWriteTimeout)
+}
+
+// socketWriter sends progress lines to a socket and swallows the first
+// error, after a warning, along with everything written after it.
+type socketWriter struct {
+	conn   io.Writer
+	path   string
+	failed bool
+}
+
+func (s *socketWriter) Write(p []byte) (int, error) {
+	if s.failed {
+		return len(p), nil
+	}
+	if d, ok := s.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		_ = d.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	}
+	if _, err := s.conn.Write(p); err != nil {
+		s.failed = true
+		fmt.Fprintf(os.Stderr, "Warning: progress socket %s failed, no longer reporting progress: %v\n", s.path, err)
+	}
+	return len(p), nil
+}
+
+// newCommandReporter creates the reporter for a command from its --progress,
+// --progress-socket and --quiet flags. JSON lines go to the socket if one is
+// given and can be opened, otherwise to stderr.
+func newCommandReporter(format, socket string, quiet bool, operation string) (*Reporter, error) {
+	if format != ProgressText && format != ProgressJSON {
+		return nil, fmt.Errorf("invalid progress format %q: use %s or %s", format, ProgressText, ProgressJSON)
+	}
+	if socket != "" {
+		r, err := NewSocketReporter(socket, operation)
+		if err == nil {
+			return r, nil
+		}
+		fmt.Fprintf(os.Stderr, "Warning: progress socket %s unavailable, continuing without it: %v\n", socket, err)
+	}
+
+	if format == ProgressJSON && !quiet {
+		return NewJSONReporter(os.Stderr, operation), nil
+	}
+	return NewReporter(quiet), nil
+}
+
+// SetStatus updates the status message. Until the next SetRate there is no
+// speed or ETA.
 func (r *Reporter) SetStatus(text string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.status = text
+	r.speed = 0
+	r.eta = ""
+}
+
+// SetRate records the speed and ETA of the status just set, implementing
+// volume.RateReporter.
+func (r *Reporter) SetRate(speedMiBps float64, eta string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.speed = speedMiBps
+	r.eta = eta
 }
 
 // SetProgress updates the progress bar and info text.
@@ -58,6 +178,11 @@ func (r *Reporter) Update() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if r.jsonOut != nil {
+		r.writeJSON()
+		return
+	}
+
 	// Build progress bar
 	barWidth := 30
 	filled := min(int(r.progress*float32(barWidth)), barWidth)
@@ -75,6 +200,37 @@ func (r *Reporter) Update() {
 	fmt.Fprint(os.Stderr, line)
 }
 
+// Warn prints a non-fatal warning on its own line, even when quiet. Like
+// other warnings it stays plain text on stderr when progress is JSON.
+func (r *Reporter) Warn(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.showsText() && r.lastLine > 0 {
+		fmt.Fprintln(os.Stderr)
+		r.lastLine = 0
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+}
+
+// writeJSON writes the current state as one JSON line. Speed and ETA are
+// zero and empty when the status has no rate. Caller holds r.mu.
+func (r *Reporter) writeJSON() {
+	event := progressEvent{
+		Operation:  r.operation,
+		Status:     r.status,
+		Fraction:   r.progress,
+		SpeedMiBps: r.speed,
+		ETA:        r.eta,
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	_, _ = r.jsonOut.Write(append(line, '\n'))
+}
+
 // IsCancelled checks if the operation was cancelled.
 func (r *Reporter) IsCancelled() bool {
 	return r.cancelled.Load()
@@ -85,17 +241,28 @@ func (r *Reporter) Cancel() {
 	r.cancelled.Store(true)
 }
 
-// Finish prints a newline to move past the progress line.
+// Finish prints a newline to move past the progress line, and closes the
+// progress socket if there is one.
 func (r *Reporter) Finish() {
-	if !r.quiet {
+	if !r.quiet && r.jsonOut == nil {
 		fmt.Fprintln(os.Stderr)
 	}
+	if r.closer != nil {
+		_ = r.closer.Close()
+		r.closer = nil
+	}
+}
+
+// showsText reports whether informational lines belong on stderr: not when
+// quiet, and not when stderr or a socket carries JSON progress.
+func (r *Reporter) showsText() bool {
+	return !r.quiet && r.jsonOut == nil
 }
 
 // PrintError prints an error message.
 func (r *Reporter) PrintError(format string, args ...any) {
 	// Move to new line if we were showing progress
-	if !r.quiet && r.lastLine > 0 {
+	if !r.quiet && r.jsonOut == nil && r.lastLine > 0 {
 		fmt.Fprintln(os.Stderr)
 	}
 	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
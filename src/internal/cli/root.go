@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"os/signal"
 	"syscall"
 
+	"Picocrypt-NG/internal/crypto"
+
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +27,31 @@ var rootCmd = &cobra.Command{
   - Optional Serpent-CTR as second cipher layer (paranoid mode)
   - Reed-Solomon error correction for data recovery`,
 	Version: Version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return checkRNG(rngCheck)
+	},
+}
+
+// rngCheck selects the startup RNG self-check: off, warn or fail
+var rngCheck string
+
+// checkRNG runs crypto.CheckRNG for --rng-check. With warn a failure is
+// printed and the command continues; with fail it is returned, so the
+// command stops before generating any key material.
+func checkRNG(mode string) error {
+	switch mode {
+	case "off":
+		return nil
+	case "warn", "fail":
+	default:
+		return fmt.Errorf("invalid --rng-check: %s (must be off, warn or fail)", mode)
+	}
+	err := crypto.CheckRNG()
+	if err == nil || mode == "fail" {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	return nil
 }
 
 // Global reporter for signal handling
@@ -41,7 +70,7 @@ func Execute(version string) bool {
 
 	// Check if first arg is a known subcommand
 	cmd := os.Args[1]
-	if cmd != "encrypt" && cmd != "decrypt" && cmd != "help" && cmd != "--help" && cmd != "-h" && cmd != "version" && cmd != "--version" && cmd != "-v" {
+	if cmd != "encrypt" && cmd != "decrypt" && cmd != "bench" && cmd != "passgen" && cmd != "help" && cmd != "--help" && cmd != "-h" && cmd != "version" && cmd != "--version" && cmd != "-v" {
 		return false
 	}
 
@@ -59,6 +88,11 @@ func Execute(version string) bool {
 	}()
 
 	if err := rootCmd.Execute(); err != nil {
+		// Pass on the exit status of a failed --pipe command
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 	return true
@@ -67,4 +101,5 @@ func Execute(version string) bool {
 func init() {
 	// Disable default completion command
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.PersistentFlags().StringVar(&rngCheck, "rng-check", "off", "Self-check the system RNG at startup: off, warn, or fail")
 }
@@ -1,6 +1,8 @@
 package crypto
 
 import (
+	"fmt"
+	"runtime"
 	"testing"
 
 	"golang.org/x/crypto/chacha20"
@@ -95,6 +97,28 @@ func BenchmarkXChaCha20(b *testing.B) {
 	}
 }
 
+// BenchmarkParanoidEncrypt measures paranoid Serpent-CTR + XChaCha20 +
+// HMAC-SHA3 throughput per 1 MiB block, serially and with the layers
+// spread over every CPU.
+func BenchmarkParanoidEncrypt(b *testing.B) {
+	for _, workers := range []int{1, max(runtime.GOMAXPROCS(0), 2)} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			mac, _ := NewMAC(make([]byte, 32), true)
+			cs, _ := NewCipherSuite(make([]byte, 32), make([]byte, 24), make([]byte, 32), make([]byte, 16),
+				mac, NewHKDFStream(make([]byte, 32), make([]byte, 32)), true)
+			cs.SetWorkers(workers)
+			src := make([]byte, 1<<20) // 1 MiB
+			dst := make([]byte, len(src))
+
+			b.ResetTimer()
+			b.SetBytes(int64(len(src)))
+			for i := 0; i < b.N; i++ {
+				cs.Encrypt(dst, src)
+			}
+		})
+	}
+}
+
 // BenchmarkSecureZero measures secure memory zeroing performance.
 func BenchmarkSecureZero(b *testing.B) {
 	data := make([]byte, 32) // Typical key size
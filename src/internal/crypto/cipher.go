@@ -5,6 +5,7 @@ import (
 	"errors"
 	"hash"
 	"io"
+	"sync"
 
 	"github.com/Picocrypt/serpent"
 	"golang.org/x/crypto/chacha20"
@@ -20,8 +21,24 @@ type CipherSuite struct {
 	hkdf     io.Reader
 	paranoid bool
 	key      []byte // Keep for rekeying
+
+	// Parallel mode (SetWorkers) starts fresh ciphers at stream positions
+	// instead of using chacha and serpent
+	workers   int
+	nonce     []byte
+	serpentIV []byte
+	pos       int64 // Bytes processed since the last (re)key
 }
 
+// minParallelSegment is the smallest share of a call given to one worker;
+// below it the cost of starting a goroutine and ciphers outweighs the gain.
+const minParallelSegment = 64 << 10
+
+// testHookLayer, if set, is called with each layer's name ("serpent" or
+// "chacha") and output as Encrypt and Decrypt apply it. Both layers are XOR
+// keystreams, so their order only shows in the intermediate value.
+var testHookLayer func(layer string, out []byte)
+
 // NewCipherSuite creates a new cipher suite with the given parameters.
 //
 // CRITICAL: Encryption order is Serpent-CTR -> XChaCha20 -> MAC
@@ -33,11 +50,13 @@ func NewCipherSuite(key, nonce, serpentKey, serpentIV []byte, mac hash.Hash, hkd
 	}
 
 	cs := &CipherSuite{
-		chacha:   chacha,
-		mac:      mac,
-		hkdf:     hkdf,
-		paranoid: paranoid,
-		key:      key,
+		chacha:    chacha,
+		mac:       mac,
+		hkdf:      hkdf,
+		paranoid:  paranoid,
+		key:       key,
+		nonce:     append([]byte(nil), nonce...),
+		serpentIV: append([]byte(nil), serpentIV...),
 	}
 
 	if paranoid {
@@ -57,12 +76,20 @@ func NewCipherSuite(key, nonce, serpentKey, serpentIV []byte, mac hash.Hash, hkd
 //
 // CRITICAL: This exact order MUST be preserved.
 func (cs *CipherSuite) Encrypt(dst, src []byte) {
+	if cs.workers > 1 {
+		cs.parallel(dst, src, true)
+		return
+	}
+	cs.pos += int64(len(src))
+
 	if cs.paranoid {
 		cs.serpent.XORKeyStream(dst, src)
+		layerDone("serpent", dst)
 		copy(src, dst) // serpent output becomes chacha input
 	}
 
 	cs.chacha.XORKeyStream(dst, src)
+	layerDone("chacha", dst)
 
 	// MAC the ciphertext (encrypt-then-MAC)
 	cs.mac.Write(dst)
@@ -73,14 +100,111 @@ func (cs *CipherSuite) Encrypt(dst, src []byte) {
 //
 // CRITICAL: This exact order MUST be preserved.
 func (cs *CipherSuite) Decrypt(dst, src []byte) {
+	if cs.workers > 1 {
+		cs.parallel(dst, src, false)
+		return
+	}
+	cs.pos += int64(len(src))
+
 	// MAC the ciphertext first (verify-then-decrypt)
 	cs.mac.Write(src)
 
 	cs.chacha.XORKeyStream(dst, src)
+	layerDone("chacha", dst)
 
 	if cs.paranoid {
 		copy(src, dst) // chacha output becomes serpent input
 		cs.serpent.XORKeyStream(dst, src)
+		layerDone("serpent", dst)
+	}
+}
+
+// SetWorkers spreads each paranoid Encrypt or Decrypt call over up to n
+// goroutines, each running both layers over its own segment of the buffer
+// with ciphers started at that segment's keystream position. Both layers
+// are seekable counter-mode streams, so the output and the MAC input are
+// byte-identical to the serial path; only the work is split. Normal mode,
+// n <= 1 and calls too small to split are unaffected.
+//
+// SetWorkers must be called before the first Encrypt or Decrypt, and the
+// layer test hook is not called in parallel mode.
+func (cs *CipherSuite) SetWorkers(n int) {
+	if cs.paranoid {
+		cs.workers = n
+	}
+}
+
+// parallel is Encrypt (encrypt=true) or Decrypt over segments of src. It
+// never modifies src, unlike the serial path.
+func (cs *CipherSuite) parallel(dst, src []byte, encrypt bool) {
+	n := len(src)
+	seg := (n + cs.workers - 1) / cs.workers
+	seg = max(seg, minParallelSegment)
+	seg = (seg + 63) &^ 63 // Whole XChaCha20 blocks, so segments start aligned
+
+	var wg sync.WaitGroup
+	for off := 0; off < n; off += seg {
+		end := min(off+seg, n)
+		wg.Add(1)
+		go func(d, s []byte, pos int64) {
+			defer wg.Done()
+			if encrypt {
+				cs.serpentAt(pos).XORKeyStream(d, s)
+				cs.chachaAt(pos).XORKeyStream(d, d)
+			} else {
+				cs.chachaAt(pos).XORKeyStream(d, s)
+				cs.serpentAt(pos).XORKeyStream(d, d)
+			}
+		}(dst[off:end], src[off:end], cs.pos+int64(off))
+	}
+
+	// Decryption MACs the ciphertext, which the workers only read
+	if !encrypt {
+		cs.mac.Write(src)
+	}
+	wg.Wait()
+	if encrypt {
+		cs.mac.Write(dst)
+	}
+	cs.pos += int64(n)
+}
+
+// chachaAt returns an XChaCha20 stream positioned pos bytes after the
+// current nonce's start.
+func (cs *CipherSuite) chachaAt(pos int64) *chacha20.Cipher {
+	// The key and nonce were accepted by NewCipherSuite or Rekey
+	c, _ := chacha20.NewUnauthenticatedCipher(cs.key, cs.nonce)
+	c.SetCounter(uint32(pos / 64)) // Rekeying keeps pos far below 2^32 blocks
+	if skip := pos % 64; skip > 0 {
+		buf := make([]byte, skip)
+		c.XORKeyStream(buf, buf)
+	}
+	return c
+}
+
+// serpentAt returns a Serpent-CTR stream positioned pos bytes after the
+// current IV's start. Like crypto/cipher's CTR, the counter is the whole
+// 128-bit IV, incremented big-endian.
+func (cs *CipherSuite) serpentAt(pos int64) cipher.Stream {
+	iv := append([]byte(nil), cs.serpentIV...)
+	carry := uint64(pos / 16)
+	for i := len(iv) - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(iv[i]) + carry&0xff
+		iv[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	s := cipher.NewCTR(cs.serpentS, iv)
+	if skip := pos % 16; skip > 0 {
+		buf := make([]byte, skip)
+		s.XORKeyStream(buf, buf)
+	}
+	return s
+}
+
+// layerDone passes a layer's output to testHookLayer if set.
+func layerDone(layer string, out []byte) {
+	if testHookLayer != nil {
+		testHookLayer(layer, out)
 	}
 }
 
@@ -102,6 +226,8 @@ func (cs *CipherSuite) Rekey() error {
 		return err
 	}
 	cs.chacha = chacha
+	cs.nonce = nonce
+	cs.pos = 0
 
 	// Read new IV for Serpent (if paranoid)
 	if cs.paranoid {
@@ -110,6 +236,7 @@ func (cs *CipherSuite) Rekey() error {
 			return errors.New("fatal hkdf.Read error during rekey (serpent IV)")
 		}
 		cs.serpent = cipher.NewCTR(cs.serpentS, serpentIV)
+		cs.serpentIV = serpentIV
 	}
 
 	return nil
@@ -2,7 +2,9 @@ package crypto
 
 import (
 	"bytes"
+	"encoding/hex"
 	"testing"
+	"time"
 )
 
 func TestRandomBytes(t *testing.T) {
@@ -97,6 +99,19 @@ func TestDeriveKey(t *testing.T) {
 	}
 }
 
+func TestCalibrateDeriveKey(t *testing.T) {
+	// The estimate scales one 32 MiB pass up to the full cost, so it can
+	// never be below the time the calibration itself took
+	start := time.Now()
+	estimate := CalibrateDeriveKey(true, 0)
+	if elapsed := time.Since(start); estimate < elapsed {
+		t.Errorf("CalibrateDeriveKey = %s; below the %s it took to run", estimate, elapsed)
+	}
+	if estimate <= 0 {
+		t.Errorf("CalibrateDeriveKey = %s; want a positive estimate", estimate)
+	}
+}
+
 func TestSubkeyReader(t *testing.T) {
 	key := make([]byte, 32)
 	salt := make([]byte, 32)
@@ -535,3 +550,160 @@ func TestCipherSuiteEncryptDecrypt(t *testing.T) {
 		})
 	}
 }
+
+// TestCipherSuiteLayerOrder pins the paranoid layer order with vectors
+// computed directly from Serpent-CTR and XChaCha20. The final ciphertext
+// is the same in either order, as both are XOR keystreams, so the vectors
+// check each layer's output: encryption must apply Serpent first and
+// decryption must remove XChaCha20 first.
+func TestCipherSuiteLayerOrder(t *testing.T) {
+	key := make([]byte, 32)
+	nonce := make([]byte, 24)
+	serpentKey := make([]byte, 32)
+	serpentIV := make([]byte, 16)
+	for i := range key {
+		key[i] = byte(i)
+		serpentKey[i] = byte(i + 32)
+	}
+	for i := range nonce {
+		nonce[i] = byte(i + 64)
+	}
+	for i := range serpentIV {
+		serpentIV[i] = byte(i + 88)
+	}
+	plaintext := []byte("Picocrypt paranoid mode: Serpent-CTR first, then XChaCha20 last.")
+
+	mustHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// plaintext XOR Serpent-CTR keystream
+	serpentLayer := mustHex("32e88ea8d769c77b97abe6a8fe77c68a072a4ef5f0c0107757ecb58bc09bd1bc" +
+		"88eb4de4cec59862ab70ed9594c274486ce19a59a5639bed974ce26f9d8f833d")
+	// serpentLayer XOR XChaCha20 keystream
+	ciphertext := mustHex("b706bfbee414e4bdb5bed2f4ac518bf53b44c466a9f05fabd3bfad8f4337c7da" +
+		"b75c496a86a40087e5c8fc00af32a83ecb86338b345741053a25c776325835e5")
+
+	type layerOutput struct {
+		layer string
+		out   []byte
+	}
+	var got []layerOutput
+	testHookLayer = func(layer string, out []byte) {
+		got = append(got, layerOutput{layer, bytes.Clone(out)})
+	}
+	t.Cleanup(func() { testHookLayer = nil })
+
+	check := func(op string, want []layerOutput) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s applied %d layers; want %d", op, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].layer != want[i].layer {
+				t.Errorf("%s layer %d = %s; want %s", op, i, got[i].layer, want[i].layer)
+			}
+			if !bytes.Equal(got[i].out, want[i].out) {
+				t.Errorf("%s %s output = %x; want %x", op, got[i].layer, got[i].out, want[i].out)
+			}
+		}
+	}
+
+	mac, _ := NewMAC(make([]byte, 32), true)
+	encSuite, err := NewCipherSuite(key, nonce, serpentKey, serpentIV, mac, NewHKDFStream(key, make([]byte, 32)), true)
+	if err != nil {
+		t.Fatalf("NewCipherSuite() failed: %v", err)
+	}
+	dst := make([]byte, len(plaintext))
+	encSuite.Encrypt(dst, bytes.Clone(plaintext))
+	check("Encrypt", []layerOutput{{"serpent", serpentLayer}, {"chacha", ciphertext}})
+
+	got = nil
+	mac, _ = NewMAC(make([]byte, 32), true)
+	decSuite, err := NewCipherSuite(key, nonce, serpentKey, serpentIV, mac, NewHKDFStream(key, make([]byte, 32)), true)
+	if err != nil {
+		t.Fatalf("NewCipherSuite() failed: %v", err)
+	}
+	decSuite.Decrypt(dst, bytes.Clone(ciphertext))
+	check("Decrypt", []layerOutput{{"chacha", serpentLayer}, {"serpent", plaintext}})
+}
+
+// TestCipherSuiteParallel checks that SetWorkers changes only how the work
+// is split: ciphertext, plaintext and MAC must match the serial path for
+// call sizes that do and do not fall on block boundaries, across a rekey.
+func TestCipherSuiteParallel(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+	serpentKey := bytes.Repeat([]byte{0x22}, 32)
+	nonce := bytes.Repeat([]byte{0x33}, 24)
+	// An IV whose low bytes carry when advanced, as counters do
+	serpentIV := append(bytes.Repeat([]byte{0x44}, 8), bytes.Repeat([]byte{0xFF}, 8)...)
+	hkdfSalt := make([]byte, 32)
+
+	newSuite := func(workers int) *CipherSuite {
+		mac, _ := NewMAC(make([]byte, 32), true)
+		cs, err := NewCipherSuite(key, nonce, serpentKey, serpentIV, mac, NewHKDFStream(key, hkdfSalt), true)
+		if err != nil {
+			t.Fatalf("NewCipherSuite() failed: %v", err)
+		}
+		cs.SetWorkers(workers)
+		return cs
+	}
+
+	blockCounts := []int{1, 2, 5}
+	if testing.Short() {
+		blockCounts = []int{2}
+	}
+	for _, blocks := range blockCounts {
+		for _, tail := range []int{0, 15, 200003} {
+			// Full 1 MiB blocks then a short final one, with a rekey midway
+			var sizes []int
+			for i := 0; i < blocks; i++ {
+				sizes = append(sizes, 1<<20)
+			}
+			if tail > 0 {
+				sizes = append(sizes, tail)
+			}
+			rekeyAfter := blocks / 2
+
+			for _, workers := range []int{3, 8} {
+				serial, par := newSuite(1), newSuite(workers)
+				serialDec, parDec := newSuite(1), newSuite(workers)
+				for i, n := range sizes {
+					plain := make([]byte, n)
+					for j := range plain {
+						plain[j] = byte(i*31 + j)
+					}
+					want := make([]byte, n)
+					serial.Encrypt(want, append([]byte(nil), plain...))
+					got := make([]byte, n)
+					par.Encrypt(got, append([]byte(nil), plain...))
+					if !bytes.Equal(got, want) {
+						t.Fatalf("blocks=%d tail=%d workers=%d: ciphertext of call %d differs", blocks, tail, workers, i)
+					}
+
+					wantPlain := make([]byte, n)
+					serialDec.Decrypt(wantPlain, append([]byte(nil), want...))
+					gotPlain := make([]byte, n)
+					parDec.Decrypt(gotPlain, append([]byte(nil), want...))
+					if !bytes.Equal(gotPlain, plain) || !bytes.Equal(wantPlain, plain) {
+						t.Fatalf("blocks=%d tail=%d workers=%d: call %d does not decrypt", blocks, tail, workers, i)
+					}
+
+					if i+1 == rekeyAfter {
+						for _, cs := range []*CipherSuite{serial, par, serialDec, parDec} {
+							if err := cs.Rekey(); err != nil {
+								t.Fatalf("Rekey() failed: %v", err)
+							}
+						}
+					}
+				}
+				if !bytes.Equal(par.Sum(), serial.Sum()) || !bytes.Equal(parDec.Sum(), serial.Sum()) {
+					t.Errorf("blocks=%d tail=%d workers=%d: MAC differs from the serial path", blocks, tail, workers)
+				}
+			}
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/hkdf"
@@ -57,24 +58,62 @@ const (
 //
 // CRITICAL: Parameters MUST NOT change or existing volumes cannot be decrypted.
 func DeriveKey(password, salt []byte, paranoid bool) ([]byte, error) {
+	return DeriveKeyThreads(password, salt, paranoid, 0)
+}
+
+// Argon2Threads returns the default Argon2 thread count for the mode.
+func Argon2Threads(paranoid bool) uint8 {
+	if paranoid {
+		return Argon2ParanoidThreads
+	}
+	return Argon2NormalThreads
+}
+
+// Argon2Passes returns the default Argon2 pass count for the mode.
+func Argon2Passes(paranoid bool) uint8 {
+	if paranoid {
+		return Argon2ParanoidPasses
+	}
+	return Argon2NormalPasses
+}
+
+// DeriveKeyThreads is DeriveKey with an explicit Argon2 thread count.
+// Argon2 parallelism is part of the key, so a volume derived with a
+// non-default count must record it; threads == 0 uses the mode default.
+func DeriveKeyThreads(password, salt []byte, paranoid bool, threads uint8) ([]byte, error) {
+	return DeriveKeyCost(password, salt, paranoid, threads, 0)
+}
+
+// DeriveKeyCost is DeriveKeyThreads with an explicit Argon2 pass count as
+// well. The memory stays that of the mode. Like the thread count, the pass
+// count is part of the key and must be recorded; passes == 0 uses the mode
+// default.
+func DeriveKeyCost(password, salt []byte, paranoid bool, threads, passes uint8) ([]byte, error) {
+	if threads == 0 {
+		threads = Argon2Threads(paranoid)
+	}
+	if passes == 0 {
+		passes = Argon2Passes(paranoid)
+	}
+
 	var key []byte
 
 	if paranoid {
 		key = argon2.IDKey(
 			password,
 			salt,
-			Argon2ParanoidPasses,
+			uint32(passes),
 			Argon2ParanoidMemory,
-			Argon2ParanoidThreads,
+			threads,
 			Argon2KeySize,
 		)
 	} else {
 		key = argon2.IDKey(
 			password,
 			salt,
-			Argon2NormalPasses,
+			uint32(passes),
 			Argon2NormalMemory,
-			Argon2NormalThreads,
+			threads,
 			Argon2KeySize,
 		)
 	}
@@ -87,6 +126,40 @@ func DeriveKey(password, salt []byte, paranoid bool) ([]byte, error) {
 	return key, nil
 }
 
+// calibrationMemory is the Argon2 memory in KiB timed by CalibrateDeriveKey.
+const calibrationMemory = 32 << 10 // 32 MiB
+
+// CalibrateDeriveKey estimates how long DeriveKeyThreads takes on this
+// machine by timing a single Argon2 pass over a fraction of the memory and
+// scaling up. Argon2 time grows about linearly with passes and memory, so
+// the estimate is rough, but it costs a small fraction of a real derivation.
+// threads == 0 uses the mode default.
+func CalibrateDeriveKey(paranoid bool, threads uint8) time.Duration {
+	if threads == 0 {
+		threads = Argon2Threads(paranoid)
+	}
+	passes, memory := Argon2NormalPasses, Argon2NormalMemory
+	if paranoid {
+		passes, memory = Argon2ParanoidPasses, Argon2ParanoidMemory
+	}
+
+	start := time.Now()
+	argon2.IDKey([]byte("calibration"), make([]byte, 16), 1, calibrationMemory, threads, Argon2KeySize)
+	return time.Since(start) * time.Duration(passes*(memory/calibrationMemory))
+}
+
+// CalibratePasses returns the Argon2 pass count at the mode's memory whose
+// derivation CalibrateDeriveKey estimates to take at least target, and never
+// fewer than the mode default. The caller bounds it to what it can record.
+func CalibratePasses(paranoid bool, threads uint8, target time.Duration) int {
+	def := int(Argon2Passes(paranoid))
+	perPass := CalibrateDeriveKey(paranoid, threads) / time.Duration(def)
+	if perPass <= 0 {
+		return def
+	}
+	return max(def, int((target+perPass-1)/perPass))
+}
+
 // HKDF subkey sizes
 const (
 	SubkeyHeaderSize  = 64 // For v2 header HMAC
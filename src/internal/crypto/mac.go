@@ -25,5 +25,17 @@ func NewMAC(subkey []byte, paranoid bool) (hash.Hash, error) {
 	return mac, nil
 }
 
+// PepperPassword mixes an out-of-band pepper into the password before key
+// derivation as HMAC-SHA3-512(pepper, password). With an empty pepper the
+// password is returned unchanged, so unpeppered volumes derive the same key.
+func PepperPassword(password, pepper []byte) []byte {
+	if len(pepper) == 0 {
+		return password
+	}
+	mac := hmac.New(sha3.New512, pepper)
+	mac.Write(password)
+	return mac.Sum(nil)
+}
+
 // MACSize returns the output size of the MAC (64 bytes for both modes).
 const MACSize = 64
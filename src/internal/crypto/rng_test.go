@@ -0,0 +1,100 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// blockingReader never returns, like /dev/random before the pool is seeded
+type blockingReader struct{ release chan struct{} }
+
+func (r blockingReader) Read(p []byte) (int, error) {
+	<-r.release
+	return 0, io.EOF
+}
+
+// failingReader fails every read
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("getrandom: not implemented")
+}
+
+// biasedReader returns random bytes with the high bit cleared
+type biasedReader struct{}
+
+func (biasedReader) Read(p []byte) (int, error) {
+	n, err := rand.Read(p)
+	for i := range p[:n] {
+		p[i] &= 0x7F
+	}
+	return n, err
+}
+
+// skewedOnceReader returns biased bytes for the first n bytes, then random
+// ones, like a healthy source that fails the monobit test by chance
+type skewedOnceReader struct{ n int }
+
+func (r *skewedOnceReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return rand.Read(p)
+	}
+	n, err := biasedReader{}.Read(p[:min(len(p), r.n)])
+	r.n -= n
+	return n, err
+}
+
+func TestCheckRNG(t *testing.T) {
+	if err := CheckRNG(); err != nil {
+		t.Fatalf("CheckRNG on crypto/rand: %v", err)
+	}
+
+	sample := make([]byte, rngSampleSize)
+	if _, err := rand.Read(sample); err != nil {
+		t.Fatal(err)
+	}
+	repeating := bytes.Repeat(sample, 2)
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	tests := []struct {
+		name string
+		r    io.Reader
+	}{
+		{"zeros", bytes.NewReader(make([]byte, 2*rngSampleSize))},
+		{"constant", bytes.NewReader(bytes.Repeat([]byte{0xA5}, 2*rngSampleSize))},
+		{"repeating", bytes.NewReader(repeating)},
+		{"biased", biasedReader{}},
+		{"short", bytes.NewReader(sample)},
+		{"failing", failingReader{}},
+		{"blocking", blockingReader{release}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRNG(tt.r, 50*time.Millisecond)
+			if !errors.Is(err, perrors.ErrRNGUnhealthy) {
+				t.Errorf("checkRNG = %v; want ErrRNGUnhealthy", err)
+			}
+		})
+	}
+}
+
+// TestCheckRNGRetry tests that a single monobit failure is retried with
+// fresh samples before it is reported
+func TestCheckRNGRetry(t *testing.T) {
+	if err := checkRNG(&skewedOnceReader{n: 2 * rngSampleSize}, time.Second); err != nil {
+		t.Errorf("checkRNG after one skewed pair = %v; want nil", err)
+	}
+	err := checkRNG(biasedReader{}, time.Second)
+	if !errors.Is(err, perrors.ErrRNGUnhealthy) || !errors.Is(err, errMonobit) {
+		t.Errorf("checkRNG on a biased source = %v; want a monobit ErrRNGUnhealthy", err)
+	}
+}
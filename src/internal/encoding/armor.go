@@ -0,0 +1,170 @@
+package encoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+)
+
+// ASCII armor wraps a binary volume in base64 between BEGIN/END marker lines,
+// like GPG's, so it survives being pasted into text-only channels.
+const (
+	ArmorBegin = "-----BEGIN PICOCRYPT VOLUME-----"
+	ArmorEnd   = "-----END PICOCRYPT VOLUME-----"
+	ArmorExt   = ".asc" // Suffix for an armored copy written next to a volume
+
+	armorLineLen = 64
+)
+
+// ErrInvalidArmor means armored text is missing its markers or holds
+// something other than base64, e.g. because a paste was cut short.
+var ErrInvalidArmor = errors.New("invalid armored volume")
+
+// Armor writes src to dst as armored text, 64 base64 characters per line.
+func Armor(dst io.Writer, src io.Reader) error {
+	if _, err := io.WriteString(dst, ArmorBegin+"\n"); err != nil {
+		return err
+	}
+	lw := &lineWriter{w: dst}
+	enc := base64.NewEncoder(base64.StdEncoding, lw)
+	if _, err := io.Copy(enc, src); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if lw.col > 0 {
+		if _, err := io.WriteString(dst, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(dst, ArmorEnd+"\n")
+	return err
+}
+
+// ArmoredSize returns the size of the armored text Armor writes for n bytes.
+func ArmoredSize(n int64) int64 {
+	chars := (n + 2) / 3 * 4
+	lines := (chars + armorLineLen - 1) / armorLineLen
+	return int64(len(ArmorBegin)+1) + chars + lines + int64(len(ArmorEnd)+1)
+}
+
+// lineWriter breaks the base64 stream into lines of armorLineLen.
+type lineWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(armorLineLen-lw.col, len(p))
+		if _, err := lw.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		lw.col += n
+		p = p[n:]
+		if lw.col == armorLineLen {
+			if _, err := lw.w.Write([]byte("\n")); err != nil {
+				return written, err
+			}
+			lw.col = 0
+		}
+	}
+	return written, nil
+}
+
+// IsArmored reports whether the file at path starts with the armor BEGIN
+// line, ignoring leading whitespace.
+func IsArmored(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	return HasArmorBegin(f)
+}
+
+// HasArmorBegin is IsArmored for the text read from r.
+func HasArmorBegin(r io.Reader) bool {
+	buf := make([]byte, 256)
+	n, _ := io.ReadFull(r, buf)
+	return bytes.HasPrefix(bytes.TrimLeft(buf[:n], " \t\r\n"), []byte(ArmorBegin))
+}
+
+// NewArmorReader returns a reader yielding the binary volume inside the
+// armored text read from r. Line breaks and surrounding whitespace are
+// ignored, so re-wrapped text still decodes. Reads fail with
+// ErrInvalidArmor if the text ends before the END line.
+func NewArmorReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			if string(trimmed) != ArmorBegin {
+				return nil, ErrInvalidArmor
+			}
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidArmor
+		}
+	}
+	body := &armorBody{r: br}
+	return &fullReader{r: base64.NewDecoder(base64.StdEncoding, body)}, nil
+}
+
+// armorBody yields the base64 characters between the markers.
+type armorBody struct {
+	r    *bufio.Reader
+	buf  []byte
+	done bool
+}
+
+func (b *armorBody) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+		line, err := b.r.ReadBytes('\n')
+		trimmed := bytes.TrimSpace(line)
+		if string(trimmed) == ArmorEnd {
+			b.done = true
+			continue
+		}
+		b.buf = bytes.Join(bytes.Fields(trimmed), nil)
+		if err == io.EOF {
+			// No END line: the text was cut short
+			return 0, ErrInvalidArmor
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+// fullReader fills p completely unless the stream ends, so callers that
+// expect a header field in a single Read work on armored input too.
+type fullReader struct {
+	r io.Reader
+}
+
+func (f *fullReader) Read(p []byte) (int, error) {
+	n, err := io.ReadFull(f.r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	var corrupt base64.CorruptInputError
+	if errors.As(err, &corrupt) {
+		err = ErrInvalidArmor
+	}
+	return n, err
+}
@@ -0,0 +1,132 @@
+package encoding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArmorRoundtrip(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 3, 47, 48, 49, 1000, 100000} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+
+		var armored bytes.Buffer
+		if err := Armor(&armored, bytes.NewReader(data)); err != nil {
+			t.Fatalf("Armor(%d bytes) failed: %v", size, err)
+		}
+		if got := ArmoredSize(int64(size)); got != int64(armored.Len()) {
+			t.Errorf("ArmoredSize(%d) = %d; Armor wrote %d", size, got, armored.Len())
+		}
+		text := armored.String()
+		if !strings.HasPrefix(text, ArmorBegin+"\n") || !strings.HasSuffix(text, "\n"+ArmorEnd+"\n") {
+			t.Fatalf("Armor(%d bytes) is missing its markers:\n%s", size, text)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+			if len(line) > armorLineLen && !strings.HasPrefix(line, "-----") {
+				t.Fatalf("Armor(%d bytes) has a %d character line", size, len(line))
+			}
+		}
+
+		r, err := NewArmorReader(&armored)
+		if err != nil {
+			t.Fatalf("NewArmorReader(%d bytes) failed: %v", size, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Reading armor (%d bytes) failed: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("Armor round trip of %d bytes did not recover the data", size)
+		}
+	}
+}
+
+func TestArmorReaderRewrapped(t *testing.T) {
+	data := bytes.Repeat([]byte("picocrypt"), 50)
+	var armored bytes.Buffer
+	if err := Armor(&armored, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Armor failed: %v", err)
+	}
+
+	// A chat client may re-wrap lines, indent them and use CRLF
+	lines := strings.Split(strings.TrimSpace(armored.String()), "\n")
+	body := strings.Join(lines[1:len(lines)-1], "")
+	var rewrapped strings.Builder
+	rewrapped.WriteString("\r\n  " + ArmorBegin + "\r\n")
+	for len(body) > 0 {
+		n := min(37, len(body))
+		rewrapped.WriteString("  " + body[:n] + "\r\n")
+		body = body[n:]
+	}
+	rewrapped.WriteString(ArmorEnd)
+
+	r, err := NewArmorReader(strings.NewReader(rewrapped.String()))
+	if err != nil {
+		t.Fatalf("NewArmorReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading rewrapped armor failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Rewrapped armor did not recover the data")
+	}
+}
+
+func TestArmorReaderInvalid(t *testing.T) {
+	var armored bytes.Buffer
+	if err := Armor(&armored, bytes.NewReader(make([]byte, 500))); err != nil {
+		t.Fatalf("Armor failed: %v", err)
+	}
+	text := armored.String()
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"no begin line", "not armor\n"},
+		{"empty", ""},
+		{"cut short", text[:len(text)/2]},
+		{"not base64", ArmorBegin + "\n!!!!\n" + ArmorEnd + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewArmorReader(strings.NewReader(tt.text))
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if !errors.Is(err, ErrInvalidArmor) {
+				t.Errorf("Expected ErrInvalidArmor, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsArmored(t *testing.T) {
+	dir := t.TempDir()
+	armoredPath := filepath.Join(dir, "armored.pcv")
+	binaryPath := filepath.Join(dir, "binary.pcv")
+	if err := os.WriteFile(armoredPath, []byte("\n"+ArmorBegin+"\nAAAA\n"+ArmorEnd+"\n"), 0644); err != nil {
+		t.Fatalf("Write file: %v", err)
+	}
+	if err := os.WriteFile(binaryPath, []byte{0x76, 0x32, 0x00, 0xff}, 0644); err != nil {
+		t.Fatalf("Write file: %v", err)
+	}
+
+	if !IsArmored(armoredPath) {
+		t.Error("IsArmored should detect the armored file")
+	}
+	if IsArmored(binaryPath) {
+		t.Error("IsArmored should not match a binary file")
+	}
+	if IsArmored(filepath.Join(dir, "missing")) {
+		t.Error("IsArmored should be false for a missing file")
+	}
+}
@@ -42,3 +42,15 @@ func Unpad(data []byte) []byte {
 	}
 	return data[:BlockSize-padLen]
 }
+
+// UnpadLen removes padLen bytes of padding from a 128-byte block, for
+// volumes that store the length instead of relying on the last byte.
+//
+// Returns data unchanged if it is shorter than BlockSize or padLen is
+// outside 0-BlockSize, like Unpad does for invalid padding.
+func UnpadLen(data []byte, padLen int) []byte {
+	if len(data) < BlockSize || padLen < 0 || padLen > BlockSize {
+		return data
+	}
+	return data[:BlockSize-padLen]
+}
@@ -85,3 +85,33 @@ func TestUnpadInvalidData(t *testing.T) {
 		t.Errorf("Unpad(1 byte) should return data unchanged")
 	}
 }
+
+func TestUnpadLen(t *testing.T) {
+	for _, dataLen := range []int{0, 1, 100, 127, 128} {
+		padded := Pad(bytes.Repeat([]byte{0x80}, dataLen))
+		padLen := len(padded) - dataLen
+		if len(padded) == 2*BlockSize {
+			padded = padded[BlockSize:] // Only the final block is unpadded
+		}
+		want := padded[:BlockSize-padLen]
+		if got := UnpadLen(padded, padLen); !bytes.Equal(got, want) {
+			t.Errorf("UnpadLen(%d bytes of data) = %d bytes; want %d", dataLen, len(got), len(want))
+		}
+	}
+
+	// The stored length wins over a last byte that looks like padding
+	block := bytes.Repeat([]byte{0x05}, BlockSize)
+	if got := UnpadLen(block, 0); len(got) != BlockSize {
+		t.Errorf("UnpadLen(0) removed %d bytes", BlockSize-len(got))
+	}
+
+	// Invalid lengths and short data are returned unchanged
+	for _, padLen := range []int{-1, BlockSize + 1} {
+		if got := UnpadLen(block, padLen); len(got) != BlockSize {
+			t.Errorf("UnpadLen(%d) = %d bytes; want data unchanged", padLen, len(got))
+		}
+	}
+	if got := UnpadLen([]byte{0x01}, 1); len(got) != 1 {
+		t.Error("UnpadLen(short) should return data unchanged")
+	}
+}
@@ -17,6 +17,7 @@ package encoding
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/Picocrypt/infectious"
 )
@@ -27,6 +28,12 @@ const (
 	RS128EncodedSize = 136 // Output chunk size for RS128 (128 + 8 parity)
 )
 
+// RSCodecVersion identifies the codec geometry NewRSCodecs builds. Every
+// volume is written with this geometry; a build that changes the shape of
+// any codec must bump it, so that old volumes are refused by Verify instead
+// of being silently misdecoded.
+const RSCodecVersion = 1
+
 // RSCodecs holds pre-initialized Reed-Solomon Forward Error Correction (FEC) codecs.
 // All codecs are created once at startup and reused throughout the application lifetime.
 //
@@ -40,6 +47,23 @@ type RSCodecs struct {
 	RS32  *infectious.FEC // 32 data -> 96 total bytes - HKDF salt, keyfile hash
 	RS64  *infectious.FEC // 64 data -> 192 total bytes - key hash, auth tag
 	RS128 *infectious.FEC // 128 data -> 136 total bytes (6% overhead) - payload chunks
+
+	Version int // Geometry the codecs were built for, RSCodecVersion from NewRSCodecs
+}
+
+// rsGeometry is the data and total size of each codec at RSCodecVersion.
+var rsGeometry = []struct {
+	name            string
+	required, total int
+	codec           func(*RSCodecs) *infectious.FEC
+}{
+	{"RS1", 1, 3, func(c *RSCodecs) *infectious.FEC { return c.RS1 }},
+	{"RS5", 5, 15, func(c *RSCodecs) *infectious.FEC { return c.RS5 }},
+	{"RS16", 16, 48, func(c *RSCodecs) *infectious.FEC { return c.RS16 }},
+	{"RS24", 24, 72, func(c *RSCodecs) *infectious.FEC { return c.RS24 }},
+	{"RS32", 32, 96, func(c *RSCodecs) *infectious.FEC { return c.RS32 }},
+	{"RS64", 64, 192, func(c *RSCodecs) *infectious.FEC { return c.RS64 }},
+	{"RS128", RS128DataSize, RS128EncodedSize, func(c *RSCodecs) *infectious.FEC { return c.RS128 }},
 }
 
 // NewRSCodecs initializes all Reed-Solomon codecs.
@@ -66,9 +90,46 @@ func NewRSCodecs() (*RSCodecs, error) {
 		RS32:  rs32,
 		RS64:  rs64,
 		RS128: rs128,
+
+		Version: RSCodecVersion,
 	}, nil
 }
 
+// Verify checks that c is the codec set volumes are written with: the same
+// RSCodecVersion and, for each codec, the same data and total sizes. The
+// error names the first difference.
+func (c *RSCodecs) Verify() error {
+	if c == nil {
+		return errors.New("no Reed-Solomon codecs")
+	}
+	if c.Version != RSCodecVersion {
+		return fmt.Errorf("codec version %d, volumes use version %d", c.Version, RSCodecVersion)
+	}
+	for _, g := range rsGeometry {
+		fec := g.codec(c)
+		if fec == nil {
+			return fmt.Errorf("%s codec is missing", g.name)
+		}
+		if fec.Required() != g.required || fec.Total() != g.total {
+			return fmt.Errorf("%s codec is %d->%d, volumes use %d->%d",
+				g.name, fec.Required(), fec.Total(), g.required, g.total)
+		}
+	}
+	return nil
+}
+
+// Codec returns the codec of c that takes required data bytes per block,
+// such as c.RS16 for 16, or nil if there is none. It lets a format
+// descriptor name a codec by its data size.
+func (c *RSCodecs) Codec(required int) *infectious.FEC {
+	for _, g := range rsGeometry {
+		if g.required == required {
+			return g.codec(c)
+		}
+	}
+	return nil
+}
+
 // Encode applies Reed-Solomon encoding to data using the specified codec.
 // The input data length must match the codec's Required() size.
 // Returns encoded data with parity bytes appended (length = codec.Total()).
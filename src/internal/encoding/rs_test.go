@@ -2,7 +2,10 @@ package encoding
 
 import (
 	"bytes"
+	"strings"
 	"testing"
+
+	"github.com/Picocrypt/infectious"
 )
 
 func TestNewRSCodecs(t *testing.T) {
@@ -42,6 +45,63 @@ func TestNewRSCodecs(t *testing.T) {
 	}
 }
 
+func TestRSCodecsVerify(t *testing.T) {
+	codecs, err := NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs() failed: %v", err)
+	}
+	if codecs.Version != RSCodecVersion {
+		t.Errorf("Version = %d; want %d", codecs.Version, RSCodecVersion)
+	}
+	if err := codecs.Verify(); err != nil {
+		t.Fatalf("Verify() failed for NewRSCodecs: %v", err)
+	}
+
+	reshaped, err := infectious.NewFEC(16, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(c *RSCodecs)
+		want   string
+	}{
+		{"version", func(c *RSCodecs) { c.Version++ }, "version"},
+		{"geometry", func(c *RSCodecs) { c.RS16 = reshaped }, "RS16"},
+		{"missing codec", func(c *RSCodecs) { c.RS128 = nil }, "RS128"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alt := *codecs
+			tt.modify(&alt)
+			err := alt.Verify()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify() = %v; want an error naming %s", err, tt.want)
+			}
+		})
+	}
+
+	var none *RSCodecs
+	if err := none.Verify(); err == nil {
+		t.Error("Verify() on nil codecs should fail")
+	}
+}
+
+func TestRSCodecsCodec(t *testing.T) {
+	codecs, err := NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs() failed: %v", err)
+	}
+	if codecs.Codec(16) != codecs.RS16 || codecs.Codec(RS128DataSize) != codecs.RS128 {
+		t.Error("Codec did not return the codec with that data size")
+	}
+	for _, required := range []int{0, 2, 136} {
+		if codecs.Codec(required) != nil {
+			t.Errorf("Codec(%d) returned a codec", required)
+		}
+	}
+}
+
 func TestRSEncodeDecodeRS128(t *testing.T) {
 	codecs, err := NewRSCodecs()
 	if err != nil {
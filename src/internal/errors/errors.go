@@ -16,6 +16,31 @@ var (
 	ErrCorruptHeader = errors.New("header corrupted")
 	ErrCorruptData   = errors.New("data corrupted")
 
+	// ErrPostWriteVerifyFailed means a volume was written but did not verify
+	// when re-read with the same credentials. The volume is left on disk.
+	ErrPostWriteVerifyFailed = errors.New("post-write verification failed")
+
+	// ErrDeleteFailed means the operation completed but some originals
+	// could not, or were deliberately not, deleted.
+	ErrDeleteFailed = errors.New("some files could not be deleted")
+
+	// ErrUnzipFailed means a volume was decrypted but auto-unzip could not
+	// extract the result. The decrypted .zip is kept.
+	ErrUnzipFailed = errors.New("archive could not be extracted")
+
+	// ErrUnknownCompression means an archive entry uses, or a request asks
+	// for, a compression method with no registered compressor or
+	// decompressor.
+	ErrUnknownCompression = errors.New("unknown compression method")
+
+	// ErrNotDurable means the finished output could not be fsynced, so it
+	// may not survive a power loss. The output is left on disk.
+	ErrNotDurable = errors.New("output could not be synced to stable storage")
+
+	// ErrRNGUnhealthy means the system random number generator failed the
+	// startup self-check, so no keys or nonces should be generated.
+	ErrRNGUnhealthy = errors.New("system random number generator failed self-check")
+
 	// Input validation errors
 	ErrNoInputFiles      = errors.New("no input files specified")
 	ErrNoCredentials     = errors.New("no password or keyfiles provided")
@@ -23,11 +48,103 @@ var (
 	ErrInvalidChunkSize  = errors.New("invalid chunk size")
 	ErrDuplicateKeyfiles = errors.New("duplicate keyfiles detected")
 
+	// ErrWeakPassword means the password scores below the requested
+	// minimum strength.
+	ErrWeakPassword = errors.New("password is too weak")
+
 	// File errors
 	ErrFileNotFound    = errors.New("file not found")
 	ErrFileExists      = errors.New("file already exists")
 	ErrInvalidFormat   = errors.New("invalid volume format")
 	ErrVersionMismatch = errors.New("unsupported volume version")
+	ErrNotLegacyVolume = errors.New("volume is not in the legacy v1 format")
+	ErrNotRegularFile  = errors.New("not a regular file")
+
+	// ErrSameInputOutput means the output, or a file written on the way to
+	// it, is one of the inputs, which would be overwritten while being read.
+	ErrSameInputOutput = errors.New("output path is the same as an input")
+
+	// ErrKeyfileInInput means a keyfile would be archived into the volume it
+	// unlocks, and deleted with the inputs when DeleteInputs is set.
+	ErrKeyfileInInput = errors.New("keyfile is inside the files being encrypted")
+
+	// ErrTooManyKeyfiles means more keyfiles were selected than the
+	// request's MaxKeyfiles allows, e.g. a whole directory by mistake.
+	ErrTooManyKeyfiles = errors.New("too many keyfiles")
+
+	// ErrUnexpectedKeyfiles means keyfiles were supplied with
+	// StrictKeyfiles for a volume that was not encrypted with any.
+	ErrUnexpectedKeyfiles = errors.New("keyfiles supplied but the volume does not use keyfiles")
+
+	// ErrInputChangedDuringRead means the input grew or shrank while it was
+	// being encrypted, so the volume would match neither version of it.
+	ErrInputChangedDuringRead = errors.New("input changed size while it was being read")
+
+	// ErrChunkSize means a split chunk does not have the size its siblings
+	// imply, so it was truncated or padded on the way.
+	ErrChunkSize = errors.New("split chunk has an unexpected size")
+
+	// ErrChunkCount means the split chunks found on disk are not the number
+	// the volume's recorded split layout calls for, e.g. a stray extra chunk.
+	ErrChunkCount = errors.New("split chunks do not match the recorded count")
+
+	// ErrFileSystemUnsupported means a request set an option that works on
+	// the OS directly together with a FileSystem that is not the OS.
+	ErrFileSystemUnsupported = errors.New("option is not supported with a custom file system")
+
+	// ErrOutputDirNotWritable means no file could be created next to the
+	// output path, so the operation was refused before doing any work.
+	ErrOutputDirNotWritable = errors.New("output directory is not writable")
+
+	// ErrOutputDirMissing means the output's directory does not exist and
+	// the request did not ask for it to be created.
+	ErrOutputDirMissing = errors.New("output directory does not exist")
+
+	// ErrInsufficientSpace means the destination filesystem cannot hold the
+	// output, so the operation was refused before writing it.
+	ErrInsufficientSpace = errors.New("not enough free disk space")
+
+	// ErrRSCodecMismatch means the Reed-Solomon codecs in use do not have
+	// the geometry volumes are written with, so decoding would misread them.
+	ErrRSCodecMismatch = errors.New("Reed-Solomon codecs do not match the volume format")
+
+	// ErrTruncatedVolume means the file ends before the complete header, or
+	// inside a Reed-Solomon chunk of the payload, for example an unfinished
+	// download or copy.
+	ErrTruncatedVolume = errors.New("volume is truncated")
+
+	// ErrDeniableNotAcknowledged means a volume looks deniable but the caller
+	// asked for strict handling without requesting the deniability pass.
+	ErrDeniableNotAcknowledged = errors.New("volume appears deniable but deniability was not requested")
+
+	// ErrPepperRequired means the volume was created with a pepper and none
+	// was supplied; the pepper is never stored in the volume.
+	ErrPepperRequired = errors.New("volume requires a pepper that was not supplied")
+
+	// ErrContentMismatch means the decrypted plaintext passed the MAC but
+	// does not have the size or SHA-256 the caller expected, e.g. the right
+	// password was used on the wrong volume.
+	ErrContentMismatch = errors.New("decrypted content does not match the expected size or hash")
+
+	// ErrNoBlockHashes means block verification was requested for a volume
+	// created without block hashes.
+	ErrNoBlockHashes = errors.New("volume has no block hashes")
+
+	// ErrNoPreview means a preview was requested from a volume created
+	// without one.
+	ErrNoPreview = errors.New("volume has no preview")
+
+	// ErrNoRecoveryKey means a recovery key was supplied for a volume
+	// created without a recovery recipient.
+	ErrNoRecoveryKey = errors.New("volume has no recovery key")
+
+	// ErrDerivationTooSlow is advisory: Paranoid key derivation is expected to
+	// take longer than the caller's MaxDerivationTime on this machine.
+	ErrDerivationTooSlow = errors.New("key derivation would be too slow")
+
+	// ErrRekeyUnsupported means a volume has sections or a layout that
+	// re-keying cannot carry over, such as a preview or a recovery key.
+	ErrRekeyUnsupported = errors.New("volume cannot be re-keyed")
 
 	// Crypto errors
 	ErrRandFailure   = errors.New("crypto/rand failure")
@@ -14,15 +14,40 @@ func TestSentinelErrors(t *testing.T) {
 		{"ErrAuthFailed", ErrAuthFailed},
 		{"ErrCorruptHeader", ErrCorruptHeader},
 		{"ErrCorruptData", ErrCorruptData},
+		{"ErrPostWriteVerifyFailed", ErrPostWriteVerifyFailed},
 		{"ErrNoInputFiles", ErrNoInputFiles},
 		{"ErrNoCredentials", ErrNoCredentials},
 		{"ErrPasswordMismatch", ErrPasswordMismatch},
 		{"ErrInvalidChunkSize", ErrInvalidChunkSize},
 		{"ErrDuplicateKeyfiles", ErrDuplicateKeyfiles},
+		{"ErrWeakPassword", ErrWeakPassword},
 		{"ErrFileNotFound", ErrFileNotFound},
 		{"ErrFileExists", ErrFileExists},
 		{"ErrInvalidFormat", ErrInvalidFormat},
 		{"ErrVersionMismatch", ErrVersionMismatch},
+		{"ErrNotLegacyVolume", ErrNotLegacyVolume},
+		{"ErrNotRegularFile", ErrNotRegularFile},
+		{"ErrSameInputOutput", ErrSameInputOutput},
+		{"ErrOutputDirNotWritable", ErrOutputDirNotWritable},
+		{"ErrFileSystemUnsupported", ErrFileSystemUnsupported},
+		{"ErrOutputDirMissing", ErrOutputDirMissing},
+		{"ErrKeyfileInInput", ErrKeyfileInInput},
+		{"ErrInputChangedDuringRead", ErrInputChangedDuringRead},
+		{"ErrRNGUnhealthy", ErrRNGUnhealthy},
+		{"ErrInsufficientSpace", ErrInsufficientSpace},
+		{"ErrDerivationTooSlow", ErrDerivationTooSlow},
+		{"ErrChunkSize", ErrChunkSize},
+		{"ErrChunkCount", ErrChunkCount},
+		{"ErrDeleteFailed", ErrDeleteFailed},
+		{"ErrNotDurable", ErrNotDurable},
+		{"ErrDeniableNotAcknowledged", ErrDeniableNotAcknowledged},
+		{"ErrPepperRequired", ErrPepperRequired},
+		{"ErrTooManyKeyfiles", ErrTooManyKeyfiles},
+		{"ErrContentMismatch", ErrContentMismatch},
+		{"ErrNoBlockHashes", ErrNoBlockHashes},
+		{"ErrNoRecoveryKey", ErrNoRecoveryKey},
+		{"ErrTruncatedVolume", ErrTruncatedVolume},
+		{"ErrRSCodecMismatch", ErrRSCodecMismatch},
 		{"ErrRandFailure", ErrRandFailure},
 		{"ErrKeyDerivation", ErrKeyDerivation},
 		{"ErrHKDFFailure", ErrHKDFFailure},
@@ -0,0 +1,133 @@
+package fileops
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// Compressor returns a writer compressing an archive entry into w, as for
+// archive/zip. Closing it must flush everything but leave w open.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// Decompressor returns a reader decompressing an archive entry from r, as
+// for archive/zip.
+type Decompressor func(r io.Reader) io.ReadCloser
+
+// Compression methods registered beyond the built-in zip.Store and
+// zip.Deflate, which archive/zip always provides. The method ID of each
+// entry is stored in its zip header.
+var compression = struct {
+	sync.RWMutex
+	compressors   map[uint16]Compressor
+	decompressors map[uint16]Decompressor
+}{
+	compressors:   make(map[uint16]Compressor),
+	decompressors: make(map[uint16]Decompressor),
+}
+
+// builtinMethod reports whether archive/zip handles method itself.
+func builtinMethod(method uint16) bool {
+	return method == zip.Store || method == zip.Deflate
+}
+
+// RegisterCompressor makes c available to CreateZip as ZipOptions.Method.
+// Each method can be registered once; the built-in Store and Deflate
+// cannot be replaced.
+func RegisterCompressor(method uint16, c Compressor) error {
+	if c == nil {
+		return errors.New("register compressor: nil Compressor")
+	}
+	compression.Lock()
+	defer compression.Unlock()
+	if _, ok := compression.compressors[method]; ok || builtinMethod(method) {
+		return fmt.Errorf("register compressor: method %d is already registered", method)
+	}
+	compression.compressors[method] = c
+	return nil
+}
+
+// RegisterDecompressor makes d available to Unpack and OpenEntry for
+// entries stored with method. Each method can be registered once; the
+// built-in Store and Deflate cannot be replaced.
+func RegisterDecompressor(method uint16, d Decompressor) error {
+	if d == nil {
+		return errors.New("register decompressor: nil Decompressor")
+	}
+	compression.Lock()
+	defer compression.Unlock()
+	if _, ok := compression.decompressors[method]; ok || builtinMethod(method) {
+		return fmt.Errorf("register decompressor: method %d is already registered", method)
+	}
+	compression.decompressors[method] = d
+	return nil
+}
+
+// HasCompressor reports whether CreateZip can write entries with method.
+func HasCompressor(method uint16) bool {
+	compression.RLock()
+	defer compression.RUnlock()
+	_, ok := compression.compressors[method]
+	return ok || builtinMethod(method)
+}
+
+// useCompressor registers the compressor for method on w, failing with
+// ErrUnknownCompression if there is none.
+func useCompressor(w *zip.Writer, method uint16) error {
+	if builtinMethod(method) {
+		return nil
+	}
+	compression.RLock()
+	c, ok := compression.compressors[method]
+	compression.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w %d: no compressor registered", perrors.ErrUnknownCompression, method)
+	}
+	w.RegisterCompressor(method, zip.Compressor(c))
+	return nil
+}
+
+// useDecompressors registers every registered decompressor on r.
+func useDecompressors(r *zip.Reader) {
+	compression.RLock()
+	defer compression.RUnlock()
+	for method, d := range compression.decompressors {
+		r.RegisterDecompressor(method, zip.Decompressor(d))
+	}
+}
+
+// OpenZip opens the zip archive at path with every registered decompressor
+// available to its entries.
+func OpenZip(path string) (*zip.ReadCloser, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	useDecompressors(&reader.Reader)
+	return reader, nil
+}
+
+// NewZipReader is OpenZip for an archive read through r, such as the
+// encrypted temporary file of ExtractFile.
+func NewZipReader(r io.ReaderAt, size int64) (*zip.Reader, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	useDecompressors(reader)
+	return reader, nil
+}
+
+// OpenEntry opens an entry of an archive from OpenZip. An entry whose
+// method has no decompressor fails with ErrUnknownCompression.
+func OpenEntry(f *zip.File) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if errors.Is(err, zip.ErrAlgorithm) {
+		return nil, fmt.Errorf("%w %d: no decompressor registered", perrors.ErrUnknownCompression, f.Method)
+	}
+	return rc, err
+}
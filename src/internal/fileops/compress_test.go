@@ -0,0 +1,61 @@
+package fileops
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// TestCompressionRegistry tests that methods register once, that the
+// built-ins cannot be replaced and that an unregistered method is refused
+func TestCompressionRegistry(t *testing.T) {
+	method := uint16(0xC0DE)
+	identity := func(w io.Writer) (io.WriteCloser, error) { return nopWriteCloser{w}, nil }
+
+	for _, builtin := range []uint16{zip.Store, zip.Deflate} {
+		if err := RegisterCompressor(builtin, identity); err == nil {
+			t.Errorf("RegisterCompressor replaced built-in method %d", builtin)
+		}
+		if err := RegisterDecompressor(builtin, io.NopCloser); err == nil {
+			t.Errorf("RegisterDecompressor replaced built-in method %d", builtin)
+		}
+	}
+
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "data.txt")
+	if err := os.WriteFile(input, []byte("registry"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := CreateZip(ZipOptions{
+		Files:      []string{input},
+		RootDir:    tmpDir,
+		OutputPath: filepath.Join(tmpDir, "unknown.zip"),
+		Compress:   true,
+		Method:     &method,
+	})
+	if !errors.Is(err, perrors.ErrUnknownCompression) {
+		t.Errorf("expected ErrUnknownCompression before registration, got %v", err)
+	}
+
+	if HasCompressor(method) {
+		t.Fatalf("method %d registered before the test", method)
+	}
+	if err := RegisterCompressor(method, identity); err != nil {
+		t.Fatalf("RegisterCompressor failed: %v", err)
+	}
+	if !HasCompressor(method) {
+		t.Error("HasCompressor is false after registration")
+	}
+	if err := RegisterCompressor(method, identity); err == nil {
+		t.Error("RegisterCompressor accepted a method twice")
+	}
+}
@@ -0,0 +1,90 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime/debug"
+)
+
+// ErrMmapUnsupported means output cannot be memory-mapped here, either on
+// this platform or for this size; callers write to the file instead.
+var ErrMmapUnsupported = errors.New("memory-mapped output is not supported")
+
+// MmapWriter writes sequentially into a shared memory mapping of a file
+// instead of issuing a write call per buffer. The file is grown to the
+// mapped size up front and cut back to what was written by Close.
+type MmapWriter struct {
+	f    *os.File
+	data []byte
+	pos  int
+}
+
+// NewMmapWriter grows f to size bytes and maps it for writing. When mapping
+// fails, f is truncated back to empty and the error wraps
+// ErrMmapUnsupported if the platform or size is the cause.
+func NewMmapWriter(f *os.File, size int64) (*MmapWriter, error) {
+	if size <= 0 || size > math.MaxInt {
+		return nil, fmt.Errorf("%w: cannot map %d bytes", ErrMmapUnsupported, size)
+	}
+	if err := f.Truncate(size); err != nil {
+		return nil, fmt.Errorf("grow output for mapping: %w", err)
+	}
+	data, err := mmapFile(f, int(size))
+	if err != nil {
+		_ = f.Truncate(0)
+		return nil, err
+	}
+	return &MmapWriter{f: f, data: data}, nil
+}
+
+// Write copies p into the mapping after what was written before. Writing
+// past the mapped size fails with io.ErrShortWrite. A page the system
+// cannot back, as when the disk fills up, is reported as an error instead
+// of crashing the process with SIGBUS.
+func (w *MmapWriter) Write(p []byte) (n int, err error) {
+	if w.data == nil {
+		return 0, os.ErrClosed
+	}
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			n, err = 0, fmt.Errorf("write mapped output: %v", r)
+		}
+	}()
+	n = copy(w.data[w.pos:], p)
+	w.pos += n
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// Written returns the number of bytes written so far.
+func (w *MmapWriter) Written() int64 {
+	return int64(w.pos)
+}
+
+// Close flushes the mapping to the file with msync, unmaps it and truncates
+// the file to the bytes written. It does not close the file, and calling it
+// again does nothing.
+func (w *MmapWriter) Close() error {
+	if w.data == nil {
+		return nil
+	}
+	data := w.data
+	w.data = nil
+	syncErr := msyncFile(data)
+	if err := munmapFile(data); err != nil && syncErr == nil {
+		syncErr = err
+	}
+	if syncErr != nil {
+		return fmt.Errorf("flush mapped output: %w", syncErr)
+	}
+	if err := w.f.Truncate(int64(w.pos)); err != nil {
+		return fmt.Errorf("trim mapped output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package fileops
+
+import "os"
+
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func msyncFile(data []byte) error {
+	return ErrMmapUnsupported
+}
+
+func munmapFile(data []byte) error {
+	return ErrMmapUnsupported
+}
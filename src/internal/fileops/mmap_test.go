@@ -0,0 +1,141 @@
+package fileops
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newMmapWriter maps a new file of size bytes, skipping where mapping is
+// unsupported
+func newMmapWriter(tb testing.TB, path string, size int64) (*os.File, *MmapWriter) {
+	tb.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = f.Close() })
+	w, err := NewMmapWriter(f, size)
+	if errors.Is(err, ErrMmapUnsupported) {
+		tb.Skip("memory-mapped output is not supported here")
+	}
+	if err != nil {
+		tb.Fatalf("NewMmapWriter failed: %v", err)
+	}
+	return f, w
+}
+
+func TestMmapWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapped")
+	f, w := newMmapWriter(t, path, 1<<20)
+
+	want := bytes.Repeat([]byte("mapped output "), 50000)
+	for rest := want; len(rest) > 0; {
+		n := min(len(rest), 65536)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if w.Written() != int64(len(want)) {
+		t.Errorf("Written = %d; want %d", w.Written(), len(want))
+	}
+
+	// The mapping ends at its size
+	if n, err := w.Write(make([]byte, 1<<20)); !errors.Is(err, io.ErrShortWrite) || n != 1<<20-len(want) {
+		t.Errorf("Write past the end = %d, %v; want %d, ErrShortWrite", n, err, 1<<20-len(want))
+	}
+	want = append(want, make([]byte, 1<<20-len(want))...)
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+	_ = f.Close()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("file holds %d bytes that differ from the %d written", len(got), len(want))
+	}
+}
+
+func TestMmapWriterTrims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapped")
+	_, w := newMmapWriter(t, path, 4096)
+	if _, err := w.Write([]byte("short")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "short" {
+		t.Errorf("file = %q; want it trimmed to %q", got, "short")
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "empty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := NewMmapWriter(f, 0); !errors.Is(err, ErrMmapUnsupported) {
+		t.Errorf("NewMmapWriter(0) = %v; want ErrMmapUnsupported", err)
+	}
+}
+
+// BenchmarkOutputWrites compares writing a large output through a mapping
+// with buffered writes to the file, both synced at the end.
+func BenchmarkOutputWrites(b *testing.B) {
+	const size = 256 << 20
+	block := bytes.Repeat([]byte{0xA5}, 1<<20)
+	if testing.Short() {
+		b.Skip("writes 256 MiB per iteration")
+	}
+
+	b.Run("mmap", func(b *testing.B) {
+		b.SetBytes(size)
+		path := filepath.Join(b.TempDir(), "out")
+		for b.Loop() {
+			f, w := newMmapWriter(b, path, size)
+			for range size / len(block) {
+				if _, err := w.Write(block); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				b.Fatal(err)
+			}
+			_ = f.Close()
+		}
+	})
+
+	b.Run("write", func(b *testing.B) {
+		b.SetBytes(size)
+		path := filepath.Join(b.TempDir(), "out")
+		for b.Loop() {
+			f, err := os.Create(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for range size / len(block) {
+				if _, err := f.Write(block); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := f.Sync(); err != nil {
+				b.Fatal(err)
+			}
+			_ = f.Close()
+		}
+	})
+}
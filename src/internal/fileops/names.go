@@ -0,0 +1,143 @@
+package fileops
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	perrors "Picocrypt-NG/internal/errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// NameManifestEntry is the archive entry holding the real entry names when
+// ZipOptions.NameKey is set. It is always the last entry.
+const NameManifestEntry = ".picocrypt-names"
+
+// NameSaltSize is the length of ZipOptions.NameSalt.
+const NameSaltSize = 16
+
+// nameManifestMagic starts the manifest data, so a user file that happens to
+// share the entry name is not mistaken for a manifest.
+var nameManifestMagic = []byte("PCNAMES1")
+
+// NameKeyFunc returns the 32-byte key used to seal the name manifest, given
+// the salt stored in it. The caller zeroes the key after use.
+type NameKeyFunc func(salt []byte) ([]byte, error)
+
+// entryNames hands out opaque archive names and records the real ones. A nil
+// *entryNames leaves names unchanged.
+type entryNames struct {
+	real map[string]string // Opaque name -> real name
+}
+
+// assign returns the name to store for the entry with real name name.
+// Directory entries keep their trailing slash so they are still created as
+// directories by any unzip tool.
+func (n *entryNames) assign(name string) string {
+	if n == nil {
+		return name
+	}
+	opaque := fmt.Sprintf("%08x", len(n.real))
+	if strings.HasSuffix(name, "/") {
+		opaque += "/"
+	}
+	n.real[opaque] = name
+	return opaque
+}
+
+// writeManifest seals the recorded names with XChaCha20-Poly1305 and adds
+// them as the NameManifestEntry entry. The salt is stored in the clear and
+// authenticated, so the key can be derived again on extraction.
+func (n *entryNames) writeManifest(writer *zip.Writer, key, salt []byte) error {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return fmt.Errorf("name manifest: %w", err)
+	}
+	if len(salt) != NameSaltSize {
+		return fmt.Errorf("name manifest: salt must be %d bytes", NameSaltSize)
+	}
+	plain, err := json.Marshal(n.real)
+	if err != nil {
+		return fmt.Errorf("name manifest: %w", err)
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return perrors.ErrRandFailure
+	}
+
+	data := append(bytes.Clone(nameManifestMagic), salt...)
+	data = append(data, nonce...)
+	data = aead.Seal(data, nonce, plain, data)
+
+	entry, err := writer.CreateHeader(&zip.FileHeader{Name: NameManifestEntry, Method: zip.Store})
+	if err != nil {
+		return fmt.Errorf("create name manifest: %w", err)
+	}
+	if _, err := entry.Write(data); err != nil {
+		return fmt.Errorf("write name manifest: %w", err)
+	}
+	return nil
+}
+
+// RealNames reads the name manifest of an archive created with
+// ZipOptions.NameKey and maps each entry to its real name. The manifest
+// entry itself is mapped to "". Archives without a manifest return nil and
+// no error. A manifest that cannot be opened with the derived key fails
+// with ErrAuthFailed.
+func RealNames(files []*zip.File, keyFn NameKeyFunc) (map[*zip.File]string, error) {
+	if len(files) == 0 || files[len(files)-1].Name != NameManifestEntry {
+		return nil, nil
+	}
+	manifest := files[len(files)-1]
+
+	rc, err := manifest.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open name manifest: %w", err)
+	}
+	data, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read name manifest: %w", err)
+	}
+
+	headerSize := len(nameManifestMagic) + NameSaltSize + chacha20poly1305.NonceSizeX
+	if len(data) < headerSize || !bytes.HasPrefix(data, nameManifestMagic) {
+		return nil, nil // An ordinary file that happens to share the name
+	}
+	salt := data[len(nameManifestMagic) : len(nameManifestMagic)+NameSaltSize]
+	nonce := data[len(nameManifestMagic)+NameSaltSize : headerSize]
+
+	key, err := keyFn(salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	clear(key)
+	if err != nil {
+		return nil, fmt.Errorf("name manifest: %w", err)
+	}
+	plain, err := aead.Open(nil, nonce, data[headerSize:], data[:headerSize])
+	if err != nil {
+		return nil, fmt.Errorf("%w: entry names could not be decrypted", perrors.ErrAuthFailed)
+	}
+
+	real := map[string]string{}
+	if err := json.Unmarshal(plain, &real); err != nil {
+		return nil, fmt.Errorf("parse name manifest: %w", err)
+	}
+	names := make(map[*zip.File]string, len(files))
+	for _, f := range files[:len(files)-1] {
+		name, ok := real[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: entry %s is not in the name manifest", perrors.ErrAuthFailed, f.Name)
+		}
+		names[f] = name
+	}
+	names[manifest] = ""
+	return names, nil
+}
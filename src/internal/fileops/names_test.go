@@ -0,0 +1,138 @@
+package fileops
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+func TestCreateZipNameKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	rootDir := filepath.Join(tmpDir, "input")
+	files := map[string]string{
+		"tax return 2024.pdf":      "private",
+		"medical/scan results.png": "also private",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(rootDir, "secret", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	salt := bytes.Repeat([]byte{0x07}, NameSaltSize)
+	var gotSalt []byte
+	keyFn := func(s []byte) ([]byte, error) {
+		gotSalt = bytes.Clone(s)
+		return bytes.Clone(key), nil
+	}
+
+	zipPath := filepath.Join(tmpDir, "out.zip")
+	err := CreateZip(ZipOptions{
+		Files:      paths,
+		RootDir:    rootDir,
+		OutputPath: zipPath,
+		Compress:   true,
+		DirEntries: true,
+		NameKey:    key,
+		NameSalt:   salt,
+	})
+	if err != nil {
+		t.Fatalf("CreateZip failed: %v", err)
+	}
+
+	// The intermediate zip only has opaque names, and no real name appears anywhere in it
+	raw, err := os.ReadFile(zipPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	for _, leak := range []string{"secret", "tax return", "medical", "scan results"} {
+		if bytes.Contains(raw, []byte(leak)) {
+			t.Errorf("intermediate zip contains %q", leak)
+		}
+	}
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	for i, f := range reader.File {
+		if i == len(reader.File)-1 {
+			if f.Name != NameManifestEntry {
+				t.Errorf("last entry = %q; want the name manifest", f.Name)
+			}
+			continue
+		}
+		if len(strings.TrimSuffix(f.Name, "/")) != 8 {
+			t.Errorf("entry name %q is not opaque", f.Name)
+		}
+	}
+	_ = reader.Close()
+
+	// Unpacking with the key restores the real layout and skips the manifest
+	extractDir := filepath.Join(tmpDir, "extracted")
+	if err := Unpack(UnpackOptions{ZipPath: zipPath, ExtractDir: extractDir, NameKey: keyFn}); err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if !bytes.Equal(gotSalt, salt) {
+		t.Errorf("key function got salt %x; want %x", gotSalt, salt)
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(extractDir, "secret", filepath.FromSlash(name)))
+		if err != nil || string(got) != content {
+			t.Errorf("%s: got %q, %v; want %q", name, got, err, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, NameManifestEntry)); !os.IsNotExist(err) {
+		t.Error("name manifest was extracted")
+	}
+
+	// A wrong key fails before anything is extracted
+	wrongFn := func([]byte) ([]byte, error) { return make([]byte, 32), nil }
+	wrongDir := filepath.Join(tmpDir, "wrong")
+	err = Unpack(UnpackOptions{ZipPath: zipPath, ExtractDir: wrongDir, NameKey: wrongFn})
+	if !errors.Is(err, perrors.ErrAuthFailed) {
+		t.Errorf("Unpack with wrong key: got %v; want ErrAuthFailed", err)
+	}
+	if _, err := os.Stat(wrongDir); !os.IsNotExist(err) {
+		t.Error("wrong key created the extraction directory")
+	}
+}
+
+func TestRealNamesWithoutManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, NameManifestEntry)
+	if err := os.WriteFile(path, []byte("just a user file"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	zipPath := filepath.Join(tmpDir, "plain.zip")
+	if err := CreateZip(ZipOptions{Files: []string{path}, RootDir: tmpDir, OutputPath: zipPath}); err != nil {
+		t.Fatalf("CreateZip failed: %v", err)
+	}
+
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	called := false
+	names, err := RealNames(reader.File, func([]byte) ([]byte, error) {
+		called = true
+		return make([]byte, 32), nil
+	})
+	if err != nil || names != nil || called {
+		t.Errorf("RealNames on an ordinary zip = %v, %v (key derived: %v)", names, err, called)
+	}
+}
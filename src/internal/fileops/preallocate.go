@@ -0,0 +1,20 @@
+package fileops
+
+// Truncater is a file that can be resized.
+type Truncater interface {
+	Truncate(size int64) error
+}
+
+// Preallocate grows f to size bytes, reserving the disk blocks up front
+// where the platform allows it so the file is less fragmented and cannot
+// run out of space later. That needs an *os.File (or another file with an
+// Fd method) on Linux or macOS; otherwise, or when the filesystem does not
+// support it, f is only truncated to size, which leaves a sparse file.
+func Preallocate(f Truncater, size int64) error {
+	if fd, ok := f.(interface{ Fd() uintptr }); ok {
+		// Best effort: the reservation may fail or not set the size itself,
+		// and the truncate below covers both
+		_ = reserveBlocks(fd.Fd(), size)
+	}
+	return f.Truncate(size)
+}
@@ -0,0 +1,16 @@
+//go:build darwin
+
+package fileops
+
+import "golang.org/x/sys/unix"
+
+// reserveBlocks allocates size bytes past the physical end of fd, trying
+// for one contiguous extent first.
+func reserveBlocks(fd uintptr, size int64) error {
+	store := unix.Fstore_t{Flags: unix.F_ALLOCATECONTIG | unix.F_ALLOCATEALL, Posmode: unix.F_PEOFPOSMODE, Length: size}
+	if err := unix.FcntlFstore(fd, unix.F_PREALLOCATE, &store); err == nil {
+		return nil
+	}
+	store.Flags = unix.F_ALLOCATEALL
+	return unix.FcntlFstore(fd, unix.F_PREALLOCATE, &store)
+}
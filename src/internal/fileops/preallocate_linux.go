@@ -0,0 +1,10 @@
+//go:build linux
+
+package fileops
+
+import "golang.org/x/sys/unix"
+
+// reserveBlocks allocates the first size bytes of fd.
+func reserveBlocks(fd uintptr, size int64) error {
+	return unix.Fallocate(int(fd), 0, 0, size)
+}
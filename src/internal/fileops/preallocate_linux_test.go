@@ -0,0 +1,38 @@
+//go:build linux
+
+package fileops
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestPreallocateReservesBlocks tests that the space is allocated rather
+// than left sparse
+func TestPreallocateReservesBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	const size = 4 << 20
+	if err := reserveBlocks(f.Fd(), size); errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		t.Skip("the filesystem cannot reserve blocks")
+	}
+	if err := Preallocate(f, size); err != nil {
+		t.Fatalf("Preallocate failed: %v", err)
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		t.Fatal(err)
+	}
+	if allocated := st.Blocks * 512; allocated < size {
+		t.Errorf("%d bytes allocated; want at least %d", allocated, size)
+	}
+}
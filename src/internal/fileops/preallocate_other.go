@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package fileops
+
+import "errors"
+
+func reserveBlocks(fd uintptr, size int64) error {
+	return errors.ErrUnsupported
+}
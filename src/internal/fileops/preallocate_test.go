@@ -0,0 +1,43 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// truncateOnly is a file without an Fd, as a custom FileSystem may return.
+type truncateOnly struct{ size int64 }
+
+func (f *truncateOnly) Truncate(size int64) error {
+	f.size = size
+	return nil
+}
+
+func TestPreallocate(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("header")); err != nil {
+		t.Fatal(err)
+	}
+
+	const size = 4 << 20
+	if err := Preallocate(f, size); err != nil {
+		t.Fatalf("Preallocate failed: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != size {
+		t.Errorf("size = %d; want %d", info.Size(), size)
+	}
+
+	var other truncateOnly
+	if err := Preallocate(&other, size); err != nil || other.size != size {
+		t.Errorf("Preallocate without Fd: size %d, err %v; want %d", other.size, err, size)
+	}
+}
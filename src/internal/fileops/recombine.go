@@ -7,6 +7,7 @@ import (
 	"os"
 	"time"
 
+	perrors "Picocrypt-NG/internal/errors"
 	"Picocrypt-NG/internal/util"
 )
 
@@ -17,9 +18,75 @@ type RecombineOptions struct {
 	Progress   ProgressFunc
 	Status     StatusFunc
 	Cancel     CancelFunc
+
+	// VerifySizes checks every chunk's size with CheckChunkSizes before
+	// anything is written, so a damaged chunk is named instead of surfacing
+	// as a MAC failure after decryption.
+	VerifySizes bool
+}
+
+// ChunkSizeError reports the split chunk whose size doesn't fit the others.
+// It matches errors.ErrChunkSize.
+type ChunkSizeError struct {
+	Index int   // Chunk number (the N in basePath.N)
+	Size  int64 // Actual size in bytes
+	Want  int64 // Expected size, or the maximum for the last chunk
+}
+
+func (e *ChunkSizeError) Error() string {
+	return fmt.Sprintf("chunk %d is %d bytes, expected %d", e.Index, e.Size, e.Want)
+}
+
+func (e *ChunkSizeError) Unwrap() error {
+	return perrors.ErrChunkSize
+}
+
+// ChunkCountError reports split chunks that do not number what the volume's
+// recorded layout calls for. It matches errors.ErrChunkCount.
+type ChunkCountError struct {
+	Found int // Consecutive chunks basePath.0, basePath.1, ... on disk
+	Want  int // Chunks the layout records
+}
+
+func (e *ChunkCountError) Error() string {
+	return fmt.Sprintf("found %d chunks, the volume was split into %d", e.Found, e.Want)
+}
+
+func (e *ChunkCountError) Unwrap() error {
+	return perrors.ErrChunkCount
+}
+
+// CheckChunkLayout checks the chunks of basePath against a recorded split
+// layout: exactly chunks files, each chunkSize bytes except the last, which
+// holds the rest of volumeSize. Unlike CheckChunkSizes it needs no
+// majority, so a stray extra chunk or a missing last one is caught too.
+func CheckChunkLayout(basePath string, chunkSize, volumeSize int64, chunks int) error {
+	var sizes []int64
+	for {
+		stat, err := os.Stat(fmt.Sprintf("%s.%d", basePath, len(sizes)))
+		if err != nil {
+			break
+		}
+		sizes = append(sizes, stat.Size())
+	}
+	if len(sizes) != chunks {
+		return &ChunkCountError{Found: len(sizes), Want: chunks}
+	}
+	for i, size := range sizes {
+		want := chunkSize
+		if i == chunks-1 {
+			want = volumeSize - chunkSize*int64(chunks-1)
+		}
+		if size != want {
+			return &ChunkSizeError{Index: i, Size: size, Want: want}
+		}
+	}
+	return nil
 }
 
-// CountChunks returns the number of split chunks for a given base path
+// CountChunks returns the number of split chunks for a given base path.
+// Only finished chunks (basePath.0, basePath.1, ...) are counted; .incomplete
+// files left by an interrupted split are ignored.
 func CountChunks(basePath string) (int, int64, error) {
 	count := 0
 	var totalSize int64
@@ -40,6 +107,62 @@ func CountChunks(basePath string) (int, int64, error) {
 	return count, totalSize, nil
 }
 
+// CheckChunkSizes checks the sizes of the chunks of basePath against each
+// other. Split writes every chunk at the same size except the last, which
+// may be shorter but never empty or longer. The expected size is the most
+// common size among the non-last chunks, the larger one on a tie since
+// damage in transit usually truncates, and never less than the last chunk.
+// That way a single damaged chunk is reported by its own index, not by the
+// intact neighbour it disagrees with. Only sizes are compared; a chunk with
+// flipped bits but the right length still passes.
+func CheckChunkSizes(basePath string) error {
+	var sizes []int64
+	for {
+		stat, err := os.Stat(fmt.Sprintf("%s.%d", basePath, len(sizes)))
+		if err != nil {
+			break
+		}
+		sizes = append(sizes, stat.Size())
+	}
+	if len(sizes) == 0 {
+		return errors.New("no chunks found")
+	}
+
+	last := len(sizes) - 1
+	if last == 0 {
+		if sizes[0] == 0 {
+			return &ChunkSizeError{Index: 0, Size: 0, Want: 1}
+		}
+		return nil
+	}
+
+	counts := make(map[int64]int)
+	for _, size := range sizes[:last] {
+		counts[size]++
+	}
+	want := sizes[0]
+	for _, size := range sizes[:last] {
+		if counts[size] > counts[want] || (counts[size] == counts[want] && size > want) {
+			want = size
+		}
+	}
+	// Without a majority to say otherwise, a last chunk longer than the
+	// others means they were cut short, not that it grew
+	if counts[want] == 1 && sizes[last] > want {
+		want = sizes[last]
+	}
+
+	for i, size := range sizes[:last] {
+		if size != want {
+			return &ChunkSizeError{Index: i, Size: size, Want: want}
+		}
+	}
+	if sizes[last] == 0 || sizes[last] > want {
+		return &ChunkSizeError{Index: last, Size: sizes[last], Want: want}
+	}
+	return nil
+}
+
 // Recombine merges split chunks back into a single file.
 // Chunks are expected to be named: basePath.0, basePath.1, etc.
 func Recombine(opts RecombineOptions) error {
@@ -48,6 +171,12 @@ func Recombine(opts RecombineOptions) error {
 		return err
 	}
 
+	if opts.VerifySizes {
+		if err := CheckChunkSizes(opts.InputBase); err != nil {
+			return err
+		}
+	}
+
 	// Check if output already exists
 	if _, err := os.Stat(opts.OutputPath); err == nil {
 		return fmt.Errorf("output file already exists: %s", opts.OutputPath)
@@ -0,0 +1,80 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/log"
+)
+
+// rename is os.Rename; tests replace it to simulate a cross-device move.
+var rename = os.Rename
+
+// Rename moves oldpath to newpath like os.Rename. When the two are on
+// different filesystems, where a rename is impossible, it instead copies the
+// file beside newpath, syncs it, renames the copy into place and removes
+// oldpath, so newpath never holds a partial file. Only regular files can be
+// moved this way.
+func Rename(oldpath, newpath string) error {
+	err := rename(oldpath, newpath)
+	if err == nil || !errors.Is(err, errCrossDevice) {
+		return err
+	}
+
+	log.Info("rename crosses filesystems, copying instead",
+		log.String("from", oldpath), log.String("to", newpath))
+	if err := copySynced(oldpath, newpath); err != nil {
+		return fmt.Errorf("move %s across filesystems: %w", oldpath, err)
+	}
+	if err := os.Remove(oldpath); err != nil {
+		return fmt.Errorf("remove %s after copy: %w", oldpath, err)
+	}
+	return nil
+}
+
+// copySynced copies src to dst through dst+".moving", keeping src's mode.
+func copySynced(src, dst string) error {
+	fin, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fin.Close() }()
+
+	stat, err := fin.Stat()
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("%s: %w", src, perrors.ErrNotRegularFile)
+	}
+
+	tmpPath := dst + ".moving"
+	fout, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fout, fin); err != nil {
+		_ = fout.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := fout.Sync(); err != nil {
+		_ = fout.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := fout.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	// The copy is beside dst, so this rename stays on one filesystem
+	if err := os.Rename(tmpPath, dst); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package fileops
+
+import "syscall"
+
+// errCrossDevice is returned by os.Rename when the target is on another filesystem.
+var errCrossDevice error = syscall.EXDEV
@@ -0,0 +1,74 @@
+package fileops
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// withRenamer replaces the rename used by Rename for the rest of the test.
+func withRenamer(t *testing.T, fn func(oldpath, newpath string) error) {
+	t.Helper()
+	orig := rename
+	rename = fn
+	t.Cleanup(func() { rename = orig })
+}
+
+func TestRenameCrossDevice(t *testing.T) {
+	withRenamer(t, func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errCrossDevice}
+	})
+
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "volume.pcv.incomplete")
+	dst := filepath.Join(tmpDir, "out", "volume.pcv")
+	if err := os.Mkdir(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	content := []byte("payload that must survive the move")
+	if err := os.WriteFile(src, content, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := Rename(src, dst); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil || string(got) != string(content) {
+		t.Fatalf("destination = %q, %v; want %q", got, err, content)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("source should be removed after the copy")
+	}
+	if _, err := os.Stat(dst + ".moving"); !os.IsNotExist(err) {
+		t.Error("temporary copy left behind")
+	}
+	if stat, err := os.Stat(dst); err == nil && runtime.GOOS != "windows" && stat.Mode().Perm() != 0600 {
+		t.Errorf("destination mode = %v; want 0600", stat.Mode().Perm())
+	}
+}
+
+func TestRenameOtherErrors(t *testing.T) {
+	failure := errors.New("permission denied")
+	withRenamer(t, func(oldpath, newpath string) error { return failure })
+
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "a")
+	dst := filepath.Join(tmpDir, "b")
+	if err := os.WriteFile(src, []byte("x"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := Rename(src, dst); !errors.Is(err, failure) {
+		t.Fatalf("Rename error = %v; want %v", err, failure)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Error("destination should not be created for other rename errors")
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Error("source should be kept for other rename errors")
+	}
+}
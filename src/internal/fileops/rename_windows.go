@@ -0,0 +1,8 @@
+//go:build windows
+
+package fileops
+
+import "golang.org/x/sys/windows"
+
+// errCrossDevice is returned by os.Rename when the target is on another volume.
+var errCrossDevice error = windows.ERROR_NOT_SAME_DEVICE
@@ -0,0 +1,179 @@
+package fileops
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/header"
+)
+
+// VolumeKind classifies a volume found by FindVolumes.
+type VolumeKind int
+
+const (
+	// VolumeStandard has a readable Picocrypt header.
+	VolumeStandard VolumeKind = iota
+	// VolumeDeniable has a .pcv name but no readable header, as produced by
+	// the deniability wrapper. Indistinguishable from random data by content.
+	VolumeDeniable
+)
+
+func (k VolumeKind) String() string {
+	switch k {
+	case VolumeStandard:
+		return "standard"
+	case VolumeDeniable:
+		return "deniable"
+	default:
+		return "unknown"
+	}
+}
+
+// VolumeRef is one logical volume found by FindVolumes.
+type VolumeRef struct {
+	Path    string     // Volume path; for a split volume, the base path without .N
+	Chunks  []string   // Chunk paths in order (.0, .1, ...); nil if not split
+	Kind    VolumeKind // Standard or deniable
+	Version string     // Header version, e.g. "v2.02"; empty if deniable
+}
+
+// chunkRe matches a split chunk name and captures the base and index.
+var chunkRe = regexp.MustCompile(`^(.+)\.(\d+)$`)
+
+// versionRe matches a decoded volume version string.
+var versionRe = regexp.MustCompile(`^v\d\.\d{2}$`)
+
+// ListFiles walks root and returns the regular files under it in walk
+// order, following symlinks to files. Anything else that is not a directory,
+// such as a FIFO, socket or device, or a broken or directory symlink, is
+// skipped and reported through warn if it is not nil.
+func ListFiles(root string, warn StatusFunc) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// WalkDir does not follow symlinks, so resolve them before checking
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			if warn != nil {
+				warn(fmt.Sprintf("skipping %s (not a regular file)", path))
+			}
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// FindVolumes walks root and returns every Picocrypt volume under it,
+// sorted by path. Files are recognised by their header, whatever their
+// name; a .pcv file without a readable header is reported as deniable.
+// Split chunks (name.0, name.1, ...) are grouped into one VolumeRef when
+// name.0 exists, classified by that first chunk; chunks after a gap in the
+// numbering are ignored. Other files are skipped.
+func FindVolumes(root string) ([]VolumeRef, error) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	present := make(map[string]bool)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+			present[path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan %s: %w", root, err)
+	}
+
+	var refs []VolumeRef
+	grouped := make(map[string]bool)
+	for _, path := range files {
+		m := chunkRe.FindStringSubmatch(path)
+		if m == nil || m[2] != "0" {
+			continue
+		}
+		base := m[1]
+
+		var chunks []string
+		for i := 0; ; i++ {
+			chunk := base + "." + strconv.Itoa(i)
+			if !present[chunk] {
+				break
+			}
+			chunks = append(chunks, chunk)
+		}
+		// Leave the chunks to the single-file pass if the set is not a
+		// volume, so an ordinary file that happens to end in .0 still counts
+		ref, ok := classifyVolume(chunks[0], base, rsCodecs)
+		if !ok {
+			continue
+		}
+		for _, chunk := range chunks {
+			grouped[chunk] = true
+		}
+		ref.Path = base
+		ref.Chunks = chunks
+		refs = append(refs, ref)
+	}
+
+	for _, path := range files {
+		if grouped[path] {
+			continue
+		}
+		if ref, ok := classifyVolume(path, path, rsCodecs); ok {
+			refs = append(refs, ref)
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Path < refs[j].Path })
+	return refs, nil
+}
+
+// classifyVolume reports whether path starts with a volume header. If it
+// does not, a name ending in .pcv still marks a deniable volume.
+func classifyVolume(path, name string, rs *encoding.RSCodecs) (VolumeRef, bool) {
+	if version, ok := peekVolumeVersion(path, rs); ok {
+		return VolumeRef{Path: path, Kind: VolumeStandard, Version: version}, true
+	}
+	if strings.EqualFold(filepath.Ext(name), ".pcv") {
+		return VolumeRef{Path: path, Kind: VolumeDeniable}, true
+	}
+	return VolumeRef{}, false
+}
+
+// peekVolumeVersion returns the header version of path if it has one.
+func peekVolumeVersion(path string, rs *encoding.RSCodecs) (string, bool) {
+	fin, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer func() { _ = fin.Close() }()
+
+	version, err := header.PeekVersion(fin, rs)
+	if err != nil || !versionRe.MatchString(version) {
+		return "", false
+	}
+	return version, true
+}
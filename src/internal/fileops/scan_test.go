@@ -0,0 +1,105 @@
+package fileops
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// TestFindVolumes tests classification and split grouping over a mixed tree.
+func TestFindVolumes(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	// A file is a volume by content: an RS-encoded version, then anything
+	volumeData := append(encoding.Encode(rsCodecs.RS5, []byte("v2.02")), bytes.Repeat([]byte{0xAB}, 64)...)
+	randomData := bytes.Repeat([]byte{0x5A, 0xC3, 0x11}, 40)
+
+	root := t.TempDir()
+	files := map[string][]byte{
+		"single.pcv":             volumeData,
+		"nested/renamed.bin":     volumeData, // detected without the extension
+		"split/big.pcv.0":        volumeData,
+		"split/big.pcv.1":        randomData,
+		"split/big.pcv.2":        randomData,
+		"split/big.pcv.3":        randomData,
+		"split/big.pcv.5":        randomData, // after a gap, not part of the set
+		"hidden.pcv":             randomData, // deniable
+		"notes.txt":              []byte("not a volume"),
+		"logs/app.log.0":         []byte("rotated log"),
+		"logs/app.log.1":         []byte("rotated log"),
+		"nested/deeper/data.dat": randomData,
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	refs, err := FindVolumes(root)
+	if err != nil {
+		t.Fatalf("FindVolumes failed: %v", err)
+	}
+
+	p := func(name string) string { return filepath.Join(root, filepath.FromSlash(name)) }
+	want := []VolumeRef{
+		{Path: p("hidden.pcv"), Kind: VolumeDeniable},
+		{Path: p("nested/renamed.bin"), Kind: VolumeStandard, Version: "v2.02"},
+		{Path: p("single.pcv"), Kind: VolumeStandard, Version: "v2.02"},
+		{Path: p("split/big.pcv"), Kind: VolumeStandard, Version: "v2.02", Chunks: []string{
+			p("split/big.pcv.0"), p("split/big.pcv.1"), p("split/big.pcv.2"), p("split/big.pcv.3"),
+		}},
+	}
+
+	if len(refs) != len(want) {
+		t.Fatalf("Found %d volumes, want %d: %+v", len(refs), len(want), refs)
+	}
+	for i, w := range want {
+		got := refs[i]
+		if got.Path != w.Path || got.Kind != w.Kind || got.Version != w.Version {
+			t.Errorf("Volume %d = {%s %s %q}, want {%s %s %q}",
+				i, got.Path, got.Kind, got.Version, w.Path, w.Kind, w.Version)
+		}
+		if len(got.Chunks) != len(w.Chunks) {
+			t.Errorf("%s: %d chunks, want %d", w.Path, len(got.Chunks), len(w.Chunks))
+			continue
+		}
+		for j := range w.Chunks {
+			if got.Chunks[j] != w.Chunks[j] {
+				t.Errorf("%s chunk %d = %s, want %s", w.Path, j, got.Chunks[j], w.Chunks[j])
+			}
+		}
+	}
+}
+
+// TestFindVolumesDeniableSplit tests that a split set with a .pcv base and
+// no readable header is grouped as one deniable volume.
+func TestFindVolumesDeniableSplit(t *testing.T) {
+	root := t.TempDir()
+	for i := range 3 {
+		path := filepath.Join(root, "secret.pcv."+string(rune('0'+i)))
+		if err := os.WriteFile(path, bytes.Repeat([]byte{byte(i + 1)}, 32), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	refs, err := FindVolumes(root)
+	if err != nil {
+		t.Fatalf("FindVolumes failed: %v", err)
+	}
+	if len(refs) != 1 {
+		t.Fatalf("Found %d volumes, want 1: %+v", len(refs), refs)
+	}
+	if refs[0].Kind != VolumeDeniable || len(refs[0].Chunks) != 3 {
+		t.Errorf("Got %+v, want one deniable volume with 3 chunks", refs[0])
+	}
+}
@@ -12,8 +12,11 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
+	perrors "Picocrypt-NG/internal/errors"
 	"Picocrypt-NG/internal/util"
 )
 
@@ -41,7 +44,11 @@ type SplitOptions struct {
 // Split divides a file into multiple sequential chunks for easier storage/transfer.
 //
 // Output files are named with numeric suffixes: inputPath.0, inputPath.1, inputPath.2, etc.
-// Existing chunks with matching names are deleted before splitting begins.
+// Existing chunks with matching names, and .incomplete chunks left behind by an
+// interrupted split, are deleted before splitting begins. Other files sharing
+// the prefix (such as a sidecar) are kept. If the split fails or opts.Cancel
+// reports cancellation, every chunk it wrote is removed again; cancellation
+// returns ErrCancelled.
 //
 // Use cases:
 //   - Storing large encrypted volumes on FAT32 (4 GiB file size limit)
@@ -55,24 +62,7 @@ func Split(opts SplitOptions) ([]string, error) {
 		return nil, fmt.Errorf("stat input: %w", err)
 	}
 	totalSize := stat.Size()
-
-	// Calculate actual chunk size in bytes
-	chunkSize := int64(opts.ChunkSize)
-	switch opts.Unit {
-	case SplitUnitKiB:
-		chunkSize *= util.KiB
-	case SplitUnitMiB:
-		chunkSize *= util.MiB
-	case SplitUnitGiB:
-		chunkSize *= util.GiB
-	case SplitUnitTiB:
-		chunkSize *= util.TiB
-	case SplitUnitTotal:
-		// Divide into N equal parts
-		chunkSize = int64(math.Ceil(float64(totalSize) / float64(opts.ChunkSize)))
-	}
-
-	numChunks := int(math.Ceil(float64(totalSize) / float64(chunkSize)))
+	chunkSize, numChunks := ChunkLayout(totalSize, opts.ChunkSize, opts.Unit)
 
 	fin, err := os.Open(opts.InputPath)
 	if err != nil {
@@ -80,8 +70,8 @@ func Split(opts SplitOptions) ([]string, error) {
 	}
 	defer func() { _ = fin.Close() }()
 
-	// Delete existing chunks first
-	existingChunks, _ := filepath.Glob(opts.InputPath + ".*")
+	// Delete existing chunks first, finished or not
+	existingChunks, _ := chunkFiles(opts.InputPath, false)
 	for _, chunk := range existingChunks {
 		_ = os.Remove(chunk)
 	}
@@ -90,22 +80,25 @@ func Split(opts SplitOptions) ([]string, error) {
 	var totalDone int64
 	startTime := time.Now()
 
+	// removeChunks deletes every chunk written so far, finished or still
+	// .incomplete, so a failed or cancelled split leaves none behind
+	removeChunks := func() {
+		written, _ := chunkFiles(opts.InputPath, false)
+		for _, chunk := range written {
+			_ = os.Remove(chunk)
+		}
+	}
+
 	for i := range numChunks {
 		if opts.Cancel != nil && opts.Cancel() {
-			// Clean up partial chunks
-			for _, chunk := range chunks {
-				_ = os.Remove(chunk)
-			}
-			return nil, errors.New("operation cancelled")
+			removeChunks()
+			return nil, perrors.ErrCancelled
 		}
 
 		chunkPath := fmt.Sprintf("%s.%d.incomplete", opts.InputPath, i)
 		fout, err := os.Create(chunkPath)
 		if err != nil {
-			// Clean up partial chunks
-			for _, chunk := range chunks {
-				_ = os.Remove(chunk)
-			}
+			removeChunks()
 			return nil, fmt.Errorf("create chunk %d: %w", i, err)
 		}
 
@@ -115,11 +108,8 @@ func Split(opts SplitOptions) ([]string, error) {
 		for chunkDone < chunkSize {
 			if opts.Cancel != nil && opts.Cancel() {
 				_ = fout.Close()
-				_ = os.Remove(chunkPath)
-				for _, chunk := range chunks {
-					_ = os.Remove(chunk)
-				}
-				return nil, errors.New("operation cancelled")
+				removeChunks()
+				return nil, perrors.ErrCancelled
 			}
 
 			// Adjust buffer size if near end of chunk
@@ -132,10 +122,7 @@ func Split(opts SplitOptions) ([]string, error) {
 			if n > 0 {
 				if _, err := fout.Write(buf[:n]); err != nil {
 					_ = fout.Close()
-					_ = os.Remove(chunkPath)
-					for _, chunk := range chunks {
-						_ = os.Remove(chunk)
-					}
+					removeChunks()
 					return nil, fmt.Errorf("write chunk %d: %w", i, err)
 				}
 				chunkDone += int64(n)
@@ -155,26 +142,28 @@ func Split(opts SplitOptions) ([]string, error) {
 			}
 			if readErr != nil {
 				_ = fout.Close()
-				_ = os.Remove(chunkPath)
-				for _, chunk := range chunks {
-					_ = os.Remove(chunk)
-				}
+				removeChunks()
 				return nil, fmt.Errorf("read for chunk %d: %w", i, readErr)
 			}
 		}
 
-		// Sync to ensure data is flushed before renaming
+		// Sync to ensure data is flushed before renaming. On failure no
+		// .incomplete chunk is left behind for a later recombine to trip over.
 		if err := fout.Sync(); err != nil {
+			_ = fout.Close()
+			removeChunks()
 			return nil, fmt.Errorf("sync chunk %d: %w", i, err)
 		}
 
 		if err := fout.Close(); err != nil {
+			removeChunks()
 			return nil, fmt.Errorf("close chunk %d: %w", i, err)
 		}
 
 		// Rename to final name
 		finalPath := fmt.Sprintf("%s.%d", opts.InputPath, i)
-		if err := os.Rename(chunkPath, finalPath); err != nil {
+		if err := Rename(chunkPath, finalPath); err != nil {
+			removeChunks()
 			return nil, fmt.Errorf("rename chunk %d: %w", i, err)
 		}
 
@@ -183,3 +172,95 @@ func Split(opts SplitOptions) ([]string, error) {
 
 	return chunks, nil
 }
+
+// chunkSuffixRe matches the suffix of a chunk file after its base path: a
+// finished chunk (.N) or one an interrupted split left behind (.N.incomplete).
+var chunkSuffixRe = regexp.MustCompile(`^\.\d+(\.incomplete)?$`)
+
+// ChunkLayout returns the chunk size in bytes and the number of chunks
+// Split cuts a totalSize-byte file into for the given size and unit.
+func ChunkLayout(totalSize int64, size int, unit SplitUnit) (int64, int) {
+	chunkSize := int64(size)
+	switch unit {
+	case SplitUnitKiB:
+		chunkSize *= util.KiB
+	case SplitUnitMiB:
+		chunkSize *= util.MiB
+	case SplitUnitGiB:
+		chunkSize *= util.GiB
+	case SplitUnitTiB:
+		chunkSize *= util.TiB
+	case SplitUnitTotal:
+		// Divide into N equal parts
+		chunkSize = int64(math.Ceil(float64(totalSize) / float64(size)))
+	}
+	return chunkSize, int(math.Ceil(float64(totalSize) / float64(chunkSize)))
+}
+
+// IsChunkPath reports whether path names a chunk of basePath, finished or
+// .incomplete, whether or not it exists.
+func IsChunkPath(path, basePath string) bool {
+	suffix, ok := strings.CutPrefix(path, basePath)
+	return ok && chunkSuffixRe.MatchString(suffix)
+}
+
+// chunkFiles lists the chunk files of basePath, sorted by name. With
+// staleOnly, only .N.incomplete files are returned. A missing directory
+// yields no files.
+func chunkFiles(basePath string, staleOnly bool) ([]string, error) {
+	dir, base := filepath.Split(basePath)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list chunks: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), base)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		m := chunkSuffixRe.FindStringSubmatch(suffix)
+		if m == nil || (staleOnly && m[1] == "") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	return files, nil
+}
+
+// ChunkFiles returns every chunk file of basePath, finished or .incomplete,
+// sorted by name.
+func ChunkFiles(basePath string) ([]string, error) {
+	return chunkFiles(basePath, false)
+}
+
+// StaleChunks returns the .N.incomplete chunk files an interrupted split of
+// basePath left behind. Recombine and CountChunks never read them, but they
+// are worth reporting or removing before writing a new chunk set.
+func StaleChunks(basePath string) ([]string, error) {
+	return chunkFiles(basePath, true)
+}
+
+// RemoveStaleChunks deletes the files reported by StaleChunks and returns
+// the paths it removed.
+func RemoveStaleChunks(basePath string) ([]string, error) {
+	stale, err := StaleChunks(basePath)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("remove stale chunk: %w", err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
@@ -2,9 +2,15 @@ package fileops
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/util"
 )
 
 // TestSplitAndRecombine tests the full cycle of splitting and recombining a file.
@@ -165,6 +171,50 @@ func TestSplitCancellation(t *testing.T) {
 	t.Log("Split cancellation works correctly")
 }
 
+// TestSplitCancellationMidChunk tests that cancelling after a chunk is
+// finished and while the next is being written removes both
+func TestSplitCancellationMidChunk(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	inputPath := filepath.Join(tmpDir, "test.dat")
+	if err := os.WriteFile(inputPath, make([]byte, 7*util.MiB), 0644); err != nil {
+		t.Fatalf("Create test file: %v", err)
+	}
+
+	// Progress reports "2/4" once the first MiB of the second chunk is
+	// written; the next check cancels with .0 and .1.incomplete on disk
+	secondChunk := false
+	var onDisk []string
+	_, err := Split(SplitOptions{
+		InputPath: inputPath,
+		ChunkSize: 2,
+		Unit:      SplitUnitMiB,
+		Progress: func(_ float32, info string) {
+			secondChunk = info == "2/4"
+		},
+		Cancel: func() bool {
+			if secondChunk {
+				onDisk, _ = filepath.Glob(inputPath + ".*")
+			}
+			return secondChunk
+		},
+	})
+	if !errors.Is(err, perrors.ErrCancelled) {
+		t.Fatalf("Expected ErrCancelled, got: %v", err)
+	}
+	want := []string{inputPath + ".0", inputPath + ".1.incomplete"}
+	if !slices.Equal(onDisk, want) {
+		t.Fatalf("chunks on disk when cancelled = %v; want %v", onDisk, want)
+	}
+
+	if left, _ := filepath.Glob(inputPath + ".*"); len(left) > 0 {
+		t.Errorf("chunks left after cancellation: %v", left)
+	}
+	if _, err := os.Stat(inputPath); err != nil {
+		t.Errorf("input removed by cancelled split: %v", err)
+	}
+}
+
 // TestRecombineCancellation tests that recombine can be cancelled.
 func TestRecombineCancellation(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -443,3 +493,169 @@ func TestRecombineSingleChunk(t *testing.T) {
 		t.Error("Recombined content does not match original chunk")
 	}
 }
+
+// TestCheckChunkSizes tests that a chunk whose size doesn't fit its
+// siblings is reported by index.
+func TestCheckChunkSizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		sizes   []int
+		wantBad int // -1 for no error
+	}{
+		{"correct sizes", []int{100, 100, 100, 40}, -1},
+		{"equal last chunk", []int{100, 100, 100}, -1},
+		{"single chunk", []int{30}, -1},
+		{"short middle chunk", []int{100, 93, 100, 40}, 1},
+		{"short first chunk", []int{93, 100, 100, 40}, 0},
+		{"short first of two full chunks", []int{93, 100, 40}, 0},
+		{"short second of two full chunks", []int{100, 93, 40}, 1},
+		{"short first of two chunks", []int{60, 100}, 0},
+		{"oversized last chunk", []int{100, 100, 140}, 2},
+		{"empty last chunk", []int{100, 100, 0}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basePath := filepath.Join(t.TempDir(), "test.pcv")
+			for i, size := range tt.sizes {
+				if err := os.WriteFile(fmt.Sprintf("%s.%d", basePath, i), make([]byte, size), 0644); err != nil {
+					t.Fatalf("Create chunk: %v", err)
+				}
+			}
+
+			err := CheckChunkSizes(basePath)
+			if tt.wantBad < 0 {
+				if err != nil {
+					t.Fatalf("CheckChunkSizes failed: %v", err)
+				}
+				return
+			}
+			var sizeErr *ChunkSizeError
+			if !errors.As(err, &sizeErr) {
+				t.Fatalf("Expected ChunkSizeError, got: %v", err)
+			}
+			if sizeErr.Index != tt.wantBad {
+				t.Errorf("Bad chunk = %d; want %d", sizeErr.Index, tt.wantBad)
+			}
+			if !errors.Is(err, perrors.ErrChunkSize) {
+				t.Errorf("Error should match ErrChunkSize: %v", err)
+			}
+		})
+	}
+}
+
+// TestRecombineVerifySizes tests that a bad chunk stops Recombine before the
+// output is created.
+func TestRecombineVerifySizes(t *testing.T) {
+	tmpDir := t.TempDir()
+	basePath := filepath.Join(tmpDir, "test.pcv")
+	for i, size := range []int{100, 60, 100, 40} {
+		if err := os.WriteFile(fmt.Sprintf("%s.%d", basePath, i), make([]byte, size), 0644); err != nil {
+			t.Fatalf("Create chunk: %v", err)
+		}
+	}
+
+	outputPath := filepath.Join(tmpDir, "output.pcv")
+	err := Recombine(RecombineOptions{
+		InputBase:   basePath,
+		OutputPath:  outputPath,
+		VerifySizes: true,
+	})
+	var sizeErr *ChunkSizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Index != 1 {
+		t.Fatalf("Expected ChunkSizeError for chunk 1, got: %v", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("Output should not be created when a chunk is bad")
+	}
+}
+
+// TestSplitRemovesStaleChunks tests that a fresh split cleans up .incomplete
+// chunks left by an interrupted one without touching unrelated files.
+func TestSplitRemovesStaleChunks(t *testing.T) {
+	tmpDir := t.TempDir()
+	testData := bytes.Repeat([]byte("chunk data "), 1000) // ~11 KB, two 8 KiB chunks
+	inputPath := filepath.Join(tmpDir, "output.pcv")
+	if err := os.WriteFile(inputPath, testData, 0644); err != nil {
+		t.Fatalf("Create test file: %v", err)
+	}
+	stalePath := inputPath + ".2.incomplete"
+	if err := os.WriteFile(stalePath, []byte("leftover from a crash"), 0644); err != nil {
+		t.Fatalf("Create stale chunk: %v", err)
+	}
+	sidecarPath := inputPath + ".meta"
+	if err := os.WriteFile(sidecarPath, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Create sidecar: %v", err)
+	}
+
+	stale, err := StaleChunks(inputPath)
+	if err != nil || len(stale) != 1 || stale[0] != stalePath {
+		t.Fatalf("StaleChunks = %v, %v; want [%s]", stale, err, stalePath)
+	}
+
+	chunks, err := Split(SplitOptions{InputPath: inputPath, ChunkSize: 8, Unit: SplitUnitKiB})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(chunks))
+	}
+	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
+		t.Error("stale .incomplete chunk was not removed")
+	}
+	if _, err := os.Stat(sidecarPath); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+
+	recombinedPath := filepath.Join(tmpDir, "recombined.pcv")
+	if err := Recombine(RecombineOptions{InputBase: inputPath, OutputPath: recombinedPath}); err != nil {
+		t.Fatalf("Recombine failed: %v", err)
+	}
+	got, err := os.ReadFile(recombinedPath)
+	if err != nil {
+		t.Fatalf("Read recombined file: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Recombined data does not match original")
+	}
+}
+
+// TestRemoveStaleChunks tests that recombine ignores .incomplete chunks and
+// that they can be removed on their own.
+func TestRemoveStaleChunks(t *testing.T) {
+	tmpDir := t.TempDir()
+	base := filepath.Join(tmpDir, "vol.pcv")
+	files := map[string]string{
+		base + ".0":             "first",
+		base + ".1.incomplete":  "partial",
+		base + ".10.incomplete": "partial",
+		base + ".x.incomplete":  "not a chunk",
+		base + "2.0.incomplete": "other volume",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	count, size, err := CountChunks(base)
+	if err != nil || count != 1 || size != int64(len("first")) {
+		t.Errorf("CountChunks = %d, %d, %v; want 1 chunk of %d bytes", count, size, err, len("first"))
+	}
+
+	removed, err := RemoveStaleChunks(base)
+	if err != nil {
+		t.Fatalf("RemoveStaleChunks failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("removed %v; want the .1 and .10 leftovers", removed)
+	}
+	for path := range files {
+		_, err := os.Stat(path)
+		gone := os.IsNotExist(err)
+		want := path == base+".1.incomplete" || path == base+".10.incomplete"
+		if gone != want {
+			t.Errorf("%s removed = %v; want %v", filepath.Base(path), gone, want)
+		}
+	}
+}
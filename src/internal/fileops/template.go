@@ -0,0 +1,58 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// templateRe matches a {placeholder} in an output template.
+var templateRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ExpandOutputTemplate builds a per-file output path for batch encryption.
+// Placeholders refer to inputPath:
+//
+//	{dir}   directory of the input
+//	{name}  file name without its extension
+//	{ext}   extension including the dot, or "" if there is none
+//	{date}  now as YYYY-MM-DD
+//
+// For example "{dir}/encrypted/{name}{ext}.pcv" turns /a/b.txt into
+// /a/encrypted/b.txt.pcv. Unknown placeholders are an error. Missing parent
+// directories of the result are created.
+func ExpandOutputTemplate(template, inputPath string, now time.Time) (string, error) {
+	if template == "" {
+		return "", errors.New("empty output template")
+	}
+
+	base := filepath.Base(inputPath)
+	ext := filepath.Ext(base)
+	values := map[string]string{
+		"{dir}":  filepath.Dir(inputPath),
+		"{name}": strings.TrimSuffix(base, ext),
+		"{ext}":  ext,
+		"{date}": now.Format("2006-01-02"),
+	}
+
+	var unknown string
+	out := templateRe.ReplaceAllStringFunc(template, func(p string) string {
+		v, ok := values[p]
+		if !ok && unknown == "" {
+			unknown = p
+		}
+		return v
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("unknown placeholder %s in output template", unknown)
+	}
+	out = filepath.Clean(filepath.FromSlash(out))
+
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return "", fmt.Errorf("create output directory: %w", err)
+	}
+	return out, nil
+}
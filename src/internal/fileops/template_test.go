@@ -0,0 +1,65 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandOutputTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "photos", "beach.jpg")
+	now := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantDir  string
+	}{
+		{
+			name:     "sibling_folder",
+			template: "{dir}/../encrypted/{name}{ext}.pcv",
+			want:     filepath.Join(tmpDir, "encrypted", "beach.jpg.pcv"),
+			wantDir:  filepath.Join(tmpDir, "encrypted"),
+		},
+		{
+			name:     "dated",
+			template: "{dir}/{date}/{name}.pcv",
+			want:     filepath.Join(tmpDir, "photos", "2026-03-14", "beach.pcv"),
+			wantDir:  filepath.Join(tmpDir, "photos", "2026-03-14"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandOutputTemplate(tt.template, input, now)
+			if err != nil {
+				t.Fatalf("ExpandOutputTemplate failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if info, err := os.Stat(tt.wantDir); err != nil || !info.IsDir() {
+				t.Errorf("Output directory %s was not created", tt.wantDir)
+			}
+		})
+	}
+
+	t.Run("no_extension", func(t *testing.T) {
+		got, err := ExpandOutputTemplate("{dir}/{name}{ext}.pcv", filepath.Join(tmpDir, "README"), now)
+		if err != nil {
+			t.Fatalf("ExpandOutputTemplate failed: %v", err)
+		}
+		if want := filepath.Join(tmpDir, "README.pcv"); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("unknown_placeholder", func(t *testing.T) {
+		if _, err := ExpandOutputTemplate("{dir}/{user}.pcv", input, now); err == nil {
+			t.Error("Expected an error for an unknown placeholder")
+		}
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fileops
+
+import "os"
+
+// umask returns 0; only Unix has a file mode creation mask.
+func umask() os.FileMode {
+	return 0
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package fileops
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	umaskOnce  sync.Once
+	umaskValue os.FileMode
+)
+
+// umask returns the process file mode creation mask. Reading it means
+// setting it, so it is read once and put straight back.
+func umask() os.FileMode {
+	umaskOnce.Do(func() {
+		mask := unix.Umask(0)
+		unix.Umask(mask)
+		umaskValue = os.FileMode(mask) & os.ModePerm
+	})
+	return umaskValue
+}
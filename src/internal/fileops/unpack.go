@@ -20,7 +20,13 @@ type UnpackOptions struct {
 	SameLevel  bool   // Extract to same directory as zip (not a subdirectory)
 	Progress   ProgressFunc
 	Status     StatusFunc
-	Cancel     CancelFunc // Cancellation check callback (optional)
+	Cancel     CancelFunc  // Cancellation check callback (optional)
+	NameKey    NameKeyFunc // Restores real names from a name manifest (optional)
+
+	// RestoreModes applies the Unix permission bits stored in each entry,
+	// less the process umask. Setuid, setgid and sticky bits are never
+	// restored. Off, everything is created with the default mode.
+	RestoreModes bool
 }
 
 // normalizeZipPath normalizes a path from a zip file by converting all separators
@@ -51,9 +57,46 @@ func isValidExtractionPath(outPath, extractDir string) bool {
 	return !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && rel != ".."
 }
 
+// creatorUnix is the "version made by" host value for Unix in the zip spec;
+// only such entries carry meaningful permission bits.
+const creatorUnix = 3
+
+// restoreMode applies the permission bits stored in a zip entry to path,
+// masked by the umask as if the file had been created with them. Perm drops
+// the setuid, setgid and sticky bits. Entries written on other hosts only
+// carry DOS attributes and are left with the default mode.
+func restoreMode(path string, f *zip.File) error {
+	if f.CreatorVersion>>8 != creatorUnix {
+		return nil
+	}
+	if err := os.Chmod(path, f.Mode().Perm()&^umask()); err != nil {
+		return fmt.Errorf("set mode of %s: %w", path, err)
+	}
+	return nil
+}
+
+// UnpackedSize returns the total uncompressed size of the entries in the
+// zip archive at zipPath, as recorded in its central directory.
+func UnpackedSize(zipPath string) (int64, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, fmt.Errorf("open zip: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	var total int64
+	for _, f := range reader.File {
+		total += int64(f.UncompressedSize64)
+	}
+	return total, nil
+}
+
 // Unpack extracts a zip archive to the specified directory.
+// Permission bits stored for Unix-created entries are restored on files
+// and directories; directories are updated last so a read-only directory
+// does not block extracting its contents.
 func Unpack(opts UnpackOptions) (retErr error) {
-	reader, err := zip.OpenReader(opts.ZipPath)
+	reader, err := OpenZip(opts.ZipPath)
 	if err != nil {
 		return fmt.Errorf("open zip: %w", err)
 	}
@@ -63,9 +106,28 @@ func Unpack(opts UnpackOptions) (retErr error) {
 		}
 	}()
 
+	// Map opaque entry names back to the real ones; the manifest is not extracted
+	var realNames map[*zip.File]string
+	if opts.NameKey != nil {
+		realNames, err = RealNames(reader.File, opts.NameKey)
+		if err != nil {
+			return err
+		}
+	}
+	files := reader.File
+	if realNames != nil {
+		files = files[:len(files)-1]
+	}
+	entryName := func(f *zip.File) string {
+		if realNames != nil {
+			return realNames[f]
+		}
+		return f.Name
+	}
+
 	// Calculate total uncompressed size
 	var totalSize int64
-	for _, f := range reader.File {
+	for _, f := range files {
 		totalSize += int64(f.UncompressedSize64)
 	}
 
@@ -97,10 +159,10 @@ func Unpack(opts UnpackOptions) (retErr error) {
 
 	// First pass: create all directories and cache normalized paths
 	// Cache normalized paths to avoid redundant normalization in second pass
-	normalizedPaths := make(map[*zip.File]string, len(reader.File))
-	for _, f := range reader.File {
+	normalizedPaths := make(map[*zip.File]string, len(files))
+	for _, f := range files {
 		// Normalize and validate path to prevent zip slip attacks
-		normalizedName := normalizeZipPath(f.Name)
+		normalizedName := normalizeZipPath(entryName(f))
 		outPath := filepath.Join(extractDir, normalizedName)
 		if !isValidExtractionPath(outPath, extractDir) {
 			return errors.New("potentially malicious zip item path")
@@ -123,7 +185,7 @@ func Unpack(opts UnpackOptions) (retErr error) {
 	var done int64
 	startTime := time.Now()
 
-	for i, f := range reader.File {
+	for i, f := range files {
 		// Check for cancellation between files
 		if opts.Cancel != nil && opts.Cancel() {
 			return errors.New("operation cancelled")
@@ -141,9 +203,9 @@ func Unpack(opts UnpackOptions) (retErr error) {
 			return fmt.Errorf("create parent dir for %s: %w", outPath, err)
 		}
 
-		fileInArchive, err := f.Open()
+		fileInArchive, err := OpenEntry(f)
 		if err != nil {
-			return fmt.Errorf("open %s in archive: %w", f.Name, err)
+			return fmt.Errorf("open %s in archive: %w", entryName(f), err)
 		}
 
 		dstFile, err := os.Create(outPath)
@@ -174,7 +236,7 @@ func Unpack(opts UnpackOptions) (retErr error) {
 				done += int64(n)
 				if opts.Progress != nil {
 					progress, speed, eta := util.Statify(done, totalSize, startTime)
-					opts.Progress(progress, fmt.Sprintf("%d/%d", i+1, len(reader.File)))
+					opts.Progress(progress, fmt.Sprintf("%d/%d", i+1, len(files)))
 					if opts.Status != nil {
 						opts.Status(fmt.Sprintf("Unpacking at %.2f MiB/s (ETA: %s)", speed, eta))
 					}
@@ -187,12 +249,32 @@ func Unpack(opts UnpackOptions) (retErr error) {
 			if readErr != nil {
 				_ = dstFile.Close()
 				_ = fileInArchive.Close()
-				return fmt.Errorf("read %s: %w", f.Name, readErr)
+				return fmt.Errorf("read %s: %w", entryName(f), readErr)
 			}
 		}
 
 		_ = dstFile.Close()
 		_ = fileInArchive.Close()
+
+		if opts.RestoreModes {
+			if err := restoreMode(outPath, f); err != nil {
+				return err
+			}
+		}
+	}
+	if !opts.RestoreModes {
+		return nil
+	}
+
+	// Restore directory modes deepest first, after all contents are written
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		if !f.FileInfo().IsDir() {
+			continue
+		}
+		if err := restoreMode(normalizedPaths[f], f); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -193,6 +193,45 @@ func TestUnpackCancellation(t *testing.T) {
 	t.Logf("Unpack correctly cancelled: %v", err)
 }
 
+// TestUnpackedSize verifies that the uncompressed sizes are summed, not the
+// compressed ones
+func TestUnpackedSize(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "sizes.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Create zip file: %v", err)
+	}
+
+	w := zip.NewWriter(f)
+	for i, size := range []int{1000, 250000} {
+		fw, err := w.CreateHeader(&zip.FileHeader{
+			Name:   "file" + string(rune('0'+i)) + ".txt",
+			Method: zip.Deflate,
+		})
+		if err != nil {
+			t.Fatalf("Create entry: %v", err)
+		}
+		_, _ = fw.Write(make([]byte, size))
+	}
+	_ = w.Close()
+	_ = f.Close()
+
+	size, err := UnpackedSize(zipPath)
+	if err != nil {
+		t.Fatalf("UnpackedSize failed: %v", err)
+	}
+	if size != 251000 {
+		t.Errorf("UnpackedSize = %d; want 251000", size)
+	}
+	if stat, _ := os.Stat(zipPath); stat.Size() >= size {
+		t.Errorf("zip of %d bytes did not compress", stat.Size())
+	}
+
+	if _, err := UnpackedSize(filepath.Join(t.TempDir(), "missing.zip")); err == nil {
+		t.Error("expected an error for a missing zip")
+	}
+}
+
 // createMaliciousZip creates a zip file with a path traversal attempt
 func createMaliciousZip(t *testing.T, path string) {
 	t.Helper()
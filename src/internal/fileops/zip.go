@@ -2,14 +2,21 @@ package fileops
 
 import (
 	"archive/zip"
+	"bytes"
+	"compress/flate"
 	"crypto/rand"
 	"crypto/subtle"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sync"
 
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/log"
 	"Picocrypt-NG/internal/util"
 
 	"golang.org/x/crypto/chacha20"
@@ -57,6 +64,30 @@ func (er *encryptedReader) Read(data []byte) (int, error) {
 	return n, err
 }
 
+// encryptedReaderAt decrypts a file written through encryptedWriter at any
+// offset, seeking the ChaCha20 keystream to the 64-byte block holding it.
+// Used to open the encrypted temporary zip as an archive.
+type encryptedReaderAt struct {
+	r     io.ReaderAt
+	key   []byte
+	nonce []byte
+}
+
+func (er *encryptedReaderAt) ReadAt(data []byte, off int64) (int, error) {
+	n, err := er.r.ReadAt(data, off)
+	if n > 0 {
+		c, cerr := chacha20.NewUnauthenticatedCipher(er.key, er.nonce)
+		if cerr != nil {
+			return 0, cerr
+		}
+		c.SetCounter(uint32(off / 64))
+		skip := make([]byte, off%64)
+		c.XORKeyStream(skip, skip)
+		c.XORKeyStream(data[:n], data[:n])
+	}
+	return n, err
+}
+
 // TempZipCiphers holds paired ChaCha20 ciphers for encrypting temporary files.
 // This protects plaintext from being written to disk during multi-file encryption.
 //
@@ -145,14 +176,21 @@ func (t *TempZipCiphers) Close() {
 
 // ZipOptions configures zip file creation
 type ZipOptions struct {
-	Files      []string        // Files to include
-	RootDir    string          // Root directory for relative paths
-	OutputPath string          // Output .tmp file path
-	Compress   bool            // Use Deflate compression
-	Cipher     *TempZipCiphers // Optional encryption for temp file
-	Progress   ProgressFunc
-	Status     StatusFunc
-	Cancel     CancelFunc
+	Files           []string        // Files to include
+	RootDir         string          // Root directory for relative paths
+	OutputPath      string          // Output .tmp file path
+	Compress        bool            // Use Deflate compression
+	Method          *uint16         // With Compress, the entry method (see RegisterCompressor); nil = Deflate
+	DirEntries      bool            // Add an entry, with its mode, for each directory under RootDir
+	SkipHighEntropy bool            // With Compress, Store files whose first block looks incompressible
+	Workers         int             // With Compress, deflate small files on this many goroutines (0 or 1 = serial)
+	Cipher          *TempZipCiphers // Optional encryption for temp file
+	NameKey         []byte          // Optional 32-byte key; store entries under opaque names (see RealNames)
+	NameSalt        []byte          // With NameKey, the NameSaltSize-byte salt the key was derived from
+	Progress        ProgressFunc
+	Status          StatusFunc
+	Warn            StatusFunc // Advisories that leave the archive usable, e.g. a file stored uncompressed
+	Cancel          CancelFunc
 }
 
 // CreateZip creates a zip archive from the given files.
@@ -169,6 +207,11 @@ func CreateZip(opts ZipOptions) error {
 		w = &encryptedWriter{w: file, cipher: opts.Cipher.Writer}
 	}
 
+	method := zip.Deflate
+	if opts.Method != nil {
+		method = *opts.Method
+	}
+
 	writer := zip.NewWriter(w)
 
 	// Helper to cleanup on error
@@ -178,6 +221,13 @@ func CreateZip(opts ZipOptions) error {
 		_ = os.Remove(opts.OutputPath)
 	}
 
+	if opts.Compress {
+		if err := useCompressor(writer, method); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
 	// Calculate total size for progress
 	var totalSize int64
 	for _, path := range opts.Files {
@@ -189,11 +239,28 @@ func CreateZip(opts ZipOptions) error {
 		totalSize += stat.Size()
 	}
 
+	var prefetch *zipPrefetcher
+	if opts.Compress && method == zip.Deflate && opts.Workers > 1 {
+		prefetch = newZipPrefetcher(opts.Files, opts.Workers, opts.SkipHighEntropy)
+	}
+
+	var names *entryNames
+	if opts.NameKey != nil {
+		names = &entryNames{real: make(map[string]string)}
+	}
+
 	var done int64
+	buf := make([]byte, util.MiB)
+	seenDirs := make(map[string]bool)
 	for i, path := range opts.Files {
 		if opts.Cancel != nil && opts.Cancel() {
 			cleanup()
-			return errors.New("operation cancelled")
+			return perrors.ErrCancelled
+		}
+
+		var pre deflatedEntry
+		if prefetch != nil {
+			pre = prefetch.take(i)
 		}
 
 		if opts.Progress != nil {
@@ -215,14 +282,53 @@ func CreateZip(opts ZipOptions) error {
 		// Set relative path
 		rel, err := filepath.Rel(opts.RootDir, path)
 		if err != nil {
+			cleanup()
 			return err
 		}
-		header.Name = filepath.ToSlash(rel)
+		name := filepath.ToSlash(rel)
 
+		if opts.DirEntries {
+			if err := addDirEntries(writer, opts.RootDir, filepath.Dir(rel), seenDirs, names); err != nil {
+				cleanup()
+				return err
+			}
+		}
+		header.Name = names.assign(name)
+
+		if opts.Status != nil {
+			opts.Status(fmt.Sprintf("Compressing %s (%d/%d)...", name, i+1, len(opts.Files)))
+		}
+
+		header.Method = zip.Store
 		if opts.Compress {
-			header.Method = zip.Deflate
-		} else {
-			header.Method = zip.Store
+			header.Method = method
+			if opts.SkipHighEntropy && looksIncompressible(path) {
+				header.Method = zip.Store
+				log.Info("storing high-entropy file uncompressed", log.String("file", name))
+				if opts.Warn != nil {
+					opts.Warn(fmt.Sprintf("%s appears already compressed; compression disabled", name))
+				}
+			}
+		}
+
+		// Write an entry deflated ahead of time as is
+		if pre.ok && header.Method == zip.Deflate {
+			header.CRC32 = pre.crc
+			header.UncompressedSize64 = pre.size
+			header.CompressedSize64 = uint64(len(pre.data))
+			entry, err := writer.CreateRaw(header)
+			if err == nil {
+				_, err = entry.Write(pre.data)
+			}
+			if err != nil {
+				cleanup()
+				return fmt.Errorf("zip %s: %w", path, err)
+			}
+			done += int64(pre.size)
+			if opts.Progress != nil {
+				opts.Progress(float32(done)/float32(totalSize), fmt.Sprintf("%d/%d", i+1, len(opts.Files)))
+			}
+			continue
 		}
 
 		entry, err := writer.CreateHeader(header)
@@ -237,40 +343,33 @@ func CreateZip(opts ZipOptions) error {
 			return fmt.Errorf("open %s: %w", path, err)
 		}
 
-		buf := make([]byte, util.MiB)
-		for {
-			if opts.Cancel != nil && opts.Cancel() {
-				_ = fin.Close()
-				cleanup()
-				return errors.New("operation cancelled")
-			}
-
-			n, readErr := fin.Read(buf)
-			if n > 0 {
-				if _, err := entry.Write(buf[:n]); err != nil {
-					_ = fin.Close()
-					cleanup()
-					return fmt.Errorf("write to zip: %w", err)
-				}
-				done += int64(n)
-
-				if opts.Progress != nil {
-					opts.Progress(float32(done)/float32(totalSize), fmt.Sprintf("%d/%d", i+1, len(opts.Files)))
-				}
-			}
-
-			if readErr == io.EOF {
-				break
-			}
-			if readErr != nil {
-				_ = fin.Close()
-				cleanup()
-				return fmt.Errorf("read %s: %w", path, readErr)
+		// Copy through a reader that checks for cancellation and reports
+		// progress on every read, so a single huge file can be interrupted
+		pr := &zipProgressReader{
+			r:     fin,
+			opts:  &opts,
+			done:  &done,
+			total: totalSize,
+			info:  fmt.Sprintf("%d/%d", i+1, len(opts.Files)),
+		}
+		if _, err := io.CopyBuffer(entry, pr, buf); err != nil {
+			_ = fin.Close()
+			cleanup()
+			if errors.Is(err, perrors.ErrCancelled) {
+				return err
 			}
+			return fmt.Errorf("zip %s: %w", path, err)
 		}
 		_ = fin.Close()
 	}
 
+	if names != nil {
+		if err := names.writeManifest(writer, opts.NameKey, opts.NameSalt); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
 	// Close writer and file on success
 	if err := writer.Close(); err != nil {
 		_ = file.Close()
@@ -285,6 +384,280 @@ func CreateZip(opts ZipOptions) error {
 	return nil
 }
 
+// Archive overhead bounds for MaxZipSize, as archive/zip writes entries for
+// CreateZip: a local header (30) and central directory record (46), both
+// carrying the name and a 9-byte extended timestamp, up to 28 bytes of Zip64
+// extras and a 24-byte data descriptor. The end of the archive has a Zip64
+// end record (56) and locator (20) besides the regular end record (22).
+// Deflate falls back to stored blocks of at least 16 KiB for incompressible
+// data, at 5 bytes per block, plus an empty final block.
+const (
+	zipEntryOverhead   = 30 + 46 + 2*9 + 28 + 24
+	zipEndOverhead     = 56 + 20 + 22
+	deflateBlockSize   = 16 * util.KiB
+	deflateBlockHeader = 5
+
+	// Manifest data around the JSON: magic, salt, nonce and Poly1305 tag
+	nameManifestOverhead = 8 + NameSaltSize + 24 + 16
+)
+
+// MaxZipSize returns an upper bound on the size of the archive CreateZip
+// writes for opts when the files hold dataSize bytes in total, without
+// reading them. Entry names are counted as sealed when NameKey is set; the
+// key itself is not used. Compressible data usually makes the archive much
+// smaller, so this is meant for free space checks, not size predictions. A
+// registered Method is assumed to expand data no more than Deflate.
+func MaxZipSize(opts ZipOptions, dataSize int64) int64 {
+	var names []string
+	seenDirs := make(map[string]bool)
+	for _, path := range opts.Files {
+		rel, err := filepath.Rel(opts.RootDir, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		if opts.DirEntries {
+			for dir := filepath.Dir(rel); dir != "." && !seenDirs[dir]; dir = filepath.Dir(dir) {
+				seenDirs[dir] = true
+				names = append(names, filepath.ToSlash(dir)+"/")
+			}
+		}
+		names = append(names, filepath.ToSlash(rel))
+	}
+
+	size := dataSize + zipEndOverhead
+	if opts.Compress {
+		size += (dataSize/deflateBlockSize + 2*int64(len(opts.Files))) * deflateBlockHeader
+	}
+
+	manifest := int64(2) // JSON braces
+	for i, name := range names {
+		stored := len(name)
+		if opts.NameKey != nil {
+			stored = len(fmt.Sprintf("%08x", i)) + 1
+			// "opaque":"real", with every character of the real name escaped
+			manifest += int64(stored) + 6*int64(len(name)) + 6
+		}
+		size += zipEntryOverhead + 2*int64(stored)
+	}
+	if opts.NameKey != nil {
+		size += zipEntryOverhead + 2*int64(len(NameManifestEntry)) + nameManifestOverhead + manifest
+	}
+	return size
+}
+
+// Entropy sampling for SkipHighEntropy. Encrypted and already compressed
+// data sits just under 8 bits per byte; text and most uncompressed formats
+// are well below the threshold. Samples smaller than entropyMinSample are
+// too short to judge and are always compressed.
+const (
+	entropySampleSize = 64 * util.KiB
+	entropyMinSample  = 4 * util.KiB
+	entropyThreshold  = 7.5 // bits per byte
+)
+
+// looksIncompressible reports whether the first block of path has a Shannon
+// entropy above entropyThreshold. Read errors report false; the file is then
+// compressed as usual and any real error surfaces when it is archived.
+func looksIncompressible(path string) bool {
+	fin, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = fin.Close() }()
+
+	buf := make([]byte, entropySampleSize)
+	n, _ := io.ReadFull(fin, buf)
+	if n < entropyMinSample {
+		return false
+	}
+	return shannonEntropy(buf[:n]) > entropyThreshold
+}
+
+// shannonEntropy returns the Shannon entropy of data in bits per byte.
+func shannonEntropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var entropy float64
+	total := float64(len(data))
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / total
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// Concurrent compression for ZipOptions.Workers. Each prefetched entry is
+// held in memory until the writer reaches it, so only files up to
+// parallelZipMaxSize are compressed ahead; larger ones take the serial path.
+// zipDeflateLevel matches archive/zip's own compressor, so an entry's data
+// is the same either way.
+const (
+	parallelZipMaxSize = 8 * util.MiB
+	zipDeflateLevel    = 5
+)
+
+// flateWriterPool reuses compressors across files, as archive/zip does;
+// setting one up costs far more than compressing a small file.
+var flateWriterPool sync.Pool
+
+// deflatedEntry is a file compressed ahead of the zip writer. ok is false if
+// the file was not compressed ahead (too large, incompressible or an error);
+// the writer then handles it serially and reports any error itself.
+type deflatedEntry struct {
+	ok   bool
+	data []byte
+	crc  uint32
+	size uint64
+}
+
+// zipPrefetcher compresses the files following the one being written, at
+// most workers at a time, and hands the results back in file order.
+type zipPrefetcher struct {
+	files           []string
+	workers         int
+	skipHighEntropy bool
+	results         []chan deflatedEntry
+	next            int // Index of the next file to start
+}
+
+func newZipPrefetcher(files []string, workers int, skipHighEntropy bool) *zipPrefetcher {
+	return &zipPrefetcher{
+		files:           files,
+		workers:         workers,
+		skipHighEntropy: skipHighEntropy,
+		results:         make([]chan deflatedEntry, len(files)),
+	}
+}
+
+// take starts compressing files up to i+workers-1 and waits for file i.
+// Goroutines still running when CreateZip returns early finish on their
+// own; their buffered results are simply dropped.
+func (p *zipPrefetcher) take(i int) deflatedEntry {
+	for p.next < len(p.files) && p.next < i+p.workers {
+		ch := make(chan deflatedEntry, 1)
+		p.results[p.next] = ch
+		path := p.files[p.next]
+		go func() { ch <- deflateFile(path, p.skipHighEntropy) }()
+		p.next++
+	}
+	entry := <-p.results[i]
+	p.results[i] = nil
+	return entry
+}
+
+// deflateFile compresses a small regular file into memory.
+func deflateFile(path string, skipHighEntropy bool) deflatedEntry {
+	stat, err := os.Stat(path)
+	if err != nil || !stat.Mode().IsRegular() || stat.Size() > parallelZipMaxSize {
+		return deflatedEntry{}
+	}
+	if skipHighEntropy && looksIncompressible(path) {
+		return deflatedEntry{}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return deflatedEntry{}
+	}
+
+	var out bytes.Buffer
+	fw, ok := flateWriterPool.Get().(*flate.Writer)
+	if ok {
+		fw.Reset(&out)
+	} else if fw, err = flate.NewWriter(&out, zipDeflateLevel); err != nil {
+		return deflatedEntry{}
+	}
+	defer flateWriterPool.Put(fw)
+	if _, err := fw.Write(data); err != nil {
+		return deflatedEntry{}
+	}
+	if err := fw.Close(); err != nil {
+		return deflatedEntry{}
+	}
+	return deflatedEntry{
+		ok:   true,
+		data: out.Bytes(),
+		crc:  crc32.ChecksumIEEE(data),
+		size: uint64(len(data)),
+	}
+}
+
+// addDirEntries writes a directory entry for relDir and each of its
+// ancestors below the root, parents first, skipping any already in seen.
+// The entries carry the directories' modes so Unpack can restore them.
+func addDirEntries(writer *zip.Writer, rootDir, relDir string, seen map[string]bool, names *entryNames) error {
+	if relDir == "." || seen[relDir] {
+		return nil
+	}
+	if err := addDirEntries(writer, rootDir, filepath.Dir(relDir), seen, names); err != nil {
+		return err
+	}
+	seen[relDir] = true
+
+	path := filepath.Join(rootDir, relDir)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return fmt.Errorf("create header for %s: %w", path, err)
+	}
+	header.Name = names.assign(filepath.ToSlash(relDir) + "/")
+	header.Method = zip.Store
+	if _, err := writer.CreateHeader(header); err != nil {
+		return fmt.Errorf("create entry for %s: %w", path, err)
+	}
+	return nil
+}
+
+// zipProgressReader wraps a source file being added to a zip archive.
+// Each Read checks for cancellation before touching the file and reports
+// overall progress afterwards.
+type zipProgressReader struct {
+	r     io.Reader
+	opts  *ZipOptions
+	done  *int64 // Bytes read across all files
+	total int64  // Total bytes across all files
+	info  string // Per-file progress label, e.g. "2/5"
+}
+
+func (pr *zipProgressReader) Read(p []byte) (int, error) {
+	if pr.opts.Cancel != nil && pr.opts.Cancel() {
+		return 0, perrors.ErrCancelled
+	}
+
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		*pr.done += int64(n)
+		if pr.opts.Progress != nil {
+			pr.opts.Progress(float32(*pr.done)/float32(pr.total), pr.info)
+		}
+	}
+	return n, err
+}
+
+// WrapWriterWithCipher wraps a writer with the temp zip encryption cipher
+func WrapWriterWithCipher(w io.Writer, cipher *TempZipCiphers) io.Writer {
+	if cipher == nil {
+		return w
+	}
+	return &encryptedWriter{w: w, cipher: cipher.Writer}
+}
+
+// WrapReaderAtWithCipher gives random access to a file written through
+// WrapWriterWithCipher, decrypting whatever is read. It must not be used
+// after cipher is closed.
+func WrapReaderAtWithCipher(r io.ReaderAt, cipher *TempZipCiphers) io.ReaderAt {
+	if cipher == nil {
+		return r
+	}
+	return &encryptedReaderAt{r: r, key: cipher.key, nonce: cipher.nonce}
+}
+
 // WrapReaderWithCipher wraps a reader with the temp zip decryption cipher
 func WrapReaderWithCipher(r io.Reader, cipher *TempZipCiphers) io.Reader {
 	if cipher == nil {
@@ -3,10 +3,16 @@ package fileops
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	perrors "Picocrypt-NG/internal/errors"
 )
 
 func TestTempZipCiphers(t *testing.T) {
@@ -132,6 +138,35 @@ func TestTempZipCiphersEncryptDecrypt(t *testing.T) {
 	}
 }
 
+func TestWrapReaderAtWithCipher(t *testing.T) {
+	ciphers, err := NewTempZipCiphers()
+	if err != nil {
+		t.Fatalf("NewTempZipCiphers() failed: %v", err)
+	}
+	defer ciphers.Close()
+
+	plaintext := make([]byte, 1000)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 31)
+	}
+	var buf bytes.Buffer
+	if _, err := WrapWriterWithCipher(&buf, ciphers).Write(plaintext); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Offsets inside, at and across 64-byte keystream blocks
+	ra := WrapReaderAtWithCipher(bytes.NewReader(buf.Bytes()), ciphers)
+	for _, r := range []struct{ off, n int }{{0, 10}, {63, 2}, {64, 64}, {100, 500}, {990, 10}} {
+		got := make([]byte, r.n)
+		if _, err := ra.ReadAt(got, int64(r.off)); err != nil {
+			t.Fatalf("ReadAt(%d) failed: %v", r.off, err)
+		}
+		if !bytes.Equal(got, plaintext[r.off:r.off+r.n]) {
+			t.Errorf("ReadAt(%d, %d bytes) returned wrong plaintext", r.off, r.n)
+		}
+	}
+}
+
 func TestCreateZip(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -251,6 +286,189 @@ func TestCreateZipWithCompression(t *testing.T) {
 		statNoCompress.Size(), statCompress.Size())
 }
 
+// writeManyFiles creates count small text files spread over a few
+// subdirectories of dir and returns their paths in creation order.
+func writeManyFiles(tb testing.TB, dir string, count int) []string {
+	tb.Helper()
+	var files []string
+	for i := 0; i < count; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%d", i%4))
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			tb.Fatalf("MkdirAll: %v", err)
+		}
+		path := filepath.Join(sub, fmt.Sprintf("file%04d.txt", i))
+		content := strings.Repeat(fmt.Sprintf("line %d of file %d\n", i%7, i), 50+i%200)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			tb.Fatalf("WriteFile: %v", err)
+		}
+		files = append(files, path)
+	}
+	return files
+}
+
+// TestCreateZipWorkers tests that a concurrently compressed archive extracts
+// to the same entries, in the same order, as a serial one.
+func TestCreateZipWorkers(t *testing.T) {
+	srcDir := filepath.Join(t.TempDir(), "src")
+	files := writeManyFiles(t, srcDir, 120)
+
+	// A file too large to prefetch and an incompressible one take the serial path
+	large := filepath.Join(srcDir, "large.txt")
+	if err := os.WriteFile(large, bytes.Repeat([]byte("large "), parallelZipMaxSize/6+1000), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	random := make([]byte, 64*1024)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	noise := filepath.Join(srcDir, "noise.bin")
+	if err := os.WriteFile(noise, random, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	files = append(files[:60], append([]string{large, noise}, files[60:]...)...)
+
+	build := func(workers int) string {
+		out := filepath.Join(t.TempDir(), fmt.Sprintf("workers%d.zip", workers))
+		err := CreateZip(ZipOptions{
+			Files:           files,
+			RootDir:         srcDir,
+			OutputPath:      out,
+			Compress:        true,
+			SkipHighEntropy: true,
+			Workers:         workers,
+		})
+		if err != nil {
+			t.Fatalf("CreateZip (workers=%d) failed: %v", workers, err)
+		}
+		return out
+	}
+	serial, parallel := build(1), build(4)
+
+	sr, err := zip.OpenReader(serial)
+	if err != nil {
+		t.Fatalf("open serial zip: %v", err)
+	}
+	defer sr.Close()
+	pr, err := zip.OpenReader(parallel)
+	if err != nil {
+		t.Fatalf("open parallel zip: %v", err)
+	}
+	defer pr.Close()
+
+	if len(sr.File) != len(pr.File) {
+		t.Fatalf("entry count: serial %d, parallel %d", len(sr.File), len(pr.File))
+	}
+	read := func(f *zip.File) []byte {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		return data
+	}
+	for i := range sr.File {
+		s, p := sr.File[i], pr.File[i]
+		if s.Name != p.Name || s.Method != p.Method || s.Mode() != p.Mode() {
+			t.Fatalf("entry %d: serial %s (method %d), parallel %s (method %d)", i, s.Name, s.Method, p.Name, p.Method)
+		}
+		if !bytes.Equal(read(s), read(p)) {
+			t.Errorf("entry %s differs between serial and parallel archives", s.Name)
+		}
+	}
+}
+
+// BenchmarkCreateZipWorkers compares serial and concurrent compression of a
+// folder of many small files.
+func BenchmarkCreateZipWorkers(b *testing.B) {
+	srcDir := b.TempDir()
+	files := writeManyFiles(b, srcDir, 1000)
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			out := filepath.Join(b.TempDir(), "bench.zip")
+			for b.Loop() {
+				err := CreateZip(ZipOptions{
+					Files:      files,
+					RootDir:    srcDir,
+					OutputPath: out,
+					Compress:   true,
+					Workers:    workers,
+				})
+				if err != nil {
+					b.Fatalf("CreateZip failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// TestCreateZipSkipHighEntropy tests that incompressible files are stored
+// with an advisory while compressible ones are still deflated.
+func TestCreateZipSkipHighEntropy(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	random := make([]byte, 256*1024)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	inputs := map[string][]byte{
+		"random.bin":     random,
+		"repetitive.txt": bytes.Repeat([]byte("Picocrypt "), 25000),
+	}
+
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, name)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatalf("Create file: %v", err)
+			}
+
+			var warnings []string
+			zipPath := filepath.Join(tmpDir, name+".zip")
+			err := CreateZip(ZipOptions{
+				Files:           []string{path},
+				RootDir:         tmpDir,
+				OutputPath:      zipPath,
+				Compress:        true,
+				SkipHighEntropy: true,
+				Warn:            func(s string) { warnings = append(warnings, s) },
+			})
+			if err != nil {
+				t.Fatalf("CreateZip failed: %v", err)
+			}
+
+			advised := false
+			for _, s := range warnings {
+				if strings.Contains(s, "appears already compressed") {
+					advised = true
+				}
+			}
+
+			reader, err := zip.OpenReader(zipPath)
+			if err != nil {
+				t.Fatalf("Open zip: %v", err)
+			}
+			defer func() { _ = reader.Close() }()
+			method := reader.File[0].Method
+
+			highEntropy := name == "random.bin"
+			if advised != highEntropy {
+				t.Errorf("Advisory fired = %v; want %v (warnings: %q)", advised, highEntropy, warnings)
+			}
+			wantMethod := zip.Deflate
+			if highEntropy {
+				wantMethod = zip.Store
+			}
+			if method != wantMethod {
+				t.Errorf("Method = %d; want %d", method, wantMethod)
+			}
+		})
+	}
+}
+
 func TestCreateZipWithEncryption(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -356,6 +574,40 @@ func TestCreateZipCancellation(t *testing.T) {
 	}
 }
 
+func TestCreateZipCancellationMidFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A single file spanning several read buffers
+	bigFile := filepath.Join(tmpDir, "big.bin")
+	if err := os.WriteFile(bigFile, bytes.Repeat([]byte("Z"), 8*1024*1024), 0644); err != nil {
+		t.Fatalf("Create file: %v", err)
+	}
+
+	// Allow the per-file check and a couple of reads, then cancel
+	checks := 0
+	zipPath := filepath.Join(tmpDir, "cancelled.tmp")
+	err := CreateZip(ZipOptions{
+		Files:      []string{bigFile},
+		RootDir:    tmpDir,
+		OutputPath: zipPath,
+		Compress:   true,
+		Cancel: func() bool {
+			checks++
+			return checks > 3
+		},
+	})
+
+	if !errors.Is(err, perrors.ErrCancelled) {
+		t.Fatalf("Expected ErrCancelled, got: %v", err)
+	}
+	if checks != 4 {
+		t.Errorf("Cancel checked %d times; want 4 (cancellation should stop the copy)", checks)
+	}
+	if _, err := os.Stat(zipPath); !os.IsNotExist(err) {
+		t.Error("Cancelled zip should be removed")
+	}
+}
+
 func TestCreateZipProgress(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -38,7 +38,16 @@ type AuthResult struct {
 //  7. serpentIV
 //  8. nonce
 //  9. keyfileHash
-func ComputeV2HeaderMAC(subkeyHeader []byte, h *VolumeHeader, keyfileHash []byte) []byte {
+//  10. block table digest (only if Flags.BlockHashes)
+//  11. recovery wrap (only if Flags.Recovery)
+//  12. split layout digits (only if Flags.SplitLayout)
+//  13. trailer digits (only if Flags.Trailer)
+//  14. aad (only if non-empty)
+//
+// aad is caller-supplied associated data that is authenticated but never
+// stored; the same bytes must be supplied at decryption. An empty aad adds
+// nothing to the MAC, so volumes without AAD are unchanged.
+func ComputeV2HeaderMAC(subkeyHeader []byte, h *VolumeHeader, keyfileHash, aad []byte) []byte {
 	mac := hmac.New(sha3.New512, subkeyHeader)
 
 	// Write all header fields in exact order
@@ -51,13 +60,28 @@ func ComputeV2HeaderMAC(subkeyHeader []byte, h *VolumeHeader, keyfileHash []byte
 	mac.Write(h.SerpentIV)
 	mac.Write(h.Nonce)
 	mac.Write(keyfileHash)
+	if h.Flags.BlockHashes {
+		mac.Write(h.BlockTableDigest)
+	}
+	if h.Flags.Recovery {
+		mac.Write(h.RecoveryWrap)
+	}
+	if h.Flags.SplitLayout && h.SplitLayout != nil {
+		mac.Write(h.SplitLayout.digits())
+	}
+	if h.Flags.Trailer && h.Trailer != nil {
+		mac.Write(h.Trailer.digits())
+	}
+	if len(aad) > 0 {
+		mac.Write(aad)
+	}
 
 	return mac.Sum(nil)
 }
 
 // ComputeV2HeaderMACRaw computes the HMAC-SHA3-512 using raw header field bytes.
 // This is used during decryption where we need to use the exact decoded bytes.
-func ComputeV2HeaderMACRaw(subkeyHeader []byte, raw *RawHeaderFields, h *VolumeHeader, keyfileHash []byte) []byte {
+func ComputeV2HeaderMACRaw(subkeyHeader []byte, raw *RawHeaderFields, h *VolumeHeader, keyfileHash, aad []byte) []byte {
 	mac := hmac.New(sha3.New512, subkeyHeader)
 
 	// Write all header fields in exact order using raw bytes where available
@@ -70,6 +94,21 @@ func ComputeV2HeaderMACRaw(subkeyHeader []byte, raw *RawHeaderFields, h *VolumeH
 	mac.Write(h.SerpentIV)
 	mac.Write(h.Nonce)
 	mac.Write(keyfileHash)
+	if h.Flags.BlockHashes {
+		mac.Write(h.BlockTableDigest)
+	}
+	if h.Flags.Recovery {
+		mac.Write(h.RecoveryWrap)
+	}
+	if h.Flags.SplitLayout && h.SplitLayout != nil {
+		mac.Write(h.SplitLayout.digits())
+	}
+	if h.Flags.Trailer && h.Trailer != nil {
+		mac.Write(h.Trailer.digits())
+	}
+	if len(aad) > 0 {
+		mac.Write(aad)
+	}
 
 	return mac.Sum(nil)
 }
@@ -84,8 +123,8 @@ func ComputeV1KeyHash(key []byte) []byte {
 
 // VerifyV2Header verifies a v2 volume header using HMAC-SHA3-512.
 // Returns true if the computed MAC matches the stored keyHash.
-func VerifyV2Header(subkeyHeader []byte, h *VolumeHeader, keyfileHash []byte) *AuthResult {
-	computed := ComputeV2HeaderMAC(subkeyHeader, h, keyfileHash)
+func VerifyV2Header(subkeyHeader []byte, h *VolumeHeader, keyfileHash, aad []byte) *AuthResult {
+	computed := ComputeV2HeaderMAC(subkeyHeader, h, keyfileHash, aad)
 	valid := subtle.ConstantTimeCompare(computed, h.KeyHash) == 1
 
 	return &AuthResult{
@@ -95,8 +134,8 @@ func VerifyV2Header(subkeyHeader []byte, h *VolumeHeader, keyfileHash []byte) *A
 }
 
 // VerifyV2HeaderRaw verifies a v2 volume header using raw decoded bytes.
-func VerifyV2HeaderRaw(subkeyHeader []byte, raw *RawHeaderFields, h *VolumeHeader, keyfileHash []byte) *AuthResult {
-	computed := ComputeV2HeaderMACRaw(subkeyHeader, raw, h, keyfileHash)
+func VerifyV2HeaderRaw(subkeyHeader []byte, raw *RawHeaderFields, h *VolumeHeader, keyfileHash, aad []byte) *AuthResult {
+	computed := ComputeV2HeaderMACRaw(subkeyHeader, raw, h, keyfileHash, aad)
 	valid := subtle.ConstantTimeCompare(computed, h.KeyHash) == 1
 
 	return &AuthResult{
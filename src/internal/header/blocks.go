@@ -0,0 +1,122 @@
+package header
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"Picocrypt-NG/internal/encoding"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Block table layout (only present when Flags.BlockHashes is set).
+// The table sits between the header and the payload so that any prefix of a
+// volume that includes it can be checked block by block:
+//
+//	blockCount  rs16: 16 -> 48   zero-padded decimal number of blocks N
+//	hashes      rs32: 32 -> 96   N x SHA3-256 of each on-disk payload block
+//
+// A block is the encoded form of one 1 MiB plaintext read, as written to the
+// volume (1 MiB, or 1088 KiB with Reed-Solomon); the last block may be shorter.
+// The table is authenticated by folding its Digest into the v2 header MAC.
+const (
+	BlockHashSize     = 32
+	BlockCountEncSize = 48
+	BlockHashEncSize  = 96
+)
+
+// ErrCorruptedBlockTable indicates the block table could not be decoded
+var ErrCorruptedBlockTable = errors.New("block hash table is damaged")
+
+// BlockTable holds the per-block payload hashes of a volume
+type BlockTable struct {
+	Hashes [][]byte // SHA3-256 of each on-disk payload block, in order
+}
+
+// NewBlockTable creates a table with room for blocks hashes
+func NewBlockTable(blocks int64) *BlockTable {
+	return &BlockTable{Hashes: make([][]byte, blocks)}
+}
+
+// BlockTableSize returns the encoded size of a table of blocks hashes
+func BlockTableSize(blocks int64) int64 {
+	return BlockCountEncSize + blocks*BlockHashEncSize
+}
+
+// HashBlock returns the table entry for one on-disk payload block
+func HashBlock(block []byte) []byte {
+	sum := sha3.Sum256(block)
+	return sum[:]
+}
+
+// Size returns the encoded size of the table
+func (t *BlockTable) Size() int64 {
+	return BlockTableSize(int64(len(t.Hashes)))
+}
+
+// Digest returns SHA3-256(blockCount || hashes...), the value bound into the
+// header MAC. Unset entries hash as zeros.
+func (t *BlockTable) Digest() []byte {
+	d := sha3.New256()
+	_, _ = fmt.Fprintf(d, "%016d", len(t.Hashes))
+	zero := make([]byte, BlockHashSize)
+	for _, h := range t.Hashes {
+		if h == nil {
+			h = zero
+		}
+		d.Write(h)
+	}
+	return d.Sum(nil)
+}
+
+// WriteBlockTable writes the encoded table at offset
+func WriteBlockTable(w io.WriterAt, offset int64, t *BlockTable, rs *encoding.RSCodecs) error {
+	buf := make([]byte, 0, t.Size())
+	buf = append(buf, encoding.Encode(rs.RS16, []byte(fmt.Sprintf("%016d", len(t.Hashes))))...)
+	zero := make([]byte, BlockHashSize)
+	for _, h := range t.Hashes {
+		if h == nil {
+			h = zero
+		}
+		buf = append(buf, encoding.Encode(rs.RS32, h)...)
+	}
+	if _, err := w.WriteAt(buf, offset); err != nil {
+		return fmt.Errorf("write block table: %w", err)
+	}
+	return nil
+}
+
+// ReadBlockTable reads an encoded table from r, which must be positioned
+// directly after the header. Unlike ReadHeader it fails on any decode error,
+// since a damaged entry could no longer be matched against its block.
+func ReadBlockTable(r io.Reader, rs *encoding.RSCodecs) (*BlockTable, error) {
+	countEnc := make([]byte, BlockCountEncSize)
+	if _, err := readField(r, "block count", countEnc); err != nil {
+		return nil, err
+	}
+	countDec, err := encoding.Decode(rs.RS16, countEnc, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: block count: %w", ErrCorruptedBlockTable, err)
+	}
+	count, err := strconv.ParseInt(string(countDec), 10, 64)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("%w: invalid block count", ErrCorruptedBlockTable)
+	}
+
+	// Read entries incrementally so a bogus count cannot force a huge allocation
+	t := &BlockTable{}
+	enc := make([]byte, BlockHashEncSize)
+	for i := int64(0); i < count; i++ {
+		if _, err := readField(r, "block table", enc); err != nil {
+			return nil, err
+		}
+		dec, err := encoding.Decode(rs.RS32, enc, false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: block hash %d: %w", ErrCorruptedBlockTable, i, err)
+		}
+		t.Hashes = append(t.Hashes, dec)
+	}
+	return t, nil
+}
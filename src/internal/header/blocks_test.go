@@ -0,0 +1,97 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+func TestFlagsBlockHashes(t *testing.T) {
+	flags := Flags{Paranoid: true, Pepper: true, Threads: 3, BlockHashes: true}
+	if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+		t.Errorf("BlockHashes round-trip: got %+v", parsed)
+	}
+	if b := (&Flags{}).ToBytes(); b[0] != 0 {
+		t.Errorf("Flags without block hashes ToBytes()[0] = %d; want 0", b[0])
+	}
+}
+
+func TestBlockTableRoundTrip(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	table := NewBlockTable(3)
+	for i := range table.Hashes {
+		table.Hashes[i] = HashBlock([]byte{byte(i)})
+	}
+
+	path := filepath.Join(t.TempDir(), "table")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := WriteBlockTable(f, 0, table, rs); err != nil {
+		t.Fatalf("WriteBlockTable failed: %v", err)
+	}
+	_ = f.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if int64(len(data)) != BlockTableSize(3) {
+		t.Fatalf("table size = %d; want %d", len(data), BlockTableSize(3))
+	}
+
+	// A few damaged bytes per field are repaired by Reed-Solomon
+	data[0] ^= 0xFF
+	data[BlockCountEncSize+1] ^= 0xFF
+
+	got, err := ReadBlockTable(bytes.NewReader(data), rs)
+	if err != nil {
+		t.Fatalf("ReadBlockTable failed: %v", err)
+	}
+	if !bytes.Equal(got.Digest(), table.Digest()) {
+		t.Error("read table digest differs from written table")
+	}
+
+	if _, err := ReadBlockTable(bytes.NewReader(data[:BlockTableSize(2)]), rs); err == nil {
+		t.Error("expected an error for a truncated table")
+	}
+
+	for i := 0; i < BlockHashEncSize/2; i++ {
+		data[BlockCountEncSize+i] ^= byte(i + 1)
+	}
+	if _, err := ReadBlockTable(bytes.NewReader(data), rs); !errors.Is(err, ErrCorruptedBlockTable) {
+		t.Errorf("expected ErrCorruptedBlockTable, got %v", err)
+	}
+}
+
+func TestV2HeaderMACBlockTable(t *testing.T) {
+	subkey := bytes.Repeat([]byte{0x42}, 64)
+	keyfileHash := make([]byte, KeyfileHashSize)
+	h := &VolumeHeader{
+		Version:   CurrentVersion,
+		Flags:     Flags{BlockHashes: true},
+		Salt:      make([]byte, SaltSize),
+		HKDFSalt:  make([]byte, HKDFSaltSize),
+		SerpentIV: make([]byte, SerpentIVSize),
+		Nonce:     make([]byte, NonceSize),
+	}
+
+	table := NewBlockTable(2)
+	h.BlockTableDigest = table.Digest()
+	mac1 := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
+
+	table.Hashes[1] = HashBlock([]byte("tampered"))
+	h.BlockTableDigest = table.Digest()
+	if bytes.Equal(mac1, ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)) {
+		t.Error("changing a block hash did not change the header MAC")
+	}
+}
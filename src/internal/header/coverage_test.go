@@ -2,6 +2,7 @@ package header
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"testing"
 
@@ -363,13 +364,13 @@ func TestComputeV2HeaderMACRaw(t *testing.T) {
 	}
 
 	// Compute MAC using raw fields
-	macRaw := ComputeV2HeaderMACRaw(subkey, raw, h, keyfileHash)
+	macRaw := ComputeV2HeaderMACRaw(subkey, raw, h, keyfileHash, nil)
 	if len(macRaw) != 64 {
 		t.Errorf("MAC length = %d; want 64", len(macRaw))
 	}
 
 	// Compute MAC using regular method
-	macRegular := ComputeV2HeaderMAC(subkey, h, keyfileHash)
+	macRegular := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
 
 	// Both should produce the same result when raw matches parsed
 	if !bytes.Equal(macRaw, macRegular) {
@@ -399,17 +400,17 @@ func TestVerifyV2HeaderRaw(t *testing.T) {
 	}
 
 	// Set the correct MAC using raw computation
-	h.KeyHash = ComputeV2HeaderMACRaw(subkey, raw, h, keyfileHash)
+	h.KeyHash = ComputeV2HeaderMACRaw(subkey, raw, h, keyfileHash, nil)
 
 	// Verify should pass
-	result := VerifyV2HeaderRaw(subkey, raw, h, keyfileHash)
+	result := VerifyV2HeaderRaw(subkey, raw, h, keyfileHash, nil)
 	if !result.Valid {
 		t.Error("VerifyV2HeaderRaw failed for correct MAC")
 	}
 
 	// Modify raw comments, verify should fail
 	raw.Comments = []byte("Modified")
-	result = VerifyV2HeaderRaw(subkey, raw, h, keyfileHash)
+	result = VerifyV2HeaderRaw(subkey, raw, h, keyfileHash, nil)
 	if result.Valid {
 		t.Error("VerifyV2HeaderRaw passed for modified raw comments")
 	}
@@ -438,10 +439,10 @@ func TestVerifyV2HeaderRawDifferentSubkey(t *testing.T) {
 	}
 
 	// Set MAC with subkey1
-	h.KeyHash = ComputeV2HeaderMACRaw(subkey1, raw, h, keyfileHash)
+	h.KeyHash = ComputeV2HeaderMACRaw(subkey1, raw, h, keyfileHash, nil)
 
 	// Verify with subkey2 should fail
-	result := VerifyV2HeaderRaw(subkey2, raw, h, keyfileHash)
+	result := VerifyV2HeaderRaw(subkey2, raw, h, keyfileHash, nil)
 	if result.Valid {
 		t.Error("VerifyV2HeaderRaw should fail with different subkey")
 	}
@@ -532,6 +533,106 @@ func TestMaxCommentLength(t *testing.T) {
 	}
 }
 
+func TestMaxCommentLengthRoundtrip(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	h := NewVolumeHeader(
+		bytes.Repeat([]byte{0x01}, SaltSize),
+		bytes.Repeat([]byte{0x02}, HKDFSaltSize),
+		bytes.Repeat([]byte{0x03}, SerpentIVSize),
+		bytes.Repeat([]byte{0x04}, NonceSize),
+	)
+	comments := make([]byte, MaxCommentLen)
+	for i := range comments {
+		comments[i] = byte('a' + i%26)
+	}
+	h.Comments = string(comments)
+	h.Flags.Paranoid = true
+
+	var buf bytes.Buffer
+	n, err := NewWriter(&buf, rs).WriteHeader(h)
+	if err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	if n != HeaderSize(MaxCommentLen) {
+		t.Errorf("Wrote %d bytes; want %d", n, HeaderSize(MaxCommentLen))
+	}
+	data := buf.Bytes()
+
+	result, err := NewReader(bytes.NewReader(data), rs).ReadHeader()
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	if result.DecodeError != nil {
+		t.Errorf("Unexpected decode error: %v", result.DecodeError)
+	}
+	if result.Header.Comments != h.Comments {
+		t.Error("Comments were not read back intact")
+	}
+	if !result.Header.Flags.Paranoid {
+		t.Error("Flags after the comments were misread")
+	}
+	if result.BytesRead != len(data) {
+		t.Errorf("BytesRead = %d; want %d", result.BytesRead, len(data))
+	}
+
+	raw, err := NewReader(bytes.NewReader(data), rs).ReadHeaderRaw()
+	if err != nil {
+		t.Fatalf("ReadHeaderRaw failed: %v", err)
+	}
+	if raw.Raw.CommentsLen != MaxCommentLen || !bytes.Equal(raw.Raw.Comments, comments) {
+		t.Errorf("ReadHeaderRaw comments length = %d; want %d", raw.Raw.CommentsLen, MaxCommentLen)
+	}
+}
+
+// The comment length field is five RS-encoded bytes, so a six-digit length
+// cannot be stored at all; anything that decodes to other than five digits
+// must be reported as damaged rather than parsed leniently
+func TestMalformedCommentLength(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	h := NewVolumeHeader(
+		bytes.Repeat([]byte{0x01}, SaltSize),
+		bytes.Repeat([]byte{0x02}, HKDFSaltSize),
+		bytes.Repeat([]byte{0x03}, SerpentIVSize),
+		bytes.Repeat([]byte{0x04}, NonceSize),
+	)
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, rs).WriteHeader(h); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+
+	// The last two are five bytes of NULs and of Arabic-Indic digits
+	for _, field := range []string{"1234a", "-0001", "+1234", " 1234", "1234 ", "12.45", "0x1ff", "\x00\x00\x00\x00\x00", "٠١2"} {
+		data := bytes.Clone(buf.Bytes())
+		copy(data[VersionEncSize:], encoding.Encode(rs.RS5, []byte(field)))
+
+		_, err := NewReader(bytes.NewReader(data), rs).ReadHeader()
+		if !errors.Is(err, ErrInvalidCommentLength) {
+			t.Errorf("ReadHeader(%q) error = %v; want ErrInvalidCommentLength", field, err)
+		}
+		_, err = NewReader(bytes.NewReader(data), rs).ReadHeaderRaw()
+		if !errors.Is(err, ErrInvalidCommentLength) {
+			t.Errorf("ReadHeaderRaw(%q) error = %v; want ErrInvalidCommentLength", field, err)
+		}
+	}
+
+	// A field too damaged for Reed-Solomon is rejected the same way
+	data := bytes.Clone(buf.Bytes())
+	for i := VersionEncSize; i < VersionEncSize+CommentLenEncSize; i++ {
+		data[i] ^= 0xFF
+	}
+	if _, err := NewReader(bytes.NewReader(data), rs).ReadHeader(); !errors.Is(err, ErrInvalidCommentLength) {
+		t.Errorf("ReadHeader of a garbled field error = %v; want ErrInvalidCommentLength", err)
+	}
+}
+
 // =============================================================================
 // Tests for header read/write edge cases
 // =============================================================================
@@ -639,8 +740,13 @@ func TestHeaderReadTruncatedAtVariousPoints(t *testing.T) {
 
 		reader := NewReader(bytes.NewReader(truncated), rs)
 		_, err := reader.ReadHeader()
-		if err == nil && point < BaseHeaderSize {
-			t.Errorf("ReadHeader should fail with truncation at %d bytes", point)
+		if !errors.Is(err, ErrTruncatedHeader) {
+			t.Errorf("truncation at %d bytes: got %v; want ErrTruncatedHeader", point, err)
+		}
+
+		_, err = NewReader(bytes.NewReader(truncated), rs).ReadHeaderRaw()
+		if !errors.Is(err, ErrTruncatedHeader) {
+			t.Errorf("ReadHeaderRaw truncation at %d bytes: got %v; want ErrTruncatedHeader", point, err)
 		}
 	}
 }
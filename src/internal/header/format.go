@@ -2,7 +2,13 @@
 // This is AUDIT-CRITICAL code - changes here directly affect file format compatibility.
 package header
 
-import "Picocrypt-NG/internal/encoding"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"Picocrypt-NG/internal/encoding"
+)
 
 // Version constants
 const (
@@ -48,29 +54,138 @@ func HeaderSize(commentsLen int) int {
 
 // Flags represents the boolean options stored in the volume header
 type Flags struct {
-	Paranoid       bool // flags[0]: Paranoid mode (8 Argon2 passes, HMAC-SHA3)
-	UseKeyfiles    bool // flags[1]: Keyfiles were used for encryption
-	KeyfileOrdered bool // flags[2]: Keyfile order matters
-	ReedSolomon    bool // flags[3]: Full Reed-Solomon encoding on payload
-	Padded         bool // flags[4]: Final block was padded (RS internals)
+	Paranoid       bool  // flags[0]: Paranoid mode (8 Argon2 passes, HMAC-SHA3)
+	UseKeyfiles    bool  // flags[1]: Keyfiles were used for encryption
+	KeyfileOrdered bool  // flags[2]: Keyfile order matters
+	ReedSolomon    bool  // flags[3]: Full Reed-Solomon encoding on payload
+	Padded         bool  // flags[4]: Final block was padded (RS internals)
+	Pepper         bool  // flags[0] bit 7: Password was mixed with an out-of-band pepper
+	Threads        uint8 // flags[0] bits 1-4: Argon2 threads if not the mode default (0 = default)
+	BlockHashes    bool  // flags[0] bit 5: A block hash table follows the header
+	KeyfileBLAKE2b bool  // flags[0] bit 6: Keyfiles were hashed with BLAKE2b-256, not SHA3-256
+	CDCDedup       bool  // flags[3] bit 1: Payload is content-defined chunk records
+	Preview        bool  // flags[1] bit 1: An encrypted preview precedes the payload
+	Passes         uint8 // flags[2] bits 1-7: Argon2 passes if not the mode default (0 = default)
+	Recovery       bool  // flags[1] bit 2: The volume keys are wrapped to a recovery key
+	SplitLayout    bool  // flags[1] bit 3: The chunk layout of a split volume is recorded
+	Trailer        bool  // flags[1] bit 4: A format descriptor trailer follows the payload
+
+	// ExplicitPadding (flags[3] bit 2) replaces the Padded heuristic: flags[4]
+	// then holds PadLen, the number of padding bytes (0-128) on the final
+	// RS128 chunk, and Padded is false.
+	ExplicitPadding bool
+	PadLen          uint8
 }
 
+// pepperBit marks a peppered volume in flags[0], next to Paranoid, so the
+// header layout is unchanged. Older versions misread such a volume as
+// non-paranoid and fail as if the password were wrong.
+const pepperBit = 0x80
+
+// Argon2 thread counts other than the mode default share flags[0] in the
+// same way, as a 4-bit field above the Paranoid bit.
+const (
+	threadsShift = 1
+	threadsMask  = 0x0F << threadsShift
+)
+
+// MaxThreads is the largest Argon2 thread count the header can record.
+const MaxThreads = 0x0F
+
+// Argon2 pass counts other than the mode default fill flags[2] above the
+// KeyfileOrdered bit. Older versions read KeyfileOrdered from the whole
+// byte and derive with the default passes, so they fail as if the password
+// were wrong.
+const passesShift = 1
+
+// MaxPasses is the largest Argon2 pass count the header can record.
+const MaxPasses = 0x7F
+
+// blockHashesBit marks a volume with a block hash table (see blocks.go).
+// Older versions misread it like pepperBit.
+const blockHashesBit = 0x20
+
+// keyfileBLAKE2bBit marks keyfiles hashed with BLAKE2b-256 instead of
+// SHA3-256. Older versions misread it like pepperBit.
+const keyfileBLAKE2bBit = 0x40
+
+// cdcDedupBit marks a payload of content-defined chunk records in flags[3],
+// next to ReedSolomon, which it excludes. Older versions read the volume as
+// a plain payload and fail the MAC check.
+const cdcDedupBit = 0x02
+
+// explicitPaddingBit marks a stored pad length in flags[3], next to
+// ReedSolomon, which is the only mode that pads. Older versions read the
+// volume as not Reed-Solomon encoded and fail the MAC check instead of
+// leaving the padding on the output.
+const explicitPaddingBit = 0x04
+
+// MaxPadLen is the largest PadLen: PKCS#7 pads a full RS128 chunk.
+const MaxPadLen = 128
+
+// previewBit marks a volume with an encrypted preview (see preview.go) in
+// flags[1], next to UseKeyfiles. Older versions read the preview as part of
+// the payload and fail the MAC check.
+const previewBit = 0x02
+
+// recoveryBit marks a volume with a recovery section (see recovery.go) in
+// flags[1]. Older versions misread it like previewBit.
+const recoveryBit = 0x04
+
+// splitLayoutBit marks a volume with a split layout section (see layout.go)
+// in flags[1]. Older versions misread it like previewBit.
+const splitLayoutBit = 0x08
+
+// trailerBit marks a volume with a format descriptor trailer (see
+// trailer.go) in flags[1]. Older versions read the trailer as part of the
+// payload and fail the MAC check.
+const trailerBit = 0x10
+
 // ToBytes converts Flags to 5-byte slice for encoding
 func (f *Flags) ToBytes() []byte {
 	b := make([]byte, 5)
 	if f.Paranoid {
 		b[0] = 1
 	}
+	if f.Pepper {
+		b[0] |= pepperBit
+	}
+	b[0] |= (f.Threads << threadsShift) & threadsMask
+	if f.BlockHashes {
+		b[0] |= blockHashesBit
+	}
+	if f.KeyfileBLAKE2b {
+		b[0] |= keyfileBLAKE2bBit
+	}
 	if f.UseKeyfiles {
 		b[1] = 1
 	}
+	if f.Preview {
+		b[1] |= previewBit
+	}
+	if f.Recovery {
+		b[1] |= recoveryBit
+	}
+	if f.SplitLayout {
+		b[1] |= splitLayoutBit
+	}
+	if f.Trailer {
+		b[1] |= trailerBit
+	}
 	if f.KeyfileOrdered {
 		b[2] = 1
 	}
+	b[2] |= f.Passes << passesShift
 	if f.ReedSolomon {
 		b[3] = 1
 	}
-	if f.Padded {
+	if f.CDCDedup {
+		b[3] |= cdcDedupBit
+	}
+	if f.ExplicitPadding {
+		b[3] |= explicitPaddingBit
+		b[4] = f.PadLen
+	} else if f.Padded {
 		b[4] = 1
 	}
 	return b
@@ -81,13 +196,29 @@ func FlagsFromBytes(b []byte) Flags {
 	if len(b) < 5 {
 		return Flags{}
 	}
-	return Flags{
-		Paranoid:       b[0] == 1,
-		UseKeyfiles:    b[1] == 1,
-		KeyfileOrdered: b[2] == 1,
-		ReedSolomon:    b[3] == 1,
-		Padded:         b[4] == 1,
+	f := Flags{
+		Paranoid:        b[0]&^(pepperBit|threadsMask|blockHashesBit|keyfileBLAKE2bBit) == 1,
+		UseKeyfiles:     b[1]&^(previewBit|recoveryBit|splitLayoutBit|trailerBit) == 1,
+		KeyfileOrdered:  b[2]&1 == 1,
+		ReedSolomon:     b[3]&^(cdcDedupBit|explicitPaddingBit) == 1,
+		Pepper:          b[0]&pepperBit != 0,
+		Threads:         (b[0] & threadsMask) >> threadsShift,
+		BlockHashes:     b[0]&blockHashesBit != 0,
+		KeyfileBLAKE2b:  b[0]&keyfileBLAKE2bBit != 0,
+		CDCDedup:        b[3]&cdcDedupBit != 0,
+		Preview:         b[1]&previewBit != 0,
+		Recovery:        b[1]&recoveryBit != 0,
+		SplitLayout:     b[1]&splitLayoutBit != 0,
+		Trailer:         b[1]&trailerBit != 0,
+		Passes:          b[2] >> passesShift,
+		ExplicitPadding: b[3]&explicitPaddingBit != 0,
 	}
+	if f.ExplicitPadding {
+		f.PadLen = b[4]
+	} else {
+		f.Padded = b[4] == 1
+	}
+	return f
 }
 
 // VolumeHeader contains all header fields for a Picocrypt volume
@@ -107,6 +238,23 @@ type VolumeHeader struct {
 	KeyHash     []byte // 64 bytes - v2: HMAC-SHA3-512 of header; v1: SHA3-512(key)
 	KeyfileHash []byte // 32 bytes - SHA3-256 of keyfile key (or zeros if no keyfiles)
 	AuthTag     []byte // 64 bytes - MAC of ciphertext (BLAKE2b or HMAC-SHA3)
+
+	// BlockTableDigest is BlockTable.Digest() when Flags.BlockHashes is set.
+	// It is not stored in the header itself, only bound into the v2 MAC.
+	BlockTableDigest []byte
+
+	// RecoveryWrap holds the wrapped volume keys when Flags.Recovery is
+	// set. Like the block table it follows the header and is bound into
+	// the v2 MAC.
+	RecoveryWrap []byte
+
+	// SplitLayout is the recorded chunk layout when Flags.SplitLayout is
+	// set. It follows the recovery section and is bound into the v2 MAC.
+	SplitLayout *SplitLayout
+
+	// Trailer is the format descriptor when Flags.Trailer is set. It ends
+	// the volume, after the payload, and is bound into the v2 MAC.
+	Trailer *Trailer
 }
 
 // NewVolumeHeader creates a new header with default values and provided crypto params
@@ -128,6 +276,145 @@ func (h *VolumeHeader) IsLegacyV1() bool {
 	return len(h.Version) >= 2 && h.Version[:2] == "v1"
 }
 
+// Keyfile name hints are appended to the comments field so they are covered
+// by the v2 header MAC without changing the volume layout:
+//
+//	<comments> RS <name> US <name> ...
+//
+// RS (0x1E) and US (0x1F) are the ASCII record and unit separators. Older
+// versions simply show the hints as part of the comments.
+const (
+	keyfileNamesMarker = "\x1e"
+	keyfileNameSep     = "\x1f"
+)
+
+// ErrInvalidKeyfileName is returned when a keyfile name hint cannot be stored.
+var ErrInvalidKeyfileName = errors.New("keyfile name is empty or contains reserved characters")
+
+// ErrReservedComments is returned when user comments contain one of the
+// separators that mark the stored names, which would be misread as one.
+var ErrReservedComments = errors.New("comments contain reserved characters (0x1D-0x1F)")
+
+// ErrCommentsTooLong is returned when the comments, with any stored names,
+// do not fit the five-digit comment length field.
+var ErrCommentsTooLong = errors.New("comments are too long")
+
+// CheckUserComments fails with ErrReservedComments or ErrCommentsTooLong if
+// comments cannot be stored as the user's part of the comments field.
+func CheckUserComments(comments string) error {
+	if strings.ContainsAny(comments, originalNameMarker+keyfileNamesMarker+keyfileNameSep) {
+		return ErrReservedComments
+	}
+	return checkCommentsLen(comments)
+}
+
+// checkCommentsLen fails with ErrCommentsTooLong if the whole encoded
+// comments field is longer than MaxCommentLen.
+func checkCommentsLen(comments string) error {
+	if len(comments) > MaxCommentLen {
+		return fmt.Errorf("%w: %d bytes, at most %d", ErrCommentsTooLong, len(comments), MaxCommentLen)
+	}
+	return nil
+}
+
+// EncodeKeyfileNames appends keyfile basenames to comments for storage in
+// the header. Names must be non-empty and must not contain the separator
+// characters; neither may the user's comments, which may already carry the
+// original name. The result must fit in MaxCommentLen.
+func EncodeKeyfileNames(comments string, names []string) (string, error) {
+	if strings.ContainsAny(comments, keyfileNamesMarker+keyfileNameSep) {
+		return "", ErrReservedComments
+	}
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, keyfileNamesMarker+keyfileNameSep) {
+			return "", ErrInvalidKeyfileName
+		}
+	}
+	encoded := comments + keyfileNamesMarker + strings.Join(names, keyfileNameSep)
+	if err := checkCommentsLen(encoded); err != nil {
+		return "", err
+	}
+	return encoded, nil
+}
+
+// KeyfileNames returns the keyfile name hints stored in the header, or nil
+// if the volume was created without them. The names are only authenticated
+// once the header MAC has been verified.
+func (h *VolumeHeader) KeyfileNames() []string {
+	i := strings.LastIndex(h.Comments, keyfileNamesMarker)
+	if i < 0 || i == len(h.Comments)-1 {
+		return nil
+	}
+	return strings.Split(h.Comments[i+1:], keyfileNameSep)
+}
+
+// UserComments returns the comments with any keyfile name hints and stored
+// original name removed.
+func (h *VolumeHeader) UserComments() string {
+	end := len(h.Comments)
+	if i := strings.LastIndex(h.Comments, keyfileNamesMarker); i >= 0 {
+		end = i
+	}
+	if i := strings.LastIndex(h.Comments[:end], originalNameMarker); i >= 0 {
+		end = i
+	}
+	return h.Comments[:end]
+}
+
+// The original file name is stored the same way, between the comments and
+// any keyfile name hints:
+//
+//	<comments> GS <name> [RS <name> US <name> ...]
+//
+// GS (0x1D) is the ASCII group separator.
+const originalNameMarker = "\x1d"
+
+// ErrInvalidOriginalName is returned when a file name cannot be stored.
+var ErrInvalidOriginalName = errors.New("original name is empty, a path, or contains reserved characters")
+
+// validOriginalName reports whether name is a bare file name that is safe to
+// store and to create next to the volume on decrypt.
+func validOriginalName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, originalNameMarker+keyfileNamesMarker+keyfileNameSep+"/\\\x00")
+}
+
+// EncodeOriginalName appends the original file name to comments for storage
+// in the header. It must be applied before EncodeKeyfileNames, and the
+// result must fit in MaxCommentLen.
+func EncodeOriginalName(comments, name string) (string, error) {
+	if strings.ContainsAny(comments, originalNameMarker+keyfileNamesMarker+keyfileNameSep) {
+		return "", ErrReservedComments
+	}
+	if !validOriginalName(name) {
+		return "", ErrInvalidOriginalName
+	}
+	encoded := comments + originalNameMarker + name
+	if err := checkCommentsLen(encoded); err != nil {
+		return "", err
+	}
+	return encoded, nil
+}
+
+// OriginalName returns the file name stored in the header, or "" if there is
+// none or it is not a bare file name. Like the keyfile names, it is only
+// authenticated once the header MAC has been verified.
+func (h *VolumeHeader) OriginalName() string {
+	end := len(h.Comments)
+	if i := strings.LastIndex(h.Comments, keyfileNamesMarker); i >= 0 {
+		end = i
+	}
+	i := strings.LastIndex(h.Comments[:end], originalNameMarker)
+	if i < 0 {
+		return ""
+	}
+	name := h.Comments[i+1 : end]
+	if !validOriginalName(name) {
+		return ""
+	}
+	return name
+}
+
 // Codecs returns the Reed-Solomon codecs needed for header encoding/decoding
 type Codecs struct {
 	*encoding.RSCodecs
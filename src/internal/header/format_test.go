@@ -2,6 +2,11 @@ package header
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
 
 	"Picocrypt-NG/internal/encoding"
@@ -65,6 +70,98 @@ func TestFlags(t *testing.T) {
 	}
 }
 
+func TestFlagsPepper(t *testing.T) {
+	for _, paranoid := range []bool{false, true} {
+		flags := Flags{Paranoid: paranoid, Pepper: true}
+		parsed := FlagsFromBytes(flags.ToBytes())
+		if parsed != flags {
+			t.Errorf("Pepper round-trip with paranoid=%v: got %+v", paranoid, parsed)
+		}
+	}
+
+	// Volumes without a pepper keep the historical flag bytes
+	if b := (&Flags{Paranoid: true}).ToBytes(); b[0] != 1 {
+		t.Errorf("Paranoid without pepper ToBytes()[0] = %d; want 1", b[0])
+	}
+}
+
+func TestFlagsKeyfileBLAKE2b(t *testing.T) {
+	for _, paranoid := range []bool{false, true} {
+		flags := Flags{Paranoid: paranoid, UseKeyfiles: true, KeyfileBLAKE2b: true, BlockHashes: true}
+		if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+			t.Errorf("KeyfileBLAKE2b round-trip with paranoid=%v: got %+v", paranoid, parsed)
+		}
+	}
+
+	// SHA3 keyfiles keep the historical flag bytes
+	if b := (&Flags{Paranoid: true, UseKeyfiles: true}).ToBytes(); b[0] != 1 {
+		t.Errorf("SHA3 keyfiles ToBytes()[0] = %d; want 1", b[0])
+	}
+}
+
+func TestFlagsCDCDedup(t *testing.T) {
+	for _, rs := range []bool{false, true} {
+		flags := Flags{ReedSolomon: rs, CDCDedup: true}
+		if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+			t.Errorf("CDCDedup round-trip with reedSolomon=%v: got %+v", rs, parsed)
+		}
+	}
+
+	// Volumes without it keep the historical flag bytes
+	if b := (&Flags{ReedSolomon: true}).ToBytes(); b[3] != 1 {
+		t.Errorf("Reed-Solomon without CDC ToBytes()[3] = %d; want 1", b[3])
+	}
+}
+
+func TestFlagsExplicitPadding(t *testing.T) {
+	for _, padLen := range []uint8{0, 1, 127, MaxPadLen} {
+		flags := Flags{ReedSolomon: true, ExplicitPadding: true, PadLen: padLen}
+		if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+			t.Errorf("ExplicitPadding round-trip with padLen=%d: got %+v", padLen, parsed)
+		}
+	}
+
+	// A stored length of 1 is not the legacy Padded flag
+	if parsed := FlagsFromBytes((&Flags{ReedSolomon: true, ExplicitPadding: true, PadLen: 1}).ToBytes()); parsed.Padded {
+		t.Error("explicit PadLen 1 was read as Padded")
+	}
+
+	// Volumes without it keep the historical flag bytes
+	if b := (&Flags{ReedSolomon: true, Padded: true}).ToBytes(); b[3] != 1 || b[4] != 1 {
+		t.Errorf("Padded without ExplicitPadding ToBytes() = %v; want [3]=1 [4]=1", b)
+	}
+}
+
+func TestFlagsPasses(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		for _, passes := range []uint8{1, 9, MaxPasses} {
+			flags := Flags{UseKeyfiles: true, KeyfileOrdered: ordered, Passes: passes}
+			if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+				t.Errorf("Passes round-trip with passes=%d ordered=%v: got %+v", passes, ordered, parsed)
+			}
+		}
+	}
+
+	// Volumes with the default passes keep the historical flag bytes
+	if b := (&Flags{UseKeyfiles: true, KeyfileOrdered: true}).ToBytes(); b[2] != 1 {
+		t.Errorf("ordered keyfiles ToBytes()[2] = %d; want 1", b[2])
+	}
+}
+
+func TestFlagsPreview(t *testing.T) {
+	for _, keyfiles := range []bool{false, true} {
+		flags := Flags{UseKeyfiles: keyfiles, KeyfileOrdered: keyfiles, Preview: true}
+		if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+			t.Errorf("Preview round-trip with keyfiles=%v: got %+v", keyfiles, parsed)
+		}
+	}
+
+	// Volumes without it keep the historical flag bytes
+	if b := (&Flags{UseKeyfiles: true}).ToBytes(); b[1] != 1 {
+		t.Errorf("keyfiles without preview ToBytes()[1] = %d; want 1", b[1])
+	}
+}
+
 func TestFlagsFromBytesShort(t *testing.T) {
 	// Should handle short/nil input gracefully
 	flags := FlagsFromBytes(nil)
@@ -337,27 +434,27 @@ func TestV2HeaderMAC(t *testing.T) {
 	}
 
 	// Compute MAC
-	mac1 := ComputeV2HeaderMAC(subkey, h, keyfileHash)
+	mac1 := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
 	if len(mac1) != 64 {
 		t.Errorf("MAC length = %d; want 64", len(mac1))
 	}
 
 	// Same inputs should produce same MAC
-	mac2 := ComputeV2HeaderMAC(subkey, h, keyfileHash)
+	mac2 := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
 	if !bytes.Equal(mac1, mac2) {
 		t.Error("Same inputs produced different MACs")
 	}
 
 	// Different subkey should produce different MAC
 	differentSubkey := bytes.Repeat([]byte{0x43}, 64)
-	mac3 := ComputeV2HeaderMAC(differentSubkey, h, keyfileHash)
+	mac3 := ComputeV2HeaderMAC(differentSubkey, h, keyfileHash, nil)
 	if bytes.Equal(mac1, mac3) {
 		t.Error("Different subkeys produced same MAC")
 	}
 
 	// Different header field should produce different MAC
 	h.Comments = "Different"
-	mac4 := ComputeV2HeaderMAC(subkey, h, keyfileHash)
+	mac4 := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
 	if bytes.Equal(mac1, mac4) {
 		t.Error("Different comments produced same MAC")
 	}
@@ -399,17 +496,17 @@ func TestVerifyV2Header(t *testing.T) {
 	}
 
 	// Set the correct MAC
-	h.KeyHash = ComputeV2HeaderMAC(subkey, h, keyfileHash)
+	h.KeyHash = ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
 
 	// Verify should pass
-	result := VerifyV2Header(subkey, h, keyfileHash)
+	result := VerifyV2Header(subkey, h, keyfileHash, nil)
 	if !result.Valid {
 		t.Error("VerifyV2Header failed for correct MAC")
 	}
 
 	// Modify header, verify should fail
 	h.Comments = "Modified"
-	result = VerifyV2Header(subkey, h, keyfileHash)
+	result = VerifyV2Header(subkey, h, keyfileHash, nil)
 	if result.Valid {
 		t.Error("VerifyV2Header passed for modified header")
 	}
@@ -485,3 +582,263 @@ func TestAuthErrors(t *testing.T) {
 		t.Errorf("Unexpected error message: %s", kfOrdErr.Error())
 	}
 }
+
+func TestKeyfileNames(t *testing.T) {
+	comments, err := EncodeKeyfileNames("my notes", []string{"token.bin", "photo.jpg"})
+	if err != nil {
+		t.Fatalf("EncodeKeyfileNames failed: %v", err)
+	}
+
+	h := &VolumeHeader{Comments: comments}
+	names := h.KeyfileNames()
+	if len(names) != 2 || names[0] != "token.bin" || names[1] != "photo.jpg" {
+		t.Errorf("KeyfileNames() = %q; want [token.bin photo.jpg]", names)
+	}
+	if h.UserComments() != "my notes" {
+		t.Errorf("UserComments() = %q; want %q", h.UserComments(), "my notes")
+	}
+
+	// Plain comments carry no names
+	plain := &VolumeHeader{Comments: "my notes"}
+	if plain.KeyfileNames() != nil {
+		t.Error("KeyfileNames() should be nil for plain comments")
+	}
+	if plain.UserComments() != "my notes" {
+		t.Errorf("UserComments() = %q; want %q", plain.UserComments(), "my notes")
+	}
+
+	// Reserved characters and empty names are rejected
+	if _, err := EncodeKeyfileNames("", []string{"bad\x1fname"}); err == nil {
+		t.Error("Expected error for name containing separator")
+	}
+	if _, err := EncodeKeyfileNames("", []string{""}); err == nil {
+		t.Error("Expected error for empty name")
+	}
+	if _, err := EncodeKeyfileNames("bad\x1ecomment", []string{"ok"}); err == nil {
+		t.Error("Expected error for comments containing marker")
+	}
+}
+
+func TestOriginalName(t *testing.T) {
+	comments, err := EncodeOriginalName("my notes", "archive.tar.gz")
+	if err != nil {
+		t.Fatalf("EncodeOriginalName failed: %v", err)
+	}
+	comments, err = EncodeKeyfileNames(comments, []string{"token.bin"})
+	if err != nil {
+		t.Fatalf("EncodeKeyfileNames failed: %v", err)
+	}
+
+	h := &VolumeHeader{Comments: comments}
+	if h.OriginalName() != "archive.tar.gz" {
+		t.Errorf("OriginalName() = %q; want %q", h.OriginalName(), "archive.tar.gz")
+	}
+	if names := h.KeyfileNames(); len(names) != 1 || names[0] != "token.bin" {
+		t.Errorf("KeyfileNames() = %q; want [token.bin]", names)
+	}
+	if h.UserComments() != "my notes" {
+		t.Errorf("UserComments() = %q; want %q", h.UserComments(), "my notes")
+	}
+
+	if (&VolumeHeader{Comments: "my notes"}).OriginalName() != "" {
+		t.Error("OriginalName() should be empty for plain comments")
+	}
+
+	// Paths and reserved characters are rejected when storing and ignored when read
+	for _, name := range []string{"", ".", "..", "dir/file", "dir\\file", "bad\x1dname"} {
+		if _, err := EncodeOriginalName("", name); !errors.Is(err, ErrInvalidOriginalName) {
+			t.Errorf("EncodeOriginalName(%q): expected ErrInvalidOriginalName, got %v", name, err)
+		}
+	}
+	if name := (&VolumeHeader{Comments: "\x1d../secret"}).OriginalName(); name != "" {
+		t.Errorf("OriginalName() = %q for a stored path; want empty", name)
+	}
+}
+
+// TestCommentsLimits tests that user comments cannot fake stored names and
+// that the encoded field never outgrows the five-digit length
+func TestCommentsLimits(t *testing.T) {
+	for _, c := range []string{"a\x1db", "a\x1eb", "a\x1fb"} {
+		if err := CheckUserComments(c); !errors.Is(err, ErrReservedComments) {
+			t.Errorf("CheckUserComments(%q): expected ErrReservedComments, got %v", c, err)
+		}
+	}
+	if err := CheckUserComments(strings.Repeat("x", MaxCommentLen)); err != nil {
+		t.Errorf("CheckUserComments at the limit: %v", err)
+	}
+	if err := CheckUserComments(strings.Repeat("x", MaxCommentLen+1)); !errors.Is(err, ErrCommentsTooLong) {
+		t.Errorf("CheckUserComments over the limit: expected ErrCommentsTooLong, got %v", err)
+	}
+
+	// Comments that fit on their own can overflow once the names are added
+	long := strings.Repeat("x", MaxCommentLen-10)
+	if _, err := EncodeOriginalName(long, "archive.tar.gz"); !errors.Is(err, ErrCommentsTooLong) {
+		t.Errorf("EncodeOriginalName: expected ErrCommentsTooLong, got %v", err)
+	}
+	if _, err := EncodeKeyfileNames(long, []string{"token.bin", "photo.jpg"}); !errors.Is(err, ErrCommentsTooLong) {
+		t.Errorf("EncodeKeyfileNames: expected ErrCommentsTooLong, got %v", err)
+	}
+}
+
+// TestParse tests that Parse returns every field and stops exactly at the
+// end of the header
+func TestParse(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	for _, comments := range []string{"", "Parsed comment"} {
+		t.Run(fmt.Sprintf("comments=%q", comments), func(t *testing.T) {
+			original := &VolumeHeader{
+				Version:  CurrentVersion,
+				Comments: comments,
+				Flags: Flags{
+					Paranoid:    true,
+					UseKeyfiles: true,
+					ReedSolomon: true,
+					BlockHashes: true,
+				},
+				Salt:        bytes.Repeat([]byte{0x01}, SaltSize),
+				HKDFSalt:    bytes.Repeat([]byte{0x02}, HKDFSaltSize),
+				SerpentIV:   bytes.Repeat([]byte{0x03}, SerpentIVSize),
+				Nonce:       bytes.Repeat([]byte{0x04}, NonceSize),
+				KeyHash:     bytes.Repeat([]byte{0x05}, KeyHashSize),
+				KeyfileHash: bytes.Repeat([]byte{0x06}, KeyfileHashSize),
+				AuthTag:     bytes.Repeat([]byte{0x07}, AuthTagSize),
+			}
+			var buf bytes.Buffer
+			if _, err := NewWriter(&buf, rs).WriteHeader(original); err != nil {
+				t.Fatalf("WriteHeader failed: %v", err)
+			}
+			data := append(bytes.Clone(buf.Bytes()), "payload"...)
+			err := WriteAuthValues(&bytesWriterAt{buf: data}, AuthValuesOffset(len(comments)),
+				original.KeyHash, original.KeyfileHash, original.AuthTag, rs)
+			if err != nil {
+				t.Fatalf("WriteAuthValues failed: %v", err)
+			}
+
+			r := bytes.NewReader(data)
+			h, n, err := Parse(r, rs)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !reflect.DeepEqual(h, original) {
+				t.Errorf("Parse = %+v; want %+v", h, original)
+			}
+			if n != HeaderSize(len(comments)) {
+				t.Errorf("consumed %d bytes; want %d", n, HeaderSize(len(comments)))
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != "payload" {
+				t.Errorf("Parse read into the payload; %q left", rest)
+			}
+
+			// A field beyond repair still returns the header
+			damaged := bytes.Clone(data)
+			salt := VersionEncSize + CommentLenEncSize + 3*len(comments) + FlagsEncSize
+			for i := range SaltEncSize {
+				damaged[salt+i] = byte(i)
+			}
+			h, n, err = Parse(bytes.NewReader(damaged), rs)
+			if !errors.Is(err, ErrCorruptedHeader) || h == nil {
+				t.Fatalf("damaged salt: got header %v, err %v; want header and ErrCorruptedHeader", h != nil, err)
+			}
+			if !bytes.Equal(h.Nonce, original.Nonce) || n != HeaderSize(len(comments)) {
+				t.Error("fields after the damaged salt were not read")
+			}
+
+			// A truncated header returns nothing
+			h, n, err = Parse(bytes.NewReader(data[:HeaderSize(len(comments))-1]), rs)
+			if !errors.Is(err, ErrTruncatedHeader) || h != nil {
+				t.Errorf("truncated: got header %v, err %v; want ErrTruncatedHeader", h != nil, err)
+			}
+			if n != HeaderSize(len(comments))-1 {
+				t.Errorf("truncated: consumed %d bytes; want %d", n, HeaderSize(len(comments))-1)
+			}
+		})
+	}
+}
+
+// TestWrite tests that Write round-trips through Parse and reports where the
+// auth values are backfilled
+func TestWrite(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	for _, comments := range []string{"", "Written comment"} {
+		t.Run(fmt.Sprintf("comments=%q", comments), func(t *testing.T) {
+			h := NewVolumeHeader(
+				bytes.Repeat([]byte{0x01}, SaltSize),
+				bytes.Repeat([]byte{0x02}, HKDFSaltSize),
+				bytes.Repeat([]byte{0x03}, SerpentIVSize),
+				bytes.Repeat([]byte{0x04}, NonceSize),
+			)
+			h.Comments = comments
+			h.Flags = Flags{ReedSolomon: true, Preview: true}
+
+			var buf bytes.Buffer
+			n, offsets, err := Write(&buf, h, rs)
+			if err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if n != buf.Len() || offsets.End != int64(n) {
+				t.Errorf("wrote %d bytes, buffer has %d, End = %d", n, buf.Len(), offsets.End)
+			}
+			var again bytes.Buffer
+			if _, _, err := Write(&again, h, rs); err != nil || !bytes.Equal(again.Bytes(), buf.Bytes()) {
+				t.Error("Write is not deterministic")
+			}
+
+			// Placeholders read back as zeros
+			got, _, err := Parse(bytes.NewReader(buf.Bytes()), rs)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, h) {
+				t.Errorf("Parse = %+v; want %+v", got, h)
+			}
+
+			// Backfill at the reported offsets
+			h.KeyHash = bytes.Repeat([]byte{0x05}, KeyHashSize)
+			h.KeyfileHash = bytes.Repeat([]byte{0x06}, KeyfileHashSize)
+			h.AuthTag = bytes.Repeat([]byte{0x07}, AuthTagSize)
+			data := buf.Bytes()
+			if err := WriteAuthValues(&bytesWriterAt{buf: data}, offsets.KeyHash, h.KeyHash, h.KeyfileHash, h.AuthTag, rs); err != nil {
+				t.Fatalf("WriteAuthValues failed: %v", err)
+			}
+			fields := []struct {
+				name   string
+				offset int64
+				size   int
+				want   []byte
+			}{
+				{"key hash", offsets.KeyHash, KeyHashEncSize, encoding.Encode(rs.RS64, h.KeyHash)},
+				{"keyfile hash", offsets.KeyfileHash, KeyfileHashEncSize, encoding.Encode(rs.RS32, h.KeyfileHash)},
+				{"auth tag", offsets.AuthTag, AuthTagEncSize, encoding.Encode(rs.RS64, h.AuthTag)},
+			}
+			for _, f := range fields {
+				if !bytes.Equal(data[f.offset:f.offset+int64(f.size)], f.want) {
+					t.Errorf("%s is not at offset %d", f.name, f.offset)
+				}
+			}
+
+			got, _, err = Parse(bytes.NewReader(data), rs)
+			if err != nil {
+				t.Fatalf("Parse after backfill failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, h) {
+				t.Errorf("Parse after backfill = %+v; want %+v", got, h)
+			}
+		})
+	}
+
+	// Oversized comments write nothing
+	h := NewVolumeHeader(make([]byte, SaltSize), make([]byte, HKDFSaltSize), make([]byte, SerpentIVSize), make([]byte, NonceSize))
+	h.Comments = string(make([]byte, MaxCommentLen+1))
+	var buf bytes.Buffer
+	if n, _, err := Write(&buf, h, rs); err == nil || n != 0 || buf.Len() != 0 {
+		t.Errorf("oversized comments: wrote %d bytes, err %v", n, err)
+	}
+}
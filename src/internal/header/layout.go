@@ -0,0 +1,74 @@
+package header
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// Split layout section (only present when Flags.SplitLayout is set). It
+// follows the header, any block table and any recovery section, before the
+// preview:
+//
+//	chunkSize   rs16: 16 -> 48   zero-padded decimal size of every chunk but the last
+//	chunks      rs16: 16 -> 48   zero-padded decimal number of chunks
+//	volumeSize  rs16: 16 -> 48   zero-padded decimal size of the unsplit volume
+//
+// The section is bound into the v2 header MAC, so once the MAC has passed
+// recombining can trust it to tell a stray or missing chunk apart.
+const SplitLayoutEncSize = 3 * 48
+
+// ErrCorruptedSplitLayout indicates the split layout could not be decoded
+var ErrCorruptedSplitLayout = errors.New("split layout is damaged")
+
+// SplitLayout records how a volume was split into chunks
+type SplitLayout struct {
+	ChunkSize  int64 // Size of every chunk but the last, which may be shorter
+	Chunks     int64 // Number of chunks
+	VolumeSize int64 // Size of the unsplit volume, the sum of the chunk sizes
+}
+
+// digits returns the 48 decimal digits stored and bound into the MAC
+func (l *SplitLayout) digits() []byte {
+	return fmt.Appendf(nil, "%016d%016d%016d", l.ChunkSize, l.Chunks, l.VolumeSize)
+}
+
+// WriteSplitLayout writes the encoded layout at offset
+func WriteSplitLayout(w io.WriterAt, offset int64, l *SplitLayout, rs *encoding.RSCodecs) error {
+	digits := l.digits()
+	if len(digits) != 48 {
+		return fmt.Errorf("write split layout: %+v does not fit 16 digits per field", *l)
+	}
+	buf := make([]byte, 0, SplitLayoutEncSize)
+	for i := 0; i < len(digits); i += 16 {
+		buf = append(buf, encoding.Encode(rs.RS16, digits[i:i+16])...)
+	}
+	if _, err := w.WriteAt(buf, offset); err != nil {
+		return fmt.Errorf("write split layout: %w", err)
+	}
+	return nil
+}
+
+// ReadSplitLayout reads an encoded layout from r, which must be positioned
+// directly after the header, any block table and any recovery section.
+func ReadSplitLayout(r io.Reader, rs *encoding.RSCodecs) (*SplitLayout, error) {
+	enc := make([]byte, SplitLayoutEncSize)
+	if _, err := readField(r, "split layout", enc); err != nil {
+		return nil, err
+	}
+	var fields [3]int64
+	for i := range fields {
+		dec, err := encoding.Decode(rs.RS16, enc[i*48:(i+1)*48], false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorruptedSplitLayout, err)
+		}
+		fields[i], err = strconv.ParseInt(string(dec), 10, 64)
+		if err != nil || fields[i] < 0 {
+			return nil, fmt.Errorf("%w: invalid field %q", ErrCorruptedSplitLayout, dec)
+		}
+	}
+	return &SplitLayout{ChunkSize: fields[0], Chunks: fields[1], VolumeSize: fields[2]}, nil
+}
@@ -0,0 +1,85 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+func TestFlagsSplitLayout(t *testing.T) {
+	for _, keyfiles := range []bool{false, true} {
+		flags := Flags{UseKeyfiles: keyfiles, Recovery: true, SplitLayout: true}
+		if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+			t.Errorf("SplitLayout round-trip with keyfiles=%v: got %+v", keyfiles, parsed)
+		}
+	}
+}
+
+func TestSplitLayoutRoundTrip(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	layout := &SplitLayout{ChunkSize: 4 << 30, Chunks: 3, VolumeSize: 9<<30 + 1234}
+	path := filepath.Join(t.TempDir(), "layout")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := WriteSplitLayout(f, 0, layout, rs); err != nil {
+		t.Fatalf("WriteSplitLayout failed: %v", err)
+	}
+	_ = f.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(data) != SplitLayoutEncSize {
+		t.Fatalf("section size = %d; want %d", len(data), SplitLayoutEncSize)
+	}
+	data[0] ^= 0xFF // repaired by Reed-Solomon
+
+	got, err := ReadSplitLayout(bytes.NewReader(data), rs)
+	if err != nil {
+		t.Fatalf("ReadSplitLayout failed: %v", err)
+	}
+	if *got != *layout {
+		t.Errorf("read layout %+v; want %+v", *got, *layout)
+	}
+
+	if _, err := ReadSplitLayout(bytes.NewReader(data[:SplitLayoutEncSize-1]), rs); !errors.Is(err, ErrTruncatedHeader) {
+		t.Errorf("expected ErrTruncatedHeader, got %v", err)
+	}
+	for i := 0; i < 24; i++ {
+		data[48+i] ^= byte(i + 1)
+	}
+	if _, err := ReadSplitLayout(bytes.NewReader(data), rs); !errors.Is(err, ErrCorruptedSplitLayout) {
+		t.Errorf("expected ErrCorruptedSplitLayout, got %v", err)
+	}
+}
+
+func TestV2HeaderMACSplitLayout(t *testing.T) {
+	subkey := bytes.Repeat([]byte{0x42}, 64)
+	keyfileHash := make([]byte, KeyfileHashSize)
+	h := &VolumeHeader{
+		Version:     CurrentVersion,
+		Flags:       Flags{SplitLayout: true},
+		Salt:        make([]byte, SaltSize),
+		HKDFSalt:    make([]byte, HKDFSaltSize),
+		SerpentIV:   make([]byte, SerpentIVSize),
+		Nonce:       make([]byte, NonceSize),
+		SplitLayout: &SplitLayout{ChunkSize: 1024, Chunks: 3, VolumeSize: 3000},
+	}
+	mac1 := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
+
+	h.SplitLayout.Chunks = 4
+	if bytes.Equal(mac1, ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)) {
+		t.Error("changing the chunk count did not change the header MAC")
+	}
+}
@@ -0,0 +1,73 @@
+package header
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// Preview layout (only present when Flags.Preview is set). The preview
+// follows the header and any block table, before the payload, so it can be
+// read without touching the payload:
+//
+//	previewLen  rs16: 16 -> 48   zero-padded decimal length L of the sealed preview
+//	sealed      L bytes          nonce (24) || XChaCha20-Poly1305 ciphertext and tag
+//
+// The sealed bytes are not Reed-Solomon encoded; the AEAD tag detects any
+// damage, which only affects the preview. Its key is derived from the volume
+// key, so a preview is only readable with the volume's credentials.
+const (
+	PreviewLenEncSize = 48
+	PreviewOverhead   = 24 + 16 // XChaCha20 nonce and Poly1305 tag
+
+	// MaxPreviewSize caps the plaintext preview: enough for a thumbnail,
+	// small enough that reading it is always cheap.
+	MaxPreviewSize = 64 << 10
+)
+
+// ErrCorruptedPreview indicates the preview length could not be decoded
+var ErrCorruptedPreview = errors.New("preview is damaged")
+
+// PreviewSize returns the on-disk size of a preview section holding a sealed
+// preview of sealedLen bytes
+func PreviewSize(sealedLen int) int64 {
+	return PreviewLenEncSize + int64(sealedLen)
+}
+
+// WritePreview writes the preview section holding sealed at offset
+func WritePreview(w io.WriterAt, offset int64, sealed []byte, rs *encoding.RSCodecs) error {
+	buf := make([]byte, 0, PreviewSize(len(sealed)))
+	buf = append(buf, encoding.Encode(rs.RS16, []byte(fmt.Sprintf("%016d", len(sealed))))...)
+	buf = append(buf, sealed...)
+	if _, err := w.WriteAt(buf, offset); err != nil {
+		return fmt.Errorf("write preview: %w", err)
+	}
+	return nil
+}
+
+// ReadPreview reads the sealed preview from r, which must be positioned
+// directly after the header and any block table. A length beyond
+// MaxPreviewSize is rejected before anything is allocated.
+func ReadPreview(r io.Reader, rs *encoding.RSCodecs) ([]byte, error) {
+	lenEnc := make([]byte, PreviewLenEncSize)
+	if _, err := readField(r, "preview length", lenEnc); err != nil {
+		return nil, err
+	}
+	lenDec, err := encoding.Decode(rs.RS16, lenEnc, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: preview length: %w", ErrCorruptedPreview, err)
+	}
+	n, err := strconv.Atoi(string(lenDec))
+	if err != nil || n < PreviewOverhead || n > MaxPreviewSize+PreviewOverhead {
+		return nil, fmt.Errorf("%w: invalid preview length", ErrCorruptedPreview)
+	}
+
+	sealed := make([]byte, n)
+	if _, err := readField(r, "preview", sealed); err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
@@ -8,6 +8,8 @@ import (
 	"strconv"
 
 	"Picocrypt-NG/internal/encoding"
+
+	"github.com/Picocrypt/infectious"
 )
 
 // ErrCorruptedHeader indicates the header could not be decoded
@@ -19,6 +21,9 @@ var ErrInvalidVersion = errors.New("invalid version format")
 // ErrInvalidCommentLength indicates the comment length field is corrupted
 var ErrInvalidCommentLength = errors.New("unable to read comments length")
 
+// ErrTruncatedHeader indicates the input ended before the complete header
+var ErrTruncatedHeader = errors.New("volume is shorter than its header")
+
 // Reader handles reading volume headers from an input stream
 type Reader struct {
 	r  io.Reader
@@ -37,183 +42,40 @@ type ReadResult struct {
 	BytesRead   int   // Total bytes consumed from the reader
 }
 
-// ReadHeader reads and decodes a complete volume header.
-// Returns the header even if RS decode errors occur (for force-decrypt scenarios).
-// The DecodeError field will be set if any corruption was detected.
-func (r *Reader) ReadHeader() (*ReadResult, error) {
-	result := &ReadResult{
-		Header: &VolumeHeader{},
-	}
-	h := result.Header
-	var decodeErrors []error
-
-	// Read version (15 bytes -> 5 bytes)
-	versionEnc := make([]byte, VersionEncSize)
-	n, err := io.ReadFull(r.r, versionEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read version: %w", err)
-	}
-
-	versionDec, err := encoding.Decode(r.rs.RS5, versionEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-	h.Version = string(versionDec)
-
-	// Validate version format
-	if valid, _ := regexp.Match(`^v\d\.\d{2}$`, versionDec); !valid {
-		return result, ErrInvalidVersion
-	}
-
-	// Read comment length (15 bytes -> 5 bytes)
-	commentLenEnc := make([]byte, CommentLenEncSize)
-	n, err = io.ReadFull(r.r, commentLenEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read comment length: %w", err)
-	}
-
-	commentLenDec, err := encoding.Decode(r.rs.RS5, commentLenEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-
-	// Validate comment length format (5 digits)
-	if valid, _ := regexp.Match(`^\d{5}$`, commentLenDec); !valid {
-		return result, ErrInvalidCommentLength
-	}
-
-	commentsLen, _ := strconv.Atoi(string(commentLenDec))
-
-	// Read comments (each byte is rs1 encoded: 3 bytes -> 1 byte)
-	comments := make([]byte, 0, commentsLen)
-	for i := 0; i < commentsLen; i++ {
-		cEnc := make([]byte, 3) // rs1: 1 -> 3
-		n, err = io.ReadFull(r.r, cEnc)
-		result.BytesRead += n
-		if err != nil {
-			return result, fmt.Errorf("read comment char %d: %w", i, err)
-		}
-
-		cDec, err := encoding.Decode(r.rs.RS1, cEnc, false)
-		if err != nil {
-			decodeErrors = append(decodeErrors, err)
-		}
-		comments = append(comments, cDec...)
-	}
-	h.Comments = string(comments)
-
-	// Read flags (15 bytes -> 5 bytes)
-	flagsEnc := make([]byte, FlagsEncSize)
-	n, err = io.ReadFull(r.r, flagsEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read flags: %w", err)
-	}
-
-	flagsDec, err := encoding.Decode(r.rs.RS5, flagsEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-	h.Flags = FlagsFromBytes(flagsDec)
-
-	// Read salt (48 bytes -> 16 bytes)
-	saltEnc := make([]byte, SaltEncSize)
-	n, err = io.ReadFull(r.r, saltEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read salt: %w", err)
-	}
-
-	h.Salt, err = encoding.Decode(r.rs.RS16, saltEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-
-	// Read HKDF salt (96 bytes -> 32 bytes)
-	hkdfSaltEnc := make([]byte, HKDFSaltEncSize)
-	n, err = io.ReadFull(r.r, hkdfSaltEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read hkdf salt: %w", err)
-	}
-
-	h.HKDFSalt, err = encoding.Decode(r.rs.RS32, hkdfSaltEnc, false)
+// Parse reads a complete volume header from r and returns it with the
+// number of bytes consumed, which is HeaderSize(len(h.Comments)) on success.
+// v1 and v2 headers share the layout, so both are parsed here; see
+// VolumeHeader.IsLegacyV1.
+//
+// I/O errors, a truncated header (ErrTruncatedHeader), an invalid version
+// (ErrInvalidVersion) or comment length (ErrInvalidCommentLength) return a
+// nil header. If only Reed-Solomon decoding failed, the best-effort header is
+// returned with ErrCorruptedHeader, for force-decrypt scenarios.
+func Parse(r io.Reader, rs *encoding.RSCodecs) (*VolumeHeader, int, error) {
+	p := &headerParser{r: r, rs: rs, checkVersion: true}
+	h, _, err := p.parse()
 	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+		return nil, p.n, err
 	}
-
-	// Read Serpent IV (48 bytes -> 16 bytes)
-	serpentIVEnc := make([]byte, SerpentIVEncSize)
-	n, err = io.ReadFull(r.r, serpentIVEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read serpent iv: %w", err)
-	}
-
-	h.SerpentIV, err = encoding.Decode(r.rs.RS16, serpentIVEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-
-	// Read nonce (72 bytes -> 24 bytes)
-	nonceEnc := make([]byte, NonceEncSize)
-	n, err = io.ReadFull(r.r, nonceEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read nonce: %w", err)
-	}
-
-	h.Nonce, err = encoding.Decode(r.rs.RS24, nonceEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-
-	// Read key hash (192 bytes -> 64 bytes)
-	keyHashEnc := make([]byte, KeyHashEncSize)
-	n, err = io.ReadFull(r.r, keyHashEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read key hash: %w", err)
-	}
-
-	h.KeyHash, err = encoding.Decode(r.rs.RS64, keyHashEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-
-	// Read keyfile hash (96 bytes -> 32 bytes)
-	keyfileHashEnc := make([]byte, KeyfileHashEncSize)
-	n, err = io.ReadFull(r.r, keyfileHashEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read keyfile hash: %w", err)
-	}
-
-	h.KeyfileHash, err = encoding.Decode(r.rs.RS32, keyfileHashEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
-
-	// Read auth tag (192 bytes -> 64 bytes)
-	authTagEnc := make([]byte, AuthTagEncSize)
-	n, err = io.ReadFull(r.r, authTagEnc)
-	result.BytesRead += n
-	if err != nil {
-		return result, fmt.Errorf("read auth tag: %w", err)
+	if p.decodeErrors > 0 {
+		return h, p.n, ErrCorruptedHeader
 	}
+	return h, p.n, nil
+}
 
-	h.AuthTag, err = encoding.Decode(r.rs.RS64, authTagEnc, false)
+// ReadHeader reads and decodes a complete volume header.
+// Returns the header even if RS decode errors occur (for force-decrypt scenarios).
+// The DecodeError field will be set if any corruption was detected.
+func (r *Reader) ReadHeader() (*ReadResult, error) {
+	p := &headerParser{r: r.r, rs: r.rs, checkVersion: true}
+	h, _, err := p.parse()
+	result := &ReadResult{Header: h, BytesRead: p.n}
 	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+		return result, err
 	}
-
-	// Set combined decode error if any occurred
-	if len(decodeErrors) > 0 {
+	if p.decodeErrors > 0 {
 		result.DecodeError = ErrCorruptedHeader
 	}
-
 	return result, nil
 }
 
@@ -222,8 +84,8 @@ func (r *Reader) ReadHeader() (*ReadResult, error) {
 // Returns the version string and any error.
 func PeekVersion(r io.Reader, rs *encoding.RSCodecs) (string, error) {
 	versionEnc := make([]byte, VersionEncSize)
-	if _, err := io.ReadFull(r, versionEnc); err != nil {
-		return "", fmt.Errorf("read version: %w", err)
+	if _, err := readField(r, "version", versionEnc); err != nil {
+		return "", err
 	}
 
 	versionDec, err := encoding.Decode(rs.RS5, versionEnc, false)
@@ -252,142 +114,160 @@ type ReadHeaderRawResult struct {
 
 // ReadHeaderRaw reads header fields and returns raw bytes for MAC computation.
 // This is needed for v2 header authentication where we MAC the decoded field values.
+// Unlike Parse, a damaged version, comment length or flags field is an error,
+// since the MAC would be computed over the wrong bytes.
 // Returns the header even if RS decode errors occur (for force-decrypt scenarios).
 // The DecodeError field will be set if any corruption was detected.
 func (r *Reader) ReadHeaderRaw() (*ReadHeaderRawResult, error) {
-	result := &ReadHeaderRawResult{
-		Raw:    &RawHeaderFields{},
-		Header: &VolumeHeader{},
+	p := &headerParser{r: r.r, rs: r.rs, strict: true}
+	h, raw, err := p.parse()
+	if err != nil {
+		return nil, err
 	}
-	raw := result.Raw
-	h := result.Header
-	var decodeErrors []error
-
-	// Read version
-	versionEnc := make([]byte, VersionEncSize)
-	if _, err := io.ReadFull(r.r, versionEnc); err != nil {
-		return nil, fmt.Errorf("read version: %w", err)
+	if p.commentsDamaged {
+		h.Comments = "Comments are corrupted"
 	}
-	versionDec, err := encoding.Decode(r.rs.RS5, versionEnc, false)
-	if err != nil {
-		return nil, fmt.Errorf("decode version: %w", err)
+	result := &ReadHeaderRawResult{Raw: raw, Header: h}
+	if p.decodeErrors > 0 {
+		result.DecodeError = ErrCorruptedHeader
 	}
-	raw.Version = versionDec
-	h.Version = string(versionDec)
+	return result, nil
+}
 
-	// Read comment length
-	commentLenEnc := make([]byte, CommentLenEncSize)
-	if _, err := io.ReadFull(r.r, commentLenEnc); err != nil {
-		return nil, fmt.Errorf("read comment length: %w", err)
-	}
-	commentLenDec, err := encoding.Decode(r.rs.RS5, commentLenEnc, false)
+// headerParser reads the header fields in order. It is the one parser
+// behind Parse, ReadHeader and ReadHeaderRaw.
+type headerParser struct {
+	r  io.Reader
+	rs *encoding.RSCodecs
+	n  int // Bytes consumed so far
+
+	// checkVersion rejects a version that is not vN.NN. strict fails on a
+	// damaged version, comment length or flags field instead of recording it.
+	checkVersion bool
+	strict       bool
+
+	decodeErrors    int  // Fields that failed to decode
+	commentsDamaged bool // Some comment byte failed to decode
+}
+
+// field reads size encoded bytes and decodes them with fec. A decoding
+// failure of a metadata field is fatal in strict mode, otherwise it is
+// counted and the best-effort bytes are returned.
+func (p *headerParser) field(name string, size int, fec *infectious.FEC, meta bool) ([]byte, error) {
+	enc := make([]byte, size)
+	n, err := readField(p.r, name, enc)
+	p.n += n
 	if err != nil {
-		return nil, fmt.Errorf("decode comment length: %w", err)
+		return nil, err
 	}
-	commentsLen, _ := strconv.Atoi(string(commentLenDec))
-	raw.CommentsLen = commentsLen
-
-	// Read comments (track corruption but continue reading)
-	raw.Comments = make([]byte, 0, commentsLen)
-	commentsCorrupted := false
-	for i := 0; i < commentsLen; i++ {
-		cEnc := make([]byte, 3)
-		if _, err := io.ReadFull(r.r, cEnc); err != nil {
-			return nil, fmt.Errorf("read comment char %d: %w", i, err)
-		}
-		cDec, err := encoding.Decode(r.rs.RS1, cEnc, false)
-		if err != nil {
-			commentsCorrupted = true
-			decodeErrors = append(decodeErrors, err)
+	dec, err := encoding.Decode(fec, enc, false)
+	if err != nil {
+		if meta && p.strict {
+			return nil, fmt.Errorf("decode %s: %w", name, err)
 		}
-		raw.Comments = append(raw.Comments, cDec...)
-	}
-	if commentsCorrupted {
-		h.Comments = "Comments are corrupted"
-	} else {
-		h.Comments = string(raw.Comments)
+		p.decodeErrors++
 	}
+	return dec, nil
+}
 
-	// Read flags
-	flagsEnc := make([]byte, FlagsEncSize)
-	if _, err := io.ReadFull(r.r, flagsEnc); err != nil {
-		return nil, fmt.Errorf("read flags: %w", err)
-	}
-	flagsDec, err := encoding.Decode(r.rs.RS5, flagsEnc, false)
-	if err != nil {
-		return nil, fmt.Errorf("decode flags: %w", err)
-	}
-	raw.Flags = flagsDec
-	h.Flags = FlagsFromBytes(flagsDec)
+// parse reads every header field. On error the header and raw fields hold
+// whatever was read before it.
+func (p *headerParser) parse() (*VolumeHeader, *RawHeaderFields, error) {
+	h := &VolumeHeader{}
+	raw := &RawHeaderFields{}
+	var err error
 
-	// Read remaining crypto fields (collect errors but continue for force-decrypt)
-	saltEnc := make([]byte, SaltEncSize)
-	if _, err := io.ReadFull(r.r, saltEnc); err != nil {
-		return nil, fmt.Errorf("read salt: %w", err)
+	// Version (15 bytes -> 5 bytes)
+	if raw.Version, err = p.field("version", VersionEncSize, p.rs.RS5, true); err != nil {
+		return h, raw, err
 	}
-	h.Salt, err = encoding.Decode(r.rs.RS16, saltEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+	h.Version = string(raw.Version)
+	if p.checkVersion && !versionPattern.Match(raw.Version) {
+		return h, raw, ErrInvalidVersion
 	}
 
-	hkdfSaltEnc := make([]byte, HKDFSaltEncSize)
-	if _, err := io.ReadFull(r.r, hkdfSaltEnc); err != nil {
-		return nil, fmt.Errorf("read hkdf salt: %w", err)
-	}
-	h.HKDFSalt, err = encoding.Decode(r.rs.RS32, hkdfSaltEnc, false)
+	// Comment length (15 bytes -> 5 bytes)
+	commentLenDec, err := p.field("comment length", CommentLenEncSize, p.rs.RS5, true)
 	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+		return h, raw, err
 	}
-
-	serpentIVEnc := make([]byte, SerpentIVEncSize)
-	if _, err := io.ReadFull(r.r, serpentIVEnc); err != nil {
-		return nil, fmt.Errorf("read serpent iv: %w", err)
-	}
-	h.SerpentIV, err = encoding.Decode(r.rs.RS16, serpentIVEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+	if raw.CommentsLen, err = ParseCommentsLen(commentLenDec); err != nil {
+		return h, raw, err
 	}
 
-	nonceEnc := make([]byte, NonceEncSize)
-	if _, err := io.ReadFull(r.r, nonceEnc); err != nil {
-		return nil, fmt.Errorf("read nonce: %w", err)
-	}
-	h.Nonce, err = encoding.Decode(r.rs.RS24, nonceEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+	// Comments (each byte is rs1 encoded: 3 bytes -> 1 byte)
+	raw.Comments = make([]byte, 0, raw.CommentsLen)
+	for range raw.CommentsLen {
+		before := p.decodeErrors
+		c, err := p.field("comments", 3, p.rs.RS1, false)
+		if err != nil {
+			return h, raw, err
+		}
+		p.commentsDamaged = p.commentsDamaged || p.decodeErrors > before
+		raw.Comments = append(raw.Comments, c...)
+	}
+	h.Comments = string(raw.Comments)
+
+	// Flags (15 bytes -> 5 bytes)
+	if raw.Flags, err = p.field("flags", FlagsEncSize, p.rs.RS5, true); err != nil {
+		return h, raw, err
+	}
+	h.Flags = FlagsFromBytes(raw.Flags)
+
+	// Cryptographic fields and authentication values
+	fields := []struct {
+		name string
+		size int
+		fec  *infectious.FEC
+		dst  *[]byte
+	}{
+		{"salt", SaltEncSize, p.rs.RS16, &h.Salt},                 // 48 -> 16
+		{"hkdf salt", HKDFSaltEncSize, p.rs.RS32, &h.HKDFSalt},    // 96 -> 32
+		{"serpent iv", SerpentIVEncSize, p.rs.RS16, &h.SerpentIV}, // 48 -> 16
+		{"nonce", NonceEncSize, p.rs.RS24, &h.Nonce},              // 72 -> 24
+		{"key hash", KeyHashEncSize, p.rs.RS64, &h.KeyHash},       // 192 -> 64
+		{"keyfile hash", KeyfileHashEncSize, p.rs.RS32, &h.KeyfileHash},
+		{"auth tag", AuthTagEncSize, p.rs.RS64, &h.AuthTag},
+	}
+	for _, f := range fields {
+		if *f.dst, err = p.field(f.name, f.size, f.fec, false); err != nil {
+			return h, raw, err
+		}
 	}
+	return h, raw, nil
+}
 
-	keyHashEnc := make([]byte, KeyHashEncSize)
-	if _, err := io.ReadFull(r.r, keyHashEnc); err != nil {
-		return nil, fmt.Errorf("read key hash: %w", err)
-	}
-	h.KeyHash, err = encoding.Decode(r.rs.RS64, keyHashEnc, false)
-	if err != nil {
-		decodeErrors = append(decodeErrors, err)
-	}
+// versionPattern is the accepted form of the decoded version field
+var versionPattern = regexp.MustCompile(`^v\d\.\d{2}$`)
 
-	keyfileHashEnc := make([]byte, KeyfileHashEncSize)
-	if _, err := io.ReadFull(r.r, keyfileHashEnc); err != nil {
-		return nil, fmt.Errorf("read keyfile hash: %w", err)
+// commentsLenPattern is the only accepted form of the comment length: five
+// ASCII digits, zero-padded, as written by %05d. Signs, spaces and anything
+// longer or shorter mean the field is damaged, not a different length.
+var commentsLenPattern = regexp.MustCompile(`^\d{5}$`)
+
+// ParseCommentsLen parses a decoded comment length field, returning
+// ErrInvalidCommentLength unless it is five digits. The result is always
+// between 0 and MaxCommentLen.
+func ParseCommentsLen(dec []byte) (int, error) {
+	if !commentsLenPattern.Match(dec) {
+		return 0, ErrInvalidCommentLength
 	}
-	h.KeyfileHash, err = encoding.Decode(r.rs.RS32, keyfileHashEnc, false)
+	n, err := strconv.Atoi(string(dec))
 	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+		return 0, ErrInvalidCommentLength
 	}
+	return n, nil
+}
 
-	authTagEnc := make([]byte, AuthTagEncSize)
-	if _, err := io.ReadFull(r.r, authTagEnc); err != nil {
-		return nil, fmt.Errorf("read auth tag: %w", err)
+// readField fills buf from r. A short read means the file ends inside the
+// header and is reported as ErrTruncatedHeader rather than a bare EOF.
+func readField(r io.Reader, name string, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, fmt.Errorf("%w: %s cut short", ErrTruncatedHeader, name)
 	}
-	h.AuthTag, err = encoding.Decode(r.rs.RS64, authTagEnc, false)
 	if err != nil {
-		decodeErrors = append(decodeErrors, err)
+		return n, fmt.Errorf("read %s: %w", name, err)
 	}
-
-	// Set combined decode error if any occurred
-	if len(decodeErrors) > 0 {
-		result.DecodeError = ErrCorruptedHeader
-	}
-
-	return result, nil
+	return n, nil
 }
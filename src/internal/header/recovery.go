@@ -0,0 +1,58 @@
+package header
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// Recovery section layout (only present when Flags.Recovery is set). It
+// follows the header and any block table, before the preview:
+//
+//	wrapped  7 x rs16: 112 -> 336   ephemeral X25519 public key (32) || sealed volume keys (64) and tag (16)
+//
+// The section is Reed-Solomon encoded like the header because the wrapped
+// keys are bound into the v2 header MAC: damage beyond repair fails the
+// volume as a damaged header, not just its recovery.
+const (
+	RecoveryWrapSize = 112
+	RecoveryEncSize  = RecoveryWrapSize / 16 * 48
+)
+
+// ErrCorruptedRecovery indicates the recovery section could not be decoded
+var ErrCorruptedRecovery = errors.New("recovery section is damaged")
+
+// WriteRecovery writes the recovery section holding wrapped at offset
+func WriteRecovery(w io.WriterAt, offset int64, wrapped []byte, rs *encoding.RSCodecs) error {
+	if len(wrapped) != RecoveryWrapSize {
+		return fmt.Errorf("write recovery section: wrapped keys are %d bytes, not %d", len(wrapped), RecoveryWrapSize)
+	}
+	buf := make([]byte, 0, RecoveryEncSize)
+	for i := 0; i < RecoveryWrapSize; i += 16 {
+		buf = append(buf, encoding.Encode(rs.RS16, wrapped[i:i+16])...)
+	}
+	if _, err := w.WriteAt(buf, offset); err != nil {
+		return fmt.Errorf("write recovery section: %w", err)
+	}
+	return nil
+}
+
+// ReadRecovery reads the wrapped keys from r, which must be positioned
+// directly after the header and any block table.
+func ReadRecovery(r io.Reader, rs *encoding.RSCodecs) ([]byte, error) {
+	enc := make([]byte, RecoveryEncSize)
+	if _, err := readField(r, "recovery section", enc); err != nil {
+		return nil, err
+	}
+	wrapped := make([]byte, 0, RecoveryWrapSize)
+	for i := 0; i < RecoveryEncSize; i += 48 {
+		dec, err := encoding.Decode(rs.RS16, enc[i:i+48], false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorruptedRecovery, err)
+		}
+		wrapped = append(wrapped, dec...)
+	}
+	return wrapped, nil
+}
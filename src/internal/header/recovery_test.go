@@ -0,0 +1,95 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+func TestFlagsRecovery(t *testing.T) {
+	for _, keyfiles := range []bool{false, true} {
+		flags := Flags{UseKeyfiles: keyfiles, Preview: true, Recovery: true}
+		if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+			t.Errorf("Recovery round-trip with keyfiles=%v: got %+v", keyfiles, parsed)
+		}
+	}
+}
+
+func TestRecoveryRoundTrip(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	wrapped := make([]byte, RecoveryWrapSize)
+	for i := range wrapped {
+		wrapped[i] = byte(i * 7)
+	}
+	path := filepath.Join(t.TempDir(), "recovery")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := WriteRecovery(f, 0, wrapped, rs); err != nil {
+		t.Fatalf("WriteRecovery failed: %v", err)
+	}
+	if err := WriteRecovery(f, 0, wrapped[:RecoveryWrapSize-1], rs); err == nil {
+		t.Error("expected an error for short wrapped keys")
+	}
+	_ = f.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(data) != RecoveryEncSize {
+		t.Fatalf("section size = %d; want %d", len(data), RecoveryEncSize)
+	}
+
+	// A few damaged bytes are repaired by Reed-Solomon
+	data[0] ^= 0xFF
+	data[RecoveryEncSize-1] ^= 0xFF
+
+	got, err := ReadRecovery(bytes.NewReader(data), rs)
+	if err != nil {
+		t.Fatalf("ReadRecovery failed: %v", err)
+	}
+	if !bytes.Equal(got, wrapped) {
+		t.Error("read wrapped keys differ from written ones")
+	}
+
+	if _, err := ReadRecovery(bytes.NewReader(data[:RecoveryEncSize-1]), rs); !errors.Is(err, ErrTruncatedHeader) {
+		t.Errorf("expected ErrTruncatedHeader, got %v", err)
+	}
+
+	for i := 0; i < 24; i++ {
+		data[48+i] ^= byte(i + 1)
+	}
+	if _, err := ReadRecovery(bytes.NewReader(data), rs); !errors.Is(err, ErrCorruptedRecovery) {
+		t.Errorf("expected ErrCorruptedRecovery, got %v", err)
+	}
+}
+
+func TestV2HeaderMACRecovery(t *testing.T) {
+	subkey := bytes.Repeat([]byte{0x42}, 64)
+	keyfileHash := make([]byte, KeyfileHashSize)
+	h := &VolumeHeader{
+		Version:      CurrentVersion,
+		Flags:        Flags{Recovery: true},
+		Salt:         make([]byte, SaltSize),
+		HKDFSalt:     make([]byte, HKDFSaltSize),
+		SerpentIV:    make([]byte, SerpentIVSize),
+		Nonce:        make([]byte, NonceSize),
+		RecoveryWrap: make([]byte, RecoveryWrapSize),
+	}
+	mac1 := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
+
+	h.RecoveryWrap[RecoveryWrapSize-1] = 1
+	if bytes.Equal(mac1, ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)) {
+		t.Error("changing the wrapped keys did not change the header MAC")
+	}
+}
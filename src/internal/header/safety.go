@@ -0,0 +1,55 @@
+package header
+
+import (
+	"encoding/base32"
+	"fmt"
+	"os"
+
+	"Picocrypt-NG/internal/encoding"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// safetyCodeDomain separates safety code hashes from every other use of the
+// header's random fields.
+const safetyCodeDomain = "picocrypt safety code v1"
+
+// SafetyCode returns a short code identifying the volume, formatted as two
+// groups of four base32 characters, e.g. "K3QF-7ZMA". It is derived only
+// from the random salts and nonce written at encryption time, so it stays
+// the same for the life of the volume and almost surely differs between
+// volumes, but it is neither secret nor a check on the contents: noting it
+// when encrypting lets a user confirm later that a file is the one they
+// meant, before typing a password into it.
+func (h *VolumeHeader) SafetyCode() string {
+	d := sha3.New256()
+	d.Write([]byte(safetyCodeDomain))
+	d.Write(h.Salt)
+	d.Write(h.HKDFSalt)
+	d.Write(h.SerpentIV)
+	d.Write(h.Nonce)
+	code := base32.StdEncoding.EncodeToString(d.Sum(nil)[:5]) // 40 bits, 8 chars
+	return code[:4] + "-" + code[4:]
+}
+
+// SafetyCode reads the header of the volume at path, or of the first chunk
+// of a split volume, and returns its VolumeHeader.SafetyCode. A damaged
+// header is refused rather than yielding a code that matches nothing, and
+// deniable volumes have no readable header to derive one from.
+func SafetyCode(path string) (string, error) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h, _, err := Parse(f, rs)
+	if err != nil {
+		return "", fmt.Errorf("safety code: %w", err)
+	}
+	return h.SafetyCode(), nil
+}
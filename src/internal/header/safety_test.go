@@ -0,0 +1,74 @@
+package header
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+func TestSafetyCode(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+	dir := t.TempDir()
+
+	writeVolume := func(name string, fill byte, comments string) string {
+		h := NewVolumeHeader(
+			bytes.Repeat([]byte{fill}, SaltSize),
+			bytes.Repeat([]byte{fill + 1}, HKDFSaltSize),
+			bytes.Repeat([]byte{fill + 2}, SerpentIVSize),
+			bytes.Repeat([]byte{fill + 3}, NonceSize),
+		)
+		h.Comments = comments
+		var buf bytes.Buffer
+		if _, err := NewWriter(&buf, rs).WriteHeader(h); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		buf.WriteString("payload")
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	code := func(path string) string {
+		c, err := SafetyCode(path)
+		if err != nil {
+			t.Fatalf("SafetyCode(%s) failed: %v", filepath.Base(path), err)
+		}
+		return c
+	}
+
+	a := code(writeVolume("a.pcv", 0x10, ""))
+	if !regexp.MustCompile(`^[A-Z2-7]{4}-[A-Z2-7]{4}$`).MatchString(a) {
+		t.Errorf("SafetyCode = %q; want XXXX-XXXX in base32", a)
+	}
+	if again := code(filepath.Join(dir, "a.pcv")); again != a {
+		t.Errorf("SafetyCode not stable: %q then %q", a, again)
+	}
+
+	// Only the random fields count, not the comments
+	if c := code(writeVolume("a-comment.pcv", 0x10, "renamed")); c != a {
+		t.Errorf("comments changed the safety code: %q vs %q", c, a)
+	}
+	if b := code(writeVolume("b.pcv", 0x20, "")); b == a {
+		t.Errorf("different volumes share the safety code %q", a)
+	}
+
+	notVolume := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notVolume, []byte("not a volume"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SafetyCode(notVolume); err == nil {
+		t.Error("SafetyCode accepted a file that is not a volume")
+	}
+	if _, err := SafetyCode(filepath.Join(dir, "missing.pcv")); err == nil {
+		t.Error("SafetyCode accepted a missing file")
+	}
+}
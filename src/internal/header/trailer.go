@@ -0,0 +1,262 @@
+package header
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// Format descriptor trailer (only present when Flags.Trailer is set). It
+// ends the volume, after the payload, so a reader finds it TrailerEncSize
+// bytes before the end of the file without knowing any other offset:
+//
+//	fields  32 x rs16: 16 -> 48   offset, then size, of each TrailerField in order
+//	codecs   4 x rs16: 16 -> 48   4 digits per TrailerField: its Codec
+//	memory       rs16: 16 -> 48   Argon2 memory in KiB
+//	params       rs16: 16 -> 48   passes(4) threads(4) cipher(2) mac(2) keyfileHash(2) rsVersion(2)
+//	magic        rs16: 16 -> 48   trailerMagic
+//
+// All numbers are zero-padded decimal. The trailer is bound into the v2
+// header MAC, so once the MAC has passed a reader can trust it to describe
+// the volume instead of relying on the offsets compiled into this package.
+const (
+	trailerSlots   = 2*16 + 4 + 3 // fields, codecs, memory, params, magic
+	TrailerEncSize = trailerSlots * 48
+)
+
+// trailerMagic ends every trailer and names its own layout, so a later
+// layout can be told apart by its magic.
+const trailerMagic = "PCV-TRAILER-v001"
+
+// ErrCorruptedTrailer indicates the trailer could not be decoded
+var ErrCorruptedTrailer = errors.New("format trailer is damaged")
+
+// TrailerField identifies a part of the volume described by the trailer
+type TrailerField int
+
+// Trailer fields, in file order
+const (
+	FieldVersion TrailerField = iota
+	FieldCommentsLen
+	FieldComments
+	FieldFlags
+	FieldSalt
+	FieldHKDFSalt
+	FieldSerpentIV
+	FieldNonce
+	FieldKeyHash
+	FieldKeyfileHash
+	FieldAuthTag
+	FieldBlockTable
+	FieldRecovery
+	FieldSplitLayout
+	FieldPreview
+	FieldPayload
+
+	TrailerFields // Number of fields
+)
+
+var trailerFieldNames = [TrailerFields]string{
+	"version", "comments length", "comments", "flags", "salt", "HKDF salt",
+	"Serpent IV", "nonce", "key hash", "keyfile hash", "auth tag",
+	"block table", "recovery", "split layout", "preview", "payload",
+}
+
+func (f TrailerField) String() string {
+	if f < 0 || f >= TrailerFields {
+		return fmt.Sprintf("field %d", int(f))
+	}
+	return trailerFieldNames[f]
+}
+
+// FieldLocation is where a field is stored and how it is encoded
+type FieldLocation struct {
+	Offset int64 // File offset of the first byte
+	Size   int64 // Stored size in bytes; 0 if the volume has no such section
+
+	// Codec is the data size of the Reed-Solomon code the field is stored
+	// in, as passed to encoding.RSCodecs.Codec (5 for rs5: 5 -> 15), or 0
+	// for bytes stored as they are or a section with a layout of its own.
+	Codec int
+}
+
+// Cipher, MAC and keyfile hash choices recorded in a trailer
+const (
+	CipherXChaCha20        = 1 // XChaCha20
+	CipherXChaCha20Serpent = 2 // XChaCha20, then Serpent-CTR (paranoid)
+
+	MACBLAKE2b  = 1 // Keyed BLAKE2b-512
+	MACHMACSHA3 = 2 // HMAC-SHA3-512 (paranoid)
+
+	KeyfileHashNone    = 0 // No keyfiles
+	KeyfileHashSHA3    = 1 // SHA3-256
+	KeyfileHashBLAKE2b = 2 // BLAKE2b-256
+)
+
+// Trailer is the format descriptor of a volume: where each field is, how it
+// is encoded, and the parameters needed to derive its keys and check it.
+type Trailer struct {
+	Fields [TrailerFields]FieldLocation
+
+	Argon2Memory  int64 // KiB
+	Argon2Passes  int
+	Argon2Threads int
+	Cipher        int // CipherXChaCha20 or CipherXChaCha20Serpent
+	MAC           int // MACBLAKE2b or MACHMACSHA3
+	KeyfileHash   int // KeyfileHashNone, KeyfileHashSHA3 or KeyfileHashBLAKE2b
+	RSVersion     int // encoding.RSCodecVersion of the codecs the volume is written with
+}
+
+// NewTrailer returns a trailer describing the header fields of h and the
+// choices its flags record. The caller fills in the sections after the
+// header and the Argon2 parameters.
+func NewTrailer(h *VolumeHeader) *Trailer {
+	t := &Trailer{
+		Cipher:      CipherXChaCha20,
+		MAC:         MACBLAKE2b,
+		KeyfileHash: KeyfileHashNone,
+		RSVersion:   encoding.RSCodecVersion,
+	}
+	if h.Flags.Paranoid {
+		t.Cipher = CipherXChaCha20Serpent
+		t.MAC = MACHMACSHA3
+	}
+	if h.Flags.UseKeyfiles {
+		t.KeyfileHash = KeyfileHashSHA3
+		if h.Flags.KeyfileBLAKE2b {
+			t.KeyfileHash = KeyfileHashBLAKE2b
+		}
+	}
+
+	n := int64(len(h.Comments))
+	layout := [...]FieldLocation{
+		FieldVersion:     {Size: VersionEncSize, Codec: 5},
+		FieldCommentsLen: {Size: CommentLenEncSize, Codec: 5},
+		FieldComments:    {Size: n * 3, Codec: 1},
+		FieldFlags:       {Size: FlagsEncSize, Codec: 5},
+		FieldSalt:        {Size: SaltEncSize, Codec: SaltSize},
+		FieldHKDFSalt:    {Size: HKDFSaltEncSize, Codec: HKDFSaltSize},
+		FieldSerpentIV:   {Size: SerpentIVEncSize, Codec: SerpentIVSize},
+		FieldNonce:       {Size: NonceEncSize, Codec: NonceSize},
+		FieldKeyHash:     {Size: KeyHashEncSize, Codec: KeyHashSize},
+		FieldKeyfileHash: {Size: KeyfileHashEncSize, Codec: KeyfileHashSize},
+		FieldAuthTag:     {Size: AuthTagEncSize, Codec: AuthTagSize},
+	}
+	var offset int64
+	for i, loc := range layout {
+		loc.Offset = offset
+		t.Fields[i] = loc
+		offset += loc.Size
+	}
+	return t
+}
+
+// digits returns the decimal digits stored and bound into the MAC
+func (t *Trailer) digits() []byte {
+	var b []byte
+	for _, loc := range t.Fields {
+		b = fmt.Appendf(b, "%016d%016d", loc.Offset, loc.Size)
+	}
+	for _, loc := range t.Fields {
+		b = fmt.Appendf(b, "%04d", loc.Codec)
+	}
+	b = fmt.Appendf(b, "%016d", t.Argon2Memory)
+	b = fmt.Appendf(b, "%04d%04d%02d%02d%02d%02d", t.Argon2Passes, t.Argon2Threads,
+		t.Cipher, t.MAC, t.KeyfileHash, t.RSVersion)
+	return append(b, trailerMagic...)
+}
+
+// WriteTrailer writes the encoded trailer at offset, which must be the end
+// of the payload
+func WriteTrailer(w io.WriterAt, offset int64, t *Trailer, rs *encoding.RSCodecs) error {
+	digits := t.digits()
+	if len(digits) != trailerSlots*16 {
+		return fmt.Errorf("write trailer: %+v does not fit its fields", *t)
+	}
+	buf := make([]byte, 0, TrailerEncSize)
+	for i := 0; i < len(digits); i += 16 {
+		buf = append(buf, encoding.Encode(rs.RS16, digits[i:i+16])...)
+	}
+	if _, err := w.WriteAt(buf, offset); err != nil {
+		return fmt.Errorf("write trailer: %w", err)
+	}
+	return nil
+}
+
+// ReadTrailer reads the trailer from the last TrailerEncSize bytes of r.
+// Every field it describes must end before the trailer starts.
+func ReadTrailer(r io.ReadSeeker, rs *encoding.RSCodecs) (*Trailer, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seek trailer: %w", err)
+	}
+	start := end - TrailerEncSize
+	if start < int64(BaseHeaderSize) {
+		return nil, fmt.Errorf("%w: no room for the trailer", ErrTruncatedHeader)
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek trailer: %w", err)
+	}
+	enc := make([]byte, TrailerEncSize)
+	if _, err := readField(r, "trailer", enc); err != nil {
+		return nil, err
+	}
+
+	digits := make([]byte, 0, trailerSlots*16)
+	for i := 0; i < TrailerEncSize; i += 48 {
+		dec, err := encoding.Decode(rs.RS16, enc[i:i+48], false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorruptedTrailer, err)
+		}
+		digits = append(digits, dec...)
+	}
+	if magic := string(digits[len(digits)-16:]); magic != trailerMagic {
+		return nil, fmt.Errorf("%w: unknown magic %q", ErrCorruptedTrailer, magic)
+	}
+
+	p := digitParser{digits: digits}
+	t := &Trailer{}
+	for i := range t.Fields {
+		t.Fields[i].Offset = p.next(16)
+		t.Fields[i].Size = p.next(16)
+	}
+	for i := range t.Fields {
+		t.Fields[i].Codec = int(p.next(4))
+	}
+	t.Argon2Memory = p.next(16)
+	t.Argon2Passes = int(p.next(4))
+	t.Argon2Threads = int(p.next(4))
+	t.Cipher = int(p.next(2))
+	t.MAC = int(p.next(2))
+	t.KeyfileHash = int(p.next(2))
+	t.RSVersion = int(p.next(2))
+	if p.err != nil {
+		return nil, p.err
+	}
+	for i, loc := range t.Fields {
+		if loc.Offset+loc.Size > start {
+			return nil, fmt.Errorf("%w: %s runs past the trailer", ErrCorruptedTrailer, TrailerField(i))
+		}
+	}
+	return t, nil
+}
+
+// digitParser reads consecutive zero-padded decimal numbers, keeping the
+// first error
+type digitParser struct {
+	digits []byte
+	err    error
+}
+
+func (p *digitParser) next(width int) int64 {
+	field := p.digits[:width]
+	p.digits = p.digits[width:]
+	v, err := strconv.ParseInt(string(field), 10, 64)
+	if (err != nil || v < 0) && p.err == nil {
+		p.err = fmt.Errorf("%w: invalid field %q", ErrCorruptedTrailer, field)
+	}
+	return v
+}
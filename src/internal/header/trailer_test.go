@@ -0,0 +1,121 @@
+package header
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+func TestFlagsTrailer(t *testing.T) {
+	for _, keyfiles := range []bool{false, true} {
+		flags := Flags{UseKeyfiles: keyfiles, Preview: true, SplitLayout: true, Trailer: true}
+		if parsed := FlagsFromBytes(flags.ToBytes()); parsed != flags {
+			t.Errorf("Trailer round-trip with keyfiles=%v: got %+v", keyfiles, parsed)
+		}
+	}
+}
+
+func TestTrailerRoundTrip(t *testing.T) {
+	rs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("NewRSCodecs failed: %v", err)
+	}
+
+	h := &VolumeHeader{Comments: "notes", Flags: Flags{Paranoid: true, UseKeyfiles: true, KeyfileBLAKE2b: true}}
+	tr := NewTrailer(h)
+	if tr.Cipher != CipherXChaCha20Serpent || tr.MAC != MACHMACSHA3 || tr.KeyfileHash != KeyfileHashBLAKE2b {
+		t.Errorf("NewTrailer choices = %d, %d, %d; want the paranoid ones with BLAKE2b keyfiles", tr.Cipher, tr.MAC, tr.KeyfileHash)
+	}
+	if got := tr.Fields[FieldKeyHash].Offset; got != AuthValuesOffset(len(h.Comments)) {
+		t.Errorf("key hash offset = %d; want %d", got, AuthValuesOffset(len(h.Comments)))
+	}
+	auth := tr.Fields[FieldAuthTag]
+	if end := auth.Offset + auth.Size; end != int64(HeaderSize(len(h.Comments))) {
+		t.Errorf("header fields end at %d; want %d", end, HeaderSize(len(h.Comments)))
+	}
+
+	payloadEnd := int64(HeaderSize(len(h.Comments))) + 1000
+	tr.Fields[FieldPayload] = FieldLocation{Offset: int64(HeaderSize(len(h.Comments))), Size: 1000}
+	tr.Argon2Memory, tr.Argon2Passes, tr.Argon2Threads = 1<<20, 8, 8
+
+	path := filepath.Join(t.TempDir(), "trailer")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := WriteTrailer(f, payloadEnd, tr, rs); err != nil {
+		t.Fatalf("WriteTrailer failed: %v", err)
+	}
+	_ = f.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(data) != int(payloadEnd)+TrailerEncSize {
+		t.Fatalf("file size = %d; want %d", len(data), int(payloadEnd)+TrailerEncSize)
+	}
+	data[payloadEnd] ^= 0xFF // repaired by Reed-Solomon
+
+	got, err := ReadTrailer(bytes.NewReader(data), rs)
+	if err != nil {
+		t.Fatalf("ReadTrailer failed: %v", err)
+	}
+	if *got != *tr {
+		t.Errorf("read trailer %+v; want %+v", *got, *tr)
+	}
+
+	if _, err := ReadTrailer(bytes.NewReader(data[payloadEnd+1:]), rs); !errors.Is(err, ErrTruncatedHeader) {
+		t.Errorf("expected ErrTruncatedHeader, got %v", err)
+	}
+	if _, err := ReadTrailer(bytes.NewReader(data[:len(data)-48]), rs); !errors.Is(err, ErrCorruptedTrailer) {
+		t.Errorf("expected ErrCorruptedTrailer without the magic, got %v", err)
+	}
+
+	// A field past the start of the trailer is refused
+	tr.Fields[FieldPayload].Size++
+	var buf bytes.Buffer
+	buf.Write(data[:payloadEnd])
+	if err := WriteTrailer(writerAtBuffer{&buf}, payloadEnd, tr, rs); err != nil {
+		t.Fatalf("WriteTrailer failed: %v", err)
+	}
+	if _, err := ReadTrailer(bytes.NewReader(buf.Bytes()), rs); !errors.Is(err, ErrCorruptedTrailer) {
+		t.Errorf("expected ErrCorruptedTrailer for a field past the trailer, got %v", err)
+	}
+}
+
+// writerAtBuffer appends to a buffer at its end, the only offset WriteAt
+// is called with here
+type writerAtBuffer struct{ b *bytes.Buffer }
+
+func (w writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	if off != int64(w.b.Len()) {
+		return 0, errors.New("write not at the end")
+	}
+	return w.b.Write(p)
+}
+
+func TestV2HeaderMACTrailer(t *testing.T) {
+	subkey := bytes.Repeat([]byte{0x42}, 64)
+	keyfileHash := make([]byte, KeyfileHashSize)
+	h := &VolumeHeader{
+		Version:   CurrentVersion,
+		Flags:     Flags{Trailer: true},
+		Salt:      make([]byte, SaltSize),
+		HKDFSalt:  make([]byte, HKDFSaltSize),
+		SerpentIV: make([]byte, SerpentIVSize),
+		Nonce:     make([]byte, NonceSize),
+	}
+	h.Trailer = NewTrailer(h)
+	h.Trailer.Fields[FieldPayload] = FieldLocation{Offset: BaseHeaderSize, Size: 5000}
+	mac1 := ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)
+
+	h.Trailer.Fields[FieldPayload].Size = 4000
+	if bytes.Equal(mac1, ComputeV2HeaderMAC(subkey, h, keyfileHash, nil)) {
+		t.Error("changing the payload size did not change the header MAC")
+	}
+}
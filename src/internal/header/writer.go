@@ -120,9 +120,40 @@ func (w *Writer) WriteHeader(h *VolumeHeader) (int, error) {
 	return totalWritten, nil
 }
 
+// Offsets locates the parts of a written header that are only known once
+// the payload has been encrypted, and where the header ends.
+type Offsets struct {
+	KeyHash     int64 // Start of the auth values, as passed to WriteAuthValues
+	KeyfileHash int64
+	AuthTag     int64
+	End         int64 // First byte after the header: block table, preview or payload
+}
+
+// HeaderOffsets returns the Offsets of a header with commentsLen comment bytes
+func HeaderOffsets(commentsLen int) Offsets {
+	keyHash := AuthValuesOffset(commentsLen)
+	return Offsets{
+		KeyHash:     keyHash,
+		KeyfileHash: keyHash + KeyHashEncSize,
+		AuthTag:     keyHash + KeyHashEncSize + KeyfileHashEncSize,
+		End:         int64(HeaderSize(commentsLen)),
+	}
+}
+
+// Write serializes h to w with placeholder auth values (see
+// Writer.WriteHeader) and returns the bytes written and the Offsets at which
+// WriteAuthValues backfills them. The output depends only on h.
+func Write(w io.Writer, h *VolumeHeader, rs *encoding.RSCodecs) (int, Offsets, error) {
+	n, err := NewWriter(w, rs).WriteHeader(h)
+	if err != nil {
+		return n, Offsets{}, err
+	}
+	return n, HeaderOffsets(len(h.Comments)), nil
+}
+
 // WriteAuthValues writes the authentication values to a seekable writer.
 // This should be called after encryption is complete.
-// offset is the position in the file where auth values begin (309 + comments*3 for v2.02)
+// offset is the position in the file where auth values begin (Offsets.KeyHash)
 func WriteAuthValues(w io.WriterAt, offset int64, keyHash, keyfileHash, authTag []byte, rs *encoding.RSCodecs) error {
 	pos := offset
 
@@ -4,20 +4,60 @@ package keyfile
 
 import (
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"strings"
 
 	"Picocrypt-NG/internal/crypto"
 	"Picocrypt-NG/internal/util"
 
+	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/sha3"
 )
 
+// HashAlgorithm selects the 256-bit hash keyfiles are processed with. It is
+// recorded in the volume header, so decryption uses whatever encryption did.
+type HashAlgorithm uint8
+
+const (
+	HashSHA3    HashAlgorithm = iota // SHA3-256, the original and default
+	HashBLAKE2b                      // BLAKE2b-256
+)
+
+// String returns the name ParseHashAlgorithm accepts.
+func (a HashAlgorithm) String() string {
+	if a == HashBLAKE2b {
+		return "blake2b"
+	}
+	return "sha3"
+}
+
+// ParseHashAlgorithm parses "sha3" or "blake2b" (case-insensitive).
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	switch strings.ToLower(name) {
+	case "sha3", "sha3-256":
+		return HashSHA3, nil
+	case "blake2b", "blake2b-256":
+		return HashBLAKE2b, nil
+	}
+	return 0, fmt.Errorf("unknown keyfile hash %q (must be sha3 or blake2b)", name)
+}
+
+// newHash returns a fresh hasher for a.
+func (a HashAlgorithm) newHash() hash.Hash {
+	if a == HashBLAKE2b {
+		h, _ := blake2b.New256(nil) // Only fails for an oversized key
+		return h
+	}
+	return sha3.New256()
+}
+
 // Result contains the computed keyfile key and its hash for verification.
 // Call Close() when done to securely zero the key material.
 type Result struct {
 	Key    []byte // 32 bytes - derived key for XOR with main password key
-	Hash   []byte // 32 bytes - SHA3-256(Key) for header storage/verification
+	Hash   []byte // 32 bytes - hash of Key (same algorithm) for header storage/verification
 	closed bool
 }
 
@@ -45,6 +85,12 @@ type ProgressFunc func(progress float32)
 //   - Ordered:   SHA3-256(file1 || file2 || file3 || ...)
 //   - Unordered: SHA3-256(file1) XOR SHA3-256(file2) XOR SHA3-256(file3) XOR ...
 func Process(paths []string, ordered bool, progress ProgressFunc) (*Result, error) {
+	return ProcessWith(paths, ordered, HashSHA3, progress)
+}
+
+// ProcessWith is Process with the hash selected by alg. The ordered and
+// unordered combination rules are the same for every algorithm.
+func ProcessWith(paths []string, ordered bool, alg HashAlgorithm, progress ProgressFunc) (*Result, error) {
 	if len(paths) == 0 {
 		return &Result{
 			Key:  make([]byte, 32),
@@ -66,9 +112,9 @@ func Process(paths []string, ordered bool, progress ProgressFunc) (*Result, erro
 	var err error
 
 	if ordered {
-		key, err = processOrdered(paths, totalSize, progress)
+		key, err = processOrdered(paths, totalSize, alg, progress)
 	} else {
-		key, err = processUnordered(paths, totalSize, progress)
+		key, err = processUnordered(paths, totalSize, alg, progress)
 	}
 
 	if err != nil {
@@ -76,7 +122,7 @@ func Process(paths []string, ordered bool, progress ProgressFunc) (*Result, erro
 	}
 
 	// Compute hash of keyfile key for verification
-	h := sha3.New256()
+	h := alg.newHash()
 	h.Write(key)
 	hash := h.Sum(nil)
 
@@ -88,40 +134,16 @@ func Process(paths []string, ordered bool, progress ProgressFunc) (*Result, erro
 
 // processOrdered hashes all keyfiles sequentially.
 // The file order IS IMPORTANT - different order = different key.
-// Algorithm: SHA3-256(file1_contents || file2_contents || ...)
-func processOrdered(paths []string, totalSize int64, progress ProgressFunc) ([]byte, error) {
-	hasher := sha3.New256()
+// Algorithm: H(file1_contents || file2_contents || ...)
+func processOrdered(paths []string, totalSize int64, alg HashAlgorithm, progress ProgressFunc) ([]byte, error) {
+	buf := util.GetMiBBuffer()
+	defer util.PutMiBBuffer(buf)
+
+	hasher := alg.newHash()
 	var done int64
 
 	for _, path := range paths {
-		fin, err := os.Open(path)
-		if err != nil {
-			return nil, err
-		}
-
-		buf := make([]byte, util.MiB)
-		for {
-			n, err := fin.Read(buf)
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				_ = fin.Close()
-				return nil, err
-			}
-
-			if _, err := hasher.Write(buf[:n]); err != nil {
-				_ = fin.Close()
-				return nil, err
-			}
-
-			done += int64(n)
-			if progress != nil {
-				progress(float32(done) / float32(totalSize))
-			}
-		}
-
-		if err := fin.Close(); err != nil {
+		if err := hashFile(hasher, path, buf, &done, totalSize, progress); err != nil {
 			return nil, err
 		}
 	}
@@ -131,57 +153,68 @@ func processOrdered(paths []string, totalSize int64, progress ProgressFunc) ([]b
 
 // processUnordered hashes each keyfile individually and XORs the results.
 // The file order IS NOT important due to XOR commutativity.
-// Algorithm: SHA3-256(file1) XOR SHA3-256(file2) XOR ...
-func processUnordered(paths []string, totalSize int64, progress ProgressFunc) ([]byte, error) {
+// Algorithm: H(file1) XOR H(file2) XOR ...
+func processUnordered(paths []string, totalSize int64, alg HashAlgorithm, progress ProgressFunc) ([]byte, error) {
+	buf := util.GetMiBBuffer()
+	defer util.PutMiBBuffer(buf)
+
 	var combinedKey []byte
 	var done int64
 
 	for _, path := range paths {
-		fin, err := os.Open(path)
-		if err != nil {
+		hasher := alg.newHash()
+		if err := hashFile(hasher, path, buf, &done, totalSize, progress); err != nil {
 			return nil, err
 		}
+		fileHash := hasher.Sum(nil)
 
-		hasher := sha3.New256()
-		buf := make([]byte, util.MiB)
-		for {
-			n, err := fin.Read(buf)
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				_ = fin.Close()
-				return nil, err
+		// XOR with combined key
+		if combinedKey == nil {
+			combinedKey = fileHash
+		} else {
+			for i, b := range fileHash {
+				combinedKey[i] ^= b
 			}
+		}
+	}
+
+	return combinedKey, nil
+}
+
+// hashFile streams the keyfile at path into hasher through buf, adding the
+// bytes read to done for progress. It is the only place keyfile contents are
+// read, and it never holds more than len(buf) of them, so a keyfile larger
+// than memory is processed in constant space. Duplicate detection works on
+// the 32-byte digests (see IsDuplicateKeyfileKey), never on the contents.
+func hashFile(hasher hash.Hash, path string, buf []byte, done *int64, totalSize int64, progress ProgressFunc) error {
+	fin, err := os.Open(path)
+	if err != nil {
+		return err
+	}
 
+	for {
+		n, err := fin.Read(buf)
+		if n > 0 {
 			if _, err := hasher.Write(buf[:n]); err != nil {
 				_ = fin.Close()
-				return nil, err
+				return err
 			}
 
-			done += int64(n)
+			*done += int64(n)
 			if progress != nil {
-				progress(float32(done) / float32(totalSize))
+				progress(float32(*done) / float32(totalSize))
 			}
 		}
-
-		if err := fin.Close(); err != nil {
-			return nil, err
+		if err == io.EOF {
+			break
 		}
-
-		fileHash := hasher.Sum(nil)
-
-		// XOR with combined key
-		if combinedKey == nil {
-			combinedKey = fileHash
-		} else {
-			for i, b := range fileHash {
-				combinedKey[i] ^= b
-			}
+		if err != nil {
+			_ = fin.Close()
+			return err
 		}
 	}
 
-	return combinedKey, nil
+	return fin.Close()
 }
 
 // IsDuplicateKeyfileKey checks if the keyfile key is all zeros,
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
+	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -114,6 +116,78 @@ func TestProcessUnordered(t *testing.T) {
 	}
 }
 
+func TestProcessWithBLAKE2b(t *testing.T) {
+	dir := t.TempDir()
+	a := []byte("keyfile1-content")
+	b := []byte("keyfile2-content")
+	createTestKeyfiles(t, dir, map[string][]byte{"a.key": a, "b.key": b})
+	pathsAB := []string{filepath.Join(dir, "a.key"), filepath.Join(dir, "b.key")}
+	pathsBA := []string{pathsAB[1], pathsAB[0]}
+
+	process := func(paths []string, ordered bool, alg HashAlgorithm) *Result {
+		t.Helper()
+		result, err := ProcessWith(paths, ordered, alg, nil)
+		if err != nil {
+			t.Fatalf("ProcessWith(ordered=%v, %v) failed: %v", ordered, alg, err)
+		}
+		return result
+	}
+
+	// Ordered: BLAKE2b-256 over the concatenation, order matters
+	ordered := process(pathsAB, true, HashBLAKE2b)
+	if want := blake2b.Sum256(append(append([]byte{}, a...), b...)); !bytes.Equal(ordered.Key, want[:]) {
+		t.Error("Ordered BLAKE2b key should be BLAKE2b-256(a || b)")
+	}
+	if bytes.Equal(ordered.Key, process(pathsBA, true, HashBLAKE2b).Key) {
+		t.Error("Ordered BLAKE2b: different order should produce different keys")
+	}
+	if want := blake2b.Sum256(ordered.Key); !bytes.Equal(ordered.Hash, want[:]) {
+		t.Error("Hash should be BLAKE2b-256 of key")
+	}
+
+	// Unordered: XOR of per-file BLAKE2b-256, order doesn't matter
+	unordered := process(pathsAB, false, HashBLAKE2b)
+	ha, hb := blake2b.Sum256(a), blake2b.Sum256(b)
+	want := make([]byte, 32)
+	for i := range want {
+		want[i] = ha[i] ^ hb[i]
+	}
+	if !bytes.Equal(unordered.Key, want) {
+		t.Error("Unordered BLAKE2b key should be BLAKE2b-256(a) XOR BLAKE2b-256(b)")
+	}
+	if !bytes.Equal(unordered.Key, process(pathsBA, false, HashBLAKE2b).Key) {
+		t.Error("Unordered BLAKE2b: different order should produce same keys")
+	}
+
+	// The algorithms never agree, and HashSHA3 is what Process uses
+	sha3Result := process(pathsAB, true, HashSHA3)
+	if bytes.Equal(sha3Result.Key, ordered.Key) {
+		t.Error("SHA3 and BLAKE2b keys should differ")
+	}
+	plain, err := Process(pathsAB, true, nil)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if !bytes.Equal(plain.Key, sha3Result.Key) {
+		t.Error("Process should match ProcessWith(HashSHA3)")
+	}
+}
+
+func TestParseHashAlgorithm(t *testing.T) {
+	for _, alg := range []HashAlgorithm{HashSHA3, HashBLAKE2b} {
+		parsed, err := ParseHashAlgorithm(alg.String())
+		if err != nil || parsed != alg {
+			t.Errorf("ParseHashAlgorithm(%q) = %v, %v; want %v", alg.String(), parsed, err, alg)
+		}
+	}
+	if parsed, err := ParseHashAlgorithm("BLAKE2b-256"); err != nil || parsed != HashBLAKE2b {
+		t.Errorf("ParseHashAlgorithm(BLAKE2b-256) = %v, %v", parsed, err)
+	}
+	if _, err := ParseHashAlgorithm("md5"); err == nil {
+		t.Error("ParseHashAlgorithm should reject unknown names")
+	}
+}
+
 func TestProcessEmpty(t *testing.T) {
 	result, err := Process(nil, true, nil)
 	if err != nil {
@@ -377,3 +451,66 @@ func TestProcessUnorderedReadError(t *testing.T) {
 		t.Error("Process should fail when given a directory")
 	}
 }
+
+// TestProcessLargeSparseKeyfile tests that a keyfile far larger than any
+// buffer is hashed correctly in constant memory, ordered and unordered, and
+// that listing it twice is still caught as a duplicate
+func TestProcessLargeSparseKeyfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 512 MiB keyfile in short mode")
+	}
+
+	const size = 512 << 20
+	tail := []byte("end of a very large keyfile")
+	path := filepath.Join(t.TempDir(), "huge.key")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(tail, size-int64(len(tail))); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := sha3.New256()
+	zeros := make([]byte, 1024*1024)
+	for left := size - len(tail); left > 0; left -= len(zeros) {
+		h.Write(zeros[:min(left, len(zeros))])
+	}
+	h.Write(tail)
+	want := h.Sum(nil)
+
+	for _, ordered := range []bool{true, false} {
+		var ms runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		base, peak := ms.HeapAlloc, ms.HeapAlloc
+		var calls int
+		result, err := Process([]string{path}, ordered, func(p float32) {
+			// Sampling every call would stop the world 1024 times
+			if calls++; calls%64 == 0 {
+				runtime.ReadMemStats(&ms)
+				peak = max(peak, ms.HeapAlloc)
+			}
+		})
+		if err != nil {
+			t.Fatalf("Process(ordered=%v) failed: %v", ordered, err)
+		}
+		if !bytes.Equal(result.Key, want) {
+			t.Errorf("Process(ordered=%v) key = %x; want %x", ordered, result.Key, want)
+		}
+		if grew := peak - base; grew > 64*1024*1024 {
+			t.Errorf("Process(ordered=%v) heap grew by %d MiB for a 512 MiB keyfile", ordered, grew>>20)
+		}
+	}
+
+	result, err := Process([]string{path, path}, false, nil)
+	if err != nil {
+		t.Fatalf("Process duplicate failed: %v", err)
+	}
+	if !IsDuplicateKeyfileKey(result.Key) {
+		t.Error("same large keyfile twice not detected as duplicate")
+	}
+}
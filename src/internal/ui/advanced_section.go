@@ -3,6 +3,7 @@ package ui
 
 import (
 	"reflect"
+	"strconv"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -80,7 +81,8 @@ func (a *App) buildEncryptOptions() {
 	})
 	a.reedSolomonCheck.SetChecked(a.State.ReedSolomon)
 
-	a.deleteCheck = widget.NewCheck("Delete files", func(checked bool) {
+	// Deleting the originals always verifies the volume first, hence the label
+	a.deleteCheck = widget.NewCheck("Verify & delete", func(checked bool) {
 		a.State.Delete = checked
 	})
 	a.deleteCheck.SetChecked(a.State.Delete)
@@ -108,7 +110,32 @@ func (a *App) buildEncryptOptions() {
 
 	row3 := container.NewGridWithColumns(2, a.deniabilityCheck, a.recursivelyCheck)
 
-	// Row 4: Split into chunks
+	// Row 4: Zip a dropped one-file folder anyway
+	a.keepArchiveCheck = widget.NewCheck("Keep folder archive", func(checked bool) {
+		a.State.KeepArchive = checked
+		a.updateOutputFileForKeepArchive(checked)
+	})
+	a.keepArchiveCheck.SetChecked(a.State.KeepArchive)
+	row4 := container.NewGridWithColumns(2, a.keepArchiveCheck)
+
+	// Output template for recursive mode, e.g. {dir}/encrypted/{name}{ext}.pcv
+	a.templateEntry = widget.NewEntry()
+	a.templateEntry.SetPlaceHolder("{dir}/{name}{ext}.pcv")
+	a.templateEntry.SetText(a.State.OutputTemplate)
+	a.templateEntry.OnChanged = func(text string) {
+		a.State.OutputTemplate = text
+	}
+
+	// Files processed at once in recursive mode; each derives its own key,
+	// taking 1 GiB of memory while it does
+	a.parallelSelect = widget.NewSelect([]string{"1", "2", "4"}, func(selected string) {
+		a.State.Concurrency, _ = strconv.Atoi(selected)
+	})
+	a.parallelSelect.SetSelected(strconv.Itoa(max(a.State.Concurrency, 1)))
+	parallel := container.NewHBox(widget.NewLabel("Parallel:"), a.parallelSelect)
+	templateRow := container.NewBorder(nil, nil, widget.NewLabel("Output:"), parallel, a.templateEntry)
+
+	// Row 5: Split into chunks
 	a.splitCheck = widget.NewCheck("Split:", func(checked bool) {
 		a.State.Split = checked
 		a.updateUIState() // Update status to show increased disk space requirement
@@ -146,6 +173,8 @@ func (a *App) buildEncryptOptions() {
 	a.advancedContainer.Add(row1)
 	a.advancedContainer.Add(row2)
 	a.advancedContainer.Add(row3)
+	a.advancedContainer.Add(row4)
+	a.advancedContainer.Add(templateRow)
 	a.advancedContainer.Add(splitRow)
 }
 
@@ -250,6 +279,10 @@ func (a *App) updateEncryptOptionsState(advancedDisabled bool) {
 
 	setWidgetDisabled(a.compressCheck, advancedDisabled || a.State.Recursively)
 	setWidgetDisabled(a.recursivelyCheck, advancedDisabled || notEnoughFiles)
+	_, oneFileFolder := a.oneFileFolder()
+	setWidgetDisabled(a.keepArchiveCheck, advancedDisabled || (!oneFileFolder && !a.State.KeepArchive))
+	setWidgetDisabled(a.templateEntry, advancedDisabled || !a.State.Recursively)
+	setWidgetDisabled(a.parallelSelect, advancedDisabled || !a.State.Recursively)
 	setWidgetDisabled(a.paranoidCheck, advancedDisabled)
 	setWidgetDisabled(a.reedSolomonCheck, advancedDisabled)
 	setWidgetDisabled(a.deleteCheck, advancedDisabled)
@@ -286,6 +319,8 @@ func (a *App) updateOutputFileForCompress(compress bool) {
 		if strings.HasSuffix(a.State.OutputFile, ".zip.pcv") {
 			a.State.OutputFile = strings.TrimSuffix(a.State.OutputFile, ".zip.pcv") + ".pcv"
 		}
+		// A one-file folder is no longer archived, so name it after the file
+		a.applyRawSingleFile()
 	}
 
 	// Refresh the output entry to show the updated filename
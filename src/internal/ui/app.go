@@ -28,13 +28,17 @@
 package ui
 
 import (
+	"context"
 	_ "embed"
+	"os"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"Picocrypt-NG/internal/app"
 	"Picocrypt-NG/internal/encoding"
 	"Picocrypt-NG/internal/util"
+	"Picocrypt-NG/internal/volume"
 
 	"fyne.io/fyne/v2"
 	fyneApp "fyne.io/fyne/v2/app"
@@ -77,6 +81,14 @@ type App struct {
 	// Cancellation flag (atomic for thread safety across goroutines)
 	cancelled atomic.Bool
 
+	// Context for the running operation; the Cancel button cancels it with
+	// ErrCancelled as the cause so it reads differently from a timeout
+	workCtx    context.Context
+	cancelWork context.CancelCauseFunc
+
+	// Clears credentials after inactivity (see idleClearPref)
+	idle *app.IdleTimer
+
 	// UI widgets that need to be updated
 	inputLabel        *widget.Label
 	clearButton       *widget.Button
@@ -84,6 +96,7 @@ type App struct {
 	passwordEntry     *PasswordEntry
 	cPasswordEntry    *PasswordEntry
 	strengthIndicator *PasswordStrengthIndicator
+	entropyLabel      *widget.Label
 	validIndicator    *ValidationIndicator
 	keyfileLabel      *widget.Label
 	commentsLabel     *widget.Label
@@ -93,6 +106,7 @@ type App struct {
 	outputEntry       *widget.Label
 	startButton       *widget.Button
 	statusLabel       *ColoredLabel
+	showFolderBtn     *widget.Button
 
 	// Confirm password section (hidden in decrypt mode)
 	confirmLabel *widget.Label
@@ -119,6 +133,9 @@ type App struct {
 	deleteCheck      *widget.Check
 	deniabilityCheck *widget.Check
 	recursivelyCheck *widget.Check
+	keepArchiveCheck *widget.Check
+	templateEntry    *widget.Entry
+	parallelSelect   *widget.Select
 	splitCheck       *widget.Check
 	splitSizeEntry   *widget.Entry
 	splitUnitSelect  *widget.Select
@@ -232,9 +249,13 @@ func (a *App) Run() {
 		})
 	}
 
+	a.startIdleTimer()
+	a.State.MinPasswordScore = a.fyneApp.Preferences().Int(minPasswordScorePref)
+
 	// Set up Enter key handler
 	if deskCanvas, ok := a.Window.Canvas().(desktop.Canvas); ok {
 		deskCanvas.SetOnKeyDown(func(event *fyne.KeyEvent) {
+			a.touchIdle()
 			if event.Name == fyne.KeyReturn || event.Name == fyne.KeyEnter {
 				a.onClickStart()
 			}
@@ -245,6 +266,55 @@ func (a *App) Run() {
 	a.Window.ShowAndRun()
 }
 
+// idleClearPref is the preference holding the minutes of inactivity after
+// which entered passwords and keyfiles are cleared; 0 (the default) is off.
+const idleClearPref = "idleClearMinutes"
+
+// minPasswordScorePref is the preference holding the lowest password
+// strength (zxcvbn score, 0-4) accepted for encryption; 0 (the default)
+// accepts any. It is set in the settings dialog.
+const minPasswordScorePref = "minPasswordScore"
+
+// startIdleTimer starts clearing credentials after the configured period of
+// inactivity. Interaction is recorded through touchIdle; the period is set
+// in the settings dialog.
+func (a *App) startIdleTimer() {
+	minutes := a.fyneApp.Preferences().Int(idleClearPref)
+	a.idle = app.NewIdleTimer(a.State, time.Duration(minutes)*time.Minute)
+
+	// The ticker runs even while the timer is off, since the settings
+	// dialog can turn it on at any time
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !a.idle.Check() {
+				continue
+			}
+			fyne.Do(func() {
+				if a.passwordEntry != nil {
+					a.passwordEntry.SetText("")
+				}
+				if a.cPasswordEntry != nil {
+					a.cPasswordEntry.SetText("")
+				}
+				a.State.MainStatus = "Password and keyfiles cleared after inactivity"
+				a.State.MainStatusColor = util.YELLOW
+				a.updatePasswordStrength()
+				a.updateValidation()
+				a.updateUIState()
+			})
+		}
+	}()
+}
+
+// touchIdle restarts the inactivity period.
+func (a *App) touchIdle() {
+	if a.idle != nil {
+		a.idle.Touch()
+	}
+}
+
 // showFileDialogWithResize temporarily resizes the window to accommodate file dialogs.
 // This is necessary because Fyne file dialogs are constrained by the parent window size
 // when using fixed-size windows. The window is restored after the dialog closes.
@@ -327,6 +397,12 @@ func (a *App) buildUI() fyne.CanvasObject {
 	a.startButton.Importance = widget.HighImportance
 
 	a.statusLabel = NewColoredLabel(a.State.MainStatus, a.State.MainStatusColor)
+	a.showFolderBtn = widget.NewButton("Show in folder", a.showOutputFolder)
+	diagnosticsBtn := widget.NewButtonWithIcon("", theme.InfoIcon(), a.copyDiagnostics)
+	diagnosticsBtn.Importance = widget.LowImportance
+	settingsBtn := widget.NewButtonWithIcon("", theme.SettingsIcon(), a.showSettingsModal)
+	settingsBtn.Importance = widget.LowImportance
+	statusRow := container.NewBorder(nil, nil, nil, container.NewHBox(settingsBtn, diagnosticsBtn, a.showFolderBtn), a.statusLabel)
 
 	// Advanced section label (hidden when no mode selected)
 	a.advancedLabel = widget.NewLabel("Advanced:")
@@ -344,7 +420,7 @@ func (a *App) buildUI() fyne.CanvasObject {
 		outputSection,
 		widget.NewSeparator(),
 		a.startButton,
-		a.statusLabel,
+		statusRow,
 	)
 
 	// Full layout with padding
@@ -427,6 +503,9 @@ func (a *App) refreshAdvanced() {
 // updateUIState updates the enabled/disabled state of all UI elements.
 // This mirrors the exact logic from the original giu implementation.
 func (a *App) updateUIState() {
+	// Nearly every interaction ends up here
+	a.touchIdle()
+
 	hasFiles := len(a.State.AllFiles) > 0 || len(a.State.OnlyFiles) > 0 || len(a.State.OnlyFolders) > 0
 	isScanning := a.State.Scanning
 
@@ -474,6 +553,10 @@ func (a *App) updateUIState() {
 	passwordsMatch := a.State.Mode != "encrypt" || a.State.Password == a.State.CPassword
 	advancedAndStartDisabled := !hasCredentials || !passwordsMatch
 
+	// Start also waits for a password that meets the configured strength
+	weakPassword := a.State.Mode == "encrypt" && a.State.Password != "" &&
+		a.State.PasswordStrength < a.State.MinPasswordScore
+
 	// Update advanced section checkboxes/inputs (from advanced_section.go)
 	a.updateAdvancedDisableState()
 
@@ -485,7 +568,7 @@ func (a *App) updateUIState() {
 		}
 		a.startButton.SetText(label)
 
-		if mainDisabled || advancedAndStartDisabled {
+		if mainDisabled || advancedAndStartDisabled || weakPassword {
 			a.startButton.Disable()
 		} else {
 			a.startButton.Enable()
@@ -520,28 +603,21 @@ func (a *App) updateUIState() {
 	if a.statusLabel != nil {
 		statusText := a.State.MainStatus
 		if a.State.MainStatus == "Ready" && a.State.RequiredFreeSpace > 0 {
-			multiplier := 1
-			if len(a.State.AllFiles) > 1 || len(a.State.OnlyFolders) > 0 {
-				multiplier++
-			}
-			if a.State.Deniability {
-				multiplier++
-			}
-			if a.State.Split {
-				multiplier++
-			}
-			if a.State.Recombine {
-				multiplier++
-			}
-			if a.State.AutoUnzip {
-				multiplier++
-			}
-			statusText = "Ready (ensure >" + util.Sizeify(a.State.RequiredFreeSpace*int64(multiplier)) + " free)"
+			statusText = "Ready (ensure >" + util.Sizeify(a.requiredFreeSpace()) + " free)"
 		}
 		a.statusLabel.SetText(statusText)
 		a.statusLabel.SetColor(a.State.MainStatusColor)
 	}
 
+	// Show in folder - only once an operation has left an output behind
+	if a.showFolderBtn != nil {
+		if a.State.IsBusy() || !outputFolderExists(a.State.LastOutput) {
+			a.showFolderBtn.Disable()
+		} else {
+			a.showFolderBtn.Enable()
+		}
+	}
+
 	// Update labels
 	if a.inputLabel != nil {
 		a.inputLabel.SetText(a.State.InputLabel)
@@ -573,3 +649,61 @@ func (a *App) resetUI() {
 	a.updateValidation()
 	a.updateUIState()
 }
+
+// showOutputFolder opens the system file browser at the folder holding the
+// last operation's output.
+func (a *App) showOutputFolder() {
+	if err := util.ShowInFolder(a.State.LastOutput); err != nil {
+		a.State.MainStatus = "Couldn't open folder: " + err.Error()
+		a.State.MainStatusColor = util.RED
+		a.updateUIState()
+	}
+}
+
+// copyDiagnostics copies a redacted dump of the state, for pasting into a
+// bug report, to the clipboard.
+func (a *App) copyDiagnostics() {
+	a.fyneApp.Clipboard().SetContent(a.State.Diagnostics())
+	a.State.MainStatus = "Diagnostics copied to clipboard"
+	a.State.MainStatusColor = util.WHITE
+	a.updateUIState()
+}
+
+// outputFolderExists reports whether path was set and its folder is still
+// there. The output itself may have been split, unzipped or moved since.
+func outputFolderExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	return err == nil && info.IsDir()
+}
+
+// requiredFreeSpace returns the disk space the current operation needs, the
+// peak the volume layer computes from the selected options. Decryption also
+// checks it against the free space before starting.
+func (a *App) requiredFreeSpace() int64 {
+	if a.State.Mode == "encrypt" {
+		return volume.RequiredFreeSpace(&volume.EncryptRequest{
+			InputFile:          a.State.InputFile,
+			InputFiles:         a.State.AllFiles,
+			OnlyFolders:        a.State.OnlyFolders,
+			OnlyFiles:          a.State.OnlyFiles,
+			OutputFile:         a.State.OutputFile,
+			Comments:           a.State.Comments,
+			ReedSolomon:        a.State.ReedSolomon,
+			Deniability:        a.State.Deniability,
+			Compress:           a.State.Compress,
+			RawSingleFile:      !a.State.KeepArchive,
+			Split:              a.State.Split,
+			VerifyAfterEncrypt: a.State.Delete,
+		}, a.State.RequiredFreeSpace)
+	}
+
+	return volume.RequiredDecryptSpace(&volume.DecryptRequest{
+		OutputFile:  a.State.OutputFile,
+		Deniability: a.State.Deniability,
+		Recombine:   a.State.Recombine,
+		AutoUnzip:   a.State.AutoUnzip,
+	}, a.State.RequiredFreeSpace)
+}
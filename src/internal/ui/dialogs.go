@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	perrors "Picocrypt-NG/internal/errors"
 	"Picocrypt-NG/internal/util"
 
 	"fyne.io/fyne/v2"
@@ -37,6 +38,9 @@ func (a *App) showProgressModal() {
 		a.State.Working = false
 		a.State.CanCancel = false
 		a.cancelled.Store(true)
+		if a.cancelWork != nil {
+			a.cancelWork(perrors.ErrCancelled)
+		}
 		a.State.MainStatus = "Operation cancelled by user"
 		a.State.MainStatusColor = util.WHITE
 		if a.cancelButton != nil {
@@ -80,16 +84,47 @@ func (a *App) showPassgenModal() {
 	})
 	numsCheck.SetChecked(a.State.PassgenNums)
 
+	// Explains an unusable symbol set as it is typed instead of letting
+	// Generate do nothing
+	symbolSetError := widget.NewLabel("")
+	symbolSetError.Importance = widget.DangerImportance
+	symbolSetError.Wrapping = fyne.TextWrapWord
+	updateSymbolSetError := func() {
+		if msg := passgenSymbolSetError(a.State.PassgenSymbols, a.State.PassgenSymbolSet); msg != "" {
+			symbolSetError.SetText(msg)
+			symbolSetError.Show()
+		} else {
+			symbolSetError.Hide()
+		}
+	}
+
 	symbolsCheck := widget.NewCheck("Symbols", func(checked bool) {
 		a.State.PassgenSymbols = checked
+		updateSymbolSetError()
 	})
 	symbolsCheck.SetChecked(a.State.PassgenSymbols)
 
+	symbolSetEntry := widget.NewEntry()
+	symbolSetEntry.SetText(a.State.PassgenSymbolSet)
+	symbolSetEntry.OnChanged = func(text string) {
+		a.State.PassgenSymbolSet = text
+		updateSymbolSetError()
+	}
+	updateSymbolSetError()
+
 	copyCheck := widget.NewCheck("Copy to clipboard", func(checked bool) {
 		a.State.PassgenCopy = checked
 	})
 	copyCheck.SetChecked(a.State.PassgenCopy)
 
+	countEntry := widget.NewEntry()
+	countEntry.SetText(strconv.Itoa(int(a.State.PassgenCount)))
+	countEntry.OnChanged = func(text string) {
+		if n, err := strconv.Atoi(strings.TrimSpace(text)); err == nil && n >= 1 && n <= maxPassgenCount {
+			a.State.PassgenCount = int32(n)
+		}
+	}
+
 	content := container.NewVBox(
 		lengthLabel,
 		lengthSlider,
@@ -97,7 +132,10 @@ func (a *App) showPassgenModal() {
 		lowerCheck,
 		numsCheck,
 		symbolsCheck,
+		container.NewBorder(nil, nil, widget.NewLabel("Symbol set:"), nil, symbolSetEntry),
+		symbolSetError,
 		copyCheck,
+		container.NewBorder(nil, nil, widget.NewLabel("Count:"), nil, countEntry),
 	)
 
 	a.passgenModal = dialog.NewCustomConfirm("Generate password:", "Generate", "Cancel", content, func(generate bool) {
@@ -106,6 +144,19 @@ func (a *App) showPassgenModal() {
 			if !a.State.PassgenUpper && !a.State.PassgenLower && !a.State.PassgenNums && !a.State.PassgenSymbols {
 				return
 			}
+			// Symbols need a valid set; an empty one would fall back to the
+			// default. Reopen the dialog so the message stays in view.
+			if passgenSymbolSetError(a.State.PassgenSymbols, a.State.PassgenSymbolSet) != "" {
+				a.showPassgenModal()
+				return
+			}
+			if a.State.PassgenCount > 1 {
+				a.State.ShowPassgen = false
+				if passwords := a.State.GenPasswords(); passwords != nil {
+					a.showPasswordListModal(passwords)
+				}
+				return
+			}
 			password := a.State.GenPassword()
 			a.State.Password = password
 			a.State.CPassword = password
@@ -125,6 +176,132 @@ func (a *App) showPassgenModal() {
 	a.passgenModal.Show()
 }
 
+// passgenSymbolSetError returns the message the password generator shows
+// for a symbol set it cannot use, or "" if symbols are off or the set is
+// valid.
+func passgenSymbolSetError(symbols bool, set string) string {
+	if !symbols {
+		return ""
+	}
+	if _, err := util.ParseSymbolSet(set); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// maxPassgenCount caps the passwords generated at once.
+const maxPassgenCount = 1000
+
+// showPasswordListModal lists a batch of generated passwords, one per line,
+// with the option to save them to a text file.
+func (a *App) showPasswordListModal(passwords []string) {
+	text := strings.Join(passwords, "\n") + "\n"
+
+	list := widget.NewMultiLineEntry()
+	list.SetText(text)
+	list.TextStyle = fyne.TextStyle{Monospace: true}
+	list.SetMinRowsVisible(10)
+
+	saveButton := widget.NewButton("Save...", func() {
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+			defer writer.Close()
+			if _, err := writer.Write([]byte(text)); err != nil {
+				a.State.MainStatus = "Failed to write passwords"
+				a.State.MainStatusColor = util.RED
+				a.updateUIState()
+			}
+		}, a.Window)
+		saveDialog.SetFileName("passwords-" + strconv.Itoa(int(time.Now().Unix())) + ".txt")
+		a.showFileDialogWithResize(saveDialog, fyne.NewSize(600, 450))
+	})
+
+	content := container.NewBorder(nil, saveButton, nil, nil, list)
+	listModal := dialog.NewCustom(fmt.Sprintf("Generated %d passwords:", len(passwords)), "Close", content, a.Window)
+	listModal.Resize(fyne.NewSize(480, 360))
+	a.State.ModalID++
+	listModal.Show()
+}
+
+// showWarningsModal lists the non-fatal warnings an operation reported, one
+// per line. Nothing is shown if there were none.
+func (a *App) showWarningsModal(warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	list := widget.NewLabel(strings.Join(warnings, "\n"))
+	list.Wrapping = fyne.TextWrapWord
+
+	warningsModal := dialog.NewCustom(fmt.Sprintf("%d warning(s):", len(warnings)), "Close", container.NewVScroll(list), a.Window)
+	warningsModal.Resize(fyne.NewSize(480, 300))
+	a.State.ModalID++
+	warningsModal.Show()
+}
+
+// idleClearChoices are the inactivity periods, in minutes, offered in the
+// settings dialog; 0 is off.
+var idleClearChoices = []int{0, 5, 15, 30, 60}
+
+// idleClearLabel names an idle-clear period for the settings dialog.
+func idleClearLabel(minutes int) string {
+	if minutes == 0 {
+		return "Never"
+	}
+	return fmt.Sprintf("After %d minutes", minutes)
+}
+
+// showSettingsModal shows the settings dialog. Changes are saved to the
+// preferences and take effect at once.
+func (a *App) showSettingsModal() {
+	prefs := a.fyneApp.Preferences()
+
+	idleOptions := make([]string, len(idleClearChoices))
+	for i, minutes := range idleClearChoices {
+		idleOptions[i] = idleClearLabel(minutes)
+	}
+	idleSelect := widget.NewSelect(idleOptions, func(selected string) {
+		for _, minutes := range idleClearChoices {
+			if idleClearLabel(minutes) == selected {
+				prefs.SetInt(idleClearPref, minutes)
+				a.idle.SetTimeout(time.Duration(minutes) * time.Minute)
+				return
+			}
+		}
+	})
+	idleSelect.SetSelected(idleClearLabel(prefs.Int(idleClearPref)))
+
+	scoreOptions := []string{"Any", "1 of 4", "2 of 4", "3 of 4", "4 of 4"}
+	scoreSelect := widget.NewSelect(scoreOptions, func(selected string) {
+		for score, option := range scoreOptions {
+			if option == selected {
+				prefs.SetInt(minPasswordScorePref, score)
+				a.State.MinPasswordScore = score
+				a.updatePasswordStrength()
+				a.updateUIState()
+				return
+			}
+		}
+	})
+	if score := a.State.MinPasswordScore; score >= 0 && score < len(scoreOptions) {
+		scoreSelect.SetSelected(scoreOptions[score])
+	}
+
+	content := container.NewVBox(
+		widget.NewLabel("Clear password and keyfiles when idle:"),
+		idleSelect,
+		widget.NewLabel("Minimum password strength to encrypt:"),
+		scoreSelect,
+	)
+
+	settingsModal := dialog.NewCustom("Settings:", "Close", content, a.Window)
+	settingsModal.Resize(fyne.NewSize(320, 0))
+	a.State.ModalID++
+	settingsModal.Show()
+}
+
 // showOverwriteModal shows the overwrite confirmation dialog.
 func (a *App) showOverwriteModal() {
 	a.overwriteModal = dialog.NewConfirm("Warning:", "Output already exists. Overwrite?", func(overwrite bool) {
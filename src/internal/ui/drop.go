@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -39,11 +40,27 @@ func (a *App) onDrop(names []string) {
 	// AFTER it's set below, because fyne.Do() queues the call for later execution
 	a.resetUI()
 
+	// Special files (FIFOs, devices, sockets) and unreadable items left out
+	// of the selection
+	skipped := 0
+	var unreadable []string
+
 	// One item dropped
 	if len(names) == 1 {
-		stat, err := os.Stat(names[0])
+		stat, err := statReadable(names[0])
 		if err != nil {
-			a.State.MainStatus = "Failed to stat dropped item"
+			a.State.MainStatus = "Dropped item cannot be read"
+			a.State.MainStatusColor = util.RED
+			a.State.Scanning = false
+			fyne.Do(func() {
+				a.refreshUI()
+			})
+			return
+		}
+
+		// FIFOs and device files could block the scan or encryption forever
+		if isSpecialFile(stat) {
+			a.State.MainStatus = "Dropped item is not a regular file"
 			a.State.MainStatusColor = util.RED
 			a.State.Scanning = false
 			fyne.Do(func() {
@@ -76,7 +93,8 @@ func (a *App) onDrop(names []string) {
 			isSplit := strings.Contains(names[0], ".pcv.") && endsNum
 
 			// Decide if encrypting or decrypting
-			if strings.HasSuffix(names[0], ".pcv") || isSplit {
+			armored := strings.HasSuffix(names[0], ".pcv"+encoding.ArmorExt)
+			if strings.HasSuffix(names[0], ".pcv") || armored || isSplit {
 				a.handleDecryptDrop(names[0], isSplit)
 				// For decrypt, no folder scanning needed
 				a.State.Scanning = false
@@ -105,32 +123,34 @@ func (a *App) onDrop(names []string) {
 		}
 	} else {
 		// Multiple items dropped - always encrypt
-		a.handleMultipleDrop(names)
+		skipped, unreadable = a.handleMultipleDrop(names)
+		if a.State.Mode == "" {
+			a.State.Scanning = false
+			fyne.Do(func() {
+				a.refreshUI()
+			})
+			return
+		}
 	}
 
 	// Recursively add all files in 'onlyFolders' to 'allFiles' (matches original lines 1133-1173)
 	go func() {
 		oldInputLabel := a.State.InputLabel
 		for _, name := range a.State.OnlyFolders {
-			if filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
+			_ = filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
+				// Leave out what cannot be read, keeping the rest of the drop
 				if err != nil {
-					fyne.Do(func() {
-						a.resetUI()
-						a.State.MainStatus = "Failed to walk through dropped items"
-						a.State.MainStatusColor = util.RED
-						a.refreshUI()
-					})
-					return err
+					unreadable = append(unreadable, path)
+					return nil
 				}
-				stat, err := os.Stat(path)
+				stat, err := statReadable(path)
 				if err != nil {
-					fyne.Do(func() {
-						a.resetUI()
-						a.State.MainStatus = "Failed to walk through dropped items"
-						a.State.MainStatusColor = util.RED
-						a.refreshUI()
-					})
-					return err
+					unreadable = append(unreadable, path)
+					return nil
+				}
+				if isSpecialFile(stat) {
+					skipped++
+					return nil
 				}
 				// If 'path' is a valid file path, add to 'allFiles'
 				if !stat.IsDir() {
@@ -144,25 +164,64 @@ func (a *App) onDrop(names []string) {
 					})
 				}
 				return nil
-			}) != nil {
-				fyne.Do(func() {
-					a.resetUI()
-					a.State.MainStatus = "Failed to walk through dropped items"
-					a.State.MainStatusColor = util.RED
-					a.refreshUI()
-				})
-				return
-			}
+			})
 		}
 		fyne.Do(func() {
 			a.State.InputLabel = fmt.Sprintf("%s (%s)", oldInputLabel, util.Sizeify(a.State.CompressTotal))
 			a.State.Scanning = false
+			a.applyRawSingleFile()
 			a.refreshUI()
 			a.refreshAdvanced()
+			a.showWarningsModal(dropWarnings(skipped, unreadable))
 		})
 	}()
 }
 
+// oneFileFolder returns the request for a single dropped folder encrypted
+// without a zip wrapper, and whether the drop is a folder holding exactly
+// one file that can be. With "Compress files" checked it never is.
+func (a *App) oneFileFolder() (volume.EncryptRequest, bool) {
+	req := volume.EncryptRequest{
+		InputFiles:    a.State.AllFiles,
+		OnlyFolders:   a.State.OnlyFolders,
+		OnlyFiles:     a.State.OnlyFiles,
+		Compress:      a.State.Compress,
+		RawSingleFile: true,
+	}
+	return req, req.IsRawSingleFile()
+}
+
+// applyRawSingleFile names the output after the file when a single dropped
+// folder holds exactly one file, so it is encrypted directly instead of being
+// zipped. Checking "Keep folder archive" or "Compress files" still produces
+// an archive.
+func (a *App) applyRawSingleFile() {
+	if a.State.Mode != "encrypt" || a.State.KeepArchive {
+		return
+	}
+	req, ok := a.oneFileFolder()
+	if !ok {
+		return
+	}
+	a.State.StartLabel = "Encrypt"
+	a.State.OutputFile = req.RawSingleFileOutput()
+}
+
+// updateOutputFileForKeepArchive switches a dropped one-file folder between
+// the archive's output name and the file's.
+func (a *App) updateOutputFileForKeepArchive(keep bool) {
+	if a.State.Mode != "encrypt" {
+		return
+	}
+	if !keep {
+		a.applyRawSingleFile()
+	} else if _, ok := a.oneFileFolder(); ok {
+		a.State.StartLabel = "Zip and Encrypt"
+		a.State.OutputFile = a.State.InputFile + ".pcv"
+	}
+	a.refreshUI()
+}
+
 // handleDecryptDrop handles a .pcv file being dropped for decryption.
 func (a *App) handleDecryptDrop(name string, isSplit bool) {
 	a.State.Mode = "decrypt"
@@ -179,7 +238,7 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 		ind := strings.Index(name, ".pcv")
 		name = name[:ind+4]
 		a.State.InputFile = name
-		a.State.OutputFile = name[:ind]
+		a.State.OutputFile = volume.DecryptOutputName(name, nil)
 		a.State.Recombine = true
 
 		// Find out the number of split chunks
@@ -195,7 +254,7 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 		a.State.RequiredFreeSpace = a.State.CompressTotal
 	} else {
 		a.State.InputFile = name
-		a.State.OutputFile = name[:len(name)-4]
+		a.State.OutputFile = volume.DecryptOutputName(name, nil)
 	}
 
 	// Open the input file in read-only mode
@@ -217,9 +276,19 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 	}
 	defer func() { _ = fin.Close() }()
 
+	// Read an armored volume's header through the decoder
+	var src io.Reader = fin
+	if !isSplit && encoding.IsArmored(name) {
+		if src, err = encoding.NewArmorReader(fin); err != nil {
+			a.State.MainStatus = "The armored volume is damaged"
+			a.State.MainStatusColor = util.RED
+			return
+		}
+	}
+
 	// Check if version can be read from header
 	tmp := make([]byte, 15)
-	if n, err := fin.Read(tmp); err != nil || n != 15 {
+	if n, err := src.Read(tmp); err != nil || n != 15 {
 		a.State.MainStatus = "Failed to read header"
 		a.State.MainStatusColor = util.RED
 		return
@@ -235,7 +304,7 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 
 	// Read comments from file
 	tmp = make([]byte, 15)
-	if n, err := fin.Read(tmp); err != nil || n != 15 {
+	if n, err := src.Read(tmp); err != nil || n != 15 {
 		a.State.MainStatus = "Failed to read header"
 		a.State.MainStatusColor = util.RED
 		return
@@ -243,30 +312,39 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 
 	tmp, err = encoding.Decode(a.rsCodecs.RS5, tmp, false)
 	if err == nil {
-		commentsLength, err := strconv.Atoi(string(tmp))
+		commentsLength, err := header.ParseCommentsLen(tmp)
 		if err != nil {
 			a.State.Comments = "Comment length is corrupted"
 		} else {
 			tmp = make([]byte, commentsLength*3)
-			if n, err := fin.Read(tmp); err != nil || n != commentsLength*3 {
+			if n, err := io.ReadFull(src, tmp); err != nil || n != commentsLength*3 {
 				a.State.MainStatus = "Failed to read comments"
 				a.State.MainStatusColor = util.RED
 				return
 			}
-			a.State.Comments = ""
+			var comments strings.Builder
+			comments.Grow(commentsLength)
 			for i := 0; i < commentsLength*3; i += 3 {
 				t, err := encoding.Decode(a.rsCodecs.RS1, tmp[i:i+3], false)
 				if err != nil {
-					a.State.Comments = "Comments are corrupted"
+					comments.Reset()
+					comments.WriteString("Comments are corrupted")
 					break
 				}
-				a.State.Comments += string(t)
+				comments.Write(t)
 			}
+			a.State.Comments = comments.String()
 		}
 	} else {
 		a.State.Comments = "Comments are corrupted"
 	}
 
+	// Separate any keyfile name hints from the user's comments
+	commentsHeader := &header.VolumeHeader{Comments: a.State.Comments}
+	keyfileNames := commentsHeader.KeyfileNames()
+	a.State.Comments = commentsHeader.UserComments()
+	a.State.OutputFile = volume.DecryptOutputName(a.State.InputFile, commentsHeader)
+
 	// Update comments entry if it exists
 	fyne.Do(func() {
 		if a.commentsEntry != nil {
@@ -276,7 +354,7 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 
 	// Read flags from file
 	flags := make([]byte, 15)
-	if n, err := fin.Read(flags); err != nil || n != 15 {
+	if n, err := src.Read(flags); err != nil || n != 15 {
 		a.State.MainStatus = "Failed to read header"
 		a.State.MainStatusColor = util.RED
 		return
@@ -294,6 +372,9 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 	if flagsStruct.UseKeyfiles {
 		a.State.Keyfile = true
 		a.State.KeyfileLabel = "Keyfiles required"
+		if len(keyfileNames) > 0 {
+			a.State.KeyfileLabel = "Needs keyfiles: " + strings.Join(keyfileNames, ", ")
+		}
 	} else {
 		a.State.KeyfileLabel = "Not applicable"
 	}
@@ -308,23 +389,29 @@ func (a *App) handleDecryptDrop(name string, isSplit bool) {
 }
 
 // handleMultipleDrop handles multiple files/folders being dropped.
-// Matches original lines 1081-1131 exactly.
-func (a *App) handleMultipleDrop(names []string) {
+// Matches original lines 1081-1131, except that special files are left out
+// and unreadable items are skipped instead of failing the whole drop; it
+// returns how many special files were skipped and the unreadable items.
+func (a *App) handleMultipleDrop(names []string) (int, []string) {
 	a.State.Mode = "encrypt"
 	a.State.StartLabel = "Zip and Encrypt"
-	files, folders := 0, 0
+	files, folders, skipped := 0, 0, 0
+	var unreadable []string
+	first := ""
 
 	// Go through each dropped item and add to corresponding slices
 	for _, name := range names {
-		stat, err := os.Stat(name)
+		stat, err := statReadable(name)
 		if err != nil {
-			a.State.MainStatus = "Failed to stat dropped items"
-			a.State.MainStatusColor = util.RED
-			fyne.Do(func() {
-				a.resetUI()
-				a.refreshUI()
-			})
-			return
+			unreadable = append(unreadable, name)
+			continue
+		}
+		if isSpecialFile(stat) {
+			skipped++
+			continue
+		}
+		if first == "" {
+			first = name
 		}
 		if stat.IsDir() {
 			folders++
@@ -340,6 +427,14 @@ func (a *App) handleMultipleDrop(names []string) {
 		}
 	}
 
+	// Nothing usable was dropped
+	if first == "" {
+		a.State.ResetUI()
+		a.State.MainStatus = "None of the dropped items can be read"
+		a.State.MainStatusColor = util.RED
+		return skipped, unreadable
+	}
+
 	// Update UI with the number of files and folders selected (matches original lines 1111-1125)
 	if folders == 0 {
 		a.State.InputLabel = fmt.Sprintf("%d files", files)
@@ -358,8 +453,51 @@ func (a *App) handleMultipleDrop(names []string) {
 	}
 
 	// Set the input and output paths (matches original lines 1127-1129)
-	a.State.InputFile = filepath.Join(filepath.Dir(names[0]), "encrypted-"+strconv.Itoa(int(time.Now().Unix()))) + ".zip"
+	a.State.InputFile = filepath.Join(filepath.Dir(first), "encrypted-"+strconv.Itoa(int(time.Now().Unix()))) + ".zip"
 	a.State.OutputFile = a.State.InputFile + ".pcv"
+	return skipped, unreadable
+}
+
+// statReadable stats name and checks that it can be opened for reading, so
+// an item the user lacks permission for is caught at drop time rather than
+// halfway through encryption.
+func statReadable(name string) (os.FileInfo, error) {
+	stat, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if isSpecialFile(stat) {
+		return stat, nil // Opening a FIFO could block
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+	return stat, nil
+}
+
+// dropWarnings describes what a drop left out for the warnings panel, or
+// returns nil if nothing.
+func dropWarnings(skipped int, unreadable []string) []string {
+	var warnings []string
+	if skipped > 0 {
+		warnings = append(warnings, fmt.Sprintf("Skipped %d special file(s)", skipped))
+	}
+	switch len(unreadable) {
+	case 0:
+	case 1:
+		warnings = append(warnings, "Cannot read "+filepath.Base(unreadable[0]))
+	default:
+		warnings = append(warnings, fmt.Sprintf("Cannot read %d items, e.g. %s", len(unreadable), filepath.Base(unreadable[0])))
+	}
+	return warnings
+}
+
+// isSpecialFile reports whether stat describes something other than a
+// regular file or directory, such as a FIFO, socket or device node.
+func isSpecialFile(stat os.FileInfo) bool {
+	return !stat.IsDir() && !stat.Mode().IsRegular()
 }
 
 // handleKeyfileDrop processes dropped keyfiles when the modal is open.
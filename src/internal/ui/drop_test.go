@@ -578,3 +578,98 @@ func TestStatusWithFreeSpace(t *testing.T) {
 		}
 	}
 }
+
+// TestMultipleDropSkipsUnreadable tests that an unreadable item in a drop is
+// reported while the readable ones are still selected.
+func TestMultipleDropSkipsUnreadable(t *testing.T) {
+	test.NewApp()
+	defer test.NewApp()
+
+	tmpDir := t.TempDir()
+	readable := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(readable, []byte("readable"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unreadable := filepath.Join(tmpDir, "private.txt")
+	if err := os.WriteFile(unreadable, []byte("private"), 0000); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := os.Open(unreadable); err == nil {
+		// Permissions do not stop root; a file removed since it was
+		// dropped cannot be read either
+		_ = f.Close()
+		_ = os.Remove(unreadable)
+	}
+
+	a := createTestApp(t)
+	skipped, failed := a.handleMultipleDrop([]string{unreadable, readable})
+	if skipped != 0 || len(failed) != 1 || failed[0] != unreadable {
+		t.Fatalf("handleMultipleDrop = %d, %v; want the unreadable file reported", skipped, failed)
+	}
+	if a.State.Mode != "encrypt" || len(a.State.AllFiles) != 1 || a.State.AllFiles[0] != readable {
+		t.Errorf("Mode = %q, AllFiles = %v; want the readable file selected for encryption", a.State.Mode, a.State.AllFiles)
+	}
+	if a.State.InputLabel != "1 files" {
+		t.Errorf("InputLabel = %q; want only the readable file counted", a.State.InputLabel)
+	}
+	if warnings := dropWarnings(skipped, failed); len(warnings) != 1 || warnings[0] != "Cannot read private.txt" {
+		t.Errorf("dropWarnings = %q; want it to name the unreadable file", warnings)
+	}
+
+	t.Run("NothingReadable", func(t *testing.T) {
+		a := createTestApp(t)
+		missing := filepath.Join(tmpDir, "missing.txt")
+		if _, failed := a.handleMultipleDrop([]string{unreadable, missing}); len(failed) != 2 {
+			t.Errorf("failed = %v; want both items", failed)
+		}
+		if a.State.Mode != "" || a.State.MainStatusColor != util.RED {
+			t.Errorf("Mode = %q, status %q; want the drop refused", a.State.Mode, a.State.MainStatus)
+		}
+	})
+}
+
+// TestKeepFolderArchive tests that "Keep folder archive" zips a dropped
+// one-file folder instead of encrypting the file directly, and that
+// unchecking it goes back to the file's name.
+func TestKeepFolderArchive(t *testing.T) {
+	test.NewApp()
+	defer test.NewApp()
+
+	a := createTestApp(t)
+	a.buildUI()
+	parent := t.TempDir()
+	folder := filepath.Join(parent, "docs")
+	archive := filepath.Join(parent, "encrypted-1.zip")
+
+	// State as a drop of a folder holding only notes.txt leaves it
+	a.State.Mode = "encrypt"
+	a.State.OnlyFolders = []string{folder}
+	a.State.AllFiles = []string{filepath.Join(folder, "notes.txt")}
+	a.State.InputFile = archive
+	a.State.OutputFile = archive + ".pcv"
+	a.applyRawSingleFile()
+	a.refreshAdvanced()
+
+	rawOutput := filepath.Join(parent, "notes.txt.pcv")
+	if a.State.OutputFile != rawOutput || a.State.StartLabel != "Encrypt" {
+		t.Fatalf("output = %q, label %q; want %q, Encrypt", a.State.OutputFile, a.State.StartLabel, rawOutput)
+	}
+
+	a.keepArchiveCheck.SetChecked(true)
+	if !a.State.KeepArchive {
+		t.Fatal("checking the option should set KeepArchive")
+	}
+	if a.State.OutputFile != archive+".pcv" || a.State.StartLabel != "Zip and Encrypt" {
+		t.Errorf("output = %q, label %q; want the archive", a.State.OutputFile, a.State.StartLabel)
+	}
+	// A rescan keeps the archive while the option is set
+	a.applyRawSingleFile()
+	if a.State.OutputFile != archive+".pcv" {
+		t.Errorf("output = %q after a rescan; want the archive", a.State.OutputFile)
+	}
+
+	a.keepArchiveCheck.SetChecked(false)
+	if a.State.OutputFile != rawOutput || a.State.StartLabel != "Encrypt" {
+		t.Errorf("output = %q, label %q; want %q, Encrypt", a.State.OutputFile, a.State.StartLabel, rawOutput)
+	}
+}
@@ -366,7 +366,10 @@ func (a *App) buildMobilePasswordSection() fyne.CanvasObject {
 	}
 
 	a.strengthIndicator = NewPasswordStrengthIndicator()
-	passwordRow := container.NewBorder(nil, nil, nil, a.strengthIndicator, a.passwordEntry)
+	a.entropyLabel = widget.NewLabel("")
+	a.entropyLabel.Hide()
+	passwordRow := container.NewBorder(nil, nil, nil,
+		container.NewHBox(a.entropyLabel, a.strengthIndicator), a.passwordEntry)
 
 	// Confirm password
 	a.cPasswordEntry = NewPasswordEntry()
@@ -471,7 +474,8 @@ func (a *App) buildMobileEncryptOptions() {
 	})
 	a.reedSolomonCheck.SetChecked(a.State.ReedSolomon)
 
-	a.deleteCheck = widget.NewCheck("Delete files", func(checked bool) {
+	// Deleting the originals always verifies the volume first, hence the label
+	a.deleteCheck = widget.NewCheck("Verify & delete", func(checked bool) {
 		a.State.Delete = checked
 	})
 	a.deleteCheck.SetChecked(a.State.Delete)
@@ -494,10 +498,17 @@ func (a *App) buildMobileEncryptOptions() {
 	})
 	a.recursivelyCheck.SetChecked(a.State.Recursively)
 
+	a.keepArchiveCheck = widget.NewCheck("Keep folder archive", func(checked bool) {
+		a.State.KeepArchive = checked
+		a.updateOutputFileForKeepArchive(checked)
+	})
+	a.keepArchiveCheck.SetChecked(a.State.KeepArchive)
+
 	// Grid layout - 2 columns
 	row1 := container.NewGridWithColumns(2, a.paranoidCheck, a.compressCheck)
 	row2 := container.NewGridWithColumns(2, a.reedSolomonCheck, a.deleteCheck)
 	row3 := container.NewGridWithColumns(2, a.deniabilityCheck, a.recursivelyCheck)
+	row4 := container.NewGridWithColumns(2, a.keepArchiveCheck)
 
 	// Split section
 	a.splitCheck = widget.NewCheck("Split:", func(checked bool) {
@@ -533,6 +544,7 @@ func (a *App) buildMobileEncryptOptions() {
 	a.advancedContainer.Add(row1)
 	a.advancedContainer.Add(row2)
 	a.advancedContainer.Add(row3)
+	a.advancedContainer.Add(row4)
 	a.advancedContainer.Add(splitRow)
 }
 
@@ -3,12 +3,18 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"sync"
+	"time"
 
 	"Picocrypt-NG/internal/app"
+	perrors "Picocrypt-NG/internal/errors"
 	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/log"
 	"Picocrypt-NG/internal/util"
 	"Picocrypt-NG/internal/volume"
 
@@ -47,6 +53,11 @@ func (a *App) startWork() {
 	a.State.CanCancel = true
 	a.State.ModalID++
 	a.cancelled.Store(false)
+	if a.cancelWork != nil {
+		// Release the previous operation's context
+		a.cancelWork(nil)
+	}
+	a.workCtx, a.cancelWork = context.WithCancelCause(context.Background())
 
 	a.showProgressModal()
 
@@ -60,6 +71,7 @@ func (a *App) startWork() {
 			if isMobile() {
 				a.CleanupMobileTempFiles()
 			}
+			warnings := a.State.TakeWarnings()
 			fyne.Do(func() {
 				if a.progressModal != nil {
 					a.progressModal.Hide()
@@ -67,6 +79,7 @@ func (a *App) startWork() {
 				// Rebuild advanced section (clears options, resizes window for empty mode)
 				a.updateAdvancedSection()
 				a.updateUIState()
+				a.showWarningsModal(warnings)
 			})
 		}()
 	} else {
@@ -103,81 +116,26 @@ func (a *App) startRecursiveWork() {
 		return
 	}
 
-	// Store all settings before they get cleared by onDrop/resetUI
-	savedPassword := a.State.Password
-	savedKeyfile := a.State.Keyfile
-	savedKeyfiles := make([]string, len(a.State.Keyfiles))
-	copy(savedKeyfiles, a.State.Keyfiles)
-	savedKeyfileOrdered := a.State.KeyfileOrdered
-	savedKeyfileLabel := a.State.KeyfileLabel
-	savedComments := a.State.Comments
-	savedParanoid := a.State.Paranoid
-	savedReedSolomon := a.State.ReedSolomon
-	savedDeniability := a.State.Deniability
-	savedSplit := a.State.Split
-	savedSplitSize := a.State.SplitSize
-	savedSplitSelected := a.State.SplitSelected
-	savedDelete := a.State.Delete
-
+	concurrency := a.State.Concurrency
+	if fit := volume.FitConcurrency(concurrency); fit < concurrency {
+		a.State.AddWarning(fmt.Sprintf("Processed %d files at a time instead of %d: each one needs 1 GiB of memory and not enough is free", fit, concurrency))
+		concurrency = fit
+	}
 	files := make([]string, len(a.State.AllFiles))
 	copy(files, a.State.AllFiles)
+	prepare := a.recursivePrepare()
 
 	go func() {
 		var failedCount int
 		var successCount int
-
-		for i, file := range files {
-			a.State.PopupStatus = fmt.Sprintf("Processing file %d/%d...", i+1, len(files))
-			// Use binding - automatically updates bound widget
-			_ = a.boundStatus.Set(a.State.PopupStatus)
-
-			a.onDrop([]string{file})
-
-			// Restore all saved settings
-			a.State.Password = savedPassword
-			a.State.CPassword = savedPassword
-			a.State.Keyfile = savedKeyfile
-			a.State.Keyfiles = make([]string, len(savedKeyfiles))
-			copy(a.State.Keyfiles, savedKeyfiles)
-			a.State.KeyfileOrdered = savedKeyfileOrdered
-			a.State.KeyfileLabel = savedKeyfileLabel
-			a.State.Comments = savedComments
-			a.State.Paranoid = savedParanoid
-			a.State.ReedSolomon = savedReedSolomon
-			if a.State.Mode != "decrypt" {
-				a.State.Deniability = savedDeniability
-			}
-			a.State.Split = savedSplit
-			a.State.SplitSize = savedSplitSize
-			a.State.SplitSelected = savedSplitSelected
-			a.State.Delete = savedDelete
-
-			if a.doWork() {
-				successCount++
-			} else {
-				failedCount++
-			}
-
-			// Reset Working flag so next iteration's onDrop() isn't blocked
-			// (onDrop has a guard to prevent race conditions during scanning/working)
-			a.State.Working = false
-
-			if a.cancelled.Load() {
-				a.State.Working = false
-				a.State.ShowProgress = false
-				// Clean up mobile temp files after cancellation
-				if isMobile() {
-					a.CleanupMobileTempFiles()
-				}
-				fyne.Do(func() {
-					if a.progressModal != nil {
-						a.progressModal.Hide()
-					}
-					a.updateAdvancedSection()
-					a.updateUIState()
-				})
-				return
-			}
+		if concurrency > 1 {
+			successCount, failedCount = a.runRecursiveParallel(files, prepare, concurrency)
+		} else {
+			successCount, failedCount = a.runRecursiveSequential(files, prepare)
+		}
+		if a.cancelled.Load() {
+			a.finishCancelledRecursiveWork()
+			return
 		}
 
 		a.State.Working = false
@@ -198,18 +156,278 @@ func (a *App) startRecursiveWork() {
 			a.State.MainStatusColor = util.YELLOW
 		}
 
+		warnings := a.State.TakeWarnings()
 		fyne.Do(func() {
 			if a.progressModal != nil {
 				a.progressModal.Hide()
 			}
 			a.updateAdvancedSection()
 			a.updateUIState()
+			a.showWarningsModal(warnings)
 		})
 	}()
 }
 
+// recursivePrepare returns the function a recursive run calls for each
+// file: it selects file as if it had been dropped on its own and restores
+// the settings saved now. It reports false if the file cannot be processed.
+func (a *App) recursivePrepare() func(file string) bool {
+	// Store all settings before they get cleared by onDrop/resetUI
+	savedPassword := a.State.Password
+	savedKeyfile := a.State.Keyfile
+	savedKeyfiles := make([]string, len(a.State.Keyfiles))
+	copy(savedKeyfiles, a.State.Keyfiles)
+	savedKeyfileOrdered := a.State.KeyfileOrdered
+	savedKeyfileLabel := a.State.KeyfileLabel
+	savedComments := a.State.Comments
+	savedParanoid := a.State.Paranoid
+	savedReedSolomon := a.State.ReedSolomon
+	savedDeniability := a.State.Deniability
+	savedSplit := a.State.Split
+	savedSplitSize := a.State.SplitSize
+	savedSplitSelected := a.State.SplitSelected
+	savedDelete := a.State.Delete
+	savedTemplate := a.State.OutputTemplate
+
+	return func(file string) bool {
+		a.onDrop([]string{file})
+
+		// Restore all saved settings
+		a.State.Password = savedPassword
+		a.State.CPassword = savedPassword
+		a.State.Keyfile = savedKeyfile
+		a.State.Keyfiles = make([]string, len(savedKeyfiles))
+		copy(a.State.Keyfiles, savedKeyfiles)
+		a.State.KeyfileOrdered = savedKeyfileOrdered
+		a.State.KeyfileLabel = savedKeyfileLabel
+		a.State.Comments = savedComments
+		a.State.Paranoid = savedParanoid
+		a.State.ReedSolomon = savedReedSolomon
+		if a.State.Mode != "decrypt" {
+			a.State.Deniability = savedDeniability
+		}
+		a.State.Split = savedSplit
+		a.State.SplitSize = savedSplitSize
+		a.State.SplitSelected = savedSplitSelected
+		a.State.Delete = savedDelete
+		a.State.OutputTemplate = savedTemplate
+
+		if savedTemplate != "" && a.State.Mode == "encrypt" {
+			output, err := fileops.ExpandOutputTemplate(savedTemplate, file, time.Now())
+			if err != nil {
+				log.Error("output template failed", log.String("file", file), log.Err(err))
+				return false
+			}
+			a.State.OutputFile = output
+		}
+		return true
+	}
+}
+
+// finishCancelledRecursiveWork closes the progress modal after a recursive
+// run was cancelled.
+func (a *App) finishCancelledRecursiveWork() {
+	a.State.Working = false
+	a.State.ShowProgress = false
+	// Clean up mobile temp files after cancellation
+	if isMobile() {
+		a.CleanupMobileTempFiles()
+	}
+	warnings := a.State.TakeWarnings()
+	fyne.Do(func() {
+		if a.progressModal != nil {
+			a.progressModal.Hide()
+		}
+		a.updateAdvancedSection()
+		a.updateUIState()
+		a.showWarningsModal(warnings)
+	})
+}
+
+// runRecursiveSequential processes files one at a time, stopping early if
+// cancelled.
+func (a *App) runRecursiveSequential(files []string, prepare func(string) bool) (successCount, failedCount int) {
+	for i, file := range files {
+		a.State.PopupStatus = fmt.Sprintf("Processing file %d/%d...", i+1, len(files))
+		// Use binding - automatically updates bound widget
+		_ = a.boundStatus.Set(a.State.PopupStatus)
+
+		if !prepare(file) {
+			failedCount++
+			a.State.Working = false
+			continue
+		}
+
+		if a.doWork() {
+			successCount++
+		} else {
+			failedCount++
+		}
+
+		// Reset Working flag so next iteration's onDrop() isn't blocked
+		// (onDrop has a guard to prevent race conditions during scanning/working)
+		a.State.Working = false
+
+		if a.cancelled.Load() {
+			break
+		}
+	}
+	return successCount, failedCount
+}
+
+// runRecursiveParallel processes files with up to concurrency of them at
+// once. Preparing a request goes through onDrop and the shared State, so
+// that is done one file at a time; the requests are then run through the
+// volume batch functions, which share nothing between requests.
+func (a *App) runRecursiveParallel(files []string, prepare func(string) bool, concurrency int) (successCount, failedCount int) {
+	progress := &batchProgress{a: a, fraction: make([]float32, len(files))}
+	var encReqs []*volume.EncryptRequest
+	var encSlots []int
+	var decReqs []*volume.DecryptRequest
+	var decSlots []int
+	kept := make([]bool, len(files))
+	for i, file := range files {
+		a.State.PopupStatus = fmt.Sprintf("Preparing file %d/%d...", i+1, len(files))
+		_ = a.boundStatus.Set(a.State.PopupStatus)
+
+		if !prepare(file) {
+			progress.finish(i)
+			failedCount++
+			continue
+		}
+		if a.State.Mode == "encrypt" {
+			req := a.encryptRequest(progress.reporter(i))
+			if req == nil {
+				progress.finish(i)
+				failedCount++
+				continue
+			}
+			encReqs = append(encReqs, req)
+			encSlots = append(encSlots, i)
+		} else {
+			decReqs = append(decReqs, a.decryptRequest(progress.reporter(i), &kept[i]))
+			decSlots = append(decSlots, i)
+		}
+	}
+
+	a.State.Working = true
+	var mu sync.Mutex
+	onDone := func(slots []int, inputs func(int) string) func(int, error) {
+		return func(index int, err error) {
+			progress.finish(slots[index])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && !errors.Is(err, perrors.ErrDeleteFailed) && !errors.Is(err, perrors.ErrUnzipFailed) {
+				log.Error("recursive operation failed", log.String("file", inputs(index)), log.Err(err))
+				failedCount++
+				return
+			}
+			successCount++
+		}
+	}
+	volume.EncryptBatch(a.workCtx, encReqs, volume.BatchOptions{
+		Concurrency: concurrency,
+		OnDone:      onDone(encSlots, func(i int) string { return encReqs[i].InputFile }),
+	})
+	volume.DecryptBatch(a.workCtx, decReqs, volume.BatchOptions{
+		Concurrency: concurrency,
+		OnDone:      onDone(decSlots, func(i int) string { return decReqs[i].InputFile }),
+	})
+
+	if successCount > 0 {
+		a.State.ResetUI()
+		a.clearCredentialEntries()
+	}
+	if slices.Contains(kept, true) {
+		a.State.Kept = true
+	}
+	return successCount, failedCount
+}
+
+// batchProgress shows the files a parallel recursive run processes as one
+// progress bar, each file counting for an equal share.
+type batchProgress struct {
+	a        *App
+	mu       sync.Mutex
+	fraction []float32
+	done     int
+}
+
+// reporter returns the reporter for file i. Per-file status text is not
+// shown, as it would flicker between the files running at once.
+func (p *batchProgress) reporter(i int) *app.UIReporter {
+	r := app.NewUIReporter(
+		func(string) {},
+		func(fraction float32, info string) {
+			p.mu.Lock()
+			p.fraction[i] = fraction
+			total := p.totalLocked()
+			p.mu.Unlock()
+			_ = p.a.boundProgress.Set(float64(total))
+		},
+		func(bool) {},
+		func() {},
+		p.a.cancelled.Load,
+	)
+	r.OnWarn = p.a.State.AddWarning
+	return r
+}
+
+// finish counts file i as done and updates the status line.
+func (p *batchProgress) finish(i int) {
+	p.mu.Lock()
+	p.fraction[i] = 1
+	p.done++
+	total := p.totalLocked()
+	status := fmt.Sprintf("Processed %d/%d files...", p.done, len(p.fraction))
+	p.mu.Unlock()
+	_ = p.a.boundProgress.Set(float64(total))
+	_ = p.a.boundStatus.Set(status)
+}
+
+func (p *batchProgress) totalLocked() float32 {
+	var sum float32
+	for _, f := range p.fraction {
+		sum += f
+	}
+	return sum / float32(len(p.fraction))
+}
+
 // doEncrypt performs encryption using the volume package.
 func (a *App) doEncrypt(reporter *app.UIReporter) bool {
+	req := a.encryptRequest(reporter)
+	if req == nil {
+		return false
+	}
+
+	err := volume.Encrypt(a.workCtx, req)
+	deleteFailed := errors.Is(err, perrors.ErrDeleteFailed)
+	if err != nil && !deleteFailed {
+		if !a.cancelled.Load() {
+			a.State.MainStatus = err.Error()
+			a.State.MainStatusColor = util.RED
+		}
+		return false
+	}
+
+	a.State.ResetUI()
+	a.State.LastOutput = req.OutputFile
+	a.State.MainStatus = "Completed"
+	a.State.MainStatusColor = util.GREEN
+	a.clearCredentialEntries()
+
+	if deleteFailed {
+		a.State.MainStatus = "Completed (some files couldn't be deleted)"
+		a.State.MainStatusColor = util.YELLOW
+	}
+
+	return true
+}
+
+// encryptRequest builds the encryption request for the current State. It
+// returns nil, with the reason in the main status, if the settings are
+// invalid.
+func (a *App) encryptRequest(reporter volume.ProgressReporter) *volume.EncryptRequest {
 	var chunkUnit fileops.SplitUnit
 	switch a.State.SplitSelected {
 	case 0:
@@ -230,42 +448,51 @@ func (a *App) doEncrypt(reporter *app.UIReporter) bool {
 		if err != nil || n <= 0 {
 			a.State.MainStatus = "Invalid split size"
 			a.State.MainStatusColor = util.RED
-			return false
+			return nil
 		}
 		chunkSize = n
 	}
 
 	shouldDelete := a.State.Delete
 
-	req := &volume.EncryptRequest{
-		InputFile:      a.State.InputFile,
-		InputFiles:     a.State.AllFiles,
-		OnlyFolders:    a.State.OnlyFolders,
-		OnlyFiles:      a.State.OnlyFiles,
-		OutputFile:     a.State.OutputFile,
-		Password:       a.State.Password,
-		Keyfiles:       a.State.Keyfiles,
-		KeyfileOrdered: a.State.KeyfileOrdered,
-		Comments:       a.State.Comments,
-		Paranoid:       a.State.Paranoid,
-		ReedSolomon:    a.State.ReedSolomon,
-		Deniability:    a.State.Deniability,
-		Compress:       a.State.Compress,
-		Split:          a.State.Split,
-		ChunkSize:      chunkSize,
-		ChunkUnit:      chunkUnit,
-		Reporter:       reporter,
-		RSCodecs:       a.rsCodecs,
+	return &volume.EncryptRequest{
+		InputFile:        a.State.InputFile,
+		InputFiles:       a.State.AllFiles,
+		OnlyFolders:      a.State.OnlyFolders,
+		OnlyFiles:        a.State.OnlyFiles,
+		OutputFile:       a.State.OutputFile,
+		Password:         a.State.Password,
+		MinPasswordScore: a.State.MinPasswordScore,
+		Keyfiles:         a.State.Keyfiles,
+		KeyfileOrdered:   a.State.KeyfileOrdered,
+		Comments:         a.State.Comments,
+		Paranoid:         a.State.Paranoid,
+		ReedSolomon:      a.State.ReedSolomon,
+		Deniability:      a.State.Deniability,
+		Compress:         a.State.Compress,
+		RawSingleFile:    !a.State.KeepArchive,
+		Split:            a.State.Split,
+		ChunkSize:        chunkSize,
+		ChunkUnit:        chunkUnit,
+		Reporter:         reporter,
+		RSCodecs:         a.rsCodecs,
+
+		// "Verify & delete": originals are only deleted once the volume
+		// has been re-read and verified
+		DeleteInputs:       shouldDelete,
+		VerifyAfterEncrypt: shouldDelete,
 	}
+}
 
-	filesToDelete := make([]string, len(a.State.AllFiles))
-	copy(filesToDelete, a.State.AllFiles)
-	foldersToDelete := make([]string, len(a.State.OnlyFolders))
-	copy(foldersToDelete, a.State.OnlyFolders)
-	inputFileToDelete := a.State.InputFile
+// doDecrypt performs decryption using the volume package.
+func (a *App) doDecrypt(reporter *app.UIReporter) bool {
+	kept := false
+	req := a.decryptRequest(reporter, &kept)
 
-	err := volume.Encrypt(context.Background(), req)
-	if err != nil {
+	err := volume.Decrypt(a.workCtx, req)
+	deleteFailed := errors.Is(err, perrors.ErrDeleteFailed)
+	unzipFailed := errors.Is(err, perrors.ErrUnzipFailed)
+	if err != nil && !deleteFailed && !unzipFailed {
 		if !a.cancelled.Load() {
 			a.State.MainStatus = err.Error()
 			a.State.MainStatusColor = util.RED
@@ -274,87 +501,56 @@ func (a *App) doEncrypt(reporter *app.UIReporter) bool {
 	}
 
 	a.State.ResetUI()
-	a.State.MainStatus = "Completed"
-	a.State.MainStatusColor = util.GREEN
+	a.State.LastOutput = req.OutputFile
+	a.clearCredentialEntries()
 
-	// Clear UI widgets to match the reset state
-	fyne.Do(func() {
-		if a.passwordEntry != nil {
-			a.passwordEntry.SetText("")
-		}
-		if a.cPasswordEntry != nil {
-			a.cPasswordEntry.SetText("")
-		}
-		if a.commentsEntry != nil {
-			a.commentsEntry.SetText("")
-		}
-		a.updatePasswordStrength()
-		a.updateValidation()
-	})
+	if kept {
+		a.State.Kept = true
+		a.State.MainStatus = "The input file was modified. Please be careful"
+		a.State.MainStatusColor = util.YELLOW
+	} else {
+		a.State.MainStatus = "Completed"
+		a.State.MainStatusColor = util.GREEN
+	}
 
-	if shouldDelete {
-		var deleteErrors []string
-		if len(filesToDelete) > 0 {
-			for _, f := range filesToDelete {
-				if err := os.Remove(f); err != nil {
-					deleteErrors = append(deleteErrors, f)
-				}
-			}
-			for _, f := range foldersToDelete {
-				if err := os.RemoveAll(f); err != nil {
-					deleteErrors = append(deleteErrors, f)
-				}
-			}
-		} else {
-			if err := os.Remove(inputFileToDelete); err != nil {
-				deleteErrors = append(deleteErrors, inputFileToDelete)
-			}
-		}
-		if len(deleteErrors) > 0 {
-			a.State.MainStatus = "Completed (some files couldn't be deleted)"
-			a.State.MainStatusColor = util.YELLOW
-		}
+	if unzipFailed {
+		// The decrypted .zip is kept in place of the extracted files
+		a.State.MainStatus = "Completed, but auto unzipping failed: " + err.Error()
+		a.State.MainStatusColor = util.YELLOW
+	}
+	if deleteFailed {
+		a.State.MainStatus = "Completed (volume couldn't be deleted)"
+		a.State.MainStatusColor = util.YELLOW
 	}
 
 	return true
 }
 
-// doDecrypt performs decryption using the volume package.
-func (a *App) doDecrypt(reporter *app.UIReporter) bool {
-	kept := false
-
-	shouldDelete := a.State.Delete
-	recombine := a.State.Recombine
-	inputFile := a.State.InputFile
-
-	req := &volume.DecryptRequest{
-		InputFile:    a.State.InputFile,
-		OutputFile:   a.State.OutputFile,
-		Password:     a.State.Password,
-		Keyfiles:     a.State.Keyfiles,
-		ForceDecrypt: a.State.Keep,
-		VerifyFirst:  a.State.VerifyFirst,
-		AutoUnzip:    a.State.AutoUnzip,
-		SameLevel:    a.State.SameLevel,
-		Recombine:    a.State.Recombine,
-		Deniability:  a.State.Deniability,
-		Reporter:     reporter,
-		RSCodecs:     a.rsCodecs,
-		Kept:         &kept,
-	}
-
-	err := volume.Decrypt(context.Background(), req)
-	if err != nil {
-		if !a.cancelled.Load() {
-			a.State.MainStatus = err.Error()
-			a.State.MainStatusColor = util.RED
-		}
-		return false
+// decryptRequest builds the decryption request for the current State;
+// kept receives whether a damaged volume was decrypted anyway.
+func (a *App) decryptRequest(reporter volume.ProgressReporter, kept *bool) *volume.DecryptRequest {
+	return &volume.DecryptRequest{
+		InputFile:      a.State.InputFile,
+		OutputFile:     a.State.OutputFile,
+		Password:       a.State.Password,
+		Keyfiles:       a.State.Keyfiles,
+		ForceDecrypt:   a.State.Keep,
+		VerifyFirst:    a.State.VerifyFirst,
+		AutoUnzip:      a.State.AutoUnzip,
+		SameLevel:      a.State.SameLevel,
+		Recombine:      a.State.Recombine,
+		Deniability:    a.State.Deniability,
+		DeleteVolume:   a.State.Delete,
+		WarnSameDevice: true,
+		Reporter:       reporter,
+		RSCodecs:       a.rsCodecs,
+		Kept:           kept,
 	}
+}
 
-	a.State.ResetUI()
-
-	// Clear UI widgets to match the reset state
+// clearCredentialEntries clears the password and comments widgets to match
+// a reset State.
+func (a *App) clearCredentialEntries() {
 	fyne.Do(func() {
 		if a.passwordEntry != nil {
 			a.passwordEntry.SetText("")
@@ -368,45 +564,11 @@ func (a *App) doDecrypt(reporter *app.UIReporter) bool {
 		a.updatePasswordStrength()
 		a.updateValidation()
 	})
-
-	if kept {
-		a.State.Kept = true
-		a.State.MainStatus = "The input file was modified. Please be careful"
-		a.State.MainStatusColor = util.YELLOW
-	} else {
-		a.State.MainStatus = "Completed"
-		a.State.MainStatusColor = util.GREEN
-	}
-
-	if shouldDelete && !kept {
-		var deleteError bool
-		if recombine {
-			for i := 0; ; i++ {
-				chunkPath := inputFile + "." + strconv.Itoa(i)
-				if _, err := os.Stat(chunkPath); os.IsNotExist(err) {
-					break
-				}
-				if err := os.Remove(chunkPath); err != nil {
-					deleteError = true
-				}
-			}
-		} else {
-			if err := os.Remove(inputFile); err != nil {
-				deleteError = true
-			}
-		}
-		if deleteError {
-			a.State.MainStatus = "Completed (volume couldn't be deleted)"
-			a.State.MainStatusColor = util.YELLOW
-		}
-	}
-
-	return true
 }
 
 // CreateReporter creates a UIReporter for progress updates.
 func (a *App) CreateReporter() *app.UIReporter {
-	return app.NewUIReporter(
+	r := app.NewUIReporter(
 		func(text string) {
 			a.State.PopupStatus = text
 			// Use binding - automatically thread-safe and updates bound widgets
@@ -440,4 +602,7 @@ func (a *App) CreateReporter() *app.UIReporter {
 			return !a.State.Working
 		},
 	)
+	// Collected for the warnings panel shown when the operation finishes
+	r.OnWarn = a.State.AddWarning
+	return r
 }
@@ -2,7 +2,9 @@
 package ui
 
 import (
-	"github.com/Picocrypt/zxcvbn-go"
+	"fmt"
+
+	"Picocrypt-NG/internal/util"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -66,8 +68,11 @@ func (a *App) buildPasswordSection() fyne.CanvasObject {
 	}
 
 	a.strengthIndicator = NewPasswordStrengthIndicator()
+	a.entropyLabel = widget.NewLabel("")
+	a.entropyLabel.Hide()
 
-	passwordRow := container.NewBorder(nil, nil, nil, a.strengthIndicator, a.passwordEntry)
+	passwordRow := container.NewBorder(nil, nil, nil,
+		container.NewHBox(a.entropyLabel, a.strengthIndicator), a.passwordEntry)
 
 	// Confirm password
 	a.cPasswordEntry = NewPasswordEntry()
@@ -98,14 +103,31 @@ func (a *App) buildPasswordSection() fyne.CanvasObject {
 	)
 }
 
-// updatePasswordStrength updates the password strength indicator.
+// updatePasswordStrength updates the password strength indicator and the
+// estimated entropy label next to it, which also warns about common passwords.
 func (a *App) updatePasswordStrength() {
-	a.State.PasswordStrength = zxcvbn.PasswordStrength(a.State.Password, nil).Score
+	a.State.PasswordStrength, a.State.PasswordEntropy = util.PasswordStrength(a.State.Password)
+	a.State.PasswordCommon = util.IsCommonPassword(a.State.Password)
 	if a.strengthIndicator != nil {
 		a.strengthIndicator.SetStrength(a.State.PasswordStrength)
 		a.strengthIndicator.SetVisible(a.State.Password != "")
 		a.strengthIndicator.SetDecryptMode(a.State.Mode == "decrypt")
 	}
+	if a.entropyLabel != nil {
+		text := fmt.Sprintf("%.0f bits", a.State.PasswordEntropy)
+		if a.State.PasswordCommon {
+			text += ", common password"
+		}
+		if a.State.PasswordStrength < a.State.MinPasswordScore {
+			text += ", too weak to encrypt"
+		}
+		a.entropyLabel.SetText(text)
+		if a.State.Password != "" && a.State.Mode != "decrypt" {
+			a.entropyLabel.Show()
+		} else {
+			a.entropyLabel.Hide()
+		}
+	}
 }
 
 // updateValidation updates the password validation indicator.
@@ -401,3 +401,28 @@ func TestPasswordStrengthIndicatorVisibility(t *testing.T) {
 		})
 	}
 }
+
+// TestPassgenSymbolSetError tests the message shown for an unusable custom
+// symbol set.
+func TestPassgenSymbolSetError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		symbols bool
+		set     string
+		wantMsg bool
+	}{
+		{"Valid", true, "!@#", false},
+		{"Empty", true, "", true},
+		{"Space", true, "! #", true},
+		{"NonASCII", true, "!é", true},
+		{"SymbolsOff", false, "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if msg := passgenSymbolSetError(tc.symbols, tc.set); (msg != "") != tc.wantMsg {
+				t.Errorf("passgenSymbolSetError(%v, %q) = %q; want message %v", tc.symbols, tc.set, msg, tc.wantMsg)
+			}
+		})
+	}
+}
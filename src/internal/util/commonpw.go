@@ -0,0 +1,50 @@
+package util
+
+import (
+	"crypto/sha1"
+	_ "embed"
+	"encoding/binary"
+)
+
+//go:generate go run gen_commonpw.go
+
+// commonPasswordFilter is a bloom filter over the SHA-1 digests of the
+// passwords in testdata/common_passwords.txt, built by gen_commonpw.go.
+// It is checked offline; nothing ever leaves the machine.
+//
+//go:embed common_passwords.bloom
+var commonPasswordFilter []byte
+
+// commonPasswordProbes is the number of bits set per password. With 4 KiB of
+// filter and a few hundred entries the false positive rate is far below one
+// in a billion, so generated passwords are never flagged in practice.
+const commonPasswordProbes = 16
+
+// IsCommonPassword reports whether pw appears in the bundled list of common
+// and breached passwords. It is meant for a warning, not for rejecting a
+// password: a bloom filter can return false positives.
+//
+// Every probe is evaluated without an early exit, so the running time does
+// not depend on how many bits match.
+func IsCommonPassword(pw string) bool {
+	if pw == "" {
+		return false
+	}
+	sum := sha1.Sum([]byte(pw))
+	return commonPasswordFilterHas(commonPasswordFilter, sum)
+}
+
+// commonPasswordFilterHas checks the probe bits for a SHA-1 digest.
+// gen_commonpw.go must derive the same bit positions when setting them.
+func commonPasswordFilterHas(filter []byte, sum [sha1.Size]byte) bool {
+	bits := uint64(len(filter)) * 8
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	hit := byte(1)
+	for i := range uint64(commonPasswordProbes) {
+		bit := (h1 + i*h2) % bits
+		hit &= filter[bit/8] >> (bit % 8)
+	}
+	return hit&1 == 1
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIsCommonPassword(t *testing.T) {
+	t.Run("bundled list is flagged", func(t *testing.T) {
+		// Fails if the embedded filter is stale; run go generate after editing the list
+		f, err := os.Open("testdata/common_passwords.txt")
+		if err != nil {
+			t.Fatalf("Failed to open list: %v", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			pw := strings.TrimRight(scanner.Text(), "\r")
+			if pw != "" && !IsCommonPassword(pw) {
+				t.Errorf("IsCommonPassword(%q) = false, want true", pw)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("Failed to read list: %v", err)
+		}
+	})
+
+	t.Run("well-known passwords", func(t *testing.T) {
+		for _, pw := range []string{"password", "123456", "qwerty", "letmein", "iloveyou", "P@ssw0rd"} {
+			if !IsCommonPassword(pw) {
+				t.Errorf("IsCommonPassword(%q) = false, want true", pw)
+			}
+		}
+	})
+
+	t.Run("generated passwords are not flagged", func(t *testing.T) {
+		for range 1000 {
+			pw, err := GenPassword(PassgenOptions{Length: 16, Upper: true, Lower: true, Numbers: true, Symbols: true})
+			if err != nil {
+				t.Fatalf("GenPassword failed: %v", err)
+			}
+			if IsCommonPassword(pw) {
+				t.Errorf("IsCommonPassword(%q) = true for a generated password", pw)
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if IsCommonPassword("") {
+			t.Error("IsCommonPassword(\"\") = true, want false")
+		}
+	})
+}
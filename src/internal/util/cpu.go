@@ -0,0 +1,65 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup filesystem is mounted; tests point it at
+// a fake tree.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// EffectiveCPUs returns how many CPUs this process can actually use:
+// GOMAXPROCS, lowered to the cgroup CPU quota on Linux when one is set.
+// Go versions before 1.25 do not apply the quota to GOMAXPROCS themselves.
+func EffectiveCPUs() int {
+	n := runtime.GOMAXPROCS(0)
+	if runtime.GOOS == "linux" {
+		if quota := cgroupCPUQuota(cgroupRoot); quota > 0 && quota < n {
+			n = quota
+		}
+	}
+	return n
+}
+
+// cgroupCPUQuota returns the CPU quota under root rounded up to whole
+// CPUs, from cgroup v2 cpu.max or the cgroup v1 CFS files. Returns 0 when
+// there is no limit or it cannot be read.
+func cgroupCPUQuota(root string) int {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if data, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			return quotaCPUs(fields[0], fields[1])
+		}
+		return 0
+	}
+
+	// cgroup v1: quota is -1 when unlimited
+	quota, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0
+	}
+	period, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0
+	}
+	return quotaCPUs(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// quotaCPUs converts a CFS quota and period in microseconds to CPUs,
+// rounding up so a 1.5 CPU quota allows 2 threads.
+func quotaCPUs(quota, period string) int {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return int((q + p - 1) / p)
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCgroupCPUQuota(t *testing.T) {
+	write := func(t *testing.T, root, name, content string) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{"v2_limited", map[string]string{"cpu.max": "200000 100000\n"}, 2},
+		{"v2_fractional", map[string]string{"cpu.max": "150000 100000\n"}, 2},
+		{"v2_unlimited", map[string]string{"cpu.max": "max 100000\n"}, 0},
+		{"v1_limited", map[string]string{
+			"cpu/cpu.cfs_quota_us":  "300000\n",
+			"cpu/cpu.cfs_period_us": "100000\n",
+		}, 3},
+		{"v1_unlimited", map[string]string{
+			"cpu/cpu.cfs_quota_us":  "-1\n",
+			"cpu/cpu.cfs_period_us": "100000\n",
+		}, 0},
+		{"none", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				write(t, root, name, content)
+			}
+			if got := cgroupCPUQuota(root); got != tt.want {
+				t.Errorf("cgroupCPUQuota() = %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveCPUsPositive(t *testing.T) {
+	if n := EffectiveCPUs(); n < 1 {
+		t.Errorf("EffectiveCPUs() = %d; want >= 1", n)
+	}
+}
@@ -0,0 +1,14 @@
+package util
+
+// Device identifies the storage device holding a path.
+type Device struct {
+	ID        uint64 // Equal for paths on the same filesystem
+	Removable bool   // The device is removable media, such as a USB drive or SD card
+}
+
+// DeviceOf returns the Device holding path, which must exist. Removable
+// media are recognized on Linux and Windows; elsewhere Removable is always
+// false. Platforms without device IDs return errors.ErrUnsupported.
+func DeviceOf(path string) (Device, error) {
+	return deviceOf(path)
+}
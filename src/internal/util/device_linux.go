@@ -0,0 +1,40 @@
+//go:build linux
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+func deviceOf(path string) (Device, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return Device{}, err
+	}
+	dev := uint64(st.Dev) // Dev is uint32 on some architectures
+	return Device{ID: dev, Removable: removableBlock(dev)}, nil
+}
+
+// removableBlock reports whether the block device dev, or the disk a
+// partition dev belongs to, is marked removable in sysfs or attached over
+// USB, as many USB disks do not claim to be removable.
+func removableBlock(dev uint64) bool {
+	sys, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(dev), unix.Minor(dev)))
+	if err != nil {
+		return false
+	}
+	if strings.Contains(sys, "/usb") {
+		return true
+	}
+	for _, dir := range []string{sys, filepath.Dir(sys)} {
+		if b, err := os.ReadFile(filepath.Join(dir, "removable")); err == nil {
+			return strings.TrimSpace(string(b)) == "1"
+		}
+	}
+	return false
+}
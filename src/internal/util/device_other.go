@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package util
+
+import "errors"
+
+func deviceOf(path string) (Device, error) {
+	return Device{}, errors.ErrUnsupported
+}
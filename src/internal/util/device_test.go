@@ -0,0 +1,35 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeviceOf(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dirDev, err := DeviceOf(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("device IDs are not available on this platform")
+	}
+	if err != nil {
+		t.Fatalf("DeviceOf failed: %v", err)
+	}
+	fileDev, err := DeviceOf(file)
+	if err != nil {
+		t.Fatalf("DeviceOf failed: %v", err)
+	}
+	if fileDev != dirDev {
+		t.Errorf("DeviceOf(file) = %+v; want its directory's %+v", fileDev, dirDev)
+	}
+
+	if _, err := DeviceOf(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build unix && !linux
+
+package util
+
+import "golang.org/x/sys/unix"
+
+func deviceOf(path string) (Device, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return Device{}, err
+	}
+	return Device{ID: uint64(st.Dev)}, nil // Dev is int32 on some systems
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package util
+
+import "golang.org/x/sys/windows"
+
+func deviceOf(path string) (Device, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return Device{}, err
+	}
+	root := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &root[0], uint32(len(root))); err != nil {
+		return Device{}, err
+	}
+	var serial uint32
+	if err := windows.GetVolumeInformation(&root[0], nil, 0, &serial, nil, nil, nil, 0); err != nil {
+		return Device{}, err
+	}
+	return Device{
+		ID:        uint64(serial),
+		Removable: windows.GetDriveType(&root[0]) == windows.DRIVE_REMOVABLE,
+	}, nil
+}
@@ -0,0 +1,22 @@
+package util
+
+import "github.com/Picocrypt/zxcvbn-go"
+
+// PasswordStrength runs the zxcvbn analysis once and returns both the score
+// the strength indicator shows, from 0 (guessable) to 4 (very strong), and
+// the estimated entropy in bits. Dictionary words, keyboard patterns and
+// repeats score low; random strings score close to length * log2(charset
+// size). Returns 0, 0 for an empty password.
+func PasswordStrength(pw string) (score int, bits float64) {
+	if pw == "" {
+		return 0, 0
+	}
+	result := zxcvbn.PasswordStrength(pw, nil)
+	return result.Score, result.Entropy
+}
+
+// PasswordScore returns just the score of PasswordStrength.
+func PasswordScore(pw string) int {
+	score, _ := PasswordStrength(pw)
+	return score
+}
@@ -0,0 +1,55 @@
+package util
+
+import "testing"
+
+func TestPasswordStrength(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		if score, bits := PasswordStrength(""); score != 0 || bits != 0 {
+			t.Errorf("PasswordStrength(\"\") = %d, %v, want 0, 0", score, bits)
+		}
+	})
+
+	t.Run("increases with length", func(t *testing.T) {
+		prev := 0.0
+		for _, length := range []int{8, 16, 32, 64} {
+			pw, err := GenPassword(PassgenOptions{Length: length, Upper: true, Lower: true, Numbers: true, Symbols: true})
+			if err != nil {
+				t.Fatalf("GenPassword failed: %v", err)
+			}
+			score, bits := PasswordStrength(pw)
+			if score != PasswordScore(pw) {
+				t.Errorf("length %d: score %d differs from PasswordScore", length, score)
+			}
+			if bits <= prev {
+				t.Errorf("length %d: %.1f bits, not more than %.1f for the shorter password", length, bits, prev)
+			}
+			prev = bits
+		}
+	})
+
+	t.Run("common passwords are low", func(t *testing.T) {
+		for _, pw := range []string{"password", "123456", "qwerty", "letmein"} {
+			if _, bits := PasswordStrength(pw); bits > 20 {
+				t.Errorf("PasswordStrength(%q) = %.1f bits, want <= 20", pw, bits)
+			}
+		}
+	})
+}
+
+func TestPasswordScore(t *testing.T) {
+	if got := PasswordScore(""); got != 0 {
+		t.Errorf("PasswordScore(\"\") = %d, want 0", got)
+	}
+	for _, pw := range []string{"password", "123456", "qwerty"} {
+		if score := PasswordScore(pw); score > 1 {
+			t.Errorf("PasswordScore(%q) = %d, want <= 1", pw, score)
+		}
+	}
+	pw, err := GenPassword(PassgenOptions{Length: 32, Upper: true, Lower: true, Numbers: true, Symbols: true})
+	if err != nil {
+		t.Fatalf("GenPassword failed: %v", err)
+	}
+	if score := PasswordScore(pw); score != 4 {
+		t.Errorf("PasswordScore of a generated password = %d, want 4", score)
+	}
+}
@@ -0,0 +1,33 @@
+package util
+
+import (
+	"os/exec"
+	"path/filepath"
+)
+
+// startCommand launches name without waiting for it to exit; replaced in
+// tests.
+var startCommand = func(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the process once the file browser has been handed the folder
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// ShowInFolder opens the system file browser at the directory holding path:
+// explorer on Windows, open on macOS and xdg-open on other Unix systems.
+// It returns an error where no file browser is available.
+func ShowInFolder(path string) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	name, args, err := folderCommand(dir)
+	if err != nil {
+		return err
+	}
+	return startCommand(name, args...)
+}
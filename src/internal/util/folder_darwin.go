@@ -0,0 +1,7 @@
+//go:build darwin
+
+package util
+
+func folderCommand(dir string) (string, []string, error) {
+	return "open", []string{dir}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package util
+
+import "errors"
+
+func folderCommand(dir string) (string, []string, error) {
+	return "", nil, errors.ErrUnsupported
+}
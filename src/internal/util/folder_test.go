@@ -0,0 +1,66 @@
+//go:build unix || windows
+
+package util
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestShowInFolder(t *testing.T) {
+	var gotName string
+	var gotArgs []string
+	orig := startCommand
+	startCommand = func(name string, args ...string) error {
+		gotName, gotArgs = name, args
+		return nil
+	}
+	defer func() { startCommand = orig }()
+
+	dir := t.TempDir()
+	if err := ShowInFolder(filepath.Join(dir, "volume.pcv")); err != nil {
+		t.Fatalf("ShowInFolder failed: %v", err)
+	}
+
+	want := map[string]string{"windows": "explorer", "darwin": "open"}[runtime.GOOS]
+	if want == "" {
+		want = "xdg-open"
+	}
+	if gotName != want {
+		t.Errorf("Launcher = %q; want %q", gotName, want)
+	}
+	if len(gotArgs) != 1 || gotArgs[0] != dir {
+		t.Errorf("Launcher args = %q; want [%q]", gotArgs, dir)
+	}
+}
+
+func TestShowInFolderRelative(t *testing.T) {
+	var gotArgs []string
+	orig := startCommand
+	startCommand = func(name string, args ...string) error {
+		gotArgs = args
+		return nil
+	}
+	defer func() { startCommand = orig }()
+
+	if err := ShowInFolder("volume.pcv"); err != nil {
+		t.Fatalf("ShowInFolder failed: %v", err)
+	}
+	want, _ := filepath.Abs(".")
+	if len(gotArgs) != 1 || gotArgs[0] != want {
+		t.Errorf("Launcher args = %q; want [%q]", gotArgs, want)
+	}
+}
+
+func TestShowInFolderLaunchError(t *testing.T) {
+	launchErr := errors.New("no file browser")
+	orig := startCommand
+	startCommand = func(name string, args ...string) error { return launchErr }
+	defer func() { startCommand = orig }()
+
+	if err := ShowInFolder("volume.pcv"); !errors.Is(err, launchErr) {
+		t.Errorf("ShowInFolder error = %v; want %v", err, launchErr)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build unix && !darwin
+
+package util
+
+func folderCommand(dir string) (string, []string, error) {
+	return "xdg-open", []string{dir}, nil
+}
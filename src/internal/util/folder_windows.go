@@ -0,0 +1,7 @@
+//go:build windows
+
+package util
+
+func folderCommand(dir string) (string, []string, error) {
+	return "explorer", []string{dir}, nil
+}
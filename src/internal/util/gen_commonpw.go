@@ -0,0 +1,51 @@
+//go:build ignore
+
+// gen_commonpw builds common_passwords.bloom from testdata/common_passwords.txt.
+// Run it with "go generate" after editing the list.
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	filterSize = 4096 // Bytes
+	probes     = 16   // Must match commonPasswordProbes
+)
+
+func main() {
+	f, err := os.Open("testdata/common_passwords.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	filter := make([]byte, filterSize)
+	bits := uint64(filterSize) * 8
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pw := strings.TrimRight(scanner.Text(), "\r")
+		if pw == "" {
+			continue
+		}
+		sum := sha1.Sum([]byte(pw))
+		h1 := binary.BigEndian.Uint64(sum[0:8])
+		h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+		for i := range uint64(probes) {
+			bit := (h1 + i*h2) % bits
+			filter[bit/8] |= 1 << (bit % 8)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile("common_passwords.bloom", filter, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,9 @@
+package util
+
+// AvailableMemory returns roughly how many bytes of memory can still be
+// allocated without swapping: the system's available memory, lowered to
+// the room left under the cgroup memory limit on Linux. Platforms without a
+// way to ask return errors.ErrUnsupported.
+func AvailableMemory() (int64, error) {
+	return availableMemory()
+}
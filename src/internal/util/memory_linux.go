@@ -0,0 +1,70 @@
+//go:build linux
+
+package util
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func availableMemory() (int64, error) {
+	avail, err := memAvailable("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	if room := cgroupMemoryRoom(cgroupRoot); room >= 0 && room < avail {
+		avail = room
+	}
+	return avail, nil
+}
+
+// memAvailable reads MemAvailable from a /proc/meminfo style file.
+func memAvailable(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// "MemAvailable:   12345678 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemAvailable:" && fields[2] == "kB" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb * KiB, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.ErrUnsupported
+}
+
+// cgroupMemoryRoom returns how far the cgroup v2 memory use under root is
+// from its limit. Returns -1 when there is no limit or it cannot be read.
+func cgroupMemoryRoom(root string) int64 {
+	data, err := os.ReadFile(filepath.Join(root, "memory.max"))
+	if err != nil {
+		return -1
+	}
+	limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return -1 // "max" means unlimited
+	}
+	data, err = os.ReadFile(filepath.Join(root, "memory.current"))
+	if err != nil {
+		return -1
+	}
+	used, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return max(limit-used, 0)
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemAvailable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meminfo")
+	meminfo := "MemTotal:        8000000 kB\nMemFree:          500000 kB\nMemAvailable:    3000000 kB\n"
+	if err := os.WriteFile(path, []byte(meminfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := memAvailable(path)
+	if err != nil {
+		t.Fatalf("memAvailable failed: %v", err)
+	}
+	if want := int64(3000000 * KiB); got != want {
+		t.Errorf("memAvailable() = %d; want %d", got, want)
+	}
+}
+
+func TestCgroupMemoryRoom(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  int64
+	}{
+		{"limited", map[string]string{"memory.max": "4294967296\n", "memory.current": "1073741824\n"}, 3 * GiB},
+		{"over_limit", map[string]string{"memory.max": "1073741824\n", "memory.current": "2147483648\n"}, 0},
+		{"unlimited", map[string]string{"memory.max": "max\n", "memory.current": "1073741824\n"}, -1},
+		{"none", nil, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := cgroupMemoryRoom(root); got != tt.want {
+				t.Errorf("cgroupMemoryRoom() = %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package util
+
+import "errors"
+
+func availableMemory() (int64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,19 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAvailableMemory(t *testing.T) {
+	avail, err := AvailableMemory()
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("available memory is not known on this platform")
+	}
+	if err != nil {
+		t.Fatalf("AvailableMemory failed: %v", err)
+	}
+	if avail < 0 {
+		t.Errorf("AvailableMemory = %d; want a non-negative size", avail)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package util
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGlobalMemoryStatusEx = windows.NewLazySystemDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+
+// memoryStatusEx is the Win32 MEMORYSTATUSEX structure.
+type memoryStatusEx struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+func availableMemory() (int64, error) {
+	st := memoryStatusEx{length: uint32(unsafe.Sizeof(memoryStatusEx{}))}
+	if r, _, err := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&st))); r == 0 {
+		return 0, err
+	}
+	return int64(st.availPhys), nil
+}
@@ -32,6 +32,37 @@ type PassgenOptions struct {
 	Lower   bool // Include lowercase letters a-z
 	Numbers bool // Include digits 0-9
 	Symbols bool // Include symbols -=_+!@#$^&()?<>
+
+	// SymbolSet replaces DefaultSymbols when Symbols is set, e.g. to match a
+	// site's password policy. It is checked with ParseSymbolSet; empty
+	// keeps the default.
+	SymbolSet string
+}
+
+// DefaultSymbols are the symbols of the generator unless
+// PassgenOptions.SymbolSet replaces them.
+const DefaultSymbols = "-=_+!@#$^&()?<>"
+
+// ParseSymbolSet checks a custom symbol set and returns it with repeated
+// characters removed, first occurrences kept in order. Only printable ASCII
+// other than space is allowed, and the set must not be empty.
+func ParseSymbolSet(set string) (string, error) {
+	var seen [128]bool
+	symbols := make([]byte, 0, len(set))
+	for i := 0; i < len(set); i++ {
+		c := set[i]
+		if c <= ' ' || c > '~' {
+			return "", fmt.Errorf("symbol set has a character that is not printable ASCII: %q", set[i:i+1])
+		}
+		if !seen[c] {
+			seen[c] = true
+			symbols = append(symbols, c)
+		}
+	}
+	if len(symbols) == 0 {
+		return "", errors.New("symbol set is empty")
+	}
+	return string(symbols), nil
 }
 
 // GenPassword generates a cryptographically secure password based on the given options.
@@ -43,10 +74,11 @@ type PassgenOptions struct {
 //   - Upper: ABCDEFGHIJKLMNOPQRSTUVWXYZ (26 characters)
 //   - Lower: abcdefghijklmnopqrstuvwxyz (26 characters)
 //   - Numbers: 1234567890 (10 characters)
-//   - Symbols: -=_+!@#$^&()?<> (15 characters)
+//   - Symbols: -=_+!@#$^&()?<> (15 characters), or SymbolSet
 //
 // Returns:
 //   - Empty string if no character sets are enabled or Length <= 0
+//   - Error if SymbolSet is invalid
 //   - Error if crypto/rand fails (extremely rare, indicates system issue)
 //
 // Example:
@@ -60,6 +92,27 @@ type PassgenOptions struct {
 //	})
 //	// Generates: "aB7xK9mPzR3qW8nL5tY2"
 func GenPassword(opts PassgenOptions) (string, error) {
+	chars, err := passgenCharset(opts)
+	if err != nil {
+		return "", err
+	}
+	if len(chars) == 0 || opts.Length <= 0 {
+		return "", nil
+	}
+
+	tmp := make([]byte, opts.Length)
+	for i := range opts.Length {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", fmt.Errorf("fatal crypto/rand error: %w", err)
+		}
+		tmp[i] = chars[j.Int64()]
+	}
+	return string(tmp), nil
+}
+
+// passgenCharset returns the characters enabled in opts.
+func passgenCharset(opts PassgenOptions) (string, error) {
 	chars := ""
 	if opts.Upper {
 		chars += "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -71,20 +124,53 @@ func GenPassword(opts PassgenOptions) (string, error) {
 		chars += "1234567890"
 	}
 	if opts.Symbols {
-		chars += "-=_+!@#$^&()?<>"
+		symbols := DefaultSymbols
+		if opts.SymbolSet != "" {
+			var err error
+			if symbols, err = ParseSymbolSet(opts.SymbolSet); err != nil {
+				return "", err
+			}
+		}
+		chars += symbols
 	}
+	return chars, nil
+}
 
+// GeneratePasswords generates n distinct passwords with GenPassword, e.g. to
+// provision several accounts at once. Each password is drawn independently
+// from crypto/rand; the rare duplicate is replaced by a fresh draw.
+//
+// Returns an error if n <= 0, no character set is enabled, Length <= 0,
+// SymbolSet is invalid, or opts cannot produce n distinct passwords (e.g.
+// 100 two-digit PINs).
+func GeneratePasswords(n int, opts PassgenOptions) ([]string, error) {
+	if n <= 0 {
+		return nil, errors.New("invalid count")
+	}
+	chars, err := passgenCharset(opts)
+	if err != nil {
+		return nil, err
+	}
 	if len(chars) == 0 || opts.Length <= 0 {
-		return "", nil
+		return nil, errors.New("no characters to generate from")
+	}
+	space := new(big.Int).Exp(big.NewInt(int64(len(chars))), big.NewInt(int64(opts.Length)), nil)
+	if space.Cmp(big.NewInt(int64(n))) < 0 {
+		return nil, fmt.Errorf("only %s distinct passwords possible, %d requested", space, n)
 	}
 
-	tmp := make([]byte, opts.Length)
-	for i := range opts.Length {
-		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	passwords := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for len(passwords) < n {
+		password, err := GenPassword(opts)
 		if err != nil {
-			return "", fmt.Errorf("fatal crypto/rand error: %w", err)
+			return nil, err
 		}
-		tmp[i] = chars[j.Int64()]
+		if seen[password] {
+			continue
+		}
+		seen[password] = true
+		passwords = append(passwords, password)
 	}
-	return string(tmp), nil
+	return passwords, nil
 }
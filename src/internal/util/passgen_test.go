@@ -169,3 +169,106 @@ func TestRandomBytesInvalidLength(t *testing.T) {
 		t.Error("RandomBytes(-1) should return error")
 	}
 }
+
+func TestGeneratePasswords(t *testing.T) {
+	opts := PassgenOptions{Length: 20, Upper: true, Numbers: true}
+	passwords, err := GeneratePasswords(50, opts)
+	if err != nil {
+		t.Fatalf("GeneratePasswords failed: %v", err)
+	}
+	if len(passwords) != 50 {
+		t.Fatalf("got %d passwords; want 50", len(passwords))
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range passwords {
+		if seen[p] {
+			t.Errorf("duplicate password %q", p)
+		}
+		seen[p] = true
+		if len(p) != 20 {
+			t.Errorf("password %q has length %d; want 20", p, len(p))
+		}
+		for _, c := range p {
+			if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+				t.Errorf("password %q contains invalid char: %c", p, c)
+			}
+		}
+	}
+
+	// Every two-digit PIN fits, one more does not
+	pins, err := GeneratePasswords(100, PassgenOptions{Length: 2, Numbers: true})
+	if err != nil {
+		t.Fatalf("GeneratePasswords(100 PINs) failed: %v", err)
+	}
+	distinct := make(map[string]bool)
+	for _, p := range pins {
+		distinct[p] = true
+	}
+	if len(distinct) != 100 {
+		t.Errorf("got %d distinct PINs; want 100", len(distinct))
+	}
+	if _, err := GeneratePasswords(101, PassgenOptions{Length: 2, Numbers: true}); err == nil {
+		t.Error("expected error when more passwords are requested than exist")
+	}
+
+	if _, err := GeneratePasswords(0, opts); err == nil {
+		t.Error("expected error for zero count")
+	}
+	if _, err := GeneratePasswords(5, PassgenOptions{Length: 20}); err == nil {
+		t.Error("expected error with no character sets")
+	}
+}
+
+func TestGenPasswordSymbolSet(t *testing.T) {
+	opts := PassgenOptions{Length: 200, Lower: true, Symbols: true, SymbolSet: "*%*%~"}
+	password, err := GenPassword(opts)
+	if err != nil {
+		t.Fatalf("GenPassword failed: %v", err)
+	}
+	for _, c := range password {
+		if !strings.ContainsRune("*%~", c) && (c < 'a' || c > 'z') {
+			t.Errorf("Password contains char outside the custom symbol set: %c", c)
+		}
+		if strings.ContainsRune(DefaultSymbols, c) {
+			t.Errorf("Password contains default symbol %c despite a custom set", c)
+		}
+	}
+
+	passwords, err := GeneratePasswords(5, PassgenOptions{Length: 8, Symbols: true, SymbolSet: "#"})
+	if err == nil {
+		t.Errorf("GeneratePasswords from a single symbol = %v; want an error", passwords)
+	}
+	for _, set := range []string{"a b", "é", "\t"} {
+		if _, err := GenPassword(PassgenOptions{Length: 8, Symbols: true, SymbolSet: set}); err == nil {
+			t.Errorf("GenPassword with symbol set %q succeeded; want an error", set)
+		}
+	}
+	// Without Symbols the set is not used, so it is not checked either
+	if _, err := GenPassword(PassgenOptions{Length: 8, Upper: true, SymbolSet: "\t"}); err != nil {
+		t.Errorf("GenPassword without symbols failed on an unused set: %v", err)
+	}
+}
+
+func TestParseSymbolSet(t *testing.T) {
+	tests := []struct {
+		set     string
+		want    string
+		wantErr bool
+	}{
+		{"!@#", "!@#", false},
+		{"!!@@##!", "!@#", false},
+		{"~", "~", false},
+		{DefaultSymbols, DefaultSymbols, false},
+		{"", "", true},
+		{"!@ #", "", true},
+		{"!\x7f", "", true},
+		{"£$", "", true},
+	}
+	for _, tc := range tests {
+		got, err := ParseSymbolSet(tc.set)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("ParseSymbolSet(%q) = %q, %v; want %q, error %v", tc.set, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
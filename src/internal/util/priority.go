@@ -0,0 +1,13 @@
+package util
+
+// LowPriorityNice is the niceness LowerPriority applies on Unix, the same
+// as nice(1) uses by default.
+const LowPriorityNice = 10
+
+// LowerPriority lowers the scheduling priority of the current process so a
+// background operation yields to foreground work: nice +10 on Unix, the
+// below-normal priority class on Windows. It is a no-op elsewhere. Once
+// lowered, the priority cannot be raised again without privileges.
+func LowerPriority() error {
+	return lowerPriority()
+}
@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package util
+
+func lowerPriority() error {
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package util
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+func lowerPriority() error {
+	if err := unix.Setpriority(unix.PRIO_PROCESS, 0, LowPriorityNice); err != nil {
+		return err
+	}
+
+	// On Linux niceness is per thread, and Go has already started several;
+	// apply it to each so work scheduled on any of them is affected
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil
+	}
+	for _, task := range tasks {
+		if tid, err := strconv.Atoi(task.Name()); err == nil {
+			_ = unix.Setpriority(unix.PRIO_PROCESS, tid, LowPriorityNice)
+		}
+	}
+	return nil
+}
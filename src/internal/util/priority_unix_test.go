@@ -0,0 +1,42 @@
+//go:build unix
+
+package util
+
+import (
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// niceness returns the niceness of the calling thread. Linux getpriority
+// returns 20 - nice so the result is never negative; other systems return
+// the niceness itself.
+func niceness(t *testing.T) int {
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, 0)
+	if err != nil {
+		t.Fatalf("Getpriority failed: %v", err)
+	}
+	if runtime.GOOS == "linux" {
+		return 20 - prio
+	}
+	return prio
+}
+
+func TestLowerPriority(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	before := niceness(t)
+	if before >= LowPriorityNice {
+		t.Skipf("Already running at niceness %d", before)
+	}
+
+	if err := LowerPriority(); err != nil {
+		t.Skipf("Lowering priority not permitted: %v", err)
+	}
+
+	if after := niceness(t); after <= before {
+		t.Errorf("Niceness %d after LowerPriority; want more than %d", after, before)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package util
+
+import "golang.org/x/sys/windows"
+
+func lowerPriority() error {
+	return windows.SetPriorityClass(windows.CurrentProcess(), windows.BELOW_NORMAL_PRIORITY_CLASS)
+}
@@ -0,0 +1,8 @@
+package util
+
+// FreeSpace returns the number of bytes available to the current user on
+// the filesystem holding path, which must exist. Platforms without a way to
+// ask return errors.ErrUnsupported.
+func FreeSpace(path string) (int64, error) {
+	return freeSpace(path)
+}
@@ -0,0 +1,24 @@
+package util
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFreeSpace(t *testing.T) {
+	free, err := FreeSpace(t.TempDir())
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("free space is not available on this platform")
+	}
+	if err != nil {
+		t.Fatalf("FreeSpace failed: %v", err)
+	}
+	if free <= 0 {
+		t.Errorf("FreeSpace = %d; want a positive size", free)
+	}
+
+	if _, err := FreeSpace(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
@@ -0,0 +1,100 @@
+package volume
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// encryptArmor writes the finished volume as armored text. With Armor the
+// text goes to OutputFile + ".asc" beside the volume; with ArmorOnly it
+// replaces the volume at OutputFile. The text is built in a .incomplete file
+// first, so a failure leaves the binary volume untouched.
+func encryptArmor(ctx *OperationContext, req *EncryptRequest) error {
+	ctx.SetStatus("Armoring volume...")
+
+	target := req.OutputFile + encoding.ArmorExt
+	if req.ArmorOnly {
+		target = req.OutputFile
+	}
+	tmpPath := req.OutputFile + encoding.ArmorExt + ".incomplete"
+
+	if err := armorFile(req.OutputFile, tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := fileops.Rename(tmpPath, target); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("rename armored output: %w", err)
+	}
+	return nil
+}
+
+// armorFile writes the armored text of the file at src to dst.
+func armorFile(src, dst string) error {
+	fin, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open volume: %w", err)
+	}
+	defer func() { _ = fin.Close() }()
+
+	fout, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create armored output: %w", err)
+	}
+	defer func() { _ = fout.Close() }()
+
+	if err := encoding.Armor(fout, fin); err != nil {
+		return fmt.Errorf("armor volume: %w", err)
+	}
+	if err := fout.Sync(); err != nil {
+		return fmt.Errorf("sync armored output: %w", err)
+	}
+	return fout.Close()
+}
+
+// isArmored is encoding.IsArmored for a file read through fs.
+func isArmored(fs FileSystem, path string) bool {
+	f, err := fs.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	return encoding.HasArmorBegin(f)
+}
+
+// dearmorVolume decodes the armored volume at path into a binary volume
+// next to it and returns the new path. The caller removes it when done.
+func dearmorVolume(ctx *OperationContext, path string) (string, error) {
+	ctx.SetStatus("Decoding armored volume...")
+
+	fin, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open input: %w", err)
+	}
+	defer func() { _ = fin.Close() }()
+
+	r, err := encoding.NewArmorReader(fin)
+	if err != nil {
+		return "", err
+	}
+
+	outPath := path + ".dearmored"
+	fout, err := os.Create(outPath)
+	if err != nil {
+		return "", fmt.Errorf("create dearmored volume: %w", err)
+	}
+	if _, err := io.Copy(fout, r); err != nil {
+		_ = fout.Close()
+		_ = os.Remove(outPath)
+		return "", fmt.Errorf("decode armored volume: %w", err)
+	}
+	if err := fout.Close(); err != nil {
+		_ = os.Remove(outPath)
+		return "", fmt.Errorf("close dearmored volume: %w", err)
+	}
+	return outPath, nil
+}
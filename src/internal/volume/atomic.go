@@ -0,0 +1,96 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"Picocrypt-NG/internal/crypto"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// stageOutput points req.OutputFile at a hidden, randomly named file beside
+// the output for AtomicOutput, and remembers the real path in
+// ctx.PublishPath. Every later phase then writes under the staging name.
+func stageOutput(ctx *OperationContext, req *EncryptRequest) error {
+	suffix, err := crypto.RandomBytes(4)
+	if err != nil {
+		return err
+	}
+	ctx.PublishPath = req.OutputFile
+	req.OutputFile = filepath.Join(filepath.Dir(req.OutputFile),
+		fmt.Sprintf(".%s.%x.stage", filepath.Base(req.OutputFile), suffix))
+	return nil
+}
+
+// publishOutputs fsyncs each staged output and renames it to its final
+// name, which is atomic within a directory, so a final name only ever holds
+// finished content. Outputs are published last first: the armored copy
+// before the volume, and chunks from the highest index down, so the main
+// name or chunk .0 appears only once everything else is in place.
+func publishOutputs(ctx *OperationContext, req *EncryptRequest) error {
+	ctx.SetStatus("Publishing output...")
+
+	staged := producedOutputs(req)
+	if req.Split {
+		if err := removeSupersededChunks(ctx.PublishPath, len(staged)); err != nil {
+			return err
+		}
+	}
+	for i := len(staged) - 1; i >= 0; i-- {
+		path := staged[i]
+		if err := syncPath(path); err != nil {
+			return fmt.Errorf("sync %s: %w", path, err)
+		}
+		target := ctx.PublishPath + strings.TrimPrefix(path, req.OutputFile)
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("publish output: %w", err)
+		}
+	}
+	req.OutputFile = ctx.PublishPath
+	ctx.PublishPath = ""
+	return nil
+}
+
+// removeSupersededChunks deletes the chunks of an earlier split under the
+// final name that the new chunks will not replace: those numbered count and
+// up, and any .incomplete ones. Split only cleared the staging name, and
+// Recombine would otherwise append the leftovers to the new volume.
+func removeSupersededChunks(basePath string, count int) error {
+	existing, err := fileops.ChunkFiles(basePath)
+	if err != nil {
+		return fmt.Errorf("publish output: %w", err)
+	}
+	keep := make(map[string]bool, count)
+	for i := range count {
+		keep[filepath.Clean(basePath+"."+strconv.Itoa(i))] = true
+	}
+	for _, chunk := range existing {
+		if keep[filepath.Clean(chunk)] {
+			continue
+		}
+		if err := os.Remove(chunk); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("publish output: %w", err)
+		}
+	}
+	return nil
+}
+
+// discardStaged removes whatever a failed AtomicOutput encryption left
+// under the staging name and restores req.OutputFile. Nothing is kept for
+// inspection, since the output was never published.
+func discardStaged(ctx *OperationContext, req *EncryptRequest) {
+	if ctx.PublishPath == "" {
+		return
+	}
+	for _, path := range producedOutputs(req) {
+		_ = os.Remove(path)
+	}
+	_ = os.Remove(req.OutputFile)
+	_, _ = fileops.RemoveStaleChunks(req.OutputFile)
+	req.OutputFile = ctx.PublishPath
+	ctx.PublishPath = ""
+}
@@ -0,0 +1,283 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/header"
+)
+
+// publishObserver records what the final output path holds at every
+// reporter callback, which every phase of an encryption makes
+type publishObserver struct {
+	GoldenTestReporter
+	path string
+	seen [][]byte
+}
+
+func (o *publishObserver) observe() {
+	if data, err := os.ReadFile(o.path); err == nil {
+		o.seen = append(o.seen, data)
+	}
+}
+
+func (o *publishObserver) SetStatus(text string) {
+	o.GoldenTestReporter.SetStatus(text)
+	o.observe()
+}
+
+func (o *publishObserver) SetProgress(fraction float32, info string) { o.observe() }
+
+func (o *publishObserver) Update() { o.observe() }
+
+// TestAtomicOutput tests that with AtomicOutput the final path never holds
+// anything but its finished content, where without it the observer catches
+// the intermediate volume, and that no staged files are left behind
+func TestAtomicOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping atomic output test in short mode")
+	}
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	// One output rewritten in place after the volume is written, as
+	// deniability also does, and one spread over chunk files
+	tests := []struct {
+		name  string
+		setup func(req *EncryptRequest)
+	}{
+		{"armor only", func(req *EncryptRequest) { req.ArmorOnly = true }},
+		{"split", func(req *EncryptRequest) {
+			req.Split = true
+			req.ChunkSize = 3
+			req.ChunkUnit = fileops.SplitUnitTotal
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, atomic := range []bool{false, true} {
+			name := tt.name
+			if atomic {
+				name += " atomic"
+			}
+			t.Run(name, func(t *testing.T) {
+				tmpDir := t.TempDir()
+				inputPath := filepath.Join(tmpDir, "input.txt")
+				if err := os.WriteFile(inputPath, bytes.Repeat([]byte("atomic "), 20000), 0644); err != nil {
+					t.Fatal(err)
+				}
+				outputPath := filepath.Join(tmpDir, "out.pcv")
+				observer := &publishObserver{path: outputPath}
+				req := &EncryptRequest{
+					InputFile:    inputPath,
+					OutputFile:   outputPath,
+					Password:     "atomic_password",
+					AtomicOutput: atomic,
+					Reporter:     observer,
+					RSCodecs:     rsCodecs,
+				}
+				tt.setup(req)
+				if err := Encrypt(context.Background(), req); err != nil {
+					t.Fatalf("Encrypt failed: %v", err)
+				}
+				if req.OutputFile != outputPath {
+					t.Errorf("OutputFile = %q; want %q", req.OutputFile, outputPath)
+				}
+
+				final, _ := os.ReadFile(outputPath) // nil for a split output
+				intermediate := false
+				for _, data := range observer.seen {
+					if !bytes.Equal(data, final) {
+						intermediate = true
+					}
+				}
+				if atomic && intermediate {
+					t.Error("final path held intermediate content")
+				}
+				if !atomic && !intermediate {
+					t.Error("observer saw no intermediate content without AtomicOutput")
+				}
+
+				entries, err := os.ReadDir(tmpDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, e := range entries {
+					if strings.HasPrefix(e.Name(), ".") || strings.Contains(e.Name(), "incomplete") {
+						t.Errorf("%s left behind", e.Name())
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestAtomicOutputFailure tests that a staged volume that fails
+// verification is removed without ever appearing under its final name
+func TestAtomicOutputFailure(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	if err := os.WriteFile(inputPath, []byte("staged then discarded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testHookBeforeVerify = func(path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("staged volume missing: %v", err)
+			return
+		}
+		data[len(data)-1] ^= 0xFF
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Error(err)
+		}
+	}
+	t.Cleanup(func() { testHookBeforeVerify = nil })
+
+	outputPath := filepath.Join(tmpDir, "out.pcv")
+	observer := &publishObserver{path: outputPath}
+	req := &EncryptRequest{
+		InputFile:          inputPath,
+		OutputFile:         outputPath,
+		Password:           "atomic_password",
+		AtomicOutput:       true,
+		VerifyAfterEncrypt: true,
+		Reporter:           observer,
+		RSCodecs:           rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); err == nil {
+		t.Fatal("Encrypt succeeded with a corrupted staged volume")
+	}
+	if len(observer.seen) != 0 {
+		t.Error("final path appeared although the volume failed verification")
+	}
+	if req.OutputFile != outputPath {
+		t.Errorf("OutputFile = %q; want %q", req.OutputFile, outputPath)
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %q; want only the input", names)
+	}
+}
+
+// TestAtomicOutputSupersededChunks tests that publishing a split output
+// removes the chunks of an earlier, longer split under the final name, so
+// recombining reads only the new ones
+func TestAtomicOutputSupersededChunks(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	content := bytes.Repeat([]byte("superseded "), 2000)
+	if err := os.WriteFile(inputPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	outputPath := filepath.Join(tmpDir, "out.pcv")
+	for _, name := range []string{"out.pcv.0", "out.pcv.1", "out.pcv.2", "out.pcv.3", "out.pcv.4", "out.pcv.5.incomplete"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("old chunk"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:    inputPath,
+		OutputFile:   outputPath,
+		Password:     "atomic_password",
+		AtomicOutput: true,
+		Split:        true,
+		ChunkSize:    3,
+		ChunkUnit:    fileops.SplitUnitTotal,
+		Reporter:     &GoldenTestReporter{},
+		RSCodecs:     rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	for _, name := range []string{"out.pcv.3", "out.pcv.4", "out.pcv.5.incomplete"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s from the earlier split survived the publish", name)
+		}
+	}
+
+	decryptedPath := filepath.Join(tmpDir, "decrypted.txt")
+	if err := Decrypt(context.Background(), &DecryptRequest{
+		InputFile:  outputPath,
+		OutputFile: decryptedPath,
+		Password:   "atomic_password",
+		Recombine:  true,
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Decrypt (recombine) failed: %v", err)
+	}
+	if got, _ := os.ReadFile(decryptedPath); !bytes.Equal(got, content) {
+		t.Error("decrypted content differs")
+	}
+}
+
+// TestAtomicOutputStoredName tests that a zipped volume records the name of
+// its final path, not the staging name
+func TestAtomicOutputStoredName(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, path)
+	}
+	outputPath := filepath.Join(tmpDir, "bundle.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles:        inputs,
+		OnlyFiles:         inputs,
+		OutputFile:        outputPath,
+		Password:          "atomic_password",
+		StoreOriginalName: true,
+		AtomicOutput:      true,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open volume: %v", err)
+	}
+	result, err := header.NewReader(f, rsCodecs).ReadHeader()
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	if got := filepath.Base(DecryptOutputName(outputPath, result.Header)); got != "bundle.zip" {
+		t.Errorf("stored name = %q; want bundle.zip", got)
+	}
+}
@@ -0,0 +1,81 @@
+package volume
+
+import (
+	"context"
+	"sync"
+
+	"Picocrypt-NG/internal/util"
+)
+
+// BatchOptions controls EncryptBatch and DecryptBatch.
+type BatchOptions struct {
+	// Concurrency is the most requests run at once; 0 or 1 runs them one
+	// after another. Every running request derives its own key, and Argon2
+	// takes 1 GiB per derivation, so memory use peaks at about Concurrency
+	// GiB. Batches lower it with FitConcurrency to what the machine has room
+	// for when they start.
+	Concurrency int
+
+	// OnDone, if set, is called with the index and result of each request
+	// as it finishes, possibly from several goroutines at once.
+	OnDone func(index int, err error)
+}
+
+// EncryptBatch encrypts each request independently, as Encrypt would, and
+// returns the errors in request order. The requests must not share outputs
+// or a Reporter. Requests not yet started when ctx is cancelled fail with
+// the cancellation error without doing any work.
+func EncryptBatch(ctx context.Context, reqs []*EncryptRequest, opts BatchOptions) []error {
+	return runBatch(ctx, reqs, opts, Encrypt)
+}
+
+// DecryptBatch is EncryptBatch for decryption.
+func DecryptBatch(ctx context.Context, reqs []*DecryptRequest, opts BatchOptions) []error {
+	return runBatch(ctx, reqs, opts, Decrypt)
+}
+
+// FitConcurrency lowers concurrency to the number of 1 GiB key derivations
+// that fit in the memory still available, never below 1. It is returned
+// unchanged when the available memory cannot be read.
+func FitConcurrency(concurrency int) int {
+	avail, err := util.AvailableMemory()
+	if err != nil {
+		return concurrency
+	}
+	return fitConcurrency(concurrency, avail)
+}
+
+func fitConcurrency(concurrency int, avail int64) int {
+	return int(max(min(int64(concurrency), avail/util.GiB), 1))
+}
+
+// runBatch calls run for each request on up to opts.Concurrency goroutines.
+func runBatch[R any](ctx context.Context, reqs []R, opts BatchOptions, run func(context.Context, R) error) []error {
+	errs := make([]error, len(reqs))
+	slots := make(chan struct{}, FitConcurrency(max(opts.Concurrency, 1)))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			<-slots
+			errs[i] = cancellationError(ctx)
+			if opts.OnDone != nil {
+				opts.OnDone(i, errs[i])
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			errs[i] = run(ctx, req)
+			if opts.OnDone != nil {
+				opts.OnDone(i, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
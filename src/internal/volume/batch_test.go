@@ -0,0 +1,162 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/util"
+)
+
+// batchSlotReporter counts the requests between their first status and
+// OnDone, which is what BatchOptions.Concurrency bounds.
+type batchSlotReporter struct {
+	GoldenTestReporter
+	started sync.Once
+	running *atomic.Int32
+	peak    *atomic.Int32
+}
+
+func (r *batchSlotReporter) SetStatus(text string) {
+	r.started.Do(func() {
+		n := r.running.Add(1)
+		for {
+			p := r.peak.Load()
+			if n <= p || r.peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+	})
+}
+
+func TestEncryptBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping concurrent batch test in short mode")
+	}
+	// Four derivations at once hold 4 GiB, fewer where FitConcurrency finds
+	// less room; without a limit the GC lets finished ones pile up to twice
+	// that before collecting. Hand the memory back before restoring it so
+	// the next test does not start on top of them.
+	defer func(limit int64) {
+		debug.FreeOSMemory()
+		debug.SetMemoryLimit(limit)
+	}(debug.SetMemoryLimit(4608 << 20))
+
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	const files, concurrency = 10, 4
+	tmpDir := t.TempDir()
+	var running, peak atomic.Int32
+	var encReqs []*EncryptRequest
+	var decReqs []*DecryptRequest
+	for i := range files {
+		input := filepath.Join(tmpDir, fmt.Sprintf("file%d.txt", i))
+		data := bytes.Repeat([]byte(fmt.Sprintf("batch file %d\n", i)), 100*(i+1))
+		if err := os.WriteFile(input, data, 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		encReqs = append(encReqs, &EncryptRequest{
+			InputFile:   input,
+			OutputFile:  input + ".pcv",
+			Password:    "batch_password",
+			ReedSolomon: i%2 == 1,
+			Reporter:    &batchSlotReporter{running: &running, peak: &peak},
+			RSCodecs:    rsCodecs,
+		})
+		decReqs = append(decReqs, &DecryptRequest{
+			InputFile:  input + ".pcv",
+			OutputFile: input + ".out",
+			Password:   "batch_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+	}
+
+	var mu sync.Mutex
+	done := make(map[int]bool)
+	errs := EncryptBatch(context.Background(), encReqs, BatchOptions{
+		Concurrency: concurrency,
+		OnDone: func(index int, err error) {
+			running.Add(-1)
+			mu.Lock()
+			defer mu.Unlock()
+			if done[index] {
+				t.Errorf("OnDone called twice for request %d", index)
+			}
+			done[index] = true
+		},
+	})
+	if len(errs) != files {
+		t.Fatalf("Got %d results for %d requests", len(errs), files)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Encrypt %d failed: %v", i, err)
+		}
+	}
+	if len(done) != files {
+		t.Errorf("OnDone called for %d of %d requests", len(done), files)
+	}
+	if p := peak.Load(); p > concurrency {
+		t.Errorf("%d requests ran at once; Concurrency is %d", p, concurrency)
+	}
+
+	// Every volume decrypts to its own input
+	for i, err := range DecryptBatch(context.Background(), decReqs, BatchOptions{Concurrency: concurrency}) {
+		if err != nil {
+			t.Fatalf("Decrypt %d failed: %v", i, err)
+		}
+		want, _ := os.ReadFile(encReqs[i].InputFile)
+		got, err := os.ReadFile(decReqs[i].OutputFile)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Decrypted file %d does not match its input (err: %v)", i, err)
+		}
+	}
+
+	// Requests after a cancellation are not started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reqs := []*EncryptRequest{{
+		InputFile:  encReqs[0].InputFile,
+		OutputFile: filepath.Join(tmpDir, "cancelled.pcv"),
+		Password:   "batch_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}}
+	if errs := EncryptBatch(ctx, reqs, BatchOptions{Concurrency: concurrency}); !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", errs[0])
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "cancelled.pcv.incomplete")); !os.IsNotExist(err) {
+		t.Error("A cancelled batch should not write anything")
+	}
+}
+
+func TestFitConcurrency(t *testing.T) {
+	tests := []struct {
+		concurrency int
+		avail       int64
+		want        int
+	}{
+		{4, 8 * util.GiB, 4},
+		{4, 3*util.GiB + util.GiB/2, 3},
+		{4, util.GiB / 2, 1},
+		{2, 0, 1},
+		{0, 8 * util.GiB, 1},
+	}
+	for _, tt := range tests {
+		if got := fitConcurrency(tt.concurrency, tt.avail); got != tt.want {
+			t.Errorf("fitConcurrency(%d, %d) = %d; want %d", tt.concurrency, tt.avail, got, tt.want)
+		}
+	}
+}
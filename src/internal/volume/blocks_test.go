@@ -0,0 +1,183 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/util"
+)
+
+func TestVerifyBlocks(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := make([]byte, 2*util.MiB+util.MiB/2) // Three blocks, the last one short
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	inputPath := filepath.Join(tmpDir, "blocks.bin")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "blocks.bin.pcv")
+
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  volumePath,
+		Password:    "block_password",
+		BlockHashes: true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	volume, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+	payloadStart := len(volume) - len(plaintext)
+
+	verify := func(t *testing.T, data []byte) *BlockReport {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "copy.pcv")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write volume copy: %v", err)
+		}
+		report, err := VerifyBlocks(context.Background(), &DecryptRequest{
+			InputFile: path,
+			Password:  "block_password",
+			Reporter:  &GoldenTestReporter{},
+			RSCodecs:  rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("VerifyBlocks failed: %v", err)
+		}
+		return report
+	}
+
+	t.Run("full", func(t *testing.T) {
+		report := verify(t, volume)
+		if !report.Complete() || report.Blocks != 3 || len(report.Corrupt) != 0 || report.Missing != 0 {
+			t.Errorf("unexpected report for intact volume: %+v", report)
+		}
+		if report.VerifiedBytes != int64(len(volume)) {
+			t.Errorf("VerifiedBytes = %d; want %d", report.VerifiedBytes, len(volume))
+		}
+
+		// The volume still decrypts normally
+		outputPath := filepath.Join(t.TempDir(), "out.bin")
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:   volumePath,
+			OutputFile:  outputPath,
+			Password:    "block_password",
+			VerifyFirst: true,
+			Reporter:    &GoldenTestReporter{},
+			RSCodecs:    rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		got, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Error("decrypted content mismatch")
+		}
+	})
+
+	t.Run("corrupt_block", func(t *testing.T) {
+		damaged := bytes.Clone(volume)
+		damaged[payloadStart+util.MiB+12345] ^= 0x01
+		report := verify(t, damaged)
+		if len(report.Corrupt) != 1 || report.Corrupt[0] != 1 {
+			t.Errorf("Corrupt = %v; want [1]", report.Corrupt)
+		}
+		if report.Verified != 2 || report.Complete() {
+			t.Errorf("unexpected report: %+v", report)
+		}
+		if want := int64(payloadStart + util.MiB); report.VerifiedBytes != want {
+			t.Errorf("VerifiedBytes = %d; want %d", report.VerifiedBytes, want)
+		}
+	})
+
+	t.Run("truncated_prefix", func(t *testing.T) {
+		cut := payloadStart + util.MiB + 1000
+		report := verify(t, volume[:cut])
+		if report.Verified != 1 || report.Missing != 2 || len(report.Corrupt) != 0 {
+			t.Errorf("unexpected report for prefix: %+v", report)
+		}
+		if want := int64(payloadStart + util.MiB); report.VerifiedBytes != want {
+			t.Errorf("VerifiedBytes = %d; want %d", report.VerifiedBytes, want)
+		}
+	})
+
+	t.Run("tampered_table", func(t *testing.T) {
+		// Swapping the first two table entries keeps each entry decodable but
+		// must fail header authentication
+		tampered := bytes.Clone(volume)
+		entry := payloadStart - int(header.BlockTableSize(3)) + header.BlockCountEncSize
+		size := header.BlockHashEncSize
+		first := bytes.Clone(tampered[entry : entry+size])
+		copy(tampered[entry:], tampered[entry+size:entry+2*size])
+		copy(tampered[entry+size:], first)
+		path := filepath.Join(t.TempDir(), "tampered.pcv")
+		if err := os.WriteFile(path, tampered, 0644); err != nil {
+			t.Fatalf("Failed to write volume copy: %v", err)
+		}
+		_, err := VerifyBlocks(context.Background(), &DecryptRequest{
+			InputFile: path,
+			Password:  "block_password",
+			Reporter:  &GoldenTestReporter{},
+			RSCodecs:  rsCodecs,
+		})
+		if err == nil {
+			t.Fatal("expected tampered block table to fail authentication")
+		}
+	})
+}
+
+func TestVerifyBlocksWithoutTable(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "plain.txt")
+	if err := os.WriteFile(inputPath, []byte("no block hashes"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "plain.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "block_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	_, err = VerifyBlocks(context.Background(), &DecryptRequest{
+		InputFile: volumePath,
+		Password:  "block_password",
+		RSCodecs:  rsCodecs,
+	})
+	if !errors.Is(err, perrors.ErrNoBlockHashes) {
+		t.Errorf("expected ErrNoBlockHashes, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// bufferDir is the one directory of a bufferFS.
+const bufferDir = "picocrypt-memory"
+
+// EncryptBytes returns data sealed as a complete volume, built entirely in
+// memory so neither the plaintext nor the volume touches the disk. The
+// credentials and options are taken from req, which is not modified; its
+// inputs, outputs and FS are replaced, and its Reporter may be nil. Options
+// that work on temporary files of their own (Compress, Split, Deniability,
+// Armor, RecordSplit and the deletion and durability options) are not
+// supported.
+func EncryptBytes(ctx context.Context, data []byte, req *EncryptRequest) ([]byte, error) {
+	fs := newBufferFS()
+	input := filepath.Join(bufferDir, "data")
+	output := filepath.Join(bufferDir, "data.pcv")
+	fs.files[input] = bytes.Clone(data)
+
+	r := *req
+	r.InputFile, r.InputFiles, r.OnlyFiles, r.OnlyFolders = input, nil, nil, nil
+	r.OutputFile, r.OutputName = output, ""
+	r.CreateOutputDirs, r.AtomicOutput = false, false
+	r.FS = fs
+	if r.Reporter == nil {
+		r.Reporter = nopReporter{}
+	}
+	if err := Encrypt(ctx, &r); err != nil {
+		return nil, err
+	}
+	return fs.files[output], nil
+}
+
+// DecryptBytes decrypts and authenticates a volume held in memory, such as
+// one returned by EncryptBytes, and returns the plaintext. The credentials
+// and options are taken from req, which is not modified; its input, outputs
+// and FS are replaced, and its Reporter may be nil. Split and deniable
+// volumes are not supported.
+func DecryptBytes(ctx context.Context, volume []byte, req *DecryptRequest) ([]byte, error) {
+	fs := newBufferFS()
+	input := filepath.Join(bufferDir, "data.pcv")
+	fs.files[input] = bytes.Clone(volume)
+
+	var out bytes.Buffer
+	r := *req
+	r.InputFile, r.OutputFile = input, ""
+	r.Output, r.DiscardOutput = &out, false
+	r.Recombine, r.DeleteVolume, r.AutoUnzip = false, false, false
+	r.FS = fs
+	if r.Reporter == nil {
+		r.Reporter = nopReporter{}
+	}
+	if err := Decrypt(ctx, &r); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// bufferFS is a FileSystem held in memory with a single directory,
+// bufferDir, for EncryptBytes and DecryptBytes.
+type bufferFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newBufferFS() *bufferFS {
+	return &bufferFS{files: make(map[string][]byte)}
+}
+
+func (fs *bufferFS) Open(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *bufferFS) Create(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *bufferFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if filepath.Dir(name) != bufferDir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	_, exists := fs.files[name]
+	switch {
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case !exists || flag&os.O_TRUNC != 0:
+		fs.files[name] = nil
+	}
+	return &bufferFile{fs: fs, name: name}, nil
+}
+
+func (fs *bufferFS) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if name == bufferDir {
+		return bufferInfo{name: name, dir: true}, nil
+	}
+	data, ok := fs.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return bufferInfo{name: filepath.Base(name), size: int64(len(data))}, nil
+}
+
+func (fs *bufferFS) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	data, ok := fs.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	fs.files[newpath] = data
+	delete(fs.files, oldpath)
+	return nil
+}
+
+func (fs *bufferFS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, ok := fs.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs.files, name)
+	return nil
+}
+
+type bufferFile struct {
+	fs   *bufferFS
+	name string
+	off  int64
+}
+
+func (f *bufferFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	data := f.fs.files[f.name]
+	if f.off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[f.off:])
+	f.off += int64(n)
+	return n, nil
+}
+
+func (f *bufferFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *bufferFile) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	data := f.fs.files[f.name]
+	if end := off + int64(len(p)); end > int64(len(data)) {
+		data = append(data, make([]byte, end-int64(len(data)))...)
+	}
+	copy(data[off:], p)
+	f.fs.files[f.name] = data
+	return len(p), nil
+}
+
+func (f *bufferFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		f.fs.mu.Lock()
+		offset += int64(len(f.fs.files[f.name]))
+		f.fs.mu.Unlock()
+	}
+	if offset < 0 {
+		return 0, errors.New("negative seek")
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *bufferFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	data := f.fs.files[f.name]
+	if size <= int64(len(data)) {
+		f.fs.files[f.name] = data[:size]
+	} else {
+		f.fs.files[f.name] = append(data, make([]byte, size-int64(len(data)))...)
+	}
+	return nil
+}
+
+func (f *bufferFile) Name() string { return f.name }
+func (f *bufferFile) Sync() error  { return nil }
+func (f *bufferFile) Close() error { return nil }
+
+type bufferInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i bufferInfo) Name() string       { return i.name }
+func (i bufferInfo) Size() int64        { return i.size }
+func (i bufferInfo) ModTime() time.Time { return time.Time{} }
+func (i bufferInfo) IsDir() bool        { return i.dir }
+func (i bufferInfo) Sys() any           { return nil }
+func (i bufferInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0700
+	}
+	return 0600
+}
@@ -0,0 +1,339 @@
+package volume
+
+// Content-defined chunking (EncryptRequest.CDCDedup).
+//
+// The plaintext is cut where a gear rolling hash over the last 64 bytes hits
+// a fixed pattern, so boundaries depend on content rather than position: an
+// insertion only moves the boundaries around it. Each chunk is sealed with
+// XChaCha20-Poly1305 under a nonce derived from the chunk itself (SIV
+// style), and stored as a record:
+//
+//	[length uint32 BE][nonce 24][ciphertext + tag]
+//
+// where length is the plaintext length. The dedup key is derived from the
+// credentials with a fixed salt, so the same chunk gives the same record in
+// every volume made with the same credentials. The records go through the
+// volume MAC as usual, which catches reordered, dropped or replayed records.
+//
+// Security tradeoffs, compared to a regular volume:
+//   - Equal chunks are visible: anyone holding two volumes (or two versions
+//     of one) learns which chunks they share and, within a volume, which
+//     chunks repeat. Nothing else about the plaintext is revealed.
+//   - Chunk lengths are stored in the clear and, being content-defined, are
+//     a fingerprint of the data a guesser could match against.
+//   - The fixed salt lets an attacker precompute dedup keys for guessed
+//     passwords once for all CDC volumes, instead of once per volume. Use a
+//     strong password or a keyfile.
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"Picocrypt-NG/internal/crypto"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/keyfile"
+	"Picocrypt-NG/internal/util"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/sha3"
+)
+
+// Chunk size bounds. With cdcAvgBits the hash matches once per MiB on
+// average past the minimum.
+const (
+	cdcMinChunk = 256 << 10
+	cdcMaxChunk = 4 << 20
+	cdcAvgBits  = 20
+	cdcWindow   = 64 // Bytes the top hash bits depend on
+)
+
+// cdcRecordHeader is the length and nonce in front of each sealed chunk.
+const cdcRecordHeader = 4 + chacha20poly1305.NonceSizeX
+
+// cdcRecordOverhead is what each record adds to its chunk.
+const cdcRecordOverhead = cdcRecordHeader + chacha20poly1305.Overhead
+
+// cdcMask selects the top cdcAvgBits hash bits, which cover the whole window.
+const cdcMask = (uint64(1)<<cdcAvgBits - 1) << (64 - cdcAvgBits)
+
+// cdcSalt is the fixed Argon2 salt (and HKDF salt) of the dedup key. It must
+// never change: records are only comparable across volumes because of it.
+var cdcSalt = []byte("Picocrypt-NG CDC")
+
+// cdcGear is the gear hash table, expanded from a fixed seed so boundaries
+// are the same in every build.
+var cdcGear = func() (gear [256]uint64) {
+	shake := sha3.NewShake128()
+	_, _ = shake.Write([]byte("Picocrypt-NG CDC gear table"))
+	buf := make([]byte, 8)
+	for i := range gear {
+		_, _ = shake.Read(buf)
+		gear[i] = binary.LittleEndian.Uint64(buf)
+	}
+	return gear
+}()
+
+// cdcBoundary returns the length of the chunk at the start of data. data
+// holds at most cdcMaxChunk bytes; all of it is taken if no boundary is
+// found.
+func cdcBoundary(data []byte) int {
+	if len(data) <= cdcMinChunk {
+		return len(data)
+	}
+	// Start hashing one window early so the hash is settled at the minimum
+	var h uint64
+	for i := cdcMinChunk - cdcWindow; i < len(data); i++ {
+		h = h<<1 + cdcGear[data[i]]
+		if i+1 >= cdcMinChunk && h&cdcMask == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// cdcChunker splits a stream into content-defined chunks.
+type cdcChunker struct {
+	r   io.Reader
+	buf []byte
+	n   int // Bytes buffered
+	cut int // Length of the chunk last returned
+	eof bool
+}
+
+func newCDCChunker(r io.Reader) *cdcChunker {
+	return &cdcChunker{r: r, buf: make([]byte, cdcMaxChunk)}
+}
+
+// next returns the next chunk, valid until the following call, or io.EOF
+// after the last one.
+func (c *cdcChunker) next() ([]byte, error) {
+	c.n = copy(c.buf, c.buf[c.cut:c.n])
+	c.cut = 0
+	for c.n < len(c.buf) && !c.eof {
+		m, err := c.r.Read(c.buf[c.n:])
+		c.n += m
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if c.n == 0 {
+		return nil, io.EOF
+	}
+	c.cut = cdcBoundary(c.buf[:c.n])
+	return c.buf[:c.cut], nil
+}
+
+// cdcCipher seals and opens chunk records.
+type cdcCipher struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+// newCDCCipher derives the dedup key. Like the name manifest key it has its
+// own Argon2 derivation (normal mode parameters), here with the fixed
+// cdcSalt; the keyfile key is XORed in as for the volume key. HKDF then
+// splits it into the record key and the nonce key.
+func newCDCCipher(password string, pepper, keyfileKey []byte) (*cdcCipher, error) {
+	peppered := crypto.PepperPassword([]byte(password), pepper)
+	key := argon2.IDKey(peppered, cdcSalt,
+		crypto.Argon2NormalPasses,
+		crypto.Argon2NormalMemory,
+		crypto.Argon2NormalThreads,
+		crypto.Argon2KeySize,
+	)
+	crypto.SecureZero(peppered)
+	if keyfileKey != nil {
+		mixed := keyfile.XORWithKey(key, keyfileKey)
+		crypto.SecureZero(key)
+		key = mixed
+	}
+
+	subkeys := make([]byte, 64)
+	_, err := io.ReadFull(crypto.NewHKDFStream(key, cdcSalt), subkeys)
+	crypto.SecureZero(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(subkeys[:32])
+	crypto.SecureZero(subkeys[:32])
+	if err != nil {
+		return nil, err
+	}
+	return &cdcCipher{aead: aead, nonceKey: subkeys[32:]}, nil
+}
+
+// nonce is the keyed BLAKE2b-192 of chunk. Equal chunks share a nonce, and
+// so a record; different chunks collide with negligible probability.
+func (c *cdcCipher) nonce(chunk []byte) []byte {
+	h, _ := blake2b.New(chacha20poly1305.NonceSizeX, c.nonceKey)
+	h.Write(chunk)
+	return h.Sum(nil)
+}
+
+// seal appends the record for chunk to dst.
+func (c *cdcCipher) seal(dst, chunk []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(chunk)))
+	nonce := c.nonce(chunk)
+	dst = append(dst, nonce...)
+	return c.aead.Seal(dst, nonce, chunk, nil)
+}
+
+// open authenticates and decrypts the sealed part of a record, then checks
+// the nonce really is that of the plaintext.
+func (c *cdcCipher) open(dst, nonce, sealed []byte) ([]byte, error) {
+	plain, err := c.aead.Open(dst, nonce, sealed, nil)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(c.nonce(plain), nonce) != 1 {
+		return nil, errors.New("nonce does not match chunk")
+	}
+	return plain, nil
+}
+
+func (c *cdcCipher) close() {
+	crypto.SecureZero(c.nonceKey)
+}
+
+// cdcKeyfileKey returns the keyfile key the dedup key is mixed with, if any.
+func cdcKeyfileKey(ctx *OperationContext) []byte {
+	if ctx.UseKeyfiles {
+		return ctx.KeyfileKey
+	}
+	return nil
+}
+
+// cdcMaxPayloadSize bounds the payload for size bytes of plaintext: every
+// chunk but the last is at least cdcMinChunk long.
+func cdcMaxPayloadSize(size int64) int64 {
+	return size + (size/cdcMinChunk+1)*cdcRecordOverhead
+}
+
+// encryptCDCPayload writes the payload as chunk records in place of the
+// streamed cipher, feeding them to the volume MAC.
+func encryptCDCPayload(ctx *OperationContext, req *EncryptRequest, r io.Reader, fout File) error {
+	ctx.SetStatus("Deriving dedup key...")
+	cdc, err := newCDCCipher(req.Password, req.Pepper, cdcKeyfileKey(ctx))
+	if err != nil {
+		return err
+	}
+	defer cdc.close()
+
+	ctx.Reporter.SetCanCancel(true)
+	startTime := time.Now()
+	var done int64
+	chunker := newCDCChunker(r)
+	w := bufio.NewWriterSize(fout, util.MiB)
+	record := make([]byte, 0, cdcMaxChunk+cdcRecordOverhead)
+	for {
+		if ctx.IsCancelled() {
+			return ctx.CancellationError()
+		}
+		chunk, err := chunker.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read input: %w", err)
+		}
+
+		record = cdc.seal(record[:0], chunk)
+		ctx.CipherSuite.MAC().Write(record)
+		if _, err := w.Write(record); err != nil {
+			return fmt.Errorf("write ciphertext: %w", err)
+		}
+
+		done += int64(len(chunk))
+		progress, speed, eta := util.Statify(done, ctx.Total, startTime)
+		ctx.UpdateProgress(progress, fmt.Sprintf("%.2f%%", progress*100))
+		ctx.SetRate("Encrypting", speed, eta)
+	}
+	if err := checkInputRead(ctx, req, done); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write ciphertext: %w", err)
+	}
+	if err := fout.Sync(); err != nil {
+		return fmt.Errorf("sync output: %w", err)
+	}
+	return nil
+}
+
+// decryptCDCPayload reads chunk records up to the end of the volume and
+// writes their plaintext to out, feeding the records to the volume MAC so
+// decryptFinalize checks them as a whole. A record that does not
+// authenticate fails with ErrCorruptData even with ForceDecrypt, as there
+// is no plaintext to keep for it.
+func decryptCDCPayload(ctx *OperationContext, req *DecryptRequest, r io.Reader, out io.Writer) error {
+	ctx.SetStatus("Deriving dedup key...")
+	cdc, err := newCDCCipher(req.Password, req.Pepper, cdcKeyfileKey(ctx))
+	if err != nil {
+		return err
+	}
+	defer cdc.close()
+
+	ctx.Reporter.SetCanCancel(true)
+	startTime := time.Now()
+	var done, written int64
+	br := bufio.NewReaderSize(r, util.MiB)
+	record := make([]byte, cdcMaxChunk+cdcRecordOverhead)
+	plain := make([]byte, 0, cdcMaxChunk)
+	for {
+		if ctx.IsCancelled() {
+			return ctx.CancellationError()
+		}
+
+		head := record[:cdcRecordHeader]
+		if _, err := io.ReadFull(br, head); err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: truncated chunk record at payload offset %d", perrors.ErrCorruptData, done)
+		} else if err != nil {
+			return fmt.Errorf("read input: %w", err)
+		}
+		size := binary.BigEndian.Uint32(head)
+		if size == 0 || size > cdcMaxChunk {
+			return fmt.Errorf("%w: invalid chunk length %d at payload offset %d", perrors.ErrCorruptData, size, done)
+		}
+		rec := record[:cdcRecordHeader+int(size)+chacha20poly1305.Overhead]
+		if _, err := io.ReadFull(br, rec[cdcRecordHeader:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: truncated chunk record at payload offset %d", perrors.ErrCorruptData, done)
+		} else if err != nil {
+			return fmt.Errorf("read input: %w", err)
+		}
+		ctx.CipherSuite.MAC().Write(rec)
+
+		chunk, err := cdc.open(plain[:0], head[4:], rec[cdcRecordHeader:])
+		if err != nil {
+			return fmt.Errorf("%w: chunk at payload offset %d: %w", perrors.ErrCorruptData, done, err)
+		}
+		if _, err := out.Write(chunk); err != nil {
+			return fmt.Errorf("write plaintext: %w", err)
+		}
+		written += int64(len(chunk))
+		done += int64(len(rec))
+
+		progress, speed, eta := util.Statify(done, ctx.Total, startTime)
+		ctx.UpdateProgress(progress, fmt.Sprintf("%.2f%%", progress*100))
+		ctx.SetRate("Decrypting", speed, eta)
+	}
+
+	if req.Throughput != nil {
+		if elapsed := time.Since(startTime).Seconds(); elapsed > 0 {
+			*req.Throughput = float64(written) / elapsed / float64(util.MiB)
+		}
+	}
+	return nil
+}
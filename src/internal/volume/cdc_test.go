@@ -0,0 +1,280 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	mrand "math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+)
+
+// cdcChunks splits data with the chunker, copying each chunk.
+func cdcChunks(t *testing.T, r io.Reader) [][]byte {
+	t.Helper()
+	var chunks [][]byte
+	c := newCDCChunker(r)
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("next failed: %v", err)
+		}
+		chunks = append(chunks, bytes.Clone(chunk))
+	}
+}
+
+// countShared returns how many of a's items also appear in b.
+func countShared(a, b [][]byte) int {
+	seen := make(map[string]bool)
+	for _, x := range b {
+		seen[string(x)] = true
+	}
+	n := 0
+	for _, x := range a {
+		if seen[string(x)] {
+			n++
+		}
+	}
+	return n
+}
+
+// insertAt returns data with extra inserted at off.
+func insertAt(data []byte, off int, extra []byte) []byte {
+	return append(append(append([]byte{}, data[:off]...), extra...), data[off:]...)
+}
+
+func TestCDCChunker(t *testing.T) {
+	data := make([]byte, 16<<20)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	chunks := cdcChunks(t, bytes.NewReader(data))
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatal("Chunks do not reassemble the input")
+	}
+	for i, chunk := range chunks {
+		if len(chunk) > cdcMaxChunk || (len(chunk) < cdcMinChunk && i != len(chunks)-1) {
+			t.Errorf("Chunk %d has length %d outside [%d, %d]", i, len(chunk), cdcMinChunk, cdcMaxChunk)
+		}
+	}
+
+	// Boundaries depend on content, not on how the reader delivers it
+	if short := cdcChunks(t, iotest.HalfReader(bytes.NewReader(data))); countShared(short, chunks) != len(chunks) {
+		t.Error("Short reads changed the chunk boundaries")
+	}
+
+	// An insertion only disturbs the chunks around it
+	edited := insertAt(data, 8<<20, []byte("inserted"))
+	if shared := countShared(cdcChunks(t, bytes.NewReader(edited)), chunks); shared < len(chunks)-2 {
+		t.Errorf("Only %d of %d chunks survived an insertion", shared, len(chunks))
+	}
+
+	if got := cdcChunks(t, bytes.NewReader(nil)); len(got) != 0 {
+		t.Errorf("Empty input gave %d chunks", len(got))
+	}
+}
+
+// cdcRecords splits the payload of a CDC volume without comments into its
+// records.
+func cdcRecords(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+	payload := data[header.HeaderSize(0):]
+	var records [][]byte
+	for len(payload) > 0 {
+		if len(payload) < cdcRecordHeader {
+			t.Fatalf("Truncated record header")
+		}
+		size := cdcRecordOverhead + int(binary.BigEndian.Uint32(payload))
+		if size > len(payload) {
+			t.Fatalf("Record of %d bytes overruns the payload", size)
+		}
+		records = append(records, payload[:size])
+		payload = payload[size:]
+	}
+	return records
+}
+
+func TestCDCDedup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping CDC dedup test in short mode")
+	}
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	// Fixed data that the chunker splits into five chunks of 242-669 KiB
+	original := make([]byte, 2<<20)
+	_, _ = mrand.NewChaCha8([32]byte{1}).Read(original)
+	// The next version of the file: an edit near the start, an insertion
+	// in the middle and a few bytes appended
+	edited := bytes.Clone(original)
+	edited[100] ^= 0xFF
+	edited = insertAt(edited, 1<<20, bytes.Repeat([]byte("new data "), 500))
+	edited = append(edited, "trailer"...)
+
+	encrypt := func(name string, data []byte, password string) string {
+		t.Helper()
+		input := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(input, data, 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		output := input + ".pcv"
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  input,
+			OutputFile: output,
+			Password:   password,
+			CDCDedup:   true,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt %s failed: %v", name, err)
+		}
+		return output
+	}
+	decrypt := func(volume string) ([]byte, error) {
+		t.Helper()
+		output := volume + ".out"
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volume,
+			OutputFile: output,
+			Password:   "dedup_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = os.Remove(output) }()
+		return os.ReadFile(output)
+	}
+
+	v1 := encrypt("v1.bin", original, "dedup_password")
+	v2 := encrypt("v2.bin", edited, "dedup_password")
+
+	if !readVolumeHeader(t, v1, rsCodecs).Flags.CDCDedup {
+		t.Error("Header should record CDCDedup")
+	}
+
+	// Every chunk the versions share is stored as the same record
+	records1, records2 := cdcRecords(t, v1), cdcRecords(t, v2)
+	chunks1 := cdcChunks(t, bytes.NewReader(original))
+	wantShared := countShared(chunks1, cdcChunks(t, bytes.NewReader(edited)))
+	if len(records1) != len(chunks1) {
+		t.Errorf("Volume has %d records for %d chunks", len(records1), len(chunks1))
+	}
+	if wantShared < len(chunks1)/2 {
+		t.Fatalf("Only %d of %d chunks unchanged; test data does not exercise dedup", wantShared, len(chunks1))
+	}
+	if got := countShared(records1, records2); got != wantShared {
+		t.Errorf("Versions share %d records; want %d (one per unchanged chunk)", got, wantShared)
+	}
+
+	// Other credentials share nothing
+	other := encrypt("other.bin", original, "another_password")
+	if got := countShared(cdcRecords(t, other), records1); got != 0 {
+		t.Errorf("Volumes with different passwords share %d records", got)
+	}
+
+	for _, tc := range []struct {
+		volume string
+		want   []byte
+	}{{v1, original}, {v2, edited}} {
+		got, err := decrypt(tc.volume)
+		if err != nil {
+			t.Fatalf("Decrypt %s failed: %v", filepath.Base(tc.volume), err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("Decrypt %s: content mismatch", filepath.Base(tc.volume))
+		}
+	}
+
+	// Records are authenticated one by one, and their order by the volume MAC
+	tamper := func(name string, edit func(payload []byte, records [][]byte) []byte) {
+		t.Helper()
+		data, err := os.ReadFile(v1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset := header.HeaderSize(0)
+		payload := edit(bytes.Clone(data[offset:]), cdcRecords(t, v1))
+		path := filepath.Join(tmpDir, name+".pcv")
+		if err := os.WriteFile(path, append(data[:offset:offset], payload...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decrypt(path); !errors.Is(err, perrors.ErrCorruptData) {
+			t.Errorf("%s: expected ErrCorruptData, got: %v", name, err)
+		}
+		if _, err := os.Stat(path + ".out.incomplete"); !os.IsNotExist(err) {
+			t.Errorf("%s: incomplete output left behind", name)
+		}
+	}
+	tamper("flipped", func(payload []byte, _ [][]byte) []byte {
+		payload[len(payload)/2] ^= 0x01
+		return payload
+	})
+	tamper("swapped", func(_ []byte, records [][]byte) []byte {
+		records[0], records[1] = records[1], records[0]
+		return bytes.Join(records, nil)
+	})
+	tamper("dropped", func(_ []byte, records [][]byte) []byte {
+		return bytes.Join(records[:len(records)-1], nil)
+	})
+	tamper("truncated", func(payload []byte, _ [][]byte) []byte {
+		return payload[:len(payload)-10]
+	})
+}
+
+func TestCDCDedupValidation(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, setup := range map[string]func(req *EncryptRequest){
+		"reed-solomon": func(req *EncryptRequest) { req.ReedSolomon = true },
+		"block hashes": func(req *EncryptRequest) { req.BlockHashes = true },
+		"paranoid":     func(req *EncryptRequest) { req.Paranoid = true },
+		"deniability":  func(req *EncryptRequest) { req.Deniability = true },
+		"preallocate":  func(req *EncryptRequest) { req.Preallocate = true },
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := &EncryptRequest{
+				InputFile:  input,
+				OutputFile: input + ".pcv",
+				Password:   "password",
+				CDCDedup:   true,
+				Reporter:   &GoldenTestReporter{},
+			}
+			setup(req)
+			var verr *perrors.ValidationError
+			if err := req.Validate(); !errors.As(err, &verr) {
+				t.Errorf("Validate: expected ValidationError, got: %v", err)
+			}
+			// Encrypt checks too, before writing anything
+			if err := Encrypt(context.Background(), req); !errors.As(err, &verr) {
+				t.Errorf("Encrypt: expected ValidationError, got: %v", err)
+			}
+			if _, err := os.Stat(req.OutputFile + ".incomplete"); !os.IsNotExist(err) {
+				t.Error("Encrypt should not have written anything")
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package volume
+
+import (
+	"archive/zip"
+	"fmt"
+
+	"Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// Built-in compression methods for EncryptRequest.CompressionMethod, always
+// available without registration.
+const (
+	MethodStore   = zip.Store
+	MethodDeflate = zip.Deflate
+)
+
+// Compressor returns a writer compressing one archive entry into w; closing
+// it must flush everything but leave w open.
+type Compressor = fileops.Compressor
+
+// Decompressor returns a reader decompressing one archive entry from r.
+type Decompressor = fileops.Decompressor
+
+// RegisterCompressor makes c available as EncryptRequest.CompressionMethod.
+// The method ID is stored in the zip header of every entry compressed with
+// it, so any ID unused by the zip format (e.g. above 0x8000) works as long as
+// decryption registers the matching Decompressor. Each method can be
+// registered once, and MethodStore and MethodDeflate cannot be replaced.
+// Registration is process-wide and safe for concurrent use.
+func RegisterCompressor(method uint16, c Compressor) error {
+	return fileops.RegisterCompressor(method, c)
+}
+
+// RegisterDecompressor makes d available to AutoUnzip and ExtractFile for
+// entries compressed with method. Entries whose method has no decompressor
+// fail with ErrUnknownCompression.
+func RegisterDecompressor(method uint16, d Decompressor) error {
+	return fileops.RegisterDecompressor(method, d)
+}
+
+// validateCompression checks CompressionMethod: it needs Compress and a
+// registered compressor.
+func validateCompression(req *EncryptRequest) error {
+	if req.CompressionMethod == nil {
+		return nil
+	}
+	if !req.Compress {
+		return errors.NewValidationError("CompressionMethod", "requires Compress")
+	}
+	if method := *req.CompressionMethod; !fileops.HasCompressor(method) {
+		return fmt.Errorf("%w %d: no compressor registered", errors.ErrUnknownCompression, method)
+	}
+	return nil
+}
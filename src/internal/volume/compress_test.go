@@ -0,0 +1,176 @@
+package volume
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// identityWriter is a Compressor output that stores data unchanged.
+type identityWriter struct{ io.Writer }
+
+func (identityWriter) Close() error { return nil }
+
+// TestCompressionMethod tests that a volume round-trips through a registered
+// custom compressor, and that decrypting entries of an unregistered method
+// fails clearly
+func TestCompressionMethod(t *testing.T) {
+	const identity, writeOnly = 0xA001, 0xA002
+	var compressed, decompressed atomic.Int32
+	if err := RegisterCompressor(identity, func(w io.Writer) (io.WriteCloser, error) {
+		compressed.Add(1)
+		return identityWriter{w}, nil
+	}); err != nil {
+		t.Fatalf("RegisterCompressor failed: %v", err)
+	}
+	if err := RegisterDecompressor(identity, func(r io.Reader) io.ReadCloser {
+		decompressed.Add(1)
+		return io.NopCloser(r)
+	}); err != nil {
+		t.Fatalf("RegisterDecompressor failed: %v", err)
+	}
+	if err := RegisterCompressor(writeOnly, func(w io.Writer) (io.WriteCloser, error) {
+		return identityWriter{w}, nil
+	}); err != nil {
+		t.Fatalf("RegisterCompressor failed: %v", err)
+	}
+
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	tmpDir := t.TempDir()
+	folder := filepath.Join(tmpDir, "notes")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	files := map[string]string{
+		"monday.txt":  strings.Repeat("standup ", 1000),
+		"tuesday.txt": strings.Repeat("review ", 2000),
+	}
+	var inputs []string
+	for name, content := range files {
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+
+	roundTrip := func(t *testing.T, method uint16) (string, error) {
+		t.Helper()
+		volumePath := filepath.Join(t.TempDir(), "notes.zip.pcv")
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFiles:        inputs,
+			OnlyFolders:       []string{folder},
+			OutputFile:        volumePath,
+			Password:          "compress_password",
+			Compress:          true,
+			CompressionMethod: &method,
+			Reporter:          &GoldenTestReporter{},
+			RSCodecs:          rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		outputPath := strings.TrimSuffix(volumePath, ".pcv")
+		return filepath.Join(filepath.Dir(outputPath), "notes"), Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: outputPath,
+			Password:   "compress_password",
+			AutoUnzip:  true,
+			SameLevel:  true,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+	}
+
+	t.Run("custom method round trip", func(t *testing.T) {
+		extracted, err := roundTrip(t, identity)
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		for name, want := range files {
+			got, err := os.ReadFile(filepath.Join(extracted, name))
+			if err != nil {
+				t.Fatalf("Failed to read extracted file: %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("%s differs after the round trip", name)
+			}
+		}
+		if compressed.Load() != int32(len(files)) || decompressed.Load() != int32(len(files)) {
+			t.Errorf("compressor used %d times, decompressor %d; want %d each",
+				compressed.Load(), decompressed.Load(), len(files))
+		}
+	})
+
+	t.Run("store method stores entries", func(t *testing.T) {
+		store := uint16(MethodStore)
+		volumePath := filepath.Join(t.TempDir(), "notes.zip.pcv")
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFiles:        inputs,
+			OnlyFolders:       []string{folder},
+			OutputFile:        volumePath,
+			Password:          "compress_password",
+			Compress:          true,
+			CompressionMethod: &store,
+			Reporter:          &GoldenTestReporter{},
+			RSCodecs:          rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		zipPath := strings.TrimSuffix(volumePath, ".pcv")
+		if err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: zipPath,
+			Password:   "compress_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}); err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		reader, err := zip.OpenReader(zipPath)
+		if err != nil {
+			t.Fatalf("Failed to open zip: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		for _, f := range reader.File {
+			if !f.FileInfo().IsDir() && f.Method != zip.Store {
+				t.Errorf("%s has method %d; want stored", f.Name, f.Method)
+			}
+		}
+	})
+
+	t.Run("unknown method on decrypt", func(t *testing.T) {
+		_, err := roundTrip(t, writeOnly)
+		if !errors.Is(err, perrors.ErrUnknownCompression) || !errors.Is(err, perrors.ErrUnzipFailed) {
+			t.Fatalf("expected ErrUnknownCompression from auto-unzip, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "40962") {
+			t.Errorf("error should name the method: %v", err)
+		}
+	})
+
+	t.Run("unknown or uncompressed method rejected", func(t *testing.T) {
+		unknown, custom := uint16(0xA0FF), uint16(identity)
+		for _, req := range []*EncryptRequest{
+			{InputFiles: inputs, OutputFile: "x.zip.pcv", Password: "p", Compress: true, CompressionMethod: &unknown},
+			{InputFiles: inputs, OutputFile: "x.zip.pcv", Password: "p", CompressionMethod: &custom},
+		} {
+			if err := req.Validate(); err == nil {
+				t.Errorf("Validate accepted CompressionMethod %d with Compress %v", *req.CompressionMethod, req.Compress)
+			}
+		}
+	})
+}
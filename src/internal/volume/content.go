@@ -0,0 +1,51 @@
+package volume
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// contentCheck counts and hashes the plaintext as it is written, for
+// DecryptRequest.ExpectedSize and ExpectedSHA256.
+type contentCheck struct {
+	size int64
+	hash hash.Hash
+}
+
+func newContentCheck() *contentCheck {
+	return &contentCheck{hash: sha256.New()}
+}
+
+func (c *contentCheck) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	return c.hash.Write(p)
+}
+
+// verify compares the plaintext written so far with the expectations of
+// req and fails with ErrContentMismatch on a difference.
+func (c *contentCheck) verify(req *DecryptRequest) error {
+	if req.ExpectedSize > 0 && c.size != req.ExpectedSize {
+		return fmt.Errorf("%w: %d bytes, expected %d", perrors.ErrContentMismatch, c.size, req.ExpectedSize)
+	}
+	if req.ExpectedSHA256 != nil {
+		if sum := c.hash.Sum(nil); !bytes.Equal(sum, req.ExpectedSHA256) {
+			return fmt.Errorf("%w: SHA-256 %x, expected %x", perrors.ErrContentMismatch, sum, req.ExpectedSHA256)
+		}
+	}
+	return nil
+}
+
+// checkExpectedContent refuses malformed expectations before decrypting.
+func checkExpectedContent(req *DecryptRequest) error {
+	if req.ExpectedSize < 0 {
+		return perrors.NewValidationError("ExpectedSize", "must not be negative")
+	}
+	if req.ExpectedSHA256 != nil && len(req.ExpectedSHA256) != sha256.Size {
+		return perrors.NewValidationError("ExpectedSHA256", fmt.Sprintf("must be %d bytes", sha256.Size))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// TestDecryptExpectedContent tests that the plaintext is checked against a
+// caller's expected size and SHA-256 after the MAC passes
+func TestDecryptExpectedContent(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := bytes.Repeat([]byte("manifest entry\n"), 1000)
+	inputPath := filepath.Join(tmpDir, "restore.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "restore.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "expected_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	sum := sha256.Sum256(plaintext)
+	otherSum := sha256.Sum256([]byte("another file"))
+	tests := []struct {
+		name    string
+		size    int64
+		hash    []byte
+		wantErr error
+	}{
+		{"match", int64(len(plaintext)), sum[:], nil},
+		{"size_only", int64(len(plaintext)), nil, nil},
+		{"wrong_size", int64(len(plaintext)) + 1, sum[:], perrors.ErrContentMismatch},
+		{"wrong_hash", int64(len(plaintext)), otherSum[:], perrors.ErrContentMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "restore.txt")
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:      volumePath,
+				OutputFile:     outputPath,
+				Password:       "expected_password",
+				ExpectedSize:   tt.size,
+				ExpectedSHA256: tt.hash,
+				Reporter:       &GoldenTestReporter{},
+				RSCodecs:       rsCodecs,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v; want %v", err, tt.wantErr)
+			}
+			got, readErr := os.ReadFile(outputPath)
+			if tt.wantErr == nil {
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("decrypted content does not match (read error %v)", readErr)
+				}
+				return
+			}
+			if !os.IsNotExist(readErr) {
+				t.Error("output kept despite the mismatch")
+			}
+			if _, err := os.Stat(outputPath + ".incomplete"); !os.IsNotExist(err) {
+				t.Error(".incomplete output left behind")
+			}
+		})
+	}
+	var verr *perrors.ValidationError
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:      volumePath,
+		OutputFile:     filepath.Join(tmpDir, "short.txt"),
+		Password:       "expected_password",
+		ExpectedSHA256: sum[:16],
+		Reporter:       &GoldenTestReporter{},
+		RSCodecs:       rsCodecs,
+	})
+	if !errors.As(err, &verr) || verr.Field != "ExpectedSHA256" {
+		t.Errorf("short ExpectedSHA256: got %v; want a ValidationError", err)
+	}
+}
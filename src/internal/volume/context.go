@@ -12,9 +12,11 @@
 //  6. Compute auth: Calculate header HMAC (v2) or key hash (v1)
 //  7. Encrypt payload: Serpent-CTR -> XChaCha20 -> MAC
 //  8. Finalize: Write auth tag, add deniability wrapper, split chunks
+//  9. Armor (optional): Write the volume as base64 text with PEM-style markers
+//  10. Sync (optional): fsync the outputs and their directories
 //
 // Decryption pipeline:
-//  1. Preprocess: Recombine chunks, remove deniability wrapper
+//  1. Preprocess: Recombine chunks, decode armor, remove deniability wrapper
 //  2. Read header: RS-decode header fields
 //  3. Derive keys: Argon2id password derivation
 //  4. Process keyfiles: Validate against stored hash
@@ -27,13 +29,16 @@ package volume
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 
 	"Picocrypt-NG/internal/crypto"
 	"Picocrypt-NG/internal/encoding"
 	perrors "Picocrypt-NG/internal/errors"
 	"Picocrypt-NG/internal/fileops"
 	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/keyfile"
 )
 
 // ProgressReporter provides callbacks for UI updates during long-running operations.
@@ -44,6 +49,15 @@ type ProgressReporter interface {
 	SetCanCancel(can bool)                     // Enable/disable cancel button
 	Update()                                   // Trigger UI refresh
 	IsCancelled() bool                         // Check if user requested cancellation
+	Warn(msg string)                           // Report a non-fatal advisory; the operation carries on
+}
+
+// RateReporter is an optional extension of ProgressReporter for reporters
+// that want the payload rate as values rather than inside the status text.
+// SetRate is called after the matching SetStatus and before Update; any
+// other SetStatus means there is no current rate.
+type RateReporter interface {
+	SetRate(speedMiBps float64, eta string)
 }
 
 // EncryptRequest contains all parameters needed to encrypt files into a .pcv volume.
@@ -55,11 +69,42 @@ type EncryptRequest struct {
 	OnlyFolders []string // Folders that were dropped directly (for correct zip path calculation)
 	OnlyFiles   []string // Files that were dropped directly (not from folders)
 	OutputFile  string   // Output path for the .pcv volume
+	OutputName  string   // Output path without extension; overrides OutputFile (see OutputPath)
+
+	// CreateOutputDirs creates the output's missing parent directories
+	// (mode 0700) before anything is written; split chunks share that
+	// directory. Without it a missing directory fails with
+	// ErrOutputDirMissing. Directories are left in place if encryption
+	// then fails.
+	CreateOutputDirs bool
+
+	// AtomicOutput writes everything under a hidden name beside the output
+	// and renames each finished, fsynced file into place at the end, so the
+	// final names never exist holding partial or intermediate content (a
+	// volume before deniability, splitting or armoring, or one that failed
+	// VerifyAfterEncrypt). On failure the staged files are removed.
+	AtomicOutput bool
 
 	// Credentials - at least one required
-	Password       string   // User password (processed through Argon2id)
-	Keyfiles       []string // Paths to keyfile(s) for additional security
-	KeyfileOrdered bool     // If true, keyfile order matters (sequential hash vs XOR)
+	Password       string                // User password (processed through Argon2id)
+	Keyfiles       []string              // Paths to keyfile(s) for additional security
+	KeyfileOrdered bool                  // If true, keyfile order matters (sequential hash vs XOR)
+	KeyfileHash    keyfile.HashAlgorithm // Keyfile hash, recorded in the header (default SHA3-256)
+
+	// PasswordFunc, if set, supplies Password when it is empty, e.g. from
+	// the system keyring. It is called once before anything is written and
+	// its error fails the request.
+	PasswordFunc func() (string, error)
+
+	// MaxKeyfiles caps len(Keyfiles); more fail with ErrTooManyKeyfiles
+	// before any is read. 0 means DefaultMaxKeyfiles and a negative value
+	// lifts the limit.
+	MaxKeyfiles int
+
+	// MinPasswordScore rejects a password whose zxcvbn score (0-4) is lower
+	// with ErrWeakPassword. 0 accepts any password; keyfile-only requests
+	// without a password are not checked.
+	MinPasswordScore int
 
 	// Security options
 	Comments    string // Plaintext comments stored in header (NOT encrypted!)
@@ -68,39 +113,378 @@ type EncryptRequest struct {
 	Deniability bool   // Wrap volume in additional encryption layer for plausible deniability
 	Compress    bool   // Use Deflate compression when creating zip archive
 
+	// CompressionMethod, with Compress, compresses each zip entry with the
+	// method registered under this ID (see RegisterCompressor) instead of
+	// Deflate. The ID is stored in the entry's zip header; AutoUnzip needs
+	// the matching Decompressor. nil means MethodDeflate; MethodStore
+	// stores every entry uncompressed.
+	CompressionMethod *uint16
+
+	// ExplicitPadding stores the final chunk's pad length in the
+	// authenticated header flags, so decryption strips exactly that many
+	// bytes instead of inferring the padding from the Padded heuristic and
+	// the last byte. Only applies with ReedSolomon, the only padded mode.
+	// Older versions cannot decrypt such volumes.
+	ExplicitPadding bool
+
+	// AbortOnInputChange fails with ErrInputChangedDuringRead, removing the
+	// partial output, if the input does not read back at the size it had
+	// when encryption started, e.g. a log still being written. Without it
+	// whatever is read up to EOF is encrypted. ExplicitPadding always checks.
+	AbortOnInputChange bool
+
+	// SkipIncompressible samples each file before compressing it and stores
+	// files that already look encrypted or compressed, reporting each through
+	// Reporter.Warn. Only applies with Compress.
+	SkipIncompressible bool
+
+	// ZipWorkers compresses small files (up to 8 MiB) for the archive on
+	// this many goroutines ahead of the writer. Entries keep their order and
+	// contents; 0 or 1 compresses serially. Only applies with Compress.
+	ZipWorkers int
+
+	// RawSingleFile encrypts the file directly, without a zip wrapper, when
+	// the input is a single folder holding exactly one file. Name the output
+	// with RawSingleFileOutput so it decrypts to the file's original name.
+	// Ignored when Compress is set, since that explicitly asks for an archive.
+	RawSingleFile bool
+
+	// PreserveDirs adds an entry for every directory in the archive, so
+	// their permissions are restored along with the files' by an unzip with
+	// DecryptRequest.RestoreModes.
+	PreserveDirs bool
+
+	// EncryptNames stores archive entries under opaque names, with the real
+	// names sealed in a manifest entry, so the decrypted .zip reveals nothing
+	// but sizes and dates. Decrypt restores the names on auto-unzip. The
+	// manifest key is expanded from the volume key, keyfiles included, so
+	// the names need the same credentials (or recovery key) as the payload.
+	// Only applies when the input is zipped.
+	EncryptNames bool
+
+	// StoreKeyfileNames records the keyfile basenames in the header so the
+	// decrypt UI can tell the user which keyfiles are needed. The names are
+	// authenticated but NOT encrypted; incompatible with Deniability.
+	StoreKeyfileNames bool
+
+	// StoreOriginalName records the name of the input file (or, for zipped
+	// input, of the .zip) in the header, so DecryptOutputName restores it
+	// even if the volume was renamed. Authenticated but NOT encrypted, like
+	// the keyfile names, unless the volume is deniable.
+	StoreOriginalName bool
+
+	// DeleteInputs removes the original files and dropped folders once the
+	// volume is complete and, with VerifyAfterEncrypt, verified. Zipped
+	// inputs (folders or several files) require VerifyAfterEncrypt. Folders
+	// are kept if they contain files that are not in InputFiles. A failure to
+	// delete is reported as ErrDeleteFailed; the volume is still complete.
+	DeleteInputs bool
+
+	// RequireDurable fsyncs the finished output files and the directories
+	// holding them once everything else is done, before DeleteInputs, and
+	// fails with ErrNotDurable if any sync fails. The originals are then
+	// kept; the output is left in place.
+	RequireDurable bool
+
+	// Durable, if non-nil, is set to whether that fsync barrier succeeded.
+	// Setting it runs the barrier without requiring it to pass.
+	Durable *bool
+
+	// VerifyAfterEncrypt re-opens the finished volume and verifies it with the
+	// same credentials before reporting success. A zipped input is decrypted
+	// and must hold every input file at its size on disk. On failure the
+	// volume is kept and Encrypt returns an error wrapping
+	// ErrPostWriteVerifyFailed.
+	VerifyAfterEncrypt bool
+
+	// Preallocate reserves the expected volume size on disk before the
+	// payload is written, reducing fragmentation for large volumes. Blocks
+	// are only reserved on Linux and macOS filesystems that support it;
+	// elsewhere the output just gets its final size as a sparse file. The
+	// payload is still written in place and any unused space is trimmed.
+	Preallocate bool
+
+	// AAD is associated data bound into the header MAC but not stored in the
+	// volume. The same bytes must be passed to DecryptRequest.AAD or the
+	// volume will not open. Empty AAD leaves the volume format unchanged.
+	AAD []byte
+
+	// Pepper is a secret mixed into the password before Argon2 and never
+	// stored; the header only records that one is required. Keep it outside
+	// the volume (e.g. in an HSM). Does not apply to the deniability wrapper.
+	Pepper []byte
+
+	// Argon2Threads overrides the Argon2 thread count. 0 uses the mode
+	// default (4, or 8 when Paranoid); Argon2ThreadsAuto clamps that default
+	// to the CPUs actually available, including cgroup quotas on Linux. Any
+	// count other than the default is recorded in the header for
+	// decryption, and volumes that record one cannot be opened by upstream
+	// Picocrypt or older versions.
+	Argon2Threads int
+
+	// MaxDerivationTime, if positive, bounds the expected key derivation
+	// time in Paranoid mode. The cost is estimated with a short Argon2
+	// calibration before any other work, and an estimate above the limit
+	// fails with ErrDerivationTooSlow, suggesting normal mode. Ignored
+	// otherwise.
+	MaxDerivationTime time.Duration
+
+	// TargetDerivationTime, if positive, raises the Argon2 pass count until
+	// key derivation is estimated to take this long on this machine, for
+	// secrets that should never be quick to unlock. The memory stays that
+	// of the mode. The pass count is recorded in the header, so decryption
+	// repeats the same work; it is capped at header.MaxPasses with a
+	// warning, and must not exceed MaxDerivationTime when both are set.
+	// Volumes with a non-default pass count cannot be opened by older
+	// versions.
+	TargetDerivationTime time.Duration
+
+	// LowPriority lowers the process scheduling priority before starting
+	// (nice on Unix, below-normal on Windows). It stays lowered afterwards.
+	LowPriority bool
+
+	// CipherWorkers splits the Serpent-CTR and XChaCha20 work of each 1 MiB
+	// block across this many goroutines. The volume is byte-identical to a
+	// serial encryption; 0 or 1 runs serially. Only applies with Paranoid.
+	CipherWorkers int
+
+	// BlockHashes stores an authenticated SHA3-256 hash of every 1 MiB
+	// payload block between the header and the payload, so VerifyBlocks can
+	// check a partially downloaded volume and locate damaged blocks. Volumes
+	// with block hashes cannot be opened by older versions.
+	BlockHashes bool
+
+	// Trailer ends the volume with a fixed-size, authenticated format
+	// descriptor (see header.Trailer) recording where each field is, how it
+	// is encoded and the Argon2, cipher and MAC choices, so a reader can
+	// locate and check every part of the volume without compiled-in
+	// offsets. Cannot be combined with Deniability; older versions cannot
+	// open the volume.
+	Trailer bool
+
+	// CDCDedup cuts the payload at content-defined boundaries (about 1 MiB
+	// apart) and encrypts each chunk deterministically under a dedup key
+	// derived from the credentials alone, so regions that did not change
+	// encrypt to identical bytes in every volume made with the same
+	// credentials and deduplicating backup tools store them once. The cost
+	// is that equal chunks are visible as equal: anyone holding two such
+	// volumes learns which regions they share, and chunk sizes are stored in
+	// the clear. See cdc.go. Costs one extra key derivation on both sides;
+	// cannot be combined with ReedSolomon, BlockHashes, Paranoid,
+	// Deniability or Preallocate, and older versions cannot open the volume.
+	CDCDedup bool
+
+	// PreviewData, if non-empty, is stored between the header and the
+	// payload, encrypted under a key derived from the volume key, so
+	// ReadPreview can return it to the holder of the credentials without
+	// decrypting the payload - for example a thumbnail for a gallery. At
+	// most header.MaxPreviewSize bytes; its length is visible. Volumes with
+	// a preview cannot be opened by older versions.
+	PreviewData []byte
+
+	// RecoveryRecipient, if set, is a 32-byte X25519 public key (see
+	// GenerateRecoveryKey) to which the volume keys are wrapped in a
+	// section after the block table, so the holder of the private key can
+	// decrypt with DecryptRequest.RecoveryKey without the password or
+	// keyfiles. Cannot be combined with Deniability or CDCDedup, whose key
+	// comes from the password; older versions cannot open the volume.
+	RecoveryRecipient []byte
+
 	// Output splitting - useful for storage on FAT32 or cloud services with file size limits
 	Split     bool              // Enable splitting output into chunks
 	ChunkSize int               // Size of each chunk
 	ChunkUnit fileops.SplitUnit // Unit for ChunkSize: KiB, MiB, GiB, TiB, or Total (divide into N parts)
 
+	// StoreSplitLayout records the chunk size, chunk count and volume size
+	// in an authenticated section of the volume, so decrypting with
+	// Recombine rejects a stray or missing chunk with ErrChunkCount and a
+	// wrong-sized one with ErrChunkSize. Requires Split; cannot be combined
+	// with Deniability, and older versions cannot open the volume.
+	StoreSplitLayout bool
+
+	// RecordSplit, if positive, writes one independent volume per
+	// RecordSplit records of the input instead of a single volume, so each
+	// volume decrypts to whole records and concatenating the decrypted
+	// volumes in order reproduces the input. Volumes are named like
+	// OutputFile with .pcv replaced by .0.pcv, .1.pcv, and so on. Records end
+	// with RecordDelimiter, or '\n' when it is zero; a final record without
+	// one is kept. It needs a single, unzipped input file and cannot be
+	// combined with Split.
+	RecordSplit     int
+	RecordDelimiter byte
+
+	// ASCII armor for pasting into text channels. Armor also writes an
+	// armored copy at OutputFile + ".asc"; ArmorOnly replaces the binary
+	// volume at OutputFile with its armored text. Decrypt de-armors either
+	// transparently. Neither can be combined with Split.
+	Armor     bool
+	ArmorOnly bool
+
+	// Index writes an index beside the volume, at IndexPath, listing the
+	// name and size of every encrypted file. It is itself a volume, sealed
+	// with EncryptBytes under the same credentials, so a search tool holding
+	// them can enumerate the contents with ReadIndex without decrypting the
+	// payload; the index reveals only its own size. Costs one extra key
+	// derivation; cannot be combined with Deniability or RecordSplit.
+	Index bool
+
+	// FS, if set, replaces the OS for reading the input and writing the
+	// volume; see FileSystem for what still goes to the OS. Mainly for
+	// tests and virtual filesystems.
+	FS FileSystem
+
 	// Progress reporting
 	Reporter ProgressReporter // UI callback interface (can be nil for headless operation)
 
 	// Internal - initialized by caller
 	RSCodecs *encoding.RSCodecs // Pre-initialized Reed-Solomon codecs
+
+	// testValues, if set, supplies the salt, HKDF salt, Serpent IV and
+	// nonce in place of crypto/rand, making a basic volume a pure function
+	// of its password and plaintext. It is TEST-ONLY: it exists to build
+	// golden and interop vectors, is unexported so nothing outside this
+	// package's tests can set it, and a real volume must never reuse these
+	// values.
+	testValues func() (salt, hkdfSalt, serpentIV, nonce []byte)
+
+	// keptComments, if set, is stored as the whole comments field in place
+	// of Comments and any names. Rekey and Migrate set it to carry a
+	// volume's comments, stored names included, over verbatim.
+	keptComments string
+
+	// keepPasses is the Argon2 pass count to record when
+	// TargetDerivationTime is unset (0 = mode default). Rekey sets it to
+	// keep the cost of the volume it replaces.
+	keepPasses uint8
 }
 
 // DecryptRequest contains all parameters needed to decrypt a .pcv volume.
 // The Password and/or Keyfiles must match those used during encryption.
 type DecryptRequest struct {
 	// Input/Output paths
-	InputFile  string // Path to .pcv volume (or first chunk if split)
+	InputFile  string // Path to .pcv volume; if split, the base path or its .0 chunk
 	OutputFile string // Destination path for decrypted output
 
 	// Credentials - must match encryption parameters
 	Password string   // User password
 	Keyfiles []string // Keyfile paths (validated against hash stored in header)
 
+	// PasswordFunc supplies Password when it is empty, as for
+	// EncryptRequest.PasswordFunc.
+	PasswordFunc func() (string, error)
+
 	// Decryption options
 	ForceDecrypt bool // Continue despite MAC verification failure (may produce corrupted output)
 	VerifyFirst  bool // Two-pass mode: verify MAC before decryption (slower but more secure, PCC-004)
-	AutoUnzip    bool // Automatically extract if output is a .zip file
+	AutoUnzip    bool // Automatically extract if output is a .zip file; failing to is reported as ErrUnzipFailed, keeping the .zip
 	SameLevel    bool // Extract zip contents to same directory as volume (not subdirectory)
 
+	// RestoreModes applies the Unix permissions stored in the archive on
+	// AutoUnzip, less the umask and without setuid, setgid or sticky bits.
+	// Off, extracted files get the default mode.
+	RestoreModes bool
+
 	// Volume state (typically detected automatically)
 	Recombine   bool // Volume is split into chunks that need recombining first
 	Deniability bool // Volume has deniability wrapper that needs removing first
 
+	// VerifyChunks checks the split chunks' sizes before recombining and
+	// fails with a *fileops.ChunkSizeError naming the first bad chunk.
+	VerifyChunks bool
+
+	// StrictDeniability makes a volume that looks deniable fail with
+	// ErrDeniableNotAcknowledged unless Deniability is also set, instead of
+	// surfacing as a damaged header.
+	StrictDeniability bool
+
+	// DeleteVolume removes the volume (or all its chunks) after a complete
+	// decryption. Never applied when the output was kept despite errors,
+	// but applied after a failed auto-unzip, as the kept .zip holds the
+	// whole plaintext. A failure to delete is reported as ErrDeleteFailed.
+	DeleteVolume bool
+
+	// WarnSameDevice, with DeleteVolume, warns through the Reporter before
+	// decrypting when the volume and the output directory are on the same
+	// removable device, recommending a different target. It is advisory:
+	// the decryption and the deletion go ahead either way.
+	WarnSameDevice bool
+
+	// AAD must equal the EncryptRequest.AAD the volume was created with;
+	// a mismatch fails like a wrong password. Legacy v1 volumes cannot
+	// carry AAD and are rejected when it is set.
+	AAD []byte
+
+	// MaxKeyfiles caps len(Keyfiles); more fail with ErrTooManyKeyfiles
+	// before any is read. 0 or a negative value means no limit, so volumes
+	// made with many keyfiles always open.
+	MaxKeyfiles int
+
+	// StrictKeyfiles makes Keyfiles supplied for a volume encrypted without
+	// keyfiles fail with ErrUnexpectedKeyfiles before any key derivation.
+	// Otherwise they are ignored, as the header decides, with a warning
+	// through the Reporter.
+	StrictKeyfiles bool
+
+	// Pepper must equal the EncryptRequest.Pepper. A peppered volume fails
+	// with ErrPepperRequired without it, before any key derivation.
+	Pepper []byte
+
+	// RecoveryKey, if set, is the X25519 private key matching the volume's
+	// EncryptRequest.RecoveryRecipient and replaces Password, Keyfiles and
+	// Pepper. A key that does not match fails with ErrAuthFailed, and a
+	// volume created without a recipient with ErrNoRecoveryKey.
+	RecoveryKey []byte
+
+	// LowPriority lowers the process scheduling priority before starting,
+	// as for EncryptRequest.LowPriority.
+	LowPriority bool
+
+	// CipherWorkers splits the paranoid cipher work of each block across
+	// goroutines, as for EncryptRequest.CipherWorkers. Normal volumes ignore it.
+	CipherWorkers int
+
+	// MmapOutput writes the plaintext through a shared memory mapping of
+	// the output file, grown to the payload size up front and trimmed and
+	// flushed with msync at the end, saving a write call per block on very
+	// large volumes. Where mapping is unsupported (Windows, 32-bit sizes, or
+	// a FileSystem that is not the OS) the plaintext is written normally.
+	// Ignored with Output or DiscardOutput.
+	MmapOutput bool
+
+	// DiscardOutput decrypts and authenticates the payload as usual but
+	// throws the plaintext away instead of writing OutputFile, e.g. to
+	// benchmark decryption without disk writes. OutputFile, AutoUnzip,
+	// SameLevel and DeleteVolume are ignored. Unlike Verify, the full
+	// decryption path runs; see Throughput for the measured rate.
+	DiscardOutput bool
+
+	// Output, when set, receives the plaintext as it is decrypted instead
+	// of OutputFile, e.g. the stdin of a restore command. The MAC is only
+	// checked once the whole payload has been written, so a tampered volume
+	// still fails with ErrCorruptData but Output will already have seen the
+	// bad bytes; treat them as untrusted until Decrypt returns nil.
+	// Reed-Solomon volumes are repaired in a single pass. OutputFile,
+	// AutoUnzip, SameLevel and DeleteVolume are ignored.
+	Output io.Writer
+
+	// ConfirmForceDecrypt, when set, is asked before a force decrypt keeps
+	// output that failed verification. damagedRanges lists the plaintext
+	// spans Reed-Solomon could not repair (empty when only the MAC failed).
+	// Returning false discards the output and fails with ErrCorruptData.
+	// Nil keeps the output, as before.
+	ConfirmForceDecrypt func(damagedRanges []Range) bool
+
+	// FS, if set, replaces the OS for reading the volume and writing the
+	// plaintext, as for EncryptRequest.FS.
+	FS FileSystem
+
+	// ExpectedSize and ExpectedSHA256, when set, are checked against the
+	// plaintext once the MAC has passed, e.g. from a restore manifest. A
+	// mismatch discards the output and fails with ErrContentMismatch. Zero
+	// and nil skip the checks; ExpectedSHA256 must be 32 bytes.
+	ExpectedSize   int64
+	ExpectedSHA256 []byte
+
 	// Progress reporting
 	Reporter ProgressReporter // UI callback interface (can be nil for headless operation)
 
@@ -108,7 +492,40 @@ type DecryptRequest struct {
 	RSCodecs *encoding.RSCodecs // Pre-initialized Reed-Solomon codecs
 
 	// Output - set by Decrypt() after completion
-	Kept *bool // If non-nil and ForceDecrypt was used, set to true if file was kept despite MAC failure
+	Kept       *bool    // If non-nil and ForceDecrypt was used, set to true if file was kept despite MAC failure
+	Throughput *float64 // If non-nil, set to the payload decryption rate in MiB/s
+
+	// RepairStats, if non-nil, is set to the Reed-Solomon tally of the last
+	// payload pass. Left untouched for volumes without Reed-Solomon.
+	RepairStats *RepairStats
+}
+
+// writesOutputFile reports whether the plaintext goes to OutputFile, as
+// opposed to Output or nowhere.
+func (req *DecryptRequest) writesOutputFile() bool {
+	return req.Output == nil && !req.DiscardOutput
+}
+
+// Range is a span of plaintext output, in bytes.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// RepairStats counts the 136-byte Reed-Solomon chunks of a payload pass.
+// Uncorrectable chunks had more damage than RS can repair and were passed
+// through raw, which only happens with ForceDecrypt.
+type RepairStats struct {
+	Uncorrectable int64
+	Total         int64
+}
+
+// Fraction returns the share of chunks that were uncorrectable, from 0 to 1.
+func (s RepairStats) Fraction() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Uncorrectable) / float64(s.Total)
 }
 
 // OperationContext holds mutable state during encryption/decryption operations.
@@ -117,19 +534,34 @@ type OperationContext struct {
 	// Context for cancellation and timeouts
 	Ctx context.Context
 
+	// FS is the request's FileSystem, or the OS
+	FS FileSystem
+
 	// File paths
 	InputFile  string // Current input file (may change during preprocessing)
 	OutputFile string // Final output destination
 	TempFile   string // Intermediate file path (zip archive or recombined chunks)
 
+	// PublishPath is the real output path while AtomicOutput stages the
+	// encryption under a hidden name in the request's OutputFile
+	PublishPath string
+
+	// Section is the byte range of InputFile a RecordSplit volume holds,
+	// or nil for the whole input (encrypt only)
+	Section *inputSection
+
 	// Volume header - populated during encryption or read during decryption
 	Header *header.VolumeHeader
 
+	// HeaderOffsets locates the auth values of the written header (encrypt only)
+	HeaderOffsets header.Offsets
+
 	// Cryptographic state
 	Key          []byte               // Argon2-derived key (possibly XORed with keyfile key)
 	KeyfileKey   []byte               // 32-byte key derived from keyfile(s)
 	KeyfileHash  []byte               // SHA3-256(KeyfileKey) for verification
 	SubkeyReader *crypto.SubkeyReader // HKDF stream for deriving MAC/Serpent subkeys
+	HeaderSubkey []byte               // Kept until finalize when the header MAC covers the block table or split layout
 	CipherSuite  *crypto.CipherSuite  // Initialized cipher suite (XChaCha20 + optional Serpent)
 	Counter      *crypto.Counter      // Tracks bytes for 60 GiB rekey threshold
 
@@ -141,11 +573,28 @@ type OperationContext struct {
 	TempCiphers  *fileops.TempZipCiphers // Ciphers for encrypted temp zip
 
 	// Reed-Solomon retry state (for corrupt file recovery)
-	TriedFullRSDecode bool // Prevents infinite retry loop when MAC fails
-	Kept              bool // True if ForceDecrypt was used and MAC failed
+	TriedFullRSDecode bool    // Prevents infinite retry loop when MAC fails
+	Kept              bool    // True if ForceDecrypt was used and MAC failed
+	DamagedRanges     []Range // Plaintext spans RS could not repair in the last payload pass
+	RSChunks          int64   // RS128 chunks decoded in the last payload pass
+	RSChunksBad       int64   // Of those, chunks RS could not repair
+
+	// Block hashes (Header.Flags.BlockHashes)
+	BlockTable *header.BlockTable // Filled during encryption, read with the header during decryption
+
+	// Preview (Header.Flags.Preview)
+	Preview []byte // Sealed preview: nonce, ciphertext and tag
 
 	// Recombine state - for proper cleanup
 	RecombinedFile string // Path to recombined file (separate from TempFile for when deniability changes it)
+	DearmoredFile  string // Path to the binary volume decoded from armored input
+
+	// Content counts and hashes the plaintext of the last payload pass
+	// when DecryptRequest.ExpectedSize or ExpectedSHA256 is set
+	Content *contentCheck
+
+	// UnzipErr is set when auto-unzip failed after a successful decryption
+	UnzipErr error
 
 	// Progress tracking
 	Total    int64            // Total bytes to process
@@ -161,6 +610,7 @@ func NewEncryptContext(ctx context.Context, req *EncryptRequest) *OperationConte
 	}
 	return &OperationContext{
 		Ctx:        ctx,
+		FS:         fileSystem(req.FS),
 		OutputFile: req.OutputFile,
 		Reporter:   req.Reporter,
 		Counter:    crypto.NewCounter(),
@@ -175,6 +625,7 @@ func NewDecryptContext(ctx context.Context, req *DecryptRequest) *OperationConte
 	}
 	return &OperationContext{
 		Ctx:        ctx,
+		FS:         fileSystem(req.FS),
 		InputFile:  req.InputFile,
 		OutputFile: req.OutputFile,
 		Reporter:   req.Reporter,
@@ -198,6 +649,26 @@ func (ctx *OperationContext) SetStatus(status string) {
 	}
 }
 
+// SetRate sets a status such as "Encrypting at 1.00 MiB/s (ETA: 00:00:05)"
+// and hands the speed and ETA to a RateReporter as well.
+func (ctx *OperationContext) SetRate(verb string, speedMiBps float64, eta string) {
+	if ctx.Reporter == nil {
+		return
+	}
+	ctx.Reporter.SetStatus(fmt.Sprintf("%s at %.2f MiB/s (ETA: %s)", verb, speedMiBps, eta))
+	if r, ok := ctx.Reporter.(RateReporter); ok {
+		r.SetRate(speedMiBps, eta)
+	}
+	ctx.Reporter.Update()
+}
+
+// Warn passes a non-fatal advisory to the reporter if available
+func (ctx *OperationContext) Warn(msg string) {
+	if ctx.Reporter != nil {
+		ctx.Reporter.Warn(msg)
+	}
+}
+
 // IsCancelled checks if the operation has been cancelled.
 // Returns true if either the context is done or the reporter indicates cancellation.
 func (opCtx *OperationContext) IsCancelled() bool {
@@ -218,12 +689,23 @@ func (opCtx *OperationContext) IsCancelled() bool {
 }
 
 // CancellationError returns the appropriate error when cancelled.
-// Returns context error if context is done, otherwise returns ErrCancelled.
+// Returns the context error if the context is done, otherwise ErrCancelled.
+// A timeout yields context.DeadlineExceeded and an explicit cancel yields
+// context.Canceled; a cause set with context.WithCancelCause is wrapped
+// alongside so callers can tell who cancelled.
 func (opCtx *OperationContext) CancellationError() error {
-	if opCtx.Ctx != nil {
+	return cancellationError(opCtx.Ctx)
+}
+
+func cancellationError(ctx context.Context) error {
+	if ctx != nil {
 		select {
-		case <-opCtx.Ctx.Done():
-			return opCtx.Ctx.Err()
+		case <-ctx.Done():
+			err := ctx.Err()
+			if cause := context.Cause(ctx); cause != nil && cause != err {
+				return fmt.Errorf("%w: %w", err, cause)
+			}
+			return err
 		default:
 		}
 	}
@@ -238,6 +720,59 @@ func (ctx *OperationContext) TempZipReader(r io.Reader) io.Reader {
 	return r
 }
 
+// PayloadOffset returns the file offset of the first payload byte: the end
+// of the header, or of the block table, recovery, split layout and preview
+// sections when the volume has them.
+func (ctx *OperationContext) PayloadOffset() int64 {
+	return ctx.PreviewOffset() + ctx.previewSize()
+}
+
+// PreviewOffset returns the file offset of the preview section, which
+// follows the header, any block table, recovery and split layout sections.
+func (ctx *OperationContext) PreviewOffset() int64 {
+	offset := ctx.SplitLayoutOffset()
+	if ctx.Header.Flags.SplitLayout {
+		offset += header.SplitLayoutEncSize
+	}
+	return offset
+}
+
+// SplitLayoutOffset returns the file offset of the split layout section,
+// which follows the header, any block table and any recovery section.
+func (ctx *OperationContext) SplitLayoutOffset() int64 {
+	offset := ctx.RecoveryOffset()
+	if ctx.Header.Flags.Recovery {
+		offset += header.RecoveryEncSize
+	}
+	return offset
+}
+
+// RecoveryOffset returns the file offset of the recovery section, which
+// follows the header and any block table.
+func (ctx *OperationContext) RecoveryOffset() int64 {
+	offset := int64(header.HeaderSize(len(ctx.Header.Comments)))
+	if ctx.Header.Flags.BlockHashes && ctx.BlockTable != nil {
+		offset += ctx.BlockTable.Size()
+	}
+	return offset
+}
+
+// PayloadReader limits r, positioned at PayloadOffset, to the payload, so a
+// trailer after it is not read as ciphertext.
+func (ctx *OperationContext) PayloadReader(r io.Reader) io.Reader {
+	if !ctx.Header.Flags.Trailer {
+		return r
+	}
+	return io.LimitReader(r, ctx.Total)
+}
+
+func (ctx *OperationContext) previewSize() int64 {
+	if !ctx.Header.Flags.Preview {
+		return 0
+	}
+	return header.PreviewSize(len(ctx.Preview))
+}
+
 // Close securely zeros all sensitive cryptographic material in the context.
 // This should be called via defer immediately after creating the context.
 //
@@ -249,10 +784,11 @@ func (ctx *OperationContext) Close() {
 	}
 
 	// Zero main key material
-	crypto.SecureZeroMultiple(ctx.Key, ctx.KeyfileKey, ctx.KeyfileHash)
+	crypto.SecureZeroMultiple(ctx.Key, ctx.KeyfileKey, ctx.KeyfileHash, ctx.HeaderSubkey)
 	ctx.Key = nil
 	ctx.KeyfileKey = nil
 	ctx.KeyfileHash = nil
+	ctx.HeaderSubkey = nil
 
 	// Close cipher suite (zeros internal key)
 	if ctx.CipherSuite != nil {
@@ -8,8 +8,11 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
 	"Picocrypt-NG/internal/header"
 )
 
@@ -637,6 +640,7 @@ func (r *CancellableReporter) SetStatus(text string)                     {}
 func (r *CancellableReporter) SetProgress(fraction float32, info string) { r.progressCalls++ }
 func (r *CancellableReporter) SetCanCancel(can bool)                     {}
 func (r *CancellableReporter) Update()                                   {}
+func (r *CancellableReporter) Warn(msg string)                           {}
 func (r *CancellableReporter) IsCancelled() bool {
 	return r.progressCalls > r.cancelAfter
 }
@@ -972,6 +976,165 @@ func TestEncryptContextCancellation(t *testing.T) {
 	}
 }
 
+// zipCancelReporter cancels a context after a number of progress updates
+type zipCancelReporter struct {
+	GoldenTestReporter
+	progressCalls int
+	cancelAfter   int
+	cancel        context.CancelFunc
+}
+
+func (r *zipCancelReporter) SetProgress(fraction float32, info string) {
+	r.progressCalls++
+	if r.progressCalls > r.cancelAfter {
+		r.cancel()
+	}
+}
+
+// TestEncryptZipCancellation tests that cancelling while a large file is being
+// zipped stops promptly, removes the temp zip and output, and returns the
+// matching cancellation error
+func TestEncryptZipCancellation(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		useCtx  bool
+		wantErr error
+	}{
+		{"reporter", false, perrors.ErrCancelled},
+		{"context", true, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+
+			small := filepath.Join(tmpDir, "small.txt")
+			large := filepath.Join(tmpDir, "large.bin")
+			if err := os.WriteFile(small, []byte("small file"), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+			if err := os.WriteFile(large, make([]byte, 16*1024*1024), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Progress fires once per file and once per 1 MiB read, so
+			// cancelling after 4 updates lands in the middle of large.bin
+			var reporter ProgressReporter = &CancellableReporter{cancelAfter: 4}
+			if tt.useCtx {
+				reporter = &zipCancelReporter{cancelAfter: 4, cancel: cancel}
+			}
+
+			encryptedPath := filepath.Join(tmpDir, "zipped.pcv")
+			err := Encrypt(ctx, &EncryptRequest{
+				InputFiles: []string{small, large},
+				OnlyFiles:  []string{small, large},
+				OutputFile: encryptedPath,
+				Password:   "zip_cancel_password",
+				Reporter:   reporter,
+				RSCodecs:   rsCodecs,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got: %v", tt.wantErr, err)
+			}
+
+			for _, path := range []string{
+				filepath.Join(tmpDir, "zipped.tmp"),
+				encryptedPath,
+				encryptedPath + ".incomplete",
+			} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s should not exist after cancellation", filepath.Base(path))
+				}
+			}
+		})
+	}
+}
+
+// splitCancelReporter cancels once the split reports its second of four
+// chunks, by context if cancel is set and through IsCancelled otherwise
+type splitCancelReporter struct {
+	GoldenTestReporter
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+func (r *splitCancelReporter) SetProgress(fraction float32, info string) {
+	if info != "2/4" {
+		return
+	}
+	if r.cancel != nil {
+		r.cancel()
+	} else {
+		r.cancelled = true
+	}
+}
+
+func (r *splitCancelReporter) IsCancelled() bool { return r.cancelled }
+
+// TestEncryptSplitCancellation tests that cancelling in the middle of a split
+// leaves no chunk, finished or .incomplete, and no unsplit volume behind
+func TestEncryptSplitCancellation(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		useCtx  bool
+		wantErr error
+	}{
+		{"reporter", false, perrors.ErrCancelled},
+		{"context", true, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			inputPath := filepath.Join(tmpDir, "split.bin")
+			if err := os.WriteFile(inputPath, make([]byte, 7*1024*1024), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			reporter := &splitCancelReporter{}
+			if tt.useCtx {
+				reporter.cancel = cancel
+			}
+
+			// Four chunks of 1.75 MiB, so the second is cancelled half written
+			encryptedPath := filepath.Join(tmpDir, "split.bin.pcv")
+			err := Encrypt(ctx, &EncryptRequest{
+				InputFile:  inputPath,
+				OutputFile: encryptedPath,
+				Password:   "split_cancel_password",
+				Split:      true,
+				ChunkSize:  4,
+				ChunkUnit:  fileops.SplitUnitTotal,
+				Reporter:   reporter,
+				RSCodecs:   rsCodecs,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got: %v", tt.wantErr, err)
+			}
+
+			left, _ := filepath.Glob(encryptedPath + "*")
+			if len(left) > 0 {
+				t.Errorf("files left after cancellation: %v", left)
+			}
+		})
+	}
+}
+
 // TestDecryptContextCancellation tests that decryption respects context cancellation.
 // This tests the standard Go context.Context pattern for cancellation.
 func TestDecryptContextCancellation(t *testing.T) {
@@ -1030,6 +1193,88 @@ func TestDecryptContextCancellation(t *testing.T) {
 	}
 }
 
+// TestDecryptCancellationCause tests that a timed-out decrypt and an
+// explicitly cancelled one return distinguishable errors
+func TestDecryptCancellationCause(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "cause.bin")
+	if err := os.WriteFile(inputPath, []byte("cancellation cause test data"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := inputPath + ".pcv"
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: encryptedPath,
+		Password:   "cause_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, func())
+		want    []error
+		notWant []error
+	}{
+		{
+			name: "timeout",
+			ctx: func() (context.Context, func()) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+				<-ctx.Done()
+				return ctx, cancel
+			},
+			want:    []error{context.DeadlineExceeded},
+			notWant: []error{context.Canceled, perrors.ErrCancelled},
+		},
+		{
+			name: "explicit",
+			ctx: func() (context.Context, func()) {
+				ctx, cancel := context.WithCancelCause(context.Background())
+				cancel(perrors.ErrCancelled)
+				return ctx, func() {}
+			},
+			want:    []error{context.Canceled, perrors.ErrCancelled},
+			notWant: []error{context.DeadlineExceeded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, release := tt.ctx()
+			defer release()
+
+			outputPath := filepath.Join(tmpDir, tt.name+".out")
+			err := Decrypt(ctx, &DecryptRequest{
+				InputFile:  encryptedPath,
+				OutputFile: outputPath,
+				Password:   "cause_password",
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("Expected %v, got: %v", want, err)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if errors.Is(err, notWant) {
+					t.Errorf("Error %v should not match %v", err, notWant)
+				}
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Error("Output should not exist after cancellation")
+			}
+		})
+	}
+}
+
 // TestRoundTripLargeFile tests encryption/decryption with a larger file to hit rekey code paths
 func TestRoundTripLargeFile(t *testing.T) {
 	if testing.Short() {
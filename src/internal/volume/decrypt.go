@@ -3,9 +3,11 @@ package volume
 import (
 	"context"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -25,8 +27,42 @@ import (
 func Decrypt(ctx context.Context, req *DecryptRequest) error {
 	opCtx := NewDecryptContext(ctx, req)
 	defer opCtx.Close() // Secure zeroing of key material
+	return decrypt(opCtx, req)
+}
+
+// decrypt runs every decryption phase on opCtx. The caller owns opCtx, so
+// ExtractFile can still use the keys after the payload is verified.
+func decrypt(opCtx *OperationContext, req *DecryptRequest) error {
+	req, err := req.withPassword()
+	if err != nil {
+		return err
+	}
 
 	log.Info("starting decryption", log.String("input", req.InputFile))
+	if req.LowPriority {
+		lowerPriority()
+	}
+
+	// Refuse up front if the output would overwrite the volume or cannot
+	// fit; nothing has been written yet
+	if err := checkDecryptSameInputOutput(req); err != nil {
+		return err
+	}
+	if err := decryptCheckFileSystem(req); err != nil {
+		return err
+	}
+	if err := checkExpectedContent(req); err != nil {
+		return err
+	}
+	if err := checkDecryptKeyfileCount(req); err != nil {
+		return err
+	}
+	if err := decryptCheckSpace(req); err != nil {
+		return err
+	}
+	if req.WarnSameDevice && req.DeleteVolume && req.writesOutputFile() {
+		warnSameRemovableDevice(opCtx, req)
+	}
 
 	// Phase 1: Preprocess (recombine if split, remove deniability)
 	if err := decryptPreprocess(opCtx, req); err != nil {
@@ -57,6 +93,10 @@ func Decrypt(ctx context.Context, req *DecryptRequest) error {
 		cleanupDecrypt(opCtx, req)
 		return err
 	}
+	if err := decryptCheckKeyfiles(opCtx, req); err != nil {
+		cleanupDecrypt(opCtx, req)
+		return err
+	}
 
 	// Phase 5.5 (optional): Two-pass verification - verify MAC BEFORE decryption
 	// This addresses security audit recommendation PCC-004: authenticate ciphertext
@@ -94,15 +134,48 @@ func Decrypt(ctx context.Context, req *DecryptRequest) error {
 		return err
 	}
 
+	// Phase 8 (optional): Delete the volume after a clean, complete decryption.
+	// A failed auto-unzip does not stop it: the kept .zip holds everything.
+	if req.DeleteVolume && req.writesOutputFile() {
+		if err := decryptDeleteVolume(opCtx, req); err != nil {
+			return errors.Join(opCtx.UnzipErr, err)
+		}
+	}
+
+	if opCtx.UnzipErr != nil {
+		return opCtx.UnzipErr
+	}
+
 	log.Info("decryption completed successfully")
 	return nil
 }
 
+// splitVolumeBase returns the base path of a split volume given either the
+// base itself or one of its numbered chunks (file.pcv.0 -> file.pcv), the
+// same way the GUI treats a dropped chunk.
+func splitVolumeBase(path string) string {
+	idx := strings.LastIndex(path, ".pcv.")
+	if idx < 0 {
+		return path
+	}
+	suffix := path[idx+len(".pcv."):]
+	if suffix == "" {
+		return path
+	}
+	for _, c := range suffix {
+		if c < '0' || c > '9' {
+			return path
+		}
+	}
+	return path[:idx+len(".pcv")]
+}
+
 func decryptPreprocess(ctx *OperationContext, req *DecryptRequest) error {
 	inputFile := req.InputFile
 
 	// Recombine split chunks if needed
 	if req.Recombine {
+		inputFile = splitVolumeBase(inputFile)
 		ctx.SetStatus("Recombining chunks...")
 
 		outputPath := strings.TrimSuffix(inputFile, ".pcv") + ".pcv"
@@ -118,6 +191,7 @@ func decryptPreprocess(ctx *OperationContext, req *DecryptRequest) error {
 			Cancel: func() bool {
 				return ctx.IsCancelled()
 			},
+			VerifySizes: req.VerifyChunks,
 		})
 		if err != nil {
 			return err
@@ -129,6 +203,32 @@ func decryptPreprocess(ctx *OperationContext, req *DecryptRequest) error {
 		inputFile = outputPath
 	}
 
+	// Decode an armored volume back to binary first
+	if isArmored(ctx.FS, inputFile) {
+		// Decoding writes a temporary file beside the volume on the OS
+		if err := checkFileSystem(ctx.FS, []fsOption{{"Armor", true}}); err != nil {
+			return err
+		}
+		dearmored, err := dearmorVolume(ctx, inputFile)
+		if err != nil {
+			return err
+		}
+		ctx.DearmoredFile = dearmored
+		inputFile = dearmored
+	}
+
+	// The deniability checks below are the first to decode with the codecs
+	if req.StrictDeniability || req.Deniability {
+		if err := checkRSCodecs(req.RSCodecs); err != nil {
+			return err
+		}
+	}
+
+	// In strict mode a deniable-looking volume must be acknowledged explicitly
+	if req.StrictDeniability && !req.Deniability && isDeniable(ctx.FS, inputFile, req.RSCodecs) {
+		return perrors.ErrDeniableNotAcknowledged
+	}
+
 	// Remove deniability wrapper if present
 	if req.Deniability {
 		decrypted, err := RemoveDeniability(inputFile, req.Password, ctx.Reporter, req.RSCodecs)
@@ -146,7 +246,7 @@ func decryptPreprocess(ctx *OperationContext, req *DecryptRequest) error {
 	ctx.InputFile = inputFile
 
 	// Get file size
-	stat, err := os.Stat(inputFile)
+	stat, err := ctx.FS.Stat(inputFile)
 	if err != nil {
 		return fmt.Errorf("stat input: %w", err)
 	}
@@ -155,17 +255,32 @@ func decryptPreprocess(ctx *OperationContext, req *DecryptRequest) error {
 	return nil
 }
 
+// checkRSCodecs refuses a codec set with a different geometry than volumes
+// are written with, which would decode them into garbage.
+func checkRSCodecs(rs *encoding.RSCodecs) error {
+	if err := rs.Verify(); err != nil {
+		return fmt.Errorf("%w: %w", perrors.ErrRSCodecMismatch, err)
+	}
+	return nil
+}
+
 func decryptReadHeader(ctx *OperationContext, req *DecryptRequest) error {
 	ctx.SetStatus("Reading values...")
 
-	fin, err := os.Open(ctx.InputFile)
+	fin, err := ctx.FS.Open(ctx.InputFile)
 	if err != nil {
 		return fmt.Errorf("open input: %w", err)
 	}
 	defer func() { _ = fin.Close() }()
 
+	if err := checkRSCodecs(req.RSCodecs); err != nil {
+		return err
+	}
 	reader := header.NewReader(fin, req.RSCodecs)
 	result, err := reader.ReadHeader()
+	if errors.Is(err, header.ErrTruncatedHeader) || errors.Is(err, header.ErrInvalidCommentLength) {
+		return wrapTruncated(err)
+	}
 	if err != nil {
 		return fmt.Errorf("read header: %w", err)
 	}
@@ -184,6 +299,61 @@ func decryptReadHeader(ctx *OperationContext, req *DecryptRequest) error {
 	// Update total size with comment length
 	ctx.Total -= int64(len(ctx.Header.Comments)) * 3
 
+	// The block table follows the header and is authenticated with it
+	if ctx.Header.Flags.BlockHashes {
+		table, err := header.ReadBlockTable(fin, req.RSCodecs)
+		if err != nil {
+			return wrapTruncated(err)
+		}
+		ctx.BlockTable = table
+		ctx.Header.BlockTableDigest = table.Digest()
+		ctx.Total -= table.Size()
+	}
+
+	// Then the wrapped recovery keys, which the header MAC also covers
+	if ctx.Header.Flags.Recovery {
+		wrapped, err := header.ReadRecovery(fin, req.RSCodecs)
+		if err != nil {
+			return wrapTruncated(err)
+		}
+		ctx.Header.RecoveryWrap = wrapped
+		ctx.Total -= header.RecoveryEncSize
+	}
+
+	// And the split layout, checked against the chunks once the MAC passes
+	if ctx.Header.Flags.SplitLayout {
+		layout, err := header.ReadSplitLayout(fin, req.RSCodecs)
+		if err != nil {
+			return wrapTruncated(err)
+		}
+		ctx.Header.SplitLayout = layout
+		ctx.Total -= header.SplitLayoutEncSize
+	}
+
+	// So does the preview, which is authenticated on its own
+	if ctx.Header.Flags.Preview {
+		sealed, err := header.ReadPreview(fin, req.RSCodecs)
+		if err != nil {
+			return wrapTruncated(err)
+		}
+		ctx.Preview = sealed
+		ctx.Total -= header.PreviewSize(len(sealed))
+	}
+
+	// The trailer ends the volume and must describe the payload found here
+	if ctx.Header.Flags.Trailer {
+		trailer, err := header.ReadTrailer(fin, req.RSCodecs)
+		if err != nil {
+			return wrapTruncated(err)
+		}
+		ctx.Header.Trailer = trailer
+		ctx.Total -= header.TrailerEncSize
+		if payload := trailer.Fields[header.FieldPayload]; payload.Offset != ctx.PayloadOffset() || payload.Size != ctx.Total {
+			return fmt.Errorf("%w: trailer places the payload at %d+%d, not %d+%d",
+				perrors.ErrCorruptHeader, payload.Offset, payload.Size, ctx.PayloadOffset(), ctx.Total)
+		}
+	}
+
 	// Check for legacy v1
 	ctx.IsLegacyV1 = ctx.Header.IsLegacyV1()
 
@@ -193,10 +363,35 @@ func decryptReadHeader(ctx *OperationContext, req *DecryptRequest) error {
 	return nil
 }
 
+// wrapTruncated reports a header section cut off by the end of the volume as
+// ErrTruncatedVolume; any other failure to read one means it is damaged.
+func wrapTruncated(err error) error {
+	if errors.Is(err, header.ErrTruncatedHeader) {
+		return fmt.Errorf("%w: %w", perrors.ErrTruncatedVolume, err)
+	}
+	return fmt.Errorf("%w: %w", perrors.ErrCorruptHeader, err)
+}
+
 func decryptDeriveKeys(ctx *OperationContext, req *DecryptRequest) error {
+	// Argon2 can't be interrupted, so don't start it for a dead context
+	if ctx.IsCancelled() {
+		return ctx.CancellationError()
+	}
+	if req.RecoveryKey != nil {
+		return decryptRecoverKeys(ctx, req)
+	}
 	ctx.SetStatus("Deriving key...")
 
-	key, err := crypto.DeriveKey([]byte(req.Password), ctx.Header.Salt, ctx.Header.Flags.Paranoid)
+	// The pepper is not stored, so only the flag tells us one is needed
+	if ctx.Header.Flags.Pepper && len(req.Pepper) == 0 {
+		return perrors.ErrPepperRequired
+	}
+	if !ctx.Header.Flags.Pepper && len(req.Pepper) > 0 {
+		return perrors.NewValidationError("Pepper", "volume was not created with a pepper")
+	}
+	password := crypto.PepperPassword([]byte(req.Password), req.Pepper)
+	defer crypto.SecureZero(password)
+	key, err := crypto.DeriveKeyCost(password, ctx.Header.Salt, ctx.Header.Flags.Paranoid, ctx.Header.Flags.Threads, ctx.Header.Flags.Passes)
 	if err != nil {
 		return err
 	}
@@ -205,7 +400,25 @@ func decryptDeriveKeys(ctx *OperationContext, req *DecryptRequest) error {
 	return nil
 }
 
+// decryptCheckKeyfiles applies StrictKeyfiles to keyfiles supplied for a
+// volume that uses none, or warns that they were ignored. It runs once per
+// operation, after the header is authenticated, since the key phases above
+// are repeated for VerifyFirst and the Reed-Solomon retry.
+func decryptCheckKeyfiles(ctx *OperationContext, req *DecryptRequest) error {
+	if ctx.UseKeyfiles || len(req.Keyfiles) == 0 {
+		return nil
+	}
+	if req.StrictKeyfiles {
+		return perrors.ErrUnexpectedKeyfiles
+	}
+	ctx.Warn("This volume does not use keyfiles; the supplied keyfiles were ignored")
+	return nil
+}
+
 func decryptProcessKeyfiles(ctx *OperationContext, req *DecryptRequest) error {
+	if req.RecoveryKey != nil {
+		return nil // decryptRecoverKeys already has the keyfile key
+	}
 	if !ctx.UseKeyfiles {
 		ctx.KeyfileHash = make([]byte, 32)
 		return nil
@@ -217,7 +430,12 @@ func decryptProcessKeyfiles(ctx *OperationContext, req *DecryptRequest) error {
 
 	ctx.SetStatus("Reading keyfiles...")
 
-	result, err := keyfile.Process(req.Keyfiles, ctx.Header.Flags.KeyfileOrdered, func(p float32) {
+	// The header, not the caller, decides which hash applies
+	alg := keyfile.HashSHA3
+	if ctx.Header.Flags.KeyfileBLAKE2b {
+		alg = keyfile.HashBLAKE2b
+	}
+	result, err := keyfile.ProcessWith(req.Keyfiles, ctx.Header.Flags.KeyfileOrdered, alg, func(p float32) {
 		ctx.UpdateProgress(p, "")
 	})
 	if err != nil {
@@ -234,6 +452,11 @@ func decryptVerifyAuth(ctx *OperationContext, req *DecryptRequest) error {
 	ctx.SetStatus("Calculating values...")
 
 	if ctx.IsLegacyV1 {
+		// v1 has no header MAC to bind AAD into
+		if len(req.AAD) > 0 {
+			return fmt.Errorf("%w: legacy v1 volumes cannot carry AAD", perrors.ErrAuthFailed)
+		}
+
 		// v1: HKDF initialized AFTER keyfile XOR
 		// First verify password using SHA3-512(key)
 		authResult := header.VerifyV1Header(ctx.Key, ctx.Header)
@@ -281,7 +504,7 @@ func decryptVerifyAuth(ctx *OperationContext, req *DecryptRequest) error {
 		}
 
 		// Verify header MAC
-		authResult := header.VerifyV2Header(subkeyHeader, ctx.Header, ctx.KeyfileHash)
+		authResult := header.VerifyV2Header(subkeyHeader, ctx.Header, ctx.KeyfileHash, req.AAD)
 
 		if !authResult.Valid {
 			if req.ForceDecrypt {
@@ -292,6 +515,15 @@ func decryptVerifyAuth(ctx *OperationContext, req *DecryptRequest) error {
 			}
 		}
 
+		// The recorded split layout is only trusted once the MAC has passed
+		if authResult.Valid && req.Recombine && ctx.Header.Flags.SplitLayout {
+			layout := ctx.Header.SplitLayout
+			err := fileops.CheckChunkLayout(splitVolumeBase(req.InputFile), layout.ChunkSize, layout.VolumeSize, int(layout.Chunks))
+			if err != nil {
+				return err
+			}
+		}
+
 		// Verify keyfiles separately for better error messages
 		if ctx.UseKeyfiles {
 			if !header.VerifyKeyfileHash(ctx.KeyfileHash, ctx.Header.KeyfileHash) {
@@ -343,26 +575,28 @@ func decryptVerifyMACFirst(ctx *OperationContext, req *DecryptRequest) error {
 	}
 
 	// Open input file
-	fin, err := os.Open(ctx.InputFile)
+	fin, err := ctx.FS.Open(ctx.InputFile)
 	if err != nil {
 		return fmt.Errorf("open input: %w", err)
 	}
 	defer func() { _ = fin.Close() }()
 
 	// Skip past header
-	headerSize := header.HeaderSize(len(ctx.Header.Comments))
-	if _, err := fin.Seek(int64(headerSize), 0); err != nil {
+	if _, err := fin.Seek(ctx.PayloadOffset(), 0); err != nil {
 		return fmt.Errorf("seek past header: %w", err)
 	}
+	payload := ctx.PayloadReader(fin)
 
 	// Verification loop - read ciphertext and update MAC without decrypting
-	ctx.Reporter.SetCanCancel(true)
+	if ctx.Reporter != nil {
+		ctx.Reporter.SetCanCancel(true)
+	}
 	startTime := time.Now()
 	var done int64
 	var counter int64
 
 	reedsolo := ctx.Header.Flags.ReedSolomon
-	padded := ctx.Header.Flags.Padded
+	pad := finalPadOf(ctx.Header.Flags)
 
 	// Pre-allocate buffer outside loop to reduce GC pressure
 	var srcBufSize int
@@ -378,7 +612,13 @@ func decryptVerifyMACFirst(ctx *OperationContext, req *DecryptRequest) error {
 			return ctx.CancellationError()
 		}
 
-		n, readErr := fin.Read(src)
+		n, readErr := readPayload(payload, src, reedsolo)
+		if errors.Is(readErr, perrors.ErrTruncatedVolume) {
+			if !req.ForceDecrypt {
+				return readErr
+			}
+			readErr = io.EOF
+		}
 		if n > 0 {
 			srcData := src[:n]
 			var data []byte
@@ -386,7 +626,7 @@ func decryptVerifyMACFirst(ctx *OperationContext, req *DecryptRequest) error {
 			// Decode Reed-Solomon if enabled (fast decode for verification)
 			if reedsolo {
 				var decErr error
-				data, decErr = decodeWithRSFast(srcData, req.RSCodecs, done+int64(n) >= ctx.Total, padded, req.ForceDecrypt, true)
+				data, _, decErr = decodeWithRSFast(srcData, req.RSCodecs, done+int64(n) >= ctx.Total, pad, req.ForceDecrypt, true)
 				if decErr != nil && !req.ForceDecrypt {
 					return decErr
 				}
@@ -406,7 +646,7 @@ func decryptVerifyMACFirst(ctx *OperationContext, req *DecryptRequest) error {
 
 			progress, speed, eta := util.Statify(done, ctx.Total, startTime)
 			ctx.UpdateProgress(progress/2, fmt.Sprintf("%.2f%% (verifying)", progress*50)) // Show 0-50% for pass 1
-			ctx.SetStatus(fmt.Sprintf("Verifying at %.2f MiB/s (ETA: %s)", speed, eta))
+			ctx.SetRate("Verifying", speed, eta)
 
 			// Handle rekey threshold - we need to track this for MAC computation
 			// but can't actually rekey without ciphers. For very large files (>60GiB),
@@ -441,13 +681,18 @@ func decryptVerifyMACFirst(ctx *OperationContext, req *DecryptRequest) error {
 }
 
 func decryptPayload(ctx *OperationContext, req *DecryptRequest) error {
+	// Plaintext streamed to Output cannot be taken back for a second RS
+	// pass, so the only pass repairs as it goes
+	if req.Output != nil {
+		ctx.TriedFullRSDecode = true
+		return decryptPayloadWithFastDecode(ctx, req, false)
+	}
 	return decryptPayloadWithFastDecode(ctx, req, true) // First pass: fast decode (skip RS error correction)
 }
 
-// decryptPayloadWithFastDecode performs the actual decryption.
-// When fastDecode is true, RS decoding just returns first 128 bytes (no error correction).
-// This matches the original Picocrypt behavior for performance.
-func decryptPayloadWithFastDecode(ctx *OperationContext, req *DecryptRequest, fastDecode bool) error {
+// decryptInitCipher reads the remaining subkeys and creates the payload cipher
+// suite. It must run after decryptVerifyAuth has positioned the HKDF stream.
+func decryptInitCipher(ctx *OperationContext) error {
 	// Read remaining subkeys
 	macSubkey, err := ctx.SubkeyReader.MACSubkey()
 	if err != nil {
@@ -480,33 +725,74 @@ func decryptPayloadWithFastDecode(ctx *OperationContext, req *DecryptRequest, fa
 	}
 	ctx.CipherSuite = cipherSuite
 
+	return nil
+}
+
+// decryptPayloadWithFastDecode performs the actual decryption.
+// When fastDecode is true, RS decoding just returns first 128 bytes (no error correction).
+// This matches the original Picocrypt behavior for performance.
+func decryptPayloadWithFastDecode(ctx *OperationContext, req *DecryptRequest, fastDecode bool) error {
+	if err := decryptInitCipher(ctx); err != nil {
+		return err
+	}
+	ctx.CipherSuite.SetWorkers(req.CipherWorkers)
+
 	// Open files
-	fin, err := os.Open(ctx.InputFile)
+	fin, err := ctx.FS.Open(ctx.InputFile)
 	if err != nil {
 		return fmt.Errorf("open input: %w", err)
 	}
 	defer func() { _ = fin.Close() }()
 
 	// Skip past header
-	headerSize := header.HeaderSize(len(ctx.Header.Comments))
-	if _, err := fin.Seek(int64(headerSize), 0); err != nil {
+	if _, err := fin.Seek(ctx.PayloadOffset(), 0); err != nil {
 		return fmt.Errorf("seek past header: %w", err)
 	}
+	payload := ctx.PayloadReader(fin)
+
+	// With DiscardOutput nothing is created and the plaintext goes nowhere
+	var fout File
+	var out io.Writer = io.Discard
+	if req.Output != nil {
+		out = req.Output
+	} else if !req.DiscardOutput {
+		fout, err = ctx.FS.Create(req.OutputFile + ".incomplete")
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer func() { _ = fout.Close() }()
+		out = fout
+	}
+	var mapped *fileops.MmapWriter
+	if fout != nil && req.MmapOutput {
+		if mapped = mmapOutput(ctx, fout); mapped != nil {
+			defer func() { _ = mapped.Close() }()
+			out = mapped
+		}
+	}
+	if req.ExpectedSize > 0 || req.ExpectedSHA256 != nil {
+		ctx.Content = newContentCheck()
+		out = io.MultiWriter(out, ctx.Content)
+	}
 
-	fout, err := os.Create(req.OutputFile + ".incomplete")
-	if err != nil {
-		return fmt.Errorf("create output: %w", err)
+	if ctx.Header.Flags.CDCDedup {
+		if err := decryptCDCPayload(ctx, req, payload, out); err != nil {
+			return err
+		}
+		return syncOutput(fout, mapped)
 	}
-	defer func() { _ = fout.Close() }()
 
 	// Decrypt loop
 	ctx.Reporter.SetCanCancel(true)
 	startTime := time.Now()
 	var done int64
 	var counter int64
+	var written int64
+	ctx.DamagedRanges = nil
+	ctx.RSChunks, ctx.RSChunksBad = 0, 0
 
 	reedsolo := ctx.Header.Flags.ReedSolomon
-	padded := ctx.Header.Flags.Padded
+	pad := finalPadOf(ctx.Header.Flags)
 
 	// Pre-allocate buffers outside loop to reduce GC pressure
 	// RS-encoded buffer is larger: 1 MiB * 136/128 = ~1.0625 MiB
@@ -525,17 +811,29 @@ func decryptPayloadWithFastDecode(ctx *OperationContext, req *DecryptRequest, fa
 			return ctx.CancellationError()
 		}
 
-		n, readErr := fin.Read(src)
+		n, readErr := readPayload(payload, src, reedsolo)
+		if errors.Is(readErr, perrors.ErrTruncatedVolume) {
+			if !req.ForceDecrypt {
+				return readErr
+			}
+			readErr = io.EOF
+		}
 		if n > 0 {
 			srcData := src[:n]
 			var data []byte
 
 			// Decode Reed-Solomon if enabled
 			if reedsolo {
+				var bad int
 				var decErr error
-				data, decErr = decodeWithRSFast(srcData, req.RSCodecs, done+int64(n) >= ctx.Total, padded, req.ForceDecrypt, fastDecode)
-				if decErr != nil && !req.ForceDecrypt {
-					return decErr
+				data, bad, decErr = decodeWithRSFast(srcData, req.RSCodecs, done+int64(n) >= ctx.Total, pad, req.ForceDecrypt, fastDecode)
+				ctx.RSChunks += int64((n + encoding.RS128EncodedSize - 1) / encoding.RS128EncodedSize)
+				ctx.RSChunksBad += int64(bad)
+				if decErr != nil {
+					if !req.ForceDecrypt {
+						return decErr
+					}
+					ctx.DamagedRanges = append(ctx.DamagedRanges, Range{Offset: written, Length: int64(len(data))})
 				}
 			} else {
 				data = srcData
@@ -546,9 +844,10 @@ func decryptPayloadWithFastDecode(ctx *OperationContext, req *DecryptRequest, fa
 			// Decrypt: MAC -> XChaCha20 -> Serpent
 			ctx.CipherSuite.Decrypt(dstData, data)
 
-			if _, err := fout.Write(dstData); err != nil {
+			if _, err := out.Write(dstData); err != nil {
 				return fmt.Errorf("write plaintext: %w", err)
 			}
+			written += int64(len(dstData))
 
 			if reedsolo {
 				done += int64(util.MiB / encoding.RS128DataSize * encoding.RS128EncodedSize)
@@ -560,9 +859,9 @@ func decryptPayloadWithFastDecode(ctx *OperationContext, req *DecryptRequest, fa
 			progress, speed, eta := util.Statify(done, ctx.Total, startTime)
 			ctx.UpdateProgress(progress, fmt.Sprintf("%.2f%%", progress*100))
 			if fastDecode {
-				ctx.SetStatus(fmt.Sprintf("Decrypting at %.2f MiB/s (ETA: %s)", speed, eta))
+				ctx.SetRate("Decrypting", speed, eta)
 			} else {
-				ctx.SetStatus(fmt.Sprintf("Repairing at %.2f MiB/s (ETA: %s)", speed, eta))
+				ctx.SetRate("Repairing", speed, eta)
 			}
 
 			// Rekey every 60 GiB
@@ -582,14 +881,67 @@ func decryptPayloadWithFastDecode(ctx *OperationContext, req *DecryptRequest, fa
 		}
 	}
 
-	// Sync before verifying MAC to ensure all data is written
+	if req.Throughput != nil {
+		if elapsed := time.Since(startTime).Seconds(); elapsed > 0 {
+			*req.Throughput = float64(written) / elapsed / float64(util.MiB)
+		}
+	}
+	if req.RepairStats != nil && reedsolo {
+		*req.RepairStats = RepairStats{Uncorrectable: ctx.RSChunksBad, Total: ctx.RSChunks}
+	}
+
+	return syncOutput(fout, mapped)
+}
+
+// readPayload fills src from the payload, so that only the last read can be
+// short. In Reed-Solomon mode that last block must still end on an encoded
+// chunk; one that does not was cut off and fails with ErrTruncatedVolume.
+func readPayload(fin io.Reader, src []byte, reedsolo bool) (int, error) {
+	n, err := io.ReadFull(fin, src)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+		if reedsolo && n%encoding.RS128EncodedSize != 0 {
+			err = fmt.Errorf("%w: payload ends inside a Reed-Solomon chunk", perrors.ErrTruncatedVolume)
+		}
+	}
+	return n, err
+}
+
+// syncOutput syncs the plaintext, if written to a file, before the MAC is
+// verified, to ensure all data is written. A mapped output is flushed and
+// trimmed to the plaintext size first.
+func syncOutput(fout File, mapped *fileops.MmapWriter) error {
+	if fout == nil {
+		return nil
+	}
+	if mapped != nil {
+		if err := mapped.Close(); err != nil {
+			return err
+		}
+	}
 	if err := fout.Sync(); err != nil {
 		return fmt.Errorf("sync output: %w", err)
 	}
-
 	return nil
 }
 
+// mmapOutput maps fout for DecryptRequest.MmapOutput, sized for the payload,
+// which is never smaller than the plaintext. It returns nil where mapping is
+// not possible, and the plaintext is then written to fout as usual.
+func mmapOutput(ctx *OperationContext, fout File) *fileops.MmapWriter {
+	f, ok := fout.(*os.File)
+	if !ok {
+		log.Warn("memory-mapped output needs an OS file, writing normally")
+		return nil
+	}
+	mapped, err := fileops.NewMmapWriter(f, ctx.Total)
+	if err != nil {
+		log.Warn("memory-mapped output unavailable, writing normally", log.Err(err))
+		return nil
+	}
+	return mapped
+}
+
 func decryptFinalize(ctx *OperationContext, req *DecryptRequest) error {
 	ctx.SetStatus("Comparing values...")
 
@@ -603,7 +955,7 @@ func decryptFinalize(ctx *OperationContext, req *DecryptRequest) error {
 			ctx.TriedFullRSDecode = true
 
 			// Remove incomplete file
-			_ = os.Remove(req.OutputFile + ".incomplete")
+			removeIncomplete(ctx, req)
 
 			// Re-derive keys (needed to reset HKDF stream)
 			if err := decryptDeriveKeys(ctx, req); err != nil {
@@ -625,6 +977,10 @@ func decryptFinalize(ctx *OperationContext, req *DecryptRequest) error {
 			return decryptFinalize(ctx, req)
 		}
 
+		if req.ForceDecrypt && req.ConfirmForceDecrypt != nil && !req.ConfirmForceDecrypt(ctx.DamagedRanges) {
+			removeIncomplete(ctx, req)
+			return perrors.ErrCorruptData
+		}
 		if req.ForceDecrypt {
 			// Continue but mark as kept
 			ctx.Kept = true
@@ -633,14 +989,23 @@ func decryptFinalize(ctx *OperationContext, req *DecryptRequest) error {
 			}
 		} else {
 			// Remove incomplete output
-			_ = os.Remove(req.OutputFile + ".incomplete")
+			removeIncomplete(ctx, req)
 			return perrors.ErrCorruptData
 		}
 	}
 
+	if ctx.Content != nil {
+		if err := ctx.Content.verify(req); err != nil {
+			removeIncomplete(ctx, req)
+			return err
+		}
+	}
+
 	// Rename to final output
-	if err := os.Rename(req.OutputFile+".incomplete", req.OutputFile); err != nil {
-		return fmt.Errorf("rename output: %w", err)
+	if req.writesOutputFile() {
+		if err := ctx.FS.Rename(req.OutputFile+".incomplete", req.OutputFile); err != nil {
+			return fmt.Errorf("rename output: %w", err)
+		}
 	}
 
 	// Cleanup temp files
@@ -651,23 +1016,42 @@ func decryptFinalize(ctx *OperationContext, req *DecryptRequest) error {
 	if ctx.RecombinedFile != "" && ctx.RecombinedFile != ctx.TempFile {
 		_ = os.Remove(ctx.RecombinedFile)
 	}
+	if ctx.DearmoredFile != "" {
+		_ = os.Remove(ctx.DearmoredFile)
+	}
 
 	// Auto-unzip if requested and output is a .zip
-	if req.AutoUnzip && strings.HasSuffix(req.OutputFile, ".zip") {
+	if req.AutoUnzip && req.writesOutputFile() && strings.HasSuffix(req.OutputFile, ".zip") {
 		ctx.SetStatus("Unzipping...")
-		err := fileops.Unpack(fileops.UnpackOptions{
-			ZipPath:   req.OutputFile,
-			SameLevel: req.SameLevel,
-			Progress: func(p float32, info string) {
-				ctx.UpdateProgress(p, info)
-			},
-			Status: func(s string) {
-				ctx.SetStatus(s)
-			},
-			Cancel: ctx.IsCancelled,
-		})
+		// The central directory knows how much the archive extracts to
+		size, err := fileops.UnpackedSize(req.OutputFile)
+		if err == nil {
+			err = checkFreeSpace(filepath.Dir(req.OutputFile), size)
+		}
+		if err == nil {
+			err = fileops.Unpack(fileops.UnpackOptions{
+				ZipPath:      req.OutputFile,
+				SameLevel:    req.SameLevel,
+				RestoreModes: req.RestoreModes,
+				Progress: func(p float32, info string) {
+					ctx.UpdateProgress(p, info)
+				},
+				Status: func(s string) {
+					ctx.SetStatus(s)
+				},
+				Cancel:  ctx.IsCancelled,
+				NameKey: nameKeyFunc(ctx.Key),
+			})
+		}
+		if err != nil && ctx.IsCancelled() {
+			return ctx.CancellationError()
+		}
 		if err != nil {
-			return fmt.Errorf("unzip: %w", err)
+			// The decryption itself succeeded, so keep the .zip for the
+			// user to extract by other means
+			log.Warn("auto-unzip failed, keeping the decrypted zip", log.String("zip", req.OutputFile), log.Err(err))
+			ctx.UnzipErr = fmt.Errorf("%w: %w", perrors.ErrUnzipFailed, err)
+			return nil
 		}
 
 		// Remove the zip
@@ -685,15 +1069,50 @@ func cleanupDecrypt(ctx *OperationContext, req *DecryptRequest) {
 	if ctx.RecombinedFile != "" && ctx.RecombinedFile != ctx.TempFile {
 		_ = os.Remove(ctx.RecombinedFile)
 	}
-	_ = os.Remove(req.OutputFile + ".incomplete")
+	if ctx.DearmoredFile != "" {
+		_ = os.Remove(ctx.DearmoredFile)
+	}
+	removeIncomplete(ctx, req)
 	// Note: ctx.Close() is called via defer in Decrypt()
 }
 
+// removeIncomplete deletes the partial output file, if req writes one.
+func removeIncomplete(ctx *OperationContext, req *DecryptRequest) {
+	if req.writesOutputFile() {
+		_ = ctx.FS.Remove(req.OutputFile + ".incomplete")
+	}
+}
+
+// finalPad says how the last RS128 chunk of a volume is unpadded
+type finalPad struct {
+	padded bool // A full-size last block still ends in a padded chunk
+	length int  // Stored pad length, or -1 to read it from the last byte
+}
+
+// finalPadOf returns the padding recorded in flags: the stored length with
+// ExplicitPadding, else the Padded heuristic and PKCS#7's last byte.
+func finalPadOf(flags header.Flags) finalPad {
+	if flags.ExplicitPadding {
+		return finalPad{padded: flags.PadLen > 0, length: int(flags.PadLen)}
+	}
+	return finalPad{padded: flags.Padded, length: -1}
+}
+
+// unpad removes the padding from the last chunk
+func (p finalPad) unpad(chunk []byte) []byte {
+	if p.length < 0 {
+		return encoding.Unpad(chunk)
+	}
+	return encoding.UnpadLen(chunk, p.length)
+}
+
 // decodeWithRSFast decodes Reed-Solomon encoded data with optional fast decode.
 // When fastDecode is true, it skips RS error correction and just returns the data bytes.
 // This matches the original Picocrypt behavior for performance.
-func decodeWithRSFast(data []byte, rs *encoding.RSCodecs, isLast, padded, forceDecode, fastDecode bool) ([]byte, error) {
-	var result []byte
+// With forceDecode, chunks that fail to decode are passed through raw and the
+// result is returned together with ErrCorruptData so callers can note the damage.
+// bad is the number of RS128 chunks that could not be corrected.
+func decodeWithRSFast(data []byte, rs *encoding.RSCodecs, isLast bool, pad finalPad, forceDecode, fastDecode bool) (result []byte, bad int, err error) {
 	fullBlockEncodedSize := util.MiB / encoding.RS128DataSize * encoding.RS128EncodedSize
 
 	// Full 1 MiB block
@@ -703,14 +1122,15 @@ func decodeWithRSFast(data []byte, rs *encoding.RSCodecs, isLast, padded, forceD
 			if err != nil {
 				if forceDecode {
 					decoded = data[i : i+encoding.RS128DataSize] // Use raw data
+					bad++
 				} else {
-					return nil, perrors.ErrCorruptData
+					return nil, bad, perrors.ErrCorruptData
 				}
 			}
 
 			// Unpad last chunk if needed
-			if isLast && i == fullBlockEncodedSize-encoding.RS128EncodedSize && padded {
-				decoded = encoding.Unpad(decoded)
+			if isLast && i == fullBlockEncodedSize-encoding.RS128EncodedSize && pad.padded {
+				decoded = pad.unpad(decoded)
 			}
 
 			result = append(result, decoded...)
@@ -719,9 +1139,9 @@ func decodeWithRSFast(data []byte, rs *encoding.RSCodecs, isLast, padded, forceD
 		// Partial block - must have at least one RS128 chunk
 		if len(data) < encoding.RS128EncodedSize {
 			if forceDecode {
-				return data, nil // Return raw data for severely truncated input
+				return data, 1, perrors.ErrCorruptData // Return raw data for severely truncated input
 			}
-			return nil, perrors.ErrCorruptData
+			return nil, bad, perrors.ErrCorruptData
 		}
 
 		chunks := len(data)/encoding.RS128EncodedSize - 1
@@ -730,8 +1150,9 @@ func decodeWithRSFast(data []byte, rs *encoding.RSCodecs, isLast, padded, forceD
 			if err != nil {
 				if forceDecode {
 					decoded = data[i*encoding.RS128EncodedSize : i*encoding.RS128EncodedSize+encoding.RS128DataSize]
+					bad++
 				} else {
-					return nil, perrors.ErrCorruptData
+					return nil, bad, perrors.ErrCorruptData
 				}
 			}
 			result = append(result, decoded...)
@@ -752,12 +1173,16 @@ func decodeWithRSFast(data []byte, rs *encoding.RSCodecs, isLast, padded, forceD
 					safeEnd = len(data)
 				}
 				decoded = data[lastChunkStart:safeEnd]
+				bad++
 			} else {
-				return nil, perrors.ErrCorruptData
+				return nil, bad, perrors.ErrCorruptData
 			}
 		}
-		result = append(result, encoding.Unpad(decoded)...)
+		result = append(result, pad.unpad(decoded)...)
 	}
 
-	return result, nil
+	if bad > 0 {
+		return result, bad, perrors.ErrCorruptData
+	}
+	return result, 0, nil
 }
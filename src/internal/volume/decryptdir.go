@@ -0,0 +1,180 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/header"
+)
+
+// DecryptDirOptions controls DecryptDir.
+type DecryptDirOptions struct {
+	BatchOptions
+
+	// Overwrite replaces outputs that already exist. Without it such
+	// volumes are skipped and reported as DirSkipped.
+	Overwrite bool
+
+	// AutoUnzip extracts decrypted .zip archives next to them, as for
+	// DecryptRequest.AutoUnzip.
+	AutoUnzip bool
+
+	// NewReporter, if set, returns the reporter for one volume; the
+	// reporters must not be shared between volumes. Without it progress is
+	// not reported.
+	NewReporter func(volume string) ProgressReporter
+
+	// CredentialsFor, if set, returns the credentials for one volume in
+	// place of the shared ones, for folders whose volumes each have their
+	// own password. It may be called from several goroutines at once.
+	CredentialsFor func(volume string) Credentials
+}
+
+// DirStatus classifies the outcome of one volume of DecryptDir or RekeyDir.
+type DirStatus int
+
+const (
+	DirDecrypted  DirStatus = iota // Decrypted to OutputFile
+	DirSkipped                     // OutputFile existed and Overwrite was not set
+	DirAuthFailed                  // Wrong password or keyfiles, or a tampered header
+	DirCorrupt                     // Damaged or truncated volume
+	DirCancelled                   // Not finished when the context was cancelled
+	DirFailed                      // Any other error
+	DirRekeyed                     // Re-encrypted in place by RekeyDir
+)
+
+func (s DirStatus) String() string {
+	switch s {
+	case DirDecrypted:
+		return "decrypted"
+	case DirSkipped:
+		return "skipped"
+	case DirAuthFailed:
+		return "authentication failed"
+	case DirCorrupt:
+		return "corrupt"
+	case DirCancelled:
+		return "cancelled"
+	case DirFailed:
+		return "failed"
+	case DirRekeyed:
+		return "rekeyed"
+	default:
+		return "unknown"
+	}
+}
+
+// DirResult is the outcome of one volume of DecryptDir or RekeyDir.
+type DirResult struct {
+	Volume     fileops.VolumeRef
+	OutputFile string
+	Status     DirStatus
+	Err        error // nil for DirDecrypted, unless AutoUnzip failed and kept the .zip
+}
+
+// DecryptDir decrypts every volume fileops.FindVolumes finds under dir,
+// split and deniable ones included, with the same credentials unless
+// opts.CredentialsFor supplies them per volume. Each volume is written to
+// outDir under its DecryptOutputName, keeping its path relative to dir;
+// missing directories are created. Volumes are run as a DecryptBatch, so
+// one failing does not stop the others, and the results come back in
+// FindVolumes order. The error is only set if dir cannot be scanned.
+func DecryptDir(ctx context.Context, dir string, creds Credentials, outDir string, opts DecryptDirOptions) ([]DirResult, error) {
+	refs, err := fileops.FindVolumes(dir)
+	if err != nil {
+		return nil, err
+	}
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]DirResult, len(refs))
+	for i, ref := range refs {
+		rel, err := filepath.Rel(dir, DecryptOutputName(ref.Path, nil))
+		if err != nil {
+			return nil, err
+		}
+		results[i] = DirResult{Volume: ref, OutputFile: filepath.Join(outDir, rel)}
+	}
+
+	batchOpts := opts.BatchOptions
+	batchOpts.OnDone = func(i int, err error) {
+		results[i].Status, results[i].Err = dirStatus(err), err
+		if opts.OnDone != nil {
+			opts.OnDone(i, err)
+		}
+	}
+	runBatch(ctx, results, batchOpts, func(ctx context.Context, res DirResult) error {
+		return decryptDirVolume(ctx, res, creds, opts, rsCodecs)
+	})
+	return results, nil
+}
+
+// decryptDirVolume decrypts one volume of DecryptDir to res.OutputFile.
+func decryptDirVolume(ctx context.Context, res DirResult, creds Credentials, opts DecryptDirOptions, rsCodecs *encoding.RSCodecs) error {
+	if _, err := os.Stat(res.OutputFile); err == nil && !opts.Overwrite {
+		return perrors.NewFileError("write", res.OutputFile, perrors.ErrFileExists)
+	}
+	if err := createOutputDir(res.OutputFile); err != nil {
+		return err
+	}
+
+	var reporter ProgressReporter = nopReporter{}
+	if opts.NewReporter != nil {
+		reporter = opts.NewReporter(res.Volume.Path)
+	}
+	if opts.CredentialsFor != nil {
+		creds = opts.CredentialsFor(res.Volume.Path)
+	}
+	return Decrypt(ctx, &DecryptRequest{
+		InputFile:   res.Volume.Path,
+		OutputFile:  res.OutputFile,
+		Password:    creds.Password,
+		Keyfiles:    creds.Keyfiles,
+		Pepper:      creds.Pepper,
+		AAD:         creds.AAD,
+		MaxKeyfiles: creds.MaxKeyfiles,
+		Recombine:   res.Volume.Chunks != nil,
+		Deniability: res.Volume.Kind == fileops.VolumeDeniable,
+		AutoUnzip:   opts.AutoUnzip,
+		Reporter:    reporter,
+		RSCodecs:    rsCodecs,
+	})
+}
+
+// dirStatus classifies a DecryptDir volume error.
+func dirStatus(err error) DirStatus {
+	var authErr *header.AuthError
+	switch {
+	case err == nil:
+		return DirDecrypted
+	case errors.Is(err, perrors.ErrUnzipFailed):
+		return DirDecrypted // The .zip itself was decrypted and kept
+	case errors.Is(err, perrors.ErrFileExists):
+		return DirSkipped
+	case errors.As(err, &authErr), perrors.IsAuthFailed(err):
+		return DirAuthFailed
+	case perrors.IsCorrupt(err), errors.Is(err, perrors.ErrTruncatedVolume):
+		return DirCorrupt
+	case perrors.IsCancelled(err), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return DirCancelled
+	default:
+		return DirFailed
+	}
+}
+
+// nopReporter is the ProgressReporter of volumes run without one.
+type nopReporter struct{}
+
+func (nopReporter) SetStatus(string)            {}
+func (nopReporter) SetProgress(float32, string) {}
+func (nopReporter) SetCanCancel(bool)           {}
+func (nopReporter) Update()                     {}
+func (nopReporter) IsCancelled() bool           { return false }
+func (nopReporter) Warn(string)                 {}
@@ -0,0 +1,253 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// TestDecryptDir tests a folder of volumes with mixed options, two under
+// other credentials and one whose output already exists
+func TestDecryptDir(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping concurrent folder decryption in short mode")
+	}
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(2560 << 20))
+
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	srcDir := t.TempDir()
+	volDir := t.TempDir()
+	outDir := t.TempDir()
+	volumes := []struct {
+		name     string
+		password string
+		opts     func(req *EncryptRequest)
+	}{
+		{"plain.txt.pcv", "dir_password", nil},
+		{"nested/rs.txt.pcv", "dir_password", func(req *EncryptRequest) { req.ReedSolomon = true }},
+		{"split.txt.pcv", "dir_password", func(req *EncryptRequest) {
+			req.Split, req.ChunkSize, req.ChunkUnit = true, 3, fileops.SplitUnitTotal
+		}},
+		{"hidden.txt.pcv", "dir_password", func(req *EncryptRequest) { req.Deniability = true }},
+		{"other.txt.pcv", "other_password", nil},
+		{"nested/other.txt.pcv", "other_password", func(req *EncryptRequest) { req.ReedSolomon = true }},
+		{"existing.txt.pcv", "dir_password", nil},
+	}
+	contents := make(map[string][]byte)
+	for _, v := range volumes {
+		data := bytes.Repeat([]byte(v.name+"\n"), 500)
+		input := filepath.Join(srcDir, filepath.Base(v.name))
+		if err := os.WriteFile(input, data, 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		output := filepath.Join(volDir, filepath.FromSlash(v.name))
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			t.Fatal(err)
+		}
+		req := &EncryptRequest{
+			InputFile:  input,
+			OutputFile: output,
+			Password:   v.password,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}
+		if v.opts != nil {
+			v.opts(req)
+		}
+		if err := Encrypt(context.Background(), req); err != nil {
+			t.Fatalf("Encrypt %s failed: %v", v.name, err)
+		}
+		contents[v.name] = data
+	}
+	existing := filepath.Join(outDir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := DecryptDir(context.Background(), volDir, Credentials{Password: "dir_password"}, outDir,
+		DecryptDirOptions{BatchOptions: BatchOptions{Concurrency: 2}})
+	if err != nil {
+		t.Fatalf("DecryptDir failed: %v", err)
+	}
+	if len(results) != len(volumes) {
+		t.Fatalf("got %d results; want %d", len(results), len(volumes))
+	}
+
+	want := map[string]DirStatus{
+		"plain.txt.pcv":        DirDecrypted,
+		"nested/rs.txt.pcv":    DirDecrypted,
+		"split.txt.pcv":        DirDecrypted,
+		"hidden.txt.pcv":       DirDecrypted,
+		"other.txt.pcv":        DirAuthFailed,
+		"nested/other.txt.pcv": DirAuthFailed,
+		"existing.txt.pcv":     DirSkipped,
+	}
+	for _, res := range results {
+		rel, _ := filepath.Rel(volDir, res.Volume.Path)
+		name := filepath.ToSlash(rel)
+		if res.Status != want[name] {
+			t.Errorf("%s: status %s (%v); want %s", name, res.Status, res.Err, want[name])
+			continue
+		}
+		wantOutput := filepath.Join(outDir, filepath.FromSlash(name[:len(name)-len(".pcv")]))
+		if res.OutputFile != wantOutput {
+			t.Errorf("%s: output %s; want %s", name, res.OutputFile, wantOutput)
+		}
+		got, readErr := os.ReadFile(res.OutputFile)
+		switch res.Status {
+		case DirDecrypted:
+			if res.Err != nil || !bytes.Equal(got, contents[name]) {
+				t.Errorf("%s: decrypted content does not match (err %v)", name, res.Err)
+			}
+		case DirSkipped:
+			if !errors.Is(res.Err, perrors.ErrFileExists) || string(got) != "keep me" {
+				t.Errorf("%s: existing output not kept (err %v)", name, res.Err)
+			}
+		default:
+			if res.Err == nil || !os.IsNotExist(readErr) {
+				t.Errorf("%s: failed volume left output (err %v)", name, res.Err)
+			}
+		}
+	}
+
+	// A cancelled context runs nothing, and Overwrite replaces earlier outputs
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = DecryptDir(ctx, volDir, Credentials{Password: "dir_password"}, outDir,
+		DecryptDirOptions{Overwrite: true})
+	if err != nil {
+		t.Fatalf("DecryptDir failed: %v", err)
+	}
+	for _, res := range results {
+		if res.Status != DirCancelled {
+			t.Errorf("%s: status %s after cancellation; want cancelled", res.Volume.Path, res.Status)
+		}
+	}
+	results, err = DecryptDir(context.Background(), filepath.Join(volDir, "nested"), Credentials{Password: "dir_password"},
+		filepath.Join(outDir, "nested"), DecryptDirOptions{Overwrite: true})
+	if err != nil {
+		t.Fatalf("DecryptDir failed: %v", err)
+	}
+	if len(results) != 2 || results[1].Status != DirDecrypted {
+		t.Fatalf("nested results = %v; want rs.txt.pcv decrypted over its earlier output", results)
+	}
+}
+
+// TestDecryptDirAutoUnzipFailure tests that a volume whose archive cannot be
+// extracted still counts as decrypted, with the reason kept in Err
+func TestDecryptDirAutoUnzipFailure(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	srcDir := t.TempDir()
+	volDir := t.TempDir()
+	outDir := t.TempDir()
+
+	// A zip signature followed by garbage: not a readable archive
+	archive := append([]byte("PK\x03\x04"), bytes.Repeat([]byte("not a zip "), 100)...)
+	input := filepath.Join(srcDir, "broken.zip")
+	if err := os.WriteFile(input, archive, 0644); err != nil {
+		t.Fatalf("Failed to write malformed zip: %v", err)
+	}
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  input,
+		OutputFile: filepath.Join(volDir, "broken.zip.pcv"),
+		Password:   "dir_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	results, err := DecryptDir(context.Background(), volDir, Credentials{Password: "dir_password"}, outDir,
+		DecryptDirOptions{AutoUnzip: true})
+	if err != nil {
+		t.Fatalf("DecryptDir failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results; want 1", len(results))
+	}
+	res := results[0]
+	if res.Status != DirDecrypted {
+		t.Errorf("status %s (%v); want decrypted", res.Status, res.Err)
+	}
+	if !errors.Is(res.Err, perrors.ErrUnzipFailed) {
+		t.Errorf("Err = %v; want ErrUnzipFailed with the reason", res.Err)
+	}
+	if got, err := os.ReadFile(res.OutputFile); err != nil || !bytes.Equal(got, archive) {
+		t.Errorf("decrypted .zip was not kept intact (err %v)", err)
+	}
+}
+
+// TestDecryptDirCredentialsFor tests a folder of two volumes with different
+// passwords, each supplied by CredentialsFor
+func TestDecryptDirCredentialsFor(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	srcDir := t.TempDir()
+	volDir := t.TempDir()
+	outDir := t.TempDir()
+	passwords := make(map[string]string)
+	for _, name := range []string{"first.txt", "second.txt"} {
+		input := filepath.Join(srcDir, name)
+		if err := os.WriteFile(input, []byte("contents of "+name), 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		volume := filepath.Join(volDir, name+".pcv")
+		if err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  input,
+			OutputFile: volume,
+			Password:   "password for " + name,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}); err != nil {
+			t.Fatalf("Encrypt %s failed: %v", name, err)
+		}
+		passwords[volume] = "password for " + name
+	}
+
+	// The shared password fits neither volume
+	var mu sync.Mutex
+	var asked []string
+	results, err := DecryptDir(context.Background(), volDir, Credentials{Password: "shared password"}, outDir,
+		DecryptDirOptions{CredentialsFor: func(volume string) Credentials {
+			mu.Lock()
+			defer mu.Unlock()
+			asked = append(asked, volume)
+			return Credentials{Password: passwords[volume]}
+		}})
+	if err != nil {
+		t.Fatalf("DecryptDir failed: %v", err)
+	}
+	if len(asked) != 2 {
+		t.Errorf("CredentialsFor asked for %q; want both volumes", asked)
+	}
+	for _, res := range results {
+		name := filepath.Base(res.OutputFile)
+		if res.Status != DirDecrypted {
+			t.Errorf("%s: status %s (%v); want decrypted", name, res.Status, res.Err)
+			continue
+		}
+		if got, err := os.ReadFile(res.OutputFile); err != nil || string(got) != "contents of "+name {
+			t.Errorf("%s decrypted to %q (%v)", name, got, err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package volume
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/log"
+	"Picocrypt-NG/internal/util"
+)
+
+// encryptDeleteInputs removes the original files after a completed (and, if
+// requested, verified) encryption. Files in InputFiles are removed one by
+// one; a dropped folder is only removed once nothing but directories is left
+// in it, so a file created after the scan, and therefore not in the archive,
+// is never destroyed. Zipped inputs are only deleted once VerifyAfterEncrypt
+// has checked the archive holds them. If any target is or contains the
+// output, nothing is deleted. Failures are collected into one ErrDeleteFailed.
+func encryptDeleteInputs(ctx *OperationContext, req *EncryptRequest) error {
+	if ctx.IsCancelled() {
+		return ctx.CancellationError()
+	}
+	volumePath := req.OutputFile
+	if req.Split {
+		volumePath += ".0"
+	}
+	if _, err := os.Stat(volumePath); err != nil {
+		// Never delete the originals without a volume to show for them
+		return fmt.Errorf("%w: volume missing: %w", perrors.ErrDeleteFailed, err)
+	}
+
+	if needsZip(req) && !req.VerifyAfterEncrypt {
+		return fmt.Errorf("%w: zipped inputs are only deleted after VerifyAfterEncrypt; nothing was deleted",
+			perrors.ErrDeleteFailed)
+	}
+
+	// Refuse outright if any target is, or contains, something we produced
+	if err := checkDeleteOverlap(deleteTargets(req), producedOutputs(req)); err != nil {
+		return err
+	}
+
+	ctx.SetStatus("Deleting originals...")
+
+	var failed []string
+	if len(req.InputFiles) == 0 {
+		if err := os.Remove(req.InputFile); err != nil {
+			failed = append(failed, req.InputFile)
+		}
+	}
+	for _, f := range req.InputFiles {
+		if err := os.Remove(f); err != nil {
+			failed = append(failed, f)
+		}
+	}
+	for _, folder := range req.OnlyFolders {
+		if leftover := firstRegularFile(folder); leftover != "" {
+			log.Warn("not deleting folder with unarchived files",
+				log.String("folder", folder), log.String("file", leftover))
+			failed = append(failed, folder)
+			continue
+		}
+		if err := os.RemoveAll(folder); err != nil {
+			failed = append(failed, folder)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", perrors.ErrDeleteFailed, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// deleteTargets lists every path encryptDeleteInputs may remove.
+func deleteTargets(req *EncryptRequest) []string {
+	targets := append([]string{}, req.InputFiles...)
+	if len(req.InputFiles) == 0 {
+		targets = append(targets, req.InputFile)
+	}
+	return append(targets, req.OnlyFolders...)
+}
+
+// producedOutputs lists the volume files this encryption wrote: the output
+// and any armored copy, or each chunk of a split output.
+func producedOutputs(req *EncryptRequest) []string {
+	if !req.Split {
+		if req.Armor {
+			return []string{req.OutputFile, req.OutputFile + encoding.ArmorExt}
+		}
+		return []string{req.OutputFile}
+	}
+	var chunks []string
+	for i := 0; ; i++ {
+		chunk := req.OutputFile + "." + strconv.Itoa(i)
+		if _, err := os.Stat(chunk); err != nil {
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// checkDeleteOverlap returns ErrDeleteFailed if any target equals, or is a
+// directory above, one of the outputs, e.g. when an output template places
+// the volume inside a source folder. Nothing is deleted in that case.
+func checkDeleteOverlap(targets, outputs []string) error {
+	for _, target := range targets {
+		absTarget, err := filepath.Abs(target)
+		if err != nil {
+			return fmt.Errorf("%w: %w", perrors.ErrDeleteFailed, err)
+		}
+		for _, out := range outputs {
+			absOut, err := filepath.Abs(out)
+			if err != nil {
+				return fmt.Errorf("%w: %w", perrors.ErrDeleteFailed, err)
+			}
+			rel, err := filepath.Rel(absTarget, absOut)
+			if err != nil {
+				continue
+			}
+			if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+				log.Warn("not deleting originals that contain the output",
+					log.String("target", target), log.String("output", out))
+				return fmt.Errorf("%w: deleting %s would remove the output %s; nothing was deleted",
+					perrors.ErrDeleteFailed, target, out)
+			}
+		}
+	}
+	return nil
+}
+
+// firstRegularFile returns the path of any non-directory entry under root,
+// or "" if the tree holds only directories. Walk errors count as a file so
+// the caller errs on the side of keeping the folder.
+func firstRegularFile(root string) string {
+	var found string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil && found == "" {
+		return root
+	}
+	return found
+}
+
+// deviceOf identifies the device holding a path; replaced in tests.
+var deviceOf = util.DeviceOf
+
+// warnSameRemovableDevice warns, for DecryptRequest.WarnSameDevice, when the
+// volume to be deleted and the output share one removable device: a failure
+// mid-operation, such as the drive being pulled, could then lose both. Paths
+// whose device cannot be read are not warned about. A split volume is
+// located by its first chunk, as the base path need not exist.
+func warnSameRemovableDevice(ctx *OperationContext, req *DecryptRequest) {
+	input := req.InputFile
+	if req.Recombine {
+		input = splitVolumeBase(input) + ".0"
+	}
+	src, err := deviceOf(input)
+	if err != nil {
+		log.Debug("volume device unavailable, skipping the check", log.String("input", input), log.Err(err))
+		return
+	}
+	if !src.Removable {
+		return
+	}
+	dst, err := deviceOf(filepath.Dir(req.OutputFile))
+	if err != nil {
+		log.Debug("output device unavailable, skipping the check", log.String("output", req.OutputFile), log.Err(err))
+		return
+	}
+	if dst.ID == src.ID {
+		ctx.Warn(fmt.Sprintf("%s is decrypted to the removable drive it is deleted from; "+
+			"if the drive fails or is removed midway, both may be lost. "+
+			"Consider decrypting to a different drive", filepath.Base(req.InputFile)))
+	}
+}
+
+// decryptDeleteVolume removes the volume, or every chunk of a split volume,
+// after a fully successful decryption. A volume whose output was kept
+// despite errors (ForceDecrypt) is never deleted.
+func decryptDeleteVolume(ctx *OperationContext, req *DecryptRequest) error {
+	if ctx.Kept {
+		return nil
+	}
+	if ctx.IsCancelled() {
+		return ctx.CancellationError()
+	}
+
+	ctx.SetStatus("Deleting volume...")
+
+	var failed []string
+	if req.Recombine {
+		base := splitVolumeBase(req.InputFile)
+		for i := 0; ; i++ {
+			chunk := base + "." + strconv.Itoa(i)
+			if _, err := os.Stat(chunk); os.IsNotExist(err) {
+				break
+			}
+			if err := os.Remove(chunk); err != nil {
+				failed = append(failed, chunk)
+			}
+		}
+	} else if err := os.Remove(req.InputFile); err != nil {
+		failed = append(failed, req.InputFile)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", perrors.ErrDeleteFailed, strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,467 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/util"
+)
+
+// TestEncryptDeleteInputs tests that originals are deleted only after a
+// verified encryption
+func TestEncryptDeleteInputs(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	t.Run("verified_success", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "delete_me.txt")
+		if err := os.WriteFile(inputPath, []byte("delete after verify"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:          inputPath,
+			OutputFile:         inputPath + ".pcv",
+			Password:           "delete_password",
+			DeleteInputs:       true,
+			VerifyAfterEncrypt: true,
+			Reporter:           &GoldenTestReporter{},
+			RSCodecs:           rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if _, err := os.Stat(inputPath); !os.IsNotExist(err) {
+			t.Error("Original should be deleted after a verified encryption")
+		}
+	})
+
+	t.Run("verify_failed", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "keep_me.txt")
+		if err := os.WriteFile(inputPath, []byte("keep when verification fails"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		testHookBeforeVerify = func(path string) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Errorf("Failed to read volume in hook: %v", err)
+				return
+			}
+			data[len(data)-1] ^= 0xFF
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Errorf("Failed to write volume in hook: %v", err)
+			}
+		}
+		t.Cleanup(func() { testHookBeforeVerify = nil })
+
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:          inputPath,
+			OutputFile:         inputPath + ".pcv",
+			Password:           "delete_password",
+			DeleteInputs:       true,
+			VerifyAfterEncrypt: true,
+			Reporter:           &GoldenTestReporter{},
+			RSCodecs:           rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrPostWriteVerifyFailed) {
+			t.Fatalf("Expected ErrPostWriteVerifyFailed, got: %v", err)
+		}
+		if _, err := os.Stat(inputPath); err != nil {
+			t.Errorf("Original must survive a failed verification: %v", err)
+		}
+	})
+
+	t.Run("folder_with_unarchived_file", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		folder := filepath.Join(tmpDir, "docs")
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		scanned := filepath.Join(folder, "scanned.txt")
+		if err := os.WriteFile(scanned, []byte("in the archive"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		other := filepath.Join(folder, "second.txt")
+		if err := os.WriteFile(other, []byte("also in the archive"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		// Appears after the scan, so it is not in InputFiles or the archive
+		late := filepath.Join(folder, "late.txt")
+		testHookBeforePayload = func(string) {
+			if err := os.WriteFile(late, []byte("not in the archive"), 0644); err != nil {
+				t.Errorf("Failed to write late file: %v", err)
+			}
+		}
+		t.Cleanup(func() { testHookBeforePayload = nil })
+
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFiles:         []string{scanned, other},
+			OnlyFolders:        []string{folder},
+			OutputFile:         filepath.Join(tmpDir, "docs.zip.pcv"),
+			Password:           "delete_password",
+			DeleteInputs:       true,
+			VerifyAfterEncrypt: true,
+			Reporter:           &GoldenTestReporter{},
+			RSCodecs:           rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrDeleteFailed) {
+			t.Fatalf("Expected ErrDeleteFailed, got: %v", err)
+		}
+		if _, err := os.Stat(late); err != nil {
+			t.Errorf("Unarchived file must not be deleted: %v", err)
+		}
+		if _, err := os.Stat(scanned); !os.IsNotExist(err) {
+			t.Error("Archived file should still be deleted")
+		}
+	})
+
+	t.Run("folder_unverified", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		folder := filepath.Join(tmpDir, "docs")
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		inputPath := filepath.Join(folder, "notes.txt")
+		if err := os.WriteFile(inputPath, []byte("zipped without verification"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFiles:   []string{inputPath},
+			OnlyFolders:  []string{folder},
+			OutputFile:   filepath.Join(tmpDir, "docs.zip.pcv"),
+			Password:     "delete_password",
+			DeleteInputs: true,
+			Reporter:     &GoldenTestReporter{},
+			RSCodecs:     rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrDeleteFailed) {
+			t.Fatalf("Expected ErrDeleteFailed, got: %v", err)
+		}
+		if _, err := os.Stat(inputPath); err != nil {
+			t.Errorf("Zipped input must not be deleted unverified: %v", err)
+		}
+	})
+
+	t.Run("folder_changed_after_zipping", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		folder := filepath.Join(tmpDir, "docs")
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		inputPath := filepath.Join(folder, "draft.txt")
+		if err := os.WriteFile(inputPath, []byte("first draft"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		// The archive holds the first draft; the file on disk has moved on
+		testHookBeforeVerify = func(string) {
+			if err := os.WriteFile(inputPath, []byte("second, longer draft"), 0644); err != nil {
+				t.Errorf("Failed to rewrite input in hook: %v", err)
+			}
+		}
+		t.Cleanup(func() { testHookBeforeVerify = nil })
+
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFiles:         []string{inputPath},
+			OnlyFolders:        []string{folder},
+			OutputFile:         filepath.Join(tmpDir, "docs.zip.pcv"),
+			Password:           "delete_password",
+			DeleteInputs:       true,
+			VerifyAfterEncrypt: true,
+			Reporter:           &GoldenTestReporter{},
+			RSCodecs:           rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrPostWriteVerifyFailed) {
+			t.Fatalf("Expected ErrPostWriteVerifyFailed, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "docs/draft.txt") {
+			t.Errorf("Error should name the changed file: %v", err)
+		}
+		if _, err := os.Stat(inputPath); err != nil {
+			t.Errorf("Changed input must not be deleted: %v", err)
+		}
+	})
+
+	t.Run("output_inside_source_folder", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		folder := filepath.Join(tmpDir, "photos")
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		inputPath := filepath.Join(folder, "beach.jpg")
+		if err := os.WriteFile(inputPath, []byte("source photo"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		// As an output template like {dir}/encrypted/{name}.pcv would place it
+		outputPath := filepath.Join(folder, "encrypted", "photos.zip.pcv")
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			t.Fatalf("Failed to create output folder: %v", err)
+		}
+
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFiles:         []string{inputPath},
+			OnlyFolders:        []string{folder},
+			OutputFile:         outputPath,
+			Password:           "delete_password",
+			DeleteInputs:       true,
+			VerifyAfterEncrypt: true,
+			Reporter:           &GoldenTestReporter{},
+			RSCodecs:           rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrDeleteFailed) {
+			t.Fatalf("Expected ErrDeleteFailed, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "would remove the output") {
+			t.Errorf("Warning should name the overlap: %v", err)
+		}
+		if _, err := os.Stat(outputPath); err != nil {
+			t.Errorf("Output must survive: %v", err)
+		}
+		if _, err := os.Stat(inputPath); err != nil {
+			t.Errorf("Nothing should be deleted on overlap: %v", err)
+		}
+	})
+}
+
+// TestDecryptDeleteVolume tests that the volume is deleted only after a
+// clean decryption, never after a failed or kept one
+func TestDecryptDeleteVolume(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	newVolume := func(t *testing.T) (string, string) {
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "volume.txt")
+		if err := os.WriteFile(inputPath, []byte("volume deletion test data"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: inputPath + ".pcv",
+			Password:   "delete_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		return inputPath + ".pcv", filepath.Join(tmpDir, "decrypted.txt")
+	}
+
+	t.Run("success", func(t *testing.T) {
+		volumePath, outputPath := newVolume(t)
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:    volumePath,
+			OutputFile:   outputPath,
+			Password:     "delete_password",
+			DeleteVolume: true,
+			Reporter:     &GoldenTestReporter{},
+			RSCodecs:     rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if _, err := os.Stat(volumePath); !os.IsNotExist(err) {
+			t.Error("Volume should be deleted after a clean decryption")
+		}
+	})
+
+	t.Run("wrong_password", func(t *testing.T) {
+		volumePath, outputPath := newVolume(t)
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:    volumePath,
+			OutputFile:   outputPath,
+			Password:     "wrong_password",
+			DeleteVolume: true,
+			Reporter:     &GoldenTestReporter{},
+			RSCodecs:     rsCodecs,
+		})
+		if err == nil {
+			t.Fatal("Decrypt should fail with the wrong password")
+		}
+		if _, err := os.Stat(volumePath); err != nil {
+			t.Errorf("Volume must survive a failed decryption: %v", err)
+		}
+	})
+
+	t.Run("kept", func(t *testing.T) {
+		volumePath, outputPath := newVolume(t)
+
+		// Corrupt the payload so the MAC fails and ForceDecrypt keeps the output
+		data, err := os.ReadFile(volumePath)
+		if err != nil {
+			t.Fatalf("Failed to read volume: %v", err)
+		}
+		data[len(data)-1] ^= 0xFF
+		if err := os.WriteFile(volumePath, data, 0644); err != nil {
+			t.Fatalf("Failed to write volume: %v", err)
+		}
+
+		var kept bool
+		err = Decrypt(context.Background(), &DecryptRequest{
+			InputFile:    volumePath,
+			OutputFile:   outputPath,
+			Password:     "delete_password",
+			ForceDecrypt: true,
+			DeleteVolume: true,
+			Reporter:     &GoldenTestReporter{},
+			RSCodecs:     rsCodecs,
+			Kept:         &kept,
+		})
+		if err != nil {
+			t.Fatalf("Forced decrypt failed: %v", err)
+		}
+		if !kept {
+			t.Fatal("Expected the output to be kept despite the MAC failure")
+		}
+		if _, err := os.Stat(volumePath); err != nil {
+			t.Errorf("Volume must survive a kept decryption: %v", err)
+		}
+	})
+}
+
+// TestDecryptWarnSameDevice tests that deleting a volume from the removable
+// drive it is decrypted to is warned about, and nothing else is
+func TestDecryptWarnSameDevice(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "holiday.txt")
+	if err := os.WriteFile(inputPath, []byte("photos from the trip"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: inputPath + ".pcv",
+		Password:   "device_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	volume, err := os.ReadFile(inputPath + ".pcv")
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+	splitDir := filepath.Join(tmpDir, "split")
+	if err := os.MkdirAll(splitDir, 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: filepath.Join(splitDir, "holiday.txt.pcv"),
+		Password:   "device_password",
+		Split:      true,
+		ChunkSize:  2,
+		ChunkUnit:  fileops.SplitUnitTotal,
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt split failed: %v", err)
+	}
+	chunks, err := filepath.Glob(filepath.Join(splitDir, "holiday.txt.pcv.*"))
+	if err != nil || len(chunks) != 2 {
+		t.Fatalf("split into %d chunks (%v); want 2", len(chunks), err)
+	}
+
+	// The volume sits on the "usb" directory; the output goes to "usb" or "disk"
+	usbDir := filepath.Join(tmpDir, "usb")
+	diskDir := filepath.Join(tmpDir, "disk")
+	for _, dir := range []string{usbDir, diskDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+	}
+	var removable bool
+	defer func(orig func(string) (util.Device, error)) { deviceOf = orig }(deviceOf)
+	deviceOf = func(path string) (util.Device, error) {
+		if _, err := os.Stat(path); err != nil {
+			return util.Device{}, err
+		}
+		if strings.HasPrefix(path, usbDir) {
+			return util.Device{ID: 1, Removable: removable}, nil
+		}
+		return util.Device{ID: 2}, nil
+	}
+
+	for _, c := range []struct {
+		name      string
+		outDir    string
+		removable bool
+		split     bool
+		warn      bool
+	}{
+		{"same removable device", usbDir, true, false, true},
+		{"different device", diskDir, true, false, false},
+		{"same fixed device", usbDir, false, false, false},
+		{"split on same removable device", usbDir, true, true, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			removable = c.removable
+			volumePath := filepath.Join(usbDir, "holiday.txt.pcv")
+			if c.split {
+				for _, chunk := range chunks {
+					data, err := os.ReadFile(chunk)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(filepath.Join(usbDir, filepath.Base(chunk)), data, 0644); err != nil {
+						t.Fatalf("Failed to write chunk: %v", err)
+					}
+				}
+			} else if err := os.WriteFile(volumePath, volume, 0644); err != nil {
+				t.Fatalf("Failed to write volume: %v", err)
+			}
+			outputPath := filepath.Join(c.outDir, "holiday.txt")
+			defer func() { _ = os.Remove(outputPath) }()
+
+			reporter := &GoldenTestReporter{}
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:      volumePath,
+				OutputFile:     outputPath,
+				Password:       "device_password",
+				Recombine:      c.split,
+				DeleteVolume:   true,
+				WarnSameDevice: true,
+				Reporter:       reporter,
+				RSCodecs:       rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+
+			warned := len(reporter.warnings) == 1 && strings.Contains(reporter.warnings[0], "removable drive")
+			if warned != c.warn || len(reporter.warnings) > 1 {
+				t.Errorf("warnings = %q; want a same-device warning: %v", reporter.warnings, c.warn)
+			}
+			if _, err := os.Stat(volumePath); !os.IsNotExist(err) {
+				t.Errorf("The warning is advisory; the volume should still be deleted: %v", err)
+			}
+			if _, err := os.Stat(volumePath + ".0"); !os.IsNotExist(err) {
+				t.Errorf("The warning is advisory; the chunks should still be deleted: %v", err)
+			}
+		})
+	}
+}
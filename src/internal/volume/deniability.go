@@ -10,6 +10,7 @@ import (
 
 	"Picocrypt-NG/internal/crypto"
 	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/fileops"
 	"Picocrypt-NG/internal/util"
 
 	"golang.org/x/crypto/argon2"
@@ -38,14 +39,14 @@ func AddDeniability(volumePath, password string, reporter ProgressReporter) erro
 	tmpPath := volumePath + ".tmp"
 	incompletePath := volumePath + ".incomplete"
 
-	if err := os.Rename(volumePath, tmpPath); err != nil {
+	if err := fileops.Rename(volumePath, tmpPath); err != nil {
 		return fmt.Errorf("rename to tmp: %w", err)
 	}
 
 	// Helper to restore original file on error
 	restoreOriginal := func() {
 		_ = os.Remove(incompletePath)
-		_ = os.Rename(tmpPath, volumePath)
+		_ = fileops.Rename(tmpPath, volumePath)
 	}
 
 	fin, err := os.Open(tmpPath)
@@ -162,7 +163,7 @@ func AddDeniability(volumePath, password string, reporter ProgressReporter) erro
 		return fmt.Errorf("remove tmp failed (data saved in %s): %w", incompletePath, err)
 	}
 
-	if err := os.Rename(incompletePath, volumePath); err != nil {
+	if err := fileops.Rename(incompletePath, volumePath); err != nil {
 		return fmt.Errorf("rename output: %w", err)
 	}
 
@@ -332,7 +333,12 @@ func RemoveDeniability(volumePath, password string, reporter ProgressReporter, r
 // This is done by attempting to read and decode the version - if it fails,
 // the volume likely has a deniability wrapper.
 func IsDeniable(volumePath string, rs *encoding.RSCodecs) bool {
-	fin, err := os.Open(volumePath)
+	return isDeniable(osFS{}, volumePath, rs)
+}
+
+// isDeniable is IsDeniable for a volume read through fs.
+func isDeniable(fs FileSystem, volumePath string, rs *encoding.RSCodecs) bool {
+	fin, err := fs.Open(volumePath)
 	if err != nil {
 		return false
 	}
@@ -0,0 +1,72 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/log"
+)
+
+// syncPath opens the file or directory at path and fsyncs it; replaced in
+// tests.
+var syncPath = func(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return f.Sync()
+}
+
+// encryptSyncOutputs is the durability barrier: it fsyncs every output file
+// and then each directory holding one, so the renames that put them there
+// are on stable storage too. The result goes to req.Durable; a failure only
+// fails the operation with RequireDurable.
+func encryptSyncOutputs(ctx *OperationContext, req *EncryptRequest) error {
+	ctx.SetStatus("Syncing to disk...")
+
+	err := syncOutputs(producedOutputs(req))
+	if req.Durable != nil {
+		*req.Durable = err == nil
+	}
+	if err == nil {
+		return nil
+	}
+	log.Warn("output is not durable", log.Err(err))
+	if req.RequireDurable {
+		return fmt.Errorf("%w: %w", perrors.ErrNotDurable, err)
+	}
+	return nil
+}
+
+// syncOutputs fsyncs paths, then their directories once each. Windows
+// cannot flush a directory handle; NTFS journals the rename instead.
+func syncOutputs(paths []string) error {
+	if len(paths) == 0 {
+		return errors.New("no output to sync")
+	}
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if err := syncPath(path); err != nil {
+			return fmt.Errorf("sync %s: %w", path, err)
+		}
+		if dir := filepath.Dir(path); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	for _, dir := range dirs {
+		if err := syncPath(dir); err != nil {
+			return fmt.Errorf("sync directory %s: %w", dir, err)
+		}
+	}
+	return nil
+}
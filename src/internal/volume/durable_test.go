@@ -0,0 +1,161 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// TestEncryptDurable tests the fsync barrier: what it syncs, how Durable
+// reports it, and that RequireDurable keeps the originals when it fails
+func TestEncryptDurable(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	// injectSync records every synced path and fails those in failing
+	injectSync := func(t *testing.T, failing func(path string) bool) *[]string {
+		var synced []string
+		orig := syncPath
+		syncPath = func(path string) error {
+			synced = append(synced, path)
+			if failing(path) {
+				return errors.New("injected fsync failure")
+			}
+			return orig(path)
+		}
+		t.Cleanup(func() { syncPath = orig })
+		return &synced
+	}
+	newInput := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "source.txt")
+		if err := os.WriteFile(path, []byte("durability test data"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		return path
+	}
+
+	t.Run("synced", func(t *testing.T) {
+		synced := injectSync(t, func(string) bool { return false })
+		inputPath := newInput(t)
+		outDir := t.TempDir()
+		outputPath := filepath.Join(outDir, "out.pcv")
+
+		durable := false
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:      inputPath,
+			OutputFile:     outputPath,
+			Password:       "durable_password",
+			Split:          true,
+			ChunkSize:      2,
+			ChunkUnit:      fileops.SplitUnitTotal,
+			RequireDurable: true,
+			Durable:        &durable,
+			DeleteInputs:   true,
+			Reporter:       &GoldenTestReporter{},
+			RSCodecs:       rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if !durable {
+			t.Error("Durable should be true after a successful barrier")
+		}
+		want := []string{outputPath + ".0", outputPath + ".1"}
+		if runtime.GOOS != "windows" {
+			want = append(want, outDir)
+		}
+		if !slices.Equal(*synced, want) {
+			t.Errorf("Synced %q; want %q", *synced, want)
+		}
+		if _, err := os.Stat(inputPath); !os.IsNotExist(err) {
+			t.Error("Original should be deleted after a durable encryption")
+		}
+	})
+
+	t.Run("file_sync_fails", func(t *testing.T) {
+		injectSync(t, func(path string) bool { return filepath.Ext(path) == ".pcv" })
+		inputPath := newInput(t)
+		outputPath := filepath.Join(t.TempDir(), "out.pcv")
+
+		durable := true
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:          inputPath,
+			OutputFile:         outputPath,
+			Password:           "durable_password",
+			RequireDurable:     true,
+			Durable:            &durable,
+			DeleteInputs:       true,
+			VerifyAfterEncrypt: true,
+			Reporter:           &GoldenTestReporter{},
+			RSCodecs:           rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrNotDurable) {
+			t.Fatalf("Expected ErrNotDurable, got: %v", err)
+		}
+		if durable {
+			t.Error("Durable should be false after a failed barrier")
+		}
+		if _, err := os.Stat(inputPath); err != nil {
+			t.Errorf("Original must survive a failed fsync: %v", err)
+		}
+		if _, err := os.Stat(outputPath); err != nil {
+			t.Errorf("Volume should be kept after a failed fsync: %v", err)
+		}
+	})
+
+	t.Run("dir_sync_fails", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("directories are not synced on Windows")
+		}
+		outDir := t.TempDir()
+		injectSync(t, func(path string) bool { return path == outDir })
+		inputPath := newInput(t)
+
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:      inputPath,
+			OutputFile:     filepath.Join(outDir, "out.pcv"),
+			Password:       "durable_password",
+			RequireDurable: true,
+			DeleteInputs:   true,
+			Reporter:       &GoldenTestReporter{},
+			RSCodecs:       rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrNotDurable) {
+			t.Fatalf("Expected ErrNotDurable, got: %v", err)
+		}
+		if _, err := os.Stat(inputPath); err != nil {
+			t.Errorf("Original must survive a failed directory fsync: %v", err)
+		}
+	})
+
+	t.Run("reported_not_required", func(t *testing.T) {
+		injectSync(t, func(string) bool { return true })
+		inputPath := newInput(t)
+
+		durable := true
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: filepath.Join(t.TempDir(), "out.pcv"),
+			Password:   "durable_password",
+			Durable:    &durable,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt should succeed when durability is not required: %v", err)
+		}
+		if durable {
+			t.Error("Durable should report the failed barrier")
+		}
+	})
+}
@@ -22,11 +22,51 @@ import (
 // Encrypt performs a complete volume encryption operation.
 // This is the main entry point for encryption.
 // If ctx is nil, a background context is used.
+// When OutputName is set, the resolved volume path is stored in OutputFile.
+// With RecordSplit, one volume is written per group of records instead.
 func Encrypt(ctx context.Context, req *EncryptRequest) error {
+	r, err := req.withPassword()
+	if err != nil {
+		return err
+	}
+	// The resolved volume path still reaches the caller's request
+	defer func() { req.OutputFile = r.OutputFile }()
+	if r.RecordSplit != 0 {
+		return encryptRecords(ctx, r)
+	}
+	return encrypt(ctx, r, nil)
+}
+
+// encrypt is Encrypt for one volume. A non-nil section limits the payload
+// to that byte range of the input.
+func encrypt(ctx context.Context, req *EncryptRequest, section *inputSection) error {
+	req.OutputFile = req.OutputPath()
 	opCtx := NewEncryptContext(ctx, req)
 	defer opCtx.Close() // Secure zeroing of key material
+	opCtx.Section = section
 
 	log.Info("starting encryption", log.String("output", req.OutputFile))
+	if req.LowPriority {
+		lowerPriority()
+	}
+
+	// Refuse up front if the output would overwrite an input; the cleanup
+	// below would otherwise remove an input named like the .incomplete file
+	if err := checkEncryptSameInputOutput(req); err != nil {
+		return err
+	}
+	if err := encryptCheckFileSystem(req); err != nil {
+		return err
+	}
+
+	// With AtomicOutput all phases write under a hidden staging name, which
+	// is published in one rename per file or removed on any failure
+	if req.AtomicOutput {
+		if err := stageOutput(opCtx, req); err != nil {
+			return err
+		}
+		defer discardStaged(opCtx, req)
+	}
 
 	// Phase 1: Preprocess (zip if multiple files or compression requested)
 	if err := encryptPreprocess(opCtx, req); err != nil {
@@ -76,13 +116,152 @@ func Encrypt(ctx context.Context, req *EncryptRequest) error {
 		return err
 	}
 
+	// Phase 9 (optional): Re-open the written volume and verify it.
+	// The volume is kept on failure so the caller can inspect it.
+	if req.VerifyAfterEncrypt {
+		if err := encryptVerifyOutput(opCtx, req); err != nil {
+			return err
+		}
+	}
+
+	// Phase 10 (optional): Write the armored copy or replace the volume with it
+	if req.Armor || req.ArmorOnly {
+		if err := encryptArmor(opCtx, req); err != nil {
+			return err
+		}
+	}
+
+	// Phase 11 (optional): Reveal the finished outputs under their names
+	if req.AtomicOutput {
+		if err := publishOutputs(opCtx, req); err != nil {
+			return err
+		}
+	}
+
+	// Phase 11.5 (optional): Write the encrypted index beside the volume
+	if req.Index {
+		if err := encryptWriteIndex(opCtx, req); err != nil {
+			return err
+		}
+	}
+
+	// Phase 12 (optional): fsync the outputs so they survive a power loss
+	if req.RequireDurable || req.Durable != nil {
+		if err := encryptSyncOutputs(opCtx, req); err != nil {
+			return err
+		}
+	}
+
+	// Phase 13 (optional): Delete the originals, only after everything above
+	// succeeded. The volume is complete even if this fails.
+	if req.DeleteInputs {
+		if err := encryptDeleteInputs(opCtx, req); err != nil {
+			return err
+		}
+	}
+
 	log.Info("encryption completed successfully")
 	return nil
 }
 
-func encryptPreprocess(ctx *OperationContext, req *EncryptRequest) error {
-	// If multiple files, or single file with compression requested, create a zip
+// IsRawSingleFile reports whether the request is a single folder holding one
+// file that RawSingleFile allows to be encrypted without a zip wrapper.
+func (req *EncryptRequest) IsRawSingleFile() bool {
+	return req.RawSingleFile && !req.Compress &&
+		len(req.OnlyFolders) == 1 && len(req.OnlyFiles) == 0 && len(req.InputFiles) == 1
+}
+
+// RawSingleFileOutput returns the volume path for a raw single-file request:
+// the file's own name with .pcv appended, placed beside the dropped folder.
+func (req *EncryptRequest) RawSingleFileOutput() string {
+	return filepath.Join(filepath.Dir(req.OnlyFolders[0]), filepath.Base(req.InputFiles[0])) + ".pcv"
+}
+
+// OutputPath returns the volume path: OutputName with .zip.pcv appended
+// when the input is zipped and .pcv otherwise, or OutputFile when no
+// OutputName is set.
+func (req *EncryptRequest) OutputPath() string {
+	if req.OutputName == "" {
+		return req.OutputFile
+	}
+	if needsZip(req) {
+		return req.OutputName + ".zip.pcv"
+	}
+	return req.OutputName + ".pcv"
+}
+
+// needsZip reports whether the input must be wrapped in a zip archive:
+// multiple files, compression, or a folder (to keep its structure), unless
+// the folder qualifies for RawSingleFile.
+func needsZip(req *EncryptRequest) bool {
 	if len(req.InputFiles) > 1 || (len(req.InputFiles) == 1 && req.Compress) {
+		return true
+	}
+	return len(req.InputFiles) == 1 && len(req.OnlyFolders) > 0 && !req.IsRawSingleFile()
+}
+
+func encryptPreprocess(ctx *OperationContext, req *EncryptRequest) error {
+	// A missing or read-only destination fails before any input is looked at
+	if req.CreateOutputDirs {
+		if err := createOutputDir(req.OutputFile); err != nil {
+			return err
+		}
+	}
+	if err := checkOutputDirWritable(ctx.FS, req.OutputFile, false); err != nil {
+		return err
+	}
+	if err := checkDerivationTime(req); err != nil {
+		return err
+	}
+	if err := checkKeyfileCount(req.Keyfiles, req.MaxKeyfiles); err != nil {
+		return err
+	}
+	if err := validateArmor(req); err != nil {
+		return err
+	}
+	if err := validateCDC(req); err != nil {
+		return err
+	}
+	if err := validatePreview(req); err != nil {
+		return err
+	}
+	if err := validateComments(req); err != nil {
+		return err
+	}
+	if err := validateRecovery(req); err != nil {
+		return err
+	}
+	if err := validateSplitLayout(req); err != nil {
+		return err
+	}
+	if err := validateTrailer(req); err != nil {
+		return err
+	}
+	if err := validateIndex(req); err != nil {
+		return err
+	}
+	if err := validateCompression(req); err != nil {
+		return err
+	}
+	if err := validatePasswordScore(req); err != nil {
+		return err
+	}
+	if err := checkKeyfilesInInput(req); err != nil {
+		return err
+	}
+
+	// Refuse special files before anything opens them; callers may skip Validate
+	if err := checkRegularFiles(ctx.FS, req.InputFiles); err != nil {
+		return err
+	}
+	if len(req.InputFiles) == 0 && req.InputFile != "" {
+		if err := checkRegularFile(ctx.FS, req.InputFile); err != nil {
+			return err
+		}
+	}
+
+	// If multiple files, a folder, or compression requested, create a zip
+	if needsZip(req) {
 		ctx.SetStatus("Compressing files...")
 
 		// Create temp zip ciphers for encrypting the temporary file
@@ -92,37 +271,49 @@ func encryptPreprocess(ctx *OperationContext, req *EncryptRequest) error {
 			return err
 		}
 
-		// Determine root directory (matches original lines 1227-1233)
-		// If folders were dropped, use parent of folder to preserve folder name in zip
-		// If only files were dropped, use parent of first file
-		var rootDir string
-		if len(req.OnlyFolders) > 0 {
-			rootDir = filepath.Dir(req.OnlyFolders[0])
-		} else if len(req.OnlyFiles) > 0 {
-			rootDir = filepath.Dir(req.OnlyFiles[0])
-		} else if len(req.InputFiles) > 0 {
-			rootDir = filepath.Dir(req.InputFiles[0])
+		// Derive the key sealing the real entry names
+		var nameKey, nameSalt []byte
+		if req.EncryptNames {
+			if nameSalt, err = crypto.RandomBytes(fileops.NameSaltSize); err != nil {
+				return err
+			}
+			if nameKey, err = encryptNameKey(ctx, req, nameSalt); err != nil {
+				return err
+			}
+			defer crypto.SecureZero(nameKey)
+			ctx.SetStatus("Compressing files...")
 		}
 
 		// Create the zip
 		ctx.TempFile = strings.TrimSuffix(req.OutputFile, ".pcv") + ".tmp"
 		err = fileops.CreateZip(fileops.ZipOptions{
-			Files:      req.InputFiles,
-			RootDir:    rootDir,
-			OutputPath: ctx.TempFile,
-			Compress:   req.Compress,
-			Cipher:     ctx.TempCiphers,
+			Files:           req.InputFiles,
+			RootDir:         zipRootDir(req),
+			OutputPath:      ctx.TempFile,
+			Compress:        req.Compress,
+			Method:          req.CompressionMethod,
+			DirEntries:      req.PreserveDirs,
+			SkipHighEntropy: req.SkipIncompressible,
+			Workers:         req.ZipWorkers,
+			Cipher:          ctx.TempCiphers,
+			NameKey:         nameKey,
+			NameSalt:        nameSalt,
 			Progress: func(p float32, info string) {
 				ctx.UpdateProgress(p, info)
 			},
 			Status: func(s string) {
 				ctx.SetStatus(s)
 			},
+			Warn: ctx.Warn,
 			Cancel: func() bool {
 				return ctx.IsCancelled()
 			},
 		})
 		if err != nil {
+			// Report context cancellation as ctx.Err() rather than the generic sentinel
+			if perrors.IsCancelled(err) {
+				return ctx.CancellationError()
+			}
 			return err
 		}
 
@@ -137,9 +328,56 @@ func encryptPreprocess(ctx *OperationContext, req *EncryptRequest) error {
 	return nil
 }
 
-func encryptGenerateValues(ctx *OperationContext, req *EncryptRequest) error {
-	ctx.SetStatus("Generating values...")
+// zipRootDir returns the directory archive paths are relative to (matches
+// original lines 1227-1233). If folders were dropped, the parent of the first
+// keeps its name in the zip; if only files were dropped, the parent of the
+// first file.
+func zipRootDir(req *EncryptRequest) string {
+	switch {
+	case len(req.OnlyFolders) > 0:
+		return filepath.Dir(req.OnlyFolders[0])
+	case len(req.OnlyFiles) > 0:
+		return filepath.Dir(req.OnlyFiles[0])
+	case len(req.InputFiles) > 0:
+		return filepath.Dir(req.InputFiles[0])
+	}
+	return ""
+}
+
+// headerComments returns the comments to store in the header: the user's,
+// followed by the original name and keyfile name hints if requested.
+func headerComments(req *EncryptRequest, original string) (string, error) {
+	if req.keptComments != "" {
+		return req.keptComments, nil
+	}
+	comments := req.Comments
+	var err error
+	if req.StoreOriginalName {
+		comments, err = header.EncodeOriginalName(comments, original)
+		if err != nil {
+			return "", perrors.NewValidationError("StoreOriginalName", err.Error())
+		}
+	}
+	if req.StoreKeyfileNames {
+		if err := validateKeyfileNames(req); err != nil {
+			return "", err
+		}
+		names := make([]string, len(req.Keyfiles))
+		for i, kf := range req.Keyfiles {
+			names[i] = filepath.Base(kf)
+		}
+		comments, err = header.EncodeKeyfileNames(comments, names)
+		if err != nil {
+			return "", perrors.NewValidationError("Keyfiles", err.Error())
+		}
+	}
+	return comments, nil
+}
 
+// encryptGenerateSalts generates the salts, Serpent IV and nonce and settles
+// the Argon2 cost, which is all encryptDeriveKeys needs. The rest of the
+// header is filled in by encryptGenerateValues once the input is final.
+func encryptGenerateSalts(ctx *OperationContext, req *EncryptRequest) error {
 	// Generate random cryptographic values
 	salt, err := crypto.RandomBytes(header.SaltSize)
 	if err != nil {
@@ -160,56 +398,235 @@ func encryptGenerateValues(ctx *OperationContext, req *EncryptRequest) error {
 	if err != nil {
 		return err
 	}
+	if req.testValues != nil {
+		salt, hkdfSalt, serpentIV, nonce = req.testValues()
+	}
+
+	threads, err := argon2Threads(req)
+	if err != nil {
+		return err
+	}
+
+	ctx.Header = header.NewVolumeHeader(salt, hkdfSalt, serpentIV, nonce)
+	ctx.Header.Flags.Paranoid = req.Paranoid
+	ctx.Header.Flags.Threads = threads
+	ctx.Header.Flags.Passes = argon2Passes(ctx, req, threads)
+	return nil
+}
+
+func encryptGenerateValues(ctx *OperationContext, req *EncryptRequest) error {
+	ctx.SetStatus("Generating values...")
+
+	// EncryptNames needs the volume key, and so the salts, before the archive
+	if ctx.Header == nil {
+		if err := encryptGenerateSalts(ctx, req); err != nil {
+			return err
+		}
+	}
 
 	// Get input file size for padded flag
-	stat, err := os.Stat(ctx.InputFile)
+	stat, err := ctx.FS.Stat(ctx.InputFile)
 	if err != nil {
 		return fmt.Errorf("stat input: %w", err)
 	}
 	ctx.Total = stat.Size()
+	if ctx.Section != nil {
+		ctx.Total = ctx.Section.length
+	}
 
 	// Determine if padding is needed (RS internals)
 	// Padding is required when the last partial block would leave fewer than RS128DataSize
 	// bytes after RS128 encoding chunks are filled.
 	ctx.Padded = ctx.Total%int64(util.MiB) >= int64(util.MiB)-encoding.RS128DataSize
 
-	// Create header
-	ctx.Header = header.NewVolumeHeader(salt, hkdfSalt, serpentIV, nonce)
-	ctx.Header.Comments = req.Comments
+	comments, err := headerComments(req, originalName(ctx, req))
+	if err != nil {
+		return err
+	}
+
+	// Complete the header, keeping the Argon2 cost the key is derived with
+	cost := ctx.Header.Flags
+	ctx.Header.Comments = comments
 	ctx.Header.Flags = header.Flags{
 		Paranoid:       req.Paranoid,
 		UseKeyfiles:    len(req.Keyfiles) > 0,
 		KeyfileOrdered: req.KeyfileOrdered,
 		ReedSolomon:    req.ReedSolomon,
 		Padded:         ctx.Padded,
+		Pepper:         len(req.Pepper) > 0,
+		Threads:        cost.Threads,
+		Passes:         cost.Passes,
+		BlockHashes:    req.BlockHashes,
+		KeyfileBLAKE2b: len(req.Keyfiles) > 0 && req.KeyfileHash == keyfile.HashBLAKE2b,
+		CDCDedup:       req.CDCDedup,
+		Preview:        len(req.PreviewData) > 0,
+		Recovery:       len(req.RecoveryRecipient) > 0,
+		SplitLayout:    req.StoreSplitLayout,
+		Trailer:        req.Trailer,
+	}
+	if req.ExplicitPadding && req.ReedSolomon {
+		ctx.Header.Flags.ExplicitPadding = true
+		ctx.Header.Flags.PadLen = finalPadLen(ctx.Total)
+		ctx.Header.Flags.Padded = false
+	}
+	if req.BlockHashes {
+		ctx.BlockTable = header.NewBlockTable((ctx.Total + int64(util.MiB) - 1) / int64(util.MiB))
+	}
+	if ctx.Header.Flags.Preview {
+		// Sealed once the keys exist; only the size is needed until then
+		ctx.Preview = make([]byte, len(req.PreviewData)+header.PreviewOverhead)
 	}
 
 	return nil
 }
 
+// lowerPriority applies LowPriority. Failing to lower the priority does not
+// affect the result, so it is only logged.
+func lowerPriority() {
+	if err := util.LowerPriority(); err != nil {
+		log.Warn("could not lower process priority", log.Err(err))
+	}
+}
+
+// effectiveCPUs reports the usable CPU count; replaced in tests.
+var effectiveCPUs = util.EffectiveCPUs
+
+// estimateDerivation estimates key derivation time; replaced in tests.
+var estimateDerivation = crypto.CalibrateDeriveKey
+
+// checkDerivationTime applies MaxDerivationTime: it returns an advisory
+// ErrDerivationTooSlow if Paranoid key derivation is estimated to exceed it.
+func checkDerivationTime(req *EncryptRequest) error {
+	if req.MaxDerivationTime > 0 && req.TargetDerivationTime > req.MaxDerivationTime {
+		return perrors.NewValidationError("TargetDerivationTime", "exceeds MaxDerivationTime")
+	}
+	if !req.Paranoid || req.MaxDerivationTime <= 0 {
+		return nil
+	}
+	threads, err := argon2Threads(req)
+	if err != nil {
+		return err
+	}
+	estimate := estimateDerivation(true, threads)
+	if estimate <= req.MaxDerivationTime {
+		return nil
+	}
+	return fmt.Errorf("%w: paranoid mode is estimated at %s on this machine (limit %s); "+
+		"normal mode uses half the Argon2 passes", perrors.ErrDerivationTooSlow,
+		estimate.Round(time.Second), req.MaxDerivationTime)
+}
+
+// calibratePasses picks the pass count for TargetDerivationTime; replaced
+// in tests.
+var calibratePasses = crypto.CalibratePasses
+
+// argon2Passes returns the Argon2 pass count to record in the header: 0 for
+// the mode default, the count calibrated to TargetDerivationTime, or the
+// count kept by Rekey.
+func argon2Passes(ctx *OperationContext, req *EncryptRequest, threads uint8) uint8 {
+	if req.TargetDerivationTime <= 0 {
+		return req.keepPasses
+	}
+	ctx.SetStatus("Calibrating key derivation...")
+	passes := calibratePasses(req.Paranoid, threads, req.TargetDerivationTime)
+	if passes > header.MaxPasses {
+		ctx.Warn(fmt.Sprintf("a derivation time of %s needs %d Argon2 passes; using the maximum of %d",
+			req.TargetDerivationTime, passes, header.MaxPasses))
+		passes = header.MaxPasses
+	}
+	if passes == int(crypto.Argon2Passes(req.Paranoid)) {
+		return 0
+	}
+	return uint8(passes)
+}
+
+// Argon2ThreadsAuto, as EncryptRequest.Argon2Threads, uses the mode default
+// clamped to the CPUs actually available.
+const Argon2ThreadsAuto = -1
+
+// argon2Threads returns the Argon2 thread count to record in the header:
+// 0 for the mode default, or the explicit or CPU-clamped count otherwise.
+// The default is never clamped on its own, so volumes stay readable by
+// versions that do not know the thread count field.
+func argon2Threads(req *EncryptRequest) (uint8, error) {
+	if req.Argon2Threads < Argon2ThreadsAuto || req.Argon2Threads > header.MaxThreads {
+		return 0, perrors.NewValidationError("Argon2Threads",
+			fmt.Sprintf("must be between 1 and %d, or Argon2ThreadsAuto", header.MaxThreads))
+	}
+
+	def := int(crypto.Argon2Threads(req.Paranoid))
+	threads := req.Argon2Threads
+	switch threads {
+	case 0:
+		threads = def
+	case Argon2ThreadsAuto:
+		threads = min(def, max(effectiveCPUs(), 1))
+	}
+	if threads == def {
+		return 0, nil
+	}
+	return uint8(threads), nil
+}
+
 func encryptWriteHeader(ctx *OperationContext, req *EncryptRequest) error {
 	// Create output file
-	fout, err := os.Create(req.OutputFile + ".incomplete")
+	fout, err := ctx.FS.Create(req.OutputFile + ".incomplete")
 	if err != nil {
 		return fmt.Errorf("create output: %w", err)
 	}
 
-	// Write header
-	w := header.NewWriter(fout, req.RSCodecs)
-	if _, err := w.WriteHeader(ctx.Header); err != nil {
+	// Write header, keeping where its auth values go for encryptFinalize
+	_, ctx.HeaderOffsets, err = header.Write(fout, ctx.Header, req.RSCodecs)
+	if err != nil {
 		_ = fout.Close()
-		_ = os.Remove(fout.Name())
+		_ = ctx.FS.Remove(fout.Name())
 		return fmt.Errorf("write header: %w", err)
 	}
 
+	// Reserve the full volume size up front; encryptPayload trims any excess
+	if req.Preallocate {
+		size := ctx.PayloadOffset() + encryptedPayloadSize(ctx.Total, req.ReedSolomon)
+		if err := fileops.Preallocate(fout, size); err != nil {
+			_ = fout.Close()
+			_ = ctx.FS.Remove(fout.Name())
+			return fmt.Errorf("preallocate output: %w", err)
+		}
+	}
+
 	_ = fout.Close()
 	return nil
 }
 
+// encryptedPayloadSize returns the payload size on disk for size bytes of
+// plaintext, mirroring encodeWithRS: every full 1 MiB block grows from 128
+// to 136 bytes per chunk, and a final partial block gets one extra padded
+// chunk.
+func encryptedPayloadSize(size int64, reedSolomon bool) int64 {
+	if !reedSolomon {
+		return size
+	}
+	fullBlocks := size / int64(util.MiB)
+	blockSize := int64(util.MiB / encoding.RS128DataSize * encoding.RS128EncodedSize)
+	total := fullBlocks * blockSize
+	if rem := size % int64(util.MiB); rem > 0 {
+		total += (rem/encoding.RS128DataSize + 1) * encoding.RS128EncodedSize
+	}
+	return total
+}
+
 func encryptDeriveKeys(ctx *OperationContext, req *EncryptRequest) error {
+	if ctx.Key != nil {
+		return nil // Derived before the archive by encryptNameKey
+	}
+	// Argon2 can't be interrupted, so don't start it for a dead context
+	if ctx.IsCancelled() {
+		return ctx.CancellationError()
+	}
 	ctx.SetStatus("Deriving key...")
 
-	key, err := crypto.DeriveKey([]byte(req.Password), ctx.Header.Salt, req.Paranoid)
+	password := crypto.PepperPassword([]byte(req.Password), req.Pepper)
+	defer crypto.SecureZero(password)
+	key, err := crypto.DeriveKeyCost(password, ctx.Header.Salt, req.Paranoid, ctx.Header.Flags.Threads, ctx.Header.Flags.Passes)
 	if err != nil {
 		return err
 	}
@@ -219,6 +636,9 @@ func encryptDeriveKeys(ctx *OperationContext, req *EncryptRequest) error {
 }
 
 func encryptProcessKeyfiles(ctx *OperationContext, req *EncryptRequest) error {
+	if ctx.KeyfileHash != nil {
+		return nil // Processed before the archive by encryptNameKey
+	}
 	if len(req.Keyfiles) == 0 {
 		ctx.KeyfileHash = make([]byte, 32)
 		return nil
@@ -227,7 +647,7 @@ func encryptProcessKeyfiles(ctx *OperationContext, req *EncryptRequest) error {
 	ctx.SetStatus("Reading keyfiles...")
 	ctx.UseKeyfiles = true
 
-	result, err := keyfile.Process(req.Keyfiles, req.KeyfileOrdered, func(p float32) {
+	result, err := keyfile.ProcessWith(req.Keyfiles, req.KeyfileOrdered, req.KeyfileHash, func(p float32) {
 		ctx.UpdateProgress(p, "")
 	})
 	if err != nil {
@@ -253,14 +673,30 @@ func encryptComputeAuth(ctx *OperationContext, req *EncryptRequest) error {
 		return err
 	}
 
-	// Compute header MAC
-	ctx.Header.KeyHash = header.ComputeV2HeaderMAC(subkeyHeader, ctx.Header, ctx.KeyfileHash)
+	// The MAC covers the wrapped keys, so wrap them first
+	if ctx.Header.Flags.Recovery {
+		ctx.Header.RecoveryWrap, err = wrapRecoveryKeys(req.RecoveryRecipient, ctx.Key, ctx.KeyfileKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Compute header MAC. With block hashes, a split layout or a trailer it
+	// also covers the block table, the final volume size or the payload
+	// size, so it is computed in encryptFinalize once the payload is written.
+	if ctx.Header.Flags.BlockHashes || ctx.Header.Flags.SplitLayout || ctx.Header.Flags.Trailer {
+		ctx.HeaderSubkey = subkeyHeader
+	} else {
+		ctx.Header.KeyHash = header.ComputeV2HeaderMAC(subkeyHeader, ctx.Header, ctx.KeyfileHash, req.AAD)
+	}
 	ctx.Header.KeyfileHash = ctx.KeyfileHash
 
 	return nil
 }
 
-func encryptPayload(ctx *OperationContext, req *EncryptRequest) error {
+// encryptInitCipher applies the keyfile key, reads the remaining subkeys and
+// creates the payload cipher suite. It must run after encryptComputeAuth.
+func encryptInitCipher(ctx *OperationContext, req *EncryptRequest) error {
 	// Apply keyfile XOR to key (AFTER HKDF init for v2)
 	key := ctx.Key
 	if ctx.UseKeyfiles && ctx.KeyfileKey != nil {
@@ -300,32 +736,90 @@ func encryptPayload(ctx *OperationContext, req *EncryptRequest) error {
 	if err != nil {
 		return err
 	}
+	cipherSuite.SetWorkers(req.CipherWorkers)
 	ctx.CipherSuite = cipherSuite
 
+	return nil
+}
+
+// checkInputRead fails with ErrInputChangedDuringRead if the payload read
+// to EOF was not the size the input had when encryption started. Explicit
+// padding always checks, as the stored pad length is only right for that size.
+func checkInputRead(ctx *OperationContext, req *EncryptRequest, done int64) error {
+	if (req.AbortOnInputChange || ctx.Header.Flags.ExplicitPadding) && done != ctx.Total {
+		return fmt.Errorf("%w: read %d of %d bytes", perrors.ErrInputChangedDuringRead, done, ctx.Total)
+	}
+	return nil
+}
+
+// testHookBeforePayload, if set, is called with the .incomplete path once the
+// output is open and positioned for the payload, before anything is written.
+var testHookBeforePayload func(outputFile string)
+
+func encryptPayload(ctx *OperationContext, req *EncryptRequest) error {
+	if err := encryptInitCipher(ctx, req); err != nil {
+		return err
+	}
+
 	// Open files
-	fin, err := os.Open(ctx.InputFile)
+	fin, err := ctx.FS.Open(ctx.InputFile)
 	if err != nil {
 		return fmt.Errorf("open input: %w", err)
 	}
 	defer func() { _ = fin.Close() }()
 
-	fout, err := os.OpenFile(req.OutputFile+".incomplete", os.O_WRONLY|os.O_APPEND, 0644)
+	fout, err := ctx.FS.OpenFile(req.OutputFile+".incomplete", os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("open output: %w", err)
 	}
 	defer func() { _ = fout.Close() }()
 
+	if ctx.Header.Flags.Recovery {
+		if err := header.WriteRecovery(fout, ctx.RecoveryOffset(), ctx.Header.RecoveryWrap, req.RSCodecs); err != nil {
+			return err
+		}
+	}
+	if ctx.Header.Flags.Preview {
+		if err := encryptWritePreview(ctx, req, fout); err != nil {
+			return err
+		}
+	}
+
+	// Write positionally after the header and the sections that follow
+	// it; the file may already be preallocated
+	if _, err := fout.Seek(ctx.PayloadOffset(), io.SeekStart); err != nil {
+		return fmt.Errorf("seek past header: %w", err)
+	}
+
+	if testHookBeforePayload != nil {
+		testHookBeforePayload(req.OutputFile + ".incomplete")
+	}
+
 	// Wrap with temp zip cipher if needed
 	var reader io.Reader = fin
 	if ctx.TempZipInUse && ctx.TempCiphers != nil {
 		reader = fileops.WrapReaderWithCipher(fin, ctx.TempCiphers)
 	}
 
+	// A record volume reads only its own records
+	if ctx.Section != nil {
+		if _, err := fin.Seek(ctx.Section.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("seek input: %w", err)
+		}
+		reader = io.LimitReader(fin, ctx.Section.length)
+	}
+
+	// Chunk records replace the streamed cipher
+	if req.CDCDedup {
+		return encryptCDCPayload(ctx, req, reader, fout)
+	}
+
 	// Encrypt loop
 	ctx.Reporter.SetCanCancel(true)
 	startTime := time.Now()
 	var done int64
 	var counter int64
+	var block int
 
 	// Get buffers from pool to reduce GC pressure
 	src := util.GetMiBBuffer()
@@ -358,12 +852,20 @@ func encryptPayload(ctx *OperationContext, req *EncryptRequest) error {
 				return fmt.Errorf("write ciphertext: %w", err)
 			}
 
+			if ctx.BlockTable != nil {
+				if block >= len(ctx.BlockTable.Hashes) {
+					return fmt.Errorf("%w: more than %d blocks", perrors.ErrInputChangedDuringRead, len(ctx.BlockTable.Hashes))
+				}
+				ctx.BlockTable.Hashes[block] = header.HashBlock(writeData)
+				block++
+			}
+
 			done += int64(n)
 			counter += int64(n)
 
 			progress, speed, eta := util.Statify(done, ctx.Total, startTime)
 			ctx.UpdateProgress(progress, fmt.Sprintf("%.2f%%", progress*100))
-			ctx.SetStatus(fmt.Sprintf("Encrypting at %.2f MiB/s (ETA: %s)", speed, eta))
+			ctx.SetRate("Encrypting", speed, eta)
 
 			// Rekey every 60 GiB
 			if counter >= crypto.RekeyThreshold {
@@ -382,6 +884,21 @@ func encryptPayload(ctx *OperationContext, req *EncryptRequest) error {
 		}
 	}
 
+	if err := checkInputRead(ctx, req, done); err != nil {
+		return err
+	}
+
+	// Drop any preallocated space the payload did not use
+	if req.Preallocate {
+		end, err := fout.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("seek output: %w", err)
+		}
+		if err := fout.Truncate(end); err != nil {
+			return fmt.Errorf("trim output: %w", err)
+		}
+	}
+
 	// Sync to ensure all encrypted data is written before finalize
 	if err := fout.Sync(); err != nil {
 		return fmt.Errorf("sync output: %w", err)
@@ -394,17 +911,50 @@ func encryptFinalize(ctx *OperationContext, req *EncryptRequest) error {
 	ctx.SetStatus("Writing values...")
 
 	// Open output file for seeking
-	fout, err := os.OpenFile(req.OutputFile+".incomplete", os.O_RDWR, 0644)
+	fout, err := ctx.FS.OpenFile(req.OutputFile+".incomplete", os.O_RDWR, 0644)
 	if err != nil {
 		return fmt.Errorf("open output for auth: %w", err)
 	}
 	defer func() { _ = fout.Close() }()
 
+	// Write the block table, the trailer, the split layout and the header
+	// MAC that covers them. The trailer goes first, as the layout records
+	// the size of the volume that ends with it.
+	if ctx.BlockTable != nil {
+		ctx.Header.BlockTableDigest = ctx.BlockTable.Digest()
+		if err := header.WriteBlockTable(fout, ctx.HeaderOffsets.End, ctx.BlockTable, req.RSCodecs); err != nil {
+			return err
+		}
+	}
+	if ctx.Header.Flags.Trailer {
+		end, err := fout.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("seek output: %w", err)
+		}
+		ctx.Header.Trailer = volumeTrailer(ctx, end)
+		if err := header.WriteTrailer(fout, end, ctx.Header.Trailer, req.RSCodecs); err != nil {
+			return err
+		}
+	}
+	if ctx.Header.Flags.SplitLayout {
+		size, err := fout.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("seek output: %w", err)
+		}
+		chunkSize, chunks := fileops.ChunkLayout(size, req.ChunkSize, req.ChunkUnit)
+		ctx.Header.SplitLayout = &header.SplitLayout{ChunkSize: chunkSize, Chunks: int64(chunks), VolumeSize: size}
+		if err := header.WriteSplitLayout(fout, ctx.SplitLayoutOffset(), ctx.Header.SplitLayout, req.RSCodecs); err != nil {
+			return err
+		}
+	}
+	if ctx.HeaderSubkey != nil {
+		ctx.Header.KeyHash = header.ComputeV2HeaderMAC(ctx.HeaderSubkey, ctx.Header, ctx.Header.KeyfileHash, req.AAD)
+	}
+
 	// Write auth values
-	offset := header.AuthValuesOffset(len(ctx.Header.Comments))
 	err = header.WriteAuthValues(
 		fout,
-		offset,
+		ctx.HeaderOffsets.KeyHash,
 		ctx.Header.KeyHash,
 		ctx.Header.KeyfileHash,
 		ctx.CipherSuite.Sum(),
@@ -421,7 +971,7 @@ func encryptFinalize(ctx *OperationContext, req *EncryptRequest) error {
 	_ = fout.Close()
 
 	// Rename to final name
-	if err := os.Rename(req.OutputFile+".incomplete", req.OutputFile); err != nil {
+	if err := ctx.FS.Rename(req.OutputFile+".incomplete", req.OutputFile); err != nil {
 		return fmt.Errorf("rename output: %w", err)
 	}
 
@@ -434,6 +984,9 @@ func encryptFinalize(ctx *OperationContext, req *EncryptRequest) error {
 
 	// Split if requested
 	if req.Split {
+		if stale, _ := fileops.StaleChunks(req.OutputFile); len(stale) > 0 {
+			log.Warn("removing chunks left by an interrupted split", log.Int("count", len(stale)))
+		}
 		ctx.SetStatus("Splitting...")
 		_, err := fileops.Split(fileops.SplitOptions{
 			InputPath: req.OutputFile,
@@ -449,6 +1002,11 @@ func encryptFinalize(ctx *OperationContext, req *EncryptRequest) error {
 				return ctx.IsCancelled()
 			},
 		})
+		if perrors.IsCancelled(err) {
+			// Split removed its chunks; the unsplit volume goes too
+			_ = os.Remove(req.OutputFile)
+			return ctx.CancellationError()
+		}
 		if err != nil {
 			return err
 		}
@@ -469,10 +1027,21 @@ func cleanupEncrypt(ctx *OperationContext, req *EncryptRequest) {
 	if ctx.TempFile != "" {
 		_ = os.Remove(ctx.TempFile)
 	}
-	_ = os.Remove(req.OutputFile + ".incomplete")
+	_ = ctx.FS.Remove(req.OutputFile + ".incomplete")
 	// Note: ctx.Close() is called via defer in Encrypt()
 }
 
+// finalPadLen returns how many bytes encodeWithRS pads the last chunk of a
+// total-byte payload with: a partial final block always gets a padded
+// chunk, a full one none.
+func finalPadLen(total int64) uint8 {
+	rem := total % int64(util.MiB)
+	if rem == 0 {
+		return 0
+	}
+	return uint8(encoding.RS128DataSize - rem%encoding.RS128DataSize)
+}
+
 // encodeWithRS encodes data with Reed-Solomon (rs128)
 // For partial blocks (< 1 MiB), this ALWAYS adds a padding chunk, even if data
 // is exactly divisible by 128, because the original Picocrypt always unpads
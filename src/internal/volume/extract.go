@@ -0,0 +1,124 @@
+package volume
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/log"
+)
+
+// ExtractFile decrypts a zip volume and writes a single entry to out.
+//
+// The whole volume is still decrypted and its MAC verified before anything
+// is written to out, so a damaged or tampered volume never yields partial
+// output. The plaintext never reaches the disk: the decrypted archive is
+// re-encrypted under an ephemeral in-memory key into a private temporary
+// file, which the zip reader decrypts on demand and which is removed
+// before returning, on success or failure.
+//
+// entryName is matched against the archive path as stored by Encrypt, with
+// either slash accepted as separator (e.g. "folder/file.txt"). Returns
+// ErrFileNotFound if there is no such file entry. req.OutputFile, AutoUnzip,
+// SameLevel, ForceDecrypt and DeleteVolume are ignored.
+func ExtractFile(ctx context.Context, req *DecryptRequest, entryName string, out io.Writer) error {
+	reader, realNames, done, err := decryptArchive(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	log.Info("extracting entry", log.String("entry", entryName))
+
+	want := strings.ReplaceAll(entryName, "\\", "/")
+	for _, f := range reader.File {
+		name := f.Name
+		if realNames != nil {
+			name = realNames[f]
+		}
+		if f.FileInfo().IsDir() || name == "" || strings.ReplaceAll(name, "\\", "/") != want {
+			continue
+		}
+
+		if req.Reporter != nil {
+			req.Reporter.SetStatus(fmt.Sprintf("Extracting %s...", entryName))
+		}
+		rc, err := fileops.OpenEntry(f)
+		if err != nil {
+			return fmt.Errorf("open zip entry %s: %w", f.Name, err)
+		}
+		_, err = io.Copy(out, rc)
+		_ = rc.Close()
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", f.Name, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s not in volume", perrors.ErrFileNotFound, entryName)
+}
+
+// decryptArchive decrypts a zip volume into a private temporary file,
+// re-encrypted under an ephemeral in-memory key, and opens it. realNames maps
+// each entry to its real name if the archive was created with EncryptNames
+// and is nil otherwise. done closes and removes the temporary file; it must
+// be called once the reader is no longer used, and only if err is nil.
+func decryptArchive(ctx context.Context, req *DecryptRequest) (reader *zip.Reader, realNames map[*zip.File]string, done func(), err error) {
+	ciphers, err := fileops.NewTempZipCiphers()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tmp, err := os.CreateTemp("", "picocrypt-extract-*.tmp")
+	if err != nil {
+		ciphers.Close()
+		return nil, nil, nil, fmt.Errorf("create temp file: %w", err)
+	}
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		ciphers.Close()
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	dreq := *req
+	dreq.OutputFile = ""
+	dreq.AutoUnzip = false
+	dreq.SameLevel = false
+	dreq.ForceDecrypt = false
+	dreq.Kept = nil
+	dreq.DeleteVolume = false
+	dreq.DiscardOutput = false
+	dreq.MmapOutput = false
+	dreq.Output = fileops.WrapWriterWithCipher(tmp, ciphers)
+
+	// Keep the context open past decryption: the name key of an archive
+	// created with EncryptNames comes from the volume key
+	opCtx := NewDecryptContext(ctx, &dreq)
+	defer opCtx.Close() // Secure zeroing of key material
+	if err = decrypt(opCtx, &dreq); err != nil {
+		return nil, nil, nil, err
+	}
+
+	stat, err := tmp.Stat()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("stat temp file: %w", err)
+	}
+	reader, err = fileops.NewZipReader(fileops.WrapReaderAtWithCipher(tmp, ciphers), stat.Size())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open zip: %w", err)
+	}
+	realNames, err = fileops.RealNames(reader.File, nameKeyFunc(opCtx.Key))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return reader, realNames, cleanup, nil
+}
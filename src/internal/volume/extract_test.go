@@ -0,0 +1,104 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// TestExtractFile tests extracting single entries by name from a three-file volume
+func TestExtractFile(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	folder := filepath.Join(tmpDir, "docs")
+	if err := os.MkdirAll(filepath.Join(folder, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	contents := map[string][]byte{
+		"docs/a.txt":     []byte("first file"),
+		"docs/b.txt":     bytes.Repeat([]byte("second file "), 1000),
+		"docs/sub/c.bin": {0x00, 0x01, 0x02, 0xFF},
+	}
+	var inputFiles []string
+	for name, data := range contents {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		inputFiles = append(inputFiles, path)
+	}
+
+	volumePath := filepath.Join(tmpDir, "docs.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles:  inputFiles,
+		OnlyFolders: []string{folder},
+		OutputFile:  volumePath,
+		Password:    "extract_password",
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	newReq := func() *DecryptRequest {
+		return &DecryptRequest{
+			InputFile: volumePath,
+			Password:  "extract_password",
+			Reporter:  &GoldenTestReporter{},
+			RSCodecs:  rsCodecs,
+		}
+	}
+
+	t.Run("existing_entry", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := ExtractFile(context.Background(), newReq(), "docs/sub/c.bin", &out); err != nil {
+			t.Fatalf("ExtractFile failed: %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), contents["docs/sub/c.bin"]) {
+			t.Errorf("Extracted %x; want %x", out.Bytes(), contents["docs/sub/c.bin"])
+		}
+
+		// Nothing else should have been written next to the volume
+		entries, err := os.ReadDir(tmpDir)
+		if err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("Expected only the input folder and volume in %s, found %d entries", tmpDir, len(entries))
+		}
+	})
+
+	t.Run("missing_entry", func(t *testing.T) {
+		var out bytes.Buffer
+		err := ExtractFile(context.Background(), newReq(), "docs/missing.txt", &out)
+		if !errors.Is(err, perrors.ErrFileNotFound) {
+			t.Errorf("Expected ErrFileNotFound, got: %v", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("Expected no output for a missing entry, got %d bytes", out.Len())
+		}
+	})
+
+	t.Run("wrong_password", func(t *testing.T) {
+		req := newReq()
+		req.Password = "wrong_password"
+		var out bytes.Buffer
+		if err := ExtractFile(context.Background(), req, "docs/a.txt", &out); err == nil {
+			t.Error("ExtractFile should fail with the wrong password")
+		}
+		if out.Len() != 0 {
+			t.Errorf("Expected no output on failure, got %d bytes", out.Len())
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package volume
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Fingerprint returns a hex SHA3-256 over a volume's non-secret header
+// parameters: version, flags, comment length, the Argon2 and HKDF salts,
+// the Serpent IV and the nonce. Neither the comments nor the payload are
+// read, and no credentials are needed.
+//
+// Every volume gets fresh random salts, so two encryptions of the same
+// input never share a fingerprint, while re-reading a volume always yields
+// the same one. For a split volume, pass the .0 chunk. Deniable volumes
+// have no readable header and return ErrInvalidFormat; a header too
+// damaged to decode returns ErrCorruptHeader.
+func Fingerprint(path string) (string, error) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return "", err
+	}
+	if IsDeniable(path, rsCodecs) {
+		return "", fmt.Errorf("%w: no readable header (deniable volume?)", perrors.ErrInvalidFormat)
+	}
+
+	fin, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open volume: %w", err)
+	}
+	defer func() { _ = fin.Close() }()
+
+	result, err := header.NewReader(fin, rsCodecs).ReadHeader()
+	if err != nil {
+		return "", fmt.Errorf("read header: %w", err)
+	}
+	if result.DecodeError != nil {
+		return "", fmt.Errorf("%w: %w", perrors.ErrCorruptHeader, result.DecodeError)
+	}
+	h := result.Header
+
+	hash := sha3.New256()
+	hash.Write([]byte(h.Version))
+	hash.Write(h.Flags.ToBytes())
+	_, _ = fmt.Fprintf(hash, "%05d", len(h.Comments))
+	hash.Write(h.Salt)
+	hash.Write(h.HKDFSalt)
+	hash.Write(h.SerpentIV)
+	hash.Write(h.Nonce)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,62 @@
+package volume
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// TestFingerprint tests that fingerprints are stable per volume and differ
+// between volumes with fresh salts
+func TestFingerprint(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "fingerprint.txt")
+	if err := os.WriteFile(inputPath, []byte("fingerprint test data"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	var volumes []string
+	for _, name := range []string{"first.pcv", "second.pcv"} {
+		path := filepath.Join(tmpDir, name)
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: path,
+			Password:   "fingerprint_password",
+			Comments:   "same options",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt %s failed: %v", name, err)
+		}
+		volumes = append(volumes, path)
+	}
+
+	first, err := Fingerprint(volumes[0])
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	again, err := Fingerprint(volumes[0])
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	if first != again {
+		t.Errorf("Fingerprint not stable across reads: %s vs %s", first, again)
+	}
+
+	second, err := Fingerprint(volumes[1])
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	if first == second {
+		t.Error("Volumes with different salts should not share a fingerprint")
+	}
+}
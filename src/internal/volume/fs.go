@@ -0,0 +1,112 @@
+package volume
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// FileSystem is the file access the core encryption and decryption paths
+// go through: reading the input, writing the .incomplete output and
+// renaming it into place. Detecting an armored or deniable volume reads
+// through it too. Requests with a nil FS use the real OS. Zipping,
+// splitting, recombining, deniability, armor, auto-unzip, AtomicOutput,
+// the durability sync (RequireDurable or Durable) and deleting inputs or
+// volumes work on the OS directly, like the free space check, so a request
+// combining them with any other FileSystem fails validation with
+// ErrFileSystemUnsupported. An armored volume read through one fails the
+// same way once it is detected.
+type FileSystem interface {
+	Open(name string) (File, error)
+	Create(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+// File is the part of *os.File a FileSystem hands out.
+type File interface {
+	io.Reader
+	io.Writer
+	io.WriterAt
+	io.Seeker
+	io.Closer
+	Name() string
+	Sync() error
+	Truncate(size int64) error
+}
+
+// osFS is the FileSystem of the real OS.
+type osFS struct{}
+
+func (osFS) Open(name string) (File, error) { return os.Open(name) }
+
+func (osFS) Create(name string) (File, error) { return os.Create(name) }
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+// Rename falls back to a synced copy across filesystems.
+func (osFS) Rename(oldpath, newpath string) error { return fileops.Rename(oldpath, newpath) }
+
+func (osFS) Remove(name string) error { return os.Remove(name) }
+
+// fileSystem returns fs, or the OS for nil.
+func fileSystem(fs FileSystem) FileSystem {
+	if fs == nil {
+		return osFS{}
+	}
+	return fs
+}
+
+// checkFileSystem returns ErrFileSystemUnsupported naming the first option
+// set that would bypass fs, or nil for the OS.
+func checkFileSystem(fs FileSystem, options []fsOption) error {
+	if _, ok := fileSystem(fs).(osFS); ok {
+		return nil
+	}
+	for _, opt := range options {
+		if opt.set {
+			return fmt.Errorf("%w: %s", perrors.ErrFileSystemUnsupported, opt.name)
+		}
+	}
+	return nil
+}
+
+// fsOption is a request option checkFileSystem looks at.
+type fsOption struct {
+	name string
+	set  bool
+}
+
+// encryptCheckFileSystem rejects encryption options that use the OS
+// directly when req.FS is another FileSystem.
+func encryptCheckFileSystem(req *EncryptRequest) error {
+	return checkFileSystem(req.FS, []fsOption{
+		{"zipping", needsZip(req)},
+		{"Split", req.Split},
+		{"Deniability", req.Deniability},
+		{"Armor", req.Armor || req.ArmorOnly},
+		{"AtomicOutput", req.AtomicOutput},
+		{"RequireDurable", req.RequireDurable || req.Durable != nil},
+		{"DeleteInputs", req.DeleteInputs},
+	})
+}
+
+// decryptCheckFileSystem rejects decryption options that use the OS
+// directly when req.FS is another FileSystem.
+func decryptCheckFileSystem(req *DecryptRequest) error {
+	return checkFileSystem(req.FS, []fsOption{
+		{"Recombine", req.Recombine},
+		{"Deniability", req.Deniability},
+		{"AutoUnzip", req.AutoUnzip},
+		{"DeleteVolume", req.DeleteVolume},
+	})
+}
@@ -0,0 +1,443 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/util"
+)
+
+var errInjectedWrite = errors.New("injected write failure")
+
+// memFS is an in-memory FileSystem whose file failName fails to write past
+// failAt bytes, after writing up to that offset.
+type memFS struct {
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string][]byte
+
+	failName string
+	failAt   int64
+}
+
+func newMemFS(dirs ...string) *memFS {
+	fs := &memFS{dirs: make(map[string]bool), files: make(map[string][]byte)}
+	for _, dir := range dirs {
+		fs.dirs[dir] = true
+	}
+	return fs
+}
+
+func (fs *memFS) Open(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *memFS) Create(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *memFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if !fs.dirs[filepath.Dir(name)] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	_, exists := fs.files[name]
+	switch {
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case !exists || flag&os.O_TRUNC != 0:
+		fs.files[name] = nil
+	}
+	return &memFile{fs: fs, name: name}, nil
+}
+
+func (fs *memFS) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.dirs[name] {
+		return memInfo{name: filepath.Base(name), dir: true}, nil
+	}
+	data, ok := fs.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memInfo{name: filepath.Base(name), size: int64(len(data))}, nil
+}
+
+func (fs *memFS) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	data, ok := fs.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	fs.files[newpath] = data
+	delete(fs.files, oldpath)
+	return nil
+}
+
+func (fs *memFS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, ok := fs.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs.files, name)
+	return nil
+}
+
+// file returns a copy of the contents of name, if it exists.
+func (fs *memFS) file(name string) ([]byte, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	data, ok := fs.files[name]
+	return bytes.Clone(data), ok
+}
+
+type memFile struct {
+	fs   *memFS
+	name string
+	off  int64
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	data := f.fs.files[f.name]
+	if f.off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[f.off:])
+	f.off += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	var err error
+	if f.name == f.fs.failName && off+int64(len(p)) > f.fs.failAt {
+		p = p[:max(f.fs.failAt-off, 0)]
+		err = errInjectedWrite
+	}
+	data := f.fs.files[f.name]
+	if end := off + int64(len(p)); end > int64(len(data)) {
+		data = append(data, make([]byte, end-int64(len(data)))...)
+	}
+	copy(data[off:], p)
+	f.fs.files[f.name] = data
+	return len(p), err
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		f.fs.mu.Lock()
+		offset += int64(len(f.fs.files[f.name]))
+		f.fs.mu.Unlock()
+	}
+	if offset < 0 {
+		return 0, errors.New("negative seek")
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	data := f.fs.files[f.name]
+	if size <= int64(len(data)) {
+		f.fs.files[f.name] = data[:size]
+	} else {
+		f.fs.files[f.name] = append(data, make([]byte, size-int64(len(data)))...)
+	}
+	return nil
+}
+
+func (f *memFile) Name() string { return f.name }
+func (f *memFile) Sync() error  { return nil }
+func (f *memFile) Close() error { return nil }
+
+type memInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() any           { return nil }
+func (i memInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// TestFileSystem tests that a volume can be written and read entirely
+// through an in-memory FileSystem, and that a write failing at a given
+// offset fails the operation and removes the partial output
+func TestFileSystem(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	// Paths under an empty real directory, which must stay empty
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.bin")
+	volumePath := filepath.Join(dir, "input.bin.pcv")
+	outputPath := filepath.Join(dir, "output.bin")
+
+	plaintext := make([]byte, 3*util.MiB+1234)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 7)
+	}
+	newFS := func() *memFS {
+		fs := newMemFS(dir)
+		fs.files[inputPath] = bytes.Clone(plaintext)
+		return fs
+	}
+	encryptReq := func(fs FileSystem) *EncryptRequest {
+		return &EncryptRequest{
+			InputFile:   inputPath,
+			OutputFile:  volumePath,
+			Password:    "memory_password",
+			ReedSolomon: true,
+			FS:          fs,
+			Reporter:    &GoldenTestReporter{},
+			RSCodecs:    rsCodecs,
+		}
+	}
+	decryptReq := func(fs FileSystem) *DecryptRequest {
+		return &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: outputPath,
+			Password:   "memory_password",
+			FS:         fs,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}
+	}
+
+	// One volume in memory serves every decryption below
+	fs := newFS()
+	if err := encryptReq(fs).Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := Encrypt(context.Background(), encryptReq(fs)); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	volume, ok := fs.file(volumePath)
+	if !ok {
+		t.Fatal("Encrypt did not write the volume to the FileSystem")
+	}
+
+	t.Run("roundtrip", func(t *testing.T) {
+		if err := Decrypt(context.Background(), decryptReq(fs)); err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		got, _ := fs.file(outputPath)
+		if !bytes.Equal(got, plaintext) {
+			t.Error("Decrypted content does not match original")
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("files written to disk: %v", entries)
+		}
+	})
+
+	t.Run("encrypt write error", func(t *testing.T) {
+		fs := newFS()
+		fs.failName = volumePath + ".incomplete"
+		fs.failAt = int64(util.MiB) + 1000
+		err := Encrypt(context.Background(), encryptReq(fs))
+		if !errors.Is(err, errInjectedWrite) {
+			t.Fatalf("Encrypt = %v; want the injected write error", err)
+		}
+		if _, ok := fs.file(fs.failName); ok {
+			t.Error(".incomplete output should be removed")
+		}
+		if _, ok := fs.file(volumePath); ok {
+			t.Error("volume should not exist after a failed write")
+		}
+	})
+
+	t.Run("decrypt write error", func(t *testing.T) {
+		fs := newMemFS(dir)
+		fs.files[volumePath] = bytes.Clone(volume)
+		fs.failName = outputPath + ".incomplete"
+		fs.failAt = 2*int64(util.MiB) + 5
+		err := Decrypt(context.Background(), decryptReq(fs))
+		if !errors.Is(err, errInjectedWrite) {
+			t.Fatalf("Decrypt = %v; want the injected write error", err)
+		}
+		if _, ok := fs.file(fs.failName); ok {
+			t.Error(".incomplete output should be removed")
+		}
+		if _, ok := fs.file(outputPath); ok {
+			t.Error("output should not exist after a failed write")
+		}
+	})
+}
+
+// TestFileSystemUnsupportedOptions tests that options working on the OS
+// directly are refused with a custom FileSystem, before anything is written
+func TestFileSystemUnsupportedOptions(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.bin")
+	volumePath := filepath.Join(dir, "input.bin.pcv")
+	newFS := func() *memFS {
+		fs := newMemFS(dir)
+		fs.files[inputPath] = []byte("stays in memory")
+		fs.files[volumePath] = []byte("not read before the check")
+		fs.files[volumePath+".0"] = []byte("nor is this chunk")
+		return fs
+	}
+
+	encryptTests := []struct {
+		name  string
+		setup func(req *EncryptRequest)
+	}{
+		{"zipping", func(req *EncryptRequest) {
+			req.InputFile = ""
+			req.InputFiles = []string{inputPath, inputPath}
+		}},
+		{"Split", func(req *EncryptRequest) {
+			req.Split = true
+			req.ChunkSize = 2
+			req.ChunkUnit = fileops.SplitUnitTotal
+		}},
+		{"Deniability", func(req *EncryptRequest) { req.Deniability = true }},
+		{"Armor", func(req *EncryptRequest) { req.Armor = true }},
+		{"AtomicOutput", func(req *EncryptRequest) { req.AtomicOutput = true }},
+		{"DeleteInputs", func(req *EncryptRequest) { req.DeleteInputs = true }},
+		{"RequireDurable", func(req *EncryptRequest) { req.RequireDurable = true }},
+		{"Durable", func(req *EncryptRequest) { req.Durable = new(bool) }},
+	}
+	for _, tt := range encryptTests {
+		t.Run("encrypt "+tt.name, func(t *testing.T) {
+			req := &EncryptRequest{
+				InputFile:  inputPath,
+				OutputFile: volumePath + ".new",
+				Password:   "memory_password",
+				FS:         newFS(),
+				Reporter:   &GoldenTestReporter{},
+			}
+			tt.setup(req)
+			if err := req.Validate(); !errors.Is(err, perrors.ErrFileSystemUnsupported) {
+				t.Errorf("Validate = %v; want ErrFileSystemUnsupported", err)
+			}
+			if err := Encrypt(context.Background(), req); !errors.Is(err, perrors.ErrFileSystemUnsupported) {
+				t.Errorf("Encrypt = %v; want ErrFileSystemUnsupported", err)
+			}
+
+			// The same options are fine on the OS
+			req.FS = nil
+			if err := encryptCheckFileSystem(req); err != nil {
+				t.Errorf("check without a FileSystem = %v; want nil", err)
+			}
+		})
+	}
+
+	decryptTests := []struct {
+		name  string
+		setup func(req *DecryptRequest)
+	}{
+		{"Recombine", func(req *DecryptRequest) { req.Recombine = true }},
+		{"Deniability", func(req *DecryptRequest) { req.Deniability = true }},
+		{"AutoUnzip", func(req *DecryptRequest) { req.AutoUnzip = true }},
+		{"DeleteVolume", func(req *DecryptRequest) { req.DeleteVolume = true }},
+	}
+	for _, tt := range decryptTests {
+		t.Run("decrypt "+tt.name, func(t *testing.T) {
+			req := &DecryptRequest{
+				InputFile:  volumePath,
+				OutputFile: inputPath + ".out",
+				Password:   "memory_password",
+				FS:         newFS(),
+				Reporter:   &GoldenTestReporter{},
+			}
+			tt.setup(req)
+			if err := req.Validate(); !errors.Is(err, perrors.ErrFileSystemUnsupported) {
+				t.Errorf("Validate = %v; want ErrFileSystemUnsupported", err)
+			}
+			if err := Decrypt(context.Background(), req); !errors.Is(err, perrors.ErrFileSystemUnsupported) {
+				t.Errorf("Decrypt = %v; want ErrFileSystemUnsupported", err)
+			}
+		})
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files written to disk: %v", entries)
+	}
+}
+
+// TestFileSystemVolumeDetection tests that armored and deniable volumes are
+// recognised from their content in the FileSystem, not from the disk
+func TestFileSystemVolumeDetection(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	// Nothing exists on disk at these paths
+	dir := t.TempDir()
+	volumePath := filepath.Join(dir, "input.bin.pcv")
+	decryptReq := func(content []byte) *DecryptRequest {
+		fs := newMemFS(dir)
+		fs.files[volumePath] = content
+		return &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: filepath.Join(dir, "output.bin"),
+			Password:   "memory_password",
+			FS:         fs,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}
+	}
+
+	t.Run("armored", func(t *testing.T) {
+		req := decryptReq([]byte("\n" + encoding.ArmorBegin + "\nAAAA\n"))
+		err := Decrypt(context.Background(), req)
+		if !errors.Is(err, perrors.ErrFileSystemUnsupported) {
+			t.Errorf("Decrypt = %v; want ErrFileSystemUnsupported for the armored volume", err)
+		}
+	})
+
+	t.Run("strict deniability", func(t *testing.T) {
+		req := decryptReq(bytes.Repeat([]byte{0xA5}, 4096))
+		req.StrictDeniability = true
+		err := Decrypt(context.Background(), req)
+		if !errors.Is(err, perrors.ErrDeniableNotAcknowledged) {
+			t.Errorf("Decrypt = %v; want ErrDeniableNotAcknowledged", err)
+		}
+	})
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files written to disk: %v", entries)
+	}
+}
@@ -2,6 +2,7 @@ package volume
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -10,12 +11,15 @@ import (
 	"strings"
 	"testing"
 
+	"Picocrypt-NG/internal/crypto"
 	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/header"
 )
 
 // GoldenTestReporter is a minimal reporter for testing
 type GoldenTestReporter struct {
 	status    string
+	warnings  []string
 	cancelled bool
 }
 
@@ -33,12 +37,20 @@ func (r *GoldenTestReporter) IsCancelled() bool {
 	return r.cancelled
 }
 
+func (r *GoldenTestReporter) Warn(msg string) {
+	r.warnings = append(r.warnings, msg)
+}
+
 // Test password for all golden files
 const goldenPassword = "test"
 
 // Expected plaintext content
 const expectedContent = "There is a test file for Picocrypt validation.\n"
 
+// goldenFixedFile is pico_test.txt encrypted with fixedValues, so it can be
+// reproduced byte for byte (see TestGoldenFixedValues)
+const goldenFixedFile = "pico_test_v2_fixed.txt.pcv"
+
 // Golden test corpus paths (relative to testdata/golden/)
 var goldenTestCases = []struct {
 	name        string
@@ -61,6 +73,13 @@ var goldenTestCases = []struct {
 		paranoid:    false,
 		reedSolomon: false,
 	},
+	{
+		name:        "v2_fixed_values",
+		file:        goldenFixedFile,
+		deniability: false,
+		paranoid:    false,
+		reedSolomon: false,
+	},
 	{
 		name:        "v1_deny_paranoid_rs",
 		file:        "pico_test_v1_deny_paranoid_rs.txt.pcv",
@@ -282,6 +301,81 @@ func TestGoldenCompressedDecryption(t *testing.T) {
 	}
 }
 
+// fixedValues returns the salt, HKDF salt, Serpent IV and nonce used for
+// deterministic vectors: consecutive byte values starting at 0x00, 0x10,
+// 0x30 and 0x40, which other implementations can reproduce trivially
+func fixedValues() (salt, hkdfSalt, serpentIV, nonce []byte) {
+	seq := func(start byte, n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = start + byte(i)
+		}
+		return b
+	}
+	return seq(0x00, header.SaltSize), seq(0x10, header.HKDFSaltSize),
+		seq(0x30, header.SerpentIVSize), seq(0x40, header.NonceSize)
+}
+
+// TestGoldenFixedValues tests that encrypting pico_test.txt with the golden
+// password and fixedValues reproduces the committed golden volume exactly.
+// A failure here means the volume format changed; decryption of the same
+// file is covered by TestGoldenDecryption.
+func TestGoldenFixedValues(t *testing.T) {
+	testdataPath := findTestdata(t)
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	want, err := os.ReadFile(filepath.Join(testdataPath, goldenFixedFile))
+	if err != nil {
+		t.Fatalf("Failed to read golden volume: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "pico_test.txt")
+	copyFile(t, filepath.Join(testdataPath, "pico_test.txt"), inputPath)
+	for i := range 2 {
+		outputPath := filepath.Join(tmpDir, fmt.Sprintf("fixed%d.pcv", i))
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: outputPath,
+			Password:   goldenPassword,
+			// Pinned to the default so the header never depends on the host
+			Argon2Threads: int(crypto.Argon2Threads(false)),
+			Reporter:      &GoldenTestReporter{},
+			RSCodecs:      rsCodecs,
+			testValues:    fixedValues,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		got, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("run %d does not match %s (%d vs %d bytes)", i, goldenFixedFile, len(got), len(want))
+		}
+	}
+
+	hdr := readVolumeHeader(t, filepath.Join(testdataPath, goldenFixedFile), rsCodecs)
+	salt, hkdfSalt, serpentIV, nonce := fixedValues()
+	for _, f := range []struct {
+		name      string
+		got, want []byte
+	}{
+		{"salt", hdr.Salt, salt},
+		{"hkdfSalt", hdr.HKDFSalt, hkdfSalt},
+		{"serpentIV", hdr.SerpentIV, serpentIV},
+		{"nonce", hdr.Nonce, nonce},
+	} {
+		if !bytes.Equal(f.got, f.want) {
+			t.Errorf("header %s = %x; want %x", f.name, f.got, f.want)
+		}
+	}
+}
+
 func TestGoldenV1Detection(t *testing.T) {
 	testdataPath := findTestdata(t)
 
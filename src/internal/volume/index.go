@@ -0,0 +1,154 @@
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"Picocrypt-NG/internal/errors"
+)
+
+// IndexExt is appended to a volume path to name its encrypted index.
+const IndexExt = ".index"
+
+// IndexEntry is one file listed in a volume index.
+type IndexEntry struct {
+	Name string `json:"name"` // Path inside the zip, or the file name of an unzipped volume
+	Size int64  `json:"size"` // Size of the original file in bytes
+}
+
+// IndexPath returns the index path for a volume. A numbered chunk of a split
+// volume shares the index of its base (file.pcv.0 -> file.pcv.index).
+func IndexPath(volumePath string) string {
+	return splitVolumeBase(volumePath) + IndexExt
+}
+
+// ReadIndex decrypts the index written beside a volume by
+// EncryptRequest.Index and returns the files it lists, without touching the
+// volume itself. Only the credentials, RSCodecs and FS of req are used; wrong
+// credentials fail as they would for the volume.
+func ReadIndex(ctx context.Context, volumePath string, req *DecryptRequest) ([]IndexEntry, error) {
+	f, err := fileSystem(req.FS).Open(IndexPath(volumePath))
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	sealed, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+
+	data, err := DecryptBytes(ctx, sealed, &DecryptRequest{
+		Password:     req.Password,
+		PasswordFunc: req.PasswordFunc,
+		Keyfiles:     req.Keyfiles,
+		MaxKeyfiles:  req.MaxKeyfiles,
+		AAD:          req.AAD,
+		Pepper:       req.Pepper,
+		RSCodecs:     req.RSCodecs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []IndexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse index: %w", err)
+	}
+	return entries, nil
+}
+
+// validateIndex checks Index. A deniable volume must not be accompanied by
+// a file that identifies it, and RecordSplit writes many volumes.
+func validateIndex(req *EncryptRequest) error {
+	if !req.Index {
+		return nil
+	}
+	if req.Deniability {
+		return errors.NewValidationError("Index", "cannot be combined with deniability")
+	}
+	if req.RecordSplit != 0 {
+		return errors.NewValidationError("Index", "cannot be combined with RecordSplit")
+	}
+	return nil
+}
+
+// encryptWriteIndex seals the list of encrypted files with EncryptBytes
+// under the credentials of the volume and writes it to IndexPath.
+func encryptWriteIndex(ctx *OperationContext, req *EncryptRequest) error {
+	ctx.SetStatus("Encrypting index...")
+
+	entries, err := indexEntries(ctx.FS, req)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("encode index: %w", err)
+	}
+	sealed, err := EncryptBytes(ctx.Ctx, data, &EncryptRequest{
+		Password:       req.Password,
+		PasswordFunc:   req.PasswordFunc,
+		Keyfiles:       req.Keyfiles,
+		MaxKeyfiles:    req.MaxKeyfiles,
+		KeyfileOrdered: req.KeyfileOrdered,
+		KeyfileHash:    req.KeyfileHash,
+		AAD:            req.AAD,
+		Pepper:         req.Pepper,
+		Paranoid:       req.Paranoid,
+		RSCodecs:       req.RSCodecs,
+	})
+	if err != nil {
+		return fmt.Errorf("encrypt index: %w", err)
+	}
+
+	// Write beside the target and rename so readers never see a partial file
+	path := IndexPath(req.OutputFile)
+	tmpPath := path + ".incomplete"
+	f, err := ctx.FS.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	_, err = f.Write(sealed)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.FS.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = ctx.FS.Remove(tmpPath)
+		return fmt.Errorf("write index: %w", err)
+	}
+	return nil
+}
+
+// indexEntries lists the files of req under the names they have in the
+// volume: relative to zipRootDir when zipped, the bare file name otherwise.
+func indexEntries(fs FileSystem, req *EncryptRequest) ([]IndexEntry, error) {
+	files := req.InputFiles
+	if len(files) == 0 {
+		files = []string{req.InputFile}
+	}
+	zipped := needsZip(req)
+	root := zipRootDir(req)
+
+	entries := make([]IndexEntry, 0, len(files))
+	for _, path := range files {
+		stat, err := fs.Stat(path)
+		if err != nil {
+			return nil, errors.NewFileError("stat", path, err)
+		}
+		name := filepath.Base(path)
+		if zipped {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil, fmt.Errorf("index %s: %w", path, err)
+			}
+			name = filepath.ToSlash(rel)
+		}
+		entries = append(entries, IndexEntry{Name: name, Size: stat.Size()})
+	}
+	return entries, nil
+}
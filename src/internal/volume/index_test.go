@@ -0,0 +1,155 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// TestIndex tests that the index written beside a volume lists its files
+// to the holder of the volume's password and to nobody else
+func TestIndex(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	folder := filepath.Join(tmpDir, "contracts")
+	if err := os.MkdirAll(filepath.Join(folder, "2024"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(folder, "lease.txt"):            "tenancy agreement",
+		filepath.Join(folder, "2024", "supplier.txt"): "supply terms for the year",
+	}
+	var inputs []string
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+
+	volumePath := filepath.Join(tmpDir, "contracts.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles:  inputs,
+		OnlyFolders: []string{folder},
+		OutputFile:  volumePath,
+		Password:    "index_password",
+		Index:       true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	t.Run("lists the files with the volume password", func(t *testing.T) {
+		entries, err := ReadIndex(context.Background(), volumePath, &DecryptRequest{
+			Password: "index_password",
+			RSCodecs: rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("ReadIndex failed: %v", err)
+		}
+		got := map[string]int64{}
+		for _, e := range entries {
+			got[e.Name] = e.Size
+		}
+		want := map[string]int64{
+			"contracts/lease.txt":         int64(len("tenancy agreement")),
+			"contracts/2024/supplier.txt": int64(len("supply terms for the year")),
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("index = %v; want %v", got, want)
+		}
+	})
+
+	t.Run("unreadable with a wrong password", func(t *testing.T) {
+		entries, err := ReadIndex(context.Background(), volumePath, &DecryptRequest{
+			Password: "wrong_password",
+			RSCodecs: rsCodecs,
+		})
+		if err == nil {
+			t.Fatalf("ReadIndex succeeded with a wrong password: %v", entries)
+		}
+	})
+
+	t.Run("names are not stored in the clear", func(t *testing.T) {
+		sealed, err := os.ReadFile(IndexPath(volumePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(sealed, []byte("lease.txt")) {
+			t.Error("the index file contains a plaintext name")
+		}
+	})
+
+	t.Run("rejected with deniability or record split", func(t *testing.T) {
+		for _, req := range []*EncryptRequest{
+			{InputFile: inputs[0], OutputFile: volumePath, Password: "p", Index: true, Deniability: true},
+			{InputFile: inputs[0], OutputFile: volumePath, Password: "p", Index: true, RecordSplit: 10},
+		} {
+			if err := req.Validate(); err == nil {
+				t.Errorf("Validate accepted Index with %+v", req)
+			}
+		}
+	})
+}
+
+// TestIndexManyKeyfiles tests that the index honours MaxKeyfiles like the
+// volume it describes
+func TestIndexManyKeyfiles(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "ledger.txt")
+	if err := os.WriteFile(inputPath, []byte("accounts"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	keyfiles := make([]string, DefaultMaxKeyfiles+1)
+	for i := range keyfiles {
+		keyfiles[i] = filepath.Join(tmpDir, fmt.Sprintf("key%02d", i))
+		if err := os.WriteFile(keyfiles[i], []byte(fmt.Sprintf("keyfile %d", i)), 0644); err != nil {
+			t.Fatalf("Failed to write keyfile: %v", err)
+		}
+	}
+
+	volumePath := inputPath + ".pcv"
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  volumePath,
+		Password:    "index_password",
+		Keyfiles:    keyfiles,
+		MaxKeyfiles: -1,
+		Index:       true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	entries, err := ReadIndex(context.Background(), volumePath, &DecryptRequest{
+		Password:    "index_password",
+		Keyfiles:    keyfiles,
+		MaxKeyfiles: -1,
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "ledger.txt" {
+		t.Errorf("index = %v; want ledger.txt", entries)
+	}
+}
@@ -0,0 +1,94 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/util"
+)
+
+// truncatingReporter shrinks the input on the first progress update, which
+// comes after the first block or chunk and, for an input over the CDC
+// chunker's 4 MiB buffer, before the rest has been read
+type truncatingReporter struct {
+	GoldenTestReporter
+	t    *testing.T
+	path string
+	size int64
+	done bool
+}
+
+func (r *truncatingReporter) SetProgress(fraction float32, info string) {
+	if r.done {
+		return
+	}
+	r.done = true
+	if err := os.Truncate(r.path, r.size); err != nil {
+		r.t.Errorf("Failed to truncate input: %v", err)
+	}
+}
+
+// TestAbortOnInputChange tests that an input shrinking mid-read fails with
+// ErrInputChangedDuringRead and leaves no output behind, and that without
+// the option whatever was read is encrypted as before
+func TestAbortOnInputChange(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		abort   bool
+		cdc     bool
+		wantErr bool
+	}{
+		{"abort", true, false, true},
+		{"abort cdc", true, true, true},
+		{"no abort", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			inputPath := filepath.Join(tmpDir, "live.log")
+			if err := os.WriteFile(inputPath, make([]byte, 8*util.MiB), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			req := &EncryptRequest{
+				InputFile:          inputPath,
+				OutputFile:         inputPath + ".pcv",
+				Password:           "change_password",
+				CDCDedup:           tt.cdc,
+				AbortOnInputChange: tt.abort,
+				Reporter:           &truncatingReporter{t: t, path: inputPath, size: 5 * util.MiB},
+				RSCodecs:           rsCodecs,
+			}
+			err := Encrypt(context.Background(), req)
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Encrypt failed: %v", err)
+				}
+				if _, err := os.Stat(req.OutputFile); err != nil {
+					t.Errorf("output missing: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, perrors.ErrInputChangedDuringRead) {
+				t.Fatalf("expected ErrInputChangedDuringRead, got %v", err)
+			}
+			for _, path := range []string{req.OutputFile, req.OutputFile + ".incomplete"} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s was left behind", path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package volume
+
+import (
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/keyfile"
+)
+
+// HashKeyfiles returns the keyfile hash that Encrypt stores in the volume
+// header for the given keyfiles: SHA3-256 of the combined keyfile key. Use
+// HashKeyfilesWith for volumes created with another EncryptRequest.KeyfileHash.
+//
+// ordered selects the same combination rule as EncryptRequest.KeyfileOrdered
+// (one hash over all files in sequence vs. XOR of per-file hashes). Sets that
+// cancel out to an all-zero key, such as an even number of identical
+// unordered keyfiles, are rejected with ErrDuplicateKeyfiles just as Encrypt
+// rejects them. An empty set hashes to 32 zero bytes, matching volumes
+// created without keyfiles.
+//
+// The keyfile key itself is zeroed before returning; only the hash, which is
+// not secret, is exposed.
+func HashKeyfiles(keyfiles []string, ordered bool) ([]byte, error) {
+	return HashKeyfilesWith(keyfiles, ordered, keyfile.HashSHA3)
+}
+
+// HashKeyfilesWith is HashKeyfiles for the keyfile hash alg.
+func HashKeyfilesWith(keyfiles []string, ordered bool, alg keyfile.HashAlgorithm) ([]byte, error) {
+	result, err := keyfile.ProcessWith(keyfiles, ordered, alg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	if len(keyfiles) > 0 && keyfile.IsDuplicateKeyfileKey(result.Key) {
+		return nil, perrors.ErrDuplicateKeyfiles
+	}
+	return result.Hash, nil
+}
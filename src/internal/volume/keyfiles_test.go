@@ -0,0 +1,465 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/keyfile"
+)
+
+// TestHashKeyfilesMatchesHeader tests that HashKeyfiles reproduces the keyfile
+// hash stored in a volume header for ordered and unordered keyfile sets
+func TestHashKeyfilesMatchesHeader(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	keyfiles := make([]string, 3)
+	for i := range keyfiles {
+		keyfiles[i] = filepath.Join(tmpDir, "key"+string(rune('a'+i)))
+		if err := os.WriteFile(keyfiles[i], bytes.Repeat([]byte{byte(i + 1)}, 1000*(i+1)), 0644); err != nil {
+			t.Fatalf("Failed to write keyfile: %v", err)
+		}
+	}
+
+	inputPath := filepath.Join(tmpDir, "hash.txt")
+	if err := os.WriteFile(inputPath, []byte("keyfile hash test data"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	for _, ordered := range []bool{false, true} {
+		name := "unordered"
+		if ordered {
+			name = "ordered"
+		}
+		t.Run(name, func(t *testing.T) {
+			volumePath := filepath.Join(tmpDir, name+".pcv")
+			err := Encrypt(context.Background(), &EncryptRequest{
+				InputFile:      inputPath,
+				OutputFile:     volumePath,
+				Password:       "hash_password",
+				Keyfiles:       keyfiles,
+				KeyfileOrdered: ordered,
+				Reporter:       &GoldenTestReporter{},
+				RSCodecs:       rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			f, err := os.Open(volumePath)
+			if err != nil {
+				t.Fatalf("Failed to open volume: %v", err)
+			}
+			result, err := header.NewReader(f, rsCodecs).ReadHeader()
+			_ = f.Close()
+			if err != nil {
+				t.Fatalf("ReadHeader failed: %v", err)
+			}
+
+			hash, err := HashKeyfiles(keyfiles, ordered)
+			if err != nil {
+				t.Fatalf("HashKeyfiles failed: %v", err)
+			}
+			if !bytes.Equal(hash, result.Header.KeyfileHash) {
+				t.Errorf("HashKeyfiles = %x; header stores %x", hash, result.Header.KeyfileHash)
+			}
+
+			// Reordering only matters for ordered sets
+			reversed := []string{keyfiles[2], keyfiles[1], keyfiles[0]}
+			hash, err = HashKeyfiles(reversed, ordered)
+			if err != nil {
+				t.Fatalf("HashKeyfiles (reversed) failed: %v", err)
+			}
+			if matches := bytes.Equal(hash, result.Header.KeyfileHash); matches == ordered {
+				t.Errorf("Reversed keyfiles match header = %v; want %v", matches, !ordered)
+			}
+		})
+	}
+}
+
+// TestHashKeyfilesDuplicates tests that cancelling unordered keyfiles are rejected
+func TestHashKeyfilesDuplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyfilePath := filepath.Join(tmpDir, "dup.key")
+	if err := os.WriteFile(keyfilePath, []byte("duplicate keyfile"), 0644); err != nil {
+		t.Fatalf("Failed to write keyfile: %v", err)
+	}
+
+	_, err := HashKeyfiles([]string{keyfilePath, keyfilePath}, false)
+	if !errors.Is(err, perrors.ErrDuplicateKeyfiles) {
+		t.Errorf("Expected ErrDuplicateKeyfiles, got: %v", err)
+	}
+
+	// Ordered hashing does not cancel, so the same pair is accepted
+	if _, err := HashKeyfiles([]string{keyfilePath, keyfilePath}, true); err != nil {
+		t.Errorf("Ordered duplicates should hash, got: %v", err)
+	}
+}
+
+// TestKeyfileHashAlgorithms tests that volumes round-trip with either keyfile
+// hash in both combination modes, and that the header flag alone decides
+// which hash decryption applies
+func TestKeyfileHashAlgorithms(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	keyfiles := make([]string, 2)
+	for i := range keyfiles {
+		keyfiles[i] = filepath.Join(tmpDir, "key"+string(rune('a'+i)))
+		if err := os.WriteFile(keyfiles[i], bytes.Repeat([]byte{byte(i + 7)}, 500*(i+1)), 0644); err != nil {
+			t.Fatalf("Failed to write keyfile: %v", err)
+		}
+	}
+	plaintext := []byte("keyfile hash algorithm test data")
+	inputPath := filepath.Join(tmpDir, "alg.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	for _, alg := range []keyfile.HashAlgorithm{keyfile.HashSHA3, keyfile.HashBLAKE2b} {
+		other := keyfile.HashBLAKE2b
+		if alg == keyfile.HashBLAKE2b {
+			other = keyfile.HashSHA3
+		}
+		for _, ordered := range []bool{false, true} {
+			name := alg.String() + "/unordered"
+			if ordered {
+				name = alg.String() + "/ordered"
+			}
+			t.Run(name, func(t *testing.T) {
+				volumePath := filepath.Join(tmpDir, alg.String()+fmt.Sprint(ordered)+".pcv")
+				if err := Encrypt(context.Background(), &EncryptRequest{
+					InputFile:      inputPath,
+					OutputFile:     volumePath,
+					Password:       "alg_password",
+					Keyfiles:       keyfiles,
+					KeyfileOrdered: ordered,
+					KeyfileHash:    alg,
+					Reporter:       &GoldenTestReporter{},
+					RSCodecs:       rsCodecs,
+				}); err != nil {
+					t.Fatalf("Encrypt failed: %v", err)
+				}
+
+				hdr := readVolumeHeader(t, volumePath, rsCodecs)
+				if hdr.Flags.KeyfileBLAKE2b != (alg == keyfile.HashBLAKE2b) {
+					t.Errorf("KeyfileBLAKE2b flag = %v for %v", hdr.Flags.KeyfileBLAKE2b, alg)
+				}
+				hash, err := HashKeyfilesWith(keyfiles, ordered, alg)
+				if err != nil {
+					t.Fatalf("HashKeyfilesWith failed: %v", err)
+				}
+				if !bytes.Equal(hash, hdr.KeyfileHash) {
+					t.Error("Header keyfile hash does not match the chosen algorithm")
+				}
+				hash, err = HashKeyfilesWith(keyfiles, ordered, other)
+				if err != nil {
+					t.Fatalf("HashKeyfilesWith failed: %v", err)
+				}
+				if bytes.Equal(hash, hdr.KeyfileHash) {
+					t.Error("The other algorithm should not reproduce the header keyfile hash")
+				}
+
+				// Decrypt needs no algorithm; it comes from the header
+				outputPath := volumePath + ".out"
+				if err := Decrypt(context.Background(), &DecryptRequest{
+					InputFile:  volumePath,
+					OutputFile: outputPath,
+					Password:   "alg_password",
+					Keyfiles:   keyfiles,
+					Reporter:   &GoldenTestReporter{},
+					RSCodecs:   rsCodecs,
+				}); err != nil {
+					t.Fatalf("Decrypt failed: %v", err)
+				}
+				got, err := os.ReadFile(outputPath)
+				if err != nil {
+					t.Fatalf("Failed to read decrypted file: %v", err)
+				}
+				if !bytes.Equal(got, plaintext) {
+					t.Error("Decrypted content does not match")
+				}
+
+				// Rewriting the flag to claim the other algorithm fails
+				flipKeyfileHashFlag(t, volumePath, rsCodecs)
+				err = Decrypt(context.Background(), &DecryptRequest{
+					InputFile:  volumePath,
+					OutputFile: volumePath + ".flipped",
+					Password:   "alg_password",
+					Keyfiles:   keyfiles,
+					Reporter:   &GoldenTestReporter{},
+					RSCodecs:   rsCodecs,
+				})
+				if err == nil {
+					t.Fatal("Decrypt succeeded with the other keyfile hash assumed")
+				}
+				if _, statErr := os.Stat(volumePath + ".flipped"); !os.IsNotExist(statErr) {
+					t.Error("No output should be written when the keyfile hash is wrong")
+				}
+			})
+		}
+	}
+}
+
+// readVolumeHeader reads the header of the volume at path.
+func readVolumeHeader(t *testing.T, path string, rsCodecs *encoding.RSCodecs) *header.VolumeHeader {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open volume: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	result, err := header.NewReader(f, rsCodecs).ReadHeader()
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	return result.Header
+}
+
+// flipKeyfileHashFlag toggles the keyfile hash bit in the RS-encoded flags of
+// a volume without comments.
+func flipKeyfileHashFlag(t *testing.T, path string, rsCodecs *encoding.RSCodecs) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+	const flagsOffset = 30 // version(15) + commentLen(15)
+	flags, err := encoding.Decode(rsCodecs.RS5, data[flagsOffset:flagsOffset+15], false)
+	if err != nil {
+		t.Fatalf("Failed to decode flags: %v", err)
+	}
+	parsed := header.FlagsFromBytes(flags)
+	parsed.KeyfileBLAKE2b = !parsed.KeyfileBLAKE2b
+	copy(data[flagsOffset:], encoding.Encode(rsCodecs.RS5, parsed.ToBytes()))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write volume: %v", err)
+	}
+}
+
+// keyfileHeapReporter records the heap watermark while keyfiles are read,
+// measured from a collection at the start of that phase so the Argon2
+// memory freed just before does not count
+type keyfileHeapReporter struct {
+	GoldenTestReporter
+	reading    bool
+	calls      int
+	base, peak uint64
+}
+
+func (r *keyfileHeapReporter) SetStatus(text string) {
+	r.GoldenTestReporter.SetStatus(text)
+	r.reading = text == "Reading keyfiles..."
+	if r.reading {
+		var ms runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		r.base, r.peak = ms.HeapAlloc, ms.HeapAlloc
+	}
+}
+
+func (r *keyfileHeapReporter) SetProgress(fraction float32, info string) {
+	if r.calls++; !r.reading || r.calls%64 != 0 {
+		return
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	r.peak = max(r.peak, ms.HeapAlloc)
+}
+
+// TestLargeSparseKeyfile tests that a keyfile far larger than any buffer is
+// streamed during encryption with bounded memory, and that the volume
+// decrypts with it and records the same hash HashKeyfiles computes
+func TestLargeSparseKeyfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 512 MiB keyfile in short mode")
+	}
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	keyfilePath := filepath.Join(tmpDir, "huge.key")
+	if err := os.WriteFile(keyfilePath, []byte("sparse keyfile head"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(keyfilePath, 512<<20); err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("protected by a very large keyfile")
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	volumePath := inputPath + ".pcv"
+	reporter := &keyfileHeapReporter{}
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "sparse_password",
+		Keyfiles:   []string{keyfilePath},
+		Reporter:   reporter,
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if reporter.base == 0 {
+		t.Fatal("keyfile phase was never reported")
+	}
+	if grew := reporter.peak - reporter.base; grew > 64<<20 {
+		t.Errorf("heap grew by %d MiB while reading a 512 MiB keyfile", grew>>20)
+	}
+
+	hash, err := HashKeyfiles([]string{keyfilePath}, false)
+	if err != nil {
+		t.Fatalf("HashKeyfiles failed: %v", err)
+	}
+	if !bytes.Equal(hash, readVolumeHeader(t, volumePath, rsCodecs).KeyfileHash) {
+		t.Error("header keyfile hash does not match HashKeyfiles")
+	}
+
+	outputPath := filepath.Join(tmpDir, "output.txt")
+	if err := Decrypt(context.Background(), &DecryptRequest{
+		InputFile:  volumePath,
+		OutputFile: outputPath,
+		Password:   "sparse_password",
+		Keyfiles:   []string{keyfilePath},
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("decrypted content does not match")
+	}
+}
+
+// TestUnexpectedKeyfiles tests keyfiles supplied for a volume without any:
+// ignored with a warning by default, ErrUnexpectedKeyfiles with StrictKeyfiles
+func TestUnexpectedKeyfiles(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "plain.txt")
+	content := []byte("no keyfiles here")
+	if err := os.WriteFile(inputPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	keyfilePath := filepath.Join(tmpDir, "stray.key")
+	if err := os.WriteFile(keyfilePath, []byte("left over from another volume"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	volumePath := inputPath + ".pcv"
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "keyfile_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	t.Run("default ignores with a warning", func(t *testing.T) {
+		outputPath := filepath.Join(t.TempDir(), "plain.txt")
+		reporter := &GoldenTestReporter{}
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: outputPath,
+			Password:   "keyfile_password",
+			Keyfiles:   []string{keyfilePath},
+			Reporter:   reporter,
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, content) {
+			t.Error("decrypted content differs")
+		}
+		if len(reporter.warnings) != 1 || !strings.Contains(reporter.warnings[0], "does not use keyfiles") {
+			t.Errorf("warnings = %q; want one about the ignored keyfiles", reporter.warnings)
+		}
+	})
+
+	t.Run("verify first warns once", func(t *testing.T) {
+		reporter := &GoldenTestReporter{}
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:   volumePath,
+			OutputFile:  filepath.Join(t.TempDir(), "plain.txt"),
+			Password:    "keyfile_password",
+			Keyfiles:    []string{keyfilePath},
+			VerifyFirst: true,
+			Reporter:    reporter,
+			RSCodecs:    rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if len(reporter.warnings) != 1 {
+			t.Errorf("warnings = %q; want the ignored keyfiles reported once", reporter.warnings)
+		}
+	})
+
+	t.Run("strict fails", func(t *testing.T) {
+		outputPath := filepath.Join(t.TempDir(), "plain.txt")
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:      volumePath,
+			OutputFile:     outputPath,
+			Password:       "keyfile_password",
+			Keyfiles:       []string{keyfilePath},
+			StrictKeyfiles: true,
+			Reporter:       &GoldenTestReporter{},
+			RSCodecs:       rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrUnexpectedKeyfiles) {
+			t.Fatalf("expected ErrUnexpectedKeyfiles, got %v", err)
+		}
+		if _, statErr := os.Stat(outputPath); !os.IsNotExist(statErr) {
+			t.Error("No output should be written for unexpected keyfiles")
+		}
+	})
+
+	t.Run("strict without keyfiles succeeds", func(t *testing.T) {
+		reporter := &GoldenTestReporter{}
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:      volumePath,
+			OutputFile:     filepath.Join(t.TempDir(), "plain.txt"),
+			Password:       "keyfile_password",
+			StrictKeyfiles: true,
+			Reporter:       reporter,
+			RSCodecs:       rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if len(reporter.warnings) != 0 {
+			t.Errorf("warnings = %q; want none", reporter.warnings)
+		}
+	})
+}
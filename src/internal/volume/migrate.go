@@ -0,0 +1,259 @@
+package volume
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"Picocrypt-NG/internal/crypto"
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/log"
+	"Picocrypt-NG/internal/util"
+)
+
+// Credentials holds the secrets needed to open an existing volume.
+type Credentials struct {
+	Password    string   // Volume password
+	Keyfiles    []string // Keyfile paths, if the volume uses keyfiles
+	Pepper      []byte   // Pepper the volume was created with, if any
+	AAD         []byte   // Associated data the volume was created with, if any
+	MaxKeyfiles int      // As for DecryptRequest.MaxKeyfiles
+}
+
+// Migrate converts a legacy v1 volume at path into the current v2 format.
+//
+// The payload is decrypted and re-encrypted one block at a time in memory,
+// so plaintext never touches the disk. The new volume is written next to
+// the original and renamed over it only after the v1 MAC has been verified;
+// on any error the original is left untouched.
+//
+// Comments, paranoid mode, Reed-Solomon and keyfile settings (including
+// keyfile ordering) are preserved. Fresh salts and nonces are generated, and
+// the same credentials open the migrated volume. Reed-Solomon payloads are
+// fully decoded, so correctable damage is repaired along the way.
+//
+// Split and deniable volumes must be recombined or unwrapped first.
+// Returns ErrNotLegacyVolume if the volume is already v2.
+// reporter may be nil.
+func Migrate(ctx context.Context, path string, creds Credentials, reporter ProgressReporter) error {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return err
+	}
+
+	dreq := &DecryptRequest{
+		InputFile:   path,
+		Password:    creds.Password,
+		Keyfiles:    creds.Keyfiles,
+		MaxKeyfiles: creds.MaxKeyfiles,
+		Reporter:    reporter,
+		RSCodecs:    rsCodecs,
+	}
+	src := NewDecryptContext(ctx, dreq)
+	defer src.Close()
+
+	log.Info("starting migration", log.String("input", path))
+
+	// Open the v1 volume: read the header and authenticate the credentials
+	if err := decryptPreprocess(src, dreq); err != nil {
+		return err
+	}
+	if err := decryptReadHeader(src, dreq); err != nil {
+		return err
+	}
+	if !src.IsLegacyV1 {
+		return perrors.ErrNotLegacyVolume
+	}
+	if err := decryptDeriveKeys(src, dreq); err != nil {
+		return err
+	}
+	if err := decryptProcessKeyfiles(src, dreq); err != nil {
+		return err
+	}
+	if err := decryptVerifyAuth(src, dreq); err != nil {
+		return err
+	}
+	if err := decryptCheckKeyfiles(src, dreq); err != nil {
+		return err
+	}
+	if err := decryptInitCipher(src); err != nil {
+		return err
+	}
+
+	// Set up a v2 encryption that mirrors the v1 settings
+	ereq := &EncryptRequest{
+		InputFile:      path,
+		OutputFile:     path,
+		Password:       creds.Password,
+		MaxKeyfiles:    -1, // Already checked when opening the v1 volume
+		KeyfileOrdered: src.Header.Flags.KeyfileOrdered,
+		keptComments:   src.Header.Comments,
+		Paranoid:       src.Header.Flags.Paranoid,
+		ReedSolomon:    src.Header.Flags.ReedSolomon,
+		Reporter:       reporter,
+		RSCodecs:       rsCodecs,
+	}
+	if src.UseKeyfiles {
+		ereq.Keyfiles = creds.Keyfiles
+	}
+	dst := NewEncryptContext(ctx, ereq)
+	defer dst.Close()
+	dst.InputFile = path
+
+	if err := migrateEncryptSetup(dst, ereq, src); err != nil {
+		cleanupEncrypt(dst, ereq)
+		return err
+	}
+	if err := migratePayload(src, dst, dreq, ereq); err != nil {
+		cleanupEncrypt(dst, ereq)
+		return err
+	}
+
+	// Only replace the original once the whole v1 payload has authenticated
+	src.SetStatus("Comparing values...")
+	if subtle.ConstantTimeCompare(src.CipherSuite.Sum(), src.Header.AuthTag) != 1 {
+		cleanupEncrypt(dst, ereq)
+		return perrors.ErrCorruptData
+	}
+
+	if err := encryptFinalize(dst, ereq); err != nil {
+		cleanupEncrypt(dst, ereq)
+		return err
+	}
+
+	log.Info("migration completed successfully")
+	return nil
+}
+
+// migrateEncryptSetup runs the v2 encryption phases up to the payload.
+// The padded flag is copied from the v1 header because the plaintext size
+// is only known after decoding; both versions compute it the same way.
+func migrateEncryptSetup(dst *OperationContext, ereq *EncryptRequest, src *OperationContext) error {
+	if err := encryptGenerateValues(dst, ereq); err != nil {
+		return err
+	}
+	dst.Padded = src.Header.Flags.Padded
+	dst.Header.Flags.Padded = src.Header.Flags.Padded
+	dst.Total = src.Total
+
+	if err := encryptWriteHeader(dst, ereq); err != nil {
+		return err
+	}
+	if err := encryptDeriveKeys(dst, ereq); err != nil {
+		return err
+	}
+	if err := encryptProcessKeyfiles(dst, ereq); err != nil {
+		return err
+	}
+	if err := encryptComputeAuth(dst, ereq); err != nil {
+		return err
+	}
+	return encryptInitCipher(dst, ereq)
+}
+
+// migratePayload streams the v1 payload through the v1 cipher suite and
+// straight into the v2 cipher suite, appending the result to the new volume.
+func migratePayload(src, dst *OperationContext, dreq *DecryptRequest, ereq *EncryptRequest) error {
+	fin, err := os.Open(src.InputFile)
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer func() { _ = fin.Close() }()
+
+	if _, err := fin.Seek(int64(header.HeaderSize(len(src.Header.Comments))), 0); err != nil {
+		return fmt.Errorf("seek past header: %w", err)
+	}
+
+	fout, err := os.OpenFile(ereq.OutputFile+".incomplete", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	defer func() { _ = fout.Close() }()
+
+	if src.Reporter != nil {
+		src.Reporter.SetCanCancel(true)
+	}
+	startTime := time.Now()
+	var done int64
+	var counter int64
+
+	reedsolo := src.Header.Flags.ReedSolomon
+	pad := finalPadOf(src.Header.Flags)
+
+	srcBufSize := util.MiB
+	if reedsolo {
+		srcBufSize = util.MiB / encoding.RS128DataSize * encoding.RS128EncodedSize
+	}
+	buf := make([]byte, srcBufSize)
+	plain := util.GetMiBBuffer()
+	defer util.PutMiBBuffer(plain)
+	defer crypto.SecureZero(plain)
+	out := util.GetMiBBuffer()
+	defer util.PutMiBBuffer(out)
+
+	for {
+		if src.IsCancelled() {
+			return src.CancellationError()
+		}
+
+		n, readErr := fin.Read(buf)
+		if n > 0 {
+			data := buf[:n]
+			if reedsolo {
+				data, _, err = decodeWithRSFast(data, dreq.RSCodecs, done+int64(n) >= src.Total, pad, false, false)
+				if err != nil {
+					return err
+				}
+			}
+
+			// v1 ciphertext -> plaintext (in memory only) -> v2 ciphertext
+			plainData := plain[:len(data)]
+			src.CipherSuite.Decrypt(plainData, data)
+			outData := out[:len(data)]
+			dst.CipherSuite.Encrypt(outData, plainData)
+
+			writeData := outData
+			if reedsolo {
+				writeData = encodeWithRS(outData, ereq.RSCodecs)
+			}
+			if _, err := fout.Write(writeData); err != nil {
+				return fmt.Errorf("write ciphertext: %w", err)
+			}
+
+			done += int64(n)
+			counter += int64(len(data))
+
+			progress, speed, eta := util.Statify(done, src.Total, startTime)
+			src.UpdateProgress(progress, fmt.Sprintf("%.2f%%", progress*100))
+			src.SetRate("Migrating", speed, eta)
+
+			// Rekey every 60 GiB; both sides see the same plaintext length
+			if counter >= crypto.RekeyThreshold {
+				if err := src.CipherSuite.Rekey(); err != nil {
+					return err
+				}
+				if err := dst.CipherSuite.Rekey(); err != nil {
+					return err
+				}
+				counter = 0
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("read input: %w", readErr)
+		}
+	}
+
+	if err := fout.Sync(); err != nil {
+		return fmt.Errorf("sync output: %w", err)
+	}
+	return nil
+}
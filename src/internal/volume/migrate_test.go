@@ -0,0 +1,220 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/crypto"
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/util"
+)
+
+// writeV1Volume writes plaintext as a legacy v1 volume (password only),
+// following the original Picocrypt v1.49 layout: SHA3-512(key) in the
+// header and no header subkey in the HKDF stream.
+func writeV1Volume(t *testing.T, path, password string, plaintext []byte, comments string, reedSolomon bool, rs *encoding.RSCodecs) {
+	t.Helper()
+
+	salt, _ := crypto.RandomBytes(header.SaltSize)
+	hkdfSalt, _ := crypto.RandomBytes(header.HKDFSaltSize)
+	serpentIV, _ := crypto.RandomBytes(header.SerpentIVSize)
+	nonce, _ := crypto.RandomBytes(header.NonceSize)
+
+	hdr := header.NewVolumeHeader(salt, hkdfSalt, serpentIV, nonce)
+	hdr.Version = "v1.49"
+	hdr.Comments = comments
+	hdr.Flags = header.Flags{
+		ReedSolomon: reedSolomon,
+		Padded:      int64(len(plaintext))%int64(util.MiB) >= int64(util.MiB)-encoding.RS128DataSize,
+	}
+
+	key, err := crypto.DeriveKey([]byte(password), salt, false)
+	if err != nil {
+		t.Fatalf("DeriveKey failed: %v", err)
+	}
+	subkeys := crypto.NewSubkeyReader(crypto.NewHKDFStream(key, hkdfSalt))
+	macSubkey, _ := subkeys.MACSubkey()
+	serpentKey, _ := subkeys.SerpentKey()
+	mac, err := crypto.NewMAC(macSubkey, false)
+	if err != nil {
+		t.Fatalf("NewMAC failed: %v", err)
+	}
+	cs, err := crypto.NewCipherSuite(key, nonce, serpentKey, serpentIV, mac, subkeys.Reader(), false)
+	if err != nil {
+		t.Fatalf("NewCipherSuite failed: %v", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create v1 volume: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := header.NewWriter(f, rs).WriteHeader(hdr); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+
+	for off := 0; off < len(plaintext); off += util.MiB {
+		chunk := plaintext[off:min(off+util.MiB, len(plaintext))]
+		enc := make([]byte, len(chunk))
+		cs.Encrypt(enc, chunk)
+		if reedSolomon {
+			enc = encodeWithRS(enc, rs)
+		}
+		if _, err := f.Write(enc); err != nil {
+			t.Fatalf("Failed to write payload: %v", err)
+		}
+	}
+
+	err = header.WriteAuthValues(f, header.AuthValuesOffset(len(comments)),
+		header.ComputeV1KeyHash(key), make([]byte, header.KeyfileHashSize), cs.Sum(), rs)
+	if err != nil {
+		t.Fatalf("WriteAuthValues failed: %v", err)
+	}
+}
+
+// TestMigrateV1ToV2 tests that a v1 volume is converted to v2 in place and
+// still decrypts to the same plaintext with the original password
+func TestMigrateV1ToV2(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	for _, reedSolomon := range []bool{false, true} {
+		name := "plain"
+		if reedSolomon {
+			name = "reed_solomon"
+		}
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			password := "legacy_password"
+			comments := "migrated from v1"
+
+			// Span more than one block to exercise the streaming loop
+			plaintext := make([]byte, util.MiB+util.MiB/2)
+			for i := range plaintext {
+				plaintext[i] = byte(i * 7)
+			}
+
+			volumePath := filepath.Join(tmpDir, "legacy.bin.pcv")
+			writeV1Volume(t, volumePath, password, plaintext, comments, reedSolomon, rsCodecs)
+
+			if err := Migrate(context.Background(), volumePath, Credentials{Password: password}, &GoldenTestReporter{}); err != nil {
+				t.Fatalf("Migrate failed: %v", err)
+			}
+
+			if _, err := os.Stat(volumePath + ".incomplete"); !os.IsNotExist(err) {
+				t.Error("Migration left an .incomplete file behind")
+			}
+
+			// The header should now be v2 with the original settings
+			f, err := os.Open(volumePath)
+			if err != nil {
+				t.Fatalf("Failed to open migrated volume: %v", err)
+			}
+			result, err := header.NewReader(f, rsCodecs).ReadHeader()
+			_ = f.Close()
+			if err != nil {
+				t.Fatalf("ReadHeader failed: %v", err)
+			}
+			hdr := result.Header
+			if hdr.IsLegacyV1() || hdr.Version != header.CurrentVersion {
+				t.Errorf("Version = %q; want %q", hdr.Version, header.CurrentVersion)
+			}
+			if hdr.Comments != comments {
+				t.Errorf("Comments = %q; want %q", hdr.Comments, comments)
+			}
+			if hdr.Flags.ReedSolomon != reedSolomon {
+				t.Errorf("ReedSolomon = %v; want %v", hdr.Flags.ReedSolomon, reedSolomon)
+			}
+
+			// And decrypt to the same plaintext with the original password
+			decryptedPath := filepath.Join(tmpDir, "legacy.bin")
+			err = Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  volumePath,
+				OutputFile: decryptedPath,
+				Password:   password,
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Decrypt after migration failed: %v", err)
+			}
+			decrypted, err := os.ReadFile(decryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read decrypted file: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Error("Decrypted content does not match original plaintext")
+			}
+		})
+	}
+}
+
+// TestMigrateWrongPassword tests that a failed migration leaves the original untouched
+func TestMigrateWrongPassword(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	volumePath := filepath.Join(tmpDir, "legacy.pcv")
+	writeV1Volume(t, volumePath, "right_password", []byte("legacy data"), "", false, rsCodecs)
+
+	original, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+
+	err = Migrate(context.Background(), volumePath, Credentials{Password: "wrong_password"}, nil)
+	if err == nil {
+		t.Fatal("Migrate should fail with the wrong password")
+	}
+
+	after, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to read volume after migration: %v", err)
+	}
+	if !bytes.Equal(original, after) {
+		t.Error("Original volume was modified by a failed migration")
+	}
+}
+
+// TestMigrateRejectsV2 tests that an already-current volume is reported as such
+func TestMigrateRejectsV2(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "current.txt")
+	if err := os.WriteFile(inputPath, []byte("already v2"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := inputPath + ".pcv"
+
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "current_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	err = Migrate(context.Background(), volumePath, Credentials{Password: "current_password"}, nil)
+	if !errors.Is(err, perrors.ErrNotLegacyVolume) {
+		t.Errorf("Expected ErrNotLegacyVolume, got: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package volume
+
+import (
+	"errors"
+	"io"
+
+	"Picocrypt-NG/internal/crypto"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/keyfile"
+)
+
+// nameKeyFunc returns the key sealing the name manifest of an archive
+// created with EncryptRequest.EncryptNames. It is expanded with HKDF from
+// the volume key, with the keyfile key already XORed in as for the payload,
+// and the salt stored in the manifest, so the names need exactly the
+// credentials (or recovery key) that open the volume. Decryption applies
+// the keyfile key to ctx.Key itself; encryptNameKey applies it here.
+func nameKeyFunc(key []byte) fileops.NameKeyFunc {
+	return func(salt []byte) ([]byte, error) {
+		if key == nil {
+			return nil, errors.New("name key: volume key not derived")
+		}
+		nameKey := make([]byte, crypto.Argon2KeySize)
+		if _, err := io.ReadFull(crypto.NewHKDFStream(key, salt), nameKey); err != nil {
+			return nil, err
+		}
+		return nameKey, nil
+	}
+}
+
+// encryptNameKey derives the volume key ahead of the archive, which needs it
+// to seal the entry names, and returns the name key for salt. The later
+// phases reuse the salts and keys.
+func encryptNameKey(ctx *OperationContext, req *EncryptRequest, salt []byte) ([]byte, error) {
+	if err := encryptGenerateSalts(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := encryptDeriveKeys(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := encryptProcessKeyfiles(ctx, req); err != nil {
+		return nil, err
+	}
+	ctx.SetStatus("Compressing files...")
+
+	key := ctx.Key
+	if ctx.UseKeyfiles && ctx.KeyfileKey != nil {
+		key = keyfile.XORWithKey(ctx.Key, ctx.KeyfileKey)
+		defer crypto.SecureZero(key)
+	}
+	return nameKeyFunc(key)(salt)
+}
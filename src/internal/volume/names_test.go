@@ -0,0 +1,182 @@
+package volume
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/fileops"
+)
+
+func TestEncryptNames(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	folder := filepath.Join(tmpDir, "confidential")
+	if err := os.MkdirAll(filepath.Join(folder, "payroll"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	contents := map[string][]byte{
+		"confidential/merger plan.txt":      []byte("first file"),
+		"confidential/payroll/salaries.csv": bytes.Repeat([]byte("name,amount\n"), 100),
+	}
+	var inputFiles []string
+	for name, data := range contents {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		inputFiles = append(inputFiles, path)
+	}
+
+	volumePath := filepath.Join(tmpDir, "confidential.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles:   inputFiles,
+		OnlyFolders:  []string{folder},
+		OutputFile:   volumePath,
+		Password:     "names_password",
+		PreserveDirs: true,
+		EncryptNames: true,
+		Reporter:     &GoldenTestReporter{},
+		RSCodecs:     rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if err := os.RemoveAll(folder); err != nil {
+		t.Fatalf("Failed to remove input folder: %v", err)
+	}
+
+	t.Run("intermediate_zip_is_opaque", func(t *testing.T) {
+		zipPath := filepath.Join(t.TempDir(), "confidential.zip")
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: zipPath,
+			Password:   "names_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+
+		raw, err := os.ReadFile(zipPath)
+		if err != nil {
+			t.Fatalf("Failed to read zip: %v", err)
+		}
+		for _, leak := range []string{"confidential", "merger", "payroll", "salaries"} {
+			if bytes.Contains(raw, []byte(leak)) {
+				t.Errorf("decrypted zip contains %q", leak)
+			}
+		}
+
+		reader, err := zip.OpenReader(zipPath)
+		if err != nil {
+			t.Fatalf("Failed to open zip: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		for _, f := range reader.File {
+			if f.Name != fileops.NameManifestEntry && len(strings.TrimSuffix(f.Name, "/")) != 8 {
+				t.Errorf("entry name %q is not opaque", f.Name)
+			}
+		}
+	})
+
+	t.Run("auto_unzip_restores_names", func(t *testing.T) {
+		outDir := t.TempDir()
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: filepath.Join(outDir, "confidential.zip"),
+			Password:   "names_password",
+			AutoUnzip:  true,
+			SameLevel:  true,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		for name, want := range contents {
+			got, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+			if err != nil {
+				t.Errorf("%s not restored: %v", name, err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s content mismatch", name)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outDir, fileops.NameManifestEntry)); !os.IsNotExist(err) {
+			t.Error("name manifest was extracted")
+		}
+	})
+
+	t.Run("extract_by_real_name", func(t *testing.T) {
+		var out bytes.Buffer
+		err := ExtractFile(context.Background(), &DecryptRequest{
+			InputFile: volumePath,
+			Password:  "names_password",
+			Reporter:  &GoldenTestReporter{},
+			RSCodecs:  rsCodecs,
+		}, "confidential/payroll/salaries.csv", &out)
+		if err != nil {
+			t.Fatalf("ExtractFile failed: %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), contents["confidential/payroll/salaries.csv"]) {
+			t.Error("extracted content mismatch")
+		}
+	})
+}
+
+// TestEncryptNamesKeyfileOnly tests that names are sealed under the volume
+// key, so a volume protected by keyfiles alone can use EncryptNames
+func TestEncryptNamesKeyfileOnly(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "secret name.txt")
+	keyfilePath := filepath.Join(tmpDir, "key")
+	for _, path := range []string{inputPath, keyfilePath} {
+		if err := os.WriteFile(path, []byte("data "+filepath.Base(path)), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	volumePath := filepath.Join(tmpDir, "a.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles:   []string{inputPath},
+		OutputFile:   volumePath,
+		Keyfiles:     []string{keyfilePath},
+		Compress:     true,
+		EncryptNames: true,
+		Reporter:     &GoldenTestReporter{},
+		RSCodecs:     rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = ExtractFile(context.Background(), &DecryptRequest{
+		InputFile: volumePath,
+		Keyfiles:  []string{keyfilePath},
+		Reporter:  &GoldenTestReporter{},
+		RSCodecs:  rsCodecs,
+	}, "secret name.txt", &out)
+	if err != nil {
+		t.Fatalf("ExtractFile failed: %v", err)
+	}
+	if out.String() != "data secret name.txt" {
+		t.Errorf("extracted %q; want the original content", out.String())
+	}
+}
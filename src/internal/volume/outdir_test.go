@@ -0,0 +1,77 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// TestCreateOutputDirs tests that missing output directories are created
+// only on request, and otherwise fail with ErrOutputDirMissing rather than a
+// generic write error
+func TestCreateOutputDirs(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "input.txt")
+	if err := os.WriteFile(input, []byte("nested output directories"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(tmpDir, "out", "sub")
+
+	req := &EncryptRequest{
+		InputFile:  input,
+		OutputFile: filepath.Join(outDir, "input.txt.pcv"),
+		Password:   "mkdir_password",
+		Split:      true,
+		ChunkSize:  3,
+		ChunkUnit:  fileops.SplitUnitTotal,
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}
+	if err := req.Validate(); !errors.Is(err, perrors.ErrOutputDirMissing) {
+		t.Errorf("Validate: expected ErrOutputDirMissing, got %v", err)
+	}
+	if err := Encrypt(context.Background(), req); !errors.Is(err, perrors.ErrOutputDirMissing) {
+		t.Errorf("Encrypt: expected ErrOutputDirMissing, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "out")); !os.IsNotExist(err) {
+		t.Error("a directory was created without CreateOutputDirs")
+	}
+
+	req.CreateOutputDirs = true
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate with CreateOutputDirs: %v", err)
+	}
+	if err := Encrypt(context.Background(), req); err != nil {
+		t.Fatalf("Encrypt with CreateOutputDirs: %v", err)
+	}
+	for i := range 3 {
+		chunk := fmt.Sprintf("%s.%d", req.OutputFile, i)
+		if _, err := os.Stat(chunk); err != nil {
+			t.Errorf("chunk %d missing: %v", i, err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		for _, dir := range []string{outDir, filepath.Dir(outDir)} {
+			info, err := os.Stat(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm&0077 != 0 {
+				t.Errorf("%s created with mode %v, want owner-only", dir, perm)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package volume
+
+import (
+	"path/filepath"
+	"strings"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/header"
+)
+
+// DecryptOutputName returns the default output path for decrypting the
+// volume at inputFile, next to the volume.
+//
+// If hdr carries a name stored with StoreOriginalName, that name is used.
+// Otherwise the split chunk suffix and exactly one trailing ".pcv" are
+// removed, so "a.tar.pcv" gives "a.tar", "a.zip.pcv" gives "a.zip" and
+// "a.pcv" gives "a"; the inner extensions are never interpreted. A name
+// that does not end in ".pcv", or is nothing but ".pcv", gets ".decrypted"
+// appended instead. An armored copy's ".pcv.asc" counts as ".pcv". hdr may
+// be nil, e.g. for a deniable volume whose header cannot be read yet.
+func DecryptOutputName(inputFile string, hdr *header.VolumeHeader) string {
+	base := splitVolumeBase(inputFile)
+	if strings.HasSuffix(base, ".pcv"+encoding.ArmorExt) {
+		base = strings.TrimSuffix(base, encoding.ArmorExt)
+	}
+	if hdr != nil {
+		if name := hdr.OriginalName(); name != "" {
+			return filepath.Join(filepath.Dir(base), name)
+		}
+	}
+	if !strings.HasSuffix(base, ".pcv") || filepath.Base(base) == ".pcv" {
+		return base + ".decrypted"
+	}
+	return strings.TrimSuffix(base, ".pcv")
+}
+
+// originalName returns the name StoreOriginalName records: that of the file
+// the volume decrypts to. It must run after encryptPreprocess.
+func originalName(ctx *OperationContext, req *EncryptRequest) string {
+	if ctx.TempZipInUse {
+		output := req.OutputFile
+		if ctx.PublishPath != "" {
+			output = ctx.PublishPath
+		}
+		return filepath.Base(strings.TrimSuffix(output, ".pcv"))
+	}
+	return filepath.Base(ctx.InputFile)
+}
@@ -0,0 +1,218 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/header"
+)
+
+// TestDecryptOutputName tests that exactly one trailing .pcv is stripped and
+// inner extensions are left alone
+func TestDecryptOutputName(t *testing.T) {
+	dir := filepath.Join("some", "dir")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a.tar.pcv", "a.tar"},
+		{"a.jpg.pcv", "a.jpg"},
+		{"a.pcv", "a"},
+		{"a.zip.pcv", "a.zip"},
+		{"a.tar.gz.pcv", "a.tar.gz"},
+		{"a.pcv.pcv", "a.pcv"},
+		{"a.tar.pcv.3", "a.tar"},
+		{"a.bin", "a.bin.decrypted"},
+		{"a.tar.pcv.asc", "a.tar"},
+		{"a.asc", "a.asc.decrypted"},
+		{".pcv", ".pcv.decrypted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := DecryptOutputName(filepath.Join(dir, tt.input), nil)
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("DecryptOutputName(%q) = %q; want %q", tt.input, got, want)
+			}
+		})
+	}
+
+	// A stored name wins over the volume's own name
+	comments, err := header.EncodeOriginalName("notes", "photo.jpg")
+	if err != nil {
+		t.Fatalf("EncodeOriginalName failed: %v", err)
+	}
+	hdr := &header.VolumeHeader{Comments: comments}
+	if got, want := DecryptOutputName(filepath.Join(dir, "renamed.pcv"), hdr), filepath.Join(dir, "photo.jpg"); got != want {
+		t.Errorf("with stored name: got %q; want %q", got, want)
+	}
+
+	// A stored path is never followed out of the volume's directory
+	hdr = &header.VolumeHeader{Comments: "notes\x1d../../etc/passwd"}
+	if got, want := DecryptOutputName(filepath.Join(dir, "evil.pcv"), hdr), filepath.Join(dir, "evil"); got != want {
+		t.Errorf("with stored path: got %q; want %q", got, want)
+	}
+}
+
+// TestStoreOriginalNameRoundTrip tests that a renamed volume created with
+// StoreOriginalName decrypts back to the original name
+func TestStoreOriginalNameRoundTrip(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := []byte("original name round trip")
+	inputPath := filepath.Join(tmpDir, "backup.tar.gz")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	keyfile := filepath.Join(tmpDir, "key.bin")
+	if err := os.WriteFile(keyfile, []byte("keyfile"), 0644); err != nil {
+		t.Fatalf("Failed to write keyfile: %v", err)
+	}
+
+	volumePath := filepath.Join(tmpDir, "renamed.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:         inputPath,
+		OutputFile:        volumePath,
+		Password:          "name_password",
+		Keyfiles:          []string{keyfile},
+		Comments:          "nightly",
+		StoreOriginalName: true,
+		StoreKeyfileNames: true,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if err := os.Remove(inputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to open volume: %v", err)
+	}
+	result, err := header.NewReader(f, rsCodecs).ReadHeader()
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	hdr := result.Header
+	if hdr.UserComments() != "nightly" {
+		t.Errorf("UserComments() = %q; want %q", hdr.UserComments(), "nightly")
+	}
+	if names := hdr.KeyfileNames(); len(names) != 1 || names[0] != "key.bin" {
+		t.Errorf("KeyfileNames() = %q; want [key.bin]", names)
+	}
+
+	outputPath := DecryptOutputName(volumePath, hdr)
+	if outputPath != inputPath {
+		t.Fatalf("DecryptOutputName = %q; want %q", outputPath, inputPath)
+	}
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:  volumePath,
+		OutputFile: outputPath,
+		Password:   "name_password",
+		Keyfiles:   []string{keyfile},
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("decrypted content does not match")
+	}
+}
+
+// TestEncryptOutputName tests that OutputName is used as given, with the
+// extension for the kind of input, and that OutputFile is used without it
+func TestEncryptOutputName(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	folder := filepath.Join(tmpDir, "photos")
+	if err := os.Mkdir(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte("content of "+name), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		files = append(files, path)
+	}
+
+	outDir := t.TempDir()
+	tests := []struct {
+		name string
+		req  EncryptRequest
+		want string
+	}{
+		{"single file", EncryptRequest{
+			InputFile:  files[0],
+			OutputName: filepath.Join(outDir, "nightly-single"),
+		}, "nightly-single.pcv"},
+		{"multiple files", EncryptRequest{
+			InputFiles: files,
+			OnlyFiles:  files,
+			OutputName: filepath.Join(outDir, "nightly-multi"),
+		}, "nightly-multi.zip.pcv"},
+		{"folder", EncryptRequest{
+			InputFiles:  files,
+			OnlyFolders: []string{folder},
+			OutputName:  filepath.Join(outDir, "nightly-folder"),
+		}, "nightly-folder.zip.pcv"},
+		{"timestamp default", EncryptRequest{
+			InputFiles:  files,
+			OnlyFolders: []string{folder},
+			OutputFile:  filepath.Join(outDir, "encrypted-1700000000.zip.pcv"),
+		}, "encrypted-1700000000.zip.pcv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Password = "name_password"
+			req.Reporter = &GoldenTestReporter{}
+			req.RSCodecs = rsCodecs
+			want := filepath.Join(outDir, tt.want)
+			if got := req.OutputPath(); got != want {
+				t.Errorf("OutputPath() = %q; want %q", got, want)
+			}
+			if err := Encrypt(context.Background(), &req); err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			if req.OutputFile != want {
+				t.Errorf("OutputFile = %q; want %q", req.OutputFile, want)
+			}
+			if _, err := os.Stat(want); err != nil {
+				t.Errorf("volume not written to %s: %v", want, err)
+			}
+		})
+	}
+
+	// The override replaces OutputFile entirely
+	req := EncryptRequest{
+		InputFile:  files[0],
+		OutputFile: filepath.Join(outDir, "ignored.pcv"),
+		OutputName: filepath.Join(outDir, "chosen"),
+	}
+	if got, want := req.OutputPath(), filepath.Join(outDir, "chosen.pcv"); got != want {
+		t.Errorf("OutputPath() with both set = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/util"
+)
+
+// TestExplicitPadding tests that a stored pad length round-trips at the
+// sizes where the Padded heuristic switches, MiB-128 from a block boundary
+func TestExplicitPadding(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	mib := int64(util.MiB)
+	sizes := []struct {
+		size   int64
+		padLen uint8
+	}{
+		{0, 0},
+		{100, 28},
+		{mib - 129, 1},
+		{mib - 128, 128}, // Legacy Padded starts here
+		{mib - 127, 127},
+		{mib - 1, 1},
+		{mib, 0},
+		{2*mib - 128, 128},
+	}
+	for _, tt := range sizes {
+		t.Run(fmt.Sprintf("size=%d", tt.size), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			// Data ending in what looks like a one-byte pad
+			plaintext := bytes.Repeat([]byte{0x01}, int(tt.size))
+			inputPath := filepath.Join(tmpDir, "input.bin")
+			if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+				t.Fatal(err)
+			}
+			volumePath := inputPath + ".pcv"
+			err := Encrypt(context.Background(), &EncryptRequest{
+				InputFile:       inputPath,
+				OutputFile:      volumePath,
+				Password:        "padding_password",
+				ReedSolomon:     true,
+				ExplicitPadding: true,
+				Reporter:        &GoldenTestReporter{},
+				RSCodecs:        rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			fin, err := os.Open(volumePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, _, err := header.Parse(fin, rsCodecs)
+			_ = fin.Close()
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !h.Flags.ExplicitPadding || h.Flags.PadLen != tt.padLen || h.Flags.Padded {
+				t.Errorf("flags = %+v; want ExplicitPadding with PadLen %d", h.Flags, tt.padLen)
+			}
+
+			outputPath := filepath.Join(tmpDir, "output.bin")
+			err = Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  volumePath,
+				OutputFile: outputPath,
+				Password:   "padding_password",
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			decrypted, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("decrypted %d bytes; want %d", len(decrypted), len(plaintext))
+			}
+		})
+	}
+
+	// Without Reed-Solomon nothing is padded and nothing is stored
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "plain.bin")
+	if err := os.WriteFile(inputPath, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	req := &EncryptRequest{
+		InputFile:       inputPath,
+		OutputFile:      inputPath + ".pcv",
+		Password:        "padding_password",
+		ExplicitPadding: true,
+		Reporter:        &GoldenTestReporter{},
+		RSCodecs:        rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); err != nil {
+		t.Fatalf("Encrypt without Reed-Solomon failed: %v", err)
+	}
+	fin, err := os.Open(req.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = fin.Close() }()
+	if h, _, err := header.Parse(fin, rsCodecs); err != nil || h.Flags.ExplicitPadding {
+		t.Errorf("without Reed-Solomon: flags %+v, err %v", h.Flags, err)
+	}
+}
+
+// TestExplicitPaddingInputChanged tests that a stored pad length computed
+// for one size is never written for another
+func TestExplicitPaddingInputChanged(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "growing.log")
+	if err := os.WriteFile(inputPath, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testHookBeforePayload = func(string) {
+		f, err := os.OpenFile(inputPath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Errorf("Failed to open input: %v", err)
+			return
+		}
+		_, _ = f.Write([]byte("appended"))
+		_ = f.Close()
+	}
+	t.Cleanup(func() { testHookBeforePayload = nil })
+
+	req := &EncryptRequest{
+		InputFile:       inputPath,
+		OutputFile:      inputPath + ".pcv",
+		Password:        "padding_password",
+		ReedSolomon:     true,
+		ExplicitPadding: true,
+		Reporter:        &GoldenTestReporter{},
+		RSCodecs:        rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); !errors.Is(err, perrors.ErrInputChangedDuringRead) {
+		t.Fatalf("expected ErrInputChangedDuringRead, got %v", err)
+	}
+	for _, path := range []string{req.OutputFile, req.OutputFile + ".incomplete"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", path)
+		}
+	}
+}
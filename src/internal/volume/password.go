@@ -0,0 +1,40 @@
+package volume
+
+import "fmt"
+
+// resolvePassword returns password, or if it is empty the one fn supplies.
+// It runs once, before anything is written, so a password store that is
+// locked or missing fails the operation cleanly.
+func resolvePassword(password string, fn func() (string, error)) (string, error) {
+	if fn == nil || password != "" {
+		return password, nil
+	}
+	pw, err := fn()
+	if err != nil {
+		return "", fmt.Errorf("fetch password: %w", err)
+	}
+	return pw, nil
+}
+
+// withPassword returns req, or a copy of it holding the password from
+// PasswordFunc, so a fetched password is never left in the caller's request.
+func (req *EncryptRequest) withPassword() (*EncryptRequest, error) {
+	password, err := resolvePassword(req.Password, req.PasswordFunc)
+	if err != nil || password == req.Password {
+		return req, err
+	}
+	r := *req
+	r.Password = password
+	return &r, nil
+}
+
+// withPassword is EncryptRequest.withPassword for decryption.
+func (req *DecryptRequest) withPassword() (*DecryptRequest, error) {
+	password, err := resolvePassword(req.Password, req.PasswordFunc)
+	if err != nil || password == req.Password {
+		return req, err
+	}
+	r := *req
+	r.Password = password
+	return &r, nil
+}
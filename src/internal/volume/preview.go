@@ -0,0 +1,116 @@
+package volume
+
+import (
+	"context"
+	"crypto/cipher"
+	"fmt"
+	"io"
+
+	"Picocrypt-NG/internal/crypto"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/keyfile"
+	"Picocrypt-NG/internal/log"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+// previewInfo separates the preview key from the volume subkeys, which come
+// from the same key and HKDF salt without info.
+var previewInfo = []byte("Picocrypt-NG preview")
+
+// newPreviewAEAD derives the preview cipher from the volume key, after the
+// keyfile key has been XORed in, and the volume's HKDF salt.
+func newPreviewAEAD(key, hkdfSalt []byte) (cipher.AEAD, error) {
+	previewKey := make([]byte, chacha20poly1305.KeySize)
+	defer crypto.SecureZero(previewKey)
+	if _, err := io.ReadFull(hkdf.New(sha3.New256, key, hkdfSalt, previewInfo), previewKey); err != nil {
+		return nil, perrors.ErrHKDFFailure
+	}
+	return chacha20poly1305.NewX(previewKey)
+}
+
+// encryptWritePreview seals req.PreviewData and writes it after the header
+// and block table. encryptGenerateValues has already sized ctx.Preview so
+// that the payload offset is known before the keys are.
+func encryptWritePreview(ctx *OperationContext, req *EncryptRequest, fout io.WriterAt) error {
+	key := ctx.Key
+	if ctx.UseKeyfiles && ctx.KeyfileKey != nil {
+		key = keyfile.XORWithKey(ctx.Key, ctx.KeyfileKey)
+		defer crypto.SecureZero(key)
+	}
+	aead, err := newPreviewAEAD(key, ctx.Header.HKDFSalt)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := crypto.RandomBytes(aead.NonceSize())
+	if err != nil {
+		return err
+	}
+	sealed := aead.Seal(nonce, nonce, req.PreviewData, nil)
+	if len(sealed) != len(ctx.Preview) {
+		return fmt.Errorf("preview changed size during encryption")
+	}
+	ctx.Preview = sealed
+	return header.WritePreview(fout, ctx.PreviewOffset(), sealed, req.RSCodecs)
+}
+
+// ReadPreview returns the preview stored with EncryptRequest.PreviewData.
+// The credentials are checked against the header and the preview is
+// authenticated, but the payload is neither read nor decrypted, so this is
+// cheap apart from the key derivation. Only InputFile, the credentials, AAD,
+// Reporter and RSCodecs are used, and ForceDecrypt is ignored; split and
+// deniable volumes must be recombined or unwrapped first.
+//
+// Volumes without a preview return ErrNoPreview; a preview that fails to
+// authenticate returns ErrCorruptData.
+func ReadPreview(ctx context.Context, req *DecryptRequest) ([]byte, error) {
+	// Never force past a failed check: the preview key must come from
+	// verified credentials
+	strict := *req
+	strict.ForceDecrypt = false
+	req = &strict
+	req, err := req.withPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	opCtx := NewDecryptContext(ctx, req)
+	defer opCtx.Close() // Secure zeroing of key material
+	opCtx.InputFile = req.InputFile
+
+	log.Info("reading preview", log.String("input", req.InputFile))
+
+	if err := decryptReadHeader(opCtx, req); err != nil {
+		return nil, err
+	}
+	if !opCtx.Header.Flags.Preview || opCtx.IsLegacyV1 {
+		return nil, perrors.ErrNoPreview
+	}
+	if err := decryptDeriveKeys(opCtx, req); err != nil {
+		return nil, err
+	}
+	if err := decryptProcessKeyfiles(opCtx, req); err != nil {
+		return nil, err
+	}
+	if err := decryptVerifyAuth(opCtx, req); err != nil {
+		return nil, err
+	}
+	if err := decryptCheckKeyfiles(opCtx, req); err != nil {
+		return nil, err
+	}
+
+	aead, err := newPreviewAEAD(opCtx.Key, opCtx.Header.HKDFSalt)
+	if err != nil {
+		return nil, err
+	}
+	nonce, sealed := opCtx.Preview[:aead.NonceSize()], opCtx.Preview[aead.NonceSize():]
+	preview, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: preview does not authenticate", perrors.ErrCorruptData)
+	}
+	return preview, nil
+}
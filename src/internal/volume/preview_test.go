@@ -0,0 +1,184 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+)
+
+func TestReadPreview(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := bytes.Repeat([]byte("full size photo "), 4096)
+	inputPath := filepath.Join(tmpDir, "photo.jpg")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	preview := make([]byte, 3000)
+	if _, err := rand.Read(preview); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		reedSolomon bool
+		blockHashes bool
+	}{
+		{"plain", false, false},
+		{"reed-solomon and block hashes", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			volumePath := filepath.Join(t.TempDir(), "photo.jpg.pcv")
+			err := Encrypt(context.Background(), &EncryptRequest{
+				InputFile:   inputPath,
+				OutputFile:  volumePath,
+				Password:    "preview_password",
+				ReedSolomon: tt.reedSolomon,
+				BlockHashes: tt.blockHashes,
+				PreviewData: preview,
+				Reporter:    &GoldenTestReporter{},
+				RSCodecs:    rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			volume, err := os.ReadFile(volumePath)
+			if err != nil {
+				t.Fatalf("Failed to read volume: %v", err)
+			}
+			if bytes.Contains(volume, preview[:64]) {
+				t.Error("preview is stored in the clear")
+			}
+
+			got, err := ReadPreview(context.Background(), &DecryptRequest{
+				InputFile: volumePath,
+				Password:  "preview_password",
+				Reporter:  &GoldenTestReporter{},
+				RSCodecs:  rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("ReadPreview failed: %v", err)
+			}
+			if !bytes.Equal(got, preview) {
+				t.Error("preview does not round-trip")
+			}
+
+			// The payload still decrypts normally
+			outputPath := filepath.Join(t.TempDir(), "photo.jpg")
+			err = Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  volumePath,
+				OutputFile: outputPath,
+				Password:   "preview_password",
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			decrypted, err := os.ReadFile(outputPath)
+			if err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("decrypted payload differs from the input (err: %v)", err)
+			}
+
+			// The wrong password reads nothing
+			got, err = ReadPreview(context.Background(), &DecryptRequest{
+				InputFile:    volumePath,
+				Password:     "wrong_password",
+				ForceDecrypt: true,
+				Reporter:     &GoldenTestReporter{},
+				RSCodecs:     rsCodecs,
+			})
+			if err == nil || got != nil {
+				t.Errorf("ReadPreview with the wrong password returned %d bytes, err %v", len(got), err)
+			}
+
+			// A damaged preview does not authenticate
+			damaged := bytes.Clone(volume)
+			offset := header.HeaderSize(0) + header.PreviewLenEncSize + 100
+			if tt.blockHashes {
+				offset += int(header.BlockTableSize(1))
+			}
+			damaged[offset] ^= 0x01
+			damagedPath := filepath.Join(t.TempDir(), "damaged.pcv")
+			if err := os.WriteFile(damagedPath, damaged, 0644); err != nil {
+				t.Fatalf("Failed to write damaged volume: %v", err)
+			}
+			_, err = ReadPreview(context.Background(), &DecryptRequest{
+				InputFile: damagedPath,
+				Password:  "preview_password",
+				Reporter:  &GoldenTestReporter{},
+				RSCodecs:  rsCodecs,
+			})
+			if !errors.Is(err, perrors.ErrCorruptData) {
+				t.Errorf("expected ErrCorruptData for a damaged preview, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadPreviewWithoutPreview(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "plain.txt")
+	if err := os.WriteFile(inputPath, []byte("no preview"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "plain.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "preview_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	_, err = ReadPreview(context.Background(), &DecryptRequest{
+		InputFile: volumePath,
+		Password:  "preview_password",
+		RSCodecs:  rsCodecs,
+	})
+	if !errors.Is(err, perrors.ErrNoPreview) {
+		t.Errorf("expected ErrNoPreview, got %v", err)
+	}
+
+	// Previews above the cap are refused before anything is written
+	req := &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  filepath.Join(tmpDir, "big.pcv"),
+		Password:    "preview_password",
+		PreviewData: make([]byte, header.MaxPreviewSize+1),
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	}
+	var verr *perrors.ValidationError
+	if err := req.Validate(); !errors.As(err, &verr) {
+		t.Errorf("Validate: expected ValidationError, got: %v", err)
+	}
+	if err := Encrypt(context.Background(), req); !errors.As(err, &verr) {
+		t.Errorf("Encrypt: expected ValidationError, got: %v", err)
+	}
+	if _, err := os.Stat(req.OutputFile + ".incomplete"); !os.IsNotExist(err) {
+		t.Error("an oversized preview should not leave an output behind")
+	}
+}
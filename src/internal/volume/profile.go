@@ -0,0 +1,61 @@
+package volume
+
+import (
+	"fmt"
+
+	"Picocrypt-NG/internal/errors"
+)
+
+// SecurityProfile bundles the encryption options that trade speed for
+// robustness, so callers can pick an intent instead of individual flags.
+type SecurityProfile int
+
+const (
+	ProfileFast     SecurityProfile = iota // Standard mode, no error correction
+	ProfileBalanced                        // Standard mode with Reed-Solomon
+	ProfileMaximum                         // Paranoid mode, Reed-Solomon and post-write verification
+)
+
+// String returns the lowercase profile name.
+func (p SecurityProfile) String() string {
+	switch p {
+	case ProfileFast:
+		return "fast"
+	case ProfileBalanced:
+		return "balanced"
+	case ProfileMaximum:
+		return "maximum"
+	default:
+		return fmt.Sprintf("SecurityProfile(%d)", int(p))
+	}
+}
+
+// ApplyProfile sets the options a profile dictates on req:
+//
+//	           Paranoid  ReedSolomon  VerifyAfterEncrypt
+//	Fast       false     false        (unchanged)
+//	Balanced   false     true         (unchanged)
+//	Maximum    true      true         true
+//
+// Paranoid mode also selects the stronger Argon2 parameters (8 passes).
+// A profile only ever turns verification on, so a caller that asked for it,
+// or needs it to delete zipped inputs, keeps it. Every other field, such as
+// Compress, Deniability, Split or keyfile settings, is left as the caller
+// set it.
+func ApplyProfile(req *EncryptRequest, profile SecurityProfile) error {
+	switch profile {
+	case ProfileFast:
+		req.Paranoid = false
+		req.ReedSolomon = false
+	case ProfileBalanced:
+		req.Paranoid = false
+		req.ReedSolomon = true
+	case ProfileMaximum:
+		req.Paranoid = true
+		req.ReedSolomon = true
+		req.VerifyAfterEncrypt = true
+	default:
+		return errors.NewValidationError("SecurityProfile", fmt.Sprintf("unknown profile %d", int(profile)))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// TestApplyProfile tests that each profile sets the fields it dictates and
+// only ever turns verification on
+func TestApplyProfile(t *testing.T) {
+	tests := []struct {
+		profile     SecurityProfile
+		paranoid    bool
+		reedSolomon bool
+		verify      bool // Forces VerifyAfterEncrypt on; otherwise it is kept
+	}{
+		{ProfileFast, false, false, false},
+		{ProfileBalanced, false, true, false},
+		{ProfileMaximum, true, true, true},
+	}
+
+	for _, tt := range tests {
+		for _, verify := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/verify=%v", tt.profile, verify), func(t *testing.T) {
+				// Start from the opposite of every dictated value
+				req := &EncryptRequest{
+					Paranoid:           !tt.paranoid,
+					ReedSolomon:        !tt.reedSolomon,
+					VerifyAfterEncrypt: verify,
+				}
+				if err := ApplyProfile(req, tt.profile); err != nil {
+					t.Fatalf("ApplyProfile failed: %v", err)
+				}
+				if req.Paranoid != tt.paranoid {
+					t.Errorf("Paranoid = %v; want %v", req.Paranoid, tt.paranoid)
+				}
+				if req.ReedSolomon != tt.reedSolomon {
+					t.Errorf("ReedSolomon = %v; want %v", req.ReedSolomon, tt.reedSolomon)
+				}
+				if want := verify || tt.verify; req.VerifyAfterEncrypt != want {
+					t.Errorf("VerifyAfterEncrypt = %v; want %v", req.VerifyAfterEncrypt, want)
+				}
+			})
+		}
+	}
+}
+
+// TestApplyProfileKeepsOtherFields tests that fields outside a profile are not overridden
+func TestApplyProfileKeepsOtherFields(t *testing.T) {
+	for _, profile := range []SecurityProfile{ProfileFast, ProfileBalanced, ProfileMaximum} {
+		t.Run(profile.String(), func(t *testing.T) {
+			req := &EncryptRequest{
+				Password:       "profile_password",
+				Comments:       "kept",
+				Compress:       true,
+				Deniability:    true,
+				KeyfileOrdered: true,
+				Split:          true,
+				ChunkSize:      5,
+			}
+			if err := ApplyProfile(req, profile); err != nil {
+				t.Fatalf("ApplyProfile failed: %v", err)
+			}
+			if req.Password != "profile_password" || req.Comments != "kept" {
+				t.Error("Credentials or comments were changed")
+			}
+			if !req.Compress || !req.Deniability || !req.KeyfileOrdered {
+				t.Error("Compress, Deniability or KeyfileOrdered was overridden")
+			}
+			if !req.Split || req.ChunkSize != 5 {
+				t.Error("Split settings were overridden")
+			}
+		})
+	}
+}
+
+// TestApplyProfileUnknown tests that an unknown profile is rejected without changes
+func TestApplyProfileUnknown(t *testing.T) {
+	req := &EncryptRequest{Paranoid: true}
+	err := ApplyProfile(req, SecurityProfile(99))
+
+	var validationErr *perrors.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got: %v", err)
+	}
+	if !req.Paranoid {
+		t.Error("Unknown profile modified the request")
+	}
+}
@@ -0,0 +1,130 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/util"
+)
+
+// inputSection is a byte range of the input encrypted as one volume.
+type inputSection struct {
+	offset, length int64
+}
+
+// validateRecordSplit rejects RecordSplit settings that cannot work: the
+// input is cut at record boundaries before encryption, so it must be one
+// file read as is, and byte splitting would cut records again.
+func validateRecordSplit(req *EncryptRequest) error {
+	switch {
+	case req.RecordSplit == 0:
+		return nil
+	case req.RecordSplit < 0:
+		return perrors.NewValidationError("RecordSplit", "must be positive")
+	case needsZip(req) || len(req.InputFiles) > 1:
+		return perrors.NewValidationError("RecordSplit", "needs a single input file")
+	case req.Split:
+		return perrors.NewValidationError("RecordSplit", "cannot be combined with Split")
+	}
+	return nil
+}
+
+// recordVolumePath returns the path of the i-th RecordSplit volume:
+// output with its .pcv suffix replaced by .<i>.pcv. The index goes before
+// .pcv so the volumes are not taken for Split chunks.
+func recordVolumePath(output string, i int) string {
+	return strings.TrimSuffix(output, ".pcv") + "." + strconv.Itoa(i) + ".pcv"
+}
+
+// encryptRecords encrypts each group of req.RecordSplit records as its own
+// volume with the request's other options. A failure removes the volumes
+// already written; DeleteInputs is applied only after the last one.
+func encryptRecords(ctx context.Context, req *EncryptRequest) error {
+	if err := validateRecordSplit(req); err != nil {
+		return err
+	}
+	input := req.InputFile
+	if len(req.InputFiles) == 1 {
+		input = req.InputFiles[0]
+	}
+	if err := checkRegularFile(fileSystem(req.FS), input); err != nil {
+		return err
+	}
+	delim := req.RecordDelimiter
+	if delim == 0 {
+		delim = '\n'
+	}
+	sections, err := recordSections(fileSystem(req.FS), input, req.RecordSplit, delim)
+	if err != nil {
+		return err
+	}
+
+	output := req.OutputPath()
+	var written []string
+	for i, section := range sections {
+		sub := *req
+		sub.RecordSplit = 0
+		sub.OutputName = ""
+		sub.OutputFile = recordVolumePath(output, i)
+		sub.DeleteInputs = req.DeleteInputs && i == len(sections)-1
+		if err := encrypt(ctx, &sub, &section); err != nil {
+			for _, path := range written {
+				_ = fileSystem(req.FS).Remove(path)
+			}
+			return fmt.Errorf("record volume %d: %w", i, err)
+		}
+		written = append(written, producedOutputs(&sub)...)
+	}
+	req.OutputFile = output
+	return nil
+}
+
+// recordSections scans path on fs for delim and returns the byte ranges
+// holding n records each; the last may hold fewer, and an empty input gives
+// one empty range. The input is streamed, so records may be of any size.
+func recordSections(fs FileSystem, path string, n int, delim byte) ([]inputSection, error) {
+	fin, err := fs.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	defer func() { _ = fin.Close() }()
+
+	buf := util.GetMiBBuffer()
+	defer util.PutMiBBuffer(buf)
+
+	var sections []inputSection
+	var start, pos int64
+	records := 0
+	for {
+		read, err := fin.Read(buf)
+		chunk := buf[:read]
+		for {
+			i := bytes.IndexByte(chunk, delim)
+			if i < 0 {
+				break
+			}
+			chunk = chunk[i+1:]
+			if records++; records == n {
+				end := pos + int64(read-len(chunk))
+				sections = append(sections, inputSection{start, end - start})
+				start, records = end, 0
+			}
+		}
+		pos += int64(read)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read input: %w", err)
+		}
+	}
+	if pos > start || len(sections) == 0 {
+		sections = append(sections, inputSection{start, pos - start})
+	}
+	return sections, nil
+}
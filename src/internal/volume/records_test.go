@@ -0,0 +1,161 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// TestRecordSplit tests that each record volume decrypts to whole lines and
+// that the decrypted volumes concatenate back to the input
+func TestRecordSplit(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	var input bytes.Buffer
+	for i := range 250 {
+		fmt.Fprintf(&input, "%d %s\n", i, bytes.Repeat([]byte("log "), i%17))
+	}
+	input.WriteString("final record without newline")
+	inputPath := filepath.Join(tmpDir, "app.log")
+	if err := os.WriteFile(inputPath, input.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  inputPath + ".pcv",
+		Password:    "records_password",
+		RecordSplit: 100,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := os.Stat(req.OutputFile); !os.IsNotExist(err) {
+		t.Errorf("%s written besides the record volumes", req.OutputFile)
+	}
+
+	// 251 records in groups of 100
+	wantLines := []int{100, 100, 51}
+	var joined []byte
+	for i, want := range wantLines {
+		volumePath := recordVolumePath(req.OutputFile, i)
+		outputPath := filepath.Join(tmpDir, fmt.Sprintf("part%d", i))
+		if err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: outputPath,
+			Password:   "records_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}); err != nil {
+			t.Fatalf("Decrypt %s failed: %v", volumePath, err)
+		}
+		part, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := bytes.Count(part, []byte("\n"))
+		last := i == len(wantLines)-1
+		if last {
+			lines++ // the unterminated final record
+		} else if !bytes.HasSuffix(part, []byte("\n")) {
+			t.Errorf("volume %d does not end on a record boundary", i)
+		}
+		if lines != want {
+			t.Errorf("volume %d holds %d records; want %d", i, lines, want)
+		}
+		joined = append(joined, part...)
+	}
+	if _, err := os.Stat(recordVolumePath(req.OutputFile, len(wantLines))); !os.IsNotExist(err) {
+		t.Error("more record volumes than expected")
+	}
+	if !bytes.Equal(joined, input.Bytes()) {
+		t.Error("concatenated volumes do not reproduce the input")
+	}
+}
+
+func TestRecordSections(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		n     int
+		delim byte
+		want  []inputSection
+	}{
+		{"empty", "", 2, '\n', []inputSection{{0, 0}}},
+		{"exact", "a\nb\nc\nd\n", 2, '\n', []inputSection{{0, 4}, {4, 4}}},
+		{"remainder", "a\nb\nc\n", 2, '\n', []inputSection{{0, 4}, {4, 2}}},
+		{"unterminated", "a\nb\nc", 2, '\n', []inputSection{{0, 4}, {4, 1}}},
+		{"custom delimiter", "one;two;three;", 1, ';', []inputSection{{0, 4}, {4, 4}, {8, 6}}},
+		{"no delimiter", "single record", 5, '\n', []inputSection{{0, 13}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "input")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := recordSections(osFS{}, path, tt.n, tt.delim)
+			if err != nil {
+				t.Fatalf("recordSections failed: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("recordSections = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordSplitValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "a.log")
+	if err := os.WriteFile(inputPath, []byte("line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(req *EncryptRequest)
+	}{
+		{"negative", func(req *EncryptRequest) { req.RecordSplit = -1 }},
+		{"with split", func(req *EncryptRequest) { req.Split, req.ChunkSize = true, 1 }},
+		{"compressed", func(req *EncryptRequest) { req.InputFiles, req.Compress = []string{inputPath}, true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &EncryptRequest{
+				InputFile:   inputPath,
+				OutputFile:  inputPath + ".pcv",
+				Password:    "records_password",
+				RecordSplit: 10,
+				Reporter:    &GoldenTestReporter{},
+			}
+			tt.setup(req)
+			var verr *perrors.ValidationError
+			if err := req.Validate(); !errors.As(err, &verr) || verr.Field != "RecordSplit" {
+				t.Errorf("Validate: expected RecordSplit ValidationError, got: %v", err)
+			}
+			// Encrypt checks too, before writing anything
+			if err := Encrypt(context.Background(), req); !errors.As(err, &verr) || verr.Field != "RecordSplit" {
+				t.Errorf("Encrypt: expected RecordSplit ValidationError, got: %v", err)
+			}
+			if _, err := os.Stat(recordVolumePath(req.OutputFile, 0)); !os.IsNotExist(err) {
+				t.Error("Encrypt should not have written anything")
+			}
+		})
+	}
+}
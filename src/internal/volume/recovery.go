@@ -0,0 +1,160 @@
+package volume
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"slices"
+
+	"Picocrypt-NG/internal/crypto"
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+// RecoveryKeySize is the size of the X25519 keys GenerateRecoveryKey
+// returns.
+const RecoveryKeySize = 32
+
+// recoveryInfo separates the key wrapping cipher from other HKDF uses.
+var recoveryInfo = []byte("Picocrypt-NG recovery")
+
+// GenerateRecoveryKey returns a new X25519 key pair for key escrow. The
+// public key goes into EncryptRequest.RecoveryRecipient; the private key,
+// kept by the recovery agent, into DecryptRequest.RecoveryKey.
+func GenerateRecoveryKey() (privateKey, publicKey []byte, err error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", perrors.ErrRandFailure, err)
+	}
+	return priv.Bytes(), priv.PublicKey().Bytes(), nil
+}
+
+// DecryptWithRecoveryKey decrypts the volume at volumePath to out with the
+// private key matching its RecoveryRecipient, skipping Argon2 and needing
+// neither the password nor the keyfiles. As with DecryptRequest.Output, the
+// MAC is checked once the whole payload has been written, so out holds
+// trustworthy plaintext only if nil is returned.
+//
+// Volumes created without a recipient fail with ErrNoRecoveryKey before
+// anything is written, and a key that does not match fails with
+// ErrAuthFailed whatever the password is, without revealing anything
+// about it.
+func DecryptWithRecoveryKey(ctx context.Context, volumePath string, recoveryKey []byte, out io.Writer) error {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return err
+	}
+	return Decrypt(ctx, &DecryptRequest{
+		InputFile:   volumePath,
+		RecoveryKey: recoveryKey,
+		Output:      out,
+		Reporter:    nopReporter{},
+		RSCodecs:    rsCodecs,
+	})
+}
+
+// newRecoveryAEAD derives the wrapping cipher from an X25519 shared secret,
+// bound to the ephemeral and recipient public keys. The key is never
+// reused, so a zero nonce is safe.
+func newRecoveryAEAD(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	defer crypto.SecureZero(wrapKey)
+	salt := append(slices.Clone(ephemeral), recipient...)
+	if _, err := io.ReadFull(hkdf.New(sha3.New256, shared, salt, recoveryInfo), wrapKey); err != nil {
+		return nil, perrors.ErrHKDFFailure
+	}
+	return chacha20poly1305.New(wrapKey)
+}
+
+// wrapRecoveryKeys seals the Argon2 key and the keyfile key (zeros without
+// keyfiles) to recipient: an ephemeral X25519 public key followed by the
+// sealed keys. Both are needed, as v2 derives the subkeys before the
+// keyfile key is XORed in.
+func wrapRecoveryKeys(recipient, key, keyfileKey []byte) ([]byte, error) {
+	pub, err := ecdh.X25519().NewPublicKey(recipient)
+	if err != nil {
+		return nil, perrors.NewValidationError("RecoveryRecipient", "not an X25519 public key")
+	}
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", perrors.ErrRandFailure, err)
+	}
+	shared, err := eph.ECDH(pub)
+	if err != nil {
+		return nil, perrors.NewValidationError("RecoveryRecipient", "not a usable X25519 public key")
+	}
+	defer crypto.SecureZero(shared)
+
+	ephPub := eph.PublicKey().Bytes()
+	aead, err := newRecoveryAEAD(shared, ephPub, recipient)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]byte, 2*RecoveryKeySize)
+	defer crypto.SecureZero(keys)
+	copy(keys, key)
+	copy(keys[RecoveryKeySize:], keyfileKey)
+
+	wrapped := aead.Seal(ephPub, make([]byte, aead.NonceSize()), keys, nil)
+	if len(wrapped) != header.RecoveryWrapSize {
+		return nil, fmt.Errorf("wrapped keys are %d bytes, not %d", len(wrapped), header.RecoveryWrapSize)
+	}
+	return wrapped, nil
+}
+
+// unwrapRecoveryKeys opens the keys wrapRecoveryKeys sealed. A private key
+// that does not match the recipient fails as ErrAuthFailed.
+func unwrapRecoveryKeys(privateKey, wrapped []byte) (key, keyfileKey []byte, err error) {
+	priv, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, perrors.NewValidationError("RecoveryKey", "not an X25519 private key")
+	}
+	ephPub := wrapped[:RecoveryKeySize]
+	eph, err := ecdh.X25519().NewPublicKey(ephPub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", perrors.ErrCorruptHeader, err)
+	}
+	shared, err := priv.ECDH(eph)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", perrors.ErrCorruptHeader, err)
+	}
+	defer crypto.SecureZero(shared)
+
+	aead, err := newRecoveryAEAD(shared, ephPub, priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := aead.Open(nil, make([]byte, aead.NonceSize()), wrapped[RecoveryKeySize:], nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: recovery key does not match the volume", perrors.ErrAuthFailed)
+	}
+	return keys[:RecoveryKeySize], keys[RecoveryKeySize:], nil
+}
+
+// decryptRecoverKeys replaces key derivation and keyfile processing when
+// DecryptRequest.RecoveryKey is set. The header MAC is then checked with
+// the recovered keys as usual, against the stored keyfile hash.
+func decryptRecoverKeys(ctx *OperationContext, req *DecryptRequest) error {
+	if !ctx.Header.Flags.Recovery || ctx.IsLegacyV1 {
+		return perrors.ErrNoRecoveryKey
+	}
+	ctx.SetStatus("Recovering key...")
+	key, keyfileKey, err := unwrapRecoveryKeys(req.RecoveryKey, ctx.Header.RecoveryWrap)
+	if err != nil {
+		return err
+	}
+	ctx.Key = key
+	ctx.KeyfileHash = ctx.Header.KeyfileHash
+	if ctx.UseKeyfiles {
+		ctx.KeyfileKey = keyfileKey
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+)
+
+func TestRecoveryRecipient(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	privateKey, publicKey, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := bytes.Repeat([]byte("escrowed "), 50000)
+	inputPath := filepath.Join(tmpDir, "report.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	keyfilePath := filepath.Join(tmpDir, "key.bin")
+	if err := os.WriteFile(keyfilePath, []byte("recovery keyfile"), 0644); err != nil {
+		t.Fatalf("Failed to write keyfile: %v", err)
+	}
+
+	// Every section between the header and the payload is present
+	volumePath := filepath.Join(tmpDir, "report.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:         inputPath,
+		OutputFile:        volumePath,
+		Password:          "recovery_password",
+		Keyfiles:          []string{keyfilePath},
+		ReedSolomon:       true,
+		BlockHashes:       true,
+		PreviewData:       []byte("thumbnail"),
+		RecoveryRecipient: publicKey,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	decrypt := func(req *DecryptRequest) ([]byte, error) {
+		req.InputFile = volumePath
+		req.OutputFile = filepath.Join(t.TempDir(), "report.txt")
+		req.Reporter = &GoldenTestReporter{}
+		req.RSCodecs = rsCodecs
+		if err := Decrypt(context.Background(), req); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(req.OutputFile)
+	}
+
+	// The credentials still work
+	got, err := decrypt(&DecryptRequest{Password: "recovery_password", Keyfiles: []string{keyfilePath}})
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("password decrypt failed: %v", err)
+	}
+
+	// So does the recovery key alone
+	got, err = decrypt(&DecryptRequest{RecoveryKey: privateKey})
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("recovery key decrypt failed: %v", err)
+	}
+	preview, err := ReadPreview(context.Background(), &DecryptRequest{
+		InputFile:   volumePath,
+		RecoveryKey: privateKey,
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil || string(preview) != "thumbnail" {
+		t.Errorf("ReadPreview with the recovery key: %q, %v", preview, err)
+	}
+
+	// Another recovery key does not open it
+	otherKey, _, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey failed: %v", err)
+	}
+	if _, err := decrypt(&DecryptRequest{RecoveryKey: otherKey}); !errors.Is(err, perrors.ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for the wrong recovery key, got %v", err)
+	}
+
+	// Nothing in the volume names the recipient
+	volume, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+	if bytes.Contains(volume, publicKey) {
+		t.Error("recipient public key is stored in the volume")
+	}
+}
+
+func TestRecoveryWithoutRecipient(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	privateKey, publicKey, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "plain.txt")
+	if err := os.WriteFile(inputPath, []byte("no recovery"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	// Bad recipients are refused before anything is written
+	var verr *perrors.ValidationError
+	for name, req := range map[string]*EncryptRequest{
+		"short key":   {RecoveryRecipient: publicKey[:31]},
+		"deniability": {RecoveryRecipient: publicKey, Deniability: true},
+		"cdc dedup":   {RecoveryRecipient: publicKey, CDCDedup: true},
+	} {
+		req.InputFile = inputPath
+		req.OutputFile = filepath.Join(tmpDir, "bad.pcv")
+		req.Password = "recovery_password"
+		req.RSCodecs = rsCodecs
+		if err := req.Validate(); !errors.As(err, &verr) {
+			t.Errorf("%s: Validate: expected ValidationError, got: %v", name, err)
+		}
+		if err := Encrypt(context.Background(), req); !errors.As(err, &verr) {
+			t.Errorf("%s: Encrypt: expected ValidationError, got: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "bad.pcv.incomplete")); !os.IsNotExist(err) {
+		t.Error("a refused recipient should not leave an output behind")
+	}
+
+	volumePath := filepath.Join(tmpDir, "plain.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "recovery_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:   volumePath,
+		OutputFile:  filepath.Join(tmpDir, "out.txt"),
+		RecoveryKey: privateKey,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if !errors.Is(err, perrors.ErrNoRecoveryKey) {
+		t.Errorf("expected ErrNoRecoveryKey for a volume without recovery, got %v", err)
+	}
+	var out bytes.Buffer
+	err = DecryptWithRecoveryKey(context.Background(), volumePath, privateKey, &out)
+	if !errors.Is(err, perrors.ErrNoRecoveryKey) || out.Len() != 0 {
+		t.Errorf("DecryptWithRecoveryKey: expected ErrNoRecoveryKey and no output, got %v and %d bytes", err, out.Len())
+	}
+}
+
+// TestRecoveryEncryptNames tests that the recovery key alone restores the
+// real names of an archive created with EncryptNames
+func TestRecoveryEncryptNames(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	privateKey, publicKey, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "board minutes.txt")
+	keyfilePath := filepath.Join(tmpDir, "key.bin")
+	for _, path := range []string{inputPath, keyfilePath} {
+		if err := os.WriteFile(path, []byte("content of "+filepath.Base(path)), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	volumePath := filepath.Join(tmpDir, "minutes.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles:        []string{inputPath},
+		OutputFile:        volumePath,
+		Password:          "recovery_password",
+		Keyfiles:          []string{keyfilePath},
+		Compress:          true,
+		EncryptNames:      true,
+		RecoveryRecipient: publicKey,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	outDir := t.TempDir()
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:   volumePath,
+		OutputFile:  filepath.Join(outDir, "minutes.zip"),
+		RecoveryKey: privateKey,
+		AutoUnzip:   true,
+		SameLevel:   true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Decrypt with the recovery key failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(outDir, "board minutes.txt"))
+	if err != nil {
+		t.Fatalf("real name not restored: %v", err)
+	}
+	if string(got) != "content of board minutes.txt" {
+		t.Errorf("restored content = %q", got)
+	}
+}
+
+func TestDecryptWithRecoveryKey(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	privateKey, publicKey, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := bytes.Repeat([]byte("escrowed "), 50000)
+	inputPath := filepath.Join(tmpDir, "ledger.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "ledger.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:         inputPath,
+		OutputFile:        volumePath,
+		Password:          "recovery_password",
+		Paranoid:          true,
+		RecoveryRecipient: publicKey,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := DecryptWithRecoveryKey(context.Background(), volumePath, privateKey, &out); err != nil {
+		t.Fatalf("DecryptWithRecoveryKey failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Error("recovered plaintext differs from the input")
+	}
+
+	// A wrong key fails before any output and says nothing about the
+	// password, which is never tried
+	otherKey, _, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey failed: %v", err)
+	}
+	out.Reset()
+	err = DecryptWithRecoveryKey(context.Background(), volumePath, otherKey, &out)
+	if !errors.Is(err, perrors.ErrAuthFailed) {
+		t.Fatalf("expected ErrAuthFailed for the wrong recovery key, got %v", err)
+	}
+	var authErr *header.AuthError
+	if errors.As(err, &authErr) || strings.Contains(strings.ToLower(err.Error()), "password") {
+		t.Errorf("wrong recovery key error mentions the password: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrong recovery key wrote %d bytes", out.Len())
+	}
+}
@@ -0,0 +1,279 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/keyfile"
+	"Picocrypt-NG/internal/log"
+	"Picocrypt-NG/internal/util"
+)
+
+// RekeyParams are the settings Rekey re-encrypts a volume with. Everything
+// else is carried over from the volume.
+type RekeyParams struct {
+	Paranoid    bool // Paranoid mode: 8 Argon2 passes, Serpent-CTR + XChaCha20, HMAC-SHA3
+	ReedSolomon bool // Reed-Solomon error correction on the payload
+
+	// Argon2Threads and TargetDerivationTime are as for EncryptRequest;
+	// left at 0, the volume's thread and pass counts are kept.
+	Argon2Threads        int
+	TargetDerivationTime time.Duration
+}
+
+// Rekey re-encrypts the volume at path in place with new parameters, for
+// example to move an archive to paranoid mode or Reed-Solomon.
+//
+// The volume is decrypted into a pipe that feeds the new encryption, so
+// plaintext never touches the disk. The new volume is written next to the
+// original and renamed over it only after the old payload MAC has been
+// verified; on any error the original is left untouched. Both key
+// derivations run at once, so memory use peaks at about 2 GiB.
+//
+// Comments, keyfile use and ordering, the keyfile hash, block hashes,
+// explicit padding, the Argon2 thread and pass counts and the format
+// trailer are preserved unless params overrides them; fresh salts and nonces are generated,
+// and the same credentials open the new volume. Volumes with a preview, a
+// recovery key or CDC chunks fail with ErrRekeyUnsupported, as do split
+// and deniable volumes, which must be recombined or unwrapped first.
+// reporter may be nil.
+func Rekey(ctx context.Context, path string, creds Credentials, params RekeyParams, reporter ProgressReporter) error {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return err
+	}
+	return rekey(ctx, path, creds, params, reporter, rsCodecs)
+}
+
+func rekey(ctx context.Context, path string, creds Credentials, params RekeyParams, reporter ProgressReporter, rsCodecs *encoding.RSCodecs) error {
+	if reporter == nil {
+		reporter = nopReporter{}
+	}
+	log.Info("starting re-key", log.String("input", path))
+
+	// Read the header for the settings to carry over and the plaintext size
+	dreq := &DecryptRequest{
+		InputFile:   path,
+		Password:    creds.Password,
+		Keyfiles:    creds.Keyfiles,
+		Pepper:      creds.Pepper,
+		AAD:         creds.AAD,
+		MaxKeyfiles: creds.MaxKeyfiles,
+		Reporter:    nopReporter{},
+		RSCodecs:    rsCodecs,
+	}
+	src := NewDecryptContext(ctx, dreq)
+	defer src.Close()
+	src.InputFile = path
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat input: %w", err)
+	}
+	src.Total = stat.Size() - int64(header.BaseHeaderSize)
+	if err := decryptReadHeader(src, dreq); err != nil {
+		return err
+	}
+	flags := src.Header.Flags
+	if flags.Preview || flags.Recovery || flags.CDCDedup || flags.SplitLayout {
+		return fmt.Errorf("%w: %s has a preview, recovery key, CDC chunks or split layout",
+			perrors.ErrRekeyUnsupported, filepath.Base(path))
+	}
+	size, err := plaintextSize(src, rsCodecs)
+	if err != nil {
+		return err
+	}
+
+	ereq := &EncryptRequest{
+		InputFile:            filepath.Join(filepath.Dir(path), ".picocrypt-rekey-"+filepath.Base(path)),
+		OutputFile:           path,
+		Password:             creds.Password,
+		Pepper:               creds.Pepper,
+		AAD:                  creds.AAD,
+		MaxKeyfiles:          -1, // Already checked when opening the volume
+		KeyfileOrdered:       flags.KeyfileOrdered,
+		keptComments:         src.Header.Comments,
+		Paranoid:             params.Paranoid,
+		ReedSolomon:          params.ReedSolomon,
+		Argon2Threads:        params.Argon2Threads,
+		TargetDerivationTime: params.TargetDerivationTime,
+		BlockHashes:          flags.BlockHashes,
+		ExplicitPadding:      flags.ExplicitPadding,
+		Trailer:              flags.Trailer,
+		Reporter:             reporter,
+		RSCodecs:             rsCodecs,
+	}
+	if params.Argon2Threads == 0 {
+		ereq.Argon2Threads = int(flags.Threads)
+	}
+	if params.TargetDerivationTime == 0 {
+		ereq.keepPasses = flags.Passes
+	}
+	if flags.UseKeyfiles {
+		ereq.Keyfiles = creds.Keyfiles
+		if flags.KeyfileBLAKE2b {
+			ereq.KeyfileHash = keyfile.HashBLAKE2b
+		}
+	}
+
+	// Decrypt into the pipe; the encryption only sees EOF, and so only
+	// renames its output over the volume, once the old MAC has verified
+	pr, pw := io.Pipe()
+	ereq.FS = &rekeyFS{input: ereq.InputFile, size: size, r: pr}
+	decrypted := make(chan error, 1)
+	go func() {
+		dreq := *dreq
+		dreq.Output = pw
+		err := Decrypt(ctx, &dreq)
+		_ = pw.CloseWithError(err)
+		decrypted <- err
+	}()
+
+	err = Encrypt(ctx, ereq)
+	_ = pr.CloseWithError(errors.New("re-encryption stopped"))
+	if decErr := <-decrypted; decErr != nil && err != nil {
+		return decErr // The cause of the failed read
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("re-key completed successfully")
+	return nil
+}
+
+// plaintextSize returns the plaintext size of the volume opened in ctx. A
+// Reed-Solomon payload is decoded (without keys) in its final block to see
+// how much padding it carries.
+func plaintextSize(ctx *OperationContext, rsCodecs *encoding.RSCodecs) (int64, error) {
+	if !ctx.Header.Flags.ReedSolomon || ctx.Total == 0 {
+		return ctx.Total, nil
+	}
+	encBlock := int64(util.MiB / encoding.RS128DataSize * encoding.RS128EncodedSize)
+	blocks := (ctx.Total + encBlock - 1) / encBlock
+	last := make([]byte, ctx.Total-(blocks-1)*encBlock)
+
+	f, err := os.Open(ctx.InputFile)
+	if err != nil {
+		return 0, fmt.Errorf("open input: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.ReadAt(last, ctx.PayloadOffset()+(blocks-1)*encBlock); err != nil {
+		return 0, fmt.Errorf("read final block: %w", err)
+	}
+	data, _, err := decodeWithRSFast(last, rsCodecs, true, finalPadOf(ctx.Header.Flags), false, false)
+	if err != nil {
+		return 0, err
+	}
+	return (blocks-1)*int64(util.MiB) + int64(len(data)), nil
+}
+
+// rekeyFS is the OS, except that input reads the plaintext from a pipe.
+type rekeyFS struct {
+	osFS
+	input string
+	size  int64
+	r     *io.PipeReader
+}
+
+func (fs *rekeyFS) Open(name string) (File, error) {
+	if name == fs.input {
+		return &pipeFile{PipeReader: fs.r, name: name}, nil
+	}
+	return fs.osFS.Open(name)
+}
+
+func (fs *rekeyFS) Stat(name string) (os.FileInfo, error) {
+	if name == fs.input {
+		return bufferInfo{name: filepath.Base(name), size: fs.size}, nil
+	}
+	return fs.osFS.Stat(name)
+}
+
+// pipeFile is a read-only File reading from a pipe.
+type pipeFile struct {
+	*io.PipeReader
+	name string
+}
+
+var errPipeFile = errors.New("plaintext pipe is read-only")
+
+func (f *pipeFile) Write([]byte) (int, error)          { return 0, errPipeFile }
+func (f *pipeFile) WriteAt([]byte, int64) (int, error) { return 0, errPipeFile }
+func (f *pipeFile) Seek(int64, int) (int64, error)     { return 0, errPipeFile }
+func (f *pipeFile) Truncate(int64) error               { return errPipeFile }
+func (f *pipeFile) Name() string                       { return f.name }
+func (f *pipeFile) Sync() error                        { return nil }
+func (f *pipeFile) Close() error                       { return nil }
+
+// RekeyDirOptions controls RekeyDir.
+type RekeyDirOptions struct {
+	BatchOptions
+
+	// NewReporter, if set, returns the reporter for one volume, as for
+	// DecryptDirOptions.NewReporter.
+	NewReporter func(volume string) ProgressReporter
+
+	// CredentialsFor, if set, returns the current credentials for one
+	// volume in place of the shared ones, as for
+	// DecryptDirOptions.CredentialsFor. Every volume gets the same params.
+	CredentialsFor func(volume string) Credentials
+}
+
+// RekeyDir re-keys every volume fileops.FindVolumes finds under dir with
+// Rekey, using the same credentials (unless opts.CredentialsFor supplies
+// them per volume) and params. Volumes are run as a batch, so one failing
+// does not stop the others; the results come back in FindVolumes order
+// with OutputFile set to the volume itself and Status DirRekeyed on
+// success. Split and deniable volumes are reported as failed
+// with ErrRekeyUnsupported. The error is only set if dir cannot be scanned.
+func RekeyDir(ctx context.Context, dir string, creds Credentials, params RekeyParams, opts RekeyDirOptions) ([]DirResult, error) {
+	refs, err := fileops.FindVolumes(dir)
+	if err != nil {
+		return nil, err
+	}
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]DirResult, len(refs))
+	for i, ref := range refs {
+		results[i] = DirResult{Volume: ref, OutputFile: ref.Path}
+	}
+
+	batchOpts := opts.BatchOptions
+	batchOpts.OnDone = func(i int, err error) {
+		results[i].Status, results[i].Err = dirStatus(err), err
+		if err == nil {
+			results[i].Status = DirRekeyed
+		}
+		if opts.OnDone != nil {
+			opts.OnDone(i, err)
+		}
+	}
+	runBatch(ctx, results, batchOpts, func(ctx context.Context, res DirResult) error {
+		if res.Volume.Chunks != nil || res.Volume.Kind == fileops.VolumeDeniable {
+			return fmt.Errorf("%w: split and deniable volumes must be recombined or unwrapped first",
+				perrors.ErrRekeyUnsupported)
+		}
+		var reporter ProgressReporter
+		if opts.NewReporter != nil {
+			reporter = opts.NewReporter(res.Volume.Path)
+		}
+		volumeCreds := creds
+		if opts.CredentialsFor != nil {
+			volumeCreds = opts.CredentialsFor(res.Volume.Path)
+		}
+		return rekey(ctx, res.Volume.Path, volumeCreds, params, reporter, rsCodecs)
+	})
+	return results, nil
+}
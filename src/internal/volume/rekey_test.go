@@ -0,0 +1,273 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/util"
+)
+
+// TestRekeyDir tests re-keying a folder of volumes to paranoid mode with
+// Reed-Solomon, then decrypting each with the original credentials
+func TestRekeyDir(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping folder re-key in short mode")
+	}
+	// Each re-key runs two key derivations at once
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(2560 << 20))
+
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	srcDir := t.TempDir()
+	volDir := t.TempDir()
+	keyfile := filepath.Join(srcDir, "key.bin")
+	if err := os.WriteFile(keyfile, bytes.Repeat([]byte("rekey keyfile "), 100), 0644); err != nil {
+		t.Fatalf("Failed to write keyfile: %v", err)
+	}
+	const password = "rekey_password"
+	creds := Credentials{Password: password, Keyfiles: []string{keyfile}}
+
+	volumes := []struct {
+		name string
+		size int
+		opts func(req *EncryptRequest)
+	}{
+		{"plain.bin.pcv", util.MiB + util.MiB/2, nil},
+		{"nested/rs.bin.pcv", util.MiB + 1000, func(req *EncryptRequest) { req.ReedSolomon = true }},
+		{"keyed.bin.pcv", 5000, func(req *EncryptRequest) {
+			req.Keyfiles = []string{keyfile}
+			req.Comments = "archive 2019"
+		}},
+		{"empty.bin.pcv", 0, nil},
+	}
+	contents := make(map[string][]byte)
+	for i, v := range volumes {
+		data := make([]byte, v.size)
+		for j := range data {
+			data[j] = byte(j*7 + i)
+		}
+		input := filepath.Join(srcDir, filepath.Base(v.name))
+		if err := os.WriteFile(input, data, 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		output := filepath.Join(volDir, filepath.FromSlash(v.name))
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			t.Fatal(err)
+		}
+		req := &EncryptRequest{
+			InputFile:  input,
+			OutputFile: output,
+			Password:   password,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}
+		if v.opts != nil {
+			v.opts(req)
+		}
+		if err := Encrypt(context.Background(), req); err != nil {
+			t.Fatalf("Encrypt %s failed: %v", v.name, err)
+		}
+		contents[v.name] = data
+	}
+
+	// A deniable volume cannot be re-keyed and must be left alone
+	hiddenInput := filepath.Join(srcDir, "hidden.bin")
+	if err := os.WriteFile(hiddenInput, []byte("hidden"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hidden := filepath.Join(volDir, "hidden.bin.pcv")
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:   hiddenInput,
+		OutputFile:  hidden,
+		Password:    password,
+		Deniability: true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt hidden volume failed: %v", err)
+	}
+	hiddenBefore, err := os.ReadFile(hidden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RekeyDir(context.Background(), volDir, creds,
+		RekeyParams{Paranoid: true, ReedSolomon: true}, RekeyDirOptions{})
+	if err != nil {
+		t.Fatalf("RekeyDir failed: %v", err)
+	}
+	if len(results) != len(volumes)+1 {
+		t.Fatalf("RekeyDir returned %d results; want %d", len(results), len(volumes)+1)
+	}
+
+	for _, res := range results {
+		rel, _ := filepath.Rel(volDir, res.Volume.Path)
+		name := filepath.ToSlash(rel)
+		if name == "hidden.bin.pcv" {
+			if res.Status != DirFailed || !errors.Is(res.Err, perrors.ErrRekeyUnsupported) {
+				t.Errorf("hidden volume: status %v, err %v; want failed with ErrRekeyUnsupported", res.Status, res.Err)
+			}
+			if after, _ := os.ReadFile(hidden); !bytes.Equal(after, hiddenBefore) {
+				t.Error("hidden volume was modified")
+			}
+			continue
+		}
+		if res.Status != DirRekeyed || res.Err != nil {
+			t.Errorf("%s: status %v, err %v; want rekeyed", name, res.Status, res.Err)
+			continue
+		}
+		if _, err := os.Stat(res.Volume.Path + ".incomplete"); !os.IsNotExist(err) {
+			t.Errorf("%s: re-key left an .incomplete file behind", name)
+		}
+
+		hdr := readVolumeHeader(t, res.Volume.Path, rsCodecs)
+		if !hdr.Flags.Paranoid || !hdr.Flags.ReedSolomon {
+			t.Errorf("%s: Paranoid = %v, ReedSolomon = %v; want both set", name, hdr.Flags.Paranoid, hdr.Flags.ReedSolomon)
+		}
+		if name == "keyed.bin.pcv" && (!hdr.Flags.UseKeyfiles || hdr.Comments != "archive 2019") {
+			t.Errorf("%s: keyfiles %v, comments %q; want the originals kept", name, hdr.Flags.UseKeyfiles, hdr.Comments)
+		}
+
+		decrypted := filepath.Join(t.TempDir(), "out.bin")
+		req := &DecryptRequest{
+			InputFile:  res.Volume.Path,
+			OutputFile: decrypted,
+			Password:   password,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}
+		if hdr.Flags.UseKeyfiles {
+			req.Keyfiles = creds.Keyfiles
+		}
+		if err := Decrypt(context.Background(), req); err != nil {
+			t.Errorf("%s: Decrypt after re-key failed: %v", name, err)
+			continue
+		}
+		got, err := os.ReadFile(decrypted)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		if !bytes.Equal(got, contents[name]) {
+			t.Errorf("%s: plaintext differs after re-key (%d bytes, want %d)", name, len(got), len(contents[name]))
+		}
+	}
+}
+
+// TestRekeyWrongPassword tests that a failed re-key leaves the volume as it was
+func TestRekeyWrongPassword(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "data.txt")
+	if err := os.WriteFile(input, []byte("rekey me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	volumePath := input + ".pcv"
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  input,
+		OutputFile: volumePath,
+		Password:   "right_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	before, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Rekey(context.Background(), volumePath, Credentials{Password: "wrong_password"},
+		RekeyParams{ReedSolomon: true}, nil)
+	var authErr *header.AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected an authentication failure, got %v", err)
+	}
+	if after, _ := os.ReadFile(volumePath); !bytes.Equal(after, before) {
+		t.Error("volume was modified by a failed re-key")
+	}
+	if _, err := os.Stat(volumePath + ".incomplete"); !os.IsNotExist(err) {
+		t.Error("failed re-key left an .incomplete file behind")
+	}
+}
+
+// TestRekeyPepper tests that a peppered volume with AAD can be re-keyed and
+// that its Argon2 cost and explicit padding survive the new parameters
+func TestRekeyPepper(t *testing.T) {
+	// Each re-key runs two key derivations at once
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(2560 << 20))
+
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "data.txt")
+	plaintext := bytes.Repeat([]byte("peppered "), 1000)
+	if err := os.WriteFile(input, plaintext, 0644); err != nil {
+		t.Fatal(err)
+	}
+	creds := Credentials{
+		Password: "pepper_password",
+		Pepper:   []byte("hsm pepper"),
+		AAD:      []byte("tenant 42"),
+	}
+	volumePath := input + ".pcv"
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:       input,
+		OutputFile:      volumePath,
+		Password:        creds.Password,
+		Pepper:          creds.Pepper,
+		AAD:             creds.AAD,
+		Argon2Threads:   2,
+		ReedSolomon:     true,
+		ExplicitPadding: true,
+		Reporter:        &GoldenTestReporter{},
+		RSCodecs:        rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	if err := rekey(context.Background(), volumePath, creds, RekeyParams{ReedSolomon: true}, nil, rsCodecs); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+
+	hdr := readVolumeHeader(t, volumePath, rsCodecs)
+	if !hdr.Flags.Pepper || hdr.Flags.Threads != 2 || !hdr.Flags.ExplicitPadding {
+		t.Errorf("Pepper = %v, Threads = %d, ExplicitPadding = %v; want the originals kept",
+			hdr.Flags.Pepper, hdr.Flags.Threads, hdr.Flags.ExplicitPadding)
+	}
+
+	decrypted := filepath.Join(tmpDir, "out.txt")
+	if err := Decrypt(context.Background(), &DecryptRequest{
+		InputFile:  volumePath,
+		OutputFile: decrypted,
+		Password:   creds.Password,
+		Pepper:     creds.Pepper,
+		AAD:        creds.AAD,
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Decrypt after re-key failed: %v", err)
+	}
+	got, err := os.ReadFile(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("plaintext differs after re-key")
+	}
+}
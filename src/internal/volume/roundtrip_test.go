@@ -2,14 +2,26 @@ package volume
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"Picocrypt-NG/internal/crypto"
 	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/util"
+
+	"github.com/Picocrypt/infectious"
 )
 
 // TestRoundTripBasic tests basic encrypt -> decrypt cycle
@@ -201,6 +213,65 @@ func TestRoundTripReedSolomon(t *testing.T) {
 	t.Log("Round-trip Reed-Solomon: SUCCESS")
 }
 
+// TestRSCodecMismatch tests that codecs with a different geometry are refused
+// with ErrRSCodecMismatch before anything is decoded or written
+func TestRSCodecMismatch(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "rs_test.txt")
+	if err := os.WriteFile(inputPath, []byte("Reed-Solomon protected data"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := filepath.Join(tmpDir, "rs_test.txt.pcv")
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  encryptedPath,
+		Password:    "rs_password",
+		ReedSolomon: true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt (RS) failed: %v", err)
+	}
+
+	// A build whose payload codec carries more parity per chunk
+	reshaped, err := infectious.NewFEC(128, 144)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alternate := *rsCodecs
+	alternate.RS128 = reshaped
+
+	for _, deniability := range []bool{false, true} {
+		t.Run(fmt.Sprintf("deniability=%v", deniability), func(t *testing.T) {
+			decryptedPath := filepath.Join(t.TempDir(), "rs_decrypted.txt")
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:   encryptedPath,
+				OutputFile:  decryptedPath,
+				Password:    "rs_password",
+				Deniability: deniability,
+				Reporter:    &GoldenTestReporter{},
+				RSCodecs:    &alternate,
+			})
+			if !errors.Is(err, perrors.ErrRSCodecMismatch) {
+				t.Fatalf("Expected ErrRSCodecMismatch, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), "RS128") {
+				t.Errorf("Error should name the codec, got: %v", err)
+			}
+			for _, path := range []string{decryptedPath, decryptedPath + ".incomplete"} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s should not exist", path)
+				}
+			}
+		})
+	}
+}
+
 // TestRoundTripDeniability tests encrypt -> decrypt with deniability
 func TestRoundTripDeniability(t *testing.T) {
 	rsCodecs, err := encoding.NewRSCodecs()
@@ -675,6 +746,153 @@ func TestWrongKeyfileFails(t *testing.T) {
 	}
 }
 
+// TestKeyfileNamesInHeader tests that keyfile name hints round-trip through
+// the header, are covered by the header MAC, and are absent unless requested
+func TestKeyfileNamesInHeader(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+
+	inputPath := filepath.Join(tmpDir, "names.txt")
+	if err := os.WriteFile(inputPath, []byte("keyfile names test"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	keyfiles := []string{
+		filepath.Join(tmpDir, "token.bin"),
+		filepath.Join(tmpDir, "photo.jpg"),
+	}
+	for i, kf := range keyfiles {
+		if err := os.WriteFile(kf, []byte{byte(i), 'k', 'f'}, 0644); err != nil {
+			t.Fatalf("Failed to write keyfile: %v", err)
+		}
+	}
+
+	readHeader := func(path string) *header.VolumeHeader {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Failed to open volume: %v", err)
+		}
+		defer f.Close()
+		result, err := header.NewReader(f, rsCodecs).ReadHeader()
+		if err != nil {
+			t.Fatalf("ReadHeader failed: %v", err)
+		}
+		return result.Header
+	}
+
+	encrypt := func(output string, store bool) {
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:         inputPath,
+			OutputFile:        output,
+			Password:          "names_password",
+			Keyfiles:          keyfiles,
+			Comments:          "backup",
+			StoreKeyfileNames: store,
+			Reporter:          &GoldenTestReporter{},
+			RSCodecs:          rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+	}
+
+	decrypt := func(input string) error {
+		return Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  input,
+			OutputFile: filepath.Join(tmpDir, "names_out.txt"),
+			Password:   "names_password",
+			Keyfiles:   keyfiles,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+	}
+
+	// Stored: names round-trip and the volume decrypts
+	storedPath := filepath.Join(tmpDir, "stored.pcv")
+	encrypt(storedPath, true)
+	hdr := readHeader(storedPath)
+	names := hdr.KeyfileNames()
+	if len(names) != 2 || names[0] != "token.bin" || names[1] != "photo.jpg" {
+		t.Errorf("KeyfileNames() = %q; want [token.bin photo.jpg]", names)
+	}
+	if hdr.UserComments() != "backup" {
+		t.Errorf("UserComments() = %q; want %q", hdr.UserComments(), "backup")
+	}
+	if err := decrypt(storedPath); err != nil {
+		t.Fatalf("Decrypt with stored names failed: %v", err)
+	}
+	_ = os.Remove(filepath.Join(tmpDir, "names_out.txt"))
+
+	// Tampered: rename token.bin -> Token.bin in the header, which must fail auth
+	pos := strings.Index(hdr.Comments, "token.bin")
+	f, err := os.OpenFile(storedPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open volume for tampering: %v", err)
+	}
+	offset := int64(header.VersionEncSize + header.CommentLenEncSize + pos*3)
+	if _, err := f.WriteAt(encoding.Encode(rsCodecs.RS1, []byte{'T'}), offset); err != nil {
+		t.Fatalf("Failed to tamper with header: %v", err)
+	}
+	_ = f.Close()
+	if got := readHeader(storedPath).KeyfileNames(); len(got) == 0 || got[0] != "Token.bin" {
+		t.Fatalf("Tampering did not take effect: %q", got)
+	}
+	if err := decrypt(storedPath); err == nil {
+		t.Error("Decrypt should fail after keyfile names were tampered with")
+	}
+
+	// Off: no names are recorded
+	plainPath := filepath.Join(tmpDir, "plain.pcv")
+	encrypt(plainPath, false)
+	hdr = readHeader(plainPath)
+	if names := hdr.KeyfileNames(); names != nil {
+		t.Errorf("KeyfileNames() = %q; want nil when option is off", names)
+	}
+	if hdr.Comments != "backup" {
+		t.Errorf("Comments = %q; want %q", hdr.Comments, "backup")
+	}
+}
+
+// TestKeyfileNamesRejectsDeniability tests that keyfile names cannot be stored in a deniable volume
+func TestKeyfileNamesRejectsDeniability(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "deny.txt")
+	keyfilePath := filepath.Join(tmpDir, "key.bin")
+	if err := os.WriteFile(inputPath, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := os.WriteFile(keyfilePath, []byte("key"), 0644); err != nil {
+		t.Fatalf("Failed to write keyfile: %v", err)
+	}
+
+	req := &EncryptRequest{
+		InputFile:         inputPath,
+		OutputFile:        inputPath + ".pcv",
+		Keyfiles:          []string{keyfilePath},
+		Deniability:       true,
+		StoreKeyfileNames: true,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	}
+	if err := req.Validate(); err == nil {
+		t.Error("Validate should reject StoreKeyfileNames with Deniability")
+	}
+	if err := Encrypt(context.Background(), req); err == nil {
+		t.Error("Encrypt should reject StoreKeyfileNames with Deniability")
+	}
+	if _, err := os.Stat(inputPath + ".pcv"); !os.IsNotExist(err) {
+		t.Error("No volume should be written when the request is rejected")
+	}
+}
+
 // TestRoundTripSplit tests encrypt with splitting -> recombine -> decrypt
 func TestRoundTripSplit(t *testing.T) {
 	rsCodecs, err := encoding.NewRSCodecs()
@@ -765,6 +983,291 @@ func TestRoundTripSplit(t *testing.T) {
 	t.Log("Round-trip split/recombine: SUCCESS")
 }
 
+// TestRecombineVerifyChunks verifies that VerifyChunks passes intact chunks
+// and names each truncated one by its own index before any key derivation
+// or decryption
+func TestRecombineVerifyChunks(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "chunks.bin")
+	if err := os.WriteFile(inputPath, make([]byte, 25*1024), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := inputPath + ".pcv"
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: encryptedPath,
+		Password:   "chunk_password",
+		Split:      true,
+		ChunkSize:  10,
+		ChunkUnit:  fileops.SplitUnitKiB,
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt (split) failed: %v", err)
+	}
+
+	decrypt := func(outputPath string, reporter ProgressReporter) error {
+		return Decrypt(context.Background(), &DecryptRequest{
+			InputFile:    encryptedPath,
+			OutputFile:   outputPath,
+			Password:     "chunk_password",
+			Recombine:    true,
+			VerifyChunks: true,
+			Reporter:     reporter,
+			RSCodecs:     rsCodecs,
+		})
+	}
+
+	t.Run("correct sizes", func(t *testing.T) {
+		if err := decrypt(filepath.Join(tmpDir, "good.out"), &GoldenTestReporter{}); err != nil {
+			t.Fatalf("Decrypt with VerifyChunks failed: %v", err)
+		}
+	})
+
+	// Two full chunks and a shorter last one, so damage to either full chunk
+	// leaves no majority and has to be told apart from the intact one
+	for _, bad := range []int{0, 1} {
+		t.Run(fmt.Sprintf("truncated chunk %d", bad), func(t *testing.T) {
+			chunkPath := fmt.Sprintf("%s.%d", encryptedPath, bad)
+			intact, err := os.ReadFile(chunkPath)
+			if err != nil {
+				t.Fatalf("Failed to read chunk: %v", err)
+			}
+			if len(intact) != 10*1024 {
+				t.Fatalf("Chunk %d is %d bytes; want a full 10 KiB chunk", bad, len(intact))
+			}
+			t.Cleanup(func() { _ = os.WriteFile(chunkPath, intact, 0644) })
+			if err := os.Truncate(chunkPath, 10*1024-7); err != nil {
+				t.Fatalf("Failed to truncate chunk: %v", err)
+			}
+
+			reporter := &GoldenTestReporter{}
+			err = decrypt(filepath.Join(tmpDir, fmt.Sprintf("bad%d.out", bad)), reporter)
+			var sizeErr *fileops.ChunkSizeError
+			if !errors.As(err, &sizeErr) {
+				t.Fatalf("Expected ChunkSizeError, got: %v", err)
+			}
+			if sizeErr.Index != bad {
+				t.Errorf("Bad chunk index = %d; want %d", sizeErr.Index, bad)
+			}
+			if !errors.Is(err, perrors.ErrChunkSize) {
+				t.Errorf("Error should match ErrChunkSize: %v", err)
+			}
+			// The last status is still the recombine step, so the failure
+			// came before key derivation
+			if reporter.status != "Recombining chunks..." {
+				t.Errorf("Last status = %q; want the recombine step", reporter.status)
+			}
+		})
+	}
+}
+
+// TestRecombineSplitLayout verifies that a recorded split layout lets
+// recombining reject a stray extra chunk that plain probing would take in
+func TestRecombineSplitLayout(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := bytes.Repeat([]byte("layout"), 10000)
+	inputPath := filepath.Join(tmpDir, "layout.bin")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := inputPath + ".pcv"
+	req := &EncryptRequest{
+		InputFile:        inputPath,
+		OutputFile:       encryptedPath,
+		Password:         "layout_password",
+		Split:            true,
+		ChunkSize:        10,
+		ChunkUnit:        fileops.SplitUnitKiB,
+		StoreSplitLayout: true,
+		BlockHashes:      true,
+		Reporter:         &GoldenTestReporter{},
+		RSCodecs:         rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); err != nil {
+		t.Fatalf("Encrypt (split) failed: %v", err)
+	}
+	chunks, _, err := fileops.CountChunks(encryptedPath)
+	if err != nil {
+		t.Fatalf("CountChunks failed: %v", err)
+	}
+
+	decrypt := func(outputPath string) error {
+		return Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  encryptedPath,
+			OutputFile: outputPath,
+			Password:   "layout_password",
+			Recombine:  true,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+	}
+
+	t.Run("correct set", func(t *testing.T) {
+		outputPath := filepath.Join(tmpDir, "good.out")
+		if err := decrypt(outputPath); err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		decrypted, err := os.ReadFile(outputPath)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("decrypted content differs from the input (err: %v)", err)
+		}
+	})
+
+	t.Run("stray chunk", func(t *testing.T) {
+		stray := fmt.Sprintf("%s.%d", encryptedPath, chunks)
+		if err := os.WriteFile(stray, make([]byte, 10*1024), 0644); err != nil {
+			t.Fatalf("Failed to write stray chunk: %v", err)
+		}
+		defer func() { _ = os.Remove(stray) }()
+
+		outputPath := filepath.Join(tmpDir, "stray.out")
+		err := decrypt(outputPath)
+		var countErr *fileops.ChunkCountError
+		if !errors.As(err, &countErr) {
+			t.Fatalf("Expected ChunkCountError, got: %v", err)
+		}
+		if countErr.Found != chunks+1 || countErr.Want != chunks {
+			t.Errorf("ChunkCountError = %+v; want found %d, want %d", *countErr, chunks+1, chunks)
+		}
+		if !errors.Is(err, perrors.ErrChunkCount) {
+			t.Errorf("Error should match ErrChunkCount: %v", err)
+		}
+		if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+			t.Error("output was written despite the layout mismatch")
+		}
+	})
+
+	t.Run("requires split", func(t *testing.T) {
+		bad := *req
+		bad.Split = false
+		var verr *perrors.ValidationError
+		if err := bad.Validate(); !errors.As(err, &verr) || verr.Field != "StoreSplitLayout" {
+			t.Errorf("Expected a StoreSplitLayout ValidationError, got: %v", err)
+		}
+	})
+}
+
+// TestRoundTripArmor verifies that armored volumes decrypt transparently and
+// that the binary volume written alongside is unchanged
+func TestRoundTripArmor(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := make([]byte, 20*1024)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 251)
+	}
+	inputPath := filepath.Join(tmpDir, "armor.bin")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	encrypt := func(outputPath string, armor, armorOnly bool) {
+		t.Helper()
+		if err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: outputPath,
+			Password:   "armor_password",
+			Armor:      armor,
+			ArmorOnly:  armorOnly,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+	}
+	decrypt := func(volumePath, outputPath string) {
+		t.Helper()
+		if err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: outputPath,
+			Password:   "armor_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		}); err != nil {
+			t.Fatalf("Decrypt of %s failed: %v", filepath.Base(volumePath), err)
+		}
+		got, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read decrypted file: %v", err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Decrypting %s did not recover the plaintext", filepath.Base(volumePath))
+		}
+		if _, err := os.Stat(volumePath + ".dearmored"); !os.IsNotExist(err) {
+			t.Error("Dearmored temp file should be removed")
+		}
+	}
+
+	t.Run("armor", func(t *testing.T) {
+		volumePath := filepath.Join(tmpDir, "both.pcv")
+		encrypt(volumePath, true, false)
+
+		if encoding.IsArmored(volumePath) {
+			t.Error("Binary volume should not be armored")
+		}
+		if !encoding.IsArmored(volumePath + ".asc") {
+			t.Fatal("Armored copy was not written")
+		}
+		decrypt(volumePath, filepath.Join(tmpDir, "both_binary.out"))
+		decrypt(volumePath+".asc", filepath.Join(tmpDir, "both_armored.out"))
+	})
+
+	t.Run("armor only", func(t *testing.T) {
+		volumePath := filepath.Join(tmpDir, "only.pcv")
+		encrypt(volumePath, false, true)
+
+		if !encoding.IsArmored(volumePath) {
+			t.Fatal("Volume should be armored text")
+		}
+		if _, err := os.Stat(volumePath + ".asc"); !os.IsNotExist(err) {
+			t.Error("ArmorOnly should not leave a separate .asc file")
+		}
+		decrypt(volumePath, filepath.Join(tmpDir, "only.out"))
+	})
+
+	t.Run("binary unchanged", func(t *testing.T) {
+		volumePath := filepath.Join(tmpDir, "plain.pcv")
+		encrypt(volumePath, false, false)
+
+		if _, err := os.Stat(volumePath + ".asc"); !os.IsNotExist(err) {
+			t.Error("No armored copy should be written without Armor")
+		}
+		decrypt(volumePath, filepath.Join(tmpDir, "plain.out"))
+	})
+
+	t.Run("split rejected", func(t *testing.T) {
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: filepath.Join(tmpDir, "split.pcv"),
+			Password:   "armor_password",
+			Armor:      true,
+			Split:      true,
+			ChunkSize:  10,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		var valErr *perrors.ValidationError
+		if !errors.As(err, &valErr) {
+			t.Errorf("Expected ValidationError, got: %v", err)
+		}
+	})
+}
+
 // TestWrongPasswordFails verifies that wrong password fails
 func TestWrongPasswordFails(t *testing.T) {
 	rsCodecs, err := encoding.NewRSCodecs()
@@ -995,6 +1498,75 @@ func TestAutoUnzipSameLevel(t *testing.T) {
 	t.Log("Auto-unzip same-level: SUCCESS")
 }
 
+// TestAutoUnzipFailureKeepsZip tests that a decrypted archive that cannot be
+// extracted is kept and the decryption still counts as successful
+func TestAutoUnzipFailureKeepsZip(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+
+	// A zip signature followed by garbage: not a readable archive
+	archive := append([]byte("PK\x03\x04"), bytes.Repeat([]byte("not a zip "), 100)...)
+	zipPath := filepath.Join(tmpDir, "broken.zip")
+	if err := os.WriteFile(zipPath, archive, 0644); err != nil {
+		t.Fatalf("Failed to write malformed zip: %v", err)
+	}
+
+	encryptedPath := filepath.Join(tmpDir, "broken.zip.pcv")
+	reporter := &GoldenTestReporter{}
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  zipPath,
+		OutputFile: encryptedPath,
+		Password:   "broken_password",
+		Reporter:   reporter,
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	_ = os.Remove(zipPath)
+
+	kept := false
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:    encryptedPath,
+		OutputFile:   zipPath,
+		Password:     "broken_password",
+		AutoUnzip:    true,
+		DeleteVolume: true,
+		Reporter:     reporter,
+		RSCodecs:     rsCodecs,
+		Kept:         &kept,
+	})
+
+	// Only the extraction is reported, with its reason
+	if !errors.Is(err, perrors.ErrUnzipFailed) {
+		t.Fatalf("Expected ErrUnzipFailed, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "zip") || err.Error() == perrors.ErrUnzipFailed.Error() {
+		t.Errorf("Warning should include the reason, got: %v", err)
+	}
+	if errors.Is(err, perrors.ErrCorruptData) || errors.Is(err, perrors.ErrDeleteFailed) || kept {
+		t.Errorf("Decryption itself should have succeeded, got: %v (kept: %v)", err, kept)
+	}
+
+	// The decrypted zip is kept intact, and the volume deleted as requested
+	content, err := os.ReadFile(zipPath)
+	if err != nil {
+		t.Fatalf("Decrypted zip should have been kept: %v", err)
+	}
+	if !bytes.Equal(content, archive) {
+		t.Error("Kept zip does not match the encrypted archive")
+	}
+	if _, err := os.Stat(zipPath + ".incomplete"); !os.IsNotExist(err) {
+		t.Error("No .incomplete file should be left behind")
+	}
+	if _, err := os.Stat(encryptedPath); !os.IsNotExist(err) {
+		t.Error("Volume should have been deleted after a complete decryption")
+	}
+}
+
 // createTestZip creates a zip file from a directory
 func createTestZip(zipPath, sourceDir, baseName string) error {
 	zipFile, err := os.Create(zipPath)
@@ -1134,6 +1706,92 @@ func TestRoundTripMultiFile(t *testing.T) {
 	t.Log("Round-trip multi-file: SUCCESS")
 }
 
+// TestRawSingleFileFolder tests that a folder holding one file is encrypted
+// directly with RawSingleFile and decrypts to the original file name, while
+// without the option it is wrapped in a zip
+func TestRawSingleFileFolder(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	for _, raw := range []bool{true, false} {
+		name := "zipped"
+		if raw {
+			name = "raw"
+		}
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			folder := filepath.Join(tmpDir, "docs")
+			if err := os.Mkdir(folder, 0755); err != nil {
+				t.Fatalf("Failed to create folder: %v", err)
+			}
+			plaintext := []byte("the only file in the folder")
+			filePath := filepath.Join(folder, "notes.txt")
+			if err := os.WriteFile(filePath, plaintext, 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			req := &EncryptRequest{
+				InputFiles:    []string{filePath},
+				OnlyFolders:   []string{folder},
+				OutputFile:    filepath.Join(tmpDir, "encrypted.zip.pcv"),
+				Password:      "raw_password",
+				RawSingleFile: raw,
+				Reporter:      &GoldenTestReporter{},
+				RSCodecs:      rsCodecs,
+			}
+			if req.IsRawSingleFile() != raw {
+				t.Fatalf("IsRawSingleFile() = %v; want %v", req.IsRawSingleFile(), raw)
+			}
+			if raw {
+				req.OutputFile = req.RawSingleFileOutput()
+				if want := filepath.Join(tmpDir, "notes.txt.pcv"); req.OutputFile != want {
+					t.Fatalf("RawSingleFileOutput() = %q; want %q", req.OutputFile, want)
+				}
+			}
+			if err := Encrypt(context.Background(), req); err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			// Decrypt next to the volume, under the name the volume implies
+			decryptedPath := strings.TrimSuffix(req.OutputFile, ".pcv")
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  req.OutputFile,
+				OutputFile: decryptedPath,
+				Password:   "raw_password",
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+
+			decrypted, err := os.ReadFile(decryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read decrypted file: %v", err)
+			}
+			if raw {
+				if filepath.Base(decryptedPath) != "notes.txt" {
+					t.Errorf("Decrypted name = %q; want notes.txt", filepath.Base(decryptedPath))
+				}
+				if string(decrypted) != string(plaintext) {
+					t.Errorf("Decrypted content = %q; want %q", decrypted, plaintext)
+				}
+			} else {
+				zr, err := zip.OpenReader(decryptedPath)
+				if err != nil {
+					t.Fatalf("Expected a zip archive without RawSingleFile: %v", err)
+				}
+				defer zr.Close()
+				if len(zr.File) != 1 || zr.File[0].Name != "docs/notes.txt" {
+					t.Errorf("Unexpected zip contents: %v", zr.File)
+				}
+			}
+		})
+	}
+}
+
 // TestRoundTripSplitWithDeniability tests split + deniability combination
 func TestRoundTripSplitWithDeniability(t *testing.T) {
 	rsCodecs, err := encoding.NewRSCodecs()
@@ -1594,35 +2252,215 @@ func TestForceDecryptCorruptedData(t *testing.T) {
 	}
 }
 
-// TestRoundTripCompressedMultiFile tests encrypting multiple files with compression
-func TestRoundTripCompressedMultiFile(t *testing.T) {
+// TestForceDecryptConfirm tests that ConfirmForceDecrypt decides whether
+// damaged output is kept
+func TestForceDecryptConfirm(t *testing.T) {
 	rsCodecs, err := encoding.NewRSCodecs()
 	if err != nil {
 		t.Fatalf("Failed to create RS codecs: %v", err)
 	}
 
 	tmpDir := t.TempDir()
+	plaintext := bytes.Repeat([]byte("confirm force decrypt "), 100)
+	inputPath := filepath.Join(tmpDir, "confirm.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := filepath.Join(tmpDir, "confirm.txt.pcv")
 
-	// Create multiple test files
-	file1Content := []byte("Compressible content: AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
-	file2Content := []byte("More compressible: BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB")
-
-	file1Path := filepath.Join(tmpDir, "compress1.txt")
-	file2Path := filepath.Join(tmpDir, "compress2.txt")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  encryptedPath,
+		Password:    "confirm_password",
+		ReedSolomon: true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
 
-	if err := os.WriteFile(file1Path, file1Content, 0644); err != nil {
-		t.Fatalf("Failed to write file1: %v", err)
+	// Damage the final RS chunk beyond what it can repair
+	data, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatalf("Failed to read encrypted file: %v", err)
 	}
-	if err := os.WriteFile(file2Path, file2Content, 0644); err != nil {
-		t.Fatalf("Failed to write file2: %v", err)
+	for i := len(data) - encoding.RS128EncodedSize; i < len(data)-encoding.RS128EncodedSize+40; i++ {
+		data[i] ^= 0xFF
+	}
+	if err := os.WriteFile(encryptedPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write corrupted file: %v", err)
 	}
 
-	encryptedPath := filepath.Join(tmpDir, "compressed.zip.pcv")
-	decryptedPath := filepath.Join(tmpDir, "compressed.zip")
+	for _, keep := range []bool{false, true} {
+		t.Run(fmt.Sprintf("keep=%v", keep), func(t *testing.T) {
+			outputPath := filepath.Join(tmpDir, fmt.Sprintf("out-%v.txt", keep))
+			var ranges []Range
+			calls := 0
+			var kept bool
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:    encryptedPath,
+				OutputFile:   outputPath,
+				Password:     "confirm_password",
+				ForceDecrypt: true,
+				ConfirmForceDecrypt: func(damaged []Range) bool {
+					calls++
+					ranges = damaged
+					return keep
+				},
+				Reporter: &GoldenTestReporter{},
+				RSCodecs: rsCodecs,
+				Kept:     &kept,
+			})
+
+			if calls != 1 {
+				t.Fatalf("ConfirmForceDecrypt called %d times, want 1", calls)
+			}
+			if len(ranges) == 0 {
+				t.Fatal("expected damaged ranges to be reported")
+			}
+			for _, r := range ranges {
+				if r.Offset < 0 || r.Length <= 0 || r.Offset+r.Length > int64(len(plaintext)) {
+					t.Errorf("damaged range %+v outside plaintext of %d bytes", r, len(plaintext))
+				}
+			}
+
+			if keep {
+				if err != nil {
+					t.Fatalf("Decrypt failed: %v", err)
+				}
+				if !kept {
+					t.Error("expected Kept to be set")
+				}
+				if _, err := os.Stat(outputPath); err != nil {
+					t.Errorf("expected output to be kept: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, perrors.ErrCorruptData) {
+				t.Fatalf("expected ErrCorruptData, got %v", err)
+			}
+			if kept {
+				t.Error("Kept set for discarded output")
+			}
+			for _, p := range []string{outputPath, outputPath + ".incomplete"} {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("%s should not exist after discard", filepath.Base(p))
+				}
+			}
+		})
+	}
+}
 
-	reporter := &GoldenTestReporter{}
+// TestForceDecryptRepairStats tests that a force decrypt reports how many
+// RS chunks were beyond repair
+func TestForceDecryptRepairStats(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
 
-	// Encrypt with multiple input files and compression
+	tmpDir := t.TempDir()
+	// 3000 bytes pad to 24 RS128 chunks
+	plaintext := bytes.Repeat([]byte("repair stats "), 3000/13+1)[:3000]
+	inputPath := filepath.Join(tmpDir, "stats.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := filepath.Join(tmpDir, "stats.txt.pcv")
+
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  encryptedPath,
+		Password:    "stats_password",
+		ReedSolomon: true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	const totalChunks = 24
+	damagedChunks := []int{0, 10, 23}
+
+	data, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatalf("Failed to read encrypted file: %v", err)
+	}
+	payload := len(data) - totalChunks*encoding.RS128EncodedSize
+	for _, c := range damagedChunks {
+		start := payload + c*encoding.RS128EncodedSize
+		for i := start; i < start+40; i++ {
+			data[i] ^= 0xFF
+		}
+	}
+	// One correctable chunk must not be counted
+	data[payload+5*encoding.RS128EncodedSize] ^= 0xFF
+	if err := os.WriteFile(encryptedPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write corrupted file: %v", err)
+	}
+
+	var kept bool
+	var stats RepairStats
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:    encryptedPath,
+		OutputFile:   filepath.Join(tmpDir, "stats.out"),
+		Password:     "stats_password",
+		ForceDecrypt: true,
+		Reporter:     &GoldenTestReporter{},
+		RSCodecs:     rsCodecs,
+		Kept:         &kept,
+		RepairStats:  &stats,
+	})
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !kept {
+		t.Error("expected Kept to be set")
+	}
+
+	if stats.Total != totalChunks {
+		t.Errorf("Total = %d, want %d", stats.Total, totalChunks)
+	}
+	if stats.Uncorrectable != int64(len(damagedChunks)) {
+		t.Errorf("Uncorrectable = %d, want %d", stats.Uncorrectable, len(damagedChunks))
+	}
+	want := float64(len(damagedChunks)) / totalChunks
+	if got := stats.Fraction(); got != want {
+		t.Errorf("Fraction() = %v, want %v", got, want)
+	}
+}
+
+// TestRoundTripCompressedMultiFile tests encrypting multiple files with compression
+func TestRoundTripCompressedMultiFile(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+
+	// Create multiple test files
+	file1Content := []byte("Compressible content: AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	file2Content := []byte("More compressible: BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB")
+
+	file1Path := filepath.Join(tmpDir, "compress1.txt")
+	file2Path := filepath.Join(tmpDir, "compress2.txt")
+
+	if err := os.WriteFile(file1Path, file1Content, 0644); err != nil {
+		t.Fatalf("Failed to write file1: %v", err)
+	}
+	if err := os.WriteFile(file2Path, file2Content, 0644); err != nil {
+		t.Fatalf("Failed to write file2: %v", err)
+	}
+
+	encryptedPath := filepath.Join(tmpDir, "compressed.zip.pcv")
+	decryptedPath := filepath.Join(tmpDir, "compressed.zip")
+
+	reporter := &GoldenTestReporter{}
+
+	// Encrypt with multiple input files and compression
 	encReq := &EncryptRequest{
 		InputFiles: []string{file1Path, file2Path},
 		OutputFile: encryptedPath,
@@ -2015,6 +2853,129 @@ func TestZeroLengthComments(t *testing.T) {
 	t.Log("Zero-length comments: SUCCESS")
 }
 
+// TestMaxLengthComments tests a round trip with comments of exactly
+// header.MaxCommentLen bytes, the largest the length field can express,
+// and that one byte more is refused before anything is written.
+func TestMaxLengthComments(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := []byte("Maximum length comments test data.")
+	inputPath := filepath.Join(tmpDir, "max_comments.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	comments := strings.Repeat("0123456789", header.MaxCommentLen/10) + "012345678"
+	encryptedPath := filepath.Join(tmpDir, "max_comments.txt.pcv")
+	decryptedPath := filepath.Join(tmpDir, "max_comments_dec.txt")
+
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: encryptedPath,
+		Password:   "max_comments_password",
+		Comments:   comments,
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt with %d-byte comments failed: %v", len(comments), err)
+	}
+
+	if got := readVolumeHeader(t, encryptedPath, rsCodecs).Comments; got != comments {
+		t.Errorf("Header comments length = %d; want %d", len(got), len(comments))
+	}
+
+	if err := Decrypt(context.Background(), &DecryptRequest{
+		InputFile:  encryptedPath,
+		OutputFile: decryptedPath,
+		Password:   "max_comments_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Decrypt with %d-byte comments failed: %v", len(comments), err)
+	}
+	decrypted, err := os.ReadFile(decryptedPath)
+	if err != nil {
+		t.Fatalf("Failed to read decrypted file: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Content mismatch.\nExpected: %q\nGot: %q", plaintext, decrypted)
+	}
+
+	tooLongPath := filepath.Join(tmpDir, "too_long.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: tooLongPath,
+		Password:   "max_comments_password",
+		Comments:   comments + "x",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err == nil {
+		t.Fatal("Encrypt should refuse comments longer than MaxCommentLen")
+	}
+	for _, path := range []string{tooLongPath, tooLongPath + ".incomplete"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist after a refused encrypt", filepath.Base(path))
+		}
+	}
+}
+
+// TestMalformedCommentLength tests that a comment length field that does
+// not decode to five digits fails decryption as a damaged header instead of
+// being read as some other length.
+func TestMalformedCommentLength(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "bad_length.txt")
+	if err := os.WriteFile(inputPath, []byte("Malformed comment length test data."), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := filepath.Join(tmpDir, "bad_length.txt.pcv")
+	if err := Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: encryptedPath,
+		Password:   "bad_length_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	original, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+
+	for _, field := range []string{"0000a", "-0001", "+0000", " 0000"} {
+		data := bytes.Clone(original)
+		copy(data[header.VersionEncSize:], encoding.Encode(rsCodecs.RS5, []byte(field)))
+		if err := os.WriteFile(encryptedPath, data, 0644); err != nil {
+			t.Fatalf("Failed to write volume: %v", err)
+		}
+
+		decryptedPath := filepath.Join(tmpDir, "bad_length_dec.txt")
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  encryptedPath,
+			OutputFile: decryptedPath,
+			Password:   "bad_length_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if !errors.Is(err, perrors.ErrCorruptHeader) || !errors.Is(err, header.ErrInvalidCommentLength) {
+			t.Errorf("Decrypt with length %q error = %v; want ErrCorruptHeader", field, err)
+		}
+		if _, err := os.Stat(decryptedPath); !os.IsNotExist(err) {
+			t.Errorf("No output should be written for length %q", field)
+		}
+	}
+}
+
 // TestRoundTripKeyfileOnly tests encryption with keyfile only (no password).
 // This is a security-critical test as keyfile-only mode uses empty password string.
 func TestRoundTripKeyfileOnly(t *testing.T) {
@@ -2574,3 +3535,961 @@ func TestDuplicateKeyfilesRejected(t *testing.T) {
 	t.Logf("Duplicate keyfiles correctly rejected: %v", err)
 	t.Log("Duplicate keyfiles rejection: SUCCESS")
 }
+
+// TestDecryptSplitChunkPath tests that a split volume decrypts identically
+// whether InputFile is the base path or the path of its first chunk
+func TestDecryptSplitChunkPath(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := make([]byte, 30*1024) // 30 KiB, three 10 KiB chunks
+	for i := range plaintext {
+		plaintext[i] = byte(i * 13)
+	}
+	inputPath := filepath.Join(tmpDir, "chunk_path.bin")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := inputPath + ".pcv"
+
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: encryptedPath,
+		Password:   "chunk_path_password",
+		Split:      true,
+		ChunkSize:  10,
+		ChunkUnit:  0, // KiB
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt (split) failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		input string
+	}{
+		{"base_path", encryptedPath},
+		{"first_chunk", encryptedPath + ".0"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			decryptedPath := filepath.Join(tmpDir, tt.name+".bin")
+			req := &DecryptRequest{
+				InputFile:  tt.input,
+				OutputFile: decryptedPath,
+				Password:   "chunk_path_password",
+				Recombine:  true,
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			}
+			if err := req.Validate(); err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			if err := Decrypt(context.Background(), req); err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+
+			decrypted, err := os.ReadFile(decryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read decrypted file: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Error("Decrypted content does not match original plaintext")
+			}
+
+			// The chunks stay in place and the recombined file is removed
+			if _, err := os.Stat(encryptedPath + ".0"); err != nil {
+				t.Errorf("First chunk missing after decrypt: %v", err)
+			}
+			if _, err := os.Stat(encryptedPath); !os.IsNotExist(err) {
+				t.Error("Recombined volume was not cleaned up")
+			}
+		})
+	}
+}
+
+// TestStrictDeniability tests that strict mode rejects an unacknowledged
+// deniable volume and decrypts normally once deniability is requested
+func TestStrictDeniability(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := []byte("strict deniability test data")
+	inputPath := filepath.Join(tmpDir, "strict.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	deniablePath := filepath.Join(tmpDir, "deniable.pcv")
+	plainPath := filepath.Join(tmpDir, "plain.pcv")
+
+	for path, deniability := range map[string]bool{deniablePath: true, plainPath: false} {
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:   inputPath,
+			OutputFile:  path,
+			Password:    "strict_password",
+			Deniability: deniability,
+			Reporter:    &GoldenTestReporter{},
+			RSCodecs:    rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt %s failed: %v", filepath.Base(path), err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		deniability bool
+		wantErr     error
+	}{
+		{"deniable_unacknowledged", deniablePath, false, perrors.ErrDeniableNotAcknowledged},
+		{"deniable_acknowledged", deniablePath, true, nil},
+		{"regular_volume", plainPath, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(tmpDir, tt.name+".txt")
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:         tt.input,
+				OutputFile:        outputPath,
+				Password:          "strict_password",
+				Deniability:       tt.deniability,
+				StrictDeniability: true,
+				Reporter:          &GoldenTestReporter{},
+				RSCodecs:          rsCodecs,
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got: %v", tt.wantErr, err)
+				}
+				if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+					t.Error("Rejected decryption left an output file behind")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			decrypted, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read decrypted file: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Error("Decrypted content does not match original plaintext")
+			}
+		})
+	}
+}
+
+// TestEncryptPreallocate tests that Preallocate sizes the output to the
+// estimated volume size before the payload is written, and that the result
+// is byte-for-byte that size and still decrypts
+func TestEncryptPreallocate(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	plaintext := make([]byte, 2*1024*1024+1000) // Two full blocks and a partial one
+	for i := range plaintext {
+		plaintext[i] = byte(i * 31)
+	}
+	comments := "preallocated"
+
+	for _, reedSolomon := range []bool{false, true} {
+		name := "plain"
+		if reedSolomon {
+			name = "reed_solomon"
+		}
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			inputPath := filepath.Join(tmpDir, "prealloc.bin")
+			if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+			encryptedPath := inputPath + ".pcv"
+
+			estimated := int64(header.HeaderSize(len(comments))) + encryptedPayloadSize(int64(len(plaintext)), reedSolomon)
+			var sizeBeforePayload int64 = -1
+			testHookBeforePayload = func(path string) {
+				if stat, err := os.Stat(path); err == nil {
+					sizeBeforePayload = stat.Size()
+				}
+			}
+			t.Cleanup(func() { testHookBeforePayload = nil })
+
+			err := Encrypt(context.Background(), &EncryptRequest{
+				InputFile:   inputPath,
+				OutputFile:  encryptedPath,
+				Password:    "prealloc_password",
+				Comments:    comments,
+				ReedSolomon: reedSolomon,
+				Preallocate: true,
+				Reporter:    &GoldenTestReporter{},
+				RSCodecs:    rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			if sizeBeforePayload != estimated {
+				t.Errorf("Size before payload = %d; want preallocated %d", sizeBeforePayload, estimated)
+			}
+			stat, err := os.Stat(encryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to stat volume: %v", err)
+			}
+			if stat.Size() != estimated {
+				t.Errorf("Final size = %d; want %d", stat.Size(), estimated)
+			}
+
+			decryptedPath := filepath.Join(tmpDir, "prealloc.out")
+			err = Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  encryptedPath,
+				OutputFile: decryptedPath,
+				Password:   "prealloc_password",
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			decrypted, err := os.ReadFile(decryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read decrypted file: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Error("Decrypted content does not match original plaintext")
+			}
+		})
+	}
+}
+
+// TestDecryptAAD tests that associated data is required to open a volume
+// created with it, and that empty AAD keeps volumes interchangeable
+func TestDecryptAAD(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := []byte("associated data test")
+	inputPath := filepath.Join(tmpDir, "aad.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	boundPath := filepath.Join(tmpDir, "bound.pcv")
+	plainPath := filepath.Join(tmpDir, "plain.pcv")
+
+	for path, aad := range map[string][]byte{boundPath: []byte("tenant-42"), plainPath: nil} {
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: path,
+			Password:   "aad_password",
+			AAD:        aad,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt %s failed: %v", filepath.Base(path), err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		aad     []byte
+		wantErr bool
+	}{
+		{"matching", boundPath, []byte("tenant-42"), false},
+		{"wrong", boundPath, []byte("tenant-43"), true},
+		{"missing", boundPath, nil, true},
+		{"unexpected", plainPath, []byte("tenant-42"), true},
+		{"empty", plainPath, []byte{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(tmpDir, tt.name+".txt")
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  tt.input,
+				OutputFile: outputPath,
+				Password:   "aad_password",
+				AAD:        tt.aad,
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if tt.wantErr {
+				var authErr *header.AuthError
+				if !errors.As(err, &authErr) || !authErr.PasswordIncorrect {
+					t.Fatalf("Expected a password error, got: %v", err)
+				}
+				if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+					t.Error("Rejected decryption left an output file behind")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			got, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Error("Decrypted content does not match original")
+			}
+		})
+	}
+}
+
+// TestDecryptPepper tests that a peppered volume needs the same pepper to
+// open, and that volumes without one are unaffected
+func TestDecryptPepper(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := []byte("pepper test data")
+	inputPath := filepath.Join(tmpDir, "pepper.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	pepperedPath := filepath.Join(tmpDir, "peppered.pcv")
+	plainPath := filepath.Join(tmpDir, "plain.pcv")
+
+	for path, pepper := range map[string][]byte{pepperedPath: []byte("hsm-secret"), plainPath: nil} {
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:  inputPath,
+			OutputFile: path,
+			Password:   "pepper_password",
+			Pepper:     pepper,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Encrypt %s failed: %v", filepath.Base(path), err)
+		}
+	}
+
+	decrypt := func(name, input string, pepper []byte) (string, error) {
+		outputPath := filepath.Join(tmpDir, name+".txt")
+		return outputPath, Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  input,
+			OutputFile: outputPath,
+			Password:   "pepper_password",
+			Pepper:     pepper,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+	}
+
+	for name, input := range map[string]string{"correct": pepperedPath, "none": plainPath} {
+		t.Run(name, func(t *testing.T) {
+			var pepper []byte
+			if input == pepperedPath {
+				pepper = []byte("hsm-secret")
+			}
+			outputPath, err := decrypt(name, input, pepper)
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			got, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Error("Decrypted content does not match original")
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := decrypt("missing", pepperedPath, nil)
+		if !errors.Is(err, perrors.ErrPepperRequired) {
+			t.Fatalf("Expected ErrPepperRequired, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "pepper") {
+			t.Errorf("Error should mention the pepper: %v", err)
+		}
+	})
+
+	t.Run("wrong", func(t *testing.T) {
+		_, err := decrypt("wrong", pepperedPath, []byte("other-secret"))
+		var authErr *header.AuthError
+		if !errors.As(err, &authErr) || !authErr.PasswordIncorrect {
+			t.Fatalf("Expected a password error, got: %v", err)
+		}
+	})
+
+	t.Run("unexpected", func(t *testing.T) {
+		_, err := decrypt("unexpected", plainPath, []byte("hsm-secret"))
+		var valErr *perrors.ValidationError
+		if !errors.As(err, &valErr) {
+			t.Fatalf("Expected a validation error, got: %v", err)
+		}
+	})
+}
+
+// TestArgon2ThreadClamp tests that Argon2ThreadsAuto clamps the thread count
+// to the available CPUs, that the default is never clamped, and that the
+// count is recorded in the header and read back on decryption
+func TestArgon2ThreadClamp(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := []byte("argon2 thread clamp test")
+	inputPath := filepath.Join(tmpDir, "threads.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	origCPUs := effectiveCPUs
+	t.Cleanup(func() { effectiveCPUs = origCPUs })
+
+	tests := []struct {
+		name     string
+		cpus     int
+		paranoid bool
+		override int
+		want     uint8 // stored value, 0 = mode default
+	}{
+		{"paranoid_2_cpus", 2, true, Argon2ThreadsAuto, 2},
+		{"normal_2_cpus", 2, false, Argon2ThreadsAuto, 2},
+		{"normal_16_cpus", 16, false, Argon2ThreadsAuto, 0},
+		{"default_2_cpus", 2, true, 0, 0},
+		{"explicit_override", 2, true, 8, 0},
+		{"explicit_non_default", 16, false, 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			effectiveCPUs = func() int { return tt.cpus }
+			volumePath := filepath.Join(tmpDir, tt.name+".pcv")
+			err := Encrypt(context.Background(), &EncryptRequest{
+				InputFile:     inputPath,
+				OutputFile:    volumePath,
+				Password:      "threads_password",
+				Paranoid:      tt.paranoid,
+				Argon2Threads: tt.override,
+				Reporter:      &GoldenTestReporter{},
+				RSCodecs:      rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			fin, err := os.Open(volumePath)
+			if err != nil {
+				t.Fatalf("Failed to open volume: %v", err)
+			}
+			result, err := header.NewReader(fin, rsCodecs).ReadHeader()
+			_ = fin.Close()
+			if err != nil {
+				t.Fatalf("ReadHeader failed: %v", err)
+			}
+			if got := result.Header.Flags.Threads; got != tt.want {
+				t.Errorf("Stored threads = %d; want %d", got, tt.want)
+			}
+			if result.Header.Flags.Paranoid != tt.paranoid {
+				t.Error("Paranoid flag not preserved alongside the thread count")
+			}
+
+			// Decryption must use the stored value, not the current CPU count
+			effectiveCPUs = func() int { return 64 }
+			outputPath := filepath.Join(tmpDir, tt.name+".txt")
+			err = Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  volumePath,
+				OutputFile: outputPath,
+				Password:   "threads_password",
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			got, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Error("Decrypted content does not match original")
+			}
+		})
+	}
+
+	t.Run("out_of_range", func(t *testing.T) {
+		err := Encrypt(context.Background(), &EncryptRequest{
+			InputFile:     inputPath,
+			OutputFile:    filepath.Join(tmpDir, "range.pcv"),
+			Password:      "threads_password",
+			Argon2Threads: header.MaxThreads + 1,
+			Reporter:      &GoldenTestReporter{},
+			RSCodecs:      rsCodecs,
+		})
+		var valErr *perrors.ValidationError
+		if !errors.As(err, &valErr) {
+			t.Fatalf("Expected a validation error, got: %v", err)
+		}
+	})
+}
+
+// TestMaxDerivationTime tests that a paranoid encryption is refused up front
+// when the calibrated key derivation exceeds MaxDerivationTime, and allowed
+// when it does not
+func TestMaxDerivationTime(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "slow.txt")
+	if err := os.WriteFile(inputPath, []byte("derivation time test"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	origEstimate := estimateDerivation
+	t.Cleanup(func() { estimateDerivation = origEstimate })
+	var estimate time.Duration
+	calls := 0
+	estimateDerivation = func(paranoid bool, threads uint8) time.Duration {
+		calls++
+		if !paranoid {
+			t.Error("calibrated normal mode for a paranoid request")
+		}
+		return estimate
+	}
+
+	req := &EncryptRequest{
+		InputFile:         inputPath,
+		OutputFile:        filepath.Join(tmpDir, "slow.txt.pcv"),
+		Password:          "slow_password",
+		Paranoid:          true,
+		MaxDerivationTime: 30 * time.Second,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	}
+
+	t.Run("slow", func(t *testing.T) {
+		estimate = 5 * time.Minute
+		err := Encrypt(context.Background(), req)
+		if !errors.Is(err, perrors.ErrDerivationTooSlow) {
+			t.Fatalf("expected ErrDerivationTooSlow, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "normal mode") {
+			t.Errorf("advisory should suggest normal mode: %v", err)
+		}
+		for _, p := range []string{req.OutputFile, req.OutputFile + ".incomplete"} {
+			if _, err := os.Stat(p); !os.IsNotExist(err) {
+				t.Errorf("%s should not exist", filepath.Base(p))
+			}
+		}
+	})
+
+	t.Run("fast", func(t *testing.T) {
+		estimate = 2 * time.Second
+		if err := checkDerivationTime(req); err != nil {
+			t.Errorf("unexpected advisory for fast derivation: %v", err)
+		}
+	})
+
+	t.Run("not_applicable", func(t *testing.T) {
+		estimate = 5 * time.Minute
+		calls = 0
+		normal := *req
+		normal.Paranoid = false
+		unlimited := *req
+		unlimited.MaxDerivationTime = 0
+		for _, r := range []*EncryptRequest{&normal, &unlimited} {
+			if err := checkDerivationTime(r); err != nil {
+				t.Errorf("unexpected advisory: %v", err)
+			}
+		}
+		if calls != 0 {
+			t.Errorf("calibrated %d times; want 0", calls)
+		}
+	})
+}
+
+// TestTargetDerivationTime tests that the pass count calibrated for a target
+// duration is recorded in the header and that decryption derives the key
+// with it
+func TestTargetDerivationTime(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := []byte("do not let me rush this")
+	inputPath := filepath.Join(tmpDir, "slow.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	origCalibrate := calibratePasses
+	t.Cleanup(func() { calibratePasses = origCalibrate })
+	const target = 3 * time.Second
+	const passes = crypto.Argon2NormalPasses + 1
+	calibratePasses = func(paranoid bool, threads uint8, got time.Duration) int {
+		if paranoid {
+			t.Error("calibrated paranoid mode for a normal request")
+		}
+		if got != target {
+			t.Errorf("calibrated for %s; want %s", got, target)
+		}
+		return passes
+	}
+
+	req := &EncryptRequest{
+		InputFile:            inputPath,
+		OutputFile:           filepath.Join(tmpDir, "slow.txt.pcv"),
+		Password:             "slow_password",
+		TargetDerivationTime: target,
+		Reporter:             &GoldenTestReporter{},
+		RSCodecs:             rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if got := readVolumeHeader(t, req.OutputFile, rsCodecs).Flags.Passes; got != passes {
+		t.Fatalf("stored passes = %d; want %d", got, passes)
+	}
+
+	outputPath := filepath.Join(tmpDir, "slow.out")
+	if err := Decrypt(context.Background(), &DecryptRequest{
+		InputFile:  req.OutputFile,
+		OutputFile: outputPath,
+		Password:   "slow_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	}); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("Decrypted content does not match original")
+	}
+}
+
+// TestTargetDerivationTimeLimits tests the pass cap and the conflict with
+// MaxDerivationTime without running any long derivation
+func TestTargetDerivationTimeLimits(t *testing.T) {
+	origCalibrate := calibratePasses
+	t.Cleanup(func() { calibratePasses = origCalibrate })
+
+	tests := []struct {
+		name       string
+		calibrated int
+		want       uint8
+		warn       bool
+	}{
+		{"default", crypto.Argon2NormalPasses, 0, false},
+		{"raised", 40, 40, false},
+		{"capped", 500, header.MaxPasses, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calibratePasses = func(paranoid bool, threads uint8, target time.Duration) int {
+				return tt.calibrated
+			}
+			reporter := &GoldenTestReporter{}
+			req := &EncryptRequest{TargetDerivationTime: time.Minute, Reporter: reporter}
+			if got := argon2Passes(NewEncryptContext(context.Background(), req), req, 0); got != tt.want {
+				t.Errorf("argon2Passes = %d; want %d", got, tt.want)
+			}
+			if warned := len(reporter.warnings) > 0; warned != tt.warn {
+				t.Errorf("warnings = %q; want warning %v", reporter.warnings, tt.warn)
+			}
+		})
+	}
+
+	req := &EncryptRequest{TargetDerivationTime: time.Minute, MaxDerivationTime: 30 * time.Second}
+	var valErr *perrors.ValidationError
+	if err := checkDerivationTime(req); !errors.As(err, &valErr) || valErr.Field != "TargetDerivationTime" {
+		t.Errorf("checkDerivationTime = %v; want a TargetDerivationTime validation error", err)
+	}
+}
+
+// TestDecryptDiscardOutput tests that DiscardOutput runs the full decryption,
+// including the MAC check, without creating any output.
+func TestDecryptDiscardOutput(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := make([]byte, 3*util.MiB)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	inputPath := filepath.Join(tmpDir, "bench.bin")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "bench.bin.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "discard_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	outputPath := filepath.Join(tmpDir, "out.bin")
+	var throughput float64
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:     volumePath,
+		OutputFile:    outputPath,
+		Password:      "discard_password",
+		DiscardOutput: true,
+		DeleteVolume:  true,
+		Reporter:      &GoldenTestReporter{},
+		RSCodecs:      rsCodecs,
+		Throughput:    &throughput,
+	})
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if throughput <= 0 {
+		t.Errorf("Throughput = %v; want > 0", throughput)
+	}
+	for _, path := range []string{outputPath, outputPath + ".incomplete"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was created", filepath.Base(path))
+		}
+	}
+	if _, err := os.Stat(volumePath); err != nil {
+		t.Errorf("volume was deleted: %v", err)
+	}
+
+	// The payload is still authenticated
+	volume, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+	volume[len(volume)-util.MiB] ^= 0x01
+	tamperedPath := filepath.Join(tmpDir, "tampered.pcv")
+	if err := os.WriteFile(tamperedPath, volume, 0644); err != nil {
+		t.Fatalf("Failed to write tampered volume: %v", err)
+	}
+	err = Decrypt(context.Background(), &DecryptRequest{
+		InputFile:     tamperedPath,
+		Password:      "discard_password",
+		DiscardOutput: true,
+		Reporter:      &GoldenTestReporter{},
+		RSCodecs:      rsCodecs,
+	})
+	if err == nil {
+		t.Error("expected a tampered volume to fail with DiscardOutput")
+	}
+}
+
+// TestDecryptTruncatedVolume tests that a volume cut off inside its header
+// fails with ErrTruncatedVolume instead of a confusing decode error.
+func TestDecryptTruncatedVolume(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "download.txt")
+	if err := os.WriteFile(inputPath, []byte("will be cut short"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "download.txt.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:  inputPath,
+		OutputFile: volumePath,
+		Password:   "truncated_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	volume, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+
+	// Field offsets for a volume without comments
+	saltStart := header.VersionEncSize + header.CommentLenEncSize + header.FlagsEncSize
+	nonceStart := saltStart + header.SaltEncSize + header.HKDFSaltEncSize + header.SerpentIVEncSize
+	authTagStart := nonceStart + header.NonceEncSize + header.KeyHashEncSize + header.KeyfileHashEncSize
+	cuts := map[string]int{
+		"empty":       0,
+		"mid_salt":    saltStart + header.SaltEncSize/2,
+		"mid_nonce":   nonceStart + header.NonceEncSize/2,
+		"mid_authtag": authTagStart + header.AuthTagEncSize/2,
+	}
+	for name, cut := range cuts {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cut.pcv")
+			if err := os.WriteFile(path, volume[:cut], 0644); err != nil {
+				t.Fatalf("Failed to write truncated volume: %v", err)
+			}
+			outputPath := filepath.Join(t.TempDir(), "out.txt")
+			err := Decrypt(context.Background(), &DecryptRequest{
+				InputFile:  path,
+				OutputFile: outputPath,
+				Password:   "truncated_password",
+				Reporter:   &GoldenTestReporter{},
+				RSCodecs:   rsCodecs,
+			})
+			if !errors.Is(err, perrors.ErrTruncatedVolume) {
+				t.Errorf("got %v; want ErrTruncatedVolume", err)
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Error("output was created for a truncated volume")
+			}
+		})
+	}
+}
+
+// TestDecryptTruncatedPayload tests that a Reed-Solomon volume cut off
+// inside an encoded chunk fails with ErrTruncatedVolume rather than
+// decrypting what is left and failing the MAC check
+func TestDecryptTruncatedPayload(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "download.bin")
+	if err := os.WriteFile(inputPath, bytes.Repeat([]byte("truncated payload "), 100000), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := filepath.Join(tmpDir, "download.bin.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:   inputPath,
+		OutputFile:  volumePath,
+		Password:    "truncated_password",
+		ReedSolomon: true,
+		Reporter:    &GoldenTestReporter{},
+		RSCodecs:    rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	volume, err := os.ReadFile(volumePath)
+	if err != nil {
+		t.Fatalf("Failed to read volume: %v", err)
+	}
+
+	cuts := map[string]int{
+		"first_block": header.BaseHeaderSize + 100*encoding.RS128EncodedSize + 17,
+		"last_block":  len(volume) - 50,
+	}
+	for name, cut := range cuts {
+		for _, verifyFirst := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/verify_first=%v", name, verifyFirst), func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "cut.pcv")
+				if err := os.WriteFile(path, volume[:cut], 0644); err != nil {
+					t.Fatalf("Failed to write truncated volume: %v", err)
+				}
+				outputPath := filepath.Join(t.TempDir(), "out.bin")
+				err := Decrypt(context.Background(), &DecryptRequest{
+					InputFile:   path,
+					OutputFile:  outputPath,
+					Password:    "truncated_password",
+					VerifyFirst: verifyFirst,
+					Reporter:    &GoldenTestReporter{},
+					RSCodecs:    rsCodecs,
+				})
+				if !errors.Is(err, perrors.ErrTruncatedVolume) {
+					t.Errorf("got %v; want ErrTruncatedVolume", err)
+				}
+				if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+					t.Error("output was created for a truncated volume")
+				}
+			})
+		}
+	}
+}
+
+// TestDecryptMmapOutput tests that decrypting through a memory-mapped
+// output writes the same plaintext as ordinary writes
+func TestDecryptMmapOutput(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := make([]byte, 3*util.MiB+12345)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	inputPath := filepath.Join(tmpDir, "large.bin")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	for _, reedSolomon := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reedSolomon=%v", reedSolomon), func(t *testing.T) {
+			encryptedPath := filepath.Join(t.TempDir(), "large.bin.pcv")
+			err := Encrypt(context.Background(), &EncryptRequest{
+				InputFile:   inputPath,
+				OutputFile:  encryptedPath,
+				Password:    "mmap_password",
+				ReedSolomon: reedSolomon,
+				Reporter:    &GoldenTestReporter{},
+				RSCodecs:    rsCodecs,
+			})
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			decrypt := func(mmap bool) []byte {
+				out := filepath.Join(t.TempDir(), "large.bin")
+				err := Decrypt(context.Background(), &DecryptRequest{
+					InputFile:  encryptedPath,
+					OutputFile: out,
+					Password:   "mmap_password",
+					MmapOutput: mmap,
+					Reporter:   &GoldenTestReporter{},
+					RSCodecs:   rsCodecs,
+				})
+				if err != nil {
+					t.Fatalf("Decrypt with MmapOutput=%v failed: %v", mmap, err)
+				}
+				got, err := os.ReadFile(out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return got
+			}
+
+			written := decrypt(false)
+			mapped := decrypt(true)
+			if !bytes.Equal(mapped, written) || !bytes.Equal(mapped, plaintext) {
+				t.Errorf("mapped output (%d bytes) differs from written output (%d bytes)", len(mapped), len(written))
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package volume
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"Picocrypt-NG/internal/fileops"
+)
+
+// SidecarExt is appended to a volume path to name its metadata sidecar.
+const SidecarExt = ".meta"
+
+// SidecarPath returns the sidecar path for a volume. A numbered chunk of a
+// split volume shares the sidecar of its base (file.pcv.0 -> file.pcv.meta).
+func SidecarPath(volumePath string) string {
+	return splitVolumeBase(volumePath) + SidecarExt
+}
+
+// WriteSidecar replaces the plaintext JSON sidecar next to a volume. The
+// sidecar is a separate file and is neither encrypted nor authenticated:
+// anyone can read or change it, and changing it never affects the volume.
+// Do not store anything sensitive in it.
+func WriteSidecar(volumePath string, meta map[string]string) error {
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode sidecar: %w", err)
+	}
+	data = append(data, '\n')
+
+	// Write beside the target and rename so readers never see a partial file
+	path := SidecarPath(volumePath)
+	tmpPath := path + ".incomplete"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("write sidecar: %w", err)
+	}
+	if err := fileops.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("write sidecar: %w", err)
+	}
+	return nil
+}
+
+// ReadSidecar returns the metadata stored next to a volume by WriteSidecar.
+// A missing sidecar yields an empty map and no error. The contents are
+// unauthenticated and may have been edited by anyone.
+func ReadSidecar(volumePath string) (map[string]string, error) {
+	data, err := os.ReadFile(SidecarPath(volumePath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read sidecar: %w", err)
+	}
+
+	meta := map[string]string{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("parse sidecar: %w", err)
+	}
+	return meta, nil
+}
@@ -0,0 +1,66 @@
+package volume
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSidecarRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	volumePath := filepath.Join(tmpDir, "backup.pcv")
+	volumeData := []byte("volume bytes are never touched")
+	if err := os.WriteFile(volumePath, volumeData, 0644); err != nil {
+		t.Fatalf("Failed to write volume: %v", err)
+	}
+
+	meta := map[string]string{"label": "photos 2024", "owner": "alice"}
+	if err := WriteSidecar(volumePath, meta); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "backup.pcv.meta")); err != nil {
+		t.Fatalf("sidecar not written next to the volume: %v", err)
+	}
+
+	got, err := ReadSidecar(volumePath)
+	if err != nil {
+		t.Fatalf("ReadSidecar failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, meta) {
+		t.Errorf("ReadSidecar = %v; want %v", got, meta)
+	}
+
+	// Overwriting replaces the previous contents
+	if err := WriteSidecar(volumePath, map[string]string{"label": "renamed"}); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+	got, err = ReadSidecar(volumePath)
+	if err != nil {
+		t.Fatalf("ReadSidecar failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]string{"label": "renamed"}) {
+		t.Errorf("ReadSidecar after overwrite = %v", got)
+	}
+
+	// A chunk of a split volume shares the base volume's sidecar
+	got, err = ReadSidecar(filepath.Join(tmpDir, "backup.pcv.0"))
+	if err != nil || got["label"] != "renamed" {
+		t.Errorf("ReadSidecar for chunk = %v, %v", got, err)
+	}
+
+	data, err := os.ReadFile(volumePath)
+	if err != nil || string(data) != string(volumeData) {
+		t.Error("volume was modified by the sidecar helpers")
+	}
+}
+
+func TestReadSidecarMissing(t *testing.T) {
+	got, err := ReadSidecar(filepath.Join(t.TempDir(), "none.pcv"))
+	if err != nil {
+		t.Fatalf("ReadSidecar failed: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Errorf("ReadSidecar = %v; want empty map", got)
+	}
+}
@@ -0,0 +1,194 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/log"
+	"Picocrypt-NG/internal/util"
+)
+
+// deniabilityOverhead is the salt and nonce AddDeniability prepends.
+const deniabilityOverhead = 16 + 24
+
+// RequiredFreeSpace returns the most disk space Encrypt uses at any one time
+// for req, given inputSize bytes of input, so callers can check for room
+// before starting. The input itself is not counted. Files that exist
+// together are added up, phase by phase:
+//
+//   - the temporary zip (only when the input is zipped) stays until the
+//     volume is finished, alongside the .incomplete output
+//   - with Deniability, the plain volume is kept while the wrapped copy is
+//     written
+//   - with Split, the chunks are written before the combined volume is
+//     removed
+//   - VerifyAfterEncrypt recombines chunks and removes the deniability
+//     wrapper into temporary files next to the volume
+//   - Armor and ArmorOnly write the armored text while the volume exists
+//
+// The peak of those phases is returned. Zipped input is assumed not to
+// compress, and CDCDedup input to cut into the most chunks it can, so the
+// result is an upper bound when Compress or CDCDedup is set.
+func RequiredFreeSpace(req *EncryptRequest, inputSize int64) int64 {
+	payload := inputSize
+	var zipSize int64
+	original := filepath.Base(req.InputFile)
+	if len(req.InputFiles) > 0 {
+		original = filepath.Base(req.InputFiles[0])
+	}
+	if needsZip(req) {
+		opts := fileops.ZipOptions{
+			Files:      req.InputFiles,
+			RootDir:    zipRootDir(req),
+			Compress:   req.Compress,
+			DirEntries: req.PreserveDirs,
+		}
+		if req.EncryptNames {
+			opts.NameKey = []byte{} // Only checked for nil
+		}
+		zipSize = fileops.MaxZipSize(opts, inputSize)
+		payload = zipSize
+		original = filepath.Base(strings.TrimSuffix(req.OutputPath(), ".pcv"))
+	}
+
+	// Invalid comments fail Encrypt before anything is written
+	comments, err := headerComments(req, original)
+	if err != nil {
+		comments = req.Comments
+	}
+	payloadSize := encryptedPayloadSize(payload, req.ReedSolomon)
+	if req.CDCDedup {
+		payloadSize = cdcMaxPayloadSize(payload)
+	}
+	volume := int64(header.HeaderSize(len(comments))) + payloadSize
+	if req.BlockHashes {
+		volume += header.BlockTableSize((payload + int64(util.MiB) - 1) / int64(util.MiB))
+	}
+	if len(req.RecoveryRecipient) > 0 {
+		volume += header.RecoveryEncSize
+	}
+	if req.StoreSplitLayout {
+		volume += header.SplitLayoutEncSize
+	}
+	if req.Trailer {
+		volume += header.TrailerEncSize
+	}
+	if len(req.PreviewData) > 0 {
+		volume += header.PreviewSize(len(req.PreviewData) + header.PreviewOverhead)
+	}
+
+	// Payload phase: temp zip and .incomplete output
+	peak := zipSize + volume
+
+	final := volume
+	if req.Deniability {
+		final += deniabilityOverhead
+		// The plain volume is renamed to .tmp and wrapped into .incomplete
+		peak = max(peak, zipSize+volume+final)
+	}
+	if req.Split {
+		// Chunks are written next to the combined volume
+		peak = max(peak, zipSize+2*final)
+	}
+
+	// Everything after finalize runs without the temp zip
+	if req.VerifyAfterEncrypt {
+		verify := final
+		if req.Split {
+			verify += final
+		}
+		if req.Deniability {
+			verify += volume
+		}
+		peak = max(peak, verify)
+	}
+	if req.Armor || req.ArmorOnly {
+		peak = max(peak, final+encoding.ArmoredSize(final))
+	}
+
+	return peak
+}
+
+// freeSpace reports the space available on the filesystem holding path;
+// replaced in tests.
+var freeSpace = util.FreeSpace
+
+// RequiredDecryptSpace returns the most disk space Decrypt uses at any one
+// time for req, given the size of the volume (all chunks together when
+// split). The plaintext is taken to be as large as the volume, which it
+// never exceeds. Recombine and Deniability each keep a temporary copy of
+// the volume until the output is complete; AutoUnzip then extracts beside
+// the .zip, counted here as one more copy. A compressed archive can extract
+// to much more, so Decrypt checks again against the sizes in the zip's
+// central directory before extracting.
+func RequiredDecryptSpace(req *DecryptRequest, volumeSize int64) int64 {
+	var temps int64
+	if req.Recombine {
+		temps += volumeSize
+	}
+	if req.Deniability {
+		temps += volumeSize
+	}
+	if !req.writesOutputFile() {
+		return temps
+	}
+
+	var unzip int64
+	if req.AutoUnzip && strings.HasSuffix(req.OutputFile, ".zip") {
+		unzip = volumeSize
+	}
+	return volumeSize + max(temps, unzip)
+}
+
+// checkFreeSpace fails with ErrInsufficientSpace if the filesystem holding
+// dir has less than need bytes available. Where free space cannot be read
+// the check passes and the write itself will fail if space runs out.
+func checkFreeSpace(dir string, need int64) error {
+	free, err := freeSpace(dir)
+	if err != nil {
+		log.Debug("free space unavailable, skipping the check", log.String("dir", dir), log.Err(err))
+		return nil
+	}
+	if free < need {
+		return fmt.Errorf("%w: %s needed, %s available in %s",
+			perrors.ErrInsufficientSpace, util.Sizeify(need), util.Sizeify(free), dir)
+	}
+	return nil
+}
+
+// decryptCheckSpace refuses a decryption that cannot fit before anything is
+// written. Temporary files go beside the volume and the output to its own
+// directory; both are checked against the output's filesystem, where they
+// usually share one.
+func decryptCheckSpace(req *DecryptRequest) error {
+	var size int64
+	if req.Recombine {
+		_, total, err := fileops.CountChunks(splitVolumeBase(req.InputFile))
+		if err != nil {
+			return nil // Recombine reports the missing chunks
+		}
+		size = total
+	} else {
+		stat, err := os.Stat(req.InputFile)
+		if err != nil {
+			return nil // Preprocess reports the missing input
+		}
+		size = stat.Size()
+	}
+
+	need := RequiredDecryptSpace(req, size)
+	if encoding.IsArmored(req.InputFile) {
+		need += size // The binary volume is decoded into a temporary file
+	}
+	dir := filepath.Dir(req.InputFile)
+	if req.writesOutputFile() {
+		dir = filepath.Dir(req.OutputFile)
+	}
+	return checkFreeSpace(dir, need)
+}
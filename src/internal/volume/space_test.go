@@ -0,0 +1,258 @@
+package volume
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+)
+
+// diskUsageReporter records the most space the files in dir take up at any
+// reporter callback, which Encrypt makes after every block it writes.
+type diskUsageReporter struct {
+	GoldenTestReporter
+	dir  string
+	peak int64
+}
+
+func (r *diskUsageReporter) sample() {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return
+	}
+	var total int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+	}
+	r.peak = max(r.peak, total)
+}
+
+func (r *diskUsageReporter) SetStatus(text string) {
+	r.GoldenTestReporter.SetStatus(text)
+	r.sample()
+}
+
+func (r *diskUsageReporter) SetProgress(fraction float32, info string) { r.sample() }
+
+func (r *diskUsageReporter) Update() { r.sample() }
+
+func (r *diskUsageReporter) IsCancelled() bool {
+	r.sample()
+	return r.GoldenTestReporter.IsCancelled()
+}
+
+func TestRequiredFreeSpace(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	// Random data does not compress, so the zip bound stays tight
+	inputDir := t.TempDir()
+	sizes := []int{3<<20 + 12345, 700000}
+	var files []string
+	var inputSize int64
+	for i, size := range sizes {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(inputDir, "folder", "sub"+string(rune('a'+i)), "file.bin")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+		inputSize += int64(size)
+	}
+
+	tests := []struct {
+		name  string
+		multi bool
+		setup func(req *EncryptRequest)
+	}{
+		{"single", false, func(req *EncryptRequest) {}},
+		{"single reed-solomon", false, func(req *EncryptRequest) { req.ReedSolomon = true }},
+		{"single deniability", false, func(req *EncryptRequest) { req.Deniability = true }},
+		{"single split", false, func(req *EncryptRequest) {
+			req.Split, req.ChunkSize, req.ChunkUnit = true, 1, fileops.SplitUnitMiB
+		}},
+		{"single armor", false, func(req *EncryptRequest) { req.Armor = true }},
+		{"single cdc", false, func(req *EncryptRequest) { req.CDCDedup = true }},
+		{"multi", true, func(req *EncryptRequest) {}},
+		{"multi compressed", true, func(req *EncryptRequest) {
+			req.Compress, req.PreserveDirs, req.BlockHashes = true, true, true
+		}},
+		{"multi deniability split", true, func(req *EncryptRequest) {
+			req.Deniability, req.Split, req.ChunkSize, req.ChunkUnit = true, true, 3, fileops.SplitUnitTotal
+		}},
+		{"multi verify split deniability", true, func(req *EncryptRequest) {
+			req.Deniability, req.VerifyAfterEncrypt = true, true
+			req.Split, req.ChunkSize, req.ChunkUnit = true, 2, fileops.SplitUnitMiB
+		}},
+		{"multi names comments", true, func(req *EncryptRequest) {
+			req.EncryptNames, req.StoreOriginalName, req.Comments = true, true, "space test"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+			reporter := &diskUsageReporter{dir: outDir}
+			req := &EncryptRequest{
+				OutputFile: filepath.Join(outDir, "out.pcv"),
+				Password:   "space_password",
+				Reporter:   reporter,
+				RSCodecs:   rsCodecs,
+			}
+			size := int64(sizes[0])
+			if tt.multi {
+				req.InputFiles = files
+				req.OnlyFolders = []string{filepath.Join(inputDir, "folder")}
+				size = inputSize
+			} else {
+				req.InputFile = files[0]
+			}
+			tt.setup(req)
+
+			want := RequiredFreeSpace(req, size)
+			if err := Encrypt(context.Background(), req); err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			reporter.sample()
+
+			if reporter.peak > want {
+				t.Errorf("Observed peak %d exceeds RequiredFreeSpace %d", reporter.peak, want)
+			}
+			// The estimate may only be loose by the zip overhead bound
+			if slack := want - reporter.peak; slack > 4096 {
+				t.Errorf("RequiredFreeSpace %d overestimates observed peak %d by %d bytes", want, reporter.peak, slack)
+			}
+		})
+	}
+}
+
+func TestRequiredDecryptSpace(t *testing.T) {
+	const size = 1000
+	tests := []struct {
+		name string
+		req  DecryptRequest
+		want int64
+	}{
+		{"plain", DecryptRequest{OutputFile: "out.bin"}, size},
+		{"recombine and deniability", DecryptRequest{OutputFile: "out.bin", Recombine: true, Deniability: true}, 3 * size},
+		{"auto unzip", DecryptRequest{OutputFile: "out.zip", AutoUnzip: true}, 2 * size},
+		{"auto unzip without zip", DecryptRequest{OutputFile: "out.bin", AutoUnzip: true}, size},
+		{"recombine and auto unzip", DecryptRequest{OutputFile: "out.zip", Recombine: true, AutoUnzip: true}, 2 * size},
+		{"discarded output", DecryptRequest{DiscardOutput: true, Deniability: true}, size},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RequiredDecryptSpace(&tt.req, size); got != tt.want {
+				t.Errorf("RequiredDecryptSpace = %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptInsufficientSpace(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	// Zeros compress well, so the archive extracts to far more than the volume
+	tmpDir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.bin", "b.bin"} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, make([]byte, 2<<20), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	volumePath := filepath.Join(tmpDir, "backup.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles: files,
+		OnlyFiles:  files,
+		OutputFile: volumePath,
+		Compress:   true,
+		Password:   "space_password",
+		Reporter:   &GoldenTestReporter{},
+		RSCodecs:   rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	stat, err := os.Stat(volumePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var available int64
+	var availableErr error
+	defer func(orig func(string) (int64, error)) { freeSpace = orig }(freeSpace)
+	freeSpace = func(string) (int64, error) { return available, availableErr }
+
+	decrypt := func(t *testing.T) (string, error) {
+		t.Helper()
+		outputPath := filepath.Join(t.TempDir(), "backup.zip")
+		return outputPath, Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: outputPath,
+			Password:   "space_password",
+			AutoUnzip:  true,
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+	}
+
+	t.Run("volume does not fit", func(t *testing.T) {
+		available, availableErr = 1024, nil
+		outputPath, err := decrypt(t)
+		if !errors.Is(err, perrors.ErrInsufficientSpace) {
+			t.Fatalf("expected ErrInsufficientSpace, got %v", err)
+		}
+		entries, _ := os.ReadDir(filepath.Dir(outputPath))
+		if len(entries) != 0 {
+			t.Errorf("nothing should be written before the check, found %d entries", len(entries))
+		}
+	})
+
+	t.Run("archive does not fit", func(t *testing.T) {
+		available, availableErr = RequiredDecryptSpace(&DecryptRequest{OutputFile: "x.zip", AutoUnzip: true}, stat.Size()), nil
+		outputPath, err := decrypt(t)
+		if !errors.Is(err, perrors.ErrUnzipFailed) || !errors.Is(err, perrors.ErrInsufficientSpace) {
+			t.Fatalf("expected ErrUnzipFailed for lack of space, got %v", err)
+		}
+		if _, err := os.Stat(outputPath); err != nil {
+			t.Errorf("decrypted zip should be kept: %v", err)
+		}
+		if _, err := os.Stat(strings.TrimSuffix(outputPath, ".zip")); !os.IsNotExist(err) {
+			t.Error("nothing should be extracted")
+		}
+	})
+
+	t.Run("free space unknown", func(t *testing.T) {
+		available, availableErr = 0, errors.ErrUnsupported
+		outputPath, err := decrypt(t)
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		extracted := filepath.Join(strings.TrimSuffix(outputPath, ".zip"), "a.bin")
+		if _, err := os.Stat(extracted); err != nil {
+			t.Errorf("archive should be extracted: %v", err)
+		}
+	})
+}
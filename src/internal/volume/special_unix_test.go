@@ -0,0 +1,265 @@
+//go:build unix
+
+package volume
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// TestEncryptRejectsFIFO tests that a FIFO in the selection is rejected with
+// ErrNotRegularFile instead of blocking on open with no writer
+func TestEncryptRejectsFIFO(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	regularPath := filepath.Join(tmpDir, "regular.txt")
+	if err := os.WriteFile(regularPath, []byte("regular file"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	fifoPath := filepath.Join(tmpDir, "pipe")
+	if err := syscall.Mkfifo(fifoPath, 0644); err != nil {
+		t.Skipf("mkfifo not supported: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  *EncryptRequest
+	}{
+		{"single", &EncryptRequest{
+			InputFile:  fifoPath,
+			OutputFile: fifoPath + ".pcv",
+		}},
+		{"zipped", &EncryptRequest{
+			InputFiles: []string{regularPath, fifoPath},
+			OnlyFiles:  []string{regularPath, fifoPath},
+			OutputFile: filepath.Join(tmpDir, "encrypted.zip.pcv"),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Password = "fifo_password"
+			tt.req.Reporter = &GoldenTestReporter{}
+			tt.req.RSCodecs = rsCodecs
+
+			if err := tt.req.Validate(); !errors.Is(err, perrors.ErrNotRegularFile) {
+				t.Errorf("Validate: expected ErrNotRegularFile, got: %v", err)
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- Encrypt(context.Background(), tt.req) }()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, perrors.ErrNotRegularFile) {
+					t.Errorf("Encrypt: expected ErrNotRegularFile, got: %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				// Unblock the reader so the goroutine can exit
+				if w, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+					_ = w.Close()
+				}
+				t.Fatal("Encrypt blocked on a FIFO")
+			}
+
+			if _, err := os.Stat(tt.req.OutputFile + ".incomplete"); !os.IsNotExist(err) {
+				t.Error("Rejected encryption left an .incomplete file behind")
+			}
+		})
+	}
+}
+
+// TestPreserveModes tests that file and directory permissions survive an
+// encrypt, decrypt and unzip round trip when PreserveDirs and RestoreModes
+// are set, less the umask and any setuid bit, and are not restored otherwise
+func TestPreserveModes(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	folder := filepath.Join(srcDir, "tree")
+	private := filepath.Join(folder, "private")
+	if err := os.MkdirAll(private, 0755); err != nil {
+		t.Fatalf("Failed to create folders: %v", err)
+	}
+
+	modes := map[string]os.FileMode{
+		"tree/readme.txt":         0644,
+		"tree/run.sh":             0755,
+		"tree/setuid":             0755 | os.ModeSetuid,
+		"tree/private/secret.txt": 0600,
+	}
+	var inputs []string
+	for rel, mode := range modes {
+		path := filepath.Join(srcDir, rel)
+		if err := os.WriteFile(path, []byte(rel), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", rel, err)
+		}
+		// Chmod explicitly so the umask does not affect the expected modes
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("Failed to chmod %s: %v", rel, err)
+		}
+		inputs = append(inputs, path)
+	}
+	if err := os.Chmod(private, 0700); err != nil {
+		t.Fatalf("Failed to chmod private: %v", err)
+	}
+	if err := os.Chmod(folder, 0750); err != nil {
+		t.Fatalf("Failed to chmod tree: %v", err)
+	}
+
+	volumePath := filepath.Join(tmpDir, "tree.zip.pcv")
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFiles:   inputs,
+		OnlyFolders:  []string{folder},
+		OutputFile:   volumePath,
+		Password:     "modes_password",
+		PreserveDirs: true,
+		Reporter:     &GoldenTestReporter{},
+		RSCodecs:     rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	unzip := func(restore bool) string {
+		outDir := filepath.Join(t.TempDir(), "out")
+		if err := os.Mkdir(outDir, 0755); err != nil {
+			t.Fatalf("Failed to create output folder: %v", err)
+		}
+		err := Decrypt(context.Background(), &DecryptRequest{
+			InputFile:    volumePath,
+			OutputFile:   filepath.Join(outDir, "tree.zip"),
+			Password:     "modes_password",
+			AutoUnzip:    true,
+			SameLevel:    true,
+			RestoreModes: restore,
+			Reporter:     &GoldenTestReporter{},
+			RSCodecs:     rsCodecs,
+		})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		return outDir
+	}
+
+	mask := os.FileMode(syscall.Umask(0))
+	syscall.Umask(int(mask))
+
+	modes["tree"] = 0750
+	modes["tree/private"] = 0700
+	outDir := unzip(true)
+	for rel, mode := range modes {
+		info, err := os.Stat(filepath.Join(outDir, rel))
+		if err != nil {
+			t.Errorf("Missing %s after unzip: %v", rel, err)
+			continue
+		}
+		want := mode.Perm() &^ mask
+		if got := info.Mode() & (os.ModePerm | os.ModeSetuid); got != want {
+			t.Errorf("%s: mode %o, want %o", rel, got, want)
+		}
+	}
+
+	// Without RestoreModes nothing is made executable
+	outDir = unzip(false)
+	if info, err := os.Stat(filepath.Join(outDir, "tree/run.sh")); err != nil {
+		t.Errorf("Missing run.sh after unzip: %v", err)
+	} else if info.Mode().Perm()&0111 != 0 {
+		t.Errorf("run.sh: mode %o restored without RestoreModes", info.Mode().Perm())
+	}
+}
+
+// TestEncryptReadOnlyOutputDir tests that a folder encrypted to a directory
+// it cannot write to fails with ErrOutputDirNotWritable before zipping,
+// leaving nothing behind
+func TestEncryptReadOnlyOutputDir(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	folder := filepath.Join(tmpDir, "folder")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(folder, "file.txt")
+	if err := os.WriteFile(input, []byte("read-only output"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := filepath.Join(tmpDir, "readonly")
+	if err := os.Mkdir(readOnly, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(readOnly, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(readOnly, 0755) })
+
+	// A regular file in place of the directory fails even for root
+	notDir := filepath.Join(tmpDir, "notdir")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, dir := range map[string]string{"read-only": readOnly, "not a directory": notDir} {
+		t.Run(name, func(t *testing.T) {
+			if dir == readOnly && os.Geteuid() == 0 {
+				t.Skip("root can write to read-only directories")
+			}
+
+			req := &EncryptRequest{
+				InputFiles:  []string{input},
+				OnlyFolders: []string{folder},
+				OutputFile:  filepath.Join(dir, "folder.zip.pcv"),
+				Password:    "readonly_password",
+				RSCodecs:    rsCodecs,
+			}
+			if err := req.Validate(); !errors.Is(err, perrors.ErrOutputDirNotWritable) {
+				t.Errorf("Validate: expected ErrOutputDirNotWritable, got %v", err)
+			}
+
+			reporter := &GoldenTestReporter{}
+			req.Reporter = reporter
+			err := Encrypt(context.Background(), req)
+			if !errors.Is(err, perrors.ErrOutputDirNotWritable) {
+				t.Fatalf("Encrypt: expected ErrOutputDirNotWritable, got %v", err)
+			}
+			if reporter.status != "" {
+				t.Errorf("work started before the check failed: status %q", reporter.status)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(readOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("leftover file in output directory: %s", e.Name())
+	}
+	entries, err = os.ReadDir(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("input folder has %d entries, want only the input file", len(entries))
+	}
+}
@@ -0,0 +1,46 @@
+package volume
+
+import (
+	"Picocrypt-NG/internal/crypto"
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/header"
+)
+
+// volumeTrailer describes the volume being encrypted, whose payload ends at
+// payloadEnd, for EncryptRequest.Trailer.
+func volumeTrailer(ctx *OperationContext, payloadEnd int64) *header.Trailer {
+	h := ctx.Header
+	t := header.NewTrailer(h)
+
+	t.Argon2Memory = crypto.Argon2NormalMemory
+	if h.Flags.Paranoid {
+		t.Argon2Memory = crypto.Argon2ParanoidMemory
+	}
+	t.Argon2Passes = int(h.Flags.Passes)
+	if t.Argon2Passes == 0 {
+		t.Argon2Passes = int(crypto.Argon2Passes(h.Flags.Paranoid))
+	}
+	t.Argon2Threads = int(h.Flags.Threads)
+	if t.Argon2Threads == 0 {
+		t.Argon2Threads = int(crypto.Argon2Threads(h.Flags.Paranoid))
+	}
+
+	section := func(present bool, offset, size int64, codec int) header.FieldLocation {
+		if !present {
+			return header.FieldLocation{Offset: offset}
+		}
+		return header.FieldLocation{Offset: offset, Size: size, Codec: codec}
+	}
+	// validateTrailer rules out BlockHashes, so there is never a block table
+	t.Fields[header.FieldBlockTable] = header.FieldLocation{Offset: ctx.HeaderOffsets.End}
+	t.Fields[header.FieldRecovery] = section(h.Flags.Recovery, ctx.RecoveryOffset(), header.RecoveryEncSize, 16)
+	t.Fields[header.FieldSplitLayout] = section(h.Flags.SplitLayout, ctx.SplitLayoutOffset(), header.SplitLayoutEncSize, 16)
+	t.Fields[header.FieldPreview] = section(h.Flags.Preview, ctx.PreviewOffset(), ctx.previewSize(), 0)
+
+	payload := header.FieldLocation{Offset: ctx.PayloadOffset(), Size: payloadEnd - ctx.PayloadOffset()}
+	if h.Flags.ReedSolomon {
+		payload.Codec = encoding.RS128DataSize
+	}
+	t.Fields[header.FieldPayload] = payload
+	return t
+}
@@ -0,0 +1,221 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+
+	"Picocrypt-NG/internal/crypto"
+	"Picocrypt-NG/internal/encoding"
+	"Picocrypt-NG/internal/header"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestTrailer(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+	_, recipient, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	plaintext := bytes.Repeat([]byte("described "), 30000)
+	inputPath := filepath.Join(tmpDir, "layout.txt")
+	if err := os.WriteFile(inputPath, plaintext, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	volumePath := inputPath + ".pcv"
+	err = Encrypt(context.Background(), &EncryptRequest{
+		InputFile:         inputPath,
+		OutputFile:        volumePath,
+		Password:          "trailer_password",
+		Comments:          "self-describing",
+		PreviewData:       []byte("thumbnail"),
+		RecoveryRecipient: recipient,
+		Trailer:           true,
+		Reporter:          &GoldenTestReporter{},
+		RSCodecs:          rsCodecs,
+	})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	decrypt := func() error {
+		return Decrypt(context.Background(), &DecryptRequest{
+			InputFile:  volumePath,
+			OutputFile: filepath.Join(t.TempDir(), "layout.txt"),
+			Password:   "trailer_password",
+			Reporter:   &GoldenTestReporter{},
+			RSCodecs:   rsCodecs,
+		})
+	}
+	if err := decrypt(); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+
+	t.Run("descriptor locates and verifies every field", func(t *testing.T) {
+		data, err := os.ReadFile(volumePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := header.ReadTrailer(bytes.NewReader(data), rsCodecs)
+		if err != nil {
+			t.Fatalf("ReadTrailer failed: %v", err)
+		}
+
+		// The fields tile the volume up to the trailer
+		locs := append([]header.FieldLocation(nil), tr.Fields[:]...)
+		sort.SliceStable(locs, func(i, j int) bool { return locs[i].Offset < locs[j].Offset })
+		var end int64
+		for _, loc := range locs {
+			if loc.Offset != end {
+				t.Fatalf("field at %d leaves a gap or overlap after %d", loc.Offset, end)
+			}
+			end += loc.Size
+		}
+		if end != int64(len(data))-header.TrailerEncSize {
+			t.Fatalf("fields end at %d; the trailer starts at %d", end, len(data)-header.TrailerEncSize)
+		}
+
+		// Read each field where the descriptor says and decode it with its codec
+		field := func(f header.TrailerField) []byte {
+			loc := tr.Fields[f]
+			raw := data[loc.Offset : loc.Offset+loc.Size]
+			if loc.Codec == 0 {
+				return raw
+			}
+			fec := rsCodecs.Codec(loc.Codec)
+			if fec == nil {
+				t.Fatalf("%s: unknown codec %d", f, loc.Codec)
+			}
+			var dec []byte
+			for i := 0; i < len(raw); i += fec.Total() {
+				block, err := encoding.Decode(fec, raw[i:i+fec.Total()], false)
+				if err != nil {
+					t.Fatalf("%s: %v", f, err)
+				}
+				dec = append(dec, block...)
+			}
+			return dec
+		}
+
+		parsed, _, err := header.Parse(bytes.NewReader(data), rsCodecs)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if got := string(field(header.FieldComments)); got != parsed.Comments {
+			t.Errorf("comments = %q; want %q", got, parsed.Comments)
+		}
+		for _, c := range []struct {
+			f    header.TrailerField
+			want []byte
+		}{
+			{header.FieldSalt, parsed.Salt},
+			{header.FieldHKDFSalt, parsed.HKDFSalt},
+			{header.FieldSerpentIV, parsed.SerpentIV},
+			{header.FieldNonce, parsed.Nonce},
+			{header.FieldKeyHash, parsed.KeyHash},
+			{header.FieldAuthTag, parsed.AuthTag},
+		} {
+			if !bytes.Equal(field(c.f), c.want) {
+				t.Errorf("%s differs from the parsed header", c.f)
+			}
+		}
+
+		// Derive the keys from the recorded Argon2 parameters
+		key := argon2.IDKey([]byte("trailer_password"), field(header.FieldSalt),
+			uint32(tr.Argon2Passes), uint32(tr.Argon2Memory), uint8(tr.Argon2Threads), crypto.Argon2KeySize)
+		subkeys := crypto.NewSubkeyReader(crypto.NewHKDFStream(key, field(header.FieldHKDFSalt)))
+		headerSubkey, err := subkeys.HeaderSubkey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		macSubkey, err := subkeys.MACSubkey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The header MAC covers the fields, the sections and the trailer
+		flags := field(header.FieldFlags)
+		commentsLen, err := strconv.Atoi(string(field(header.FieldCommentsLen)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := &header.RawHeaderFields{
+			Version:     field(header.FieldVersion),
+			CommentsLen: commentsLen,
+			Comments:    field(header.FieldComments),
+			Flags:       flags,
+		}
+		h := &header.VolumeHeader{
+			Flags:        header.FlagsFromBytes(flags),
+			Salt:         field(header.FieldSalt),
+			HKDFSalt:     field(header.FieldHKDFSalt),
+			SerpentIV:    field(header.FieldSerpentIV),
+			Nonce:        field(header.FieldNonce),
+			KeyHash:      field(header.FieldKeyHash),
+			RecoveryWrap: field(header.FieldRecovery),
+			Trailer:      tr,
+		}
+		if !header.VerifyV2HeaderRaw(headerSubkey, raw, h, field(header.FieldKeyfileHash), nil).Valid {
+			t.Error("header MAC does not verify from the described fields")
+		}
+
+		// And the payload MAC the ciphertext the descriptor locates
+		mac, err := crypto.NewMAC(macSubkey, tr.MAC == header.MACHMACSHA3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mac.Write(field(header.FieldPayload))
+		if !bytes.Equal(mac.Sum(nil), field(header.FieldAuthTag)) {
+			t.Error("payload MAC does not verify from the described payload")
+		}
+	})
+
+	t.Run("trailer is authenticated", func(t *testing.T) {
+		f, err := os.OpenFile(volumePath, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := header.ReadTrailer(f, rsCodecs)
+		if err != nil {
+			t.Fatalf("ReadTrailer failed: %v", err)
+		}
+		start, err := f.Seek(-header.TrailerEncSize, io.SeekEnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.Argon2Threads++
+		err = header.WriteTrailer(f, start, tr, rsCodecs)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("WriteTrailer failed: %v", err)
+		}
+
+		var authErr *header.AuthError
+		if err := decrypt(); !errors.As(err, &authErr) {
+			t.Errorf("expected an AuthError for a rewritten trailer, got %v", err)
+		}
+	})
+
+	t.Run("rejected with deniability or block hashes", func(t *testing.T) {
+		for _, req := range []*EncryptRequest{
+			{InputFile: inputPath, OutputFile: volumePath, Password: "p", Trailer: true, Deniability: true},
+			{InputFile: inputPath, OutputFile: volumePath, Password: "p", Trailer: true, BlockHashes: true},
+		} {
+			if err := req.Validate(); err == nil {
+				t.Errorf("Validate accepted Trailer with %+v", req)
+			}
+		}
+	})
+}
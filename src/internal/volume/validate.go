@@ -1,9 +1,16 @@
 package volume
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"Picocrypt-NG/internal/crypto"
 	"Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/fileops"
+	"Picocrypt-NG/internal/header"
+	"Picocrypt-NG/internal/util"
 )
 
 // Validate checks that the EncryptRequest has all required fields and valid configuration.
@@ -15,14 +22,23 @@ func (req *EncryptRequest) Validate() error {
 	}
 
 	// Check for credentials
-	if req.Password == "" && len(req.Keyfiles) == 0 {
+	if req.Password == "" && req.PasswordFunc == nil && len(req.Keyfiles) == 0 {
 		return errors.ErrNoCredentials
 	}
 
 	// Check output file is specified
-	if req.OutputFile == "" {
+	if req.OutputPath() == "" {
 		return errors.NewValidationError("OutputFile", "output file path is required")
 	}
+	if err := checkOutputDirWritable(fileSystem(req.FS), req.OutputPath(), req.CreateOutputDirs); err != nil {
+		return err
+	}
+	if err := checkEncryptSameInputOutput(req); err != nil {
+		return err
+	}
+	if err := encryptCheckFileSystem(req); err != nil {
+		return err
+	}
 
 	// Validate split options
 	if req.Split {
@@ -31,26 +47,202 @@ func (req *EncryptRequest) Validate() error {
 		}
 	}
 
-	// Validate input files exist
-	if req.InputFile != "" {
-		if _, err := os.Stat(req.InputFile); err != nil {
-			return errors.NewFileError("stat", req.InputFile, err)
+	if req.StoreKeyfileNames {
+		if err := validateKeyfileNames(req); err != nil {
+			return err
 		}
 	}
 
-	for _, f := range req.InputFiles {
-		if _, err := os.Stat(f); err != nil {
-			return errors.NewFileError("stat", f, err)
+	if err := validateArmor(req); err != nil {
+		return err
+	}
+	if err := validateCDC(req); err != nil {
+		return err
+	}
+	if err := validatePreview(req); err != nil {
+		return err
+	}
+	if err := validateComments(req); err != nil {
+		return err
+	}
+	if err := validateRecovery(req); err != nil {
+		return err
+	}
+	if err := validateSplitLayout(req); err != nil {
+		return err
+	}
+	if err := validateTrailer(req); err != nil {
+		return err
+	}
+	if err := validateIndex(req); err != nil {
+		return err
+	}
+	if err := validateCompression(req); err != nil {
+		return err
+	}
+	if err := validatePasswordScore(req); err != nil {
+		return err
+	}
+	if err := validateRecordSplit(req); err != nil {
+		return err
+	}
+
+	if _, err := argon2Threads(req); err != nil {
+		return err
+	}
+
+	// Validate input files exist and are regular files
+	if req.InputFile != "" {
+		if err := checkRegularFile(fileSystem(req.FS), req.InputFile); err != nil {
+			return err
 		}
 	}
+	if err := checkRegularFiles(fileSystem(req.FS), req.InputFiles); err != nil {
+		return err
+	}
 
 	// Validate keyfiles exist
+	if err := checkKeyfileCount(req.Keyfiles, req.MaxKeyfiles); err != nil {
+		return err
+	}
 	for _, kf := range req.Keyfiles {
 		if _, err := os.Stat(kf); err != nil {
 			return errors.NewFileError("stat", kf, err)
 		}
 	}
+	if err := checkKeyfilesInInput(req); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateKeyfileNames checks that storing keyfile name hints makes sense.
+// The names would identify a deniable volume, so the two are exclusive.
+func validateKeyfileNames(req *EncryptRequest) error {
+	if len(req.Keyfiles) == 0 {
+		return errors.NewValidationError("StoreKeyfileNames", "no keyfiles to record")
+	}
+	if req.Deniability {
+		return errors.NewValidationError("StoreKeyfileNames", "cannot store keyfile names in a deniable volume")
+	}
+	return nil
+}
+
+// validateArmor rejects armor for split output: each chunk would need its
+// own markers and could no longer be recombined by concatenation.
+func validateArmor(req *EncryptRequest) error {
+	if (req.Armor || req.ArmorOnly) && req.Split {
+		return errors.NewValidationError("Armor", "cannot armor a split volume")
+	}
+	return nil
+}
+
+// validateCDC rejects options CDCDedup cannot be combined with. Reed-Solomon
+// and block hashes work on fixed 1 MiB blocks, paranoid mode promises a
+// cipher cascade the chunk records do not use, a deniable volume must not
+// share ciphertext with other volumes, and the payload size is not known in
+// advance for Preallocate.
+func validateCDC(req *EncryptRequest) error {
+	if !req.CDCDedup {
+		return nil
+	}
+	switch {
+	case req.ReedSolomon:
+		return errors.NewValidationError("CDCDedup", "cannot be combined with Reed-Solomon")
+	case req.BlockHashes:
+		return errors.NewValidationError("CDCDedup", "cannot be combined with block hashes")
+	case req.Paranoid:
+		return errors.NewValidationError("CDCDedup", "cannot be combined with paranoid mode")
+	case req.Deniability:
+		return errors.NewValidationError("CDCDedup", "cannot be combined with deniability")
+	case req.Preallocate:
+		return errors.NewValidationError("CDCDedup", "cannot be combined with preallocation")
+	}
+	return nil
+}
+
+// validatePreview enforces the preview size cap.
+func validatePreview(req *EncryptRequest) error {
+	if len(req.PreviewData) > header.MaxPreviewSize {
+		return errors.NewValidationError("PreviewData",
+			fmt.Sprintf("preview is %d bytes; at most %d are allowed", len(req.PreviewData), header.MaxPreviewSize))
+	}
+	return nil
+}
+
+// validateComments checks Comments, which share the header's comments field
+// with the original name and keyfile name hints.
+func validateComments(req *EncryptRequest) error {
+	if err := header.CheckUserComments(req.Comments); err != nil {
+		return errors.NewValidationError("Comments", err.Error())
+	}
+	return nil
+}
+
+// validateRecovery checks RecoveryRecipient. A deniable volume must not
+// carry a section that identifies it, and the CDC dedup key comes from the
+// password rather than the wrapped volume keys, so the recovery key alone
+// could not decrypt the chunks.
+func validateRecovery(req *EncryptRequest) error {
+	if req.RecoveryRecipient == nil {
+		return nil
+	}
+	if len(req.RecoveryRecipient) != RecoveryKeySize {
+		return errors.NewValidationError("RecoveryRecipient", fmt.Sprintf("must be %d bytes", RecoveryKeySize))
+	}
+	if req.Deniability {
+		return errors.NewValidationError("RecoveryRecipient", "cannot be combined with deniability")
+	}
+	if req.CDCDedup {
+		return errors.NewValidationError("RecoveryRecipient", "cannot be combined with CDCDedup")
+	}
+	return nil
+}
 
+// validateSplitLayout checks StoreSplitLayout. The layout is recorded in
+// the volume before any deniability wrapper changes its size.
+func validateSplitLayout(req *EncryptRequest) error {
+	if !req.StoreSplitLayout {
+		return nil
+	}
+	if !req.Split {
+		return errors.NewValidationError("StoreSplitLayout", "requires Split")
+	}
+	if req.Deniability {
+		return errors.NewValidationError("StoreSplitLayout", "cannot be combined with deniability")
+	}
+	return nil
+}
+
+// validateTrailer checks Trailer. The trailer ends the volume, so a
+// deniability wrapper would hide it and a prefix checked with VerifyBlocks
+// would lack it.
+func validateTrailer(req *EncryptRequest) error {
+	if !req.Trailer {
+		return nil
+	}
+	if req.Deniability {
+		return errors.NewValidationError("Trailer", "cannot be combined with deniability")
+	}
+	if req.BlockHashes {
+		return errors.NewValidationError("Trailer", "cannot be combined with BlockHashes")
+	}
+	return nil
+}
+
+// validatePasswordScore enforces MinPasswordScore. Keyfile-only requests
+// have no password to score.
+func validatePasswordScore(req *EncryptRequest) error {
+	if req.MinPasswordScore < 0 || req.MinPasswordScore > 4 {
+		return errors.NewValidationError("MinPasswordScore", "must be between 0 and 4")
+	}
+	if req.MinPasswordScore == 0 || req.Password == "" {
+		return nil
+	}
+	if score := util.PasswordScore(req.Password); score < req.MinPasswordScore {
+		return fmt.Errorf("%w: scores %d of 4, at least %d required", errors.ErrWeakPassword, score, req.MinPasswordScore)
+	}
 	return nil
 }
 
@@ -62,20 +254,36 @@ func (req *DecryptRequest) Validate() error {
 		return errors.NewValidationError("InputFile", "input file path is required")
 	}
 
-	// Check input file exists
-	if _, err := os.Stat(req.InputFile); err != nil {
-		return errors.NewFileError("stat", req.InputFile, err)
+	// Check input file exists; for split volumes that is the first chunk
+	inputFile := req.InputFile
+	if req.Recombine {
+		inputFile = splitVolumeBase(inputFile) + ".0"
+	}
+	if _, err := fileSystem(req.FS).Stat(inputFile); err != nil {
+		return errors.NewFileError("stat", inputFile, err)
 	}
 
 	// Note: We don't require password/keyfiles here because they may be
 	// provided separately based on header information (keyfiles required flag)
 
 	// Check output file is specified
-	if req.OutputFile == "" {
+	if req.OutputFile == "" && req.writesOutputFile() {
 		return errors.NewValidationError("OutputFile", "output file path is required")
 	}
+	if err := checkDecryptSameInputOutput(req); err != nil {
+		return err
+	}
+	if err := decryptCheckFileSystem(req); err != nil {
+		return err
+	}
+	if err := checkExpectedContent(req); err != nil {
+		return err
+	}
 
 	// Validate keyfiles exist if provided
+	if err := checkDecryptKeyfileCount(req); err != nil {
+		return err
+	}
 	for _, kf := range req.Keyfiles {
 		if _, err := os.Stat(kf); err != nil {
 			return errors.NewFileError("stat", kf, err)
@@ -88,7 +296,7 @@ func (req *DecryptRequest) Validate() error {
 // ValidateCredentials checks that credentials are provided for decryption.
 // This should be called after reading the header to know if keyfiles are required.
 func (req *DecryptRequest) ValidateCredentials(keyfilesRequired bool) error {
-	hasPassword := req.Password != ""
+	hasPassword := req.Password != "" || req.PasswordFunc != nil
 	hasKeyfiles := len(req.Keyfiles) > 0
 
 	// Must have at least one credential type
@@ -132,6 +340,18 @@ func (b *EncryptRequestBuilder) WithOutputFile(path string) *EncryptRequestBuild
 	return b
 }
 
+// WithOutputName sets the output path without extension.
+func (b *EncryptRequestBuilder) WithOutputName(name string) *EncryptRequestBuilder {
+	b.req.OutputName = name
+	return b
+}
+
+// WithCreateOutputDirs creates missing output directories.
+func (b *EncryptRequestBuilder) WithCreateOutputDirs(create bool) *EncryptRequestBuilder {
+	b.req.CreateOutputDirs = create
+	return b
+}
+
 // WithPassword sets the encryption password.
 func (b *EncryptRequestBuilder) WithPassword(password string) *EncryptRequestBuilder {
 	b.req.Password = password
@@ -202,3 +422,201 @@ func (b *EncryptRequestBuilder) Build() (*EncryptRequest, error) {
 func (b *EncryptRequestBuilder) BuildUnchecked() *EncryptRequest {
 	return &b.req
 }
+
+// checkRegularFile returns an error if path cannot be stat'ed on fs or is
+// not a regular file. FIFOs, sockets and device nodes pass os.Stat but
+// reading them can block forever or never reach EOF, so they are rejected
+// up front.
+func checkRegularFile(fs FileSystem, path string) error {
+	stat, err := fs.Stat(path)
+	if err != nil {
+		return errors.NewFileError("stat", path, err)
+	}
+	if !stat.Mode().IsRegular() {
+		return errors.NewFileError("stat", path, errors.ErrNotRegularFile)
+	}
+	return nil
+}
+
+// checkOutputDirWritable returns ErrOutputDirNotWritable if a file cannot
+// be created in the directory of outputFile. Encrypt writes its temp zip and
+// .incomplete file there, so this catches a read-only destination before
+// any input is scanned or zipped. A missing directory is ErrOutputDirMissing
+// unless create is set, in which case it is not checked further.
+func checkOutputDirWritable(fs FileSystem, outputFile string, create bool) error {
+	dir := filepath.Dir(outputFile)
+	if _, err := fs.Stat(dir); os.IsNotExist(err) {
+		if create {
+			return nil
+		}
+		return errors.NewFileError("write", dir, errors.ErrOutputDirMissing)
+	}
+	suffix, err := crypto.RandomBytes(4)
+	if err != nil {
+		return err
+	}
+	probePath := filepath.Join(dir, fmt.Sprintf(".picocrypt-probe-%x", suffix))
+	probe, err := fs.OpenFile(probePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.NewFileError("write", dir, fmt.Errorf("%w: %w", errors.ErrOutputDirNotWritable, err))
+	}
+	_ = probe.Close()
+	_ = fs.Remove(probePath)
+	return nil
+}
+
+// createOutputDir creates the missing parents of outputFile with
+// owner-only permissions.
+func createOutputDir(outputFile string) error {
+	dir := filepath.Dir(outputFile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.NewFileError("mkdir", dir, err)
+	}
+	return nil
+}
+
+// samePath reports whether a and b name the same file: equal once cleaned
+// and made absolute or, when both exist, the same file on disk, which also
+// catches links and case-insensitive filesystems.
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA == nil && errB == nil && absA == absB {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// sameInputOutputError reports that output would overwrite input.
+func sameInputOutputError(output, input string) error {
+	return errors.NewFileError("write", output, fmt.Errorf("%w: %s", errors.ErrSameInputOutput, input))
+}
+
+// checkEncryptSameInputOutput fails with ErrSameInputOutput if the volume or
+// a file Encrypt writes on the way to it (the .incomplete file, the temp zip,
+// the deniability copy and the split chunks) is one of the inputs.
+func checkEncryptSameInputOutput(req *EncryptRequest) error {
+	output := req.OutputPath()
+	written := []string{output, output + ".incomplete"}
+	if needsZip(req) {
+		written = append(written, strings.TrimSuffix(output, ".pcv")+".tmp")
+	}
+	if req.Deniability {
+		written = append(written, output+".tmp")
+	}
+	absOutput, _ := filepath.Abs(output)
+
+	inputs := req.InputFiles
+	if len(inputs) == 0 {
+		inputs = []string{req.InputFile}
+	}
+	for _, input := range inputs {
+		for _, path := range written {
+			if samePath(input, path) {
+				return sameInputOutputError(path, input)
+			}
+		}
+		if absInput, err := filepath.Abs(input); req.Split && err == nil && fileops.IsChunkPath(absInput, absOutput) {
+			return sameInputOutputError(absInput, input)
+		}
+	}
+	return nil
+}
+
+// DefaultMaxKeyfiles is the keyfile limit when a request's MaxKeyfiles is 0.
+const DefaultMaxKeyfiles = 64
+
+// checkKeyfileCount enforces EncryptRequest.MaxKeyfiles.
+func checkKeyfileCount(keyfiles []string, limit int) error {
+	if limit == 0 {
+		limit = DefaultMaxKeyfiles
+	}
+	if limit > 0 && len(keyfiles) > limit {
+		return fmt.Errorf("%w: %d selected, at most %d allowed", errors.ErrTooManyKeyfiles, len(keyfiles), limit)
+	}
+	return nil
+}
+
+// checkDecryptKeyfileCount enforces DecryptRequest.MaxKeyfiles. Unlike
+// encryption there is no default limit: a volume made with more keyfiles
+// must still open.
+func checkDecryptKeyfileCount(req *DecryptRequest) error {
+	if req.MaxKeyfiles == 0 {
+		return nil
+	}
+	return checkKeyfileCount(req.Keyfiles, req.MaxKeyfiles)
+}
+
+// checkKeyfilesInInput fails with ErrKeyfileInInput if a keyfile is one of
+// the inputs or inside a folder being encrypted, where it would be locked
+// into the volume it is needed to open.
+func checkKeyfilesInInput(req *EncryptRequest) error {
+	inputs := req.InputFiles
+	if len(inputs) == 0 && req.InputFile != "" {
+		inputs = []string{req.InputFile}
+	}
+	for _, kf := range req.Keyfiles {
+		for _, folder := range req.OnlyFolders {
+			if withinDir(folder, kf) {
+				return errors.NewFileError("read", kf, fmt.Errorf("%w: %s", errors.ErrKeyfileInInput, folder))
+			}
+		}
+		for _, input := range inputs {
+			if samePath(kf, input) {
+				return errors.NewFileError("read", kf, errors.ErrKeyfileInInput)
+			}
+		}
+	}
+	return nil
+}
+
+// withinDir reports whether path is dir or below it, once both are cleaned
+// and made absolute.
+func withinDir(dir, path string) bool {
+	absDir, errDir := filepath.Abs(dir)
+	absPath, errPath := filepath.Abs(path)
+	if errDir != nil || errPath != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkDecryptSameInputOutput fails with ErrSameInputOutput if the output or
+// its .incomplete file is the volume, one of its chunks, or the path chunks
+// are recombined into.
+func checkDecryptSameInputOutput(req *DecryptRequest) error {
+	if !req.writesOutputFile() {
+		return nil
+	}
+	inputs := []string{req.InputFile}
+	base := ""
+	if req.Recombine {
+		base = splitVolumeBase(req.InputFile)
+		inputs = append(inputs, base)
+		base, _ = filepath.Abs(base)
+	}
+	for _, path := range []string{req.OutputFile, req.OutputFile + ".incomplete"} {
+		for _, input := range inputs {
+			if samePath(path, input) {
+				return sameInputOutputError(path, input)
+			}
+		}
+		if absPath, err := filepath.Abs(path); base != "" && err == nil && fileops.IsChunkPath(absPath, base) {
+			return sameInputOutputError(path, absPath)
+		}
+	}
+	return nil
+}
+
+// checkRegularFiles calls checkRegularFile for each path.
+func checkRegularFiles(fs FileSystem, paths []string) error {
+	for _, path := range paths {
+		if err := checkRegularFile(fs, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,10 +1,15 @@
 package volume
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"Picocrypt-NG/internal/encoding"
 	"Picocrypt-NG/internal/errors"
 )
 
@@ -248,3 +253,314 @@ func TestEncryptRequestBuilderUnchecked(t *testing.T) {
 		t.Error("BuildUnchecked() should return request even if invalid")
 	}
 }
+
+func TestMinPasswordScore(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("test content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keyfile := filepath.Join(tmpDir, "key.bin")
+	if err := os.WriteFile(keyfile, []byte("keyfile content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		keyfiles []string
+		wantErr  error
+	}{
+		{"weak password", "password123", nil, errors.ErrWeakPassword},
+		{"strong password", "vK7#qT!m9zR@x2Lw$eB4", nil, nil},
+		{"keyfile only", "", []string{keyfile}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &EncryptRequest{
+				InputFile:        testFile,
+				OutputFile:       filepath.Join(t.TempDir(), "out.pcv"),
+				Password:         tt.password,
+				Keyfiles:         tt.keyfiles,
+				MinPasswordScore: 3,
+				Reporter:         &GoldenTestReporter{},
+				RSCodecs:         rsCodecs,
+			}
+			if err := req.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			err := Encrypt(context.Background(), req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Encrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, statErr := os.Stat(req.OutputFile)
+			if written := statErr == nil; written != (tt.wantErr == nil) {
+				t.Errorf("volume written = %v; want %v", written, tt.wantErr == nil)
+			}
+		})
+	}
+
+	// Scores only go up to 4
+	req := &EncryptRequest{
+		InputFile:        testFile,
+		OutputFile:       filepath.Join(tmpDir, "out.pcv"),
+		Password:         "vK7#qT!m9zR@x2Lw$eB4",
+		MinPasswordScore: 5,
+	}
+	var verr *errors.ValidationError
+	if err := req.Validate(); !errors.As(err, &verr) {
+		t.Errorf("Validate() with MinPasswordScore 5: expected ValidationError, got %v", err)
+	}
+}
+
+func TestSameInputOutput(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	content := []byte("must survive")
+	write := func(name string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	input := write("input.txt")
+	incomplete := write("volume.pcv.incomplete")
+	chunk := write("split.pcv.2")
+	stale := write("split.pcv.1.incomplete")
+	volumeChunk := write("backup.pcv.0")
+	_ = write("backup.pcv.1")
+
+	encrypts := []struct {
+		name    string
+		req     EncryptRequest
+		wantErr bool
+	}{
+		{"output is the input", EncryptRequest{InputFile: input, OutputFile: input}, true},
+		{"output is the input uncleaned", EncryptRequest{InputFile: input, OutputFile: filepath.Join(tmpDir, "sub", "..", "input.txt")}, true},
+		{"incomplete file is the input", EncryptRequest{InputFile: incomplete, OutputFile: filepath.Join(tmpDir, "volume.pcv")}, true},
+		{"split chunk is the input", EncryptRequest{InputFile: chunk, OutputFile: filepath.Join(tmpDir, "split.pcv"), Split: true, ChunkSize: 1}, true},
+		{"incomplete split chunk is the input", EncryptRequest{InputFile: stale, OutputFile: filepath.Join(tmpDir, "split.pcv"), Split: true, ChunkSize: 1}, true},
+		{"one of several inputs", EncryptRequest{InputFiles: []string{chunk, input}, OnlyFiles: []string{chunk, input}, OutputName: filepath.Join(tmpDir, "input.txt.zip.pcv", "..", "input")}, false},
+		{"chunk name without split", EncryptRequest{InputFile: chunk, OutputFile: filepath.Join(tmpDir, "split.pcv")}, false},
+		{"distinct output", EncryptRequest{InputFile: input, OutputFile: input + ".pcv"}, false},
+	}
+	for _, tt := range encrypts {
+		t.Run("encrypt/"+tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Password = "same_password"
+			err := req.Validate()
+			if got := errors.Is(err, errors.ErrSameInputOutput); got != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want ErrSameInputOutput: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			req.Reporter = &GoldenTestReporter{}
+			req.RSCodecs = rsCodecs
+			if err := Encrypt(context.Background(), &req); !errors.Is(err, errors.ErrSameInputOutput) {
+				t.Errorf("Encrypt() error = %v, want ErrSameInputOutput", err)
+			}
+		})
+	}
+
+	decrypts := []struct {
+		name    string
+		req     DecryptRequest
+		wantErr bool
+	}{
+		{"output is the volume", DecryptRequest{InputFile: input, OutputFile: input}, true},
+		{"incomplete file is the volume", DecryptRequest{InputFile: incomplete, OutputFile: filepath.Join(tmpDir, "volume.pcv")}, true},
+		{"output is a chunk", DecryptRequest{InputFile: volumeChunk, OutputFile: filepath.Join(tmpDir, "backup.pcv.1"), Recombine: true}, true},
+		{"output is the recombined volume", DecryptRequest{InputFile: volumeChunk, OutputFile: filepath.Join(tmpDir, "backup.pcv"), Recombine: true}, true},
+		{"distinct output", DecryptRequest{InputFile: volumeChunk, OutputFile: filepath.Join(tmpDir, "backup"), Recombine: true}, false},
+		{"discarded output", DecryptRequest{InputFile: input, OutputFile: input, DiscardOutput: true}, false},
+	}
+	for _, tt := range decrypts {
+		t.Run("decrypt/"+tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Password = "same_password"
+			err := req.Validate()
+			if got := errors.Is(err, errors.ErrSameInputOutput); got != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want ErrSameInputOutput: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			req.Reporter = &GoldenTestReporter{}
+			req.RSCodecs = rsCodecs
+			if err := Decrypt(context.Background(), &req); !errors.Is(err, errors.ErrSameInputOutput) {
+				t.Errorf("Decrypt() error = %v, want ErrSameInputOutput", err)
+			}
+		})
+	}
+
+	// A hard link is the same file under another name
+	link := filepath.Join(tmpDir, "link.txt")
+	if err := os.Link(input, link); err == nil {
+		req := &EncryptRequest{InputFile: input, OutputFile: link, Password: "same_password"}
+		if err := req.Validate(); !errors.Is(err, errors.ErrSameInputOutput) {
+			t.Errorf("hard link: Validate() error = %v, want ErrSameInputOutput", err)
+		}
+	}
+
+	// Nothing was overwritten
+	for _, path := range []string{input, incomplete, chunk, stale, volumeChunk} {
+		if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, content) {
+			t.Errorf("%s was modified (err: %v)", path, err)
+		}
+	}
+}
+
+func TestKeyfileInInput(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	folder := filepath.Join(tmpDir, "folder")
+	outside := filepath.Join(tmpDir, "folder-keys")
+	for _, dir := range []string{filepath.Join(folder, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path string) string {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	data := write(filepath.Join(folder, "data.txt"))
+	inFolder := write(filepath.Join(folder, "key.bin"))
+	nested := write(filepath.Join(folder, "sub", "key.bin"))
+	outsideKey := write(filepath.Join(outside, "key.bin"))
+	folderInputs := []string{data, inFolder, nested}
+
+	tests := []struct {
+		name    string
+		req     EncryptRequest
+		wantErr bool
+	}{
+		{"keyfile in folder", EncryptRequest{InputFiles: folderInputs, OnlyFolders: []string{folder}, Keyfiles: []string{inFolder}}, true},
+		{"keyfile in subfolder", EncryptRequest{InputFiles: folderInputs, OnlyFolders: []string{folder}, Keyfiles: []string{outsideKey, nested}}, true},
+		{"keyfile is the input", EncryptRequest{InputFile: inFolder, Keyfiles: []string{inFolder}}, true},
+		{"keyfile outside folder", EncryptRequest{InputFiles: folderInputs, OnlyFolders: []string{folder}, Keyfiles: []string{outsideKey}}, false},
+		{"keyfile beside the input", EncryptRequest{InputFile: data, Keyfiles: []string{inFolder}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.OutputFile = filepath.Join(tmpDir, strings.ReplaceAll(tt.name, " ", "-")+".pcv")
+			req.Password = "keyfile_password"
+			req.Reporter = &GoldenTestReporter{}
+			req.RSCodecs = rsCodecs
+
+			err := req.Validate()
+			if got := errors.Is(err, errors.ErrKeyfileInInput); got != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want ErrKeyfileInInput: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if err := Encrypt(context.Background(), &req); !errors.Is(err, errors.ErrKeyfileInInput) {
+					t.Errorf("Encrypt() error = %v, want ErrKeyfileInInput", err)
+				}
+				if _, err := os.Stat(req.OutputFile); !os.IsNotExist(err) {
+					t.Error("a volume was written")
+				}
+				return
+			}
+			if err := Encrypt(context.Background(), &req); err != nil {
+				t.Errorf("Encrypt() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestMaxKeyfiles(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "data.txt")
+	if err := os.WriteFile(input, []byte("many keyfiles"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	volumePath := filepath.Join(tmpDir, "data.txt.pcv")
+	if err := os.WriteFile(volumePath, []byte("not read"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keyfiles := make([]string, DefaultMaxKeyfiles+1)
+	for i := range keyfiles {
+		keyfiles[i] = filepath.Join(tmpDir, fmt.Sprintf("key%02d", i))
+		if err := os.WriteFile(keyfiles[i], []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		keyfiles    int
+		maxKeyfiles int
+		wantErr     bool
+		wantDecErr  bool // Decryption has no default limit
+	}{
+		{"default limit", DefaultMaxKeyfiles, 0, false, false},
+		{"over default limit", DefaultMaxKeyfiles + 1, 0, true, false},
+		{"limit lifted", DefaultMaxKeyfiles + 1, -1, false, false},
+		{"custom limit", 2, 2, false, false},
+		{"over custom limit", 3, 2, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := EncryptRequest{
+				InputFile:   input,
+				OutputFile:  filepath.Join(tmpDir, strings.ReplaceAll(tt.name, " ", "-")+".pcv"),
+				Keyfiles:    keyfiles[:tt.keyfiles],
+				MaxKeyfiles: tt.maxKeyfiles,
+				Reporter:    &GoldenTestReporter{},
+				RSCodecs:    rsCodecs,
+			}
+			dec := DecryptRequest{
+				InputFile:   volumePath,
+				OutputFile:  filepath.Join(tmpDir, strings.ReplaceAll(tt.name, " ", "-")+".txt"),
+				Keyfiles:    keyfiles[:tt.keyfiles],
+				MaxKeyfiles: tt.maxKeyfiles,
+				Reporter:    &GoldenTestReporter{},
+				RSCodecs:    rsCodecs,
+			}
+
+			if got := errors.Is(enc.Validate(), errors.ErrTooManyKeyfiles); got != tt.wantErr {
+				t.Errorf("EncryptRequest.Validate() ErrTooManyKeyfiles = %v, want %v", got, tt.wantErr)
+			}
+			if got := errors.Is(dec.Validate(), errors.ErrTooManyKeyfiles); got != tt.wantDecErr {
+				t.Errorf("DecryptRequest.Validate() ErrTooManyKeyfiles = %v, want %v", got, tt.wantDecErr)
+			}
+			if tt.wantErr {
+				if err := Encrypt(context.Background(), &enc); !errors.Is(err, errors.ErrTooManyKeyfiles) {
+					t.Errorf("Encrypt() error = %v, want ErrTooManyKeyfiles", err)
+				}
+				if _, err := os.Stat(enc.OutputFile + ".incomplete"); !os.IsNotExist(err) {
+					t.Error("a volume was started")
+				}
+			}
+			if !tt.wantDecErr {
+				return
+			}
+			if err := Decrypt(context.Background(), &dec); !errors.Is(err, errors.ErrTooManyKeyfiles) {
+				t.Errorf("Decrypt() error = %v, want ErrTooManyKeyfiles", err)
+			}
+		})
+	}
+}
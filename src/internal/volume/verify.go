@@ -0,0 +1,139 @@
+package volume
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	perrors "Picocrypt-NG/internal/errors"
+	"Picocrypt-NG/internal/log"
+)
+
+// testHookBeforeVerify, if set, is called with the output path after an
+// encryption has been finalized and before the post-write verification runs.
+// Tests use it to simulate corruption introduced by the storage layer.
+var testHookBeforeVerify func(outputFile string)
+
+// Verify checks that a volume authenticates with the given credentials
+// without writing any plaintext to disk. It verifies the header and then
+// computes the payload MAC over the ciphertext, the same way the first pass
+// of VerifyFirst decryption does. OutputFile, AutoUnzip and SameLevel are
+// ignored. Temporary files created for split or deniable volumes are removed
+// before returning.
+func Verify(ctx context.Context, req *DecryptRequest) error {
+	opCtx := NewDecryptContext(ctx, req)
+	defer opCtx.Close() // Secure zeroing of key material
+	defer cleanupVerify(opCtx)
+
+	log.Info("starting verification", log.String("input", req.InputFile))
+
+	if err := decryptPreprocess(opCtx, req); err != nil {
+		return err
+	}
+	if err := decryptReadHeader(opCtx, req); err != nil {
+		return err
+	}
+	if err := decryptDeriveKeys(opCtx, req); err != nil {
+		return err
+	}
+	if err := decryptProcessKeyfiles(opCtx, req); err != nil {
+		return err
+	}
+	if err := decryptVerifyAuth(opCtx, req); err != nil {
+		return err
+	}
+	if err := decryptCheckKeyfiles(opCtx, req); err != nil {
+		return err
+	}
+	if err := decryptVerifyMACFirst(opCtx, req); err != nil {
+		return err
+	}
+
+	opCtx.SetStatus("Integrity verified")
+	log.Info("verification completed successfully")
+	return nil
+}
+
+// encryptVerifyOutput re-opens the volume written by Encrypt and verifies it
+// with the same credentials. A zipped input is decrypted in full and every
+// input file must be in the archive at its size on disk, so DeleteInputs never
+// removes a file the volume does not hold. Any failure, including
+// cancellation, is wrapped in ErrPostWriteVerifyFailed; the volume itself is
+// left in place.
+func encryptVerifyOutput(ctx *OperationContext, req *EncryptRequest) error {
+	if testHookBeforeVerify != nil {
+		testHookBeforeVerify(req.OutputFile)
+	}
+
+	ctx.SetStatus("Verifying written volume...")
+	dreq := &DecryptRequest{
+		InputFile:   req.OutputFile,
+		Password:    req.Password,
+		Keyfiles:    req.Keyfiles,
+		Recombine:   req.Split,
+		Deniability: req.Deniability,
+		AAD:         req.AAD,
+		Pepper:      req.Pepper,
+		FS:          req.FS,
+		Reporter:    ctx.Reporter,
+		RSCodecs:    req.RSCodecs,
+	}
+	var err error
+	if needsZip(req) {
+		err = verifyArchiveEntries(ctx, req, dreq)
+	} else {
+		err = Verify(ctx.Ctx, dreq)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", perrors.ErrPostWriteVerifyFailed, err)
+	}
+	return nil
+}
+
+// verifyArchiveEntries decrypts the zipped volume of req and checks that it
+// holds each input file under its archive name and at its current size.
+func verifyArchiveEntries(ctx *OperationContext, req *EncryptRequest, dreq *DecryptRequest) error {
+	want, err := indexEntries(ctx.FS, req)
+	if err != nil {
+		return err
+	}
+
+	reader, realNames, done, err := decryptArchive(ctx.Ctx, dreq)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	sizes := make(map[string]uint64, len(reader.File))
+	for _, f := range reader.File {
+		name := f.Name
+		if realNames != nil {
+			name = realNames[f]
+		}
+		sizes[name] = f.UncompressedSize64
+	}
+	for _, entry := range want {
+		size, ok := sizes[entry.Name]
+		if !ok {
+			return fmt.Errorf("%s is missing from the archive", entry.Name)
+		}
+		if size != uint64(entry.Size) {
+			return fmt.Errorf("%s is %d bytes in the archive but %d on disk", entry.Name, size, entry.Size)
+		}
+	}
+	return nil
+}
+
+// cleanupVerify removes the temporary files a verification pass may create.
+// Unlike cleanupDecrypt there is no output file to remove.
+func cleanupVerify(ctx *OperationContext) {
+	if ctx.TempFile != "" {
+		_ = os.Remove(ctx.TempFile)
+	}
+	if ctx.RecombinedFile != "" && ctx.RecombinedFile != ctx.TempFile {
+		_ = os.Remove(ctx.RecombinedFile)
+	}
+	if ctx.DearmoredFile != "" {
+		_ = os.Remove(ctx.DearmoredFile)
+	}
+}
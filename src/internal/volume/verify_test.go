@@ -0,0 +1,94 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+	perrors "Picocrypt-NG/internal/errors"
+)
+
+// TestEncryptVerifyAfterWrite tests that a normal encryption passes post-write verification
+func TestEncryptVerifyAfterWrite(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "verify_after.txt")
+	if err := os.WriteFile(inputPath, []byte("post-write verification test data"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := inputPath + ".pcv"
+
+	req := &EncryptRequest{
+		InputFile:          inputPath,
+		OutputFile:         encryptedPath,
+		Password:           "verify_after_password",
+		ReedSolomon:        true,
+		VerifyAfterEncrypt: true,
+		Reporter:           &GoldenTestReporter{},
+		RSCodecs:           rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); err != nil {
+		t.Fatalf("Encrypt with VerifyAfterEncrypt failed: %v", err)
+	}
+
+	if _, err := os.Stat(encryptedPath); err != nil {
+		t.Fatalf("Volume missing after verified encrypt: %v", err)
+	}
+}
+
+// TestEncryptVerifyAfterWriteCorrupted tests that corruption introduced between
+// write and verify is reported and the volume is kept
+func TestEncryptVerifyAfterWriteCorrupted(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "verify_corrupt.txt")
+	if err := os.WriteFile(inputPath, []byte("this payload will be corrupted after writing"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	encryptedPath := inputPath + ".pcv"
+
+	// Flip the last payload byte after the volume is finalized
+	testHookBeforeVerify = func(path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("Failed to read volume in hook: %v", err)
+			return
+		}
+		data[len(data)-1] ^= 0xFF
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Errorf("Failed to write volume in hook: %v", err)
+		}
+	}
+	t.Cleanup(func() { testHookBeforeVerify = nil })
+
+	req := &EncryptRequest{
+		InputFile:          inputPath,
+		OutputFile:         encryptedPath,
+		Password:           "verify_corrupt_password",
+		VerifyAfterEncrypt: true,
+		Reporter:           &GoldenTestReporter{},
+		RSCodecs:           rsCodecs,
+	}
+	err = Encrypt(context.Background(), req)
+	if !errors.Is(err, perrors.ErrPostWriteVerifyFailed) {
+		t.Fatalf("Expected ErrPostWriteVerifyFailed, got: %v", err)
+	}
+	if !errors.Is(err, perrors.ErrAuthFailed) {
+		t.Errorf("Expected underlying ErrAuthFailed, got: %v", err)
+	}
+
+	if _, err := os.Stat(encryptedPath); err != nil {
+		t.Errorf("Volume should be kept after failed verification: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package volume
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Picocrypt-NG/internal/encoding"
+)
+
+// TestEncryptWarnsIncompressible tests that a file stored uncompressed is
+// reported through the reporter's Warn rather than the status line
+func TestEncryptWarnsIncompressible(t *testing.T) {
+	rsCodecs, err := encoding.NewRSCodecs()
+	if err != nil {
+		t.Fatalf("Failed to create RS codecs: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	random := make([]byte, 256*1024)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	randomPath := filepath.Join(tmpDir, "random.bin")
+	textPath := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(randomPath, random, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(textPath, []byte(strings.Repeat("Picocrypt ", 25000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reporter := &GoldenTestReporter{}
+	req := &EncryptRequest{
+		InputFiles:         []string{randomPath, textPath},
+		OnlyFiles:          []string{randomPath, textPath},
+		OutputFile:         filepath.Join(tmpDir, "encrypted.zip.pcv"),
+		Password:           "warn_password",
+		Compress:           true,
+		SkipIncompressible: true,
+		Reporter:           reporter,
+		RSCodecs:           rsCodecs,
+	}
+	if err := Encrypt(context.Background(), req); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	if len(reporter.warnings) != 1 || !strings.Contains(reporter.warnings[0], "random.bin appears already compressed") {
+		t.Errorf("warnings = %q; want one advisory for random.bin", reporter.warnings)
+	}
+}